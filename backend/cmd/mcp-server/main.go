@@ -1,15 +1,31 @@
 package main
 
 import (
-	"log"
+	"log/slog"
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/config"
 	"healthcare-backend/pkg/database"
+	"healthcare-backend/pkg/logging"
 	"healthcare-backend/pkg/mcp"
+	"healthcare-backend/pkg/privacy"
 	"healthcare-backend/pkg/repositories"
 	"healthcare-backend/pkg/services"
 )
 
 func main() {
-	database.InitDB()
+	cfg := config.Load()
+	if err := logging.Setup(cfg.LogLevel, cfg.LogFormat == "text"); err != nil {
+		logging.Fatal("invalid LOG_LEVEL", "error", err)
+	}
+
+	fieldKeys, err := cfg.FieldKeys()
+	if err != nil {
+		logging.Fatal("invalid field encryption key", "error", err)
+	}
+	privacy.UseFieldKeys(fieldKeys)
+
+	// The API server owns migrations; this process only checks compatibility
+	database.InitDB(cfg.Database(), false)
 
 	// Repositories
 	patientRepo := repositories.NewPatientRepository(database.DB)
@@ -17,12 +33,24 @@ func main() {
 
 	// Services
 	ragService := services.NewRAGService(patientRepo, feedbackRepo)
+	ragService.K = cfg.RAGTopK
+	ragService.MaxDistance = cfg.RAGMaxDistance
+	ragService.MaxCandidates = cfg.RAGMaxCandidates
+	if ragService.Weights, err = services.ParseRAGWeights(cfg.RAGWeights); err != nil {
+		logging.Fatal("invalid RAG_WEIGHTS", "error", err)
+	}
+	if cfg.EmbeddingURL != "" {
+		client := services.NewOpenAIEmbeddingClient(cfg.EmbeddingURL, cfg.EmbeddingModel, cfg.EmbeddingAPIKey)
+		ragService.Notes = services.NewNoteEmbeddingService(database.DB, client, cfg.EmbeddingModel, nil)
+		ragService.NoteWeight = cfg.RAGNoteWeight
+	}
 
 	// MCP Server
-	mcpServer := mcp.NewMCPServer(database.DB, ragService)
+	actor := auditctx.Identity{ID: cfg.MCPActorID, Role: auditctx.RoleService}
+	mcpServer := mcp.NewMCPServer(database.DB, ragService, services.NewAuditService(database.DB), actor)
 
-	log.Println("🚀 Healthcare Clinical MCP Server starting on stdio...")
+	slog.Info("mcp server starting on stdio")
 	if err := mcpServer.Serve(); err != nil {
-		log.Fatalf("MCP Server error: %v", err)
+		logging.Fatal("mcp server stopped", "error", err)
 	}
 }
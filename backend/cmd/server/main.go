@@ -1,7 +1,11 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"math/rand"
 	"os"
 	"os/signal"
 	"syscall"
@@ -13,26 +17,72 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/contrib/websocket"
 
+	"healthcare-backend/pkg/adapters"
+	"healthcare-backend/pkg/auditctx"
 	"healthcare-backend/pkg/blockchain"
 	"healthcare-backend/pkg/cache"
+	"healthcare-backend/pkg/chaos"
 	"healthcare-backend/pkg/config"
 	"healthcare-backend/pkg/database"
+	"healthcare-backend/pkg/flags"
 	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/jobs"
+	"healthcare-backend/pkg/lifecycle"
+	"healthcare-backend/pkg/locks"
+	"healthcare-backend/pkg/logging"
 	"healthcare-backend/pkg/middleware"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/privacy"
+	"healthcare-backend/pkg/privacy/dp"
 	"healthcare-backend/pkg/queue"
 	"healthcare-backend/pkg/repositories"
 	"healthcare-backend/pkg/services"
+	"healthcare-backend/pkg/terminology"
+	"healthcare-backend/pkg/tracing"
 	"healthcare-backend/pkg/workers"
 
 	"github.com/ansrivas/fiberprometheus/v2"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 func main() {
+	migrateOnly := flag.Bool("migrate", false, "apply pending schema migrations and exit")
+	flag.Parse()
+
 	// Load configuration
 	cfg := config.Load()
+	if err := logging.Setup(cfg.LogLevel, cfg.LogFormat == "text"); err != nil {
+		logging.Fatal("invalid LOG_LEVEL", "error", err)
+	}
+	slog.Info("config loaded", "port", cfg.ServerPort, "db_driver", cfg.DBDriver, "db_host", cfg.DBHost, "db_port", cfg.DBPort, "ml_url", cfg.MLServiceURL, "log_level", cfg.LogLevel)
+
+	if *migrateOnly {
+		if err := database.RunMigrations(cfg.Database()); err != nil {
+			logging.Fatal("migration failed", "error", err)
+		}
+		slog.Info("migrations complete")
+		return
+	}
 
-	// Initialize database (SQLite for local dev)
-	database.InitDB()
+	// Traces go to an OTLP collector only when one is configured
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.OTelEndpoint)
+	if err != nil {
+		logging.Fatal("invalid OTEL_EXPORTER_OTLP_ENDPOINT", "error", err)
+	}
+
+	// Sensitive patient fields are sealed at rest; the keys must be in place
+	// before the first row is read, the demo seed and backfills included
+	fieldKeys, err := cfg.FieldKeys()
+	if err != nil {
+		logging.Fatal("invalid field encryption key", "error", err)
+	}
+	privacy.UseFieldKeys(fieldKeys)
+	if fieldKeys == nil && cfg.AppEnv == "production" {
+		slog.Warn("FIELD_ENCRYPTION_KEY not set, patient free text is stored in plaintext")
+	}
+
+	// Initialize database (SQLite for local dev). Production never migrates on boot.
+	database.InitDB(cfg.Database(), cfg.AppEnv != "production")
 
 	// Initialize Redis (optional - will fail gracefully)
 	cache.InitRedis(cfg.RedisURL)
@@ -58,18 +108,52 @@ func main() {
 	})
 
 	// Middleware
-	app.Use(cors.New())
-	app.Use(logger.New())
+	app.Use(middleware.RequestID) // First, so every log line and audit entry of the request carries it
+	app.Use(middleware.Tracing)
+	app.Use(cors.New(cors.Config{ExposeHeaders: auditctx.RequestIDHeader}))
+	app.Use(logger.New(logger.Config{
+		Format: "${time} | ${status} | ${latency} | ${ip} | ${method} | ${path} | ${locals:" + auditctx.RequestIDLocalsKey + "} | ${error}\n",
+	}))
 	app.Use(middleware.ErrorHandler)
 	app.Use(middleware.PerformanceMiddleware)
 
+	// Audit identity: who is behind each request (JWT subject, API key, or anonymous)
+	apiKeys, err := middleware.ParseAPIKeys(cfg.APIKeys)
+	if err != nil {
+		logging.Fatal("invalid API_KEYS", "error", err)
+	}
+	tokenRevocations := services.NewTokenRevocations()
+	actorConfig := middleware.ActorConfig{JWTSecret: []byte(cfg.JWTSecret), APIKeys: apiKeys, Credentials: services.NewCredentialService(database.DB), Revocations: tokenRevocations}
+	app.Use(middleware.ResolveActor(actorConfig))
+	if cfg.AuthRequired {
+		// Kiosks authenticate with their one-time intake token instead
+		app.Use(middleware.RequireAuth("/api/auth/login", "/api/auth/refresh", "/api/auth/logout", "/api/intake/"))
+		if cfg.JWTSecret == "" {
+			slog.Warn("AUTH_REQUIRED without JWT_SECRET: only API keys can authenticate")
+		}
+	} else {
+		slog.Warn("AUTH_REQUIRED=false: /api routes are open to anonymous callers")
+	}
+
+	// Feature flags for experimental routes; defaults apply until an admin overrides them
+	featureFlags := flags.NewStore(database.DB,
+		flags.Flag{Name: "ai_services", State: flags.On},
+	)
+	if err := featureFlags.Refresh(); err != nil {
+		slog.Warn("failed to load feature flags, using defaults", "error", err)
+	}
+	app.Use(flags.Middleware(featureFlags))
+
 	// Prometheus Metrics
-	prometheus := fiberprometheus.New("healthcare-backend")
+	metricsRegistry := prometheus.NewRegistry()
+	prometheus := fiberprometheus.NewWithRegistry(metricsRegistry, "healthcare-backend", "http", "", nil)
 	prometheus.RegisterAt(app, "/metrics")
+	prometheus.SetSkipPaths([]string{middleware.SelfTestPath})
 	app.Use(prometheus.Middleware)
 
 	// Rate Limiting (Global)
 	app.Use(limiter.New(limiter.Config{
+		Next:       middleware.IsSelfTest, // Synthetic traffic never uses up real clients' budget
 		Max:        cfg.RateLimitGlobalMax,
 		Expiration: 1 * time.Minute,
 		KeyGenerator: func(c *fiber.Ctx) string {
@@ -113,82 +197,339 @@ func main() {
 		},
 	})
 
+	// Specific Limiter: Public kiosk intake (no credentials, so kept tight)
+	intakeLimiter := limiter.New(limiter.Config{
+		Max:        cfg.RateLimitIntakeMax,
+		Expiration: 1 * time.Minute,
+		KeyGenerator: func(c *fiber.Ctx) string {
+			return c.IP()
+		},
+		LimitReached: func(c *fiber.Ctx) error {
+			return c.Status(429).JSON(fiber.Map{
+				"success": false,
+				"error":   "Intake rate limit exceeded.",
+			})
+		},
+	})
+
+	// Specific Limiter: Batch assessment (each batch is up to 500 ML calls)
+	batchLimiter := limiter.New(limiter.Config{
+		Max:        cfg.RateLimitBatchMax,
+		Expiration: 1 * time.Minute,
+		KeyGenerator: func(c *fiber.Ctx) string {
+			return c.IP()
+		},
+		LimitReached: func(c *fiber.Ctx) error {
+			return c.Status(429).JSON(fiber.Map{
+				"success": false,
+				"error":   "Batch assessment rate limit exceeded. Please wait.",
+			})
+		},
+	})
+
+	// Specific Limiter: Login (slows password guessing)
+	authLimiter := limiter.New(limiter.Config{
+		Max:        cfg.RateLimitLoginMax,
+		Expiration: 1 * time.Minute,
+		KeyGenerator: func(c *fiber.Ctx) string {
+			return c.IP()
+		},
+		LimitReached: func(c *fiber.Ctx) error {
+			return c.Status(429).JSON(fiber.Map{
+				"success": false,
+				"error":   "Too many login attempts, try again later.",
+			})
+		},
+	})
+
 	// Repositories
 	patientRepo := repositories.NewPatientRepository(database.DB)
 	feedbackRepo := repositories.NewFeedbackRepository(database.DB)
+	assessmentRepo := repositories.NewAssessmentRepository(database.DB)
 
 	// Services
 	ragService := services.NewRAGService(patientRepo, feedbackRepo)
+	ragService.K = cfg.RAGTopK
+	ragService.MaxDistance = cfg.RAGMaxDistance
+	ragService.MaxCandidates = cfg.RAGMaxCandidates
+	if ragService.Weights, err = services.ParseRAGWeights(cfg.RAGWeights); err != nil {
+		logging.Fatal("invalid RAG_WEIGHTS", "error", err)
+	}
+	// Note embeddings: doctor notes count towards similarity by meaning
+	var noteEmbeddings *services.NoteEmbeddingService
+	if cfg.EmbeddingURL != "" {
+		embeddingRunner := jobs.NewRunner("note-embeddings", 2, 256, 0)
+		defer embeddingRunner.Stop()
+		client := services.NewOpenAIEmbeddingClient(cfg.EmbeddingURL, cfg.EmbeddingModel, cfg.EmbeddingAPIKey)
+		noteEmbeddings = services.NewNoteEmbeddingService(database.DB, client, cfg.EmbeddingModel, embeddingRunner)
+		ragService.Notes = noteEmbeddings
+		ragService.NoteWeight = cfg.RAGNoteWeight
+		slog.Info("note embeddings enabled", "url", cfg.EmbeddingURL, "model", cfg.EmbeddingModel, "weight", cfg.RAGNoteWeight)
+	}
 	predService := services.NewPredictionService(cfg.MLServiceURL)
+	predService.SetClient(services.NewMLClient(cfg.MLTimeout))
+	predService.LLMTimeout = cfg.LLMTimeout
+	if err := predService.SetCanary(cfg.MLCanaryURL, cfg.MLCanaryPercent); err != nil {
+		logging.Fatal("invalid ML canary config", "error", err)
+	}
+	predService.PerPatientCache = !cfg.MLCacheShared
+	predService.Store = services.NewDiagnosisStore(database.DB)
+	if err := predService.RegisterMetrics(metricsRegistry); err != nil {
+		slog.Warn("failed to register prediction metrics", "error", err)
+	}
+	if cfg.MLStrictContract {
+		contract := services.NewContractMonitor()
+		if err := contract.Register(metricsRegistry); err != nil {
+			slog.Warn("failed to register ML contract metrics", "error", err)
+		}
+		predService.SetContractMonitor(contract)
+		slog.Info("ML strict contract mode enabled")
+	}
+	// Ledger: reload the blockchain snapshot before anything writes to it
+	if err := blockchain.InitBlockchainFrom(cfg.LedgerPath, cfg.LedgerSnapshotEvery); err != nil {
+		logging.Fatal("failed to load blockchain ledger", "error", err)
+	}
+	if reason := blockchain.GlobalChain.TaintedReason(); reason != "" {
+		slog.Error("blockchain ledger tainted", "reason", reason)
+	}
 	auditService := services.NewAuditService(database.DB)
-	ipfsService := services.NewIPFSService()
+	auditService.RequireActor = cfg.AuditStrict
+	if cfg.AuditQueueSize > 0 {
+		auditService.StartWriter(cfg.AuditQueueSize)
+		if err := auditService.Register(metricsRegistry); err != nil {
+			slog.Warn("failed to register audit writer metrics", "error", err)
+		}
+	}
+	auditService.AccessBatchSize = cfg.AuditAccessBatch
+	auditService.StartAccessFlusher(cfg.AuditAccessFlush)
+	signingKey, err := services.LoadSigningKey(cfg.AuditSigningKey, cfg.AuditSigningKeyPath)
+	if err != nil {
+		logging.Fatal("failed to load audit signing key", "error", err)
+	}
+	auditService.UseSigningKey(signingKey)
+	var ipfsStore services.IPFSStore
+	var ipfsKey []byte
+	if cfg.IPFSBackupKey != "" {
+		if ipfsKey, err = services.ParseBackupKey(cfg.IPFSBackupKey); err != nil {
+			logging.Fatal("invalid IPFS_BACKUP_KEY", "error", err)
+		}
+	}
+	if cfg.IPFSAPIURL != "" {
+		if ipfsKey == nil {
+			logging.Fatal("IPFS_API_URL requires IPFS_BACKUP_KEY, or nothing backed up could be restored")
+		}
+		ipfsStore = services.NewKuboClient(cfg.IPFSAPIURL)
+		slog.Info("ipfs backups enabled", "url", cfg.IPFSAPIURL)
+	}
+	ipfsService := services.NewIPFSService(ipfsStore, ipfsKey)
+	driftService := services.NewDriftService(database.DB, cfg.ModelDriftDelta)
+
+	// Article 14: structured override reasons
+	overrideService := services.NewOverrideService(database.DB)
+	if err := overrideService.Seed(); err != nil {
+		logging.Fatal("failed to seed override reasons", "error", err)
+	}
+	if n, err := overrideService.MigrateLegacy(); err != nil {
+		slog.Warn("legacy override migration failed", "error", err)
+	} else if n > 0 {
+		slog.Info("migrated legacy overrides to \"Other (legacy)\"", "count", n)
+	}
+
+	// What flags an assessment as an emergency
+	emergencyRuleService := services.NewEmergencyRuleService(database.DB)
+	if err := emergencyRuleService.Seed(); err != nil {
+		logging.Fatal("failed to seed emergency rules", "error", err)
+	}
+
+	// Medication interaction table
+	medicationService := services.NewMedicationService(database.DB)
+	if err := medicationService.Seed(); err != nil {
+		logging.Fatal("failed to seed drug interactions", "error", err)
+	}
+	if checker, err := medicationService.Checker(); err != nil {
+		slog.Warn("failed to load drug interactions, using defaults", "error", err)
+	} else {
+		predService.Interactions = checker
+	}
+
+	// ICD-10 code table for diagnosis coding
+	icd10Service := services.NewICD10Service(database.DB)
+	if err := icd10Service.Seed(); err != nil {
+		logging.Fatal("failed to seed ICD-10 codes", "error", err)
+	}
+	if coder, err := icd10Service.Coder(); err != nil {
+		slog.Warn("failed to load ICD-10 codes, using defaults", "error", err)
+	} else {
+		predService.ICD10 = coder
+	}
+
+	// Shadow mode: mirror live predictions to a candidate model without affecting responses
+	if cfg.MLShadowURL != "" {
+		shadowRunner := jobs.NewRunner("ml-shadow", 2, 100, cfg.MLShadowRate)
+		defer shadowRunner.Stop()
+		predService.Shadow = services.NewShadowService(database.DB, cfg.MLShadowURL, cfg.MLShadowTolerance, shadowRunner)
+		slog.Info("ML shadow mode enabled", "url", cfg.MLShadowURL, "rate", cfg.MLShadowRate, "tolerance", cfg.MLShadowTolerance)
+	}
+
+	// Terminology: symptom -> SNOMED CT coding for EHR partners
+	symptomTerms, err := terminology.NewMapper()
+	if err != nil {
+		logging.Fatal("failed to load symptom terminology", "error", err)
+	}
+
+	// Privacy: PHI redaction for context sent to the LLM
+	redactionMode, err := privacy.ParseMode(cfg.PHIRedactionMode)
+	if err != nil {
+		logging.Fatal("invalid PHI_REDACTION_MODE", "error", err)
+	}
+	redactor, err := privacy.NewRedactor(redactionMode, cfg.PHICustomPatterns)
+	if err != nil {
+		logging.Fatal("invalid PHI_CUSTOM_PATTERNS", "error", err)
+	}
 
 	// Workers
 	llmWorker := workers.NewLLMWorker(cfg.MLServiceURL)
+	llmWorker.Diagnoses = predService.Diagnoses
+	llmWorker.Failures = services.NewDiagnosisFailureService(database.DB)
+	llmWorker.Coder = predService.CodeDiagnosis
+	llmWorker.Store = predService.Store
+	llmWorker.MaxRetries = cfg.LLMMaxRetries
+	llmWorker.RetryBackoff = cfg.LLMRetryBackoff
+	llmWorker.Client = services.NewMLClient(cfg.LLMTimeout)
+	llmWorker.Concurrency = cfg.LLMWorkerConcurrency
+	llmWorker.Metrics = predService.Metrics
+	if err := llmWorker.RegisterMetrics(metricsRegistry); err != nil {
+		slog.Warn("failed to register LLM queue metrics", "error", err)
+	}
+	if err := predService.Diagnoses.Register(metricsRegistry); err != nil {
+		slog.Warn("failed to register diagnosis metrics", "error", err)
+	}
 	llmWorker.Start()
 
 	// Handlers
 	wsHandler := handlers.NewWebSocketHandler()
 	wsHandler.StartGlobalListener() // Listen for Redis updates
-	patientHandler := handlers.NewPatientHandler(database.DB, ragService, predService, wsHandler, auditService)
-	feedbackHandler := handlers.NewFeedbackHandler(database.DB, auditService)
-	diseaseHandler := handlers.NewDiseaseHandler(predService)
-	ekgHandler := handlers.NewEKGHandler(predService)
-	vitalsHandler := handlers.NewVitalsHandler(predService) // [NEW] Vitals Handler
+	wsHandler.MaxIdle = cfg.WSMaxIdle
+	wsHandler.StartSweeper(time.Minute) // Close idle sockets, drop finished subscriptions
+	// Diagnosis tasks are saved with their assessment and sent once it commits
+	outbox := services.NewOutbox(database.DB, predService.DeliverDiagnosis(wsHandler.BroadcastDiagnosis))
+	outbox.Start(cfg.OutboxInterval)
+	predService.Outbox = outbox
+	patientHandler := handlers.NewPatientHandler(database.DB, patientRepo, assessmentRepo, ragService, predService, wsHandler, auditService, redactor, symptomTerms)
+	providerService := services.NewProviderService(database.DB)
+	patientHandler.Providers = providerService
+	notificationService := services.NewNotificationService(database.DB, providerService, wsHandler, cfg.DefaultClinic)
+	patientHandler.Notifications = notificationService
+	patientHandler.EmergencyRules = emergencyRuleService
+	if len(cfg.EmergencyWebhookURLs) > 0 {
+		webhooks := services.NewWebhookNotifier(database.DB, cfg.EmergencyWebhookURLs)
+		webhooks.Retries = cfg.EmergencyWebhookRetries
+		webhooks.Backoff = cfg.EmergencyWebhookBackoff
+		patientHandler.Webhooks = webhooks
+		patientHandler.PublicURL = cfg.PublicURL
+	}
+	patientHandler.StreamMax = cfg.DiagnosisStreamMax
+	patientHandler.MaxDiagnosisWait = cfg.DiagnosisMaxWait
+	patientHandler.BatchWorkers = cfg.BatchWorkers
+	diagnosisFailureHandler := handlers.NewDiagnosisFailureHandler(database.DB, llmWorker.Failures, predService, wsHandler, auditService)
+	workerHandler := handlers.NewWorkerHandler(llmWorker)
+	emergencyRuleHandler := handlers.NewEmergencyRuleHandler(emergencyRuleService, auditService)
+	clinicHandler := handlers.NewClinicHandler(notificationService.Clinics, notificationService, auditService)
+	authHandler := handlers.NewAuthHandler(services.NewUserService(database.DB), auditService, tokenRevocations, []byte(cfg.JWTSecret))
+	authHandler.TokenTTL = cfg.JWTTTL
+	authHandler.RefreshTTL = cfg.RefreshTTL
+
+	// One assessment per patient at a time; Redis makes it hold across replicas
+	var sharedLocks locks.Backend
+	if cache.Ping() == nil {
+		sharedLocks = locks.NewRedisBackend(cache.RedisClient)
+	}
+	lockWait := time.Duration(cfg.AssessLockWaitMs) * time.Millisecond
+	if cfg.AssessLockMode == "reject" {
+		lockWait = 0
+	}
+	patientHandler.Locks = locks.NewPatientLocks(sharedLocks, time.Duration(cfg.AssessLockTTLSec)*time.Second, lockWait)
+	providerHandler := handlers.NewProviderHandler(providerService, auditService, wsHandler)
+	if cfg.AssessBudgetMs > 0 {
+		completionRunner := jobs.NewRunner("assessment-completion", 4, 256, 0)
+		defer completionRunner.Stop()
+		patientHandler.Budget = time.Duration(cfg.AssessBudgetMs) * time.Millisecond
+		patientHandler.Completions = completionRunner
+	}
+	feedbackHandler := handlers.NewFeedbackHandler(database.DB, feedbackRepo, overrideService, auditService)
+	feedbackHandler.ICD10 = icd10Service
+	feedbackHandler.Embeddings = noteEmbeddings
+	feedbackHandler.Providers = providerService
+	overrideHandler := handlers.NewOverrideHandler(overrideService, auditService)
+	fhirAdapter := adapters.NewFHIRAdapter()
+	if cfg.PublicURL != "" {
+		fhirAdapter.BaseURL = cfg.PublicURL + "/api/fhir"
+	}
+	fhirHandler := handlers.NewFHIRHandler(patientHandler, feedbackRepo, fhirAdapter)
+	hl7Handler := handlers.NewHL7Handler(patientHandler)
+	exportHandler := handlers.NewExportHandler(services.NewExportService(database.DB), auditService, providerService)
+	importHandler := handlers.NewImportHandler(services.NewImportService(patientRepo, auditService), wsHandler)
+	erasureHandler := handlers.NewErasureHandler(services.NewErasureService(database.DB, predService), auditService, wsHandler)
+	intakeHandler := handlers.NewIntakeHandler(services.NewIntakeService(database.DB, time.Duration(cfg.IntakeTokenTTLMinutes)*time.Minute), auditService, wsHandler)
+	assessmentHandler := handlers.NewAssessmentHandler(assessmentRepo)
+	timelineHandler := handlers.NewTimelineHandler(services.NewTimelineService(database.DB, auditService))
+	searchHandler := handlers.NewSearchHandler(services.NewSearchService(database.DB), auditService, providerService)
+	diagnosisPromptHandler := handlers.NewDiagnosisPromptHandler(database.DB, assessmentRepo, auditService)
+	backupHandler := handlers.NewBackupHandler(services.NewBackupService(database.DB, cfg.BackupDir, cfg.BackupMaxBytes), auditService)
+	diseaseHandler := handlers.NewDiseaseHandler(predService, symptomTerms)
+	ekgHandler := handlers.NewEKGHandler(database.DB, predService)
+	urgencyHandler := handlers.NewUrgencyHandler(predService)
+	schemaHandler := handlers.NewSchemaHandler()
+	selfTestHandler := handlers.NewSelfTestHandler(services.NewSelfTestService(predService))
+	uploadService := services.NewUploadService(database.DB, cfg.UploadDir, cfg.UploadRetention)
+	vitalsHandler := handlers.NewVitalsHandler(predService, uploadService) // [NEW] Vitals Handler
 	blockchainHandler := handlers.NewBlockchainHandler(auditService, ipfsService)
 	dashboardHandler := handlers.NewDashboardHandler(database.DB, predService, auditService)
+	dashboardHandler.LLMWorker = llmWorker
+	dashboardHandler.Thresholds = models.RiskThresholds{Medium: cfg.RiskMediumThreshold, High: cfg.RiskHighThreshold}
+	if err := services.ValidateRiskThresholds(dashboardHandler.Thresholds); err != nil {
+		logging.Fatal("invalid RISK_MEDIUM_THRESHOLD/RISK_HIGH_THRESHOLD", "error", err)
+	}
+	analyticsHandler := handlers.NewAnalyticsHandler(services.NewCohortService(database.DB),
+		services.NewPrivacyBudgetService(database.DB, cfg.DPBudgetEpsilon),
+		dp.NewMechanism(rand.NewSource(time.Now().UnixNano())), cfg.DPDefaultEpsilon)
+	flagsHandler := handlers.NewFlagsHandler(featureFlags, auditService)
+	adminHandler := handlers.NewAdminHandler(redactor, driftService, predService, auditService, symptomTerms)
 
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.SendString("🏥 Healthcare Clinical Copilot | Phase 8 (Scalability Stack)")
 	})
 
 	// 7. Health Probes (K8s Ready)
-	app.Get("/health/live", func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{"status": "live", "uptime": "ok"})
-	})
-
-	app.Get("/health/ready", func(c *fiber.Ctx) error {
-		ready := true
-		dependencies := fiber.Map{}
-
-		// DB Check
-		sqlDB, _ := database.DB.DB()
-		if err := sqlDB.Ping(); err != nil {
-			ready = false
-			dependencies["db"] = "unhealthy"
-		} else {
-			dependencies["db"] = "healthy"
-		}
-
-		// Redis Check
-		if err := cache.Ping(); err != nil {
-			ready = false
-			dependencies["redis"] = "unhealthy"
-		} else {
-			dependencies["redis"] = "healthy"
-		}
-
-		// NATS Check
-		if !queue.IsConnected() {
-			ready = false
-			dependencies["nats"] = "unhealthy"
-		} else {
-			dependencies["nats"] = "healthy"
-		}
-
-		status := 200
-		if !ready {
-			status = 503
-		}
-
-		return c.Status(status).JSON(fiber.Map{
-			"status":       map[bool]string{true: "ready", false: "not ready"}[ready],
-			"dependencies": dependencies,
-		})
-	})
+	shutdown := lifecycle.NewShutdown(cfg.ShutdownTimeout)
+	app.Get("/health/live", shutdown.Live) // 503 with the phase once shutdown starts
+
+	// Redis and NATS have fallbacks, and the ML service rule-based scores,
+	// so only REQUIRE_REDIS and REQUIRE_NATS make their outage fail readiness
+	healthHandler := handlers.NewHealthHandler(shutdown.ShuttingDown,
+		handlers.Dependency{Name: "db", Required: true, Check: func(ctx context.Context) error {
+			sqlDB, err := database.DB.DB()
+			if err != nil {
+				return err
+			}
+			return sqlDB.PingContext(ctx)
+		}},
+		handlers.Dependency{Name: "redis", Required: cfg.RequireRedis, Check: cache.PingCtx},
+		handlers.Dependency{Name: "nats", Required: cfg.RequireNATS, Check: func(context.Context) error {
+			if !queue.IsConnected() {
+				return errors.New("not connected")
+			}
+			return nil
+		}},
+		handlers.Dependency{Name: "ml", Check: predService.Primary.Ping},
+	)
+	app.Get("/health/ready", healthHandler.Ready)
 
 	// WebSocket Routes
 	if cfg.EnableWebSocket {
+		if cfg.AuthRequired {
+			app.Use("/ws", middleware.WebSocketAuth(actorConfig))
+		}
 		app.Use("/ws", func(c *fiber.Ctx) error {
 			if websocket.IsWebSocketUpgrade(c) {
 				c.Locals("allowed", true)
@@ -199,22 +540,123 @@ func main() {
 		app.Get("/ws/diagnostics", websocket.New(wsHandler.HandleConnection))
 	}
 
+	// Auth
+	app.Post("/api/auth/login", authLimiter, authHandler.Login)
+	app.Post("/api/auth/refresh", authLimiter, authHandler.Refresh)
+	app.Post("/api/auth/logout", authHandler.Logout)
+	app.Post("/api/auth/register", middleware.RequireRole(auditctx.RoleAdmin), authHandler.Register)
+
 	// API Routes
 	app.Get("/api/patients", patientHandler.GetPatients)
+	app.Get("/api/patients/export", middleware.RequireRole(auditctx.RoleAdmin, auditctx.RoleDoctor), exportHandler.ExportPatients) // Before :id
+	app.Get("/api/patients/:id", patientHandler.RequireAccess, handlers.AuditReads(auditService, services.EventPatientViewed), patientHandler.GetPatient)
+	app.Post("/api/patients/:id/assign", patientHandler.RequireAccess, providerHandler.AssignPatient)
+	app.Get("/api/providers", providerHandler.GetProviders)
+	app.Put("/api/patients/:id", patientHandler.RequireAccess, patientHandler.UpdatePatient)
+	app.Delete("/api/patients/:id", patientHandler.RequireAccess, patientHandler.DeletePatient)
+	app.Delete("/api/patients/:id/erase", middleware.RequireRole(auditctx.RoleAdmin), erasureHandler.Erase)
+	app.Post("/api/patients/import", middleware.RequireRole(auditctx.RoleAdmin), importHandler.ImportPatients)
 	app.Get("/api/defaults", patientHandler.GetDefaults)
 	app.Post("/api/assess", mlLimiter, patientHandler.AssessPatient)
-	app.Get("/api/diagnosis/:id", patientHandler.GetDiagnosis)
-	app.Post("/api/feedback", feedbackLimiter, feedbackHandler.SubmitFeedback)
-	app.Get("/api/dashboard/summary", dashboardHandler.GetSummary)
-
-	// New AI Services
-	app.Post("/api/disease/predict", diseaseHandler.Predict)
-	app.Post("/api/ekg/analyze", ekgHandler.Analyze)
-	app.Post("/api/vitals/analyze", vitalsHandler.Analyze) // [NEW] Route
+	app.Post("/api/assess/batch", middleware.RequireRole(auditctx.RoleAdmin, auditctx.RoleDoctor), batchLimiter, patientHandler.AssessBatch)
+	app.Post("/api/patients/:id/assess", patientHandler.RequireAccess, mlLimiter, patientHandler.AssessExisting)
+	app.Post("/api/patients/:id/reassess", patientHandler.RequireAccess, mlLimiter, patientHandler.Reassess)
+	app.Post("/api/intake/:token", intakeLimiter, intakeHandler.Submit)
+	app.Get("/api/diagnosis/:id", patientHandler.RequireAccess, handlers.AuditReads(auditService, services.EventDiagnosisViewed), patientHandler.GetDiagnosis)
+	app.Get("/api/diagnosis/:id/stream", patientHandler.RequireAccess, handlers.AuditReads(auditService, services.EventDiagnosisViewed), patientHandler.StreamDiagnosis)
+	app.Get("/api/patients/:id/explanations", patientHandler.RequireAccess, assessmentHandler.GetPatientExplanations)
+	app.Get("/api/patients/:id/timeline", patientHandler.RequireAccess, handlers.AuditReads(auditService, services.EventPatientViewed), timelineHandler.GetTimeline)
+	app.Get("/api/assessments/:id", patientHandler.RequireAssessmentAccess, assessmentHandler.GetAssessment)
+	app.Get("/api/assessments/:id/report", patientHandler.RequireAssessmentAccess, assessmentHandler.GetReport)
+	app.Get("/api/assessments/:id/verify", patientHandler.RequireAssessmentAccess, assessmentHandler.VerifySnapshot)
+	app.Get("/api/assessments/:id/compare/:other", patientHandler.RequireAssessmentAccess, assessmentHandler.Compare)
+	app.Get("/api/assessments/:id/feedback", middleware.RequireRole(auditctx.RoleAdmin, auditctx.RoleDoctor), patientHandler.RequireAssessmentAccess, feedbackHandler.GetAssessmentFeedback)
+	app.Post("/api/feedback", middleware.RequireRole(auditctx.RoleDoctor), feedbackLimiter, feedbackHandler.SubmitFeedback)
+	app.Get("/api/feedback", middleware.RequireRole(auditctx.RoleAdmin, auditctx.RoleDoctor), feedbackHandler.ListFeedback)
+	app.Get("/api/feedback/:id", middleware.RequireRole(auditctx.RoleAdmin, auditctx.RoleDoctor), feedbackHandler.GetFeedback)
+	app.Put("/api/feedback/:id", middleware.RequireRole(auditctx.RoleAdmin, auditctx.RoleDoctor), feedbackLimiter, feedbackHandler.AmendFeedback)
+	app.Get("/api/overrides/reasons", overrideHandler.GetReasons)
+	app.Get("/api/dashboard/summary", handlers.AuditReads(auditService, services.EventDashboardViewed), dashboardHandler.GetSummary)
+	app.Get("/api/dashboard/model-performance", dashboardHandler.GetModelPerformance)
+	app.Get("/api/dashboard/trends", handlers.AuditReads(auditService, services.EventDashboardViewed), dashboardHandler.GetTrends)
+	app.Get("/api/workers/status", workerHandler.GetStatus)
+	app.Get("/api/schema", schemaHandler.GetSchema)
+	app.Get("/api/analytics/cohort", analyticsHandler.GetCohort)
+	app.Get("/api/search", middleware.RequireRole(auditctx.RoleAdmin, auditctx.RoleDoctor), searchHandler.GetSearch)
+
+	// New AI Services (behind the ai_services flag)
+	aiServices := flags.Require("ai_services")
+	app.Post("/api/disease/predict", aiServices, diseaseHandler.Predict)
+	app.Post("/api/urgency/predict", aiServices, urgencyHandler.Predict)
+	app.Post("/api/ekg/analyze", aiServices, ekgHandler.Analyze)
+	app.Get("/api/patients/:id/ekg/trends", aiServices, patientHandler.RequireAccess, ekgHandler.GetTrends)
+	app.Post("/api/vitals/analyze", aiServices, vitalsHandler.Analyze) // [NEW] Route
+
+	// FHIR R4
+	app.Get("/api/fhir/Patient/:id/Observation", patientHandler.RequireAccess, handlers.AuditReads(auditService, services.EventPatientViewed), fhirHandler.GetObservations)
+	app.Get("/api/fhir/Patient/:id/$everything", patientHandler.RequireAccess, handlers.AuditReads(auditService, services.EventPatientViewed), fhirHandler.GetEverything)
+	app.Post("/api/fhir/import", middleware.RequireRole(auditctx.RoleAdmin, auditctx.RoleDoctor, auditctx.RoleService), mlLimiter, fhirHandler.ImportBundle)
+	app.Get("/api/fhir/AuditEvent", middleware.RequireRole(auditctx.RoleAdmin), fhirHandler.SearchAuditEvents)
+	app.Post("/api/hl7/ingest", middleware.RequireRole(auditctx.RoleAdmin, auditctx.RoleDoctor, auditctx.RoleService), hl7Handler.Ingest)
+
+	// Admin: every /api/admin route, including the self-test and chaos, is admin only
+	admin := app.Group("/api/admin", middleware.RequireRole(auditctx.RoleAdmin))
+	admin.Get("/privacy/mode", adminHandler.GetPrivacyMode)
+	admin.Put("/privacy/mode", adminHandler.SetPrivacyMode)
+	admin.Get("/model-drift", adminHandler.GetModelDrift)
+	admin.Get("/assessment-locks", patientHandler.GetLockStats)
+	admin.Get("/ws/status", wsHandler.GetStatus)
+	admin.Get("/diagnosis/:id/prompt", diagnosisPromptHandler.Get) // AI transparency
+	admin.Get("/diagnosis-failures", diagnosisFailureHandler.List)
+	admin.Post("/diagnosis-failures/:id/requeue", diagnosisFailureHandler.Requeue)
+	admin.Get("/emergency-rules", emergencyRuleHandler.List)
+	admin.Post("/emergency-rules", emergencyRuleHandler.Create)
+	admin.Put("/emergency-rules/:id", emergencyRuleHandler.Update)
+	admin.Delete("/emergency-rules/:id", emergencyRuleHandler.Delete)
+	app.Post(middleware.SelfTestPath, selfTestHandler.Run) // Under the admin group's prefix
+	admin.Post("/intake-tokens", intakeHandler.CreateToken)
+	admin.Post("/providers", providerHandler.CreateProvider)
+	admin.Put("/providers/:id", providerHandler.UpdateProvider)
+	admin.Get("/clinics", clinicHandler.GetClinics)
+	admin.Put("/clinics/:id", clinicHandler.SaveClinic)
+	admin.Get("/notifications", clinicHandler.GetNotifications)
+	app.Get("/api/notifications", middleware.RequireRole(auditctx.RoleAdmin), clinicHandler.GetNotifications) // Same log as /api/admin/notifications
+	admin.Put("/overrides/reasons/:code", overrideHandler.SaveReason)
+	admin.Get("/overrides/report", overrideHandler.GetReport)
+	app.Get("/api/compliance/overrides", middleware.RequireRole(auditctx.RoleAdmin), overrideHandler.GetCompliance) // Article 14 reporting
+	admin.Get("/ml/canary", adminHandler.GetCanary)
+	admin.Put("/ml/canary", adminHandler.SetCanaryPercent)
+	admin.Get("/shadow/report", adminHandler.GetShadowReport)
+	admin.Get("/terminology/symptoms", adminHandler.GetSymptomTerminology)
+	admin.Post("/terminology/symptoms", adminHandler.UploadSymptomTerminology)
+	admin.Post("/db/backup", backupHandler.CreateBackup)
+	admin.Get("/db/backups", backupHandler.ListBackups)
+	admin.Get("/db/backups/:name/restore", backupHandler.GetRestorePlan)
+	admin.Get("/db/backups/:name/download", backupHandler.DownloadBackup)
+	admin.Get("/uploads", vitalsHandler.GetUploadUsage)
+	admin.Get("/flags", flagsHandler.GetFlags)
+	admin.Put("/flags/:name", flagsHandler.SetFlag)
+
+	// Chaos: fault injection, never available in production
+	if cfg.EnableChaos && cfg.AppEnv != "production" {
+		chaos.Default.Enable()
+		chaosHandler := handlers.NewChaosHandler(chaos.Default, auditService)
+		admin.Get("/chaos", chaosHandler.GetFaults)
+		admin.Post("/chaos", chaosHandler.SetFault)
+		admin.Delete("/chaos", chaosHandler.ClearFaults)
+		slog.Warn("chaos fault injection enabled", "app_env", cfg.AppEnv)
+	} else if cfg.EnableChaos {
+		slog.Warn("ENABLE_CHAOS ignored in production")
+	}
 
 	// 8. Blockchain Audit Endpoints (AI Act Compliance)
 	app.Get("/api/blockchain/verify", blockchainHandler.VerifyChain)
-	app.Post("/api/blockchain/backup", blockchainHandler.BackupChain)
+	app.Post("/api/blockchain/backup", middleware.RequireRole(auditctx.RoleAdmin), blockchainHandler.BackupChain)
+	app.Post("/api/blockchain/restore", middleware.RequireRole(auditctx.RoleAdmin), blockchainHandler.RestoreChain)
+	app.Get("/api/audit/keys", blockchainHandler.ListKeys)
+	app.Get("/api/audit/export", middleware.RequireRole(auditctx.RoleAdmin), blockchainHandler.ExportAudit)
+	app.Get("/api/audit/actors/:id", blockchainHandler.ListActorEvents)
+	app.Get("/api/audit/patient/:id/access", middleware.RequireRole(auditctx.RoleAdmin), blockchainHandler.ListPatientAccess)
 	app.Get("/api/audit/chain", func(c *fiber.Ctx) error {
 		// Just for safety if called before Init
 		if blockchain.GlobalChain == nil {
@@ -232,15 +674,103 @@ func main() {
 		})
 	})
 
-	// Graceful Shutdown
+	// Periodic background work, stopped before the audit queue closes
+	jobs := lifecycle.NewJobs()
+
+	// Model drift monitor: raise a dashboard event when confidence slips
+	jobs.Every(1*time.Hour, func() {
+		alerts, err := driftService.CheckDrift(time.Now())
+		if err != nil {
+			slog.Warn("model drift check failed", "error", err)
+			return
+		}
+		for _, alert := range alerts {
+			slog.Warn("model drift", "model", alert.ModelName, "recent_mean", alert.RecentMean, "baseline_mean", alert.BaselineMean)
+			wsHandler.BroadcastAll(fiber.Map{"type": "dashboard_event", "event": "model_drift", "data": alert})
+			auditService.LogEvent("MODEL_DRIFT_ALERT", 0, alert, auditctx.System)
+		}
+	})
+
+	// Model monitoring record for the audit trail (AI Act Article 72)
+	jobs.Every(cfg.ModelCheckInterval, func() {
+		check, err := dashboardHandler.Models.Check(driftService, time.Now())
+		if err != nil {
+			slog.Warn("model performance check failed", "error", err)
+			return
+		}
+		if _, err := auditService.LogEvent("MODEL_DRIFT_CHECK", 0, check, auditctx.System); err != nil {
+			slog.Warn("audit event failed", "event", "MODEL_DRIFT_CHECK", "error", err)
+		}
+	})
+
+	// Pick up flag changes made through other replicas
+	jobs.Every(30*time.Second, func() {
+		if err := featureFlags.Refresh(); err != nil {
+			slog.Warn("feature flag refresh failed", "error", err)
+		}
+	})
+
+	// Uploads cleanup: finished analyses, abandoned uploads and orphaned files
+	jobs.Every(15*time.Minute, func() {
+		res, err := uploadService.Cleanup(time.Now())
+		if err != nil {
+			slog.Warn("upload cleanup failed", "error", err)
+			return
+		}
+		if res.Removed+res.OrphansRemoved > 0 {
+			slog.Info("upload cleanup", "removed", res.Removed, "orphans_removed", res.OrphansRemoved, "freed_bytes", res.FreedBytes)
+		}
+	})
+
+	// Deferred notifications go out when their clinic's working window opens
+	jobs.Every(1*time.Minute, func() {
+		n, err := notificationService.DispatchDue()
+		if err != nil {
+			slog.Warn("deferred notification dispatch failed", "error", err)
+			return
+		}
+		if n > 0 {
+			slog.Info("sent deferred notifications", "count", n)
+		}
+	})
+
+	// Revoked access tokens only need remembering until they expire
+	jobs.Every(5*time.Minute, tokenRevocations.Sweep)
+
+	// Graceful Shutdown, in order within SHUTDOWN_TIMEOUT. Health checks
+	// fail from the first step, so load balancers stop routing early.
+	shutdown.Add("announce", lifecycle.Delay(cfg.ShutdownDelay))
+	shutdown.Add("http", app.ShutdownWithContext) // In-flight requests finish
+	shutdown.Add("websockets", wsHandler.CloseAll)
+	shutdown.Add("jobs", jobs.Stop) // No drift check or notification starts mid-shutdown
+	// Tasks of the last assessments go out while NATS is still up
+	shutdown.Add("outbox", func(context.Context) error {
+		_, err := outbox.Dispatch()
+		return err
+	})
+	// Diagnoses under way finish and write their status; queued ones go back to NATS
+	shutdown.Add("workers", llmWorker.Stop)
+	// Queued and buffered audit events reach the database, and the chain,
+	// before its final snapshot
+	shutdown.Add("audit", auditService.Close)
+	shutdown.Add("ledger", func(context.Context) error { return blockchain.GlobalChain.Save() })
+	shutdown.Add("tracing", shutdownTracing) // The drained diagnoses' spans included
+	shutdown.Add("nats", func(context.Context) error {
+		queue.Close()
+		return nil
+	})
+	shutdown.Add("redis", func(context.Context) error { return cache.Close() })
 	go func() {
 		c := make(chan os.Signal, 1)
 		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 		<-c
-		log.Println("🛑 Graceful shutdown initiated...")
-		_ = app.Shutdown()
+		shutdown.Run()
 	}()
 
-	log.Printf("🚀 Server starting on port %s", cfg.ServerPort)
-	log.Fatal(app.Listen(":" + cfg.ServerPort))
+	slog.Info("server starting", "port", cfg.ServerPort)
+	if err := app.Listen(":" + cfg.ServerPort); err != nil {
+		logging.Fatal("server stopped", "error", err)
+	}
+	// Listen returns as the http step starts; the remaining steps follow
+	<-shutdown.Done()
 }
@@ -6,10 +6,22 @@ import (
 	"time"
 
 	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/terminology"
+)
+
+// Code systems used in the resources the adapter builds
+const (
+	LOINCSystem               = "http://loinc.org"
+	UCUMSystem                = "http://unitsofmeasure.org"
+	ObservationCategorySystem = "http://terminology.hl7.org/CodeSystem/observation-category"
 )
 
 // FHIRAdapter handles conversion between internal models and FHIR R4 resources
-type FHIRAdapter struct{}
+type FHIRAdapter struct {
+	// Prefix of bundle entries' fullUrl, e.g. https://health.example/api/fhir;
+	// empty gives relative references like Patient/pat-1
+	BaseURL string
+}
 
 // NewFHIRAdapter creates a new instance
 func NewFHIRAdapter() *FHIRAdapter {
@@ -46,6 +58,19 @@ func (f *FHIRAdapter) ToFHIRPatient(p models.PatientData) map[string]interface{}
 // ToFHIRDiagnosticReport converts our AssessmentResponse to FHIR DiagnosticReport
 // demonstrating interoperability for the hackathon
 func (f *FHIRAdapter) ToFHIRDiagnosticReport(assessment models.FullAssessmentResponse) map[string]interface{} {
+	results := []map[string]interface{}{
+		{
+			// Scores are percentages (models.ScoreScale), ML or rule-based alike
+			"display": fmt.Sprintf("Heart Risk: %.1f%%", assessment.Risks.HeartRisk),
+		},
+		{
+			"display": fmt.Sprintf("Urgency Level: %d", assessment.Urgency.UrgencyLevel),
+		},
+	}
+	for _, e := range assessment.ExplanationSummaries {
+		results = append(results, map[string]interface{}{"display": e.Summary})
+	}
+
 	return map[string]interface{}{
 		"resourceType": "DiagnosticReport",
 		"id":           fmt.Sprintf("rpt-%d", assessment.ID),
@@ -64,13 +89,336 @@ func (f *FHIRAdapter) ToFHIRDiagnosticReport(assessment models.FullAssessmentRes
 		},
 		"effectiveDateTime": time.Now().UTC().Format(time.RFC3339),
 		"conclusion":        assessment.Diagnosis,
-		"result": []map[string]interface{}{
+		"result":            results,
+	}
+}
+
+// ToFHIRSymptomObservations converts reported symptoms to FHIR Observations.
+// Mapped symptoms carry a SNOMED CT coding; unmapped ones keep only the free text.
+func (f *FHIRAdapter) ToFHIRSymptomObservations(patientID uint, coded []models.CodedSymptom, unmapped []string) []map[string]interface{} {
+	observations := []map[string]interface{}{}
+
+	newObservation := func(code map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"resourceType": "Observation",
+			"id":           fmt.Sprintf("sym-%d-%d", patientID, len(observations)+1),
+			"status":       "final",
+			"category": []map[string]interface{}{
+				{
+					"coding": []map[string]string{
+						{
+							"system": "http://terminology.hl7.org/CodeSystem/observation-category",
+							"code":   "survey",
+						},
+					},
+				},
+			},
+			"code": code,
+			"subject": map[string]string{
+				"reference": fmt.Sprintf("Patient/pat-%d", patientID),
+			},
+			"valueBoolean": true,
+		}
+	}
+
+	for _, s := range coded {
+		observations = append(observations, newObservation(map[string]interface{}{
+			"coding": []map[string]string{
+				{
+					"system":  terminology.SNOMEDSystem,
+					"code":    s.Code,
+					"display": s.Display,
+				},
+			},
+			"text": s.Text,
+		}))
+	}
+	for _, text := range unmapped {
+		observations = append(observations, newObservation(map[string]interface{}{"text": text}))
+	}
+	return observations
+}
+
+// vitalSign is a PatientData measurement with its LOINC code and UCUM unit.
+// value reads it for export and set fills it in on import.
+type vitalSign struct {
+	slug, code, display string
+	category            string
+	unit, ucum          string
+	value               func(p models.PatientData) float64
+	set                 func(in *models.PatientIntake, v float64)
+}
+
+// vitalSigns are the single-value measurements exported as Observations.
+// Blood pressure is a panel with components, see ToFHIRVitalObservations.
+var vitalSigns = []vitalSign{
+	{"glucose", "2339-0", "Glucose [Mass/volume] in Blood", "laboratory", "mg/dL", "mg/dL",
+		func(p models.PatientData) float64 { return float64(p.Glucose) },
+		func(in *models.PatientIntake, v float64) { in.Glucose = flexibleInt(v) }},
+	{"bmi", "39156-5", "Body mass index (BMI) [Ratio]", "vital-signs", "kg/m2", "kg/m2",
+		func(p models.PatientData) float64 { return p.BMI },
+		func(in *models.PatientIntake, v float64) { bmi := models.FlexibleFloat(v); in.BMI = &bmi }},
+	{"cholesterol", "2093-3", "Cholesterol [Mass/volume] in Serum or Plasma", "laboratory", "mg/dL", "mg/dL",
+		func(p models.PatientData) float64 { return float64(p.Cholesterol) },
+		func(in *models.PatientIntake, v float64) { in.Cholesterol = flexibleInt(v) }},
+	{"heart-rate", "8867-4", "Heart rate", "vital-signs", "beats/minute", "/min",
+		func(p models.PatientData) float64 { return float64(p.HeartRate) },
+		func(in *models.PatientIntake, v float64) { in.HeartRate = flexibleInt(v) }},
+}
+
+// bloodPressure are the components of the blood pressure panel, 85354-9
+var bloodPressure = []vitalSign{
+	{"systolic", "8480-6", "Systolic blood pressure", "vital-signs", "mmHg", "mm[Hg]",
+		func(p models.PatientData) float64 { return float64(p.SystolicBP) },
+		func(in *models.PatientIntake, v float64) { in.SystolicBP = flexibleInt(v) }},
+	{"diastolic", "8462-4", "Diastolic blood pressure", "vital-signs", "mmHg", "mm[Hg]",
+		func(p models.PatientData) float64 { return float64(p.DiastolicBP) },
+		func(in *models.PatientIntake, v float64) { in.DiastolicBP = flexibleInt(v) }},
+}
+
+// bloodPressurePanel is the LOINC code of the blood pressure Observation
+const bloodPressurePanel = "85354-9"
+
+// loincCode is a CodeableConcept with a single LOINC coding
+func loincCode(code, display string) map[string]interface{} {
+	return map[string]interface{}{
+		"coding": []map[string]string{
+			{"system": LOINCSystem, "code": code, "display": display},
+		},
+		"text": display,
+	}
+}
+
+// quantity is a valueQuantity in UCUM units
+func quantity(value float64, unit, ucum string) map[string]interface{} {
+	return map[string]interface{}{"value": value, "unit": unit, "system": UCUMSystem, "code": ucum}
+}
+
+// vitalObservation is the shell shared by the vital sign Observations
+func vitalObservation(p models.PatientData, slug, category string, code map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"resourceType": "Observation",
+		"id":           fmt.Sprintf("%s-%d", slug, p.ID),
+		"status":       "final",
+		"category": []map[string]interface{}{
 			{
-				"display": fmt.Sprintf("Heart Risk: %.1f%%", assessment.Risks.HeartRisk),
+				"coding": []map[string]string{
+					{"system": ObservationCategorySystem, "code": category},
+				},
+			},
+		},
+		"code": code,
+		"subject": map[string]string{
+			"reference": fmt.Sprintf("Patient/pat-%d", p.ID),
+		},
+		"effectiveDateTime": p.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// ToFHIRVitalObservations converts the patient's stored measurements to FHIR
+// Observations with LOINC codes. Measurements left for the ML model to
+// impute are stored as zero and skipped.
+func (f *FHIRAdapter) ToFHIRVitalObservations(p models.PatientData) []map[string]interface{} {
+	observations := []map[string]interface{}{}
+	if p.SystolicBP > 0 && p.DiastolicBP > 0 {
+		bp := vitalObservation(p, "bp", "vital-signs", loincCode(bloodPressurePanel, "Blood pressure panel with all children optional"))
+		components := []map[string]interface{}{}
+		for _, v := range bloodPressure {
+			components = append(components, map[string]interface{}{
+				"code":          loincCode(v.code, v.display),
+				"valueQuantity": quantity(v.value(p), v.unit, v.ucum),
+			})
+		}
+		bp["component"] = components
+		observations = append(observations, bp)
+	}
+	for _, v := range vitalSigns {
+		value := v.value(p)
+		if value <= 0 {
+			continue
+		}
+		obs := vitalObservation(p, v.slug, v.category, loincCode(v.code, v.display))
+		obs["valueQuantity"] = quantity(value, v.unit, v.ucum)
+		observations = append(observations, obs)
+	}
+	return observations
+}
+
+// ToFHIRObservations is the patient's vital sign Observations as a
+// searchset Bundle
+func (f *FHIRAdapter) ToFHIRObservations(p models.PatientData) map[string]interface{} {
+	return f.ToFHIRBundle("searchset", f.ToFHIRVitalObservations(p))
+}
+
+// ToFHIRAssessmentReport converts a stored assessment to a FHIR
+// DiagnosticReport dated when the assessment ran
+func (f *FHIRAdapter) ToFHIRAssessmentReport(a models.Assessment) map[string]interface{} {
+	results := []map[string]interface{}{
+		{"display": fmt.Sprintf("Heart Risk: %.1f%%", a.HeartRisk)},
+		{"display": fmt.Sprintf("Diabetes Risk: %.1f%%", a.DiabetesRisk)},
+		{"display": fmt.Sprintf("Stroke Risk: %.1f%%", a.StrokeRisk)},
+		{"display": fmt.Sprintf("Kidney Risk: %.1f%%", a.KidneyRisk)},
+	}
+	for _, e := range a.RiskExplanations() {
+		results = append(results, map[string]interface{}{"display": e.Summary})
+	}
+	conclusion := "Routine"
+	if a.Emergency {
+		conclusion = "Emergency"
+	}
+
+	return map[string]interface{}{
+		"resourceType": "DiagnosticReport",
+		"id":           fmt.Sprintf("rpt-%d", a.ID),
+		"status":       "final",
+		"code":         loincCode("54531-9", "Risk assessment"),
+		"subject": map[string]string{
+			"reference": fmt.Sprintf("Patient/pat-%d", a.PatientID),
+		},
+		"effectiveDateTime": a.CreatedAt.UTC().Format(time.RFC3339),
+		"conclusion":        conclusion,
+		"result":            results,
+	}
+}
+
+// ToFHIRMedicationStatements converts the patient's comma-separated
+// medication list to MedicationStatements, one per medication as entered
+func (f *FHIRAdapter) ToFHIRMedicationStatements(p models.PatientData) []map[string]interface{} {
+	statements := []map[string]interface{}{}
+	for _, name := range strings.Split(p.Medications, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		statements = append(statements, map[string]interface{}{
+			"resourceType":              "MedicationStatement",
+			"id":                        fmt.Sprintf("med-%d-%d", p.ID, len(statements)+1),
+			"status":                    "active",
+			"medicationCodeableConcept": map[string]string{"text": name},
+			"subject": map[string]string{
+				"reference": fmt.Sprintf("Patient/pat-%d", p.ID),
 			},
+			"dateAsserted": p.CreatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	return statements
+}
+
+// ToFHIRFeedback converts a doctor's review of an assessment to a
+// ClinicalImpression holding the notes and a Provenance recording that a
+// clinician approved or overrode target, e.g. DiagnosticReport/rpt-3
+func (f *FHIRAdapter) ToFHIRFeedback(fb models.Feedback, target string) []map[string]interface{} {
+	recorded := fb.CreatedAt.UTC().Format(time.RFC3339)
+	outcome := "overridden"
+	if fb.DoctorApproved {
+		outcome = "approved"
+	}
+
+	impression := map[string]interface{}{
+		"resourceType": "ClinicalImpression",
+		"id":           fmt.Sprintf("ci-%d", fb.ID),
+		"status":       "completed",
+		"description":  fmt.Sprintf("Clinician review of the AI risk assessment: %s", outcome),
+		"subject": map[string]string{
+			"reference": fmt.Sprintf("Patient/pat-%d", fb.PatientID),
+		},
+		"date":           recorded,
+		"supportingInfo": []map[string]string{{"reference": target}},
+	}
+	if fb.DoctorNotes != "" {
+		impression["summary"] = fb.DoctorNotes
+	}
+
+	provenance := map[string]interface{}{
+		"resourceType": "Provenance",
+		"id":           fmt.Sprintf("prov-%d", fb.ID),
+		"target":       []map[string]string{{"reference": target}},
+		"recorded":     recorded,
+		"activity":     map[string]string{"text": outcome},
+		"agent": []map[string]interface{}{
 			{
-				"display": fmt.Sprintf("Urgency Level: %d", assessment.Urgency.UrgencyLevel),
+				"type": map[string]interface{}{
+					"coding": []map[string]string{
+						{
+							"system":  "http://terminology.hl7.org/CodeSystem/v3-ParticipationType",
+							"code":    "VRF",
+							"display": "verifier",
+						},
+					},
+				},
+				"who": map[string]string{"display": "Reviewing clinician"},
 			},
 		},
+		"entity": []map[string]interface{}{
+			{"role": "source", "what": map[string]string{"reference": fmt.Sprintf("ClinicalImpression/ci-%d", fb.ID)}},
+		},
+	}
+	return []map[string]interface{}{impression, provenance}
+}
+
+// ToFHIREverything is the patient's whole record as a collection Bundle:
+// the Patient, vital sign Observations, a DiagnosticReport per assessment,
+// MedicationStatements, and each piece of feedback as a ClinicalImpression
+// with its Provenance. Feedback targets the latest report issued before it,
+// or the Patient when there was none.
+func (f *FHIRAdapter) ToFHIREverything(p models.PatientData, assessments []models.Assessment, feedback []models.Feedback) map[string]interface{} {
+	resources := []map[string]interface{}{f.ToFHIRPatient(p)}
+	resources = append(resources, f.ToFHIRVitalObservations(p)...)
+	for _, a := range assessments {
+		resources = append(resources, f.ToFHIRAssessmentReport(a))
+	}
+	resources = append(resources, f.ToFHIRMedicationStatements(p)...)
+	for _, fb := range feedback {
+		target := fmt.Sprintf("Patient/pat-%d", p.ID)
+		for _, a := range assessments {
+			if a.CreatedAt.After(fb.CreatedAt) {
+				break
+			}
+			target = fmt.Sprintf("DiagnosticReport/rpt-%d", a.ID)
+		}
+		resources = append(resources, f.ToFHIRFeedback(fb, target)...)
+	}
+	return f.ToFHIRBundle("collection", resources)
+}
+
+// ToFHIRBundle wraps resources in a Bundle of the given type. Entries get a
+// fullUrl under BaseURL; searchset entries are marked as matches.
+func (f *FHIRAdapter) ToFHIRBundle(bundleType string, resources []map[string]interface{}) map[string]interface{} {
+	entries := make([]map[string]interface{}, len(resources))
+	for i, r := range resources {
+		entries[i] = f.BundleEntry(bundleType, r)
+	}
+	bundle := map[string]interface{}{
+		"resourceType": "Bundle",
+		"type":         bundleType,
+		"timestamp":    time.Now().UTC().Format(time.RFC3339),
+		"entry":        entries,
+	}
+	if bundleType == "searchset" {
+		bundle["total"] = len(entries)
+	}
+	return bundle
+}
+
+// BundleEntry is a resource's entry in a Bundle of the given type, for
+// callers that write bundles an entry at a time
+func (f *FHIRAdapter) BundleEntry(bundleType string, resource map[string]interface{}) map[string]interface{} {
+	entry := map[string]interface{}{
+		"fullUrl":  f.FullURL(resource),
+		"resource": resource,
+	}
+	if bundleType == "searchset" {
+		entry["search"] = map[string]string{"mode": "match"}
+	}
+	return entry
+}
+
+// FullURL is where a resource the adapter built lives, e.g. Patient/pat-1
+// under BaseURL
+func (f *FHIRAdapter) FullURL(resource map[string]interface{}) string {
+	ref := fmt.Sprintf("%v/%v", resource["resourceType"], resource["id"])
+	if f.BaseURL == "" {
+		return ref
 	}
+	return f.BaseURL + "/" + ref
 }
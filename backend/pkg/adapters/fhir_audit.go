@@ -0,0 +1,159 @@
+package adapters
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"healthcare-backend/pkg/models"
+)
+
+// Code systems of AuditEvent codings
+const (
+	DICOMSystem          = "http://dicom.nema.org/resources/ontology/DCM"
+	AuditEventTypeSystem = "http://hospital.example.org/audit-event-type" // Our event types, kept as the subtype
+)
+
+// auditEventType is the FHIR coding of one of our audit event types: a DICOM
+// audit event code and the AuditEvent.action (C, R, U, D or E)
+type auditEventType struct {
+	code, display string
+	action        string
+	failure       bool // Records something refused; outcome is a minor failure
+}
+
+// Event types the table doesn't list are application activity
+var defaultAuditEventType = auditEventType{"110100", "Application Activity", "E", false}
+
+var auditEventTypes = map[string]auditEventType{
+	"PATIENT_CREATED":             {"110110", "Patient Record", "C", false},
+	"PATIENT_UPDATED":             {"110110", "Patient Record", "U", false},
+	"PATIENT_REASSESSED":          {"110110", "Patient Record", "U", false},
+	"PATIENT_ASSIGNED":            {"110110", "Patient Record", "U", false},
+	"PATIENT_REASSIGNED":          {"110110", "Patient Record", "U", false},
+	"PATIENT_DELETED":             {"110110", "Patient Record", "D", false},
+	"ERASURE_REQUEST":             {"110110", "Patient Record", "D", false},
+	"PATIENT_VIEWED":              {"110110", "Patient Record", "R", false},
+	"DIAGNOSIS_VIEWED":            {"110110", "Patient Record", "R", false},
+	"DOCTOR_FEEDBACK":             {"110110", "Patient Record", "C", false},
+	"HUMAN_OVERRIDE":              {"110110", "Patient Record", "C", false},
+	"FEEDBACK_AMENDED":            {"110110", "Patient Record", "U", false},
+	"DASHBOARD_VIEWED":            {"110112", "Query", "R", false},
+	"NOTES_SEARCHED":              {"110112", "Query", "R", false},
+	"PATIENT_IMPORTED":            {"110107", "Import", "C", false},
+	"EXPORT":                      {"110106", "Export", "R", false},
+	"DB_BACKUP_CREATED":           {"110106", "Export", "R", false},
+	"DB_BACKUP_DOWNLOADED":        {"110106", "Export", "R", false},
+	"AUDIT_EXPORTED":              {"110101", "Audit Log Used", "R", false},
+	"BLOCKCHAIN_BACKED_UP":        {"110101", "Audit Log Used", "R", false},
+	"BLOCKCHAIN_RESTORE_VERIFIED": {"110101", "Audit Log Used", "R", false},
+	"LOGIN_FAILED":                {"110114", "User Authentication", "E", true},
+	"ACCESS_DENIED":               {"110113", "Security Alert", "E", true},
+}
+
+// Extensions carrying what R4's AuditEvent has no element for: the
+// server's Ed25519 signature and the entry's links in the hash chain
+const (
+	auditSignatureExtension = "http://fhir.example.org/StructureDefinition/audit-signature"
+	auditChainExtension     = "http://fhir.example.org/StructureDefinition/audit-chain"
+)
+
+// noPatientHash is the patient hash of entries that span patients, which
+// are logged against patient 0
+var noPatientHash = func() string {
+	h := sha256.Sum256([]byte("0"))
+	return hex.EncodeToString(h[:])
+}()
+
+// ToFHIRAuditEvent converts an audit chain entry to a FHIR AuditEvent. The
+// actor becomes the requesting agent and the patient hash the entity; the
+// signature and chain hashes travel in extensions so the entry can still be
+// verified from the FHIR copy.
+func (f *FHIRAdapter) ToFHIRAuditEvent(entry models.AuditLog) map[string]interface{} {
+	eventType, known := auditEventTypes[entry.EventType]
+	if !known {
+		eventType = defaultAuditEventType
+	}
+	outcome := "0"
+	if eventType.failure {
+		outcome = "4"
+	}
+	recorded := entry.Timestamp.UTC().Format(time.RFC3339Nano)
+
+	event := map[string]interface{}{
+		"resourceType": "AuditEvent",
+		"id":           fmt.Sprintf("audit-%d", entry.ID),
+		"type": map[string]string{
+			"system": DICOMSystem, "code": eventType.code, "display": eventType.display,
+		},
+		"subtype": []map[string]string{
+			{"system": AuditEventTypeSystem, "code": entry.EventType},
+		},
+		"action":   eventType.action,
+		"recorded": recorded,
+		"outcome":  outcome,
+		"agent": []map[string]interface{}{
+			{
+				"who": map[string]interface{}{
+					"identifier": map[string]string{"value": entry.ActorID},
+				},
+				"role":      []map[string]string{{"text": entry.ActorRole}},
+				"requestor": true,
+			},
+		},
+		"source": map[string]interface{}{
+			"observer": map[string]string{"display": "healthcare-backend"},
+		},
+	}
+	if entry.PatientIDHash != "" && entry.PatientIDHash != noPatientHash {
+		event["entity"] = []map[string]interface{}{
+			{
+				"what": map[string]interface{}{
+					"identifier": map[string]string{
+						"system": "http://hospital.example.org/patient-id-sha256",
+						"value":  entry.PatientIDHash,
+					},
+				},
+				"type": map[string]string{
+					"system": "http://terminology.hl7.org/CodeSystem/audit-entity-type", "code": "1", "display": "Person",
+				},
+				"role": map[string]string{
+					"system": "http://terminology.hl7.org/CodeSystem/object-role", "code": "1", "display": "Patient",
+				},
+			},
+		}
+	}
+
+	extensions := []map[string]interface{}{
+		{
+			"url": auditChainExtension,
+			"extension": []map[string]string{
+				{"url": "payloadHash", "valueString": entry.PayloadHash},
+				{"url": "prevHash", "valueString": entry.PrevHash},
+				{"url": "currentHash", "valueString": entry.CurrentHash},
+				{"url": "publicKey", "valueString": entry.ActorPublicKey},
+			},
+		},
+	}
+	if sig, err := hex.DecodeString(entry.ActorSignature); err == nil && len(sig) > 0 {
+		extensions = append(extensions, map[string]interface{}{
+			"url": auditSignatureExtension,
+			"valueSignature": map[string]interface{}{
+				"type": []map[string]string{
+					{"system": "urn:iso-astm:E1762-95:2013", "code": "1.2.840.10065.1.12.1.5", "display": "Verification Signature"},
+				},
+				"when": recorded,
+				"who": map[string]interface{}{
+					"identifier": map[string]string{"value": entry.KeyID},
+					"display":    "healthcare-backend",
+				},
+				"sigFormat": "application/octet-stream",
+				"data":      base64.StdEncoding.EncodeToString(sig),
+			},
+		})
+	}
+	event["extension"] = extensions
+	return event
+}
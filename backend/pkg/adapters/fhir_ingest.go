@@ -0,0 +1,319 @@
+package adapters
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"healthcare-backend/pkg/models"
+)
+
+var ErrFHIRBundle = errors.New("invalid FHIR bundle")
+
+// smokingExtension is the Patient extension ToFHIRPatient writes the
+// smoking answer to
+const smokingExtension = "http://fhir.example.org/StructureDefinition/smoking-status"
+
+// fhirGenders maps FHIR administrative gender to PatientData.Gender
+var fhirGenders = map[string]string{"male": "Male", "female": "Female", "other": "Other"}
+
+// FromFHIRBundle reads a Bundle holding one Patient and its Observations
+// into a new patient record, dropping the warnings ParseFHIRBundle reports
+func (f *FHIRAdapter) FromFHIRBundle(bundle map[string]interface{}) (models.PatientData, error) {
+	patient, _, err := f.ParseFHIRBundle(bundle)
+	return patient, err
+}
+
+// ParseFHIRBundle reads a Bundle holding one Patient and its Observations
+// into a new patient record. Gender, birthDate (as age), name and the
+// smoking extension come from the Patient; vitals from Observations with a
+// recognized LOINC code in the expected UCUM unit, the most recent one
+// winning. Everything it can't use is listed in the warnings rather than
+// dropped silently. The record isn't validated; unanswered questions take
+// the clinic defaults, as in PatientIntake.NewPatient.
+func (f *FHIRAdapter) ParseFHIRBundle(bundle map[string]interface{}) (models.PatientData, []string, error) {
+	warnings := []string{}
+	if bundle["resourceType"] != "Bundle" {
+		return models.PatientData{}, warnings, fmt.Errorf("%w: resourceType must be Bundle", ErrFHIRBundle)
+	}
+	entries, _ := bundle["entry"].([]interface{})
+
+	var patient map[string]interface{}
+	var patientRefs []string // How Observations may refer to the Patient
+	var observations []map[string]interface{}
+	for i, e := range entries {
+		entry, _ := e.(map[string]interface{})
+		resource, _ := entry["resource"].(map[string]interface{})
+		if resource == nil {
+			warnings = append(warnings, fmt.Sprintf("entry %d: no resource", i))
+			continue
+		}
+		switch resource["resourceType"] {
+		case "Patient":
+			if patient != nil {
+				return models.PatientData{}, warnings, fmt.Errorf("%w: more than one Patient", ErrFHIRBundle)
+			}
+			patient = resource
+			if id, ok := resource["id"].(string); ok && id != "" {
+				patientRefs = append(patientRefs, "Patient/"+id)
+			}
+			if url, ok := entry["fullUrl"].(string); ok && url != "" {
+				patientRefs = append(patientRefs, url)
+			}
+		case "Observation":
+			observations = append(observations, resource)
+		default:
+			warnings = append(warnings, fmt.Sprintf("%s: unsupported resource type, ignored", resourceRef(resource)))
+		}
+	}
+	if patient == nil {
+		return models.PatientData{}, warnings, fmt.Errorf("%w: no Patient", ErrFHIRBundle)
+	}
+
+	var in models.PatientIntake
+	warnings = append(warnings, readFHIRPatient(patient, &in)...)
+
+	// The latest Observation of each vital wins
+	latest := map[string]time.Time{}
+	record := func(obs map[string]interface{}, v vitalSign, q map[string]interface{}) {
+		ref := resourceRef(obs)
+		value, ok := q["value"].(float64)
+		if !ok {
+			warnings = append(warnings, fmt.Sprintf("%s: %s has no numeric value", ref, v.code))
+			return
+		}
+		if ucum, _ := q["code"].(string); ucum != v.ucum && !(ucum == "" && q["unit"] == v.unit) {
+			warnings = append(warnings, fmt.Sprintf("%s: %s in unsupported unit %q, expected %s", ref, v.code, unitOf(q), v.ucum))
+			return
+		}
+		effective := effectiveTime(obs)
+		if seen, ok := latest[v.code]; ok && effective.Before(seen) {
+			return
+		}
+		latest[v.code] = effective
+		v.set(&in, value)
+	}
+	for _, obs := range observations {
+		ref := resourceRef(obs)
+		if status, _ := obs["status"].(string); status == "entered-in-error" || status == "cancelled" {
+			warnings = append(warnings, fmt.Sprintf("%s: status %s, ignored", ref, status))
+			continue
+		}
+		if subject := referenceOf(obs["subject"]); subject != "" && !slices.Contains(patientRefs, subject) {
+			warnings = append(warnings, fmt.Sprintf("%s: subject %s is not the bundle's Patient, ignored", ref, subject))
+			continue
+		}
+
+		code := loincOf(obs["code"])
+		if code == bloodPressurePanel {
+			components, _ := obs["component"].([]interface{})
+			for _, c := range components {
+				component, _ := c.(map[string]interface{})
+				v, ok := findVital(bloodPressure, loincOf(component["code"]))
+				if !ok {
+					warnings = append(warnings, fmt.Sprintf("%s: unrecognized component %s", ref, codingOf(component["code"])))
+					continue
+				}
+				q, _ := component["valueQuantity"].(map[string]interface{})
+				record(obs, v, q)
+			}
+			continue
+		}
+		v, ok := vitalByCode(code)
+		if !ok {
+			warnings = append(warnings, fmt.Sprintf("%s: unrecognized code %s", ref, codingOf(obs["code"])))
+			continue
+		}
+		q, _ := obs["valueQuantity"].(map[string]interface{})
+		record(obs, v, q)
+	}
+
+	return in.NewPatient(), warnings, nil
+}
+
+// readFHIRPatient fills in the intake's demographics from a Patient
+func readFHIRPatient(patient map[string]interface{}, in *models.PatientIntake) []string {
+	var warnings []string
+	ref := resourceRef(patient)
+
+	if g, ok := patient["gender"].(string); ok {
+		if gender, known := fhirGenders[g]; known {
+			in.Gender = &gender
+		} else {
+			warnings = append(warnings, fmt.Sprintf("%s: gender %q is not supported", ref, g))
+		}
+	}
+	if b, ok := patient["birthDate"].(string); ok {
+		if age, err := ageFromBirthDate(b, time.Now()); err == nil {
+			in.Age = flexibleInt(float64(age))
+		} else {
+			warnings = append(warnings, fmt.Sprintf("%s: unreadable birthDate %q", ref, b))
+		}
+	}
+	if names, ok := patient["name"].([]interface{}); ok && len(names) > 0 {
+		if name := humanName(names[0]); name != "" {
+			in.Name = &name
+		}
+	}
+	extensions, _ := patient["extension"].([]interface{})
+	for _, e := range extensions {
+		ext, _ := e.(map[string]interface{})
+		if ext["url"] != smokingExtension {
+			continue
+		}
+		switch smoking, _ := ext["valueCode"].(string); smoking {
+		case "Yes", "No", "Former":
+			in.Smoking = &smoking
+		default:
+			warnings = append(warnings, fmt.Sprintf("%s: smoking status %q is not supported", ref, smoking))
+		}
+	}
+	return warnings
+}
+
+// ageFromBirthDate is the age in whole years on now. A birthDate may be a
+// year, a year and month, or a full date, as FHIR allows.
+func ageFromBirthDate(birthDate string, now time.Time) (int, error) {
+	var born time.Time
+	var err error
+	for _, layout := range []string{time.DateOnly, "2006-01", "2006"} {
+		if born, err = time.Parse(layout, birthDate); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	return AgeOn(born, now), nil
+}
+
+// AgeOn is the age in whole years on now of someone born on born
+func AgeOn(born, now time.Time) int {
+	age := now.Year() - born.Year()
+	if now.Month() < born.Month() || (now.Month() == born.Month() && now.Day() < born.Day()) {
+		age--
+	}
+	return age
+}
+
+// humanName is a HumanName's text, or its given names and family name
+func humanName(v interface{}) string {
+	name, _ := v.(map[string]interface{})
+	if text, ok := name["text"].(string); ok && text != "" {
+		return text
+	}
+	var parts []string
+	given, _ := name["given"].([]interface{})
+	for _, g := range given {
+		if s, ok := g.(string); ok {
+			parts = append(parts, s)
+		}
+	}
+	if family, ok := name["family"].(string); ok {
+		parts = append(parts, family)
+	}
+	return strings.Join(parts, " ")
+}
+
+// VitalUnits are the UCUM code and display unit a LOINC-coded vital is
+// read in; ok is false for codes that don't map to a patient field
+func VitalUnits(code string) (ucum, unit string, ok bool) {
+	v, ok := vitalByCode(code)
+	return v.ucum, v.unit, ok
+}
+
+// SetVital fills in the intake field a LOINC-coded vital maps to, reporting
+// whether it maps to one. The value must be in the unit VitalUnits gives.
+func SetVital(in *models.PatientIntake, code string, value float64) bool {
+	v, ok := vitalByCode(code)
+	if ok {
+		v.set(in, value)
+	}
+	return ok
+}
+
+// vitalByCode finds a vital, blood pressure components included, by LOINC code
+func vitalByCode(code string) (vitalSign, bool) {
+	if v, ok := findVital(bloodPressure, code); ok {
+		return v, true
+	}
+	return findVital(vitalSigns, code)
+}
+
+func findVital(table []vitalSign, code string) (vitalSign, bool) {
+	for _, v := range table {
+		if v.code == code {
+			return v, true
+		}
+	}
+	return vitalSign{}, false
+}
+
+// loincOf is the LOINC code of a CodeableConcept, or ""
+func loincOf(v interface{}) string {
+	concept, _ := v.(map[string]interface{})
+	codings, _ := concept["coding"].([]interface{})
+	for _, c := range codings {
+		coding, _ := c.(map[string]interface{})
+		if coding["system"] == LOINCSystem {
+			code, _ := coding["code"].(string)
+			return code
+		}
+	}
+	return ""
+}
+
+// codingOf describes a CodeableConcept for a warning, e.g. http://loinc.org|1234-5
+func codingOf(v interface{}) string {
+	concept, _ := v.(map[string]interface{})
+	codings, _ := concept["coding"].([]interface{})
+	if len(codings) > 0 {
+		coding, _ := codings[0].(map[string]interface{})
+		return fmt.Sprintf("%v|%v", coding["system"], coding["code"])
+	}
+	if text, ok := concept["text"].(string); ok {
+		return fmt.Sprintf("%q", text)
+	}
+	return "(none)"
+}
+
+func unitOf(q map[string]interface{}) string {
+	if code, ok := q["code"].(string); ok {
+		return code
+	}
+	unit, _ := q["unit"].(string)
+	return unit
+}
+
+func referenceOf(v interface{}) string {
+	ref, _ := v.(map[string]interface{})
+	s, _ := ref["reference"].(string)
+	return s
+}
+
+// resourceRef names a resource in warnings, e.g. Observation/obs-1
+func resourceRef(r map[string]interface{}) string {
+	if id, ok := r["id"].(string); ok && id != "" {
+		return fmt.Sprintf("%v/%s", r["resourceType"], id)
+	}
+	return fmt.Sprintf("%v", r["resourceType"])
+}
+
+// effectiveTime is when an Observation was made; zero when it doesn't say
+func effectiveTime(obs map[string]interface{}) time.Time {
+	s, _ := obs["effectiveDateTime"].(string)
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func flexibleInt(v float64) *models.FlexibleInt {
+	n := models.FlexibleInt(math.Round(v))
+	return &n
+}
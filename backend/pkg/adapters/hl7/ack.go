@@ -0,0 +1,92 @@
+package hl7
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Acknowledgment codes (MSA-1)
+const (
+	AckAccept = "AA"
+	AckError  = "AE" // The message was read but couldn't be processed
+	AckReject = "AR" // We don't handle messages of this kind at all
+)
+
+// AckCode is the acknowledgment refusing a message over err: a reject for
+// message types we don't handle, an application error otherwise
+func AckCode(err error) string {
+	var issue *Issue
+	if errors.As(err, &issue) && (issue.Code == CodeUnsupportedMessageType || issue.Code == CodeUnsupportedEventCode) {
+		return AckReject
+	}
+	return AckError
+}
+
+// ACK builds the acknowledgment of msg, which is nil when it couldn't be
+// parsed. text goes in MSA-3; errs and warnings each become an ERR segment
+// with severity E or W. The ACK uses the message's delimiters, sends from
+// its receiver to its sender and echoes its control ID.
+func ACK(msg *Message, ack, text string, errs, warnings []Issue) string {
+	enc := defaultEncoding
+	var header Segment
+	if msg != nil {
+		enc = msg.enc
+		header = msg.Header()
+	}
+	sep := string(enc.field)
+	orDefault := func(field int, def string) string {
+		if v := header.Field(field); v != "" {
+			return v
+		}
+		return def
+	}
+	now := time.Now()
+
+	// Swapping sender and receiver
+	msh := []string{"MSH",
+		string([]byte{enc.component, enc.repetition, enc.escape, enc.subcomponent}),
+		orDefault(5, "HEALTHCARE_BACKEND"), header.Field(6),
+		header.Field(3), header.Field(4),
+		now.UTC().Format("20060102150405"), "",
+		"ACK" + string(enc.component) + enc.escapeText(header.Component(9, 2)) + string(enc.component) + "ACK",
+		"ACK" + strconv.FormatInt(now.UnixNano(), 36),
+		orDefault(11, "P"), orDefault(12, "2.5"),
+	}
+	controlID := ""
+	if msg != nil {
+		controlID = enc.escapeText(msg.ControlID())
+	}
+	segments := []string{
+		strings.Join(msh, sep),
+		strings.Join([]string{"MSA", ack, controlID, enc.escapeText(text)}, sep),
+	}
+	for _, e := range errs {
+		segments = append(segments, errSegment(enc, e, "E"))
+	}
+	for _, w := range warnings {
+		segments = append(segments, errSegment(enc, w, "W"))
+	}
+	return strings.Join(segments, "\r") + "\r"
+}
+
+// errSegment is an HL7 v2.5 ERR: location (ERR-2), error code (ERR-3),
+// severity (ERR-4) and the issue's text as the user message (ERR-8)
+func errSegment(enc encoding, issue Issue, severity string) string {
+	comp := string(enc.component)
+	location := ""
+	if issue.Segment != "" {
+		seq := max(issue.Sequence, 1)
+		location = enc.escapeText(issue.Segment) + comp + strconv.Itoa(seq)
+		if issue.Field > 0 {
+			location += comp + strconv.Itoa(issue.Field)
+		}
+	}
+	code := issue.Code
+	if code == 0 {
+		code = CodeApplicationError
+	}
+	errorCode := strconv.Itoa(code) + comp + codeNames[code] + comp + "HL70357"
+	return strings.Join([]string{"ERR", "", location, errorCode, severity, "", "", "", enc.escapeText(issue.Text)}, string(enc.field))
+}
@@ -0,0 +1,260 @@
+// Package hl7 reads HL7 v2 messages from a hospital interface engine and
+// builds the ACKs that answer them
+package hl7
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ContentType is the media type of HL7 v2 messages in ER7 (pipe) encoding
+const ContentType = "x-application/hl7-v2+er7"
+
+// MLLP framing some interface engines keep when relaying over HTTP
+const (
+	mllpStart = "\x0b"
+	mllpEnd   = "\x1c"
+)
+
+// HL7 error codes (table 0357) used in ERR segments
+const (
+	CodeSegmentSequence        = 100
+	CodeRequiredFieldMissing   = 101
+	CodeDataType               = 102
+	CodeTableValueNotFound     = 103
+	CodeUnsupportedMessageType = 200
+	CodeUnsupportedEventCode   = 201
+	CodeApplicationError       = 207
+)
+
+var codeNames = map[int]string{
+	CodeSegmentSequence:        "Segment sequence error",
+	CodeRequiredFieldMissing:   "Required field missing",
+	CodeDataType:               "Data type error",
+	CodeTableValueNotFound:     "Table value not found",
+	CodeUnsupportedMessageType: "Unsupported message type",
+	CodeUnsupportedEventCode:   "Unsupported event code",
+	CodeApplicationError:       "Application internal error",
+}
+
+// Issue is a problem with a message, located by segment ID, the segment's
+// occurrence among those with that ID (OBX 2 is the second OBX) and field
+// position. Errors refuse the message; warnings ride along in the ACK.
+type Issue struct {
+	Segment  string
+	Sequence int
+	Field    int // 0 when the whole segment is at fault
+	Code     int // Table 0357
+	Text     string
+}
+
+func (i *Issue) Error() string {
+	return i.Location() + ": " + i.Text
+}
+
+// Location is where the issue is, e.g. OBX[2]-5, or "message"
+func (i *Issue) Location() string {
+	if i.Segment == "" {
+		return "message"
+	}
+	loc := i.Segment
+	if i.Sequence > 1 {
+		loc += fmt.Sprintf("[%d]", i.Sequence)
+	}
+	if i.Field > 0 {
+		loc += fmt.Sprintf("-%d", i.Field)
+	}
+	return loc
+}
+
+// encoding holds a message's delimiters, read from MSH-1 and MSH-2
+type encoding struct {
+	field, component, repetition, escape, subcomponent byte
+}
+
+var defaultEncoding = encoding{'|', '^', '~', '\\', '&'}
+
+var segmentID = regexp.MustCompile(`^[A-Z][A-Z0-9]{2}$`)
+
+// Segment is one line of a message
+type Segment struct {
+	ID       string
+	Sequence int // Occurrence among segments with this ID, from 1
+	fields   []string
+	enc      encoding
+}
+
+// Field is the raw text of a field, numbered as in the standard: MSH-1 is
+// the field separator itself, so MSH fields are shifted by one
+func (s Segment) Field(n int) string {
+	if s.ID == "MSH" {
+		if n == 1 {
+			return string(s.enc.field)
+		}
+		n--
+	}
+	if n < 1 || n >= len(s.fields) {
+		return ""
+	}
+	return s.fields[n]
+}
+
+// Component is a component of a field's first repetition, numbered from 1,
+// with escape sequences decoded
+func (s Segment) Component(field, component int) string {
+	f := s.Field(field)
+	if i := strings.IndexByte(f, s.enc.repetition); i >= 0 {
+		f = f[:i]
+	}
+	parts := strings.Split(f, string(s.enc.component))
+	if component < 1 || component > len(parts) {
+		return ""
+	}
+	return s.enc.unescape(parts[component-1])
+}
+
+// Message is a parsed HL7 v2 message
+type Message struct {
+	Segments []Segment
+	enc      encoding
+}
+
+// Parse reads a message in ER7 encoding. Segments may end in CR, LF or
+// CRLF, and MLLP framing is dropped. A message must start with an MSH that
+// declares its delimiters, and every segment needs a valid ID.
+func Parse(raw string) (*Message, error) {
+	raw = strings.TrimPrefix(raw, mllpStart)
+	if i := strings.Index(raw, mllpEnd); i >= 0 {
+		raw = raw[:i]
+	}
+	lines := strings.FieldsFunc(raw, func(r rune) bool { return r == '\r' || r == '\n' })
+	if len(lines) == 0 || !strings.HasPrefix(lines[0], "MSH") {
+		return nil, &Issue{Segment: "MSH", Code: CodeSegmentSequence, Text: "message must start with an MSH segment"}
+	}
+
+	header := lines[0]
+	if len(header) < 8 {
+		return nil, &Issue{Segment: "MSH", Field: 2, Code: CodeRequiredFieldMissing, Text: "encoding characters missing"}
+	}
+	enc := encoding{field: header[3]}
+	chars := header[4:]
+	if i := strings.IndexByte(chars, enc.field); i >= 0 {
+		chars = chars[:i]
+	}
+	if len(chars) < 4 || distinct(enc.field, chars[0], chars[1], chars[2], chars[3]) != 5 {
+		return nil, &Issue{Segment: "MSH", Field: 2, Code: CodeDataType, Text: fmt.Sprintf("encoding characters %q must be four distinct delimiters", chars)}
+	}
+	enc.component, enc.repetition, enc.escape, enc.subcomponent = chars[0], chars[1], chars[2], chars[3]
+
+	msg := &Message{enc: enc}
+	seen := map[string]int{}
+	for n, line := range lines {
+		fields := strings.Split(line, string(enc.field))
+		id := fields[0]
+		if !segmentID.MatchString(id) {
+			return nil, &Issue{Segment: id, Code: CodeSegmentSequence,
+				Text: fmt.Sprintf("segment %d: %q is not a segment ID", n+1, id)}
+		}
+		if id == "MSH" && n > 0 {
+			return nil, &Issue{Segment: "MSH", Sequence: seen["MSH"] + 1, Code: CodeSegmentSequence, Text: "one message per request"}
+		}
+		seen[id]++
+		msg.Segments = append(msg.Segments, Segment{ID: id, Sequence: seen[id], fields: fields, enc: enc})
+	}
+	return msg, nil
+}
+
+// Header is the MSH segment
+func (m *Message) Header() Segment {
+	return m.Segments[0]
+}
+
+// All are the segments with the given ID, in order
+func (m *Message) All(id string) []Segment {
+	var segments []Segment
+	for _, s := range m.Segments {
+		if s.ID == id {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
+
+// Type is the message code and trigger event from MSH-9, e.g. ADT and A01
+func (m *Message) Type() (code, event string) {
+	h := m.Header()
+	return h.Component(9, 1), h.Component(9, 2)
+}
+
+// ControlID is MSH-10, which the ACK echoes
+func (m *Message) ControlID() string {
+	return m.Header().Component(10, 1)
+}
+
+func distinct(chars ...byte) int {
+	set := map[byte]bool{}
+	for _, c := range chars {
+		set[c] = true
+	}
+	return len(set)
+}
+
+// unescape decodes the delimiter escapes \F\ \S\ \T\ \R\ \E\; others, such
+// as formatting commands, are dropped
+func (e encoding) unescape(s string) string {
+	if strings.IndexByte(s, e.escape) < 0 {
+		return s
+	}
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(s, e.escape)
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(s[start+1:], e.escape)
+		if end < 0 {
+			break
+		}
+		b.WriteString(s[:start])
+		switch s[start+1 : start+1+end] {
+		case "F":
+			b.WriteByte(e.field)
+		case "S":
+			b.WriteByte(e.component)
+		case "T":
+			b.WriteByte(e.subcomponent)
+		case "R":
+			b.WriteByte(e.repetition)
+		case "E":
+			b.WriteByte(e.escape)
+		}
+		s = s[start+end+2:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+// escapeText escapes delimiters in text written into a field
+func (e encoding) escapeText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case e.escape:
+			b.WriteString(string(e.escape) + "E" + string(e.escape))
+		case e.field:
+			b.WriteString(string(e.escape) + "F" + string(e.escape))
+		case e.component:
+			b.WriteString(string(e.escape) + "S" + string(e.escape))
+		case e.subcomponent:
+			b.WriteString(string(e.escape) + "T" + string(e.escape))
+		case e.repetition:
+			b.WriteString(string(e.escape) + "R" + string(e.escape))
+		case '\r', '\n':
+			b.WriteByte(' ')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
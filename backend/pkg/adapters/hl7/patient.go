@@ -0,0 +1,174 @@
+package hl7
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"healthcare-backend/pkg/adapters"
+	"healthcare-backend/pkg/models"
+)
+
+// supportedTypes are the message codes and trigger events we ingest: an
+// admission and an unsolicited result, each with one PID and its OBXs
+var supportedTypes = map[string][]string{
+	"ADT": {"A01"},
+	"ORU": {"R01"},
+}
+
+// administrativeSex maps PID-8 (table 0001) to PatientData.Gender
+var administrativeSex = map[string]string{"M": "Male", "F": "Female", "O": "Other", "A": "Other", "N": "Other"}
+
+// Result statuses (OBX-11) of observations that must not be used
+var discardedStatuses = []string{"X", "D", "W"}
+
+// ReadPatient reads an ADT^A01 or ORU^R01 into a new patient record.
+// Demographics come from PID: name (PID-5), date of birth as age (PID-7)
+// and sex (PID-8). Vitals come from OBX segments with a recognized LOINC
+// code (OBX-3) in the expected unit (OBX-6); the latest by OBX-14 wins.
+// Malformed segments are errors; observations it can't use are warnings.
+// The record isn't validated; unanswered questions take the clinic
+// defaults, as in PatientIntake.NewPatient.
+func ReadPatient(msg *Message) (models.PatientData, []Issue, error) {
+	warnings := []Issue{}
+	code, event := msg.Type()
+	events, ok := supportedTypes[code]
+	if !ok {
+		return models.PatientData{}, warnings, &Issue{Segment: "MSH", Field: 9, Code: CodeUnsupportedMessageType,
+			Text: fmt.Sprintf("message type %q is not supported; send ADT^A01 or ORU^R01", code)}
+	}
+	if !slices.Contains(events, event) {
+		return models.PatientData{}, warnings, &Issue{Segment: "MSH", Field: 9, Code: CodeUnsupportedEventCode,
+			Text: fmt.Sprintf("event %s^%s is not supported; send ADT^A01 or ORU^R01", code, event)}
+	}
+
+	pids := msg.All("PID")
+	if len(pids) == 0 {
+		return models.PatientData{}, warnings, &Issue{Segment: "PID", Code: CodeSegmentSequence, Text: "no PID segment"}
+	}
+	if len(pids) > 1 {
+		return models.PatientData{}, warnings, &Issue{Segment: "PID", Sequence: 2, Code: CodeSegmentSequence, Text: "one patient per message"}
+	}
+	var in models.PatientIntake
+	if err := readPID(pids[0], &in); err != nil {
+		return models.PatientData{}, warnings, err
+	}
+
+	latest := map[string]time.Time{}
+	for _, obx := range msg.All("OBX") {
+		at := func(field, code int, format string, args ...any) Issue {
+			return Issue{Segment: "OBX", Sequence: obx.Sequence, Field: field, Code: code, Text: fmt.Sprintf(format, args...)}
+		}
+		if status := obx.Component(11, 1); slices.Contains(discardedStatuses, status) {
+			warnings = append(warnings, at(11, CodeApplicationError, "result status %s, ignored", status))
+			continue
+		}
+		loinc := loincOf(obx)
+		ucum, unit, known := adapters.VitalUnits(loinc)
+		if !known {
+			warnings = append(warnings, at(3, CodeTableValueNotFound, "unrecognized observation %s", obx.Field(3)))
+			continue
+		}
+		raw := strings.TrimSpace(obx.Component(5, 1))
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			issue := at(5, CodeDataType, "value %q of %s is not a number", raw, loinc)
+			return models.PatientData{}, warnings, &issue
+		}
+		if id, text := obx.Component(6, 1), obx.Component(6, 2); id != ucum && id != unit && text != unit {
+			warnings = append(warnings, at(6, CodeTableValueNotFound, "%s in unsupported unit %q, expected %s", loinc, id, ucum))
+			continue
+		}
+		var observed time.Time
+		if ts := obx.Component(14, 1); ts != "" {
+			if observed, err = ParseTime(ts); err != nil {
+				issue := at(14, CodeDataType, "unreadable observation time %q", ts)
+				return models.PatientData{}, warnings, &issue
+			}
+		}
+		if seen, ok := latest[loinc]; ok && observed.Before(seen) {
+			continue
+		}
+		latest[loinc] = observed
+		adapters.SetVital(&in, loinc, value)
+	}
+
+	return in.NewPatient(), warnings, nil
+}
+
+// readPID fills in the intake's demographics; date of birth and sex are
+// required
+func readPID(pid Segment, in *models.PatientIntake) error {
+	at := func(field, code int, format string, args ...any) error {
+		return &Issue{Segment: "PID", Sequence: pid.Sequence, Field: field, Code: code, Text: fmt.Sprintf(format, args...)}
+	}
+
+	var parts []string
+	for _, c := range []int{2, 3, 1} { // Given, middle, family
+		if s := strings.TrimSpace(pid.Component(5, c)); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	if len(parts) > 0 {
+		name := strings.Join(parts, " ")
+		in.Name = &name
+	}
+
+	dob := pid.Component(7, 1)
+	if dob == "" {
+		return at(7, CodeRequiredFieldMissing, "date of birth is required")
+	}
+	born, err := ParseTime(dob)
+	now := time.Now()
+	if err != nil || born.After(now) {
+		return at(7, CodeDataType, "unreadable date of birth %q", dob)
+	}
+	age := models.FlexibleInt(adapters.AgeOn(born, now))
+	in.Age = &age
+
+	sex := pid.Component(8, 1)
+	if sex == "" {
+		return at(8, CodeRequiredFieldMissing, "administrative sex is required")
+	}
+	gender, ok := administrativeSex[sex]
+	if !ok {
+		return at(8, CodeTableValueNotFound, "administrative sex %q is not supported; use M, F or O", sex)
+	}
+	in.Gender = &gender
+	return nil
+}
+
+// loincOf is the LOINC code of OBX-3, from the identifier or the alternate
+// identifier. A code without a coding system is taken as LOINC.
+func loincOf(obx Segment) string {
+	switch {
+	case obx.Component(3, 3) == "LN" || obx.Component(3, 3) == "":
+		return obx.Component(3, 1)
+	case obx.Component(3, 6) == "LN":
+		return obx.Component(3, 4)
+	}
+	return ""
+}
+
+// ParseTime reads an HL7 date/time, YYYY[MM[DD[HH[MM[SS[.S]]]]]][+/-ZZZZ].
+// Without a zone it is taken as UTC.
+func ParseTime(s string) (time.Time, error) {
+	zone := ""
+	if i := strings.IndexAny(s, "+-"); i >= 0 {
+		s, zone = s[:i], s[i:]
+	}
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		s = s[:i]
+	}
+	layouts := map[int]string{4: "2006", 6: "200601", 8: "20060102", 10: "2006010215", 12: "200601021504", 14: "20060102150405"}
+	layout, ok := layouts[len(s)]
+	if !ok {
+		return time.Time{}, fmt.Errorf("%q is not an HL7 date/time", s+zone)
+	}
+	if zone != "" {
+		layout += "-0700"
+	}
+	return time.Parse(layout, s+zone)
+}
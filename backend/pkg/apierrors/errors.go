@@ -0,0 +1,80 @@
+package apierrors
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StorageBusy is returned by the repository layer when the database stayed
+// locked for longer than the retry deadline. Handlers map it to 503 + Retry-After.
+type StorageBusy struct {
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *StorageBusy) Error() string {
+	return fmt.Sprintf("storage busy, retry after %s: %v", e.RetryAfter, e.Err)
+}
+
+func (e *StorageBusy) Unwrap() error {
+	return e.Err
+}
+
+// Conflict is a write that violates a uniqueness constraint. Handlers map it to 409.
+type Conflict struct {
+	Err error
+}
+
+func (e *Conflict) Error() string {
+	return fmt.Sprintf("conflicts with an existing record: %v", e.Err)
+}
+
+func (e *Conflict) Unwrap() error {
+	return e.Err
+}
+
+// StorageUnavailable means the database can't take writes at all (read-only,
+// disk full, closed, I/O failure). Handlers map it to 503.
+type StorageUnavailable struct {
+	Err error
+}
+
+func (e *StorageUnavailable) Error() string {
+	return fmt.Sprintf("storage unavailable: %v", e.Err)
+}
+
+func (e *StorageUnavailable) Unwrap() error {
+	return e.Err
+}
+
+// FromDB wraps a raw database error in the matching typed error. Errors that
+// are already typed, and anything it doesn't recognise, pass through unchanged.
+func FromDB(err error) error {
+	if err == nil {
+		return nil
+	}
+	var conflict *Conflict
+	var unavailable *StorageUnavailable
+	var busy *StorageBusy
+	if errors.As(err, &conflict) || errors.As(err, &unavailable) || errors.As(err, &busy) {
+		return err
+	}
+
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "unique constraint failed"),
+		strings.Contains(msg, "duplicated key"),
+		strings.Contains(msg, "duplicate key"):
+		return &Conflict{Err: err}
+	case strings.Contains(msg, "readonly database"),
+		strings.Contains(msg, "read-only"),
+		strings.Contains(msg, "database or disk is full"),
+		strings.Contains(msg, "disk i/o error"),
+		strings.Contains(msg, "database is closed"),
+		strings.Contains(msg, "unable to open database"):
+		return &StorageUnavailable{Err: err}
+	}
+	return err
+}
@@ -0,0 +1,50 @@
+package auditctx
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequestIDHeader carries a request's correlation ID to and from callers
+// and on to the ML service
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDLocalsKey is where the request ID middleware stores the ID
+const RequestIDLocalsKey = "request_id"
+
+type requestIDKey struct{}
+
+// SetRequestID stores the request's ID in c.Locals and in its user context,
+// so services called with c.UserContext() can forward it
+func SetRequestID(c *fiber.Ctx, id string) {
+	c.Locals(RequestIDLocalsKey, id)
+	c.SetUserContext(WithRequestID(c.UserContext(), id))
+}
+
+// RequestID returns the request's ID, empty when none was assigned
+func RequestID(c *fiber.Ctx) string {
+	id, _ := c.Locals(RequestIDLocalsKey).(string)
+	return id
+}
+
+// WithRequestID returns ctx carrying the request ID, or ctx itself for an empty ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFrom returns the request ID ctx carries, if any
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// InRequest tags the identity with the request ID ctx carries, for entries
+// logged on a request's behalf by another actor, e.g. System
+func (id Identity) InRequest(ctx context.Context) Identity {
+	id.RequestID = RequestIDFrom(ctx)
+	return id
+}
@@ -33,7 +33,7 @@ func (b *Block) CalculateHash() string {
 func CreateBlock(prevBlock Block, data interface{}) Block {
 	newBlock := Block{
 		Index:        prevBlock.Index + 1,
-		Timestamp:    time.Now(),
+		Timestamp:    time.Now().UTC(), // No monotonic reading, so the hash survives a JSON round trip
 		Data:         data,
 		PreviousHash: prevBlock.Hash,
 		Nonce:        0,
@@ -46,7 +46,7 @@ func CreateBlock(prevBlock Block, data interface{}) Block {
 func GenesisBlock() Block {
 	b := Block{
 		Index:        0,
-		Timestamp:    time.Now(),
+		Timestamp:    time.Now().UTC(),
 		Data:         "Genisis Block - Clinical Copilot High-Performance Ledger",
 		PreviousHash: "0",
 		Nonce:        0,
@@ -1,13 +1,19 @@
 package blockchain
 
 import (
-	"log"
+	"log/slog"
 	"sync"
 )
 
 type Blockchain struct {
 	Chain []Block
 	mu    sync.RWMutex
+
+	// Snapshot persistence, unset for in-memory chains
+	path      string
+	every     int    // Save after this many new blocks
+	unsaved   int    // Blocks added since the last save
+	taintedBy string // Why the loaded snapshot failed validation
 }
 
 var GlobalChain *Blockchain
@@ -17,7 +23,7 @@ func InitBlockchain() {
 	GlobalChain = &Blockchain{
 		Chain: []Block{GenesisBlock()},
 	}
-	log.Println("🔗 Blockchain Initialized: Genesis Block created.")
+	slog.Info("blockchain initialized with a genesis block")
 }
 
 // AddBlock adds a new block to the chain securely
@@ -32,6 +38,13 @@ func (bc *Blockchain) AddBlock(data interface{}) Block {
 	// but we could enforce basic difficulty if required.
 	
 	bc.Chain = append(bc.Chain, newBlock)
+
+	bc.unsaved++
+	if bc.path != "" && bc.every > 0 && bc.unsaved >= bc.every {
+		if err := bc.saveLocked(); err != nil {
+			slog.Warn("ledger snapshot failed", "error", err)
+		}
+	}
 	return newBlock
 }
 
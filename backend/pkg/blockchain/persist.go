@@ -0,0 +1,93 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// InitBlockchainFrom initializes the singleton chain from the snapshot at
+// path, or an in-memory chain when path is empty
+func InitBlockchainFrom(path string, every int) error {
+	if path == "" {
+		InitBlockchain()
+		return nil
+	}
+	bc, err := Open(path, every)
+	if err != nil {
+		return err
+	}
+	GlobalChain = bc
+	slog.Info("blockchain loaded", "path", path, "blocks", len(bc.Chain))
+	return nil
+}
+
+// Open loads the ledger snapshot at path, starting a new chain there if none
+// exists yet, and saves it again after every `every` new blocks. A snapshot
+// that fails validation is still loaded, so the evidence isn't lost, but the
+// chain is marked tainted.
+func Open(path string, every int) (*Blockchain, error) {
+	bc := &Blockchain{path: path, every: every}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		bc.Chain = []Block{GenesisBlock()}
+		return bc, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read ledger snapshot: %w", err)
+	}
+	if err := json.Unmarshal(data, &bc.Chain); err != nil {
+		return nil, fmt.Errorf("parse ledger snapshot %s: %w", path, err)
+	}
+	if len(bc.Chain) == 0 {
+		return nil, fmt.Errorf("ledger snapshot %s has no blocks", path)
+	}
+
+	if !bc.IsChainValid() {
+		bc.taintedBy = "snapshot " + path + " failed hash verification on load"
+	}
+	return bc, nil
+}
+
+// Save writes the whole chain to its snapshot file. In-memory chains are a no-op.
+func (bc *Blockchain) Save() error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	return bc.saveLocked()
+}
+
+// saveLocked writes through a temp file so a crash mid-write never leaves a
+// truncated snapshot behind
+func (bc *Blockchain) saveLocked() error {
+	if bc.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(bc.Chain)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(bc.path), 0750); err != nil {
+		return err
+	}
+	tmp := bc.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0640); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, bc.path); err != nil {
+		return err
+	}
+	bc.unsaved = 0
+	return nil
+}
+
+// TaintedReason explains why the loaded snapshot is untrusted, or is empty
+// for a clean chain
+func (bc *Blockchain) TaintedReason() string {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return bc.taintedBy
+}
@@ -2,12 +2,18 @@ package cache
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"time"
 
+	"healthcare-backend/pkg/chaos"
+
 	"github.com/redis/go-redis/v9"
 )
 
+// DiagnosisChannel carries models.DiagnosisUpdate messages from LLM workers
+// to the WebSocket listeners
+const DiagnosisChannel = "diagnosis_updates"
+
 var (
 	RedisClient *redis.Client
 	ctx         = context.Background()
@@ -26,9 +32,9 @@ func InitRedis(url string) {
 	// Test connection
 	_, err := RedisClient.Ping(ctx).Result()
 	if err != nil {
-		log.Printf("⚠️ Redis connection failed: %v", err)
+		slog.Warn("redis connection failed", "error", err)
 	} else {
-		log.Println("🔴 Redis connected successfully")
+		slog.Info("redis connected")
 	}
 }
 
@@ -37,6 +43,9 @@ func Get(key string) (string, error) {
 	if RedisClient == nil {
 		return "", context.DeadlineExceeded
 	}
+	if err := chaos.Inject(chaos.TargetRedis, ""); err != nil {
+		return "", err
+	}
 	return RedisClient.Get(ctx, key).Result()
 }
 
@@ -45,6 +54,9 @@ func Set(key string, value interface{}, ttl time.Duration) error {
 	if RedisClient == nil {
 		return context.DeadlineExceeded
 	}
+	if err := chaos.Inject(chaos.TargetRedis, ""); err != nil {
+		return err
+	}
 	return RedisClient.Set(ctx, key, value, ttl).Err()
 }
 
@@ -53,13 +65,54 @@ func Delete(key string) error {
 	if RedisClient == nil {
 		return context.DeadlineExceeded
 	}
+	if err := chaos.Inject(chaos.TargetRedis, ""); err != nil {
+		return err
+	}
 	return RedisClient.Del(ctx, key).Err()
 }
 
+// Incr atomically increments a counter and returns the new value
+func Incr(key string) (int64, error) {
+	if RedisClient == nil {
+		return 0, context.DeadlineExceeded
+	}
+	if err := chaos.Inject(chaos.TargetRedis, ""); err != nil {
+		return 0, err
+	}
+	return RedisClient.Incr(ctx, key).Result()
+}
+
+// Publish sends a message on a pub/sub channel
+func Publish(channel string, payload interface{}) error {
+	if RedisClient == nil {
+		return context.DeadlineExceeded
+	}
+	if err := chaos.Inject(chaos.TargetRedis, ""); err != nil {
+		return err
+	}
+	return RedisClient.Publish(ctx, channel, payload).Err()
+}
+
 // Ping checks if Redis is alive
 func Ping() error {
+	return PingCtx(ctx)
+}
+
+// PingCtx is Ping giving up when c is done
+func PingCtx(c context.Context) error {
 	if RedisClient == nil {
 		return context.DeadlineExceeded
 	}
-	return RedisClient.Ping(ctx).Err()
+	if err := chaos.Inject(chaos.TargetRedis, ""); err != nil {
+		return err
+	}
+	return RedisClient.Ping(c).Err()
+}
+
+// Close closes the Redis client, ending its Pub/Sub subscriptions
+func Close() error {
+	if RedisClient == nil {
+		return nil
+	}
+	return RedisClient.Close()
 }
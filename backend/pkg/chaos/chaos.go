@@ -0,0 +1,159 @@
+package chaos
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Target is a client wrapper that can have faults injected
+type Target string
+
+const (
+	TargetML    Target = "ml"
+	TargetRedis Target = "redis"
+	TargetNATS  Target = "nats"
+)
+
+// ErrInjected is returned by Inject when a fault fires
+var ErrInjected = errors.New("chaos: injected fault")
+
+// Fault describes latency and/or errors for one target (optionally one endpoint)
+type Fault struct {
+	Target    Target        `json:"target"`
+	Endpoint  string        `json:"endpoint,omitempty"` // ML path like "/predict"; empty = every call
+	Latency   time.Duration `json:"latency"`
+	ErrorRate float64       `json:"error_rate"` // 0..1
+	ExpiresAt time.Time     `json:"expires_at"`
+}
+
+func (f Fault) key() string {
+	return string(f.Target) + " " + f.Endpoint
+}
+
+// Injector holds the active faults. It does nothing until enabled, and is only
+// ever enabled outside production, so the hooks in our client wrappers are free
+// in normal operation.
+type Injector struct {
+	enabled atomic.Bool
+	mu      sync.RWMutex
+	faults  map[string]Fault
+	rng     *rand.Rand
+	now     func() time.Time
+}
+
+func NewInjector() *Injector {
+	return &Injector{
+		faults: map[string]Fault{},
+		rng:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		now:    time.Now,
+	}
+}
+
+// Default is the process-wide injector used by the ML, Redis and NATS wrappers
+var Default = NewInjector()
+
+func (i *Injector) Enable()       { i.enabled.Store(true) }
+func (i *Injector) Enabled() bool { return i.enabled.Load() }
+
+// Disable turns injection off and drops all faults
+func (i *Injector) Disable() {
+	i.enabled.Store(false)
+	i.Clear()
+}
+
+// Set installs a fault for ttl, replacing any fault on the same target/endpoint
+func (i *Injector) Set(f Fault, ttl time.Duration) (Fault, error) {
+	if !i.Enabled() {
+		return Fault{}, errors.New("chaos injection is disabled")
+	}
+	switch f.Target {
+	case TargetML, TargetRedis, TargetNATS:
+	default:
+		return Fault{}, fmt.Errorf("unknown chaos target %q (expected ml, redis or nats)", f.Target)
+	}
+	if f.Target != TargetML && f.Endpoint != "" {
+		return Fault{}, fmt.Errorf("endpoint is only supported for the ml target")
+	}
+	if f.ErrorRate < 0 || f.ErrorRate > 1 {
+		return Fault{}, fmt.Errorf("error_rate must be between 0 and 1")
+	}
+	if f.Latency < 0 || f.Latency > time.Minute {
+		return Fault{}, fmt.Errorf("latency must be between 0 and 60s")
+	}
+	if ttl <= 0 || ttl > time.Hour {
+		return Fault{}, fmt.Errorf("ttl must be between 1s and 1h")
+	}
+
+	f.ExpiresAt = i.now().Add(ttl)
+	i.mu.Lock()
+	i.faults[f.key()] = f
+	i.mu.Unlock()
+	return f, nil
+}
+
+// Clear removes every fault
+func (i *Injector) Clear() {
+	i.mu.Lock()
+	i.faults = map[string]Fault{}
+	i.mu.Unlock()
+}
+
+// Active lists unexpired faults
+func (i *Injector) Active() []Fault {
+	now := i.now()
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	active := []Fault{}
+	for k, f := range i.faults {
+		if now.After(f.ExpiresAt) {
+			delete(i.faults, k)
+			continue
+		}
+		active = append(active, f)
+	}
+	sort.Slice(active, func(a, b int) bool { return active[a].key() < active[b].key() })
+	return active
+}
+
+// Inject applies the fault matching target/endpoint, if any: it sleeps for the
+// configured latency and then fails with probability ErrorRate.
+func (i *Injector) Inject(target Target, endpoint string) error {
+	if !i.Enabled() {
+		return nil
+	}
+
+	now := i.now()
+	i.mu.RLock()
+	f, ok := i.faults[string(target)+" "+endpoint]
+	if !ok || now.After(f.ExpiresAt) {
+		f, ok = i.faults[string(target)+" "]
+	}
+	i.mu.RUnlock()
+	if !ok || now.After(f.ExpiresAt) {
+		return nil
+	}
+
+	if f.Latency > 0 {
+		time.Sleep(f.Latency)
+	}
+	if f.ErrorRate > 0 {
+		i.mu.Lock()
+		roll := i.rng.Float64()
+		i.mu.Unlock()
+		if roll < f.ErrorRate {
+			return fmt.Errorf("%w (%s%s)", ErrInjected, target, endpoint)
+		}
+	}
+	return nil
+}
+
+// Inject applies Default's faults
+func Inject(target Target, endpoint string) error {
+	return Default.Inject(target, endpoint)
+}
@@ -0,0 +1,283 @@
+// Package cli implements healthctl, the operator commands that work directly
+// against the configured database without starting the HTTP server.
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/config"
+	"healthcare-backend/pkg/database"
+	"healthcare-backend/pkg/privacy"
+	"healthcare-backend/pkg/repositories"
+	"healthcare-backend/pkg/services"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Operator is the actor recorded for audited CLI actions
+var Operator = auditctx.Identity{ID: "healthctl", Role: auditctx.RoleSystem}
+
+// Env is what every command gets: the open database and where to write
+type Env struct {
+	DB     *gorm.DB
+	JSON   bool // Machine-readable output
+	Stdout io.Writer
+
+	FieldKeys  *privacy.FieldKeys             // From FIELD_ENCRYPTION_KEY; nil when unset
+	Embeddings *services.NoteEmbeddingService // From EMBEDDING_URL; nil when unset
+}
+
+// Command is one healthctl subcommand
+type Command struct {
+	Summary string
+	Run     func(env *Env, args []string) error
+}
+
+// Commands lists every subcommand by name
+var Commands = map[string]Command{
+	"verify-audit-chain": {"Check every audit entry's hash and link", verifyAuditChain},
+	"export-audit":       {"Write the audit chain as NDJSON (--out file, default stdout)", exportAudit},
+	"rebuild-rag-index":  {"Re-scan approved feedback used for similar-case retrieval", rebuildRAGIndex},
+	"run-migrations":     {"Apply pending schema migrations", runMigrations},
+	"create-admin-user":  {"Create an admin API credential (--name)", createAdminUser},
+	"rotate-api-key":     {"Issue a new key for an API credential (--id)", rotateAPIKey},
+	"reencrypt-fields":   {"Reseal encrypted columns under FIELD_ENCRYPTION_KEY", reencryptFields},
+}
+
+// Run parses global flags, opens the database and dispatches to a
+// subcommand. It returns the process exit code.
+func Run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("healthctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dbPath := fs.String("db", database.Path, "SQLite database file")
+	asJSON := fs.Bool("json", false, "machine-readable output")
+	fs.Usage = func() { usage(fs, stderr) }
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		usage(fs, stderr)
+		return 2
+	}
+
+	name := fs.Arg(0)
+	cmd, ok := Commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "healthctl: unknown command %q\n", name)
+		usage(fs, stderr)
+		return 2
+	}
+
+	db, err := database.Open(*dbPath)
+	if err != nil {
+		return fail(stderr, *asJSON, err)
+	}
+	db = db.Session(&gorm.Session{Logger: logger.Default.LogMode(logger.Silent)}) // Keep stdout parseable
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+
+	// Nothing runs against a schema that is being changed underneath it
+	if err := database.CheckUnlocked(db); err != nil {
+		return fail(stderr, *asJSON, err)
+	}
+
+	// Encrypted patient fields read with the server's keys
+	cfg := config.Load()
+	keys, err := cfg.FieldKeys()
+	if err != nil {
+		return fail(stderr, *asJSON, err)
+	}
+	privacy.UseFieldKeys(keys)
+
+	env := &Env{DB: db, JSON: *asJSON, Stdout: stdout, FieldKeys: keys}
+	if cfg.EmbeddingURL != "" {
+		client := services.NewOpenAIEmbeddingClient(cfg.EmbeddingURL, cfg.EmbeddingModel, cfg.EmbeddingAPIKey)
+		env.Embeddings = services.NewNoteEmbeddingService(db, client, cfg.EmbeddingModel, nil)
+	}
+	if err := cmd.Run(env, fs.Args()[1:]); err != nil {
+		return fail(stderr, *asJSON, err)
+	}
+	return 0
+}
+
+func usage(fs *flag.FlagSet, w io.Writer) {
+	fmt.Fprintln(w, "usage: healthctl [--db path] [--json] <command> [flags]")
+	fs.PrintDefaults()
+	fmt.Fprintln(w, "\ncommands:")
+	names := make([]string, 0, len(Commands))
+	for name := range Commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-20s %s\n", name, Commands[name].Summary)
+	}
+}
+
+func fail(w io.Writer, asJSON bool, err error) int {
+	if asJSON {
+		json.NewEncoder(w).Encode(map[string]any{"success": false, "error": err.Error()})
+	} else {
+		fmt.Fprintf(w, "healthctl: %v\n", err)
+	}
+	return 1
+}
+
+// print writes v as one JSON object, or the text line otherwise
+func (e *Env) print(v any, text string, args ...any) error {
+	if e.JSON {
+		return json.NewEncoder(e.Stdout).Encode(v)
+	}
+	_, err := fmt.Fprintf(e.Stdout, text+"\n", args...)
+	return err
+}
+
+// ErrChainBroken is returned when verify-audit-chain finds tampering
+var ErrChainBroken = errors.New("audit chain is broken")
+
+func verifyAuditChain(env *Env, args []string) error {
+	if err := noArgs("verify-audit-chain", args); err != nil {
+		return err
+	}
+	valid, n, verr := services.NewAuditService(env.DB).VerifyChain()
+	result := map[string]any{"valid": valid, "entries": n}
+	if verr != nil {
+		result["error"] = verr.Error()
+	}
+	if valid {
+		return env.print(result, "✅ Audit chain intact (%d entries)", n)
+	}
+	if err := env.print(result, "❌ Audit chain broken at entry %d: %v", n, verr); err != nil {
+		return err
+	}
+	return ErrChainBroken
+}
+
+func exportAudit(env *Env, args []string) error {
+	fs := flag.NewFlagSet("export-audit", flag.ContinueOnError)
+	out := fs.String("out", "", "write NDJSON here instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	audit := services.NewAuditService(env.DB)
+	if *out == "" {
+		_, err := audit.ExportNDJSON(env.Stdout)
+		return err
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	n, err := audit.ExportNDJSON(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return env.print(map[string]any{"entries": n, "path": *out}, "📜 Exported %d audit entries to %s", n, *out)
+}
+
+func rebuildRAGIndex(env *Env, args []string) error {
+	if err := noArgs("rebuild-rag-index", args); err != nil {
+		return err
+	}
+	rag := services.NewRAGService(repositories.NewPatientRepository(env.DB), repositories.NewFeedbackRepository(env.DB))
+	rag.Notes = env.Embeddings
+	stats, err := rag.Reindex()
+	if err != nil {
+		return err
+	}
+	if rag.Notes != nil {
+		return env.print(stats, "🔎 %d approved cases, %d usable, %d without a patient, %d notes embedded", stats.Approved, stats.Indexed, stats.Orphaned, stats.Embedded)
+	}
+	return env.print(stats, "🔎 %d approved cases, %d usable, %d without a patient", stats.Approved, stats.Indexed, stats.Orphaned)
+}
+
+func runMigrations(env *Env, args []string) error {
+	if err := noArgs("run-migrations", args); err != nil {
+		return err
+	}
+	applied, err := database.Migrate(env.DB)
+	if err != nil {
+		return err
+	}
+	status, err := database.NewMigrator(env.DB, database.MigrationsFor(env.DB)).Status()
+	if err != nil {
+		return err
+	}
+	if applied == nil {
+		applied = []int{}
+	}
+	return env.print(map[string]any{"applied": applied, "current_version": status.Current}, "✅ Applied %v, schema at version %d", applied, status.Current)
+}
+
+func createAdminUser(env *Env, args []string) error {
+	fs := flag.NewFlagSet("create-admin-user", flag.ContinueOnError)
+	name := fs.String("name", "", "credential ID, e.g. ops-alice")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	key, cred, err := services.NewCredentialService(env.DB).CreateAdmin(*name)
+	if err != nil {
+		return err
+	}
+	services.NewAuditService(env.DB).LogEvent("API_KEY_CREATED", 0, map[string]string{"id": cred.ID, "role": cred.Role}, Operator)
+	return env.print(map[string]any{"id": cred.ID, "role": cred.Role, "api_key": key},
+		"🔑 Created %s (%s). API key, shown once:\n%s", cred.ID, cred.Role, key)
+}
+
+func rotateAPIKey(env *Env, args []string) error {
+	fs := flag.NewFlagSet("rotate-api-key", flag.ContinueOnError)
+	id := fs.String("id", "", "credential ID to rotate")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	key, cred, err := services.NewCredentialService(env.DB).Rotate(*id)
+	if err != nil {
+		return err
+	}
+	services.NewAuditService(env.DB).LogEvent("API_KEY_ROTATED", 0, map[string]string{"id": cred.ID, "role": cred.Role}, Operator)
+	return env.print(map[string]any{"id": cred.ID, "role": cred.Role, "api_key": key},
+		"🔑 Rotated %s. New API key, shown once:\n%s", cred.ID, key)
+}
+
+// reencryptFields moves every encrypted value onto the current key. Run it
+// after adding a new key with the old one in FIELD_ENCRYPTION_PREVIOUS_KEYS,
+// then drop the old key; or once after enabling encryption.
+func reencryptFields(env *Env, args []string) error {
+	if err := noArgs("reencrypt-fields", args); err != nil {
+		return err
+	}
+	if env.FieldKeys == nil {
+		return errors.New("FIELD_ENCRYPTION_KEY is not set")
+	}
+
+	report, err := services.NewFieldEncryptionService(env.DB, env.FieldKeys).Reencrypt(database.Models()...)
+	if err != nil {
+		return err
+	}
+	services.NewAuditService(env.DB).LogEvent("FIELD_KEY_ROTATED", 0, report, Operator)
+	return env.print(report, "🔐 Resealed %d values in %d of %d rows under key %s (%d changed meanwhile, left as written)",
+		report.Fields, report.Rewritten, report.Scanned, report.KeyID, report.Skipped)
+}
+
+func noArgs(name string, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("%s takes no arguments", name)
+	}
+	return nil
+}
@@ -1,9 +1,16 @@
 package config
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"os"
 	"strconv"
+	"strings"
+	"time"
+
+	"healthcare-backend/pkg/database"
+	"healthcare-backend/pkg/privacy"
 
 	"github.com/joho/godotenv"
 )
@@ -12,27 +19,168 @@ import (
 type Config struct {
 	// Server
 	ServerPort string
+	AppEnv     string // "production" disables every debug/chaos facility
+
+	// Logging
+	LogLevel  string // debug, info, warn or error
+	LogFormat string // "text" for the console, "json" for Loki
 
 	// Database
-	DBHost       string
-	DBUser       string
-	DBPassword   string
-	DBName       string
-	DBPort       string
+	DBDriver   string // "sqlite" (default, for local dev) or "postgres"
+	DBHost     string
+	DBUser     string
+	DBPassword string
+	DBName     string
+	DBPort     string
+	DBSSLMode  string
+
+	// Database connection pool
+	DBMaxOpenConns    int
+	DBMaxIdleConns    int
+	DBConnMaxLifetime time.Duration // Recycles connections, e.g. across Postgres failovers
+
+	// Backups (SQLite only)
+	BackupDir      string
+	BackupMaxBytes int64
+
+	// Audit signing key (PKCS#8 PEM)
+	AuditSigningKey     string // PEM text; takes precedence over the path
+	AuditSigningKeyPath string // Generated here on first boot if missing
+
+	// Read-access audit events are buffered and written in batches
+	AuditAccessFlush time.Duration // Longest an event waits in the buffer
+	AuditAccessBatch int           // Buffered events that trigger an early write
+	AuditQueueSize   int           // Batches the background audit writer queues; 0 writes synchronously
+
+	// IPFS backups
+	IPFSAPIURL    string // Kubo RPC API, e.g. http://ipfs:5001; empty simulates IPFS in memory
+	IPFSBackupKey string // 32-byte AES key, hex or base64; backups need it to be restored
+
+	// Field encryption at rest: 32-byte AES keys, hex or base64. New values
+	// are sealed with the current key; previous ones are only read, until
+	// healthctl reencrypt-fields has moved every row off them.
+	FieldEncryptionKey          string
+	FieldEncryptionPreviousKeys []string
+
+	// Blockchain ledger snapshot
+	LedgerPath          string // Empty keeps the ledger in memory only
+	LedgerSnapshotEvery int    // Save after this many new blocks (and on shutdown)
+
+	// Uploads volume
+	UploadDir       string
+	UploadRetention time.Duration // Unfinished uploads older than this are removed
 
 	// External Services
-	MLServiceURL string
-	RedisURL     string
-	NatsURL      string
+	MLServiceURL      string
+	MLCanaryURL       string  // Optional second ML deployment for gradual rollout
+	MLCanaryPercent   int     // Share of patients (0-100) routed to the canary
+	MLShadowURL       string  // Optional shadow deployment for offline evaluation
+	MLShadowRate      int     // Max shadow calls per second
+	MLShadowTolerance float64 // Per-risk delta still counted as agreement
+	MLStrictContract  bool    // Count and log ML responses with unknown or missing keys
+	MLCacheShared     bool    // Patients with identical vitals share a cached prediction
+	RedisURL          string
+	NatsURL           string
+	OTelEndpoint      string        // OTLP/HTTP collector for traces; empty disables tracing
+	MLTimeout         time.Duration // Bound on each ML call but /diagnose
 
 	// Feature Flags
 	EnableAuditLog  bool
+	AuditStrict     bool // ENABLE_AUDIT_LOG=strict: refuse events without an actor
 	EnableWebSocket bool
+	EnableChaos     bool // Fault injection for resilience testing; ignored in production
+
+	// Kiosk intake
+	IntakeTokenTTLMinutes int
+
+	// Notifications
+	DefaultClinic           string        // Working hours used for patients without a clinic
+	EmergencyWebhookURLs    []string      // Receive every emergency alert as JSON; empty sends none
+	EmergencyWebhookRetries int           // After the first attempt
+	EmergencyWebhookBackoff time.Duration // Before the first retry, doubling after each
+	PublicURL               string        // Base of links sent outside the backend
+
+	// Audit identity
+	JWTSecret    string        // HS256 secret for bearer tokens; empty ignores JWTs
+	JWTTTL       time.Duration // Lifetime of access tokens issued at login or refresh
+	RefreshTTL   time.Duration // Lifetime of refresh tokens
+	APIKeys      []string      // "id:role:key" machine credentials
+	MCPActorID   string        // Actor recorded for MCP tool calls
+	AuthRequired bool          // Reject anonymous /api and /ws callers
+
+	// Privacy
+	PHIRedactionMode  string   // "redact" or "block"
+	PHICustomPatterns []string // Extra regexes treated as PHI
+
+	// Differential privacy for cohort analytics
+	DPDefaultEpsilon float64 // Per query when the caller names none
+	DPBudgetEpsilon  float64 // Per caller per calendar month
+
+	// Monitoring
+	ModelDriftDelta    float64       // Alert when weekly mean confidence drops this much below the trailing month
+	ModelCheckInterval time.Duration // Between MODEL_DRIFT_CHECK audit records
+
+	// Dashboard risk levels of the highest ML risk score
+	RiskMediumThreshold float64
+	RiskHighThreshold   float64
+
+	// Latency
+	AssessBudgetMs int // /api/assess answers within this; slower components complete async. 0 disables
+	BatchWorkers   int // Rows of a batch assessment scored at once
+
+	// Per-patient assessment lock
+	AssessLockMode   string // "wait" for the in-flight assessment, or "reject" with 409 at once
+	AssessLockWaitMs int    // How long "wait" waits before answering 409
+	AssessLockTTLSec int    // Frees the lock if its holder dies
+
+	// WebSockets
+	WSMaxIdle time.Duration // Connections with no messages or pongs for this long are closed
+
+	// Diagnosis SSE streams and long-polls
+	DiagnosisStreamMax time.Duration // Streams close after this long; clients reconnect or poll
+	DiagnosisMaxWait   time.Duration // Cap on GET /api/diagnosis/:id?wait=
+
+	// LLM task retries; tasks failing every retry go to llm.tasks.dlq
+	LLMMaxRetries   int           // Retries after the first failed /diagnose call
+	LLMRetryBackoff time.Duration // Wait before the first retry, doubling after each
+	LLMTimeout      time.Duration // Bound on one /diagnose call; keep it under the 2m ack wait
+
+	// LLM worker pool
+	LLMWorkerConcurrency int // Diagnoses run at once per backend instance
+
+	// RAG: similar past cases given to the LLM
+	RAGTopK          int     // Most cases retrieved
+	RAGMaxDistance   float64 // Cases further away are left out; 0 keeps every case
+	RAGWeights       string  // Feature weights over the defaults, e.g. "gender=0.5,history_stroke=1"
+	RAGMaxCandidates int     // Only the most recent approved cases are compared; 0 is all
+	RAGNoteWeight    float64 // Share of the distance from doctor notes when embeddings are on
+
+	// Note embeddings: an OpenAI-compatible /embeddings endpoint. Empty
+	// URL leaves retrieval to vitals and history.
+	EmbeddingURL    string
+	EmbeddingModel  string
+	EmbeddingAPIKey string
+
+	// Outbox: diagnosis tasks saved with their assessment are sent on
+	// commit; this pass picks up any a crash or NATS outage left behind
+	OutboxInterval time.Duration
+
+	// Graceful shutdown
+	ShutdownTimeout time.Duration // Budget for draining and flushing everything
+	ShutdownDelay   time.Duration // Keep serving, unhealthy, so load balancers notice first
+
+	// Readiness: Redis and NATS fall back gracefully, so by default their
+	// outage only marks /health/ready degraded
+	RequireRedis bool
+	RequireNATS  bool
 
 	// Rate Limits
 	RateLimitGlobalMax   int
 	RateLimitMLMax       int
 	RateLimitFeedbackMax int
+	RateLimitIntakeMax   int // Public kiosk endpoint, per IP
+	RateLimitLoginMax    int // Password attempts, per IP
+	RateLimitBatchMax    int // Batch assessments, per IP; separate from the ML limit
 }
 
 // Global config instance
@@ -42,45 +190,221 @@ var AppConfig *Config
 func Load() *Config {
 	// Try to load .env file (ignore error if not found)
 	if err := godotenv.Load(); err != nil {
-		log.Println("ℹ️ No .env file found, using environment variables")
+		slog.Info("no .env file found, using environment variables")
 	}
 
 	config := &Config{
 		// Server
 		ServerPort: getEnv("SERVER_PORT", "3000"),
+		AppEnv:     getEnv("APP_ENV", "development"),
+
+		// Logging
+		LogLevel:  getEnv("LOG_LEVEL", "info"),
+		LogFormat: getEnv("LOG_FORMAT", defaultLogFormat(getEnv("APP_ENV", "development"))),
 
 		// Database
-		DBHost:       getEnv("DB_HOST", "localhost"),
-		DBUser:       getEnv("DB_USER", "postgres"),
-		DBPassword:   getEnv("DB_PASSWORD", "postgres"),
-		DBName:       getEnv("DB_NAME", "healthcare"),
-		DBPort:       getEnv("DB_PORT", "5432"),
+		DBDriver:   getEnv("DB_DRIVER", database.DriverSQLite),
+		DBHost:     getEnv("DB_HOST", "localhost"),
+		DBUser:     getEnv("DB_USER", "postgres"),
+		DBPassword: getEnv("DB_PASSWORD", "postgres"),
+		DBName:     getEnv("DB_NAME", "healthcare"),
+		DBPort:     getEnv("DB_PORT", "5432"),
+		DBSSLMode:  getEnv("DB_SSLMODE", "disable"),
+
+		// Database connection pool
+		DBMaxOpenConns:    getEnvInt("DB_MAX_OPEN_CONNS", 25),
+		DBMaxIdleConns:    getEnvInt("DB_MAX_IDLE_CONNS", 5),
+		DBConnMaxLifetime: getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+
+		// Backups (SQLite only)
+		BackupDir:      getEnv("DB_BACKUP_DIR", "/app/uploads/backups"),
+		BackupMaxBytes: int64(getEnvInt("DB_BACKUP_MAX_MB", 512)) << 20,
+
+		// Audit signing key (PKCS#8 PEM)
+		AuditSigningKey:     getEnv("AUDIT_SIGNING_KEY", ""),
+		AuditSigningKeyPath: getEnv("AUDIT_SIGNING_KEY_PATH", "/app/uploads/keys/audit_ed25519.pem"),
+
+		// Read-access audit
+		AuditAccessFlush: getEnvDuration("AUDIT_ACCESS_FLUSH_INTERVAL", 5*time.Second),
+		AuditAccessBatch: getEnvInt("AUDIT_ACCESS_BATCH_SIZE", 100),
+		AuditQueueSize:   getEnvInt("AUDIT_QUEUE_SIZE", 1024),
+
+		// IPFS backups
+		IPFSAPIURL:    getEnv("IPFS_API_URL", ""),
+		IPFSBackupKey: getEnv("IPFS_BACKUP_KEY", ""),
+
+		// Field encryption
+		FieldEncryptionKey:          getEnv("FIELD_ENCRYPTION_KEY", ""),
+		FieldEncryptionPreviousKeys: getEnvList("FIELD_ENCRYPTION_PREVIOUS_KEYS", ","),
+
+		// Blockchain ledger snapshot
+		LedgerPath:          getEnv("LEDGER_PATH", "/app/uploads/ledger/chain.json"),
+		LedgerSnapshotEvery: getEnvInt("LEDGER_SNAPSHOT_EVERY", 10),
+
+		// Uploads volume
+		UploadDir:       getEnv("UPLOAD_DIR", "/app/uploads"),
+		UploadRetention: getEnvDuration("UPLOAD_RETENTION", 24*time.Hour),
 
 		// External Services
-		MLServiceURL: getEnv("ML_SERVICE_URL", "http://127.0.0.1:8000"),
-		RedisURL:     getEnv("REDIS_URL", "localhost:6379"),
-		NatsURL:      getEnv("NATS_URL", "nats://localhost:4222"),
+		MLServiceURL:      getEnv("ML_SERVICE_URL", "http://127.0.0.1:8000"),
+		MLCanaryURL:       getEnv("ML_CANARY_URL", ""),
+		MLCanaryPercent:   getEnvInt("ML_CANARY_PERCENT", 0),
+		MLShadowURL:       getEnv("ML_SHADOW_URL", ""),
+		MLShadowRate:      getEnvInt("ML_SHADOW_RATE", 5),
+		MLShadowTolerance: getEnvFloat("ML_SHADOW_TOLERANCE", 5.0),
+		MLStrictContract:  getEnvBool("ML_STRICT_CONTRACT", false),
+		MLCacheShared:     getEnvBool("PREDICTION_CACHE_SHARED", true),
+		RedisURL:          getEnv("REDIS_URL", "localhost:6379"),
+		NatsURL:           getEnv("NATS_URL", "nats://localhost:4222"),
+		OTelEndpoint:      getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+		MLTimeout:         getEnvDuration("ML_TIMEOUT", 15*time.Second),
 
 		// Feature Flags
-		EnableAuditLog:  getEnvBool("ENABLE_AUDIT_LOG", true),
+		EnableAuditLog:  getEnvBool("ENABLE_AUDIT_LOG", true), // "strict" isn't a bool, so also means on
+		AuditStrict:     strings.EqualFold(getEnv("ENABLE_AUDIT_LOG", ""), "strict"),
 		EnableWebSocket: getEnvBool("ENABLE_WEBSOCKET", true),
+		EnableChaos:     getEnvBool("ENABLE_CHAOS", false),
+
+		// Kiosk intake
+		IntakeTokenTTLMinutes: getEnvInt("INTAKE_TOKEN_TTL_MIN", 30),
+
+		// Notifications
+		DefaultClinic:           getEnv("DEFAULT_CLINIC", ""),
+		EmergencyWebhookURLs:    getEnvList("EMERGENCY_WEBHOOK_URLS", ","),
+		EmergencyWebhookRetries: getEnvInt("EMERGENCY_WEBHOOK_RETRIES", 3),
+		EmergencyWebhookBackoff: getEnvDuration("EMERGENCY_WEBHOOK_BACKOFF", time.Second),
+		PublicURL:               strings.TrimRight(getEnv("PUBLIC_URL", ""), "/"),
+
+		// Audit identity
+		JWTSecret:    getEnv("JWT_SECRET", ""),
+		JWTTTL:       getEnvDuration("JWT_TTL", 15*time.Minute),
+		RefreshTTL:   getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		APIKeys:      getEnvList("API_KEYS", ","),
+		MCPActorID:   getEnv("MCP_ACTOR_ID", "mcp-server"),
+		AuthRequired: getEnvBool("AUTH_REQUIRED", true),
+
+		// Privacy
+		PHIRedactionMode:  getEnv("PHI_REDACTION_MODE", "redact"),
+		PHICustomPatterns: getEnvList("PHI_CUSTOM_PATTERNS", ";;"),
+
+		// Differential privacy for cohort analytics
+		DPDefaultEpsilon: getEnvFloat("DP_DEFAULT_EPSILON", 0.5),
+		DPBudgetEpsilon:  getEnvFloat("DP_BUDGET_EPSILON", 10),
+
+		// Monitoring
+		ModelDriftDelta:    getEnvFloat("MODEL_DRIFT_DELTA", 0.05),
+		ModelCheckInterval: getEnvDuration("MODEL_CHECK_INTERVAL", 24*time.Hour),
+
+		// Dashboard risk levels
+		RiskMediumThreshold: getEnvFloat("RISK_MEDIUM_THRESHOLD", 40),
+		RiskHighThreshold:   getEnvFloat("RISK_HIGH_THRESHOLD", 70),
+
+		// Latency
+		AssessBudgetMs: getEnvInt("ASSESS_BUDGET_MS", 2000),
+		BatchWorkers:   getEnvInt("BATCH_ASSESS_WORKERS", 4),
+
+		// Per-patient assessment lock
+		AssessLockMode:   getEnv("ASSESS_LOCK_MODE", "wait"),
+		AssessLockWaitMs: getEnvInt("ASSESS_LOCK_WAIT_MS", 5000),
+		AssessLockTTLSec: getEnvInt("ASSESS_LOCK_TTL_SEC", 60),
+
+		// WebSockets
+		WSMaxIdle: getEnvDuration("WS_MAX_IDLE", 30*time.Minute),
+
+		// Diagnosis SSE streams and long-polls
+		DiagnosisStreamMax: getEnvDuration("DIAGNOSIS_STREAM_MAX", 5*time.Minute),
+		DiagnosisMaxWait:   getEnvDuration("DIAGNOSIS_MAX_WAIT", 60*time.Second),
+
+		// LLM task retries
+		LLMMaxRetries:   getEnvInt("LLM_MAX_RETRIES", 3),
+		LLMRetryBackoff: getEnvDuration("LLM_RETRY_BACKOFF", time.Second),
+		LLMTimeout:      getEnvDuration("LLM_TIMEOUT", 90*time.Second),
+
+		// LLM worker pool
+		LLMWorkerConcurrency: getEnvInt("LLM_WORKER_CONCURRENCY", 4),
+
+		// RAG
+		RAGTopK:          getEnvInt("RAG_TOP_K", 3),
+		RAGMaxDistance:   getEnvFloat("RAG_MAX_DISTANCE", 0.5),
+		RAGWeights:       getEnv("RAG_WEIGHTS", ""),
+		RAGMaxCandidates: getEnvInt("RAG_MAX_CANDIDATES", 500),
+		RAGNoteWeight:    getEnvFloat("RAG_NOTE_WEIGHT", 0.3),
+
+		// Note embeddings
+		EmbeddingURL:    getEnv("EMBEDDING_URL", ""),
+		EmbeddingModel:  getEnv("EMBEDDING_MODEL", "text-embedding-3-small"),
+		EmbeddingAPIKey: getEnv("EMBEDDING_API_KEY", ""),
+
+		// Outbox
+		OutboxInterval: getEnvDuration("OUTBOX_INTERVAL", 10*time.Second),
+
+		// Graceful shutdown
+		ShutdownTimeout: getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+		ShutdownDelay:   getEnvDuration("SHUTDOWN_DELAY", 0),
+
+		// Readiness
+		RequireRedis: getEnvBool("REQUIRE_REDIS", false),
+		RequireNATS:  getEnvBool("REQUIRE_NATS", false),
 
 		// Rate Limits
 		RateLimitGlobalMax:   getEnvInt("RATE_LIMIT_GLOBAL_MAX", 100),
 		RateLimitMLMax:       getEnvInt("RATE_LIMIT_ML_MAX", 20),
 		RateLimitFeedbackMax: getEnvInt("RATE_LIMIT_FEEDBACK_MAX", 10),
+		RateLimitIntakeMax:   getEnvInt("RATE_LIMIT_INTAKE_MAX", 5),
+		RateLimitLoginMax:    getEnvInt("RATE_LIMIT_LOGIN_MAX", 10),
+		RateLimitBatchMax:    getEnvInt("RATE_LIMIT_BATCH_MAX", 2),
 	}
 
 	AppConfig = config
+	return config
+}
 
-	log.Printf("⚙️ Config loaded: Port=%s, DB=%s:%s, ML=%s", 
-		config.ServerPort, 
-		config.DBHost,
-		config.DBPort,
-		config.MLServiceURL,
-	)
+// Database returns the settings database.Connect opens the database with
+func (c *Config) Database() database.Settings {
+	return database.Settings{
+		Driver:          c.DBDriver,
+		Path:            database.Path,
+		Host:            c.DBHost,
+		Port:            c.DBPort,
+		User:            c.DBUser,
+		Password:        c.DBPassword,
+		Name:            c.DBName,
+		SSLMode:         c.DBSSLMode,
+		MaxOpenConns:    c.DBMaxOpenConns,
+		MaxIdleConns:    c.DBMaxIdleConns,
+		ConnMaxLifetime: c.DBConnMaxLifetime,
+	}
+}
 
-	return config
+// FieldKeys parses the field encryption keys; nil when none is configured
+func (c *Config) FieldKeys() (*privacy.FieldKeys, error) {
+	if c.FieldEncryptionKey == "" {
+		if len(c.FieldEncryptionPreviousKeys) > 0 {
+			return nil, errors.New("FIELD_ENCRYPTION_PREVIOUS_KEYS needs a current FIELD_ENCRYPTION_KEY")
+		}
+		return nil, nil
+	}
+	current, err := privacy.ParseFieldKey(c.FieldEncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	var previous [][]byte
+	for _, s := range c.FieldEncryptionPreviousKeys {
+		key, err := privacy.ParseFieldKey(s)
+		if err != nil {
+			return nil, fmt.Errorf("previous key: %w", err)
+		}
+		previous = append(previous, key)
+	}
+	return privacy.NewFieldKeys(current, previous...)
+}
+
+// defaultLogFormat keeps readable logs on a developer's console, JSON elsewhere
+func defaultLogFormat(appEnv string) string {
+	if appEnv == "development" {
+		return "text"
+	}
+	return "json"
 }
 
 // getEnv returns environment variable or default value
@@ -112,3 +436,40 @@ func getEnvInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+// getEnvFloat returns environment variable as float64 or default value
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		f, err := strconv.ParseFloat(value, 64)
+		if err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+// getEnvDuration returns environment variable as a Go duration ("30m") or default value
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		d, err := time.ParseDuration(value)
+		if err == nil && d > 0 {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+// getEnvList returns environment variable split by sep, or nil if unset
+func getEnvList(key, sep string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	var out []string
+	for _, part := range strings.Split(value, sep) {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -1,34 +1,211 @@
 package database
 
 import (
-	"log"
+	"fmt"
+	"log/slog"
 	"math"
 	"math/rand"
 	"time"
+	"healthcare-backend/pkg/logging"
 	"healthcare-backend/pkg/models"
 
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 var DB *gorm.DB
 
-func InitDB() {
+// Drivers selected by DB_DRIVER
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// Open connects to a SQLite file with WAL journaling and a busy timeout so
+// concurrent writers wait for the lock instead of failing immediately.
+func Open(path string) (*gorm.DB, error) {
+	dsn := fmt.Sprintf("%s?_journal_mode=WAL&_busy_timeout=5000", path)
+	return gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+}
+
+// Path is the SQLite database file
+const Path = "clinical.db"
+
+// Settings selects the database and sizes its connection pool
+type Settings struct {
+	Driver string
+	Path   string // SQLite file
+
+	// Postgres
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Name     string
+	SSLMode  string
+
+	MaxOpenConns    int // 0 leaves the pool unbounded
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration // 0 keeps connections forever
+}
+
+// PostgresDSN is the keyword/value connection string for the Postgres fields
+func (s Settings) PostgresDSN() string {
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s TimeZone=UTC",
+		s.Host, s.Port, s.User, s.Password, s.Name, s.SSLMode)
+}
+
+// Connect opens the database s selects and applies its pool settings
+func Connect(s Settings) (*gorm.DB, error) {
+	var db *gorm.DB
+	var err error
+	switch s.Driver {
+	case DriverSQLite, "":
+		db, err = Open(s.Path)
+	case DriverPostgres:
+		db, err = gorm.Open(postgres.Open(s.PostgresDSN()), &gorm.Config{})
+	default:
+		return nil, fmt.Errorf("unknown DB_DRIVER %q, want %q or %q", s.Driver, DriverSQLite, DriverPostgres)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(s.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(s.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(s.ConnMaxLifetime)
+	return db, nil
+}
+
+// Models lists every persisted model; migrations must keep their tables in sync
+func Models() []any {
+	return []any{&models.Provider{}, &models.PatientData{}, &models.Feedback{}, &models.DiagnosisContext{}, &models.Assessment{}, &models.AssessmentPrecision{}, &models.ShadowComparison{}, &models.OverrideLog{}, &models.OverrideReason{}, &models.EKGAnalysis{}, &models.IntakeToken{}, &models.AssessmentComponent{}, &models.AuditLog{}, &models.APICredential{}, &models.UploadedFile{}, &models.ConfigOverride{}, &models.PrivacyBudget{}, &models.Clinic{}, &models.NotificationLog{}, &models.DrugInteraction{}, &models.DrugAlias{}, &models.DiagnosisFailure{}, &models.EmergencyRule{}, &models.User{}, &models.RefreshToken{}, &models.ErasureConfirmation{}, &models.ICD10Code{}, &models.OutboxMessage{}, &models.SearchDocument{}, &models.NoteEmbedding{}, &models.FeedbackRevision{}}
+}
+
+// InitDB connects and checks the schema version. Migrations run here only
+// when applyMigrations is set (development); production runs them with
+// --migrate before rolling out, so old replicas never see surprise columns.
+func InitDB(s Settings, applyMigrations bool) {
 	var err error
-	DB, err = gorm.Open(sqlite.Open("clinical.db"), &gorm.Config{})
+	DB, err = Connect(s)
 	if err != nil {
-		log.Fatal("Failed to connect to database")
+		logging.Fatal("failed to connect to database", "driver", s.Driver, "error", err)
+	}
+
+	migrator := NewMigrator(DB, MigrationsFor(DB))
+	if applyMigrations {
+		if _, err := Migrate(DB); err != nil {
+			logging.Fatal("migration failed", "error", err)
+		}
+	}
+
+	status, err := migrator.CheckCompatibility()
+	if err != nil {
+		logging.Fatal("refusing to start", "error", err)
+	}
+	for _, w := range status.Warnings {
+		slog.Warn("schema", "warning", w)
+	}
+	slog.Info("database schema checked", "version", status.Current, "supported", status.Supported)
+
+	if DB.Migrator().HasTable(&models.SearchDocument{}) {
+		if err := RegisterSearchHooks(DB); err != nil {
+			logging.Fatal("failed to register search hooks", "error", err)
+		}
+	} else {
+		slog.Warn("search index missing, notes and diagnoses won't be indexed until --migrate")
+	}
+
+	if status.Current > 0 {
+		seedDemoData()
+	}
+}
+
+// RunMigrations applies pending migrations and data backfills, for --migrate
+func RunMigrations(s Settings) error {
+	db, err := Connect(s)
+	if err != nil {
+		return err
+	}
+	DB = db
+	_, err = Migrate(db)
+	return err
+}
+
+// Migrate applies the embedded migrations and data backfills under the
+// migration lock, so two instances never migrate the same database at once
+func Migrate(db *gorm.DB) ([]int, error) {
+	release, err := AcquireMigrationLock(db)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	applied, err := NewMigrator(db, MigrationsFor(db)).Up()
+	if err != nil {
+		return applied, err
+	}
+	if len(applied) > 0 {
+		slog.Info("applied migrations", "versions", applied)
+	}
+
+	if n, err := BackfillAssessmentSnapshots(db); err != nil {
+		slog.Warn("assessment snapshot backfill failed", "error", err)
+	} else if n > 0 {
+		slog.Info("backfilled assessment patient snapshots", "assessments", n)
+	}
+
+	if err := EnsureSearchIndex(db); err != nil {
+		return applied, fmt.Errorf("search index: %w", err)
+	}
+	if n, err := BackfillSearchDocuments(db); err != nil {
+		slog.Warn("search index backfill failed", "error", err)
+	} else if n > 0 {
+		slog.Info("indexed notes and diagnoses for search", "documents", n)
+	}
+	return applied, nil
+}
+
+// BackfillAssessmentSnapshots gives assessments created before snapshots existed
+// a copy of the current patient row, flagged as backfilled since the row may
+// have changed since the assessment ran.
+func BackfillAssessmentSnapshots(db *gorm.DB) (int, error) {
+	var pending []models.Assessment
+	if err := db.Where("patient_snapshot IS NULL OR patient_snapshot = ''").Find(&pending).Error; err != nil {
+		return 0, err
+	}
+
+	filled := 0
+	for _, a := range pending {
+		var patient models.PatientData
+		if err := db.First(&patient, a.PatientID).Error; err != nil {
+			slog.Warn("no patient for assessment, snapshot left empty", "patient_id", a.PatientID, "assessment_id", a.ID)
+			continue
+		}
+		if err := a.SetPatientSnapshot(patient); err != nil {
+			return filled, err
+		}
+		a.SnapshotBackfilled = true
+		// A struct update, so the snapshot is encrypted like any other
+		err := db.Model(&a).Select("patient_snapshot", "snapshot_hash", "snapshot_backfilled").Updates(&a).Error
+		if err != nil {
+			return filled, err
+		}
+		filled++
 	}
-	DB.AutoMigrate(&models.PatientData{}, &models.Feedback{})
-	log.Println("✅ Database Migrated (SQLite)")
-	seedDemoData()
+	return filled, nil
 }
 
 func seedDemoData() {
 	var count int64
 	DB.Model(&models.PatientData{}).Count(&count)
 	if count == 0 {
-		log.Println("🌱 Seeding random patient data...")
+		slog.Info("seeding random patient data")
 		rand.Seed(time.Now().UnixNano())
 
 		// Generate 10 random patients
@@ -77,6 +254,6 @@ func seedDemoData() {
 			DB.Create(&patient)
 		}
 		
-		log.Println("🚀 Seeded 10 Random Demo Patients")
+		slog.Info("seeded demo patients", "count", 10)
 	}
 }
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrMigrationLocked = errors.New("another instance holds the migration lock")
+
+// MigrationLockTTL frees a lock left behind by a run that crashed mid-migration
+const MigrationLockTTL = 30 * time.Minute
+
+// MigrationLock is the single row present while someone migrates the schema
+type MigrationLock struct {
+	ID         int       `gorm:"primaryKey;autoIncrement:false" json:"-"`
+	Holder     string    `json:"holder"` // host:pid of the process migrating
+	AcquiredAt time.Time `json:"acquired_at"`
+}
+
+// LockHolder returns the live migration lock, or nil when nobody holds one
+func LockHolder(db *gorm.DB) (*MigrationLock, error) {
+	if !db.Migrator().HasTable(&MigrationLock{}) {
+		return nil, nil
+	}
+	var lock MigrationLock
+	err := db.Where("acquired_at > ?", time.Now().UTC().Add(-MigrationLockTTL)).Limit(1).Find(&lock).Error
+	if err != nil || lock.Holder == "" {
+		return nil, err
+	}
+	return &lock, nil
+}
+
+// CheckUnlocked fails with ErrMigrationLocked while a migration is running
+func CheckUnlocked(db *gorm.DB) error {
+	lock, err := LockHolder(db)
+	if err != nil {
+		return err
+	}
+	if lock != nil {
+		return fmt.Errorf("%w: %s since %s", ErrMigrationLocked, lock.Holder, lock.AcquiredAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// AcquireMigrationLock takes the lock for this process. Stale locks older
+// than MigrationLockTTL are cleared first. Call release when done.
+func AcquireMigrationLock(db *gorm.DB) (release func(), err error) {
+	if err := db.AutoMigrate(&MigrationLock{}); err != nil {
+		return nil, err
+	}
+	if err := db.Where("acquired_at <= ?", time.Now().UTC().Add(-MigrationLockTTL)).Delete(&MigrationLock{}).Error; err != nil {
+		return nil, err
+	}
+
+	host, _ := os.Hostname()
+	lock := MigrationLock{ID: 1, Holder: fmt.Sprintf("%s:%d", host, os.Getpid()), AcquiredAt: time.Now().UTC()}
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&lock)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		if err := CheckUnlocked(db); err != nil {
+			return nil, err
+		}
+		return nil, ErrMigrationLocked
+	}
+
+	return func() {
+		db.Where("id = ? AND holder = ?", lock.ID, lock.Holder).Delete(&MigrationLock{})
+	}, nil
+}
@@ -0,0 +1,255 @@
+package database
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed migrations/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+var (
+	ErrSchemaTooNew        = errors.New("database schema is newer than this binary supports")
+	ErrMigrationOutOfOrder = errors.New("pending migration is older than one already applied")
+	ErrMigrationChecksum   = errors.New("applied migration was edited after it ran")
+)
+
+// Migration is one versioned schema change, loaded from NNNN_name.sql
+type Migration struct {
+	Version  int
+	Name     string
+	SQL      string
+	Checksum string
+}
+
+// SchemaMigration records an applied migration
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false" json:"version"`
+	Name      string    `json:"name"`
+	Checksum  string    `json:"checksum"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// LoadMigrations reads and orders every NNNN_name.sql file in fsys
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]Migration, 0, len(files))
+	seen := map[int]string{}
+	for _, file := range files {
+		prefix, name, ok := strings.Cut(strings.TrimSuffix(path.Base(file), ".sql"), "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %q must be named NNNN_name.sql", file)
+		}
+		if other, dup := seen[version]; dup {
+			return nil, fmt.Errorf("migrations %q and %q share version %d", other, file, version)
+		}
+		seen[version] = file
+
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		migrations = append(migrations, Migration{
+			Version:  version,
+			Name:     name,
+			SQL:      string(data),
+			Checksum: hex.EncodeToString(sum[:]),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// EmbeddedMigrations returns the SQLite migrations compiled into this binary
+func EmbeddedMigrations() []Migration {
+	return embedded("migrations")
+}
+
+// EmbeddedPostgresMigrations returns the same migrations in Postgres SQL.
+// Every SQLite migration needs its Postgres twin under the same version.
+func EmbeddedPostgresMigrations() []Migration {
+	return embedded("migrations/postgres")
+}
+
+// MigrationsFor returns the embedded migrations in db's dialect
+func MigrationsFor(db *gorm.DB) []Migration {
+	if db.Dialector.Name() == DriverPostgres {
+		return EmbeddedPostgresMigrations()
+	}
+	return EmbeddedMigrations()
+}
+
+func embedded(dir string) []Migration {
+	sub, _ := fs.Sub(migrationFiles, dir)
+	migrations, err := LoadMigrations(sub)
+	if err != nil {
+		panic(err) // Broken file names are a build-time mistake
+	}
+	return migrations
+}
+
+// SchemaStatus compares the database with the migrations this binary knows
+type SchemaStatus struct {
+	Current   int      `json:"current_version"`   // Highest applied version
+	Supported int      `json:"supported_version"` // Highest version this binary ships
+	Pending   []int    `json:"pending"`
+	Edited    []int    `json:"edited,omitempty"`  // Applied with a different checksum
+	Unknown   []int    `json:"unknown,omitempty"` // Applied but not shipped with this binary
+	Warnings  []string `json:"warnings,omitempty"`
+}
+
+// Migrator applies versioned migrations and checks schema compatibility
+type Migrator struct {
+	DB         *gorm.DB
+	Migrations []Migration
+}
+
+func NewMigrator(db *gorm.DB, migrations []Migration) *Migrator {
+	return &Migrator{DB: db, Migrations: migrations}
+}
+
+// applied loads the migration history. Read-only callers see an empty
+// history on a fresh database instead of creating the table.
+func (m *Migrator) applied(create bool) (map[int]SchemaMigration, error) {
+	if !m.DB.Migrator().HasTable(&SchemaMigration{}) {
+		if !create {
+			return map[int]SchemaMigration{}, nil
+		}
+		if err := m.DB.AutoMigrate(&SchemaMigration{}); err != nil {
+			return nil, err
+		}
+	}
+	var rows []SchemaMigration
+	if err := m.DB.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int]SchemaMigration, len(rows))
+	for _, r := range rows {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
+
+// Status reports where the database stands relative to this binary
+func (m *Migrator) Status() (SchemaStatus, error) {
+	return m.status(false)
+}
+
+func (m *Migrator) status(create bool) (SchemaStatus, error) {
+	applied, err := m.applied(create)
+	if err != nil {
+		return SchemaStatus{}, err
+	}
+
+	var status SchemaStatus
+	known := map[int]bool{}
+	for _, mig := range m.Migrations {
+		known[mig.Version] = true
+		status.Supported = mig.Version
+		row, ok := applied[mig.Version]
+		if !ok {
+			status.Pending = append(status.Pending, mig.Version)
+			continue
+		}
+		if row.Checksum != mig.Checksum {
+			status.Edited = append(status.Edited, mig.Version)
+			status.Warnings = append(status.Warnings, fmt.Sprintf("migration %d (%s) was edited after it was applied", mig.Version, mig.Name))
+		}
+	}
+	for v := range applied {
+		if v > status.Current {
+			status.Current = v
+		}
+		if !known[v] {
+			status.Unknown = append(status.Unknown, v)
+		}
+	}
+	sort.Ints(status.Unknown)
+
+	if status.Current < status.Supported {
+		status.Warnings = append(status.Warnings, fmt.Sprintf("schema version %d is behind this binary (%d); run --migrate", status.Current, status.Supported))
+	}
+	return status, nil
+}
+
+// CheckCompatibility refuses a schema newer than the binary. Older schemas
+// and edited migrations only produce warnings, since serving still works.
+func (m *Migrator) CheckCompatibility() (SchemaStatus, error) {
+	return m.checkCompatibility(false)
+}
+
+func (m *Migrator) checkCompatibility(create bool) (SchemaStatus, error) {
+	status, err := m.status(create)
+	if err != nil {
+		return status, err
+	}
+	if status.Current > status.Supported {
+		return status, fmt.Errorf("%w: database at %d, binary supports %d", ErrSchemaTooNew, status.Current, status.Supported)
+	}
+	return status, nil
+}
+
+// Up applies every pending migration in order, each in its own transaction.
+// It refuses to run when an applied migration was edited, when the database
+// is ahead of the binary, or when a pending migration would land below an
+// applied one (that usually means two branches merged in the wrong order).
+func (m *Migrator) Up() ([]int, error) {
+	status, err := m.checkCompatibility(true)
+	if err != nil {
+		return nil, err
+	}
+	if len(status.Edited) > 0 {
+		return nil, fmt.Errorf("%w: versions %v", ErrMigrationChecksum, status.Edited)
+	}
+	for _, v := range status.Pending {
+		if v < status.Current {
+			return nil, fmt.Errorf("%w: %d is pending but %d is applied", ErrMigrationOutOfOrder, v, status.Current)
+		}
+	}
+
+	pending := map[int]bool{}
+	for _, v := range status.Pending {
+		pending[v] = true
+	}
+
+	var done []int
+	for _, mig := range m.Migrations {
+		if !pending[mig.Version] {
+			continue
+		}
+		err := m.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(mig.SQL).Error; err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{
+				Version:   mig.Version,
+				Name:      mig.Name,
+				Checksum:  mig.Checksum,
+				AppliedAt: time.Now().UTC(),
+			}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %d (%s): %w", mig.Version, mig.Name, err)
+		}
+		done = append(done, mig.Version)
+	}
+	return done, nil
+}
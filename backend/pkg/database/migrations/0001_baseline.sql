@@ -0,0 +1,51 @@
+-- Schema as created by GORM AutoMigrate up to the introduction of versioned
+-- migrations. IF NOT EXISTS lets databases that were auto-migrated adopt it.
+
+CREATE TABLE IF NOT EXISTS `providers` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`user_id` integer,`name` text,`specialty` text,`on_call` numeric,`active` numeric);
+CREATE INDEX IF NOT EXISTS `idx_providers_on_call` ON `providers`(`on_call`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_providers_user_id` ON `providers`(`user_id`);
+
+CREATE TABLE IF NOT EXISTS `patient_data` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`name` text,`age` integer,`gender` text,`systolic_bp` integer,`diastolic_bp` integer,`glucose` integer,`bmi` real,`cholesterol` integer,`heart_rate` integer,`steps` integer,`smoking` text,`alcohol` text,`medications` text,`history_heart_disease` text,`history_stroke` text,`history_diabetes` text,`history_high_chol` text,`symptoms` text,`source` text,`assigned_provider_id` integer,CONSTRAINT `fk_patient_data_assigned_provider` FOREIGN KEY (`assigned_provider_id`) REFERENCES `providers`(`id`));
+CREATE INDEX IF NOT EXISTS `idx_patient_data_assigned_provider_id` ON `patient_data`(`assigned_provider_id`);
+
+CREATE TABLE IF NOT EXISTS `feedbacks` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`assessment_id` text,`patient_id` integer,`doctor_approved` numeric,`doctor_notes` text,`risk_profile` text);
+
+CREATE TABLE IF NOT EXISTS `diagnosis_contexts` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`patient_id` integer,`past_context` text,`privacy_mode` text,`redaction_count` integer,`blocked` numeric);
+CREATE INDEX IF NOT EXISTS `idx_diagnosis_contexts_patient_id` ON `diagnosis_contexts`(`patient_id`);
+
+CREATE TABLE IF NOT EXISTS `assessments` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`patient_id` integer,`heart_risk` real,`diabetes_risk` real,`stroke_risk` real,`kidney_risk` real,`general_health_score` real,`clinical_confidence` real,`rule_based` numeric,`ml_backend` text,`emergency` numeric,`audit_hash` text,`patient_snapshot` text,`snapshot_hash` text,`snapshot_backfilled` numeric);
+CREATE INDEX IF NOT EXISTS `idx_assessments_patient_id` ON `assessments`(`patient_id`);
+CREATE INDEX IF NOT EXISTS `idx_assessments_created_at` ON `assessments`(`created_at`);
+
+CREATE TABLE IF NOT EXISTS `assessment_precisions` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`assessment_id` integer,`model_name` text,`confidence` real,`rule_based` numeric,CONSTRAINT `fk_assessments_precisions` FOREIGN KEY (`assessment_id`) REFERENCES `assessments`(`id`));
+CREATE INDEX IF NOT EXISTS `idx_assessment_precisions_model_name` ON `assessment_precisions`(`model_name`);
+CREATE INDEX IF NOT EXISTS `idx_assessment_precisions_assessment_id` ON `assessment_precisions`(`assessment_id`);
+CREATE INDEX IF NOT EXISTS `idx_assessment_precisions_created_at` ON `assessment_precisions`(`created_at`);
+
+CREATE TABLE IF NOT EXISTS `shadow_comparisons` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`patient_id` integer,`primary_backend` text,`primary_heart` real,`primary_diab` real,`primary_stroke` real,`primary_kidney` real,`shadow_heart` real,`shadow_diab` real,`shadow_stroke` real,`shadow_kidney` real,`delta_heart` real,`delta_diab` real,`delta_stroke` real,`delta_kidney` real,`max_abs_delta` real,`agree` numeric,`shadow_error` text,`shadow_latency_ms` integer);
+CREATE INDEX IF NOT EXISTS `idx_shadow_comparisons_max_abs_delta` ON `shadow_comparisons`(`max_abs_delta`);
+CREATE INDEX IF NOT EXISTS `idx_shadow_comparisons_patient_id` ON `shadow_comparisons`(`patient_id`);
+CREATE INDEX IF NOT EXISTS `idx_shadow_comparisons_created_at` ON `shadow_comparisons`(`created_at`);
+
+CREATE TABLE IF NOT EXISTS `override_logs` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`feedback_id` integer,`patient_id` integer,`original_prediction` text,`doctor_override` text,`reason_code` text,`reason` text,`reason_text` text,`model_name` text,`oversight_type` text);
+CREATE INDEX IF NOT EXISTS `idx_override_logs_model_name` ON `override_logs`(`model_name`);
+CREATE INDEX IF NOT EXISTS `idx_override_logs_reason_code` ON `override_logs`(`reason_code`);
+CREATE INDEX IF NOT EXISTS `idx_override_logs_patient_id` ON `override_logs`(`patient_id`);
+CREATE INDEX IF NOT EXISTS `idx_override_logs_feedback_id` ON `override_logs`(`feedback_id`);
+CREATE INDEX IF NOT EXISTS `idx_override_logs_created_at` ON `override_logs`(`created_at`);
+
+CREATE TABLE IF NOT EXISTS `override_reasons` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`code` text,`label` text,`requires_text` numeric,`active` numeric);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_override_reasons_code` ON `override_reasons`(`code`);
+
+CREATE TABLE IF NOT EXISTS `ekg_analyses` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`patient_id` integer,`status` text,`top_condition` text,`top_probability` real,`mean_hr` real,`sdnn` real,`rmssd` real,`qrs_duration` real,`extras` text);
+CREATE INDEX IF NOT EXISTS `idx_ekg_analyses_patient_id` ON `ekg_analyses`(`patient_id`);
+CREATE INDEX IF NOT EXISTS `idx_ekg_analyses_created_at` ON `ekg_analyses`(`created_at`);
+
+CREATE TABLE IF NOT EXISTS `intake_tokens` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`token_hash` text,`clinic` text,`expires_at` datetime,`used_at` datetime,`patient_id` integer);
+CREATE INDEX IF NOT EXISTS `idx_intake_tokens_clinic` ON `intake_tokens`(`clinic`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_intake_tokens_token_hash` ON `intake_tokens`(`token_hash`);
+
+CREATE TABLE IF NOT EXISTS `assessment_components` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`assessment_id` integer,`component` text,`status` text,`late` numeric,`latency_ms` integer,`error` text,`result` text);
+CREATE INDEX IF NOT EXISTS `idx_assessment_components_assessment_id` ON `assessment_components`(`assessment_id`);
+
+CREATE TABLE IF NOT EXISTS `audit_logs` (`id` integer PRIMARY KEY AUTOINCREMENT,`timestamp` datetime,`event_type` text,`patient_id_hash` text,`payload_hash` text,`prev_hash` text,`current_hash` text,`actor_id` text,`actor_signature` text,`actor_public_key` text);
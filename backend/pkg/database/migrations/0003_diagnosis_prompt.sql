@@ -0,0 +1,12 @@
+-- What was queued for the LLM per diagnosis, for transparency review. Rows
+-- recorded before this migration keep these empty and are refused by
+-- GET /api/admin/diagnosis/:id/prompt.
+ALTER TABLE `diagnosis_contexts` ADD COLUMN `assessment_id` integer;
+ALTER TABLE `diagnosis_contexts` ADD COLUMN `prompt_version` text;
+ALTER TABLE `diagnosis_contexts` ADD COLUMN `system_prompt` text;
+ALTER TABLE `diagnosis_contexts` ADD COLUMN `prompt` text;
+ALTER TABLE `diagnosis_contexts` ADD COLUMN `llm_model` text;
+ALTER TABLE `diagnosis_contexts` ADD COLUMN `request` text;
+ALTER TABLE `diagnosis_contexts` ADD COLUMN `ml_endpoint` text;
+ALTER TABLE `diagnosis_contexts` ADD COLUMN `generation` integer;
+CREATE INDEX IF NOT EXISTS `idx_diagnosis_contexts_assessment_id` ON `diagnosis_contexts`(`assessment_id`);
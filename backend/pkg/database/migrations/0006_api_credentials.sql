@@ -0,0 +1,3 @@
+-- API keys created and rotated by healthctl. Keys from API_KEYS keep working.
+CREATE TABLE IF NOT EXISTS `api_credentials` (`id` text,`created_at` datetime,`role` text,`key_hash` text,`rotated_at` datetime,PRIMARY KEY (`id`));
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_api_credentials_key_hash` ON `api_credentials`(`key_hash`);
@@ -0,0 +1,6 @@
+-- Registry of files saved to the uploads volume, for retention cleanup.
+-- Files uploaded before this show up as orphans until cleanup removes them.
+CREATE TABLE IF NOT EXISTS `uploaded_files` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`path` text,`sha256` text,`size_bytes` integer,`purpose` text,`owner` text,`status` text,`finished_at` datetime);
+CREATE INDEX IF NOT EXISTS `idx_uploaded_files_created_at` ON `uploaded_files`(`created_at`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_uploaded_files_path` ON `uploaded_files`(`path`);
+CREATE INDEX IF NOT EXISTS `idx_uploaded_files_status` ON `uploaded_files`(`status`);
@@ -0,0 +1,3 @@
+-- Differential-privacy epsilon spent per caller per month on cohort analytics
+CREATE TABLE IF NOT EXISTS `privacy_budgets` (`id` integer PRIMARY KEY AUTOINCREMENT,`subject` text,`period` text,`spent` real,`updated_at` datetime);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_privacy_budget_period` ON `privacy_budgets`(`subject`,`period`);
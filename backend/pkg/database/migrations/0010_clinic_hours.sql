@@ -0,0 +1,11 @@
+-- Per-clinic working hours and the notification log recording whether each
+-- alert was sent, deferred to the next working window or escalated to on-call.
+-- Existing patients have no clinic and fall back to DEFAULT_CLINIC.
+CREATE TABLE IF NOT EXISTS `clinics` (`id` text,`updated_at` datetime,`name` text,`timezone` text,`hours` text,`holidays` text,PRIMARY KEY (`id`));
+CREATE TABLE IF NOT EXISTS `notification_logs` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`kind` text,`critical` numeric,`patient_id` integer,`clinic` text,`decision` text,`reason` text,`recipients` text,`payload` text,`deliver_at` datetime,`sent_at` datetime);
+CREATE INDEX IF NOT EXISTS `idx_notification_logs_created_at` ON `notification_logs`(`created_at`);
+CREATE INDEX IF NOT EXISTS `idx_notification_logs_patient_id` ON `notification_logs`(`patient_id`);
+CREATE INDEX IF NOT EXISTS `idx_notification_logs_decision` ON `notification_logs`(`decision`);
+CREATE INDEX IF NOT EXISTS `idx_notification_logs_deliver_at` ON `notification_logs`(`deliver_at`);
+ALTER TABLE `patient_data` ADD COLUMN `clinic` text;
+CREATE INDEX IF NOT EXISTS `idx_patient_data_clinic` ON `patient_data`(`clinic`);
@@ -0,0 +1,4 @@
+-- DELETE /api/patients/:id now soft-deletes, so audit entries and
+-- assessments keep referring to an existing row.
+ALTER TABLE `patient_data` ADD COLUMN `deleted_at` datetime;
+CREATE INDEX IF NOT EXISTS `idx_patient_data_deleted_at` ON `patient_data`(`deleted_at`);
@@ -0,0 +1,5 @@
+-- Medication interaction table and brand/generic aliases behind
+-- CheckMedications. Rows are seeded by MedicationService on startup.
+CREATE TABLE IF NOT EXISTS `drug_interactions` (`id` integer PRIMARY KEY AUTOINCREMENT,`drug_a` text,`drug_b` text,`condition` numeric,`severity` text,`description` text);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_drug_interactions_pair` ON `drug_interactions`(`drug_a`,`drug_b`);
+CREATE TABLE IF NOT EXISTS `drug_aliases` (`alias` text,`generic` text,PRIMARY KEY (`alias`));
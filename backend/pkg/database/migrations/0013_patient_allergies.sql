@@ -0,0 +1,3 @@
+-- Recorded allergies, cross-checked against the medication list on every
+-- assessment. Existing patients have none recorded.
+ALTER TABLE `patient_data` ADD COLUMN `allergies` text;
@@ -0,0 +1,4 @@
+-- Fingerprint of the key that signed each audit entry. Existing rows stay
+-- empty and are verified against the public key stored alongside them.
+ALTER TABLE `audit_logs` ADD COLUMN `key_id` text;
+CREATE INDEX IF NOT EXISTS `idx_audit_logs_key_id` ON `audit_logs`(`key_id`);
@@ -0,0 +1,3 @@
+-- Raw ML feature contributions behind each assessment's explanations.
+-- Earlier ML assessments keep an empty value and report no factors.
+ALTER TABLE `assessments` ADD COLUMN `contributions` text;
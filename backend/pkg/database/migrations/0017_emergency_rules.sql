@@ -0,0 +1,2 @@
+-- Admin-editable emergency triggers, seeded with the defaults on first start
+CREATE TABLE IF NOT EXISTS `emergency_rules` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`field` text,`operator` text,`threshold` real,`enabled` numeric,`description` text);
@@ -0,0 +1,4 @@
+-- Emergency webhook deliveries share the notification log
+ALTER TABLE `notification_logs` ADD COLUMN `destination` text;
+ALTER TABLE `notification_logs` ADD COLUMN `attempts` integer;
+CREATE INDEX IF NOT EXISTS `idx_notification_logs_kind` ON `notification_logs`(`kind`);
@@ -0,0 +1,3 @@
+-- Password accounts that sign in for a JWT at /api/auth/login
+CREATE TABLE IF NOT EXISTS `users` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`email` text,`name` text,`role` text,`password_hash` text);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_users_email` ON `users`(`email`);
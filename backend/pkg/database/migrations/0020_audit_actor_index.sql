@@ -0,0 +1,2 @@
+-- GET /api/audit/actors/:id lists events by actor
+CREATE INDEX IF NOT EXISTS `idx_audit_logs_actor_id` ON `audit_logs`(`actor_id`);
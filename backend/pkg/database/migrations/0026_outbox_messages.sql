@@ -0,0 +1,4 @@
+-- Diagnosis tasks saved with their assessment and sent to NATS after it
+-- commits, so a crash in between can't lose or orphan one
+CREATE TABLE IF NOT EXISTS `outbox_messages` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`subject` text,`data` text,`header` text,`attempts` integer,`last_error` text,`sent_at` datetime);
+CREATE INDEX IF NOT EXISTS `idx_outbox_messages_sent_at` ON `outbox_messages`(`sent_at`);
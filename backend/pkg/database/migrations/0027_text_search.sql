@@ -0,0 +1,8 @@
+-- Finished LLM diagnoses, kept for search after the cache expires
+ALTER TABLE `diagnosis_contexts` ADD COLUMN `diagnosis` text;
+-- Text that search covers: doctor notes and diagnoses. The full-text index
+-- over body is an FTS table created by database.EnsureSearchIndex, since
+-- whether FTS5 or FTS4 is available depends on how the driver was built.
+CREATE TABLE IF NOT EXISTS `search_documents` (`id` integer PRIMARY KEY AUTOINCREMENT,`source` text,`source_id` integer,`patient_id` integer,`assessment_id` integer,`body` text);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_search_documents_source` ON `search_documents`(`source`,`source_id`);
+CREATE INDEX IF NOT EXISTS `idx_search_documents_patient_id` ON `search_documents`(`patient_id`);
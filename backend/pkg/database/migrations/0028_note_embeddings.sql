@@ -0,0 +1,4 @@
+-- Vectors of approved doctor notes, so similar-case retrieval can compare
+-- what doctors wrote as well as vitals
+CREATE TABLE IF NOT EXISTS `note_embeddings` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`feedback_id` integer,`model` text,`vector` blob);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_note_embeddings_feedback_id` ON `note_embeddings`(`feedback_id`);
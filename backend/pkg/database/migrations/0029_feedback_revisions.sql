@@ -0,0 +1,5 @@
+-- Who submitted each feedback, so only they or an admin can amend it
+ALTER TABLE `feedbacks` ADD COLUMN `author_id` text;
+-- Every version of amended doctor notes; rows are never updated
+CREATE TABLE IF NOT EXISTS `feedback_revisions` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`feedback_id` integer,`revision` integer,`doctor_notes` text,`author_id` text,`reason` text);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_feedback_revisions_feedback_revision` ON `feedback_revisions`(`feedback_id`,`revision`);
@@ -0,0 +1,3 @@
+-- Who overrode the models, for Article 14 reporting by doctor
+ALTER TABLE `override_logs` ADD COLUMN `doctor_id` text;
+CREATE INDEX IF NOT EXISTS `idx_override_logs_doctor_id` ON `override_logs`(`doctor_id`);
@@ -0,0 +1,51 @@
+-- The SQLite baseline in Postgres types. Floats are double precision rather
+-- than GORM's decimal, so scores read back exactly as they were written.
+
+CREATE TABLE IF NOT EXISTS "providers" ("id" bigserial PRIMARY KEY,"created_at" timestamptz,"user_id" bigint,"name" text,"specialty" text,"on_call" boolean,"active" boolean);
+CREATE INDEX IF NOT EXISTS "idx_providers_on_call" ON "providers"("on_call");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_providers_user_id" ON "providers"("user_id");
+
+CREATE TABLE IF NOT EXISTS "patient_data" ("id" bigserial PRIMARY KEY,"created_at" timestamptz,"name" text,"age" bigint,"gender" text,"systolic_bp" bigint,"diastolic_bp" bigint,"glucose" bigint,"bmi" double precision,"cholesterol" bigint,"heart_rate" bigint,"steps" bigint,"smoking" text,"alcohol" text,"medications" text,"history_heart_disease" text,"history_stroke" text,"history_diabetes" text,"history_high_chol" text,"symptoms" text,"source" text,"assigned_provider_id" bigint,CONSTRAINT "fk_patient_data_assigned_provider" FOREIGN KEY ("assigned_provider_id") REFERENCES "providers"("id"));
+CREATE INDEX IF NOT EXISTS "idx_patient_data_assigned_provider_id" ON "patient_data"("assigned_provider_id");
+
+CREATE TABLE IF NOT EXISTS "feedbacks" ("id" bigserial PRIMARY KEY,"created_at" timestamptz,"assessment_id" text,"patient_id" bigint,"doctor_approved" boolean,"doctor_notes" text,"risk_profile" text);
+
+CREATE TABLE IF NOT EXISTS "diagnosis_contexts" ("id" bigserial PRIMARY KEY,"created_at" timestamptz,"patient_id" bigint,"past_context" text,"privacy_mode" text,"redaction_count" bigint,"blocked" boolean);
+CREATE INDEX IF NOT EXISTS "idx_diagnosis_contexts_patient_id" ON "diagnosis_contexts"("patient_id");
+
+CREATE TABLE IF NOT EXISTS "assessments" ("id" bigserial PRIMARY KEY,"created_at" timestamptz,"patient_id" bigint,"heart_risk" double precision,"diabetes_risk" double precision,"stroke_risk" double precision,"kidney_risk" double precision,"general_health_score" double precision,"clinical_confidence" double precision,"rule_based" boolean,"ml_backend" text,"emergency" boolean,"audit_hash" text,"patient_snapshot" text,"snapshot_hash" text,"snapshot_backfilled" boolean);
+CREATE INDEX IF NOT EXISTS "idx_assessments_patient_id" ON "assessments"("patient_id");
+CREATE INDEX IF NOT EXISTS "idx_assessments_created_at" ON "assessments"("created_at");
+
+CREATE TABLE IF NOT EXISTS "assessment_precisions" ("id" bigserial PRIMARY KEY,"created_at" timestamptz,"assessment_id" bigint,"model_name" text,"confidence" double precision,"rule_based" boolean,CONSTRAINT "fk_assessments_precisions" FOREIGN KEY ("assessment_id") REFERENCES "assessments"("id"));
+CREATE INDEX IF NOT EXISTS "idx_assessment_precisions_model_name" ON "assessment_precisions"("model_name");
+CREATE INDEX IF NOT EXISTS "idx_assessment_precisions_assessment_id" ON "assessment_precisions"("assessment_id");
+CREATE INDEX IF NOT EXISTS "idx_assessment_precisions_created_at" ON "assessment_precisions"("created_at");
+
+CREATE TABLE IF NOT EXISTS "shadow_comparisons" ("id" bigserial PRIMARY KEY,"created_at" timestamptz,"patient_id" bigint,"primary_backend" text,"primary_heart" double precision,"primary_diab" double precision,"primary_stroke" double precision,"primary_kidney" double precision,"shadow_heart" double precision,"shadow_diab" double precision,"shadow_stroke" double precision,"shadow_kidney" double precision,"delta_heart" double precision,"delta_diab" double precision,"delta_stroke" double precision,"delta_kidney" double precision,"max_abs_delta" double precision,"agree" boolean,"shadow_error" text,"shadow_latency_ms" bigint);
+CREATE INDEX IF NOT EXISTS "idx_shadow_comparisons_max_abs_delta" ON "shadow_comparisons"("max_abs_delta");
+CREATE INDEX IF NOT EXISTS "idx_shadow_comparisons_patient_id" ON "shadow_comparisons"("patient_id");
+CREATE INDEX IF NOT EXISTS "idx_shadow_comparisons_created_at" ON "shadow_comparisons"("created_at");
+
+CREATE TABLE IF NOT EXISTS "override_logs" ("id" bigserial PRIMARY KEY,"created_at" timestamptz,"feedback_id" bigint,"patient_id" bigint,"original_prediction" text,"doctor_override" text,"reason_code" text,"reason" text,"reason_text" text,"model_name" text,"oversight_type" text);
+CREATE INDEX IF NOT EXISTS "idx_override_logs_model_name" ON "override_logs"("model_name");
+CREATE INDEX IF NOT EXISTS "idx_override_logs_reason_code" ON "override_logs"("reason_code");
+CREATE INDEX IF NOT EXISTS "idx_override_logs_patient_id" ON "override_logs"("patient_id");
+CREATE INDEX IF NOT EXISTS "idx_override_logs_feedback_id" ON "override_logs"("feedback_id");
+CREATE INDEX IF NOT EXISTS "idx_override_logs_created_at" ON "override_logs"("created_at");
+
+CREATE TABLE IF NOT EXISTS "override_reasons" ("id" bigserial PRIMARY KEY,"created_at" timestamptz,"updated_at" timestamptz,"code" text,"label" text,"requires_text" boolean,"active" boolean);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_override_reasons_code" ON "override_reasons"("code");
+
+CREATE TABLE IF NOT EXISTS "ekg_analyses" ("id" bigserial PRIMARY KEY,"created_at" timestamptz,"patient_id" bigint,"status" text,"top_condition" text,"top_probability" double precision,"mean_hr" double precision,"sdnn" double precision,"rmssd" double precision,"qrs_duration" double precision,"extras" text);
+CREATE INDEX IF NOT EXISTS "idx_ekg_analyses_patient_id" ON "ekg_analyses"("patient_id");
+CREATE INDEX IF NOT EXISTS "idx_ekg_analyses_created_at" ON "ekg_analyses"("created_at");
+
+CREATE TABLE IF NOT EXISTS "intake_tokens" ("id" bigserial PRIMARY KEY,"created_at" timestamptz,"token_hash" text,"clinic" text,"expires_at" timestamptz,"used_at" timestamptz,"patient_id" bigint);
+CREATE INDEX IF NOT EXISTS "idx_intake_tokens_clinic" ON "intake_tokens"("clinic");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_intake_tokens_token_hash" ON "intake_tokens"("token_hash");
+
+CREATE TABLE IF NOT EXISTS "assessment_components" ("id" bigserial PRIMARY KEY,"created_at" timestamptz,"updated_at" timestamptz,"assessment_id" bigint,"component" text,"status" text,"late" boolean,"latency_ms" bigint,"error" text,"result" text);
+CREATE INDEX IF NOT EXISTS "idx_assessment_components_assessment_id" ON "assessment_components"("assessment_id");
+
+CREATE TABLE IF NOT EXISTS "audit_logs" ("id" bigserial PRIMARY KEY,"timestamp" timestamptz,"event_type" text,"patient_id_hash" text,"payload_hash" text,"prev_hash" text,"current_hash" text,"actor_id" text,"actor_signature" text,"actor_public_key" text);
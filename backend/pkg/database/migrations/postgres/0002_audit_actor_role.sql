@@ -0,0 +1,3 @@
+-- Role of the actor behind each audit entry. Existing rows stay empty: the
+-- role is part of the entry hash only when set, so old entries still verify.
+ALTER TABLE "audit_logs" ADD COLUMN "actor_role" text;
@@ -0,0 +1,4 @@
+-- Measurements an intake left out, so the ML payload can omit them and let
+-- the model impute. Existing patients keep this empty: their stored values
+-- are sent as before.
+ALTER TABLE "patient_data" ADD COLUMN "imputed_fields" text;
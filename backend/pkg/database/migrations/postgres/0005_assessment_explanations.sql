@@ -0,0 +1,3 @@
+-- Plain-language risk explanations shown on the assessment and its report.
+-- Earlier assessments have none.
+ALTER TABLE "assessments" ADD COLUMN "explanations" text;
@@ -0,0 +1,2 @@
+-- Runtime settings such as feature flags, editable without a redeploy
+CREATE TABLE IF NOT EXISTS "config_overrides" ("key" text,"value" text,"updated_at" timestamptz,"updated_by" text,PRIMARY KEY ("key"));
@@ -0,0 +1,3 @@
+-- Differential-privacy epsilon spent per caller per month on cohort analytics
+CREATE TABLE IF NOT EXISTS "privacy_budgets" ("id" bigserial PRIMARY KEY,"subject" text,"period" text,"spent" double precision,"updated_at" timestamptz);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_privacy_budget_period" ON "privacy_budgets"("subject","period");
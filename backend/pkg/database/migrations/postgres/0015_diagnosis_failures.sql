@@ -0,0 +1,5 @@
+-- LLM diagnosis tasks that failed every retry, listed and requeued by admins
+CREATE TABLE IF NOT EXISTS "diagnosis_failures" ("id" bigserial PRIMARY KEY,"created_at" timestamptz,"patient_id" bigint,"generation" bigint,"attempts" bigint,"error" text,"request" text,"requeued_at" timestamptz);
+CREATE INDEX IF NOT EXISTS "idx_diagnosis_failures_created_at" ON "diagnosis_failures"("created_at");
+CREATE INDEX IF NOT EXISTS "idx_diagnosis_failures_patient_id" ON "diagnosis_failures"("patient_id");
+CREATE INDEX IF NOT EXISTS "idx_diagnosis_failures_requeued_at" ON "diagnosis_failures"("requeued_at");
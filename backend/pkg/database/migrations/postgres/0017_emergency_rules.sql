@@ -0,0 +1,2 @@
+-- Admin-editable emergency triggers, seeded with the defaults on first start
+CREATE TABLE IF NOT EXISTS "emergency_rules" ("id" bigserial PRIMARY KEY,"created_at" timestamptz,"updated_at" timestamptz,"field" text,"operator" text,"threshold" double precision,"enabled" boolean,"description" text);
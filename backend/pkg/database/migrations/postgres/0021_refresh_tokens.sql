@@ -0,0 +1,4 @@
+-- Refresh tokens for /api/auth/refresh; only hashes are stored
+CREATE TABLE IF NOT EXISTS "refresh_tokens" ("id" bigserial PRIMARY KEY,"created_at" timestamptz,"user_id" bigint,"token_hash" text,"expires_at" timestamptz,"revoked_at" timestamptz);
+CREATE INDEX IF NOT EXISTS "idx_refresh_tokens_user_id" ON "refresh_tokens"("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_refresh_tokens_token_hash" ON "refresh_tokens"("token_hash");
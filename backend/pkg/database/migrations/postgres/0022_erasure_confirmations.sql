@@ -0,0 +1,4 @@
+-- Single-use confirmation tokens for DELETE /api/patients/:id/erase; only hashes are stored
+CREATE TABLE IF NOT EXISTS "erasure_confirmations" ("id" bigserial PRIMARY KEY,"created_at" timestamptz,"token_hash" text,"patient_id" bigint,"expires_at" timestamptz,"used_at" timestamptz);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_erasure_confirmations_token_hash" ON "erasure_confirmations"("token_hash");
+CREATE INDEX IF NOT EXISTS "idx_erasure_confirmations_patient_id" ON "erasure_confirmations"("patient_id");
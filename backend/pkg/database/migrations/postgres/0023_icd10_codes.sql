@@ -0,0 +1,3 @@
+-- ICD-10 code table for diagnosis coding, and the codes doctors confirm in feedback
+CREATE TABLE IF NOT EXISTS "icd10_codes" ("code" text,"description" text,"keywords" text,PRIMARY KEY ("code"));
+ALTER TABLE "feedbacks" ADD COLUMN "icd10_codes" text;
@@ -0,0 +1,2 @@
+-- Every risk model a doctor disagreed with in an override, as a JSON array
+ALTER TABLE "override_logs" ADD COLUMN "models_overridden" text;
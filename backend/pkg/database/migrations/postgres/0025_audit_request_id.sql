@@ -0,0 +1,4 @@
+-- The X-Request-ID of the request an audit entry was logged in, to
+-- correlate it with backend, ML and worker logs
+ALTER TABLE "audit_logs" ADD COLUMN "request_id" text;
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_request_id" ON "audit_logs"("request_id");
@@ -0,0 +1,9 @@
+-- Finished LLM diagnoses, kept for search after the cache expires
+ALTER TABLE "diagnosis_contexts" ADD COLUMN "diagnosis" text;
+-- Text that search covers: doctor notes and diagnoses
+CREATE TABLE IF NOT EXISTS "search_documents" ("id" bigserial PRIMARY KEY,"source" text,"source_id" bigint,"patient_id" bigint,"assessment_id" bigint,"body" text);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_search_documents_source" ON "search_documents"("source","source_id");
+CREATE INDEX IF NOT EXISTS "idx_search_documents_patient_id" ON "search_documents"("patient_id");
+-- The full-text index. SQLite keeps it in an FTS table instead, so this
+-- line has no twin there.
+CREATE INDEX IF NOT EXISTS idx_search_documents_body ON search_documents USING GIN (to_tsvector('english', body));
@@ -0,0 +1,4 @@
+-- Vectors of approved doctor notes, so similar-case retrieval can compare
+-- what doctors wrote as well as vitals
+CREATE TABLE IF NOT EXISTS "note_embeddings" ("id" bigserial PRIMARY KEY,"created_at" timestamptz,"feedback_id" bigint,"model" text,"vector" bytea);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_note_embeddings_feedback_id" ON "note_embeddings"("feedback_id");
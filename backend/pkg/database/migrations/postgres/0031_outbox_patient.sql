@@ -0,0 +1,3 @@
+-- Which patient an outbox message is about, so erasure can remove it
+ALTER TABLE "outbox_messages" ADD COLUMN "patient_id" bigint;
+CREATE INDEX IF NOT EXISTS "idx_outbox_messages_patient_id" ON "outbox_messages"("patient_id");
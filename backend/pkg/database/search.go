@@ -0,0 +1,239 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+
+	"healthcare-backend/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SearchIndexTable is the SQLite FTS table indexing search_documents.body.
+// Postgres indexes the column itself, see migration 0027.
+const SearchIndexTable = "search_fts"
+
+// searchIndexSQL creates the FTS table over search_documents, as an
+// external-content table so the text is stored once, and the triggers
+// that keep it in step. FTS5 is preferred; go-sqlite3 only has it when
+// built with -tags sqlite_fts5, and always has FTS4.
+var searchIndexSQL = map[string][]string{
+	"fts5": {
+		`CREATE VIRTUAL TABLE search_fts USING fts5(body, content='search_documents', content_rowid='id', tokenize='porter unicode61')`,
+		`CREATE TRIGGER search_fts_insert AFTER INSERT ON search_documents BEGIN
+			INSERT INTO search_fts(rowid, body) VALUES (new.id, new.body);
+		END`,
+		`CREATE TRIGGER search_fts_delete AFTER DELETE ON search_documents BEGIN
+			INSERT INTO search_fts(search_fts, rowid, body) VALUES ('delete', old.id, old.body);
+		END`,
+		`CREATE TRIGGER search_fts_update AFTER UPDATE ON search_documents BEGIN
+			INSERT INTO search_fts(search_fts, rowid, body) VALUES ('delete', old.id, old.body);
+			INSERT INTO search_fts(rowid, body) VALUES (new.id, new.body);
+		END`,
+	},
+	"fts4": {
+		`CREATE VIRTUAL TABLE search_fts USING fts4(content='search_documents', body, tokenize=porter)`,
+		`CREATE TRIGGER search_fts_insert AFTER INSERT ON search_documents BEGIN
+			INSERT INTO search_fts(docid, body) VALUES (new.id, new.body);
+		END`,
+		`CREATE TRIGGER search_fts_delete BEFORE DELETE ON search_documents BEGIN
+			DELETE FROM search_fts WHERE docid = old.id;
+		END`,
+		`CREATE TRIGGER search_fts_update_before BEFORE UPDATE ON search_documents BEGIN
+			DELETE FROM search_fts WHERE docid = old.id;
+		END`,
+		`CREATE TRIGGER search_fts_update_after AFTER UPDATE ON search_documents BEGIN
+			INSERT INTO search_fts(docid, body) VALUES (new.id, new.body);
+		END`,
+	},
+}
+
+// SearchIndexModule reports which FTS module the SQLite search index uses,
+// "" when there is none yet
+func SearchIndexModule(db *gorm.DB) (string, error) {
+	var rows []string
+	err := db.Raw("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", SearchIndexTable).Scan(&rows).Error
+	if err != nil || len(rows) == 0 {
+		return "", err
+	}
+	if strings.Contains(strings.ToLower(rows[0]), "using fts5") {
+		return "fts5", nil
+	}
+	return "fts4", nil
+}
+
+// EnsureSearchIndex creates the SQLite full-text index over the search
+// documents if it's missing and fills it from the rows already there.
+// Postgres needs nothing here.
+func EnsureSearchIndex(db *gorm.DB) error {
+	if db.Dialector.Name() == DriverPostgres {
+		return nil
+	}
+	if module, err := SearchIndexModule(db); err != nil || module != "" {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		module := "fts5"
+		if err := tx.Exec(searchIndexSQL[module][0]).Error; err != nil {
+			if !strings.Contains(err.Error(), "no such module") {
+				return err
+			}
+			module = "fts4"
+			if err := tx.Exec(searchIndexSQL[module][0]).Error; err != nil {
+				return err
+			}
+		}
+		for _, stmt := range searchIndexSQL[module][1:] {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Exec("INSERT INTO search_fts(search_fts) VALUES ('rebuild')").Error
+	})
+}
+
+// searchable is a model whose text is copied into search_documents
+type searchable interface {
+	SearchDocument() models.SearchDocument
+}
+
+// searchDocuments loads the rows q selects as search documents
+func searchDocuments[T searchable](q *gorm.DB) ([]models.SearchDocument, error) {
+	var rows []T
+	if err := q.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	docs := make([]models.SearchDocument, len(rows))
+	for i, r := range rows {
+		docs[i] = r.SearchDocument()
+	}
+	return docs, nil
+}
+
+// RegisterSearchHooks keeps search_documents in step with the rows it
+// copies: feedback and diagnosis contexts saved or deleted through db are
+// reindexed in the same transaction, so a failed index write fails the
+// save. Updates that name no row by primary key, e.g. bulk redaction, are
+// not seen and must fix the index themselves.
+func RegisterSearchHooks(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().After("gorm:create").Register("search:index_create", indexSearchable); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("search:index_update", indexSearchable); err != nil {
+		return err
+	}
+	return cb.Delete().After("gorm:delete").Register("search:index_delete", unindexSearchable)
+}
+
+// searchRowIDs returns the source and primary keys of the searchable rows
+// the statement wrote
+func searchRowIDs(tx *gorm.DB) (string, []uint) {
+	if tx.Error != nil || tx.Statement.Schema == nil || tx.Statement.Schema.PrioritizedPrimaryField == nil {
+		return "", nil
+	}
+	var source string
+	switch tx.Statement.Schema.ModelType {
+	case reflect.TypeOf(models.Feedback{}):
+		source = models.SearchFeedback
+	case reflect.TypeOf(models.DiagnosisContext{}):
+		source = models.SearchDiagnosis
+	default:
+		return "", nil
+	}
+
+	var ids []uint
+	pk := tx.Statement.Schema.PrioritizedPrimaryField
+	add := func(rv reflect.Value) {
+		if v, zero := pk.ValueOf(tx.Statement.Context, reflect.Indirect(rv)); !zero {
+			if id, ok := v.(uint); ok {
+				ids = append(ids, id)
+			}
+		}
+	}
+	switch rv := reflect.Indirect(tx.Statement.ReflectValue); rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			add(rv.Index(i))
+		}
+	case reflect.Struct:
+		add(rv)
+	}
+	return source, ids
+}
+
+// indexSearchable rereads the rows just written, so partial updates index
+// the whole row, and upserts their documents
+func indexSearchable(tx *gorm.DB) {
+	source, ids := searchRowIDs(tx)
+	if len(ids) == 0 {
+		return
+	}
+	db := tx.Session(&gorm.Session{NewDB: true})
+	var docs []models.SearchDocument
+	var err error
+	if source == models.SearchFeedback {
+		docs, err = searchDocuments[models.Feedback](db.Where("id IN ?", ids))
+	} else {
+		docs, err = searchDocuments[models.DiagnosisContext](db.Where("id IN ?", ids))
+	}
+	if err != nil {
+		tx.AddError(err)
+		return
+	}
+	for _, doc := range docs {
+		if err := saveSearchDocument(db, doc); err != nil {
+			tx.AddError(err)
+			return
+		}
+	}
+}
+
+func unindexSearchable(tx *gorm.DB) {
+	source, ids := searchRowIDs(tx)
+	if len(ids) == 0 {
+		return
+	}
+	err := tx.Session(&gorm.Session{NewDB: true}).
+		Where("source = ? AND source_id IN ?", source, ids).Delete(&models.SearchDocument{}).Error
+	if err != nil {
+		tx.AddError(err)
+	}
+}
+
+// saveSearchDocument writes doc over any earlier copy of its source row.
+// Blank text leaves nothing to find, so it removes the document.
+func saveSearchDocument(db *gorm.DB, doc models.SearchDocument) error {
+	if strings.TrimSpace(doc.Body) == "" {
+		return db.Where("source = ? AND source_id = ?", doc.Source, doc.SourceID).Delete(&models.SearchDocument{}).Error
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "source"}, {Name: "source_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"patient_id", "assessment_id", "body"}),
+	}).Create(&doc).Error
+}
+
+// BackfillSearchDocuments indexes feedback and diagnoses saved before the
+// search index existed, or while its hooks weren't registered
+func BackfillSearchDocuments(db *gorm.DB) (int, error) {
+	indexed := func(source string) *gorm.DB {
+		return db.Model(&models.SearchDocument{}).Select("source_id").Where("source = ?", source)
+	}
+	notes, err := searchDocuments[models.Feedback](db.Where("doctor_notes <> '' AND id NOT IN (?)", indexed(models.SearchFeedback)))
+	if err != nil {
+		return 0, err
+	}
+	diagnoses, err := searchDocuments[models.DiagnosisContext](db.Where("diagnosis <> '' AND id NOT IN (?)", indexed(models.SearchDiagnosis)))
+	if err != nil {
+		return 0, err
+	}
+
+	docs := append(notes, diagnoses...)
+	for i, doc := range docs {
+		if err := saveSearchDocument(db, doc); err != nil {
+			return i, err
+		}
+	}
+	return len(docs), nil
+}
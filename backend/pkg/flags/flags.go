@@ -0,0 +1,211 @@
+// Package flags gates experimental routes behind feature flags that can be
+// flipped, or rolled out to a percentage of callers, without a redeploy.
+// Flags live in the config_overrides table; evaluation reads an immutable
+// snapshot, so checking a flag costs one atomic load and a hash.
+package flags
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Flag states
+const (
+	On         = "on"
+	Off        = "off"
+	Percentage = "percentage"
+)
+
+// keyPrefix namespaces flags among the other config overrides
+const keyPrefix = "flag."
+
+// ClinicHeader names the clinic (tenant) a request acts for
+const ClinicHeader = "X-Clinic-ID"
+
+// LocalsKey is where Middleware stores the Store for Enabled
+const LocalsKey = "flags"
+
+var ErrUnknownFlag = errors.New("unknown feature flag")
+
+// Flag is the stored state of one feature flag
+type Flag struct {
+	Name    string   `json:"name"`
+	State   string   `json:"state"`             // on, off or percentage
+	Percent int      `json:"percent,omitempty"` // Share of callers (0-100) in percentage state
+	Clinics []string `json:"clinics,omitempty"` // Clinics that always get the feature
+}
+
+// Validate checks the state and percentage
+func (f Flag) Validate() error {
+	switch f.State {
+	case On, Off:
+	case Percentage:
+		if f.Percent < 0 || f.Percent > 100 {
+			return fmt.Errorf("percent must be between 0 and 100, got %d", f.Percent)
+		}
+	default:
+		return fmt.Errorf("state must be on, off or percentage, got %q", f.State)
+	}
+	return nil
+}
+
+// Enabled decides the flag for a caller. Bucketing hashes the flag name with
+// the subject, so a caller keeps its answer as the percentage grows and
+// different flags roll out to different callers.
+func (f Flag) Enabled(clinic, subject string) bool {
+	if clinic != "" && slices.Contains(f.Clinics, clinic) {
+		return true
+	}
+	switch f.State {
+	case On:
+		return true
+	case Percentage:
+		return Bucket(f.Name, subject) < f.Percent
+	}
+	return false
+}
+
+// Bucket maps a subject to 0-99 for a flag
+func Bucket(name, subject string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name + ":" + subject))
+	return int(h.Sum32() % 100)
+}
+
+// Store holds flag state. Known flags have code defaults that apply until
+// an override is saved.
+type Store struct {
+	DB       *gorm.DB
+	defaults map[string]Flag
+	snapshot atomic.Pointer[map[string]Flag]
+}
+
+// NewStore registers the known flags and their defaults; call Refresh to load overrides
+func NewStore(db *gorm.DB, defaults ...Flag) *Store {
+	s := &Store{DB: db, defaults: map[string]Flag{}}
+	for _, f := range defaults {
+		s.defaults[f.Name] = f
+	}
+	snap := make(map[string]Flag, len(s.defaults))
+	for name, f := range s.defaults {
+		snap[name] = f
+	}
+	s.snapshot.Store(&snap)
+	return s
+}
+
+// Refresh reloads overrides from the database, picking up changes made by other replicas
+func (s *Store) Refresh() error {
+	var rows []models.ConfigOverride
+	if err := s.DB.Where(clause.Like{Column: clause.Column{Name: "key"}, Value: keyPrefix + "%"}).Find(&rows).Error; err != nil {
+		return err
+	}
+	snap := make(map[string]Flag, len(s.defaults))
+	for name, f := range s.defaults {
+		snap[name] = f
+	}
+	for _, row := range rows {
+		name := strings.TrimPrefix(row.Key, keyPrefix)
+		if _, known := s.defaults[name]; !known {
+			continue // Flag removed from code
+		}
+		var f Flag
+		if err := json.Unmarshal([]byte(row.Value), &f); err != nil || f.Validate() != nil {
+			continue // Keep the default rather than serve a corrupt value
+		}
+		f.Name = name
+		snap[name] = f
+	}
+	s.snapshot.Store(&snap)
+	return nil
+}
+
+// Get returns a flag's current state
+func (s *Store) Get(name string) (Flag, bool) {
+	f, ok := (*s.snapshot.Load())[name]
+	return f, ok
+}
+
+// List returns every known flag, sorted by name
+func (s *Store) List() []Flag {
+	snap := *s.snapshot.Load()
+	out := make([]Flag, 0, len(snap))
+	for _, f := range snap {
+		out = append(out, f)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Set saves a flag and applies it at once in this process. It returns the previous state.
+func (s *Store) Set(f Flag, actor auditctx.Identity) (Flag, error) {
+	previous, ok := s.Get(f.Name)
+	if !ok {
+		return Flag{}, ErrUnknownFlag
+	}
+	if err := f.Validate(); err != nil {
+		return Flag{}, err
+	}
+	value, err := json.Marshal(f)
+	if err != nil {
+		return Flag{}, err
+	}
+	row := models.ConfigOverride{Key: keyPrefix + f.Name, Value: string(value), UpdatedAt: time.Now().UTC(), UpdatedBy: actor.ID}
+	if err := s.DB.Save(&row).Error; err != nil {
+		return Flag{}, err
+	}
+	return previous, s.Refresh()
+}
+
+// IsEnabled evaluates a flag; unknown flags are off
+func (s *Store) IsEnabled(name, clinic, subject string) bool {
+	f, ok := s.Get(name)
+	return ok && f.Enabled(clinic, subject)
+}
+
+// Middleware makes the store available to Enabled and Require
+func Middleware(s *Store) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals(LocalsKey, s)
+		return c.Next()
+	}
+}
+
+// Enabled evaluates a flag for the request. Percentage rollouts bucket by
+// clinic when the request names one, else by the resolved actor.
+func Enabled(c *fiber.Ctx, name string) bool {
+	s, ok := c.Locals(LocalsKey).(*Store)
+	if !ok {
+		return false
+	}
+	clinic := c.Get(ClinicHeader)
+	subject := clinic
+	if subject == "" {
+		subject = auditctx.Actor(c).ID
+	}
+	return s.IsEnabled(name, clinic, subject)
+}
+
+// Require answers 404 for routes behind a disabled flag, as if they did not exist
+func Require(name string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !Enabled(c, name) {
+			return fiber.ErrNotFound
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/logging"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AuditReads records each successful read of the route as an eventType
+// access event. The :id param is taken as the patient; routes without one
+// record patient 0. Events are buffered, so reads don't wait on the insert.
+func AuditReads(audit *services.AuditService, eventType string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			return err
+		}
+		if status := c.Response().StatusCode(); status < 200 || status >= 300 {
+			return nil
+		}
+		id, _ := c.ParamsInt("id")
+		if id < 0 {
+			return nil
+		}
+		if err := audit.LogAccess(eventType, uint(id), c.Route().Path, auditctx.Actor(c)); err != nil {
+			logging.Request(c).Warn("audit event failed", "event", eventType, "error", err)
+		}
+		return nil
+	}
+}
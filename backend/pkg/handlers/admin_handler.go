@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"bytes"
+	"time"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/logging"
+	"healthcare-backend/pkg/privacy"
+	"healthcare-backend/pkg/services"
+	"healthcare-backend/pkg/terminology"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AdminHandler struct {
+	Redactor   *privacy.Redactor
+	Drift      *services.DriftService
+	Prediction *services.PredictionService
+	Audit      *services.AuditService
+	Terms      *terminology.Mapper
+}
+
+func NewAdminHandler(redactor *privacy.Redactor, drift *services.DriftService, pred *services.PredictionService, audit *services.AuditService, terms *terminology.Mapper) *AdminHandler {
+	return &AdminHandler{Redactor: redactor, Drift: drift, Prediction: pred, Audit: audit, Terms: terms}
+}
+
+// GetPrivacyMode returns the active PHI redaction mode
+// GET /api/admin/privacy/mode
+func (h *AdminHandler) GetPrivacyMode(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"mode": h.Redactor.Mode()})
+}
+
+// SetPrivacyMode switches between redacting PHI and blocking context that contains it
+// PUT /api/admin/privacy/mode
+func (h *AdminHandler) SetPrivacyMode(c *fiber.Ctx) error {
+	var req struct {
+		Mode string `json:"mode"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	mode, err := privacy.ParseMode(req.Mode)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	previous := h.Redactor.Mode()
+	h.Redactor.SetMode(mode)
+
+	if _, err := h.Audit.LogEvent("PRIVACY_MODE_CHANGED", 0, fiber.Map{"from": previous, "to": mode}, auditctx.Actor(c)); err != nil {
+		logging.Request(c).Warn("audit event failed", "error", err)
+	}
+
+	return c.JSON(fiber.Map{"mode": mode, "previous": previous})
+}
+
+// GetModelDrift returns weekly per-model confidence and fallback share plus active drift alerts
+// GET /api/admin/model-drift?weeks=12
+func (h *AdminHandler) GetModelDrift(c *fiber.Ctx) error {
+	weeks := c.QueryInt("weeks", 12)
+	if weeks < 1 || weeks > 104 {
+		return c.Status(400).JSON(fiber.Map{"error": "weeks must be between 1 and 104"})
+	}
+
+	now := time.Now()
+	series, err := h.Drift.WeeklySeries(now.AddDate(0, 0, -7*weeks))
+	if err != nil {
+		return err
+	}
+	alerts, err := h.Drift.CheckDrift(now)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"weeks":  weeks,
+		"series": series,
+		"alerts": alerts,
+		"delta":  h.Drift.Delta,
+	})
+}
+
+// GetCanary returns the ML canary rollout state with per-backend metrics
+// GET /api/admin/ml/canary
+func (h *AdminHandler) GetCanary(c *fiber.Ctx) error {
+	canaryURL := ""
+	if h.Prediction.Canary != nil {
+		canaryURL = h.Prediction.Canary.URL
+	}
+	return c.JSON(fiber.Map{
+		"enabled":    h.Prediction.Canary != nil,
+		"canary_url": canaryURL,
+		"percent":    h.Prediction.CanaryPercent(),
+		"backends":   h.Prediction.BackendMetrics(),
+	})
+}
+
+// SetCanaryPercent adjusts the share of patients routed to the canary
+// PUT /api/admin/ml/canary
+func (h *AdminHandler) SetCanaryPercent(c *fiber.Ctx) error {
+	var req struct {
+		Percent *int `json:"percent"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.Percent == nil {
+		return c.Status(400).JSON(fiber.Map{"error": "percent is required"})
+	}
+	if h.Prediction.Canary == nil {
+		return c.Status(409).JSON(fiber.Map{"error": "No canary backend configured (set ML_CANARY_URL)"})
+	}
+
+	previous := h.Prediction.CanaryPercent()
+	if err := h.Prediction.SetCanaryPercent(*req.Percent); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if _, err := h.Audit.LogEvent("ML_CANARY_CHANGED", 0, fiber.Map{"from": previous, "to": *req.Percent}, auditctx.Actor(c)); err != nil {
+		logging.Request(c).Warn("audit event failed", "error", err)
+	}
+
+	return c.JSON(fiber.Map{"percent": *req.Percent, "previous": previous})
+}
+
+// GetShadowReport summarizes primary vs shadow model agreement
+// GET /api/admin/shadow/report?days=7
+func (h *AdminHandler) GetShadowReport(c *fiber.Ctx) error {
+	if h.Prediction.Shadow == nil {
+		return c.JSON(fiber.Map{"enabled": false})
+	}
+
+	days := c.QueryInt("days", 7)
+	if days < 1 || days > 90 {
+		return c.Status(400).JSON(fiber.Map{"error": "days must be between 1 and 90"})
+	}
+
+	report, err := h.Prediction.Shadow.Report(time.Now().AddDate(0, 0, -days), 10)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"enabled":    true,
+		"shadow_url": h.Prediction.Shadow.URL,
+		"queue":      h.Prediction.Shadow.Runner.Stats(),
+		"report":     report,
+	})
+}
+
+// GetSymptomTerminology reports the size of the symptom -> SNOMED table
+// GET /api/admin/terminology/symptoms
+func (h *AdminHandler) GetSymptomTerminology(c *fiber.Ctx) error {
+	terms, concepts := h.Terms.Stats()
+	return c.JSON(fiber.Map{"system": terminology.SNOMEDSystem, "terms": terms, "concepts": concepts})
+}
+
+// UploadSymptomTerminology merges a CSV (code,display,synonyms) into the symptom table
+// POST /api/admin/terminology/symptoms
+func (h *AdminHandler) UploadSymptomTerminology(c *fiber.Ctx) error {
+	if len(c.Body()) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "CSV body is required"})
+	}
+
+	loaded, err := h.Terms.Load(bytes.NewReader(c.Body()))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if _, err := h.Audit.LogEvent("TERMINOLOGY_UPDATED", 0, fiber.Map{"concepts": loaded}, auditctx.Actor(c)); err != nil {
+		logging.Request(c).Warn("audit event failed", "error", err)
+	}
+
+	terms, concepts := h.Terms.Stats()
+	return c.JSON(fiber.Map{"loaded": loaded, "terms": terms, "concepts": concepts})
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/privacy/dp"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AnalyticsHandler struct {
+	Cohorts        *services.CohortService
+	Budget         *services.PrivacyBudgetService
+	DP             *dp.Mechanism
+	DefaultEpsilon float64
+}
+
+func NewAnalyticsHandler(cohorts *services.CohortService, budget *services.PrivacyBudgetService, mech *dp.Mechanism, defaultEpsilon float64) *AnalyticsHandler {
+	return &AnalyticsHandler{Cohorts: cohorts, Budget: budget, DP: mech, DefaultEpsilon: defaultEpsilon}
+}
+
+// GetCohort returns the size and mean vitals of a patient cohort. With
+// dp=true the answer carries Laplace noise and spends epsilon from the
+// caller's monthly privacy budget.
+// GET /api/analytics/cohort?min_age=40&max_age=65&gender=Female&smoking=Yes&dp=true&epsilon=0.5
+func (h *AnalyticsHandler) GetCohort(c *fiber.Ctx) error {
+	filter := services.CohortFilter{
+		MinAge:  c.QueryInt("min_age", 0),
+		MaxAge:  c.QueryInt("max_age", 0),
+		Gender:  c.Query("gender"),
+		Smoking: c.Query("smoking"),
+	}
+	if filter.MinAge < 0 || (filter.MaxAge > 0 && filter.MaxAge < filter.MinAge) {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid age range"})
+	}
+
+	if !c.QueryBool("dp", false) {
+		stats, err := h.Cohorts.Exact(filter)
+		if err != nil {
+			return err
+		}
+		return c.JSON(stats)
+	}
+
+	epsilon := c.QueryFloat("epsilon", h.DefaultEpsilon)
+	if epsilon <= 0 || epsilon > h.Budget.Limit {
+		return c.Status(400).JSON(fiber.Map{"error": "epsilon must be positive and within the period budget", "budget": h.Budget.Limit})
+	}
+	actor := auditctx.Actor(c)
+	if actor.Role == auditctx.RoleAnonymous {
+		return c.Status(401).JSON(fiber.Map{"error": "Differentially private queries need an API key to track the privacy budget"})
+	}
+
+	now := time.Now()
+	remaining, err := h.Budget.Spend(actor.ID, epsilon, now)
+	if errors.Is(err, services.ErrPrivacyBudgetExhausted) {
+		return c.Status(429).JSON(fiber.Map{
+			"error":            err.Error(),
+			"period":           h.Budget.Period(now),
+			"budget_remaining": remaining,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	stats, err := h.Cohorts.Private(filter, h.DP, epsilon)
+	if err != nil {
+		return err
+	}
+	stats.Privacy.Period = h.Budget.Period(now)
+	stats.Privacy.BudgetRemaining = remaining
+	return c.JSON(stats)
+}
@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/logging"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// lateComponentTimeout bounds a component that missed the request budget
+// and is left to finish in the background
+const lateComponentTimeout = 30 * time.Second
+
+var budgetedComponents = []string{models.ComponentRisks, models.ComponentUrgency, models.ComponentMedications}
+
+// componentResult is one component's outcome
+type componentResult struct {
+	value   any
+	err     error
+	latency time.Duration
+}
+
+// assessmentRun holds what an assessment produced within its budget
+type assessmentRun struct {
+	risks   *models.PredictResponse
+	urgency *models.UrgencyResponse
+	meds    models.InteractionResult
+
+	done    map[string]componentResult
+	pending map[string]<-chan componentResult
+	report  *models.BudgetReport
+}
+
+// startComponent runs fn in the background under its own hard timeout,
+// keeping parent's values but not its cancellation
+func startComponent(parent context.Context, fn func(ctx context.Context) (any, error)) <-chan componentResult {
+	ch := make(chan componentResult, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(parent), lateComponentTimeout)
+		defer cancel()
+		start := time.Now()
+		v, err := fn(ctx)
+		ch <- componentResult{value: v, err: err, latency: time.Since(start)}
+	}()
+	return ch
+}
+
+// runComponents computes risks, urgency and medication analysis. With a
+// budget they run concurrently and anything unfinished at the deadline is
+// handed back as pending; without one they run inline as before.
+func (h *PatientHandler) runComponents(ctx context.Context, patient models.PatientData, symptoms []string, start time.Time) *assessmentRun {
+	run := &assessmentRun{done: map[string]componentResult{}, pending: map[string]<-chan componentResult{}}
+
+	if h.Budget <= 0 || h.Completions == nil {
+		run.risks, _ = h.Prediction.PredictRisksCtx(ctx, patient)
+		var err error
+		if run.urgency, err = h.Prediction.PredictUrgencyCtx(ctx, symptoms, patient); err != nil {
+			run.urgency = services.DegradedUrgency()
+		}
+		run.meds = h.checkMedications(patient)
+		return run
+	}
+
+	calls := map[string]<-chan componentResult{
+		models.ComponentRisks: startComponent(ctx, func(ctx context.Context) (any, error) {
+			return h.Prediction.PredictRisksCtx(ctx, patient)
+		}),
+		models.ComponentUrgency: startComponent(ctx, func(ctx context.Context) (any, error) {
+			return h.Prediction.PredictUrgencyCtx(ctx, symptoms, patient)
+		}),
+		models.ComponentMedications: startComponent(ctx, func(ctx context.Context) (any, error) {
+			return h.checkMedications(patient), nil
+		}),
+	}
+
+	budget, cancel := context.WithDeadline(context.Background(), start.Add(h.Budget))
+	defer cancel()
+
+	run.report = &models.BudgetReport{BudgetMs: h.Budget.Milliseconds(), Completed: []string{}, Pending: []string{}}
+	for _, name := range budgetedComponents {
+		if r, ok := awaitComponent(budget, calls[name]); ok {
+			run.done[name] = r
+			run.report.Completed = append(run.report.Completed, name)
+			run.apply(name, r)
+		} else {
+			run.pending[name] = calls[name]
+			run.report.Pending = append(run.report.Pending, name)
+		}
+	}
+	run.report.ElapsedMs = time.Since(start).Milliseconds()
+
+	if len(run.pending) > 0 {
+		logging.FromContext(ctx).Info("latency budget exceeded", "budget_ms", h.Budget.Milliseconds(), "pending", run.report.Pending)
+	}
+	return run
+}
+
+// checkMedications is the medications component: interactions plus the
+// cross-check against recorded allergies
+func (h *PatientHandler) checkMedications(patient models.PatientData) models.InteractionResult {
+	meds := h.Prediction.CheckMedications(patient.Medications)
+	meds.AllergyConflicts = h.Prediction.CheckAllergies(patient.Medications, patient.Allergies)
+	return meds
+}
+
+// awaitComponent waits for a result until the budget ends. A result that is
+// already there always wins, even once the deadline has passed.
+func awaitComponent(budget context.Context, ch <-chan componentResult) (componentResult, bool) {
+	select {
+	case r := <-ch:
+		return r, true
+	case <-budget.Done():
+	}
+	select {
+	case r := <-ch:
+		return r, true
+	default:
+		return componentResult{}, false
+	}
+}
+
+// apply stores a finished component's value on the run
+func (run *assessmentRun) apply(name string, r componentResult) {
+	if r.err != nil {
+		if name == models.ComponentUrgency {
+			run.urgency = services.DegradedUrgency()
+		}
+		return
+	}
+	switch name {
+	case models.ComponentRisks:
+		run.risks = r.value.(*models.PredictResponse)
+	case models.ComponentUrgency:
+		run.urgency = r.value.(*models.UrgencyResponse)
+	case models.ComponentMedications:
+		run.meds = r.value.(models.InteractionResult)
+	}
+}
+
+// newComponent builds the stored record for a component
+func newComponent(assessmentID uint, name string, r componentResult) models.AssessmentComponent {
+	c := models.AssessmentComponent{
+		AssessmentID: assessmentID,
+		Component:    name,
+		Status:       models.ComponentReady,
+		LatencyMs:    r.latency.Milliseconds(),
+		Result:       r.value,
+	}
+	if r.err != nil {
+		c.Status = models.ComponentError
+		c.Error = r.err.Error()
+		c.Result = nil
+	}
+	return c
+}
+
+// recordComponents stores every component's status and hands pending ones
+// to the completion job. onRisks runs once risks are known, now or later.
+func (h *PatientHandler) recordComponents(ctx context.Context, assessment *models.Assessment, patient models.PatientData, run *assessmentRun, onRisks func(*models.PredictResponse)) {
+	if run.report == nil {
+		onRisks(run.risks)
+		return
+	}
+
+	for _, name := range budgetedComponents {
+		if r, ok := run.done[name]; ok {
+			c := newComponent(assessment.ID, name, r)
+			if err := h.Assessments.SaveComponent(&c); err != nil {
+				logging.FromContext(ctx).Warn("failed to store component", "component", name, "assessment_id", assessment.ID, "error", err)
+			}
+			continue
+		}
+
+		c := models.AssessmentComponent{AssessmentID: assessment.ID, Component: name, Status: models.ComponentPending}
+		if err := h.Assessments.SaveComponent(&c); err != nil {
+			logging.FromContext(ctx).Warn("failed to store component", "component", name, "assessment_id", assessment.ID, "error", err)
+		}
+
+		ch := run.pending[name]
+		assessmentID := assessment.ID
+		if !h.Completions.Submit(func(jobCtx context.Context) {
+			select {
+			case r := <-ch:
+				h.completeComponent(ctx, assessmentID, patient, c, r, onRisks)
+			case <-jobCtx.Done():
+			}
+		}) {
+			c.Status = models.ComponentError
+			c.Error = "completion queue full"
+			if err := h.Assessments.SaveComponent(&c); err != nil {
+				logging.FromContext(ctx).Warn("failed to store component", "component", name, "assessment_id", assessment.ID, "error", err)
+			}
+		}
+	}
+
+	if run.risks != nil {
+		onRisks(run.risks)
+	}
+}
+
+// completeComponent stores a late result, folds it into the assessment and
+// pushes it to the patient's WebSocket subscribers
+func (h *PatientHandler) completeComponent(ctx context.Context, assessmentID uint, patient models.PatientData, c models.AssessmentComponent, r componentResult, onRisks func(*models.PredictResponse)) {
+	late := newComponent(assessmentID, c.Component, r)
+	late.ID = c.ID
+	late.CreatedAt = c.CreatedAt
+	late.Late = true
+
+	var escalated []string
+	var risks *models.PredictResponse
+	if late.Status == models.ComponentReady {
+		var err error
+		if escalated, risks, err = h.applyLate(assessmentID, c.Component, r.value); err != nil {
+			logging.FromContext(ctx).Warn("failed to apply late component", "component", c.Component, "assessment_id", assessmentID, "error", err)
+		}
+	}
+	if err := h.Assessments.SaveComponent(&late); err != nil {
+		logging.FromContext(ctx).Warn("failed to store late component", "component", c.Component, "assessment_id", assessmentID, "error", err)
+	}
+	logging.FromContext(ctx).Info("late component finished", "component", c.Component, "assessment_id", assessmentID, "status", late.Status, "duration_ms", r.latency.Milliseconds())
+
+	if _, err := h.Audit.LogEvent("AI_PREDICTION_COMPLETED", patient.ID, fiber.Map{
+		"assessment_id": assessmentID,
+		"component":     c.Component,
+		"status":        late.Status,
+		"result":        late.Result,
+	}, auditctx.System.InRequest(ctx)); err != nil {
+		logging.FromContext(ctx).Warn("audit event failed", "event", "AI_PREDICTION_COMPLETED", "error", err)
+	}
+
+	h.WS.BroadcastPatient(patient.ID, fiber.Map{
+		"type":          "assessment_component",
+		"patient_id":    patient.ID,
+		"assessment_id": assessmentID,
+		"component":     late,
+	})
+
+	if len(escalated) > 0 {
+		urgency, _ := r.value.(*models.UrgencyResponse)
+		h.notifyEmergency(ctx, patient, assessmentID, risks, escalated, urgency)
+	}
+	if risks, ok := r.value.(*models.PredictResponse); ok && late.Status == models.ComponentReady {
+		onRisks(risks)
+	}
+}
+
+// applyLate updates the stored assessment with a late component. If that
+// raised the emergency flag it returns the rules that fired and the stored
+// risks, nil while still pending. Emergency only ever escalates: a late
+// result can't clear an earlier flag.
+func (h *PatientHandler) applyLate(assessmentID uint, component string, value any) ([]string, *models.PredictResponse, error) {
+	h.lateMu.Lock()
+	defer h.lateMu.Unlock()
+
+	assessment, err := h.Assessments.GetByID(assessmentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	wasEmergency := assessment.Emergency
+
+	patient, err := assessment.Patient()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Only the late result is evaluated; the rest already had their chance
+	var fired []string
+	var risks *models.PredictResponse
+	switch v := value.(type) {
+	case *models.PredictResponse:
+		fired = h.emergencyReasons(patient, v, nil)
+		fillAssessment(assessment, assessment.PatientID, v, wasEmergency || len(fired) > 0, assessment.AuditHash)
+		assessment.SetExplanations(h.Prediction.Summarizer.Summarize(patient, *v))
+		risks = v
+	case *models.UrgencyResponse:
+		if fired = h.emergencyReasons(patient, nil, v); wasEmergency || len(fired) == 0 {
+			return nil, nil, nil
+		}
+		assessment.Emergency = true
+		if len(assessment.Precisions) > 0 { // Pending risks store none
+			stored := assessmentRisks(assessment)
+			risks = &stored
+		}
+	default:
+		return nil, nil, nil
+	}
+	if err := h.Assessments.Save(assessment); err != nil {
+		return nil, nil, err
+	}
+	if wasEmergency {
+		return nil, nil, nil
+	}
+	return fired, risks, nil
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+
+	"healthcare-backend/pkg/adapters"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/repositories"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// AssessmentHandler serves historical assessments. Everything here reads the
+// patient snapshot stored with the assessment, never the live patient row.
+type AssessmentHandler struct {
+	Assessments repositories.AssessmentRepository
+	FHIR        *adapters.FHIRAdapter
+}
+
+func NewAssessmentHandler(assessments repositories.AssessmentRepository) *AssessmentHandler {
+	return &AssessmentHandler{Assessments: assessments, FHIR: adapters.NewFHIRAdapter()}
+}
+
+// load fetches an assessment and its frozen patient data
+func (h *AssessmentHandler) load(c *fiber.Ctx, param string) (*models.Assessment, *models.PatientData, error) {
+	id, err := c.ParamsInt(param)
+	if err != nil || id <= 0 {
+		return nil, nil, fiber.NewError(400, "Invalid assessment ID")
+	}
+
+	assessment, err := h.Assessments.GetByID(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, fiber.NewError(404, "Assessment not found")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if assessment.PatientSnapshot == "" {
+		return nil, nil, fiber.NewError(409, "Assessment has no patient snapshot")
+	}
+
+	patient, err := assessment.Patient()
+	if err != nil {
+		return nil, nil, err
+	}
+	return assessment, &patient, nil
+}
+
+func assessmentRisks(a *models.Assessment) models.PredictResponse {
+	risks := models.PredictResponse{
+		HeartRisk:          a.HeartRisk,
+		DiabetesRisk:       a.DiabetesRisk,
+		StrokeRisk:         a.StrokeRisk,
+		KidneyRisk:         a.KidneyRisk,
+		GeneralHealthScore: a.GeneralHealthScore,
+		ClinicalConfidence: a.ClinicalConfidence,
+		ModelPrecisions:    map[string]float64{},
+		Backend:            a.MLBackend,
+		Source:             models.PredictionSourceML,
+	}
+	if a.RuleBased {
+		risks.Degraded = true
+		risks.Source = models.PredictionSourceRuleBased
+	}
+	for _, p := range a.Precisions {
+		risks.ModelPrecisions[p.ModelName] = p.Confidence
+	}
+	return risks
+}
+
+// GetAssessment returns the stored assessment and the status of each
+// component, including ones completed after the response was sent
+// GET /api/assessments/:id
+func (h *AssessmentHandler) GetAssessment(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return fiber.NewError(400, "Invalid assessment ID")
+	}
+
+	assessment, err := h.Assessments.GetByID(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fiber.NewError(404, "Assessment not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	components, err := h.Assessments.GetComponents(assessment.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"assessment": assessment,
+		"components": components,
+	})
+}
+
+// GetReport returns the assessment as it was produced, with FHIR resources
+// GET /api/assessments/:id/report
+func (h *AssessmentHandler) GetReport(c *fiber.Ctx) error {
+	assessment, patient, err := h.load(c, "id")
+	if err != nil {
+		return err
+	}
+
+	full := models.FullAssessmentResponse{
+		ID:           patient.ID,
+		AssessmentID: assessment.ID,
+		Risks:        assessmentRisks(assessment),
+		Emergency:    assessment.Emergency,
+		Patient:      *patient,
+		AuditHash:    assessment.AuditHash,
+
+		ExplanationSummaries: assessment.RiskExplanations(),
+	}
+
+	return c.JSON(fiber.Map{
+		"assessment":            assessment,
+		"patient":               patient,
+		"explanation_summaries": full.ExplanationSummaries,
+		"snapshot_hash":         assessment.SnapshotHash,
+		"snapshot_backfilled":   assessment.SnapshotBackfilled,
+		"fhir": fiber.Map{
+			"patient":           h.FHIR.ToFHIRPatient(*patient),
+			"diagnostic_report": h.FHIR.ToFHIRDiagnosticReport(full),
+		},
+	})
+}
+
+// GetPatientExplanations returns the factors behind the patient's latest
+// assessment, largest absolute contribution first for each model. Rule-based
+// assessments report the fallback thresholds that fired.
+// GET /api/patients/:id/explanations
+func (h *AssessmentHandler) GetPatientExplanations(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return fiber.NewError(400, "Invalid patient ID")
+	}
+
+	assessment, err := h.Assessments.GetLatestForPatient(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fiber.NewError(404, "Patient has no assessments")
+	}
+	if err != nil {
+		return err
+	}
+	if assessment.PatientSnapshot == "" {
+		return fiber.NewError(409, "Assessment has no patient snapshot")
+	}
+	patient, err := assessment.Patient()
+	if err != nil {
+		return err
+	}
+
+	factors := []models.ModelFactors{}
+	if assessment.RuleBased || len(assessment.Precisions) > 0 { // Otherwise the risks are still pending
+		risks := assessmentRisks(assessment)
+		risks.Explanations = assessment.FeatureContributions()
+		factors = services.RankFactors(patient, risks)
+	}
+	return c.JSON(fiber.Map{
+		"patient_id":    id,
+		"assessment_id": assessment.ID,
+		"created_at":    assessment.CreatedAt,
+		"rule_based":    assessment.RuleBased,
+		"explanations":  factors,
+	})
+}
+
+// VerifySnapshot checks that the stored snapshot still matches its recorded hash
+// GET /api/assessments/:id/verify
+func (h *AssessmentHandler) VerifySnapshot(c *fiber.Ctx) error {
+	assessment, _, err := h.load(c, "id")
+	if err != nil {
+		return err
+	}
+
+	computed := models.SnapshotHash(assessment.PatientSnapshot)
+	return c.JSON(fiber.Map{
+		"assessment_id":       assessment.ID,
+		"valid":               computed == assessment.SnapshotHash,
+		"snapshot_hash":       assessment.SnapshotHash,
+		"computed_hash":       computed,
+		"snapshot_backfilled": assessment.SnapshotBackfilled,
+		"audit_hash":          assessment.AuditHash,
+	})
+}
+
+// Compare lists input and risk differences between two assessments
+// GET /api/assessments/:id/compare/:other
+func (h *AssessmentHandler) Compare(c *fiber.Ctx) error {
+	a, patientA, err := h.load(c, "id")
+	if err != nil {
+		return err
+	}
+	b, patientB, err := h.load(c, "other")
+	if err != nil {
+		return err
+	}
+
+	// Diff the intake view so imputed measurements show as never provided
+	changes, err := diffFields(models.IntakeOf(*patientA), models.IntakeOf(*patientB))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"from":           a.ID,
+		"to":             b.ID,
+		"patient_inputs": changes,
+		"risk_delta": fiber.Map{
+			"heart_risk_score":    b.HeartRisk - a.HeartRisk,
+			"diabetes_risk_score": b.DiabetesRisk - a.DiabetesRisk,
+			"stroke_risk_score":   b.StrokeRisk - a.StrokeRisk,
+			"kidney_risk_score":   b.KidneyRisk - a.KidneyRisk,
+		},
+	})
+}
+
+// FieldChange is one differing JSON field between two snapshots
+type FieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+func diffFields(a, b any, ignore ...string) ([]FieldChange, error) {
+	toMap := func(v any) (map[string]any, error) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		m := map[string]any{}
+		return m, json.Unmarshal(data, &m)
+	}
+	ma, err := toMap(a)
+	if err != nil {
+		return nil, err
+	}
+	mb, err := toMap(b)
+	if err != nil {
+		return nil, err
+	}
+
+	skip := map[string]bool{}
+	for _, f := range ignore {
+		skip[f] = true
+	}
+	keys := map[string]bool{}
+	for k := range ma {
+		keys[k] = true
+	}
+	for k := range mb {
+		keys[k] = true
+	}
+
+	changes := []FieldChange{}
+	for k := range keys {
+		if !skip[k] && !reflect.DeepEqual(ma[k], mb[k]) {
+			changes = append(changes, FieldChange{Field: k, From: ma[k], To: mb[k]})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes, nil
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/logging"
+	"healthcare-backend/pkg/middleware"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Token lifetimes used when the handler's are zero. Access tokens are short
+// because only logout can revoke them early; refresh tokens renew them.
+const (
+	DefaultTokenTTL   = 15 * time.Minute
+	DefaultRefreshTTL = 30 * 24 * time.Hour
+)
+
+// AuthHandler signs users in for the bearer JWTs ResolveActor checks
+type AuthHandler struct {
+	Users       *services.UserService
+	Audit       *services.AuditService
+	Revocations *services.TokenRevocations // Access tokens revoked on logout
+	Secret      []byte
+	TokenTTL    time.Duration
+	RefreshTTL  time.Duration
+}
+
+func NewAuthHandler(users *services.UserService, audit *services.AuditService, revocations *services.TokenRevocations, secret []byte) *AuthHandler {
+	return &AuthHandler{Users: users, Audit: audit, Revocations: revocations, Secret: secret, TokenTTL: DefaultTokenTTL, RefreshTTL: DefaultRefreshTTL}
+}
+
+type loginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type registerRequest struct {
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+func (h *AuthHandler) audit(c *fiber.Ctx, event string, payload fiber.Map, actor auditctx.Identity) {
+	if _, err := h.Audit.LogEvent(event, 0, payload, actor.InRequest(c.UserContext())); err != nil {
+		logging.Request(c).Warn("audit event failed", "event", event, "error", err)
+	}
+}
+
+// Login exchanges an email and password for a JWT
+// POST /api/auth/login
+func (h *AuthHandler) Login(c *fiber.Ctx) error {
+	if len(h.Secret) == 0 {
+		return c.Status(503).JSON(fiber.Map{"error": "Login is disabled: JWT_SECRET is not set"})
+	}
+	var req loginRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	user, err := h.Users.Authenticate(req.Email, req.Password)
+	if errors.Is(err, services.ErrInvalidLogin) {
+		h.audit(c, "LOGIN_FAILED", fiber.Map{"email": req.Email}, auditctx.Anonymous)
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid email or password"})
+	}
+	if err != nil {
+		return err
+	}
+
+	h.audit(c, "LOGIN", fiber.Map{"email": user.Email}, services.UserIdentity(user))
+	refresh, issued, err := h.Users.IssueRefreshToken(user, h.RefreshTTL)
+	if err != nil {
+		return err
+	}
+	return h.sendTokens(c, user, refresh, issued)
+}
+
+// sendTokens answers with a fresh access token alongside the refresh token
+func (h *AuthHandler) sendTokens(c *fiber.Ctx, user *models.User, refresh string, issued *models.RefreshToken) error {
+	now := time.Now()
+	token, err := middleware.IssueJWT(services.UserIdentity(user), h.Secret, h.TokenTTL, now)
+	if err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{
+		"token":              token,
+		"token_type":         "Bearer",
+		"expires_at":         now.Add(h.TokenTTL).UTC(),
+		"refresh_token":      refresh,
+		"refresh_expires_at": issued.ExpiresAt,
+		"user":               user,
+	})
+}
+
+// Refresh exchanges a refresh token for a new access token and a new
+// refresh token; the old one stops working
+// POST /api/auth/refresh
+func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
+	if len(h.Secret) == 0 {
+		return c.Status(503).JSON(fiber.Map{"error": "Login is disabled: JWT_SECRET is not set"})
+	}
+	var req refreshRequest
+	if err := c.BodyParser(&req); err != nil || req.RefreshToken == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "refresh_token is required"})
+	}
+
+	user, refresh, issued, err := h.Users.Refresh(req.RefreshToken, h.RefreshTTL)
+	if errors.Is(err, services.ErrInvalidRefreshToken) {
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid or expired refresh token"})
+	}
+	if err != nil {
+		return err
+	}
+	return h.sendTokens(c, user, refresh, issued)
+}
+
+// Logout revokes the refresh token in the body and the bearer access token
+// the request carries, if any
+// POST /api/auth/logout
+func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	var req refreshRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+	}
+	if req.RefreshToken != "" {
+		if err := h.Users.RevokeRefreshToken(req.RefreshToken); err != nil {
+			return err
+		}
+	}
+	if token, ok := middleware.RequestToken(c); ok && h.Revocations != nil {
+		h.Revocations.Revoke(token.ID, token.ExpiresAt)
+	}
+	h.audit(c, "LOGOUT", fiber.Map{}, auditctx.Actor(c))
+	return c.SendStatus(204)
+}
+
+// Register creates an account. Admin only: accounts carry roles, so
+// self-registration would let anyone become a doctor.
+// POST /api/auth/register
+func (h *AuthHandler) Register(c *fiber.Ctx) error {
+	var req registerRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	user, err := h.Users.Register(req.Email, req.Name, req.Password, req.Role)
+	switch {
+	case errors.Is(err, services.ErrInvalidUser):
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrUserExists):
+		return c.Status(409).JSON(fiber.Map{"error": "A user with that email already exists"})
+	case err != nil:
+		return err
+	}
+	h.audit(c, "USER_REGISTERED", fiber.Map{"user_id": user.ID, "email": user.Email, "role": user.Role}, auditctx.Actor(c))
+	return c.Status(201).JSON(user)
+}
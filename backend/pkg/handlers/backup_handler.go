@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/logging"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type BackupHandler struct {
+	Backups *services.BackupService
+	Audit   *services.AuditService
+}
+
+func NewBackupHandler(backups *services.BackupService, audit *services.AuditService) *BackupHandler {
+	return &BackupHandler{Backups: backups, Audit: audit}
+}
+
+// backupError maps service errors onto HTTP statuses
+func backupError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrBackupUnsupported):
+		return c.Status(501).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrBackupTooLarge):
+		return c.Status(413).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrBackupNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	return err
+}
+
+func (h *BackupHandler) audit(c *fiber.Ctx, event string, payload any) {
+	if _, err := h.Audit.LogEvent(event, 0, payload, auditctx.Actor(c)); err != nil {
+		logging.Request(c).Warn("audit event failed", "error", err)
+	}
+}
+
+// CreateBackup takes an online backup of the SQLite database
+// POST /api/admin/db/backup
+func (h *BackupHandler) CreateBackup(c *fiber.Ctx) error {
+	backup, err := h.Backups.Backup()
+	if err != nil {
+		return backupError(c, err)
+	}
+
+	logging.Request(c).Info("database backup written", "file", backup.Filename, "bytes", backup.SizeBytes)
+	h.audit(c, "DB_BACKUP_CREATED", backup)
+	return c.Status(201).JSON(backup)
+}
+
+// ListBackups returns available backups, newest first
+// GET /api/admin/db/backups
+func (h *BackupHandler) ListBackups(c *fiber.Ctx) error {
+	backups, err := h.Backups.List()
+	if err != nil {
+		return backupError(c, err)
+	}
+	return c.JSON(fiber.Map{"backups": backups, "count": len(backups)})
+}
+
+// GetRestorePlan verifies a backup and explains how to restore it
+// GET /api/admin/db/backups/:name/restore
+func (h *BackupHandler) GetRestorePlan(c *fiber.Ctx) error {
+	name := c.Params("name")
+	result, err := h.Backups.Verify(name)
+	if err != nil {
+		return backupError(c, err)
+	}
+
+	h.audit(c, "DB_BACKUP_VERIFIED", result)
+	if !result.Valid {
+		return c.Status(422).JSON(fiber.Map{"error": "Backup failed verification", "verification": result})
+	}
+
+	return c.JSON(fiber.Map{
+		"verification": result,
+		"download_url": "/api/admin/db/backups/" + name + "/download",
+		"procedure": []string{
+			"Download the backup and check it with: sha256sum -c " + name + ".sha256",
+			"Stop the backend so nothing writes to clinical.db",
+			"Move clinical.db, clinical.db-wal and clinical.db-shm aside (keep them until the restore is confirmed)",
+			"Copy the backup into place as clinical.db",
+			"Start the backend and confirm /health/ready and GET /api/blockchain/verify",
+		},
+	})
+}
+
+// DownloadBackup sends a backup file, but only after it passes verification
+// GET /api/admin/db/backups/:name/download
+func (h *BackupHandler) DownloadBackup(c *fiber.Ctx) error {
+	name := c.Params("name")
+	result, err := h.Backups.Verify(name)
+	if err != nil {
+		return backupError(c, err)
+	}
+	if !result.Valid {
+		return c.Status(422).JSON(fiber.Map{"error": "Backup failed verification", "verification": result})
+	}
+
+	path, err := h.Backups.Path(name)
+	if err != nil {
+		return backupError(c, err)
+	}
+
+	h.audit(c, "DB_BACKUP_DOWNLOADED", result.Backup)
+	c.Set("X-Checksum-SHA256", result.Backup.SHA256)
+	return c.Download(path, name)
+}
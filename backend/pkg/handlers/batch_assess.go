@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/logging"
+	"healthcare-backend/pkg/middleware"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Batch assessment limits
+const (
+	MaxBatchAssessRows        = 500
+	DefaultBatchAssessWorkers = 4
+)
+
+// batchRow is a valid row of a batch, with its index in the request
+type batchRow struct {
+	index   int
+	patient models.PatientData
+}
+
+// AssessBatch assesses a screening campaign's patients in one request.
+// Every row is validated first; valid rows are saved and scored by a bounded
+// pool of workers, and invalid ones come back in the error list. Only risks
+// are predicted, and the LLM diagnosis is skipped unless include_diagnosis
+// is set.
+// POST /api/assess/batch?include_diagnosis=false
+func (h *PatientHandler) AssessBatch(c *fiber.Ctx) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(c.Body(), &raw); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Body must be a JSON array of patients"})
+	}
+	if len(raw) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "No patients to assess"})
+	}
+	if len(raw) > MaxBatchAssessRows {
+		return c.Status(413).JSON(fiber.Map{"error": fmt.Sprintf("At most %d patients per batch", MaxBatchAssessRows)})
+	}
+	includeDiagnosis := c.QueryBool("include_diagnosis")
+
+	resp := models.BatchAssessResponse{
+		Total:   len(raw),
+		Results: []models.BatchAssessResult{},
+		Errors:  []models.BatchRowError{},
+	}
+	var rows []batchRow
+	for i, body := range raw {
+		var intake models.PatientIntake
+		if err := json.Unmarshal(body, &intake); err != nil {
+			var numErrs models.NumberFieldErrors
+			if errors.As(err, &numErrs) {
+				resp.Errors = append(resp.Errors, models.BatchRowError{Row: i, Error: "Invalid numbers", Fields: numErrs})
+			} else {
+				resp.Errors = append(resp.Errors, models.BatchRowError{Row: i, Error: "Invalid input"})
+			}
+			continue
+		}
+		if errs := middleware.ValidateStruct(intake); len(errs) > 0 {
+			resp.Errors = append(resp.Errors, models.BatchRowError{Row: i, Error: "Validation failed", Fields: errs})
+			continue
+		}
+		patient := intake.NewPatient()
+		patient.Source = services.PatientSourceScreening
+		rows = append(rows, batchRow{index: i, patient: patient})
+	}
+
+	workers := h.BatchWorkers
+	if workers <= 0 {
+		workers = DefaultBatchAssessWorkers
+	}
+	ctx, actor := c.UserContext(), auditctx.Actor(c)
+	results := make([]*models.BatchAssessResult, len(rows))
+	failures := make([]error, len(rows))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, len(rows)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i], failures[i] = h.assessRow(ctx, rows[i], actor, includeDiagnosis)
+			}
+		}()
+	}
+	start := time.Now()
+	for i := range rows {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	for i, row := range rows {
+		if failures[i] != nil {
+			resp.Errors = append(resp.Errors, models.BatchRowError{Row: row.index, Error: failures[i].Error()})
+			continue
+		}
+		resp.Results = append(resp.Results, *results[i])
+	}
+	resp.Assessed = len(resp.Results)
+	resp.DiagnosisQueued = includeDiagnosis && resp.Assessed > 0
+	// Invalid rows were listed before failed ones; report them in request order
+	slices.SortFunc(resp.Errors, func(a, b models.BatchRowError) int { return a.Row - b.Row })
+
+	logging.Request(c).Info("batch assessment finished", "assessed", resp.Assessed, "total", resp.Total, "workers", workers, "duration_ms", time.Since(start).Milliseconds())
+	return c.JSON(resp)
+}
+
+// assessRow predicts one row's risks, then saves the patient and its
+// assessment. The prediction runs first so a failed row leaves nothing
+// behind.
+func (h *PatientHandler) assessRow(ctx context.Context, row batchRow, actor auditctx.Identity, includeDiagnosis bool) (*models.BatchAssessResult, error) {
+	patient := row.patient
+	risks, err := h.Prediction.PredictRisksCtx(ctx, patient)
+	if err != nil || risks == nil {
+		return nil, errors.New("ML Service Offline")
+	}
+
+	if err := h.Patients.Create(&patient); err != nil {
+		return nil, fmt.Errorf("saving patient: %w", err)
+	}
+	if _, err := h.Audit.LogEvent("PATIENT_CREATED", patient.ID, patient, actor); err != nil {
+		logging.FromContext(ctx).Warn("audit event failed", "event", "PATIENT_CREATED", "error", err)
+	}
+	h.WS.PublishQueueEvent("created", patient)
+
+	reasons := h.emergencyReasons(patient, risks, nil)
+	isEmergency := len(reasons) > 0
+
+	var assessment models.Assessment
+	if err := assessment.SetPatientSnapshot(patient); err != nil {
+		return nil, err
+	}
+	predictionEvent := "AI_PREDICTION"
+	if risks.Source == models.PredictionSourceRuleBased {
+		predictionEvent = "FALLBACK_PREDICTION"
+	}
+	auditBlock, _ := h.Audit.LogEvent(predictionEvent, patient.ID, fiber.Map{
+		"risks":                 risks,
+		"patient_snapshot_hash": assessment.SnapshotHash,
+		"batch":                 true,
+	}, auditctx.System.InRequest(ctx))
+
+	fillAssessment(&assessment, patient.ID, risks, isEmergency, auditBlock.CurrentHash)
+	if err := assessment.SetExplanations(h.Prediction.Summarizer.Summarize(patient, *risks)); err != nil {
+		return nil, err
+	}
+	if err := h.Assessments.Create(&assessment); err != nil {
+		return nil, fmt.Errorf("saving assessment: %w", err)
+	}
+	if isEmergency {
+		h.notifyEmergency(ctx, patient, assessment.ID, risks, reasons, nil)
+	}
+
+	if includeDiagnosis {
+		similar, _ := h.RAG.FindSimilarCases(patient, patient.ID)
+		contextStr, contextRecord := h.redactPastContext(ctx, patient.ID, similar)
+		llmPatient := patient
+		llmPatient.Name = "" // Identity never leaves the backend
+		priority := models.DiagnosisPriorityRoutine
+		if isEmergency {
+			priority = models.DiagnosisPriorityEmergency
+		}
+		sent := h.Prediction.StartAsyncDiagnosisCtx(ctx, patient.ID, models.DiagnosisRequest{
+			Patient:     llmPatient,
+			RiskScores:  *risks,
+			PastContext: contextStr,
+			Priority:    priority,
+			RequestID:   auditctx.RequestIDFrom(ctx),
+		}, h.WS.BroadcastDiagnosis)
+		h.recordDiagnosisRequest(ctx, contextRecord, assessment.ID, sent)
+	}
+
+	return &models.BatchAssessResult{
+		Row:              row.index,
+		PatientID:        patient.ID,
+		AssessmentID:     assessment.ID,
+		Risks:            *risks,
+		Emergency:        isEmergency,
+		EmergencyReasons: reasons,
+		AuditHash:        auditBlock.CurrentHash,
+		PredictionSource: risks.Source,
+	}, nil
+}
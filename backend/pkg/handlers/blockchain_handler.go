@@ -1,9 +1,21 @@
 package handlers
 
 import (
-	"healthcare-backend/pkg/services"
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/logging"
+	"healthcare-backend/pkg/services"
+
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -16,53 +28,223 @@ func NewBlockchainHandler(audit *services.AuditService, ipfs *services.IPFSServi
 	return &BlockchainHandler{Audit: audit, IPFS: ipfs}
 }
 
-// VerifyChain checks the cryptographic integrity of the entire audit log
+// VerifyChain checks the cryptographic integrity of the audit log. Only
+// entries since the last verified checkpoint are checked unless ?full=true.
 // GET /api/blockchain/verify
 func (h *BlockchainHandler) VerifyChain(c *fiber.Ctx) error {
-	isValid, blockCount, err := h.Audit.VerifyChain()
-	
+	res, err := h.Audit.Verify(c.QueryBool("full"))
+	isValid := err == nil && res.Valid
+
 	status := "secure"
 	if !isValid {
 		status = "compromised"
 	}
+	// A ledger snapshot that failed validation on load stays flagged until replaced
+	tainted := h.Audit.Chain.TaintedReason()
+	if isValid && tainted != "" {
+		status = "tainted"
+	}
 
 	response := fiber.Map{
 		"valid":         isValid,
-		"block_count":   blockCount,
+		"block_count":   res.Length,
+		"length":        res.Length,
+		"checked_from":  res.CheckedFrom,
+		"checked_to":    res.CheckedTo,
+		"checked":       res.Checked,
+		"incremental":   res.Incremental,
 		"status":        status,
 		"last_verified": time.Now().UTC(),
 		"node_id":       "NODE_GEMINI_01", // Mock node ID
 		"algorithm":     "SHA-256 + Ed25519",
+		"ledger_valid":  h.Audit.Chain.IsChainValid(),
+	}
+	if tainted != "" {
+		response["ledger_tainted"] = tainted
 	}
 
 	if err != nil {
 		response["error"] = err.Error()
 		return c.Status(500).JSON(response)
 	}
+	if !res.Valid {
+		response["error"] = res.Error
+		return c.Status(500).JSON(response)
+	}
 
 	return c.JSON(response)
 }
 
-// BackupChain exports the current chain and uploads it to IPFS (Simulated)
-// POST /api/blockchain/backup
-func (h *BlockchainHandler) BackupChain(c *fiber.Ctx) error {
-	// 1. Export Chain Data
-	chainData, err := h.Audit.ExportChain()
+// ListKeys lists every key that has signed audit entries, so signatures
+// from before a key rotation or restart can be traced to a stable identity
+// GET /api/audit/keys
+func (h *BlockchainHandler) ListKeys(c *fiber.Ctx) error {
+	keys, err := h.Audit.ListKeys()
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to export chain"})
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to list audit keys"})
 	}
+	return c.JSON(fiber.Map{"keys": keys, "algorithm": "Ed25519"})
+}
+
+// BackupChain streams the current chain to IPFS (Simulated), encrypting it
+// on the way without holding a full copy in memory
+// POST /api/blockchain/backup
+func (h *BlockchainHandler) BackupChain(c *fiber.Ctx) error {
+	// 1. Export Chain Data into a pipe
+	pr, pw := io.Pipe()
+	exported := make(chan int, 1)
+	go func() {
+		n, err := h.Audit.ExportNDJSON(pw)
+		exported <- n
+		pw.CloseWithError(err)
+	}()
 
 	// 2. Backup to IPFS
-	cid, err := h.IPFS.BackupChain(chainData)
+	cid, size, err := h.IPFS.BackupChain(pr)
+	pr.CloseWithError(err) // Unblocks the export if the upload gave up early
+	blockCount := <-exported
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "IPFS upload failed"})
 	}
+	if _, err := h.Audit.LogEvent("BLOCKCHAIN_BACKED_UP", 0, fiber.Map{"cid": cid, "block_count": blockCount}, auditctx.Actor(c)); err != nil {
+		logging.Request(c).Warn("audit event failed", "error", err)
+	}
 
 	return c.JSON(fiber.Map{
 		"status":      "backed_up",
 		"ipfs_cid":    cid,
 		"timestamp":   time.Now().UTC(),
-		"block_count": len(chainData) / 200, // Rough estimate or pass real count
-		"provider":    "IPFS (Decentralized)",
+		"block_count": blockCount,
+		"bytes":       size,
+		"provider":    h.IPFS.Store.Name(),
 	})
 }
+
+// RestoreChain fetches a backup by CID, decrypts it and verifies it against
+// the local audit log. Nothing local is overwritten; differences come back
+// as a mismatch report.
+// POST /api/blockchain/restore
+func (h *BlockchainHandler) RestoreChain(c *fiber.Ctx) error {
+	var req struct {
+		CID string `json:"cid"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.CID == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "cid is required"})
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(h.IPFS.RestoreChain(req.CID, pw))
+	}()
+	report, err := h.Audit.CompareNDJSON(pr)
+	pr.CloseWithError(err) // Stops the download if the comparison gave up early
+	if errors.Is(err, services.ErrCIDNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Backup not found", "cid": req.CID})
+	}
+	if err != nil {
+		logging.Request(c).Warn("audit chain restore failed", "cid", req.CID, "error", err)
+		return c.Status(422).JSON(fiber.Map{"error": "Backup could not be decrypted or read", "cid": req.CID})
+	}
+
+	status := "match"
+	if !report.Consistent() {
+		status = "mismatch"
+	}
+	if _, err := h.Audit.LogEvent("BLOCKCHAIN_RESTORE_VERIFIED", 0, fiber.Map{"cid": req.CID, "status": status}, auditctx.Actor(c)); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to record the restore"})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":   status,
+		"cid":      req.CID,
+		"report":   report,
+		"provider": h.IPFS.Store.Name(),
+	})
+}
+
+// ExportAudit streams the audit chain as an NDJSON download, one entry per line
+// GET /api/audit/export
+func (h *BlockchainHandler) ExportAudit(c *fiber.Ctx) error {
+	if _, err := h.Audit.LogEvent("AUDIT_EXPORTED", 0, fiber.Map{"format": "ndjson"}, auditctx.Actor(c)); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to record the export"})
+	}
+
+	filename := fmt.Sprintf("audit-chain-%s.ndjson", time.Now().UTC().Format("20060102-150405"))
+	c.Set(fiber.HeaderContentType, "application/x-ndjson")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+	logger := logging.Request(c) // c is recycled before the body is written
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// Headers are gone by now, so a failure can only cut the download short
+		if n, err := h.Audit.ExportNDJSON(w); err != nil {
+			logger.Warn("audit export stopped", "entries", n, "error", err)
+		}
+		w.Flush()
+	})
+	return nil
+}
+
+// Page sizes for GET /api/audit/actors/:id
+const (
+	DefaultAuditPageSize = 50
+	MaxAuditPageSize     = 500
+)
+
+// ListActorEvents returns one page of the events an actor triggered, newest
+// first. Admins can look up anyone; everyone else only themselves, except
+// anonymous callers and kiosks, whose IDs are shared by many people.
+// GET /api/audit/actors/:id?page=1&limit=50
+func (h *BlockchainHandler) ListActorEvents(c *fiber.Ctx) error {
+	actorID, err := url.PathUnescape(c.Params("id"))
+	if err != nil || actorID == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid actor ID"})
+	}
+	caller := auditctx.Actor(c)
+	shared := caller.Role == auditctx.RoleAnonymous || caller.Role == auditctx.RoleKiosk
+	if caller.Role != auditctx.RoleAdmin && (caller.ID != actorID || shared) {
+		return c.Status(403).JSON(fiber.Map{"error": "Insufficient role"})
+	}
+	page, limit, invalid := auditPage(c)
+	if invalid != nil {
+		return c.Status(400).JSON(invalid)
+	}
+
+	events, err := h.Audit.ByActor(actorID, page, limit)
+	if err != nil {
+		return err
+	}
+	return c.JSON(events)
+}
+
+// auditPage reads ?page and ?limit; a non-nil map is the 400 body
+func auditPage(c *fiber.Ctx) (int, int, fiber.Map) {
+	page, limit := c.QueryInt("page", 1), c.QueryInt("limit", DefaultAuditPageSize)
+	if page < 1 {
+		return 0, 0, fiber.Map{"error": "page must be 1 or more"}
+	}
+	if limit < 1 || limit > MaxAuditPageSize {
+		return 0, 0, fiber.Map{"error": fmt.Sprintf("limit must be between 1 and %d", MaxAuditPageSize)}
+	}
+	return page, limit, nil
+}
+
+// ListPatientAccess returns one page of who read a patient's record, newest
+// first. :id is a patient ID or the patient hash audit entries carry.
+// GET /api/audit/patient/:id/access?page=1&limit=50
+func (h *BlockchainHandler) ListPatientAccess(c *fiber.Ctx) error {
+	hash := strings.ToLower(c.Params("id"))
+	if id, err := strconv.ParseUint(hash, 10, 64); err == nil && id > 0 {
+		hash = services.PatientHash(uint(id))
+	} else if b, err := hex.DecodeString(hash); err != nil || len(b) != sha256.Size {
+		return c.Status(400).JSON(fiber.Map{"error": "Expected a patient ID or a 64-character patient hash"})
+	}
+	page, limit, invalid := auditPage(c)
+	if invalid != nil {
+		return c.Status(400).JSON(invalid)
+	}
+
+	events, err := h.Audit.AccessHistory(hash, page, limit)
+	if err != nil {
+		return err
+	}
+	return c.JSON(events)
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"time"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/chaos"
+	"healthcare-backend/pkg/logging"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ChaosHandler struct {
+	Injector *chaos.Injector
+	Audit    *services.AuditService
+}
+
+func NewChaosHandler(injector *chaos.Injector, audit *services.AuditService) *ChaosHandler {
+	return &ChaosHandler{Injector: injector, Audit: audit}
+}
+
+// GetFaults lists active fault injections
+// GET /api/admin/chaos
+func (h *ChaosHandler) GetFaults(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"enabled": h.Injector.Enabled(), "faults": h.Injector.Active()})
+}
+
+// SetFault injects latency and/or errors into the ML, Redis or NATS client for a TTL
+// POST /api/admin/chaos
+func (h *ChaosHandler) SetFault(c *fiber.Ctx) error {
+	var req struct {
+		Target     string  `json:"target"`
+		Endpoint   string  `json:"endpoint"`
+		LatencyMs  int     `json:"latency_ms"`
+		ErrorRate  float64 `json:"error_rate"`
+		TTLSeconds int     `json:"ttl_seconds"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if req.TTLSeconds == 0 {
+		req.TTLSeconds = 60
+	}
+
+	fault, err := h.Injector.Set(chaos.Fault{
+		Target:    chaos.Target(req.Target),
+		Endpoint:  req.Endpoint,
+		Latency:   time.Duration(req.LatencyMs) * time.Millisecond,
+		ErrorRate: req.ErrorRate,
+	}, time.Duration(req.TTLSeconds)*time.Second)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	logging.Request(c).Warn("chaos fault injected", "target", fault.Target, "endpoint", fault.Endpoint,
+		"latency", fault.Latency, "error_rate", fault.ErrorRate, "expires_at", fault.ExpiresAt.Format(time.RFC3339))
+	if _, err := h.Audit.LogEvent("CHAOS_FAULT_SET", 0, fault, auditctx.Actor(c)); err != nil {
+		logging.Request(c).Warn("audit event failed", "error", err)
+	}
+
+	return c.Status(201).JSON(fault)
+}
+
+// ClearFaults removes every active fault
+// DELETE /api/admin/chaos
+func (h *ChaosHandler) ClearFaults(c *fiber.Ctx) error {
+	h.Injector.Clear()
+	if _, err := h.Audit.LogEvent("CHAOS_FAULTS_CLEARED", 0, fiber.Map{}, auditctx.Actor(c)); err != nil {
+		logging.Request(c).Warn("audit event failed", "error", err)
+	}
+	return c.JSON(fiber.Map{"faults": h.Injector.Active()})
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/logging"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ClinicHandler manages clinic working hours and shows the notification log
+type ClinicHandler struct {
+	Clinics       *services.ClinicService
+	Notifications *services.NotificationService
+	Audit         *services.AuditService
+}
+
+func NewClinicHandler(clinics *services.ClinicService, notifications *services.NotificationService, audit *services.AuditService) *ClinicHandler {
+	return &ClinicHandler{Clinics: clinics, Notifications: notifications, Audit: audit}
+}
+
+// GetClinics lists clinics and their working hours
+// GET /api/admin/clinics
+func (h *ClinicHandler) GetClinics(c *fiber.Ctx) error {
+	clinics, err := h.Clinics.List()
+	if err != nil {
+		return err
+	}
+	return c.JSON(clinics)
+}
+
+// SaveClinic creates or replaces a clinic's timezone, working windows and holidays
+// PUT /api/admin/clinics/:id
+func (h *ClinicHandler) SaveClinic(c *fiber.Ctx) error {
+	var clinic models.Clinic
+	if err := c.BodyParser(&clinic); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	clinic.ID = c.Params("id")
+
+	err := h.Clinics.Save(&clinic)
+	if errors.Is(err, services.ErrInvalidClinicHours) || errors.Is(err, services.ErrClinicRequired) {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := h.Audit.LogEvent("CLINIC_HOURS_CHANGED", 0, clinic, auditctx.Actor(c)); err != nil {
+		logging.Request(c).Warn("audit event failed", "error", err)
+	}
+	return c.JSON(clinic)
+}
+
+// GetNotifications lists recent notifications with their timing decision,
+// webhook deliveries included
+// GET /api/notifications?patient_id=12&kind=emergency_webhook&limit=50
+func (h *ClinicHandler) GetNotifications(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 50)
+	if limit <= 0 || limit > 500 {
+		return c.Status(400).JSON(fiber.Map{"error": "limit must be between 1 and 500"})
+	}
+	entries, err := h.Notifications.Log(uint(c.QueryInt("patient_id", 0)), c.Query("kind"), limit)
+	if err != nil {
+		return err
+	}
+	return c.JSON(entries)
+}
@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"errors"
 	"healthcare-backend/pkg/middleware"
 	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/repositories"
 	"healthcare-backend/pkg/services"
+	"healthcare-backend/pkg/workers"
 	"sync/atomic"
 	"time"
 
@@ -15,6 +18,10 @@ type DashboardHandler struct {
 	DB         *gorm.DB
 	Prediction *services.PredictionService
 	Audit      *services.AuditService
+	LLMWorker  *workers.LLMWorker // Reports the diagnosis backlog when set
+	Trends     *services.TrendService
+	Models     *services.ModelPerformanceService
+	Thresholds models.RiskThresholds // Level ML risk scores in the risk distribution
 }
 
 func NewDashboardHandler(db *gorm.DB, pred *services.PredictionService, audit *services.AuditService) *DashboardHandler {
@@ -22,6 +29,9 @@ func NewDashboardHandler(db *gorm.DB, pred *services.PredictionService, audit *s
 		DB:         db,
 		Prediction: pred,
 		Audit:      audit,
+		Trends:     services.NewTrendService(db),
+		Models:     services.NewModelPerformanceService(db),
+		Thresholds: services.DefaultRiskThresholds,
 	}
 }
 
@@ -31,11 +41,15 @@ func (h *DashboardHandler) GetSummary(c *fiber.Ctx) error {
 
 	var highRiskPatients int64
 	// Simple heuristic for "high risk" in dashboard summary: SystolicBP > 160
-	h.DB.Model(&models.PatientData{}).Where("systolic_bp > 160").Count(&highRiskPatients)
+	h.DB.Model(&models.PatientData{}).Where(repositories.HighRiskCondition).Count(&highRiskPatients)
 
 	var recentAssessments int64
-	// Patients created in the last 24 hours (SQLite compatible)
-	h.DB.Model(&models.PatientData{}).Where("created_at > datetime('now', '-1 day')").Count(&recentAssessments)
+	// Patients created in the last 24 hours
+	h.DB.Model(&models.PatientData{}).Where("created_at > ?", time.Now().Add(-24*time.Hour)).Count(&recentAssessments)
+
+	// Assessments the ML models weren't consulted for
+	var fallbackAssessments int64
+	h.DB.Model(&models.Assessment{}).Where("rule_based = ? AND created_at > ?", true, time.Now().Add(-24*time.Hour)).Count(&fallbackAssessments)
 
 	// Check ML Service Status
 	mlPulse := "Online"
@@ -48,17 +62,15 @@ func (h *DashboardHandler) GetSummary(c *fiber.Ctx) error {
 		systemHealth = "Warning"
 	}
 
-	// Risk Distribution - REAL DATA from database
-	// High: SystolicBP > 160 | Medium: 140-160 | Low: < 140
-	var lowRiskPatients int64
-	var mediumRiskPatients int64
-	h.DB.Model(&models.PatientData{}).Where("systolic_bp < 140").Count(&lowRiskPatients)
-	h.DB.Model(&models.PatientData{}).Where("systolic_bp >= 140 AND systolic_bp <= 160").Count(&mediumRiskPatients)
-	
-	riskDist := map[string]int64{
-		"Low":    lowRiskPatients,
-		"Medium": mediumRiskPatients,
-		"High":   highRiskPatients,
+	// Risk distribution by ML score, falling back to systolic BP for
+	// patients the ML models never scored
+	riskMethods, err := services.RiskDistribution(h.DB, h.Thresholds)
+	if err != nil {
+		return err
+	}
+	riskDist := map[string]int64{}
+	for _, level := range []string{"Low", "Medium", "High"} {
+		riskDist[level] = riskMethods.MLScore[level] + riskMethods.SystolicBP[level]
 	}
 
 	// Telemetry data
@@ -72,20 +84,66 @@ func (h *DashboardHandler) GetSummary(c *fiber.Ctx) error {
 	}
 
 	summary := models.DashboardSummary{
-		TotalPatients:     totalPatients,
-		HighRiskPatients:  highRiskPatients,
-		RecentAssessments: recentAssessments,
-		SystemHealth:      systemHealth,
-		MLServicePulse:    mlPulse,
-		AuditChainValid:   true,
-		RiskDistribution:  riskDist,
+		TotalPatients:       totalPatients,
+		HighRiskPatients:    highRiskPatients,
+		RecentAssessments:   recentAssessments,
+		SystemHealth:        systemHealth,
+		MLServicePulse:      mlPulse,
+		AuditChainValid:     true,
+		RiskDistribution:    riskDist,
+		RiskMethods:         riskMethods,
+		FallbackAssessments: fallbackAssessments,
 		Performance: models.PerformanceMetrics{
 			AvgMLInferenceTimeMs: h.Prediction.LastMLLatency,
 			UptimeSeconds:        uptime,
 			RequestCount:         int64(reqCount),
 			ErrorRate:            errRate,
+			PredictionCache:      h.Prediction.CacheStats(),
 		},
 	}
+	if h.LLMWorker != nil {
+		summary.DiagnosisBacklog = h.LLMWorker.Status().Backlog
+	}
 
 	return c.JSON(summary)
 }
+
+// GetTrends returns assessment, emergency, mean risk and fallback series in
+// time buckets over the window. Windows go up to 90 days, buckets down to an hour.
+// GET /api/dashboard/trends?window=7d&bucket=1d
+func (h *DashboardHandler) GetTrends(c *fiber.Ctx) error {
+	window, err := services.ParseTrendSpan(c.Query("window", "7d"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "window: " + err.Error()})
+	}
+	bucket, err := services.ParseTrendSpan(c.Query("bucket", "1d"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "bucket: " + err.Error()})
+	}
+
+	trends, err := h.Trends.Trends(window, bucket)
+	if errors.Is(err, services.ErrInvalidTrendSpan) {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return err
+	}
+	return c.JSON(trends)
+}
+
+// GetModelPerformance reports, per risk model, the assessments it scored,
+// its mean confidence and how often doctors approved or overrode it, with
+// weekly acceptance
+// GET /api/dashboard/model-performance?weeks=12
+func (h *DashboardHandler) GetModelPerformance(c *fiber.Ctx) error {
+	weeks := c.QueryInt("weeks", 12)
+	if weeks < 1 || weeks > 104 {
+		return c.Status(400).JSON(fiber.Map{"error": "weeks must be between 1 and 104"})
+	}
+
+	report, err := h.Models.Report(time.Now().AddDate(0, 0, -7*weeks))
+	if err != nil {
+		return err
+	}
+	return c.JSON(report)
+}
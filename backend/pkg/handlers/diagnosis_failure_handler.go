@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"errors"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// DiagnosisFailureHandler lets admins review dead-lettered LLM diagnoses
+// and send them again
+type DiagnosisFailureHandler struct {
+	DB         *gorm.DB
+	Failures   *services.DiagnosisFailureService
+	Prediction *services.PredictionService
+	WS         *WebSocketHandler
+	Audit      *services.AuditService
+}
+
+func NewDiagnosisFailureHandler(db *gorm.DB, failures *services.DiagnosisFailureService, prediction *services.PredictionService, ws *WebSocketHandler, audit *services.AuditService) *DiagnosisFailureHandler {
+	return &DiagnosisFailureHandler{DB: db, Failures: failures, Prediction: prediction, WS: ws, Audit: audit}
+}
+
+// List returns diagnoses that failed every retry, newest first.
+// ?all=true includes those already requeued.
+// GET /api/admin/diagnosis-failures
+func (h *DiagnosisFailureHandler) List(c *fiber.Ctx) error {
+	failures, err := h.Failures.List(c.QueryBool("all"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{"failures": failures, "count": len(failures)})
+}
+
+// Requeue starts a fresh diagnosis from a failed task's request. It
+// supersedes any diagnosis the patient has had since.
+// POST /api/admin/diagnosis-failures/:id/requeue
+func (h *DiagnosisFailureHandler) Requeue(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid failure ID"})
+	}
+
+	failure, req, err := h.Failures.Requeue(uint(id))
+	switch {
+	case errors.Is(err, services.ErrDiagnosisFailureNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "Diagnosis failure not found"})
+	case errors.Is(err, services.ErrAlreadyRequeued):
+		return c.Status(409).JSON(fiber.Map{"error": "Diagnosis failure was already requeued"})
+	case err != nil:
+		return err
+	}
+
+	// A deleted patient gets no new diagnosis
+	if err := h.DB.Select("id").First(&models.PatientData{}, failure.PatientID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Patient not found"})
+	} else if err != nil {
+		return err
+	}
+
+	req.RequestID = auditctx.RequestID(c) // The worker's logs follow the requeue, not the failed attempt
+	sent := h.Prediction.StartAsyncDiagnosisCtx(c.UserContext(), failure.PatientID, req, h.WS.BroadcastDiagnosis)
+	h.Audit.LogEvent("DIAGNOSIS_REQUEUED", failure.PatientID, map[string]any{
+		"failure_id": failure.ID,
+		"generation": sent.Generation,
+	}, auditctx.Actor(c))
+
+	return c.JSON(fiber.Map{"failure": failure, "patient_id": failure.PatientID, "status": "pending"})
+}
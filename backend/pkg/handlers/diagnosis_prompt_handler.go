@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/repositories"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// DiagnosisPromptHandler shows what was sent to the LLM for a diagnosis,
+// for AI transparency review. Every successful read is audited.
+type DiagnosisPromptHandler struct {
+	DB          *gorm.DB
+	Assessments repositories.AssessmentRepository
+	Audit       *services.AuditService
+}
+
+func NewDiagnosisPromptHandler(db *gorm.DB, assessments repositories.AssessmentRepository, audit *services.AuditService) *DiagnosisPromptHandler {
+	return &DiagnosisPromptHandler{DB: db, Assessments: assessments, Audit: audit}
+}
+
+// Get assembles the prompt, redacted context and risk scores queued for an
+// assessment's diagnosis
+// GET /api/admin/diagnosis/:id/prompt
+func (h *DiagnosisPromptHandler) Get(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid assessment ID"})
+	}
+
+	assessment, err := h.Assessments.GetByID(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Assessment not found"})
+	}
+	if err != nil {
+		return err
+	}
+
+	var record models.DiagnosisContext
+	err = h.DB.Where("assessment_id = ? AND request <> ''", assessment.ID).Order("id DESC").First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "No prompt was recorded for this diagnosis: it predates prompt persistence or never reached the LLM"})
+	}
+	if err != nil {
+		return err
+	}
+
+	prompt := models.DiagnosisPrompt{
+		AssessmentID:   assessment.ID,
+		PatientID:      assessment.PatientID,
+		ContextID:      record.ID,
+		RecordedAt:     record.CreatedAt,
+		PromptVersion:  record.PromptVersion,
+		LLMModel:       record.LLMModel,
+		SystemPrompt:   record.SystemPrompt,
+		Prompt:         record.Prompt,
+		PastContext:    record.PastContext,
+		PrivacyMode:    record.PrivacyMode,
+		RedactionCount: record.RedactionCount,
+		ContextBlocked: record.Blocked,
+		MLEndpoint:     record.MLEndpoint,
+		MLBackend:      assessment.MLBackend,
+		RuleBased:      assessment.RuleBased,
+		Generation:     record.Generation,
+		Request:        json.RawMessage(record.Request),
+	}
+	var sent models.DiagnosisRequest
+	if err := json.Unmarshal(prompt.Request, &sent); err != nil {
+		return err
+	}
+	prompt.RiskScores = sent.RiskScores
+
+	// 📜 Reading the prompt exposes clinical data, so it's audited before it's served
+	if _, err := h.Audit.LogEvent("DIAGNOSIS_PROMPT_VIEWED", assessment.PatientID, fiber.Map{
+		"assessment_id":  assessment.ID,
+		"context_id":     record.ID,
+		"prompt_version": record.PromptVersion,
+	}, auditctx.Actor(c)); err != nil {
+		return err
+	}
+	return c.JSON(prompt)
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"healthcare-backend/pkg/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Diagnosis stream and long-poll defaults, used when the handler's fields are zero
+const (
+	DefaultDiagnosisStreamMax       = 5 * time.Minute
+	DefaultDiagnosisStreamHeartbeat = 15 * time.Second
+	DefaultMaxDiagnosisWait         = 60 * time.Second
+)
+
+// WatchDiagnosis delivers the patient's diagnosis updates until stop is
+// called. Only the latest update is kept for a slow reader.
+func (h *WebSocketHandler) WatchDiagnosis(patientID uint) (updates <-chan models.DiagnosisUpdate, stop func()) {
+	ch := make(chan models.DiagnosisUpdate, 1)
+	h.mu.Lock()
+	if h.watchers[patientID] == nil {
+		h.watchers[patientID] = make(map[chan models.DiagnosisUpdate]struct{})
+	}
+	h.watchers[patientID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.watchers[patientID], ch)
+		if len(h.watchers[patientID]) == 0 {
+			delete(h.watchers, patientID)
+		}
+		h.mu.Unlock()
+	}
+}
+
+func (h *WebSocketHandler) notifyWatchers(update models.DiagnosisUpdate) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for ch := range h.watchers[update.PatientID] {
+		select {
+		case ch <- update:
+		default:
+			// Replace the unread update with the newer one
+			select {
+			case <-ch:
+			default:
+			}
+			select {
+			case ch <- update:
+			default:
+			}
+		}
+	}
+}
+
+func diagnosisFinished(status string) bool {
+	return status == "ready" || status == "error"
+}
+
+// StreamDiagnosis pushes a patient's diagnosis as Server-Sent Events: the
+// current state at once, then every change, closing after ready or error.
+// Comment heartbeats keep proxies from dropping the idle connection.
+// GET /api/diagnosis/:id/stream
+func (h *PatientHandler) StreamDiagnosis(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid patient ID"})
+	}
+	patientID := uint(id)
+
+	maxDuration, heartbeat := h.StreamMax, h.StreamHeartbeat
+	if maxDuration <= 0 {
+		maxDuration = DefaultDiagnosisStreamMax
+	}
+	if heartbeat <= 0 {
+		heartbeat = DefaultDiagnosisStreamHeartbeat
+	}
+
+	// Watch before reading the cache so no change slips in between
+	updates, stop := h.WS.WatchDiagnosis(patientID)
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no") // nginx would otherwise buffer the stream
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer stop()
+
+		send := func(diagnosis, status string) bool {
+			data, _ := json.Marshal(fiber.Map{"id": patientID, "diagnosis": diagnosis, "status": status})
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			return w.Flush() == nil
+		}
+
+		diagnosis, status := h.Prediction.Cache.Get(patientID)
+		if !send(diagnosis, status) || diagnosisFinished(status) {
+			return
+		}
+
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		deadline := time.NewTimer(maxDuration)
+		defer deadline.Stop()
+		for {
+			select {
+			case u := <-updates:
+				if u.Status == status && u.Diagnosis == diagnosis {
+					continue
+				}
+				diagnosis, status = u.Diagnosis, u.Status
+				if !send(diagnosis, status) || diagnosisFinished(status) {
+					return
+				}
+			case <-ticker.C:
+				// A failed flush means the client has gone
+				fmt.Fprint(w, ": heartbeat\n\n")
+				if w.Flush() != nil {
+					return
+				}
+			case <-deadline.C:
+				// Clients reconnect or fall back to polling
+				fmt.Fprint(w, "event: timeout\ndata: {}\n\n")
+				w.Flush()
+				return
+			}
+		}
+	})
+	return nil
+}
+
+// diagnosisWait parses a long-poll ?wait ("30s", or plain seconds), capped
+// at MaxDiagnosisWait. Empty means no wait.
+func (h *PatientHandler) diagnosisWait(param string) (time.Duration, error) {
+	if param == "" {
+		return 0, nil
+	}
+	wait, err := time.ParseDuration(param)
+	if err != nil {
+		secs, serr := strconv.Atoi(param)
+		if serr != nil {
+			return 0, errors.New("wait must be a duration such as 30s")
+		}
+		wait = time.Duration(secs) * time.Second
+	}
+	if wait < 0 {
+		return 0, errors.New("wait must not be negative")
+	}
+	limit := h.MaxDiagnosisWait
+	if limit <= 0 {
+		limit = DefaultMaxDiagnosisWait
+	}
+	return min(wait, limit), nil
+}
+
+// awaitDiagnosis blocks until the patient's diagnosis leaves "pending", the
+// wait runs out or the server shuts down, and returns the latest state.
+// fasthttp can't report a client hanging up mid-request, so the capped wait
+// is what bounds an abandoned request.
+func (h *PatientHandler) awaitDiagnosis(c *fiber.Ctx, patientID uint, wait time.Duration) (string, string) {
+	updates, stop := h.WS.WatchDiagnosis(patientID)
+	defer stop()
+
+	// Re-read now that we're watching, in case it finished in between
+	diagnosis, status := h.Prediction.Cache.Get(patientID)
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for status == "pending" {
+		select {
+		case u := <-updates:
+			diagnosis, status = u.Diagnosis, u.Status
+		case <-timer.C:
+			return diagnosis, status
+		case <-c.Context().Done():
+			return diagnosis, status
+		}
+	}
+	return diagnosis, status
+}
@@ -3,16 +3,18 @@ package handlers
 import (
 	"healthcare-backend/pkg/models"
 	"healthcare-backend/pkg/services"
+	"healthcare-backend/pkg/terminology"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 type DiseaseHandler struct {
 	PredictionService *services.PredictionService
+	Terms             *terminology.Mapper
 }
 
-func NewDiseaseHandler(ps *services.PredictionService) *DiseaseHandler {
-	return &DiseaseHandler{PredictionService: ps}
+func NewDiseaseHandler(ps *services.PredictionService, terms *terminology.Mapper) *DiseaseHandler {
+	return &DiseaseHandler{PredictionService: ps, Terms: terms}
 }
 
 func (h *DiseaseHandler) Predict(c *fiber.Ctx) error {
@@ -25,10 +27,11 @@ func (h *DiseaseHandler) Predict(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Symptoms are required"})
 	}
 
-	result, err := h.PredictionService.PredictDisease(req)
+	result, err := h.PredictionService.PredictDiseaseCtx(c.UserContext(), req)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
+	result.CodedSymptoms, result.UnmappedSymptoms = h.Terms.Map(req.Symptoms)
 
 	return c.JSON(result)
 }
@@ -1,18 +1,22 @@
 package handlers
 
 import (
+	"healthcare-backend/pkg/apierrors"
+	"healthcare-backend/pkg/logging"
 	"healthcare-backend/pkg/models"
 	"healthcare-backend/pkg/services"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 type EKGHandler struct {
+	DB                *gorm.DB
 	PredictionService *services.PredictionService
 }
 
-func NewEKGHandler(ps *services.PredictionService) *EKGHandler {
-	return &EKGHandler{PredictionService: ps}
+func NewEKGHandler(db *gorm.DB, ps *services.PredictionService) *EKGHandler {
+	return &EKGHandler{DB: db, PredictionService: ps}
 }
 
 func (h *EKGHandler) Analyze(c *fiber.Ctx) error {
@@ -25,10 +29,49 @@ func (h *EKGHandler) Analyze(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Signal data is required"})
 	}
 
-	result, err := h.PredictionService.AnalyzeEKG(req)
+	result, err := h.PredictionService.AnalyzeEKGCtx(c.UserContext(), req)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
+	analysis := services.NewEKGAnalysis(req.PatientID, result)
+	result.ParsedFeatures = &analysis.EKGFeatures
+
+	// Only patient-linked analyses are kept for trends
+	if req.PatientID != 0 {
+		if err := h.DB.Create(&analysis).Error; err != nil {
+			logging.Request(c).Warn("failed to store ekg analysis", "patient_id", req.PatientID, "error", err)
+			return apierrors.FromDB(err)
+		}
+		result.AnalysisID = analysis.ID
+	}
+
 	return c.JSON(result)
 }
+
+// GetTrends returns a patient's stored EKG features, oldest first
+func (h *EKGHandler) GetTrends(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid patient ID"})
+	}
+	limit := c.QueryInt("limit", 100)
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	// Take the newest `limit` rows, then order them chronologically for charting
+	var analyses []models.EKGAnalysis
+	if err := h.DB.Where("patient_id = ?", id).Order("created_at DESC, id DESC").Limit(limit).Find(&analyses).Error; err != nil {
+		return err
+	}
+	for i, j := 0, len(analyses)-1; i < j; i, j = i+1, j-1 {
+		analyses[i], analyses[j] = analyses[j], analyses[i]
+	}
+
+	return c.JSON(fiber.Map{
+		"patient_id": id,
+		"count":      len(analyses),
+		"analyses":   analyses,
+	})
+}
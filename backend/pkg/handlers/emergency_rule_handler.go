@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/logging"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// EmergencyRuleHandler lets admins edit what flags an assessment as an emergency
+type EmergencyRuleHandler struct {
+	Rules *services.EmergencyRuleService
+	Audit *services.AuditService
+}
+
+func NewEmergencyRuleHandler(rules *services.EmergencyRuleService, audit *services.AuditService) *EmergencyRuleHandler {
+	return &EmergencyRuleHandler{Rules: rules, Audit: audit}
+}
+
+// emergencyRuleError maps service errors onto responses
+func emergencyRuleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidEmergencyRule):
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrEmergencyRuleNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "Emergency rule not found"})
+	}
+	return err
+}
+
+func (h *EmergencyRuleHandler) audit(c *fiber.Ctx, from, to *models.EmergencyRule) {
+	if _, err := h.Audit.LogEvent("EMERGENCY_RULE_CHANGED", 0, fiber.Map{"from": from, "to": to}, auditctx.Actor(c)); err != nil {
+		logging.Request(c).Warn("audit event failed", "error", err)
+	}
+}
+
+// List returns every rule, enabled or not, with the fields rules can test
+// GET /api/admin/emergency-rules
+func (h *EmergencyRuleHandler) List(c *fiber.Ctx) error {
+	rules, err := h.Rules.List()
+	if err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{"rules": rules, "fields": services.EmergencyRuleFields})
+}
+
+// Create adds a rule; it applies to the next assessment
+// POST /api/admin/emergency-rules
+func (h *EmergencyRuleHandler) Create(c *fiber.Ctx) error {
+	var rule models.EmergencyRule
+	if err := c.BodyParser(&rule); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	created, err := h.Rules.Create(rule)
+	if err != nil {
+		return emergencyRuleError(c, err)
+	}
+	h.audit(c, nil, created)
+	return c.Status(201).JSON(created)
+}
+
+// Update replaces a rule. Disable a rule with "enabled": false rather than
+// deleting it to keep it around for later.
+// PUT /api/admin/emergency-rules/:id
+func (h *EmergencyRuleHandler) Update(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid rule ID"})
+	}
+	var rule models.EmergencyRule
+	if err := c.BodyParser(&rule); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	saved, previous, err := h.Rules.Update(uint(id), rule)
+	if err != nil {
+		return emergencyRuleError(c, err)
+	}
+	h.audit(c, previous, saved)
+	return c.JSON(saved)
+}
+
+// Delete removes a rule
+// DELETE /api/admin/emergency-rules/:id
+func (h *EmergencyRuleHandler) Delete(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid rule ID"})
+	}
+
+	deleted, err := h.Rules.Delete(uint(id))
+	if err != nil {
+		return emergencyRuleError(c, err)
+	}
+	h.audit(c, deleted, nil)
+	return c.SendStatus(204)
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/logging"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErasureHandler serves GDPR right-to-erasure requests
+type ErasureHandler struct {
+	Erasure *services.ErasureService
+	Audit   *services.AuditService
+	WS      *WebSocketHandler
+}
+
+func NewErasureHandler(erasure *services.ErasureService, audit *services.AuditService, ws *WebSocketHandler) *ErasureHandler {
+	return &ErasureHandler{Erasure: erasure, Audit: audit, WS: ws}
+}
+
+// Erase anonymizes a patient. Without ?confirm= it answers 428 with a
+// single-use confirmation token; repeating the request with that token
+// erases the patient and returns the ErasureReport.
+// DELETE /api/patients/:id/erase?confirm=<token>
+func (h *ErasureHandler) Erase(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid patient ID"})
+	}
+
+	confirm := c.Query("confirm")
+	if confirm == "" {
+		token, record, err := h.Erasure.RequestConfirmation(uint(id))
+		if errors.Is(err, services.ErrPatientNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "Patient not found"})
+		} else if err != nil {
+			return err
+		}
+		return c.Status(428).JSON(fiber.Map{
+			"error":              "Erasure is irreversible; repeat the request with ?confirm=<confirmation_token>",
+			"confirmation_token": token,
+			"expires_at":         record.ExpiresAt,
+		})
+	}
+
+	if err := h.Audit.Flush(); err != nil { // So the report counts every audit entry
+		return err
+	}
+	report, err := h.Erasure.Erase(uint(id), confirm)
+	switch {
+	case errors.Is(err, services.ErrPatientNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "Patient not found"})
+	case errors.Is(err, services.ErrErasureTokenInvalid):
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return err
+	}
+
+	// The report names tables and counts only, so it is safe to hash-chain
+	if _, err := h.Audit.LogEvent("ERASURE_REQUEST", report.PatientID, report, auditctx.Actor(c)); err != nil {
+		logging.Request(c).Warn("audit event failed", "event", "ERASURE_REQUEST", "patient_id", report.PatientID, "error", err)
+	}
+	logging.Request(c).Info("patient erased", "patient_id", report.PatientID)
+
+	h.WS.PublishQueueEvent("deleted", models.PatientData{ID: report.PatientID})
+	return c.JSON(report)
+}
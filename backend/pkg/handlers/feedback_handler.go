@@ -1,11 +1,18 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"strconv"
+	"strings"
 	"time"
 
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/logging"
+	"healthcare-backend/pkg/middleware"
 	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/repositories"
 	"healthcare-backend/pkg/services"
 
 	"github.com/gofiber/fiber/v2"
@@ -13,27 +20,66 @@ import (
 )
 
 type FeedbackHandler struct {
-	DB    *gorm.DB
-	Audit *services.AuditService
+	DB         *gorm.DB
+	Feedback   repositories.FeedbackRepository
+	Overrides  *services.OverrideService
+	Audit      *services.AuditService
+	ICD10      *services.ICD10Service         // Checks confirmed codes; nil only checks their format
+	Embeddings *services.NoteEmbeddingService // Embeds approved notes for retrieval; nil when not configured
+	Providers  *services.ProviderService      // Scopes doctors' reads to their patients; nil reads everyone
 }
 
-func NewFeedbackHandler(db *gorm.DB, audit *services.AuditService) *FeedbackHandler {
-	return &FeedbackHandler{DB: db, Audit: audit}
+func NewFeedbackHandler(db *gorm.DB, feedback repositories.FeedbackRepository, overrides *services.OverrideService, audit *services.AuditService) *FeedbackHandler {
+	return &FeedbackHandler{DB: db, Feedback: feedback, Overrides: overrides, Audit: audit}
 }
 
 func (h *FeedbackHandler) SubmitFeedback(c *fiber.Ctx) error {
-	type FeedbackRequest struct {
-		AssessmentID    int                 `json:"assessment_id"`
-		Approved        bool                `json:"approved"`
-		Notes           string              `json:"notes"`
-		Risks           any                 `json:"risks"`
-		OverrideDetails *models.OverrideLog `json:"override_details"`
-	}
-
-	var req FeedbackRequest
+	var req models.FeedbackRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid feedback"})
 	}
+	if errs := middleware.ValidateStruct(req); len(errs) > 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "errors": errs})
+	}
+
+	// The risks the doctor reviewed are kept to compare the decision with;
+	// a rejection means nothing without them
+	risks, err := models.ParseRiskProfile(req.Risks)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if risks == nil && !req.Approved {
+		return c.Status(400).JSON(fiber.Map{"error": "risks are required when rejecting an assessment"})
+	}
+	var riskProfile string
+	if risks != nil {
+		snapshot, err := json.Marshal(risks)
+		if err != nil {
+			return err
+		}
+		riskProfile = string(snapshot)
+	}
+
+	// Overrides must use a reason from the taxonomy
+	isOverride := !req.Approved && req.OverrideDetails != nil
+	if isOverride {
+		if err := h.Overrides.Resolve(req.OverrideDetails); errors.Is(err, services.ErrInvalidOverride) {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		} else if err != nil {
+			return err
+		}
+	}
+
+	// Confirmed ICD-10 codes must be in the code table
+	if len(req.ICD10Codes) > 0 {
+		codes, err := h.resolveICD10(req.ICD10Codes)
+		if errors.Is(err, services.ErrUnknownICD10) {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		} else if err != nil {
+			return err
+		}
+		req.ICD10Codes = codes
+	}
 
 	// Map simplified request to DB model
 	fb := models.Feedback{
@@ -42,24 +88,217 @@ func (h *FeedbackHandler) SubmitFeedback(c *fiber.Ctx) error {
 		PatientID:      uint(req.AssessmentID), // Linking directly for RAG
 		DoctorApproved: req.Approved,
 		DoctorNotes:    req.Notes,
+		RiskProfile:    riskProfile,
+		ICD10Codes:     strings.Join(req.ICD10Codes, ","),
+		AuthorID:       auditctx.Actor(c).ID,
 	}
 
-	h.DB.Create(&fb)
+	if err := h.Feedback.Create(&fb); err != nil {
+		return err
+	}
+	if h.Embeddings != nil {
+		h.Embeddings.Index(fb)
+	}
 
 	// 📜 Audit: Log Event (Compliance Rule: Article 14 Human Oversight)
 	eventType := "DOCTOR_FEEDBACK"
 	payload := interface{}(req)
 
-	if !req.Approved && req.OverrideDetails != nil {
+	if isOverride {
 		eventType = "HUMAN_OVERRIDE"
 		req.OverrideDetails.OversightType = "Human-in-the-Loop"
+		req.OverrideDetails.FeedbackID = fb.ID
+		req.OverrideDetails.PatientID = fb.PatientID
+		req.OverrideDetails.DoctorID = fb.AuthorID
+		if err := h.DB.Create(req.OverrideDetails).Error; err != nil {
+			return err
+		}
 		payload = req.OverrideDetails
-		log.Printf("⚠️ Human Override Detected for Patient %d", fb.PatientID)
+		logging.Request(c).Warn("human override", "patient_id", fb.PatientID)
 	}
 
-	if _, err := h.Audit.LogEvent(eventType, fb.PatientID, payload, "doctor"); err != nil {
-		log.Printf("⚠️ Failed to log audit event: %v", err)
+	if _, err := h.Audit.LogEvent(eventType, fb.PatientID, payload, auditctx.Actor(c)); err != nil {
+		logging.Request(c).Warn("audit event failed", "error", err)
 	}
 
 	return c.JSON(fiber.Map{"status": "recorded", "id": fb.ID})
 }
+
+// resolveICD10 normalizes confirmed codes, checking them against the code
+// table when there is one
+func (h *FeedbackHandler) resolveICD10(codes []string) ([]string, error) {
+	if h.ICD10 != nil {
+		return h.ICD10.Resolve(codes)
+	}
+	return services.NormalizeICD10(codes)
+}
+
+// Page sizes for GET /api/feedback
+const (
+	DefaultFeedbackPageSize = 50
+	MaxFeedbackPageSize     = 200
+)
+
+// scope limits doctors to feedback on their patients
+func (h *FeedbackHandler) scope(c *fiber.Ctx) (services.PatientScope, error) {
+	if h.Providers == nil {
+		return services.PatientScope{All: true}, nil
+	}
+	return h.Providers.ScopeFor(auditctx.Actor(c))
+}
+
+// ListFeedback returns one page of submitted feedback, newest first.
+// Doctors only see feedback on patients assigned to them.
+// GET /api/feedback?patient_id=&approved=true|false&page=1&limit=50
+func (h *FeedbackHandler) ListFeedback(c *fiber.Ctx) error {
+	filter := repositories.FeedbackFilter{
+		Page:  c.QueryInt("page", 1),
+		Limit: c.QueryInt("limit", DefaultFeedbackPageSize),
+	}
+	if filter.Page < 1 {
+		return c.Status(400).JSON(fiber.Map{"error": "page must be 1 or more"})
+	}
+	if filter.Limit < 1 || filter.Limit > MaxFeedbackPageSize {
+		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("limit must be between 1 and %d", MaxFeedbackPageSize)})
+	}
+	if raw := c.Query("patient_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil || id == 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid patient ID"})
+		}
+		filter.PatientID = uint(id)
+	}
+	if raw := c.Query("approved"); raw != "" {
+		approved, err := strconv.ParseBool(raw)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "approved must be true or false"})
+		}
+		filter.Approved = &approved
+	}
+
+	scope, err := h.scope(c)
+	if err != nil {
+		return err
+	}
+	if !scope.All {
+		filter.AssignedTo = &scope.ProviderID
+	}
+
+	page, err := h.Feedback.List(filter)
+	if err != nil {
+		return err
+	}
+	return c.JSON(page)
+}
+
+// GetAssessmentFeedback returns all feedback given on an assessment,
+// newest first, scoped like ListFeedback
+// GET /api/assessments/:id/feedback
+func (h *FeedbackHandler) GetAssessmentFeedback(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid assessment ID"})
+	}
+	filter := repositories.FeedbackFilter{AssessmentID: uint(id), Page: 1}
+	scope, err := h.scope(c)
+	if err != nil {
+		return err
+	}
+	if !scope.All {
+		filter.AssignedTo = &scope.ProviderID
+	}
+
+	page, err := h.Feedback.List(filter)
+	if err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{"assessment_id": id, "feedback": page.Items})
+}
+
+// load fetches the feedback named by :id
+func (h *FeedbackHandler) load(c *fiber.Ctx) (*models.Feedback, error) {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return nil, fiber.NewError(400, "Invalid feedback ID")
+	}
+	fb, err := h.Feedback.GetByID(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fiber.NewError(404, "Feedback not found")
+	}
+	return fb, err
+}
+
+// GetFeedback returns one feedback with the risk snapshot the doctor
+// reviewed, decoded, and every version of its notes
+// GET /api/feedback/:id
+func (h *FeedbackHandler) GetFeedback(c *fiber.Ctx) error {
+	fb, err := h.load(c)
+	if err != nil {
+		return err
+	}
+	scope, err := h.scope(c)
+	if err != nil {
+		return err
+	}
+	if !scope.All {
+		var patient models.PatientData
+		if err := h.DB.Limit(1).Find(&patient, fb.PatientID).Error; err != nil {
+			return err
+		}
+		if !scope.Allows(patient) {
+			return c.Status(403).JSON(fiber.Map{"error": "Patient is not assigned to you"})
+		}
+	}
+	return h.withRevisions(c, fb)
+}
+
+// AmendFeedback replaces the doctor notes of submitted feedback, keeping
+// every earlier version as a revision, and logs FEEDBACK_AMENDED. Only the
+// doctor who submitted it or an admin may amend it.
+// PUT /api/feedback/:id
+func (h *FeedbackHandler) AmendFeedback(c *fiber.Ctx) error {
+	var req models.FeedbackAmendment
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid amendment"})
+	}
+	if errs := middleware.ValidateStruct(req); len(errs) > 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "errors": errs})
+	}
+	fb, err := h.load(c)
+	if err != nil {
+		return err
+	}
+	actor := auditctx.Actor(c)
+	if actor.Role != auditctx.RoleAdmin && (fb.AuthorID == "" || fb.AuthorID != actor.ID) {
+		return c.Status(403).JSON(fiber.Map{"error": "Only the submitting doctor or an admin can amend feedback"})
+	}
+
+	rev, err := h.Feedback.Amend(fb.ID, req.Notes, req.Reason, actor.ID)
+	if err != nil {
+		return err
+	}
+	fb.DoctorNotes = req.Notes
+	if h.Embeddings != nil {
+		h.Embeddings.Index(*fb)
+	}
+
+	payload := fiber.Map{"feedback_id": fb.ID, "revision": rev.Revision, "reason": req.Reason}
+	if _, err := h.Audit.LogEvent("FEEDBACK_AMENDED", fb.PatientID, payload, actor); err != nil {
+		logging.Request(c).Warn("audit event failed", "error", err)
+	}
+	return h.withRevisions(c, fb)
+}
+
+func (h *FeedbackHandler) withRevisions(c *fiber.Ctx, fb *models.Feedback) error {
+	revisions, err := h.Feedback.Revisions(fb.ID)
+	if err != nil {
+		return err
+	}
+	// Snapshots from before they were validated may not decode; the
+	// feedback is still worth returning
+	risks, err := fb.Risks()
+	if err != nil {
+		logging.Request(c).Warn("unreadable risk profile", "feedback_id", fb.ID, "error", err)
+	}
+	return c.JSON(fiber.Map{"feedback": fb, "risks": risks, "revisions": revisions})
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"healthcare-backend/pkg/adapters"
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/logging"
+	"healthcare-backend/pkg/middleware"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/repositories"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// FHIRContentType is the media type of FHIR R4 JSON responses
+const FHIRContentType = "application/fhir+json"
+
+// FHIRHandler serves stored records as FHIR R4 resources
+type FHIRHandler struct {
+	Patients    repositories.PatientRepository
+	Assessments repositories.AssessmentRepository
+	Feedback    repositories.FeedbackRepository
+	Audit       *services.AuditService
+	FHIR        *adapters.FHIRAdapter
+	Intake      *PatientHandler // Creates and assesses ingested patients
+}
+
+func NewFHIRHandler(intake *PatientHandler, feedback repositories.FeedbackRepository, fhir *adapters.FHIRAdapter) *FHIRHandler {
+	return &FHIRHandler{Patients: intake.Patients, Assessments: intake.Assessments, Feedback: feedback, Audit: intake.Audit, FHIR: fhir, Intake: intake}
+}
+
+// fhirJSON sends a FHIR resource with the FHIR media type
+func fhirJSON(c *fiber.Ctx, status int, resource any) error {
+	if err := c.Status(status).JSON(resource); err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, FHIRContentType)
+	return nil
+}
+
+// operationOutcome is a FHIR error response with a single issue
+func operationOutcome(c *fiber.Ctx, status int, code, diagnostics string) error {
+	return fhirJSON(c, status, fiber.Map{
+		"resourceType": "OperationOutcome",
+		"issue": []fiber.Map{
+			{"severity": "error", "code": code, "diagnostics": diagnostics},
+		},
+	})
+}
+
+// patient loads the patient named by the :id parameter, or sends the
+// OperationOutcome explaining why not
+func (h *FHIRHandler) patient(c *fiber.Ctx) (*models.PatientData, error) {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return nil, operationOutcome(c, 400, "invalid", "Invalid patient ID")
+	}
+	patient, err := h.Patients.GetByID(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, operationOutcome(c, 404, "not-found", "Patient not found")
+	}
+	if err != nil {
+		return nil, operationOutcome(c, 500, "exception", "Failed to load patient")
+	}
+	return patient, nil
+}
+
+// GetObservations returns the patient's vital signs as a searchset Bundle of
+// LOINC-coded Observations
+// GET /api/fhir/Patient/:id/Observation
+func (h *FHIRHandler) GetObservations(c *fiber.Ctx) error {
+	patient, err := h.patient(c)
+	if patient == nil {
+		return err
+	}
+	return fhirJSON(c, 200, h.FHIR.ToFHIRObservations(*patient))
+}
+
+// GetEverything returns the patient's full record, assessments and doctor
+// feedback included, as a collection Bundle
+// GET /api/fhir/Patient/:id/$everything
+func (h *FHIRHandler) GetEverything(c *fiber.Ctx) error {
+	patient, err := h.patient(c)
+	if patient == nil {
+		return err
+	}
+	assessments, err := h.Assessments.ListForPatient(patient.ID)
+	if err != nil {
+		return operationOutcome(c, 500, "exception", "Failed to load assessments")
+	}
+	feedback, err := h.Feedback.ListForPatient(patient.ID)
+	if err != nil {
+		return operationOutcome(c, 500, "exception", "Failed to load feedback")
+	}
+	return fhirJSON(c, 200, h.FHIR.ToFHIREverything(*patient, assessments, feedback))
+}
+
+// ImportBundle creates a patient from a FHIR bundle of a Patient and its
+// Observations. The mapped record is validated like any new patient; parts
+// of the bundle that couldn't be used come back as warnings. With
+// assess=true the patient is also assessed, and saved only if that works.
+// POST /api/fhir/import?assess=false
+func (h *FHIRHandler) ImportBundle(c *fiber.Ctx) error {
+	var bundle map[string]interface{}
+	if err := json.Unmarshal(c.Body(), &bundle); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Body must be a FHIR Bundle in JSON"})
+	}
+	patient, warnings, err := h.FHIR.ParseFHIRBundle(bundle)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error(), "warnings": warnings})
+	}
+	if errs := middleware.ValidateStruct(patient); len(errs) > 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "errors": errs, "warnings": warnings})
+	}
+	patient.Source = services.PatientSourceFHIR
+
+	resp := models.FHIRImportResponse{Warnings: warnings}
+	if c.QueryBool("assess") {
+		result, err := h.Intake.assessRow(c.UserContext(), batchRow{patient: patient}, auditctx.Actor(c), true)
+		if err != nil {
+			return c.Status(503).JSON(fiber.Map{"error": err.Error(), "warnings": warnings})
+		}
+		resp.Assessment = result
+		patient.ID = result.PatientID
+	} else {
+		if err := h.Patients.Create(&patient); err != nil {
+			return err
+		}
+		h.Intake.auditPatient(c, "PATIENT_CREATED", patient.ID, patient)
+		h.Intake.WS.PublishQueueEvent("created", patient)
+	}
+	resp.PatientID = patient.ID
+	resp.Patient = patient
+	return c.Status(201).JSON(resp)
+}
+
+// fhirDateRange reads FHIR date search parameters (date=ge2024-01-01,
+// date=lt2024-02-01T00:00:00Z) into a time range. A date covers its whole
+// day and a dateTime its whole second; a value without a prefix means eq.
+func fhirDateRange(values []string) (from, to *time.Time, err error) {
+	for _, v := range values {
+		prefix := "eq"
+		if len(v) > 2 && v[0] >= 'a' && v[0] <= 'z' {
+			prefix, v = v[:2], v[2:]
+		}
+		start, span := time.Time{}, 24*time.Hour
+		if start, err = time.Parse(time.DateOnly, v); err != nil {
+			if start, err = time.Parse(time.RFC3339, v); err != nil {
+				return nil, nil, fmt.Errorf("date %q must be a date or a dateTime with a zone", v)
+			}
+			span = time.Second
+		}
+		end := start.Add(span)
+		switch prefix {
+		case "eq":
+			from, to = &start, &end
+		case "ge":
+			from = &start
+		case "gt":
+			from = &end
+		case "lt":
+			to = &start
+		case "le":
+			to = &end
+		default:
+			return nil, nil, fmt.Errorf("date prefix %q is not supported; use eq, ge, gt, le or lt", prefix)
+		}
+	}
+	return from, to, nil
+}
+
+// SearchAuditEvents returns a page of the audit chain as a searchset Bundle
+// of AuditEvents, oldest first. The page is streamed an entry at a time;
+// the next link carries the last entry's ID as _cursor, so paging stays
+// stable while the chain grows.
+// GET /api/fhir/AuditEvent?date=ge2024-01-01&_count=100&_cursor=
+func (h *FHIRHandler) SearchAuditEvents(c *fiber.Ctx) error {
+	var dates []string
+	for _, d := range c.Context().QueryArgs().PeekMulti("date") {
+		dates = append(dates, string(d))
+	}
+	from, to, err := fhirDateRange(dates)
+	if err != nil {
+		return operationOutcome(c, 400, "invalid", err.Error())
+	}
+	count := c.QueryInt("_count", DefaultAuditPageSize)
+	if count < 1 || count > MaxAuditPageSize {
+		return operationOutcome(c, 400, "invalid", fmt.Sprintf("_count must be between 1 and %d", MaxAuditPageSize))
+	}
+	cursor, err := strconv.ParseUint(c.Query("_cursor", "0"), 10, 64)
+	if err != nil {
+		return operationOutcome(c, 400, "invalid", "_cursor must be the ID from a next link")
+	}
+	page := services.AuditRange{From: from, To: to, After: uint(cursor), Limit: count}
+
+	// The first page records the export; later pages are part of it
+	if cursor == 0 {
+		if _, err := h.Audit.LogEvent("AUDIT_EXPORTED", 0, fiber.Map{"format": "fhir", "date": dates}, auditctx.Actor(c)); err != nil {
+			return operationOutcome(c, 500, "exception", "Failed to record the export")
+		}
+	}
+	total, err := h.Audit.CountInRange(page)
+	if err != nil {
+		return operationOutcome(c, 500, "exception", "Failed to read the audit log")
+	}
+
+	link := func(after uint) string {
+		base := h.FHIR.BaseURL
+		if base == "" {
+			base = c.BaseURL() + "/api/fhir"
+		}
+		query := url.Values{"date": dates, "_count": {strconv.Itoa(count)}}
+		if after > 0 {
+			query.Set("_cursor", strconv.FormatUint(uint64(after), 10))
+		}
+		return base + "/AuditEvent?" + query.Encode()
+	}
+	c.Set(fiber.HeaderContentType, FHIRContentType)
+	logger := logging.Request(c) // c is recycled before the body is written
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		enc := json.NewEncoder(w)
+		fmt.Fprintf(w, `{"resourceType":"Bundle","type":"searchset","timestamp":%q,"total":%d,"entry":[`,
+			time.Now().UTC().Format(time.RFC3339), total)
+		n := 0
+		last, more, err := h.Audit.EachInRange(page, func(entry models.AuditLog) error {
+			if n > 0 {
+				w.WriteByte(',')
+			}
+			n++
+			return enc.Encode(h.FHIR.BundleEntry("searchset", h.FHIR.ToFHIRAuditEvent(entry)))
+		})
+		if err != nil {
+			// Headers are gone by now; leave the JSON unterminated so the
+			// client can't mistake a cut page for a whole one
+			logger.Warn("fhir AuditEvent page stopped", "entries", n, "error", err)
+			w.Flush()
+			return
+		}
+		links := []fiber.Map{{"relation": "self", "url": link(page.After)}}
+		if more {
+			links = append(links, fiber.Map{"relation": "next", "url": link(last)})
+		}
+		w.WriteString(`],"link":`)
+		enc.Encode(links)
+		w.WriteString("}")
+		w.Flush()
+	})
+	return nil
+}
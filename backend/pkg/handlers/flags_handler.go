@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"errors"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/flags"
+	"healthcare-backend/pkg/logging"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type FlagsHandler struct {
+	Flags *flags.Store
+	Audit *services.AuditService
+}
+
+func NewFlagsHandler(store *flags.Store, audit *services.AuditService) *FlagsHandler {
+	return &FlagsHandler{Flags: store, Audit: audit}
+}
+
+// GetFlags lists every feature flag and its current state
+// GET /api/admin/flags
+func (h *FlagsHandler) GetFlags(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"flags": h.Flags.List()})
+}
+
+// SetFlag turns a flag on or off, or rolls it out to a percentage of callers
+// PUT /api/admin/flags/:name
+func (h *FlagsHandler) SetFlag(c *fiber.Ctx) error {
+	var f flags.Flag
+	if err := c.BodyParser(&f); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	f.Name = c.Params("name")
+
+	previous, err := h.Flags.Set(f, auditctx.Actor(c))
+	if errors.Is(err, flags.ErrUnknownFlag) {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	logging.Request(c).Info("feature flag changed", "flag", f.Name, "from", previous.State, "to", f.State, "percent", f.Percent)
+	if _, err := h.Audit.LogEvent("FEATURE_FLAG_CHANGED", 0, fiber.Map{"from": previous, "to": f}, auditctx.Actor(c)); err != nil {
+		logging.Request(c).Warn("audit event failed", "error", err)
+	}
+	return c.JSON(fiber.Map{"flag": f, "previous": previous})
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Dependency states reported by /health/ready
+const (
+	DependencyHealthy   = "healthy"
+	DependencyDegraded  = "degraded"  // Down, but the backend serves without it
+	DependencyUnhealthy = "unhealthy" // Down, and required
+)
+
+// DefaultCheckTimeout bounds each dependency check, so one hung dependency
+// can't outlast the orchestrator's probe timeout
+const DefaultCheckTimeout = 2 * time.Second
+
+// Dependency is one thing /health/ready checks
+type Dependency struct {
+	Name     string
+	Required bool // Down fails readiness; otherwise it only degrades it
+	Check    func(ctx context.Context) error
+}
+
+// HealthHandler answers the readiness probe
+type HealthHandler struct {
+	Dependencies []Dependency
+	ShuttingDown func() bool
+	CheckTimeout time.Duration
+}
+
+func NewHealthHandler(shuttingDown func() bool, deps ...Dependency) *HealthHandler {
+	return &HealthHandler{Dependencies: deps, ShuttingDown: shuttingDown, CheckTimeout: DefaultCheckTimeout}
+}
+
+// Ready checks every dependency at once and reports each one's state and
+// latency. Answers 503 when a required dependency is down or shutdown has
+// started; optional ones being down only make the status "degraded".
+// GET /health/ready
+func (h *HealthHandler) Ready(c *fiber.Ctx) error {
+	states := make([]string, len(h.Dependencies))
+	latencies := make([]int64, len(h.Dependencies))
+	parent := c.UserContext()
+	var wg sync.WaitGroup
+	for i, dep := range h.Dependencies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(parent, h.CheckTimeout)
+			defer cancel()
+			start := time.Now()
+			err := dep.Check(ctx)
+			latencies[i] = time.Since(start).Milliseconds()
+			switch {
+			case err == nil:
+				states[i] = DependencyHealthy
+			case dep.Required:
+				states[i] = DependencyUnhealthy
+			default:
+				states[i] = DependencyDegraded
+			}
+		}()
+	}
+	wg.Wait()
+
+	status := "ready"
+	dependencies := fiber.Map{}
+	latencyMs := fiber.Map{}
+	for i, dep := range h.Dependencies {
+		dependencies[dep.Name] = states[i]
+		latencyMs[dep.Name] = latencies[i]
+		if states[i] == DependencyDegraded && status == "ready" {
+			status = "degraded"
+		}
+	}
+	for _, state := range states {
+		if state == DependencyUnhealthy {
+			status = "not ready"
+		}
+	}
+	if h.ShuttingDown != nil && h.ShuttingDown() {
+		status = "not ready"
+	}
+
+	code := fiber.StatusOK
+	if status == "not ready" {
+		code = fiber.StatusServiceUnavailable
+	}
+	return c.Status(code).JSON(fiber.Map{
+		"status":       status,
+		"dependencies": dependencies,
+		"latency_ms":   latencyMs,
+	})
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"healthcare-backend/pkg/adapters/hl7"
+	"healthcare-backend/pkg/logging"
+	"healthcare-backend/pkg/middleware"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// HL7Handler ingests HL7 v2 messages relayed by the hospital interface engine
+type HL7Handler struct {
+	Intake *PatientHandler // Creates and announces ingested patients
+}
+
+func NewHL7Handler(intake *PatientHandler) *HL7Handler {
+	return &HL7Handler{Intake: intake}
+}
+
+// Ingest creates a patient from an ADT^A01 or ORU^R01 and answers with an
+// ACK in the message's own encoding. The new patient's ID is in MSA-3 and
+// the X-Patient-ID header. A malformed or invalid message gets an AE whose
+// ERR segments point at the failing segment and field; a message type we
+// don't ingest gets an AR. Observations that couldn't be used are listed as
+// warning ERRs on the AA.
+// POST /api/hl7/ingest
+func (h *HL7Handler) Ingest(c *fiber.Ctx) error {
+	msg, err := hl7.Parse(string(c.Body()))
+	if err != nil {
+		return hl7Ack(c, 400, nil, hl7.AckError, "Malformed message", issuesOf(err), nil)
+	}
+	patient, warnings, err := hl7.ReadPatient(msg)
+	if err != nil {
+		return hl7Ack(c, 400, msg, hl7.AckCode(err), "Message could not be processed", issuesOf(err), warnings)
+	}
+	if errs := middleware.ValidateStruct(patient); len(errs) > 0 {
+		issues := make([]hl7.Issue, len(errs))
+		for i, e := range errs {
+			issues[i] = hl7.Issue{Code: hl7.CodeApplicationError, Text: fmt.Sprintf("%s: %s", e.Field, e.Message)}
+		}
+		return hl7Ack(c, 400, msg, hl7.AckError, "Patient record failed validation", issues, warnings)
+	}
+	patient.Source = services.PatientSourceHL7
+
+	if err := h.Intake.Patients.Create(&patient); err != nil {
+		logging.Request(c).Warn("failed to store hl7 patient", "error", err)
+		return hl7Ack(c, 500, msg, hl7.AckError, "Patient could not be stored",
+			[]hl7.Issue{{Code: hl7.CodeApplicationError, Text: "database error"}}, warnings)
+	}
+	h.Intake.auditPatient(c, "PATIENT_CREATED", patient.ID, patient)
+	h.Intake.WS.PublishQueueEvent("created", patient)
+
+	c.Set("X-Patient-ID", strconv.FormatUint(uint64(patient.ID), 10))
+	return hl7Ack(c, 201, msg, hl7.AckAccept, fmt.Sprintf("Patient %d created", patient.ID), nil, warnings)
+}
+
+// hl7Ack sends an ACK with the HL7 v2 media type
+func hl7Ack(c *fiber.Ctx, status int, msg *hl7.Message, ack, text string, errs, warnings []hl7.Issue) error {
+	c.Set(fiber.HeaderContentType, hl7.ContentType)
+	return c.Status(status).SendString(hl7.ACK(msg, ack, text, errs, warnings))
+}
+
+// issuesOf is the located issue behind a parse or read error
+func issuesOf(err error) []hl7.Issue {
+	var issue *hl7.Issue
+	if errors.As(err, &issue) {
+		return []hl7.Issue{*issue}
+	}
+	return []hl7.Issue{{Code: hl7.CodeApplicationError, Text: err.Error()}}
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"errors"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/logging"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ImportHandler serves CSV imports of patients from other systems
+type ImportHandler struct {
+	Import *services.ImportService
+	WS     *WebSocketHandler
+}
+
+func NewImportHandler(imports *services.ImportService, ws *WebSocketHandler) *ImportHandler {
+	return &ImportHandler{Import: imports, WS: ws}
+}
+
+// ImportPatients creates a patient per valid row of the CSV uploaded as
+// "file" and reports the rows it skipped. With dry_run nothing is created.
+// POST /api/patients/import?dry_run=true (multipart/form-data)
+func (h *ImportHandler) ImportPatients(c *fiber.Ctx) error {
+	header, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "No CSV file uploaded (use field name 'file')"})
+	}
+	file, err := header.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	dryRun := c.QueryBool("dry_run")
+	summary, err := h.Import.Import(file, auditctx.Actor(c), dryRun, func(p models.PatientData) {
+		h.WS.PublishQueueEvent("created", p)
+	})
+	if errors.Is(err, services.ErrImportHeader) || errors.Is(err, services.ErrImportNoColumns) {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	} else if err != nil {
+		return err
+	}
+
+	logging.Request(c).Info("patient import finished", "file", header.Filename, "dry_run", dryRun, "created", summary.Created, "skipped", summary.Skipped)
+	return c.JSON(summary)
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/logging"
+	"healthcare-backend/pkg/middleware"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// IntakeHandler serves account-less kiosk intake
+type IntakeHandler struct {
+	Intake *services.IntakeService
+	Audit  *services.AuditService
+	WS     *WebSocketHandler
+}
+
+func NewIntakeHandler(intake *services.IntakeService, audit *services.AuditService, ws *WebSocketHandler) *IntakeHandler {
+	return &IntakeHandler{Intake: intake, Audit: audit, WS: ws}
+}
+
+// CreateToken issues a single-use intake token for a clinic's kiosk
+// POST /api/admin/intake-tokens
+func (h *IntakeHandler) CreateToken(c *fiber.Ctx) error {
+	var req struct {
+		Clinic string `json:"clinic"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	token, record, err := h.Intake.Issue(req.Clinic)
+	if errors.Is(err, services.ErrClinicRequired) {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	} else if err != nil {
+		return err
+	}
+
+	if _, err := h.Audit.LogEvent("INTAKE_TOKEN_CREATED", 0, fiber.Map{
+		"token_id": record.ID, "clinic": record.Clinic, "expires_at": record.ExpiresAt,
+	}, auditctx.Actor(c)); err != nil {
+		logging.Request(c).Warn("audit event failed", "event", "INTAKE_TOKEN_CREATED", "error", err)
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"token":      token,
+		"id":         record.ID,
+		"clinic":     record.Clinic,
+		"expires_at": record.ExpiresAt,
+	})
+}
+
+// Submit creates a self-reported patient and burns the token.
+// Only KioskIntakeRequest fields are accepted; anything else is rejected
+// rather than ignored, so a kiosk can't set vitals or history.
+// POST /api/intake/:token
+func (h *IntakeHandler) Submit(c *fiber.Ctx) error {
+	var req models.KioskIntakeRequest
+	dec := json.NewDecoder(bytes.NewReader(c.Body()))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid intake form: " + err.Error()})
+	}
+	// Validate before redeeming so a typo doesn't burn the token
+	if errs := middleware.ValidateStruct(req); len(errs) > 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "errors": errs})
+	}
+
+	patient, record, err := h.Intake.Redeem(c.Params("token"), req)
+	switch {
+	case errors.Is(err, services.ErrIntakeTokenInvalid):
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrIntakeTokenUsed):
+		return c.Status(410).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return err
+	}
+
+	if _, err := h.Audit.LogEvent("INTAKE_TOKEN_USED", patient.ID, fiber.Map{
+		"token_id": record.ID, "clinic": record.Clinic,
+	}, auditctx.Kiosk(record.Clinic).InRequest(c.UserContext())); err != nil {
+		logging.Request(c).Warn("audit event failed", "event", "INTAKE_TOKEN_USED", "error", err)
+	}
+	logging.Request(c).Info("kiosk intake created patient", "clinic", record.Clinic, "patient_id", patient.ID)
+
+	// Shows up in the triage queue; assessment waits for a clinician
+	h.WS.PublishQueueEvent("created", patient)
+
+	return c.Status(201).JSON(fiber.Map{
+		"patient_id": patient.ID,
+		"status":     "awaiting_assessment",
+	})
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/logging"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type OverrideHandler struct {
+	Overrides *services.OverrideService
+	Audit     *services.AuditService
+}
+
+func NewOverrideHandler(overrides *services.OverrideService, audit *services.AuditService) *OverrideHandler {
+	return &OverrideHandler{Overrides: overrides, Audit: audit}
+}
+
+// GetReasons lists selectable override reasons (all of them with ?all=true)
+// GET /api/overrides/reasons
+func (h *OverrideHandler) GetReasons(c *fiber.Ctx) error {
+	reasons, err := h.Overrides.Reasons(c.QueryBool("all", false))
+	if err != nil {
+		return err
+	}
+	return c.JSON(reasons)
+}
+
+// SaveReason creates or edits a taxonomy entry
+// PUT /api/admin/overrides/reasons/:code
+func (h *OverrideHandler) SaveReason(c *fiber.Ctx) error {
+	var req struct {
+		Label        string `json:"label"`
+		RequiresText bool   `json:"requires_text"`
+		Active       *bool  `json:"active"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	active := true
+	if req.Active != nil {
+		active = *req.Active
+	}
+	saved, previous, err := h.Overrides.SaveReason(models.OverrideReason{
+		Code:         c.Params("code"),
+		Label:        req.Label,
+		RequiresText: req.RequiresText,
+		Active:       active,
+	})
+	if errors.Is(err, services.ErrInvalidOverride) {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := h.Audit.LogEvent("OVERRIDE_TAXONOMY_CHANGED", 0, fiber.Map{"from": previous, "to": saved}, auditctx.Actor(c)); err != nil {
+		logging.Request(c).Warn("audit event failed", "error", err)
+	}
+	return c.JSON(saved)
+}
+
+// GetReport aggregates overrides per reason, model and month
+// GET /api/admin/overrides/report?months=12
+func (h *OverrideHandler) GetReport(c *fiber.Ctx) error {
+	months := c.QueryInt("months", 12)
+	if months < 1 || months > 120 {
+		return c.Status(400).JSON(fiber.Map{"error": "months must be between 1 and 120"})
+	}
+
+	report, err := h.Overrides.Report(time.Now().AddDate(0, -months, 0))
+	if err != nil {
+		return err
+	}
+	return c.JSON(report)
+}
+
+// GetCompliance aggregates overrides for Article 14 reporting, as JSON or a
+// CSV of the groups
+// GET /api/compliance/overrides?from=2026-01-01&to=2026-03-31&group_by=reason|model|doctor&recent=10&format=json|csv
+func (h *OverrideHandler) GetCompliance(c *fiber.Ctx) error {
+	q := services.OverrideQuery{GroupBy: c.Query("group_by", services.OverrideGroupReason), Recent: c.QueryInt("recent", services.DefaultOverrideRecent)}
+	format := c.Query("format", "json")
+	if format != "json" && format != "csv" {
+		return c.Status(400).JSON(fiber.Map{"error": "format must be json or csv"})
+	}
+	if q.Recent < 0 || q.Recent > services.MaxOverrideRecent {
+		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("recent must be between 0 and %d", services.MaxOverrideRecent)})
+	}
+	var err error
+	if q.From, err = exportTime(c.Query("from"), false); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "from must be a date (2006-01-02) or an RFC 3339 time"})
+	}
+	if q.To, err = exportTime(c.Query("to"), true); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "to must be a date (2006-01-02) or an RFC 3339 time"})
+	}
+
+	analytics, err := h.Overrides.Analytics(q)
+	if errors.Is(err, services.ErrInvalidOverride) {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	} else if err != nil {
+		return err
+	}
+	if format == "json" {
+		return c.JSON(analytics)
+	}
+
+	filename := fmt.Sprintf("overrides-by-%s-%s.csv", q.GroupBy, time.Now().UTC().Format("20060102-150405"))
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w := csv.NewWriter(c)
+	w.Write([]string{q.GroupBy, "count", "percent_of_assessments"})
+	for _, g := range analytics.Groups {
+		w.Write([]string{g.Key, strconv.FormatInt(g.Count, 10), strconv.FormatFloat(g.Percent, 'f', 2, 64)})
+	}
+	w.Write([]string{"total", strconv.FormatInt(analytics.Total, 10), strconv.FormatFloat(analytics.Percent, 'f', 2, 64)})
+	w.Flush()
+	return w.Error()
+}
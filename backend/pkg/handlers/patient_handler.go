@@ -1,42 +1,325 @@
 package handlers
 
 import (
-	"log"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"math"
 	"math/rand"
+	"sync"
 	"time"
 
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/jobs"
+	"healthcare-backend/pkg/locks"
+	"healthcare-backend/pkg/logging"
+	"healthcare-backend/pkg/middleware"
 	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/privacy"
+	"healthcare-backend/pkg/repositories"
 	"healthcare-backend/pkg/services"
+	"healthcare-backend/pkg/terminology"
+	"healthcare-backend/pkg/tracing"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 )
 
 type PatientHandler struct {
 	DB         *gorm.DB
+	Patients    repositories.PatientRepository
+	Assessments repositories.AssessmentRepository
 	RAG        *services.RAGService
 	Prediction *services.PredictionService
 	WS         *WebSocketHandler
 	Audit      *services.AuditService
+	Redactor   *privacy.Redactor
+	Terms      *terminology.Mapper
+
+	// Latency budget for assessments; components over it finish on Completions
+	Budget      time.Duration
+	Completions *jobs.Runner
+	lateMu      sync.Mutex // Serializes late updates to a stored assessment
+
+	// Emergency alerts go to the assigned provider first, then on-call providers
+	Providers *services.ProviderService
+
+	// Times alerts against clinic working hours and logs them; nil broadcasts directly
+	Notifications *services.NotificationService
+
+	// Configurable emergency triggers; nil evaluates the defaults
+	EmergencyRules *services.EmergencyRuleService
+
+	// POSTs emergency alerts to external receivers; nil sends none. Links in
+	// them start with PublicURL.
+	Webhooks  *services.WebhookNotifier
+	PublicURL string
+
+	// Serializes assessments of the same stored patient; nil disables
+	Locks *locks.PatientLocks
+
+	// Diagnosis SSE streams close after StreamMax and send a heartbeat comment
+	// every StreamHeartbeat; zero uses the defaults
+	StreamMax       time.Duration
+	StreamHeartbeat time.Duration
+
+	// Longest ?wait a diagnosis long-poll may ask for; zero uses the default
+	MaxDiagnosisWait time.Duration
+
+	// Rows of a batch assessment scored at once; zero uses the default
+	BatchWorkers int
 }
 
-func NewPatientHandler(db *gorm.DB, rag *services.RAGService, pred *services.PredictionService, ws *WebSocketHandler, audit *services.AuditService) *PatientHandler {
+func NewPatientHandler(db *gorm.DB, patients repositories.PatientRepository, assessments repositories.AssessmentRepository, rag *services.RAGService, pred *services.PredictionService, ws *WebSocketHandler, audit *services.AuditService, redactor *privacy.Redactor, terms *terminology.Mapper) *PatientHandler {
 	return &PatientHandler{
 		DB:         db,
+		Patients:    patients,
+		Assessments: assessments,
 		RAG:        rag,
 		Prediction: pred,
 		WS:         ws,
 		Audit:      audit,
+		Redactor:   redactor,
+		Terms:      terms,
 	}
 }
 
-// Get All Patients for the Sidebar Queue
+// Patient list page sizes
+const (
+	DefaultPatientPageSize = 50
+	MaxPatientPageSize     = 200
+)
+
+// GetPatients returns one page of the sidebar queue, newest first
+// GET /api/patients?page=1&limit=50&gender=Female&min_age=40&max_age=65&high_risk=true
 func (h *PatientHandler) GetPatients(c *fiber.Ctx) error {
-	var patients []models.PatientData
-	h.DB.Order("created_at desc").Find(&patients)
-	return c.JSON(patients)
+	filter := repositories.PatientFilter{
+		Gender:   c.Query("gender"),
+		MinAge:   c.QueryInt("min_age", 0),
+		MaxAge:   c.QueryInt("max_age", 0),
+		HighRisk: c.QueryBool("high_risk", false),
+		Page:     c.QueryInt("page", 1),
+		Limit:    c.QueryInt("limit", DefaultPatientPageSize),
+	}
+	if filter.Page < 1 {
+		return c.Status(400).JSON(fiber.Map{"error": "page must be 1 or more"})
+	}
+	if filter.Limit < 1 || filter.Limit > MaxPatientPageSize {
+		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("limit must be between 1 and %d", MaxPatientPageSize)})
+	}
+	if filter.MinAge < 0 || filter.MaxAge < 0 || (filter.MaxAge > 0 && filter.MaxAge < filter.MinAge) {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid age range"})
+	}
+
+	scope, err := h.scope(c)
+	if err != nil {
+		return err
+	}
+	if !scope.All {
+		filter.AssignedTo = &scope.ProviderID
+	}
+
+	page, err := h.Patients.List(filter)
+	if err != nil {
+		return err
+	}
+	return c.JSON(page)
+}
+
+// GetPatient returns one patient with their responsible provider
+// GET /api/patients/:id
+func (h *PatientHandler) GetPatient(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid patient ID"})
+	}
+
+	var patient models.PatientData
+	if err := h.DB.Preload("AssignedProvider").First(&patient, id).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Patient not found"})
+	}
+	return c.JSON(patient)
+}
+
+// UpdatePatient changes the intake fields a request provides; omitted
+// fields keep their stored values
+// PUT /api/patients/:id
+func (h *PatientHandler) UpdatePatient(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid patient ID"})
+	}
+
+	existing, err := h.Patients.GetByID(uint(id))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Patient not found"})
+	}
+
+	intake, invalid := parsePatientIntake(c)
+	if invalid != nil {
+		return c.Status(400).JSON(invalid)
+	}
+	if errs := middleware.ValidateStructExcept(intake, intake.Omitted()...); len(errs) > 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "errors": errs})
+	}
+
+	patient := *existing
+	intake.ApplyTo(&patient)
+	patient.AssignedProvider = nil // AssignedProviderID is changed only via /assign
+	changes, err := diffFields(models.IntakeOf(*existing), models.IntakeOf(patient))
+	if err != nil {
+		return err
+	}
+	if err := h.Patients.Update(&patient); err != nil {
+		return err
+	}
+
+	h.auditPatient(c, "PATIENT_UPDATED", patient.ID, fiber.Map{"patient": patient, "changes": changes})
+	h.WS.PublishQueueEvent("updated", patient)
+	return c.JSON(patient)
+}
+
+// DeletePatient soft-deletes a patient and drops their cached prediction and
+// diagnosis. Past assessments keep their snapshots.
+// DELETE /api/patients/:id
+func (h *PatientHandler) DeletePatient(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid patient ID"})
+	}
+
+	existing, err := h.Patients.GetByID(uint(id))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Patient not found"})
+	}
+	if err := h.Patients.Delete(existing.ID); err != nil {
+		return err
+	}
+	h.Prediction.CancelDiagnosis(existing.ID)
+	h.Prediction.InvalidatePrediction(*existing)
+
+	h.auditPatient(c, "PATIENT_DELETED", existing.ID, *existing)
+	h.WS.PublishQueueEvent("deleted", *existing)
+	return c.SendStatus(204)
+}
+
+// parsePatientIntake reads a patient body, accepting localized numbers like
+// "24,5". A non-nil map is the 400 body; unreadable numbers are listed per
+// field instead of being stored as zero.
+func parsePatientIntake(c *fiber.Ctx) (models.PatientIntake, fiber.Map) {
+	var intake models.PatientIntake
+	if err := c.BodyParser(&intake); err != nil {
+		var numErrs models.NumberFieldErrors
+		if errors.As(err, &numErrs) {
+			return intake, fiber.Map{"success": false, "errors": numErrs}
+		}
+		return intake, fiber.Map{"error": "Invalid input"}
+	}
+	return intake, nil
+}
+
+// GetLockStats reports per-patient assessment lock contention
+// GET /api/admin/assessment-locks
+func (h *PatientHandler) GetLockStats(c *fiber.Ctx) error {
+	if h.Locks == nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Assessment locking is disabled"})
+	}
+	return c.JSON(h.Locks.Stats())
+}
+
+// auditPatient records a patient record change against the requesting actor
+func (h *PatientHandler) auditPatient(c *fiber.Ctx, event string, patientID uint, payload any) {
+	if _, err := h.Audit.LogEvent(event, patientID, payload, auditctx.Actor(c)); err != nil {
+		logging.Request(c).Warn("audit event failed", "event", event, "error", err)
+	}
+}
+
+// scope is the set of patients the caller may see; everyone sees every
+// patient when Providers is unset
+func (h *PatientHandler) scope(c *fiber.Ctx) (services.PatientScope, error) {
+	if h.Providers == nil {
+		return services.PatientScope{All: true}, nil
+	}
+	return h.Providers.ScopeFor(auditctx.Actor(c))
+}
+
+// RequireAccess guards routes whose :id is a patient ID. Doctors get 403
+// for patients not assigned to them, and the attempt is audited as
+// ACCESS_DENIED against the patient's hash.
+func (h *PatientHandler) RequireAccess(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Next() // The handler answers 400
+	}
+	scope, err := h.scope(c)
+	if err != nil {
+		return err
+	}
+	if scope.All {
+		return c.Next()
+	}
+
+	patient, err := h.Patients.GetByID(uint(id))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if patient != nil && scope.Allows(*patient) {
+		return c.Next()
+	}
+	// Unknown patients get 403 too, so IDs can't be probed
+	return h.denyAccess(c, uint(id), nil)
+}
+
+// RequireAssessmentAccess guards routes whose :id, and :other when present,
+// are assessment IDs, applying RequireAccess to the assessed patient.
+// Unknown assessments get 403 as well.
+func (h *PatientHandler) RequireAssessmentAccess(c *fiber.Ctx) error {
+	scope, err := h.scope(c)
+	if err != nil {
+		return err
+	}
+	if scope.All {
+		return c.Next()
+	}
+
+	for _, param := range []string{"id", "other"} {
+		if c.Params(param) == "" {
+			continue
+		}
+		id, err := c.ParamsInt(param)
+		if err != nil || id <= 0 {
+			return c.Next() // The handler answers 400
+		}
+		assessment, err := h.Assessments.GetByID(uint(id))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return h.denyAccess(c, 0, fiber.Map{"assessment_id": id})
+		} else if err != nil {
+			return err
+		}
+		patient, err := h.Patients.GetByID(assessment.PatientID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if patient == nil || !scope.Allows(*patient) {
+			return h.denyAccess(c, assessment.PatientID, fiber.Map{"assessment_id": id})
+		}
+	}
+	return c.Next()
+}
+
+// denyAccess answers 403 for a patient outside the caller's scope and
+// audits the attempt as ACCESS_DENIED
+func (h *PatientHandler) denyAccess(c *fiber.Ctx, patientID uint, detail fiber.Map) error {
+	payload := fiber.Map{"method": c.Method(), "route": c.Route().Path}
+	for k, v := range detail {
+		payload[k] = v
+	}
+	h.auditPatient(c, "ACCESS_DENIED", patientID, payload)
+	return c.Status(403).JSON(fiber.Map{"error": "Patient is not assigned to you"})
 }
 
 // Get Default Form Values for New Patient Intake (Randomized)
@@ -84,29 +367,166 @@ func (h *PatientHandler) GetDefaults(c *fiber.Ctx) error {
 func (h *PatientHandler) AssessPatient(c *fiber.Ctx) error {
 	totalStart := time.Now()
 
-	var patient models.PatientData
-	if err := c.BodyParser(&patient); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+	intake, invalid := parsePatientIntake(c)
+	if invalid != nil {
+		return c.Status(400).JSON(invalid)
+	}
+	if errs := middleware.ValidateStruct(intake); len(errs) > 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "errors": errs})
 	}
 
-	// Save Patient Record
-	patient.ID = 0 // Force new record
+	// Save Patient Record with its audit entry. A doctor's patient goes to
+	// their linked provider, so they can read it back; otherwise the
+	// provider is assigned via /assign.
+	patient := intake.NewPatient()
+	scope, err := h.scope(c)
+	if err != nil {
+		return err
+	}
+	if !scope.All && scope.ProviderID != 0 {
+		patient.AssignedProviderID = &scope.ProviderID
+	}
 	dbStart := time.Now()
-	h.DB.Create(&patient)
-	log.Printf("⏱️ DB Write: %v", time.Since(dbStart))
+	_, span := tracing.Start(c.UserContext(), "db.patient.create")
+	err = h.Audit.InTransaction(func(tx *gorm.DB, audit *services.AuditTx) error {
+		if err := repositories.NewPatientRepository(tx).Create(&patient); err != nil {
+			return err
+		}
+		_, err := audit.LogEvent("PATIENT_CREATED", patient.ID, patient, auditctx.Actor(c))
+		return err
+	})
+	tracing.End(span, err)
+	if err != nil {
+		return err
+	}
+	logging.Request(c).Info("patient saved", "patient_id", patient.ID, "duration_ms", time.Since(dbStart).Milliseconds())
+	h.WS.PublishQueueEvent("created", patient)
 
-	// RAG Enhancement: Semantic Search for Similar Cases
-	ragStart := time.Now()
-	contextStr := h.RAG.FindSimilarCases(patient)
-	log.Printf("⏱️ RAG Semantic Search: %v", time.Since(ragStart))
+	return h.runAssessment(c, patient, totalStart, nil)
+}
+
+// AssessExisting runs the assessment for a stored patient, e.g. once a
+// clinician has completed a kiosk intake with vitals
+// POST /api/patients/:id/assess
+func (h *PatientHandler) AssessExisting(c *fiber.Ctx) error {
+	totalStart := time.Now()
 
-	// 2. Call ML Predict
-	risks, err := h.Prediction.PredictRisks(patient)
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid patient ID"})
+	}
+	patient, err := h.Patients.GetByID(uint(id))
 	if err != nil {
-		return c.Status(503).JSON(fiber.Map{"error": "ML Service Offline"})
+		return c.Status(404).JSON(fiber.Map{"error": "Patient not found"})
 	}
+	if errs := middleware.ValidateStruct(*patient); len(errs) > 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "errors": errs})
+	}
+
+	lease, conflict, err := h.acquireLease(c, patient.ID)
+	if conflict != nil {
+		return c.Status(409).JSON(conflict)
+	} else if err != nil {
+		return err
+	}
+	defer lease.Release()
+
+	return h.runAssessment(c, *patient, totalStart, lease)
+}
+
+// Reassess re-runs the models on a stored patient after merging an optional
+// partial body of changed vitals into the record, instead of creating a
+// duplicate patient the way /api/assess does
+// POST /api/patients/:id/reassess
+func (h *PatientHandler) Reassess(c *fiber.Ctx) error {
+	totalStart := time.Now()
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid patient ID"})
+	}
+	existing, err := h.Patients.GetByID(uint(id))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Patient not found"})
+	}
+
+	patient := *existing
+	if len(c.Body()) > 0 {
+		intake, invalid := parsePatientIntake(c)
+		if invalid != nil {
+			return c.Status(400).JSON(invalid)
+		}
+		if errs := middleware.ValidateStructExcept(intake, intake.Omitted()...); len(errs) > 0 {
+			return c.Status(400).JSON(fiber.Map{"success": false, "errors": errs})
+		}
+		intake.ApplyTo(&patient)
+		patient.AssignedProvider = nil // AssignedProviderID is changed only via /assign
+	}
+	if errs := middleware.ValidateStruct(patient); len(errs) > 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "errors": errs})
+	}
+
+	lease, conflict, err := h.acquireLease(c, patient.ID)
+	if conflict != nil {
+		return c.Status(409).JSON(conflict)
+	} else if err != nil {
+		return err
+	}
+	defer lease.Release()
+
+	changes, err := diffFields(models.IntakeOf(*existing), models.IntakeOf(patient))
+	if err != nil {
+		return err
+	}
+	if len(changes) > 0 {
+		if err := h.Patients.Update(&patient); err != nil {
+			return err
+		}
+		h.WS.PublishQueueEvent("updated", patient)
+	}
+	// The cache key only hashes some vitals, so drop both entries to force a live run
+	h.Prediction.InvalidatePrediction(*existing)
+	h.Prediction.InvalidatePrediction(patient)
+
+	h.auditPatient(c, "PATIENT_REASSESSED", patient.ID, fiber.Map{"changes": changes})
+	return h.runAssessment(c, patient, totalStart, lease)
+}
+
+// acquireLease takes the patient's assessment lock. A non-nil map is the 409
+// body naming the assessment in flight; the lease is nil when locking is off.
+func (h *PatientHandler) acquireLease(c *fiber.Ctx, patientID uint) (*locks.Lease, fiber.Map, error) {
+	// 🔐 Two concurrent assessments would interleave cache writes and start
+	// duplicate LLM jobs with different contexts
+	if h.Locks == nil {
+		return nil, nil, nil
+	}
+	lease, err := h.Locks.Acquire(c.UserContext(), patientID)
+	var locked *locks.LockedError
+	if errors.As(err, &locked) {
+		resp := fiber.Map{"error": "An assessment for this patient is already in progress"}
+		if locked.AssessmentID != 0 {
+			resp["in_flight_assessment_id"] = locked.AssessmentID
+		}
+		return nil, resp, nil
+	}
+	return lease, nil, err
+}
+
+// runAssessment calls the ML services for a saved patient and persists the result
+func (h *PatientHandler) runAssessment(c *fiber.Ctx, patient models.PatientData, totalStart time.Time, lease *locks.Lease) error {
+	// The request's context outlives it, carrying its ID into late components and the LLM task
+	ctx := c.UserContext()
+
+	// RAG Enhancement: Semantic Search for Similar Cases
+	ragStart := time.Now()
+	_, span := tracing.Start(ctx, "rag.search", trace.WithAttributes(attribute.Int64("patient.id", int64(patient.ID))))
+	contextStr, _ := h.RAG.FindSimilarCases(patient, patient.ID)
+	span.End()
+	logging.Request(c).Info("rag search finished", "patient_id", patient.ID, "duration_ms", time.Since(ragStart).Milliseconds())
+
+	// 🔒 Privacy: strip PHI from the RAG context before it reaches the LLM
+	contextStr, contextRecord := h.redactPastContext(ctx, patient.ID, contextStr)
 
-	// 2.5 Urgency Prediction
 	symptoms := []string{}
 	if patient.Symptoms != "" {
 		parts := strings.Split(patient.Symptoms, ",")
@@ -114,13 +534,26 @@ func (h *PatientHandler) AssessPatient(c *fiber.Ctx) error {
 			symptoms = append(symptoms, strings.TrimSpace(p))
 		}
 	}
-	urgency, _ := h.Prediction.PredictUrgency(symptoms, patient)
+	codedSymptoms, unmappedSymptoms := h.Terms.Map(symptoms)
 
-	// Emergency Logic
-	isEmergency := risks.HeartRisk > 85 || patient.SystolicBP > 180 || (urgency != nil && urgency.UrgencyLevel >= 4)
+	// 2. ML Predict, Urgency and Medications (within the latency budget, if set)
+	run := h.runComponents(ctx, patient, symptoms, totalStart)
+	if run.report == nil && run.risks == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "ML Service Offline"})
+	}
+	risks, urgency, medAnalysis := run.risks, run.urgency, run.meds
+	risksPending := risks == nil
+	if risksPending {
+		risks = &models.PredictResponse{}
+	}
 
-	// Logic for Medications
-	medAnalysis := h.Prediction.CheckMedications(patient.Medications)
+	// Emergency Logic
+	var knownRisks *models.PredictResponse
+	if !risksPending {
+		knownRisks = risks
+	}
+	emergencyReasons := h.emergencyReasons(patient, knownRisks, urgency)
+	isEmergency := len(emergencyReasons) > 0
 
 	// Logic for Model Precisions
 	precisions := []models.ModelPrecision{}
@@ -128,17 +561,102 @@ func (h *PatientHandler) AssessPatient(c *fiber.Ctx) error {
 		precisions = append(precisions, models.ModelPrecision{ModelName: name, Confidence: conf})
 	}
 
+	// 🧊 Freeze the submitted patient data so later edits can't rewrite history
+	var assessment models.Assessment
+	if err := assessment.SetPatientSnapshot(patient); err != nil {
+		return err
+	}
+
 	// 📜 Audit: Log AI Prediction
-	auditBlock, _ := h.Audit.LogEvent("AI_PREDICTION", patient.ID, risks, "system")
+	auditPayload := fiber.Map{
+		"risks":                 risks,
+		"patient_snapshot_hash": assessment.SnapshotHash,
+	}
+	if run.report != nil && len(run.report.Pending) > 0 {
+		auditPayload["pending"] = run.report.Pending // Logged again as AI_PREDICTION_COMPLETED
+	}
+	// A distinct event so fallback results stand out in the ledger
+	predictionEvent := "AI_PREDICTION"
+	if risks.Source == models.PredictionSourceRuleBased {
+		predictionEvent = "FALLBACK_PREDICTION"
+	}
+	explanations := []models.RiskExplanation{}
+	factors := []models.ModelFactors{}
+	if !risksPending {
+		explanations = h.Prediction.Summarizer.Summarize(patient, *risks)
+		factors = services.RankFactors(patient, *risks)
+		if err := assessment.SetExplanations(explanations); err != nil {
+			return err
+		}
+	}
 
-	// 3. Start LLM Diagnosis ASYNC (non-blocking)
-	h.Prediction.StartAsyncDiagnosis(patient.ID, models.DiagnosisRequest{
-		Patient:     patient,
-		RiskScores:  *risks,
-		PastContext: contextStr,
-	}, h.WS.BroadcastDiagnosis)
+	// 3. The LLM diagnosis runs ASYNC (non-blocking) once risks are known
+	llmPatient := patient
+	llmPatient.Name = "" // Identity never leaves the backend
+	priority := models.DiagnosisPriorityRoutine
+	if isEmergency {
+		priority = models.DiagnosisPriorityEmergency
+	}
+	diagnosisRequest := func(risks *models.PredictResponse) models.DiagnosisRequest {
+		return models.DiagnosisRequest{
+			Patient:     llmPatient,
+			RiskScores:  *risks,
+			PastContext: contextStr,
+			Priority:    priority,
+			RequestID:   auditctx.RequestIDFrom(ctx),
+		}
+	}
 
-	log.Printf("⏱️ FAST RESPONSE (no LLM wait): %v", time.Since(totalStart))
+	// 💾 Persist the assessment (per-model precisions feed drift monitoring)
+	// with its audit entry and, through the outbox, its diagnosis task
+	var auditBlock models.AuditLog
+	var queued *models.DiagnosisRequest
+	outboxed := !risksPending && h.Prediction.Outbox != nil
+	err := h.Audit.InTransaction(func(tx *gorm.DB, audit *services.AuditTx) error {
+		var err error
+		if auditBlock, err = audit.LogEvent(predictionEvent, patient.ID, auditPayload, auditctx.System.InRequest(ctx)); err != nil {
+			return err
+		}
+		fillAssessment(&assessment, patient.ID, risks, isEmergency, auditBlock.CurrentHash)
+		if risksPending {
+			assessment.RuleBased = false // Not a fallback, just not known yet
+		}
+		if err := repositories.NewAssessmentRepository(tx).Create(&assessment); err != nil {
+			return err
+		}
+		if outboxed {
+			sent, err := h.Prediction.QueueDiagnosisTx(ctx, tx, patient.ID, diagnosisRequest(risks))
+			if err != nil {
+				return err
+			}
+			queued = &sent
+		}
+		return nil
+	})
+	if err != nil {
+		if outboxed {
+			h.Prediction.CancelDiagnosis(patient.ID) // No task will run to clear "pending"
+		}
+		return err
+	}
+	if queued != nil {
+		h.Prediction.Outbox.Notify()
+	}
+	lease.SetAssessment(assessment.ID)
+	if isEmergency {
+		h.notifyEmergency(ctx, patient, assessment.ID, knownRisks, emergencyReasons, urgency)
+	}
+
+	h.recordComponents(ctx, &assessment, patient, run, func(risks *models.PredictResponse) {
+		sent := queued
+		if sent == nil {
+			req := h.Prediction.StartAsyncDiagnosisCtx(ctx, patient.ID, diagnosisRequest(risks), h.WS.BroadcastDiagnosis)
+			sent = &req
+		}
+		h.recordDiagnosisRequest(ctx, contextRecord, assessment.ID, *sent)
+	})
+
+	logging.Request(c).Info("assessment answered", "patient_id", patient.ID, "assessment_id", assessment.ID, "duration_ms", time.Since(totalStart).Milliseconds())
 
 	var urgencyVal models.UrgencyResponse
 	if urgency != nil {
@@ -146,30 +664,204 @@ func (h *PatientHandler) AssessPatient(c *fiber.Ctx) error {
 	}
 
 	return c.JSON(models.FullAssessmentResponse{
-		ID:              patient.ID,
-		Risks:           *risks,
-		Urgency:         urgencyVal,
-		Diagnosis:       "", // Will be fetched via polling
-		DiagnosisStatus: "pending",
-		Emergency:       isEmergency,
-		Patient:         patient,
-		Medications:     medAnalysis,
-		ModelPrecisions: precisions,
-		AuditHash:       auditBlock.CurrentHash,
+		ID:               patient.ID,
+		AssessmentID:     assessment.ID,
+		Risks:            *risks,
+		Urgency:          urgencyVal,
+		Diagnosis:        "", // Will be fetched via polling
+		DiagnosisStatus:  "pending",
+		Emergency:        isEmergency,
+		EmergencyReasons: emergencyReasons,
+		RequiresReview:   len(medAnalysis.AllergyConflicts) > 0, // A prescribing question, not an emergency
+		Patient:          patient,
+		Medications:      medAnalysis,
+		ModelPrecisions:  precisions,
+		AuditHash:        auditBlock.CurrentHash,
+		CodedSymptoms:    codedSymptoms,
+		UnmappedSymptoms: unmappedSymptoms,
+		Budget:           run.report,
+		PredictionSource: risks.Source,
+
+		ExplanationSummaries: explanations,
+		Explanations:         factors,
 	})
 }
 
-// Poll for Diagnosis (async result)
+// fillAssessment maps a prediction onto its persisted record
+func fillAssessment(assessment *models.Assessment, patientID uint, risks *models.PredictResponse, emergency bool, auditHash string) {
+	ruleBased := services.IsRuleBased(risks)
+	assessment.PatientID = patientID
+	assessment.HeartRisk = risks.HeartRisk
+	assessment.DiabetesRisk = risks.DiabetesRisk
+	assessment.StrokeRisk = risks.StrokeRisk
+	assessment.KidneyRisk = risks.KidneyRisk
+	assessment.GeneralHealthScore = risks.GeneralHealthScore
+	assessment.ClinicalConfidence = risks.ClinicalConfidence
+	assessment.RuleBased = ruleBased
+	assessment.MLBackend = risks.Backend
+	assessment.Emergency = emergency
+	assessment.AuditHash = auditHash
+	assessment.SetContributions(risks.Explanations) // Floats from JSON always marshal
+	for name, conf := range risks.ModelPrecisions {
+		assessment.Precisions = append(assessment.Precisions, models.AssessmentPrecision{
+			ModelName:  name,
+			Confidence: conf,
+			RuleBased:  ruleBased,
+		})
+	}
+}
+
+// emergencyReasons describes the emergency rules the assessment triggers.
+// Pass nil for results that aren't known yet.
+func (h *PatientHandler) emergencyReasons(p models.PatientData, risks *models.PredictResponse, urgency *models.UrgencyResponse) []string {
+	if h.EmergencyRules == nil {
+		return services.EvaluateEmergencyRules(services.DefaultEmergencyRules, p, risks, urgency)
+	}
+	return h.EmergencyRules.Evaluate(p, risks, urgency)
+}
+
+// notifyEmergency alerts the patient's escalation targets over WebSocket and
+// any configured webhooks. risks is nil while they're pending.
+func (h *PatientHandler) notifyEmergency(ctx context.Context, patient models.PatientData, assessmentID uint, risks *models.PredictResponse, reasons []string, urgency *models.UrgencyResponse) {
+	alert := fiber.Map{
+		"type":              services.NotificationEmergency,
+		"patient_id":        patient.ID,
+		"assessment_id":     assessmentID,
+		"emergency_reasons": reasons,
+	}
+	if urgency != nil && urgency.GoldenHourMinutes != nil {
+		alert["golden_hour_minutes"] = *urgency.GoldenHourMinutes
+	}
+
+	if h.Webhooks != nil {
+		webhook := models.EmergencyWebhook{
+			Event:          "emergency",
+			PatientID:      patient.ID,
+			AssessmentID:   assessmentID,
+			TriggeredRules: reasons,
+			RiskScores:     map[string]float64{},
+			Timestamp:      time.Now().UTC(),
+			Link:           fmt.Sprintf("%s/api/assessments/%d/report", h.PublicURL, assessmentID),
+		}
+		if risks != nil {
+			webhook.RiskScores["heart_risk_score"] = risks.HeartRisk
+			webhook.RiskScores["diabetes_risk_score"] = risks.DiabetesRisk
+			webhook.RiskScores["stroke_risk_score"] = risks.StrokeRisk
+			webhook.RiskScores["kidney_risk_score"] = risks.KidneyRisk
+		}
+		h.Webhooks.Send(webhook) // Never waits on the receivers
+	}
+
+	if h.Notifications != nil {
+		entry, err := h.Notifications.Notify(services.Notification{
+			Kind:     services.NotificationEmergency,
+			Critical: true,
+			Patient:  patient,
+			Payload:  alert,
+		})
+		if err != nil {
+			logging.FromContext(ctx).Warn("emergency notification failed", "patient_id", patient.ID, "error", err)
+			return
+		}
+		logging.FromContext(ctx).Warn("emergency alert", "patient_id", patient.ID, "decision", entry.Decision, "recipients", len(entry.Recipients), "reason", entry.Reason)
+		return
+	}
+
+	recipients := []models.Provider{}
+	if h.Providers != nil {
+		targets, err := h.Providers.EscalationTargets(patient)
+		if err != nil {
+			logging.FromContext(ctx).Warn("could not resolve escalation targets", "patient_id", patient.ID, "error", err)
+		} else {
+			recipients = targets
+		}
+	}
+
+	alert["recipients"] = recipients
+	h.WS.BroadcastAll(alert)
+	logging.FromContext(ctx).Warn("emergency alert", "patient_id", patient.ID, "decision", "sent", "recipients", len(recipients))
+}
+
+// redactPastContext runs the RAG context through the PHI redactor and records what was sent
+func (h *PatientHandler) redactPastContext(ctx context.Context, patientID uint, contextStr string) (string, *models.DiagnosisContext) {
+	names, err := h.Patients.KnownNames()
+	if err != nil {
+		logging.FromContext(ctx).Warn("phi redaction: could not load patient names", "error", err)
+	}
+
+	res := h.Redactor.Redact(contextStr, names)
+	if res.Redactions > 0 {
+		logging.FromContext(ctx).Info("phi redacted from diagnosis context", "patient_id", patientID, "mode", res.Mode, "redactions", res.Redactions)
+	}
+
+	record := models.DiagnosisContext{
+		PatientID:      patientID,
+		PastContext:    res.Text,
+		PrivacyMode:    string(res.Mode),
+		RedactionCount: res.Redactions,
+		Blocked:        res.Blocked,
+	}
+	if err := h.DB.Create(&record).Error; err != nil {
+		logging.FromContext(ctx).Warn("failed to record diagnosis context", "patient_id", patientID, "error", err)
+		return res.Text, nil
+	}
+
+	return res.Text, &record
+}
+
+// recordDiagnosisRequest adds what was queued for the LLM to the context
+// record, for GET /api/admin/diagnosis/:id/prompt
+func (h *PatientHandler) recordDiagnosisRequest(ctx context.Context, record *models.DiagnosisContext, assessmentID uint, req models.DiagnosisRequest) {
+	if record == nil {
+		return
+	}
+	payload, err := json.Marshal(req)
+	if err != nil {
+		logging.FromContext(ctx).Warn("failed to encode diagnosis request", "error", err)
+		return
+	}
+	record.AssessmentID = assessmentID
+	record.PromptVersion = services.DiagnosisPromptVersion
+	record.SystemPrompt = services.DiagnosisSystemPrompt
+	record.Prompt = services.RenderDiagnosisPrompt(req)
+	record.LLMModel = services.DiagnosisLLMModel
+	record.Request = string(payload)
+	record.MLEndpoint = h.Prediction.MLServiceURL + "/diagnose"
+	record.Generation = req.Generation
+	if err := h.DB.Save(record).Error; err != nil {
+		logging.FromContext(ctx).Warn("failed to record diagnosis request", "assessment_id", assessmentID, "error", err)
+	}
+}
+
+// Poll for Diagnosis (async result). With ?wait=30s a pending diagnosis is
+// long-polled: the request blocks until it finishes or the wait runs out.
+// A ready diagnosis comes with its ICD-10 suggestions.
+// GET /api/diagnosis/:id?wait=30s
 func (h *PatientHandler) GetDiagnosis(c *fiber.Ctx) error {
 	id, err := c.ParamsInt("id")
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid patient ID"})
 	}
+	wait, err := h.diagnosisWait(c.Query("wait"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
 
-	diagnosis, status := h.Prediction.Cache.Get(uint(id))
+	d := h.Prediction.Cache.Lookup(uint(id))
+	if wait > 0 && d.Status == "pending" {
+		diagnosis, status := h.awaitDiagnosis(c, uint(id), wait)
+		// The cache is written before the update is announced, codes included
+		if d = h.Prediction.Cache.Lookup(uint(id)); d.Diagnosis != diagnosis || d.Status != status {
+			d = models.CachedDiagnosis{Diagnosis: diagnosis, Status: status}
+		}
+	}
+	if d.ICD10Suggestions == nil {
+		d.ICD10Suggestions = []models.ICD10Suggestion{}
+	}
 	return c.JSON(fiber.Map{
-		"id":        id,
-		"diagnosis": diagnosis,
-		"status":    status,
+		"id":                id,
+		"diagnosis":         d.Diagnosis,
+		"status":            d.Status,
+		"icd10_suggestions": d.ICD10Suggestions,
 	})
 }
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"errors"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/logging"
+	"healthcare-backend/pkg/middleware"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ProviderHandler serves the clinician directory and patient assignment
+type ProviderHandler struct {
+	Providers *services.ProviderService
+	Audit     *services.AuditService
+	WS        *WebSocketHandler
+}
+
+func NewProviderHandler(providers *services.ProviderService, audit *services.AuditService, ws *WebSocketHandler) *ProviderHandler {
+	return &ProviderHandler{Providers: providers, Audit: audit, WS: ws}
+}
+
+// GetProviders lists active providers; ?on_call=true narrows to those on call
+// GET /api/providers
+func (h *ProviderHandler) GetProviders(c *fiber.Ctx) error {
+	providers, err := h.Providers.List(c.QueryBool("on_call"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(providers)
+}
+
+// CreateProvider adds a clinician to the directory
+// POST /api/admin/providers
+func (h *ProviderHandler) CreateProvider(c *fiber.Ctx) error {
+	var p models.Provider
+	if err := c.BodyParser(&p); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if errs := middleware.ValidateStruct(p); len(errs) > 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "errors": errs})
+	}
+	if err := h.Providers.Create(&p); err != nil {
+		return err
+	}
+
+	if _, err := h.Audit.LogEvent("PROVIDER_CREATED", 0, p, auditctx.Actor(c)); err != nil {
+		logging.Request(c).Warn("audit event failed", "event", "PROVIDER_CREATED", "error", err)
+	}
+	return c.Status(201).JSON(p)
+}
+
+// UpdateProvider changes a provider's on-call or active status
+// PUT /api/admin/providers/:id
+func (h *ProviderHandler) UpdateProvider(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid provider ID"})
+	}
+	var req struct {
+		OnCall *bool `json:"on_call"`
+		Active *bool `json:"active"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	current, err := h.Providers.Get(uint(id))
+	if errors.Is(err, services.ErrProviderNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	} else if err != nil {
+		return err
+	}
+	onCall, active := current.OnCall, current.Active
+	if req.OnCall != nil {
+		onCall = *req.OnCall
+	}
+	if req.Active != nil {
+		active = *req.Active
+	}
+
+	updated, err := h.Providers.SetStatus(current.ID, onCall, active)
+	if err != nil {
+		return err
+	}
+	if _, err := h.Audit.LogEvent("PROVIDER_UPDATED", 0, fiber.Map{"from": current, "to": updated}, auditctx.Actor(c)); err != nil {
+		logging.Request(c).Warn("audit event failed", "event", "PROVIDER_UPDATED", "error", err)
+	}
+	return c.JSON(updated)
+}
+
+// AssignPatient makes a provider responsible for a patient
+// POST /api/patients/:id/assign
+func (h *ProviderHandler) AssignPatient(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid patient ID"})
+	}
+	var req struct {
+		ProviderID uint `json:"provider_id"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.ProviderID == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "provider_id is required"})
+	}
+
+	patient, previous, err := h.Providers.Assign(uint(id), req.ProviderID)
+	switch {
+	case errors.Is(err, services.ErrPatientNotFound), errors.Is(err, services.ErrProviderNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return err
+	}
+
+	// Recorded against the patient so it shows up in their history
+	event := "PATIENT_ASSIGNED"
+	if previous != nil {
+		event = "PATIENT_REASSIGNED"
+	}
+	if _, err := h.Audit.LogEvent(event, patient.ID, fiber.Map{
+		"from_provider_id": previous, "to_provider_id": req.ProviderID,
+	}, auditctx.Actor(c)); err != nil {
+		logging.Request(c).Warn("audit event failed", "event", event, "error", err)
+	}
+	logging.Request(c).Info("patient assigned", "patient_id", patient.ID, "provider_id", req.ProviderID)
+
+	h.WS.PublishQueueEvent("updated", *patient)
+	return c.JSON(patient)
+}
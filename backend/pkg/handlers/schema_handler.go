@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"healthcare-backend/pkg/schema"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SchemaHandler serves the API contract generated from the model structs
+type SchemaHandler struct {
+	Doc schema.Document
+}
+
+func NewSchemaHandler() *SchemaHandler {
+	return &SchemaHandler{Doc: schema.API()}
+}
+
+func (h *SchemaHandler) GetSchema(c *fiber.Ctx) error {
+	return c.JSON(h.Doc)
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type SearchHandler struct {
+	Search    *services.SearchService
+	Audit     *services.AuditService
+	Providers *services.ProviderService // Scopes doctors to their patients; nil searches everyone
+}
+
+func NewSearchHandler(search *services.SearchService, audit *services.AuditService, providers *services.ProviderService) *SearchHandler {
+	return &SearchHandler{Search: search, Audit: audit, Providers: providers}
+}
+
+// GetSearch finds the doctor notes and diagnoses containing every word of q,
+// best match first, each with a snippet and the patient and assessment it
+// belongs to. Doctors only see their own patients. Every search is audited
+// as NOTES_SEARCHED before results leave.
+// GET /api/search?q=chest+pain&source=feedback|diagnosis&limit=20
+func (h *SearchHandler) GetSearch(c *fiber.Ctx) error {
+	q := services.SearchQuery{Text: c.Query("q"), Limit: c.QueryInt("limit", services.DefaultSearchLimit)}
+	if q.Limit < 1 || q.Limit > services.MaxSearchLimit {
+		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("limit must be between 1 and %d", services.MaxSearchLimit)})
+	}
+	if source := c.Query("source"); source != "" {
+		for _, s := range strings.Split(source, ",") {
+			if s != models.SearchFeedback && s != models.SearchDiagnosis {
+				return c.Status(400).JSON(fiber.Map{"error": "source must be feedback or diagnosis"})
+			}
+			q.Sources = append(q.Sources, s)
+		}
+	}
+	if h.Providers != nil {
+		scope, err := h.Providers.ScopeFor(auditctx.Actor(c))
+		if err != nil {
+			return err
+		}
+		q.Scope = &scope
+	}
+
+	hits, err := h.Search.Search(q)
+	switch {
+	case errors.Is(err, services.ErrEmptySearch):
+		return c.Status(400).JSON(fiber.Map{"error": "q must contain at least one word"})
+	case errors.Is(err, services.ErrSearchIndexMissing):
+		return c.Status(503).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return err
+	}
+	if _, err := h.Audit.LogEvent("NOTES_SEARCHED", 0, fiber.Map{"q": q.Text, "sources": q.Sources, "hits": len(hits)}, auditctx.Actor(c)); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to record the search"})
+	}
+	return c.JSON(fiber.Map{"query": q.Text, "hits": hits})
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"healthcare-backend/pkg/logging"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SelfTestHandler lets on-call confirm the whole pipeline after a deploy
+type SelfTestHandler struct {
+	SelfTest *services.SelfTestService
+}
+
+func NewSelfTestHandler(selfTest *services.SelfTestService) *SelfTestHandler {
+	return &SelfTestHandler{SelfTest: selfTest}
+}
+
+// Run pushes a synthetic patient through every stage and reports each one.
+// Answers 503 when any stage failed so deploy scripts can check the status alone.
+// POST /api/admin/selftest
+func (h *SelfTestHandler) Run(c *fiber.Ctx) error {
+	report := h.SelfTest.Run(c.UserContext())
+	if !report.Passed {
+		logging.Request(c).Error("self-test failed", "duration_ms", report.DurationMs)
+		return c.Status(503).JSON(report)
+	}
+	logging.Request(c).Info("self-test passed", "duration_ms", report.DurationMs)
+	return c.JSON(report)
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"fmt"
+
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type TimelineHandler struct {
+	Timeline *services.TimelineService
+}
+
+func NewTimelineHandler(timeline *services.TimelineService) *TimelineHandler {
+	return &TimelineHandler{Timeline: timeline}
+}
+
+// GetTimeline returns a page of what happened to the patient, newest
+// first: assessments with their headline risks, feedback, overrides and
+// record changes from the audit chain, each as {type, timestamp, summary,
+// ref_id}. The next page starts at next_cursor.
+// GET /api/patients/:id/timeline?from=2025-01-01&to=2025-01-31&limit=50&cursor=
+func (h *TimelineHandler) GetTimeline(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return fiber.NewError(400, "Invalid patient ID")
+	}
+	q := services.TimelineQuery{PatientID: uint(id), Limit: c.QueryInt("limit", services.DefaultTimelinePageSize)}
+	if q.Limit < 1 || q.Limit > services.MaxTimelinePageSize {
+		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("limit must be between 1 and %d", services.MaxTimelinePageSize)})
+	}
+	if q.From, err = exportTime(c.Query("from"), false); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "from must be a date (2006-01-02) or an RFC 3339 time"})
+	}
+	if q.To, err = exportTime(c.Query("to"), true); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "to must be a date (2006-01-02) or an RFC 3339 time"})
+	}
+	if cursor := c.Query("cursor"); cursor != "" {
+		if q.After, err = services.ParseTimelineCursor(cursor); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "cursor must be the next_cursor of a previous page"})
+		}
+	}
+
+	page, err := h.Timeline.Timeline(q)
+	if err != nil {
+		return err
+	}
+	return c.JSON(page)
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"healthcare-backend/pkg/logging"
+	"healthcare-backend/pkg/middleware"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type UrgencyHandler struct {
+	PredictionService *services.PredictionService
+}
+
+func NewUrgencyHandler(ps *services.PredictionService) *UrgencyHandler {
+	return &UrgencyHandler{PredictionService: ps}
+}
+
+// Predict triages symptoms plus vitals without creating a patient. When the
+// ML service is down it answers with a degraded level 0 instead of an error.
+// POST /api/urgency/predict
+func (h *UrgencyHandler) Predict(c *fiber.Ctx) error {
+	var req models.UrgencyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if errs := middleware.ValidateStruct(req); len(errs) > 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "errors": errs})
+	}
+
+	result, err := h.PredictionService.PredictUrgencyCtx(c.UserContext(), req.Symptoms, req.Patient())
+	if err != nil {
+		logging.Request(c).Warn("urgency prediction unavailable, returning degraded result", "error", err)
+		result = services.DegradedUrgency()
+	}
+	return c.JSON(result)
+}
@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"fmt"
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/logging"
 	"healthcare-backend/pkg/models"
 	"healthcare-backend/pkg/services"
 	"os"
@@ -14,11 +16,13 @@ import (
 
 type VitalsHandler struct {
 	predictionService *services.PredictionService
+	uploads           *services.UploadService
 }
 
-func NewVitalsHandler(p *services.PredictionService) *VitalsHandler {
+func NewVitalsHandler(p *services.PredictionService, uploads *services.UploadService) *VitalsHandler {
 	return &VitalsHandler{
 		predictionService: p,
+		uploads:           uploads,
 	}
 }
 
@@ -41,7 +45,7 @@ func (h *VitalsHandler) Analyze(c *fiber.Ctx) error {
 	}
 
 	// 3. Save to shared uploads volume
-	uploadDir := "/app/uploads"
+	uploadDir := h.uploads.Dir
 	// Ensure dir exists (though docker should handle it)
 	_ = os.MkdirAll(uploadDir, 0755)
 
@@ -52,11 +56,19 @@ func (h *VitalsHandler) Analyze(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusInternalServerError, "Failed to save upload: "+err.Error())
 	}
 
-	// 4. Call Service Proxy (Pass the path as seen inside the container)
-	result, err := h.predictionService.AnalyzeVitals(savePath)
+	// Registered uploads are removed by the cleanup job once analysis finishes
+	upload, err := h.uploads.Register(savePath, "vitals", auditctx.Actor(c).ID)
 	if err != nil {
-		// Cleanup on failure
 		_ = os.Remove(savePath)
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to register upload: "+err.Error())
+	}
+
+	// 4. Call Service Proxy (Pass the path as seen inside the container)
+	result, err := h.predictionService.AnalyzeVitalsCtx(c.UserContext(), savePath)
+	if ferr := h.uploads.Finish(upload.ID, err == nil); ferr != nil {
+		logging.Request(c).Warn("failed to record upload outcome", "upload_id", upload.ID, "error", ferr)
+	}
+	if err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, "Analysis failed: "+err.Error())
 	}
 
@@ -65,3 +77,13 @@ func (h *VitalsHandler) Analyze(c *fiber.Ctx) error {
 		Data:    result,
 	})
 }
+
+// GetUploadUsage reports uploads disk usage and files missing from either side of the registry
+// GET /api/admin/uploads
+func (h *VitalsHandler) GetUploadUsage(c *fiber.Ctx) error {
+	usage, err := h.uploads.Usage()
+	if err != nil {
+		return err
+	}
+	return c.JSON(usage)
+}
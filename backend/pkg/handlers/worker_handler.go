@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"healthcare-backend/pkg/workers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// WorkerHandler reports on the background workers
+type WorkerHandler struct {
+	LLM *workers.LLMWorker
+}
+
+func NewWorkerHandler(llm *workers.LLMWorker) *WorkerHandler {
+	return &WorkerHandler{LLM: llm}
+}
+
+// GetStatus returns the LLM pool's concurrency, queue depth and in-flight count
+// GET /api/workers/status
+func (h *WorkerHandler) GetStatus(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"llm": h.LLM.Status()})
+}
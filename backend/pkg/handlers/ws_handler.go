@@ -3,42 +3,80 @@ package handlers
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"healthcare-backend/pkg/cache"
+	"healthcare-backend/pkg/models"
 	"github.com/gofiber/contrib/websocket"
 )
 
 type WebSocketHandler struct {
 	mu          sync.RWMutex
-	conns       map[*websocket.Conn]bool
+	conns       map[*websocket.Conn]*wsClient
 	patientSubs map[uint][]*websocket.Conn // patientID -> list of connections
+	queueSubs   map[*websocket.Conn]bool   // clients following the patient queue
+	terminalAt  map[uint]time.Time         // When each patient's diagnosis last finished
+
+	queueSeq atomic.Int64 // Highest queue sequence seen; used when Redis is down
+
+	// Diagnosis streams (SSE) per patient, fed by BroadcastDiagnosis
+	watchers map[uint]map[chan models.DiagnosisUpdate]struct{}
+
+	// Cleanup policy, applied by Sweep (see ws_sweep.go)
+	Now         func() time.Time // Injectable clock
+	MaxIdle     time.Duration    // Close connections with no messages or pongs for this long
+	TerminalTTL time.Duration    // Unsubscribe this long after a patient's diagnosis finished
+	sweeps      wsSweepStats
 }
 
+const (
+	queueChannel = "queue_updates"
+	queueSeqKey  = "queue:seq"
+)
+
 func NewWebSocketHandler() *WebSocketHandler {
 	return &WebSocketHandler{
-		conns:       make(map[*websocket.Conn]bool),
+		conns:       make(map[*websocket.Conn]*wsClient),
 		patientSubs: make(map[uint][]*websocket.Conn),
+		queueSubs:   make(map[*websocket.Conn]bool),
+		terminalAt:  make(map[uint]time.Time),
+		watchers:    make(map[uint]map[chan models.DiagnosisUpdate]struct{}),
+		Now:         time.Now,
+		MaxIdle:     DefaultWSMaxIdle,
+		TerminalTTL: DefaultWSTerminalTTL,
 	}
 }
 
 func (h *WebSocketHandler) HandleConnection(c *websocket.Conn) {
+	client := &wsClient{}
+	client.touch(h.Now())
+	c.SetPongHandler(func(string) error {
+		client.touch(h.Now())
+		return nil
+	})
+
 	h.mu.Lock()
-	h.conns[c] = true
+	h.conns[c] = client
 	h.mu.Unlock()
 
 	defer func() {
 		h.mu.Lock()
 		delete(h.conns, c)
+		delete(h.queueSubs, c)
 		// Clean up subscriptions
 		for id, subs := range h.patientSubs {
 			for i, sub := range subs {
 				if sub == c {
-					h.patientSubs[id] = append(subs[:i], subs[i+1:]...)
+					h.patientSubs[id] = append(subs[:i:i], subs[i+1:]...)
 					break
 				}
 			}
+			if len(h.patientSubs[id]) == 0 {
+				delete(h.patientSubs, id)
+			}
 		}
 		h.mu.Unlock()
 		c.Close()
@@ -49,6 +87,7 @@ func (h *WebSocketHandler) HandleConnection(c *websocket.Conn) {
 		if err != nil {
 			break
 		}
+		client.touch(h.Now())
 
 		var payload struct {
 			Type      string `json:"type"`
@@ -56,7 +95,7 @@ func (h *WebSocketHandler) HandleConnection(c *websocket.Conn) {
 		}
 
 		if err := json.Unmarshal(msg, &payload); err != nil {
-			log.Printf("WS error: invalid payload: %v", err)
+			slog.Debug("ws invalid payload", "error", err)
 			continue
 		}
 
@@ -64,27 +103,39 @@ func (h *WebSocketHandler) HandleConnection(c *websocket.Conn) {
 			h.mu.Lock()
 			h.patientSubs[payload.PatientID] = append(h.patientSubs[payload.PatientID], c)
 			h.mu.Unlock()
-			log.Printf("WS: Client subscribed to patient %d", payload.PatientID)
+			slog.Debug("ws client subscribed to patient", "patient_id", payload.PatientID)
+		}
+
+		if payload.Type == "subscribe_queue" {
+			h.mu.Lock()
+			h.queueSubs[c] = true
+			h.mu.Unlock()
+			slog.Debug("ws client subscribed to patient queue")
 		}
 	}
 }
 
-// StartGlobalListener listens for diagnosis updates on Redis and broadcasts them locally
+// StartGlobalListener listens for diagnosis and queue updates on Redis and broadcasts them locally
 func (h *WebSocketHandler) StartGlobalListener() {
-	pubsub := cache.RedisClient.Subscribe(context.Background(), "diagnosis_updates")
+	pubsub := cache.RedisClient.Subscribe(context.Background(), cache.DiagnosisChannel, queueChannel)
 	ch := pubsub.Channel()
 
 	go func() {
-		log.Println("🌐 WS Handler: Listening for global diagnosis updates on Redis...")
+		slog.Info("ws listening for diagnosis updates on redis")
 		for msg := range ch {
-			var update struct {
-				PatientID uint   `json:"patient_id"`
-				Diagnosis string `json:"diagnosis"`
-				Status    string `json:"status"`
+			if msg.Channel == queueChannel {
+				var event models.QueueEvent
+				if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+					slog.Warn("ws undecodable queue update from redis", "error", err)
+					continue
+				}
+				h.BroadcastQueue(event)
+				continue
 			}
 
+			var update models.DiagnosisUpdate
 			if err := json.Unmarshal([]byte(msg.Payload), &update); err != nil {
-				log.Printf("❌ WS Handler: Redis msg unmarshal error: %v", err)
+				slog.Warn("ws undecodable diagnosis update from redis", "error", err)
 				continue
 			}
 
@@ -94,11 +145,14 @@ func (h *WebSocketHandler) StartGlobalListener() {
 }
 
 func (h *WebSocketHandler) BroadcastDiagnosis(patientID uint, diagnosis string, status string) {
+	h.markDiagnosis(patientID, status)
+	h.notifyWatchers(models.DiagnosisUpdate{PatientID: patientID, Diagnosis: diagnosis, Status: status})
+
 	h.mu.RLock()
-	subs, ok := h.patientSubs[patientID]
+	subs := append([]*websocket.Conn(nil), h.patientSubs[patientID]...)
 	h.mu.RUnlock()
 
-	if !ok || len(subs) == 0 {
+	if len(subs) == 0 {
 		return
 	}
 
@@ -118,7 +172,101 @@ func (h *WebSocketHandler) BroadcastDiagnosis(patientID uint, diagnosis string,
 
 	for _, c := range subs {
 		if err := c.WriteMessage(websocket.TextMessage, payload); err != nil {
-			log.Printf("WS write error: %v", err)
+			slog.Debug("ws write failed", "error", err)
+		}
+	}
+}
+
+// BroadcastPatient pushes a message to the patient's subscribers
+func (h *WebSocketHandler) BroadcastPatient(patientID uint, msg any) {
+	h.mu.RLock()
+	subs := append([]*websocket.Conn(nil), h.patientSubs[patientID]...)
+	h.mu.RUnlock()
+	if len(subs) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		slog.Error("ws message unencodable", "error", err)
+		return
+	}
+	for _, c := range subs {
+		if err := c.WriteMessage(websocket.TextMessage, payload); err != nil {
+			slog.Debug("ws write failed", "error", err)
+		}
+	}
+}
+
+// BroadcastAll pushes a message to every connected client (dashboard-wide events)
+func (h *WebSocketHandler) BroadcastAll(msg any) {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		slog.Error("ws message unencodable", "error", err)
+		return
+	}
+
+	h.mu.RLock()
+	conns := make([]*websocket.Conn, 0, len(h.conns))
+	for c := range h.conns {
+		conns = append(conns, c)
+	}
+	h.mu.RUnlock()
+
+	for _, c := range conns {
+		if err := c.WriteMessage(websocket.TextMessage, payload); err != nil {
+			slog.Debug("ws write failed", "error", err)
+		}
+	}
+}
+
+// PublishQueueEvent announces a patient create/update/delete to every replica.
+// The sequence number comes from a shared Redis counter; without Redis the
+// event is numbered locally and delivered to this replica's clients only.
+func (h *WebSocketHandler) PublishQueueEvent(action string, patient models.PatientData) models.QueueEvent {
+	event := models.QueueEvent{
+		Type:    "queue_update",
+		Action:  action,
+		Patient: models.NewPatientQueueItem(patient),
+	}
+
+	if seq, err := cache.Incr(queueSeqKey); err == nil {
+		event.Seq = seq
+		for {
+			cur := h.queueSeq.Load()
+			if seq <= cur || h.queueSeq.CompareAndSwap(cur, seq) {
+				break
+			}
+		}
+	} else {
+		event.Seq = h.queueSeq.Add(1)
+	}
+
+	payload, _ := json.Marshal(event)
+	if err := cache.Publish(queueChannel, payload); err != nil {
+		h.BroadcastQueue(event)
+	}
+	return event
+}
+
+// BroadcastQueue sends a queue event to local clients that subscribed to the queue
+func (h *WebSocketHandler) BroadcastQueue(event models.QueueEvent) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("ws message unencodable", "error", err)
+		return
+	}
+
+	h.mu.RLock()
+	subs := make([]*websocket.Conn, 0, len(h.queueSubs))
+	for c := range h.queueSubs {
+		subs = append(subs, c)
+	}
+	h.mu.RUnlock()
+
+	for _, c := range subs {
+		if err := c.WriteMessage(websocket.TextMessage, payload); err != nil {
+			slog.Debug("ws write failed", "error", err)
 		}
 	}
 }
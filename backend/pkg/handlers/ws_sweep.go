@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	DefaultWSMaxIdle     = 30 * time.Minute
+	DefaultWSTerminalTTL = time.Hour
+
+	wsCloseGrace = 5 * time.Second // How long an idle client gets to answer our close frame
+)
+
+// wsClient tracks one connection's last sign of life
+type wsClient struct {
+	lastSeen atomic.Int64 // UnixNano of the last message or pong
+	closing  atomic.Bool
+}
+
+func (cl *wsClient) touch(t time.Time) {
+	cl.lastSeen.Store(t.UnixNano())
+}
+
+func (cl *wsClient) idleFor(now time.Time) time.Duration {
+	return now.Sub(time.Unix(0, cl.lastSeen.Load()))
+}
+
+type wsSweepStats struct {
+	sweeps     atomic.Int64
+	idleClosed atomic.Int64
+	swept      atomic.Int64
+	lastSweep  atomic.Int64 // UnixNano
+}
+
+// SweepResult is what one sweep cleaned up
+type SweepResult struct {
+	IdleClosed         int `json:"idle_closed"`
+	SweptSubscriptions int `json:"swept_subscriptions"`
+}
+
+// WSStatus is the admin view of this replica's WebSocket state
+type WSStatus struct {
+	Connections          int        `json:"connections"`
+	QueueSubscribers     int        `json:"queue_subscribers"`
+	SubscribedPatients   int        `json:"subscribed_patients"`
+	PatientSubscriptions int        `json:"patient_subscriptions"`
+	MaxIdleSeconds       int64      `json:"max_idle_seconds"`
+	TerminalTTLSeconds   int64      `json:"terminal_ttl_seconds"`
+	Sweeps               int64      `json:"sweeps"`
+	IdleClosed           int64      `json:"idle_closed"`         // Since startup
+	SweptSubscriptions   int64      `json:"swept_subscriptions"` // Since startup
+	LastSweep            *time.Time `json:"last_sweep,omitempty"`
+}
+
+// markDiagnosis remembers when a patient's diagnosis reached a final state;
+// a new pending diagnosis clears it
+func (h *WebSocketHandler) markDiagnosis(patientID uint, status string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch status {
+	case "ready", "error":
+		h.terminalAt[patientID] = h.Now()
+	default:
+		delete(h.terminalAt, patientID)
+	}
+}
+
+// Sweep closes idle connections, drops subscriptions to patients whose
+// diagnosis finished more than TerminalTTL ago, compacts the subscription
+// map and pings everyone else so live clients answer with a pong.
+func (h *WebSocketHandler) Sweep() SweepResult {
+	now := h.Now()
+	var res SweepResult
+	var idle, alive []*websocket.Conn
+
+	h.mu.Lock()
+	for c, client := range h.conns {
+		if client.closing.Load() {
+			continue
+		}
+		if client.idleFor(now) > h.MaxIdle {
+			client.closing.Store(true)
+			idle = append(idle, c)
+		} else {
+			alive = append(alive, c)
+		}
+	}
+
+	for id, at := range h.terminalAt {
+		if now.Sub(at) > h.TerminalTTL {
+			res.SweptSubscriptions += len(h.patientSubs[id])
+			delete(h.patientSubs, id)
+			delete(h.terminalAt, id)
+		}
+	}
+
+	// Go maps never shrink, so rebuild rather than leave the buckets behind
+	compacted := make(map[uint][]*websocket.Conn, len(h.patientSubs))
+	for id, subs := range h.patientSubs {
+		if len(subs) > 0 {
+			compacted[id] = subs
+		}
+	}
+	h.patientSubs = compacted
+	h.mu.Unlock()
+
+	deadline := time.Now().Add(wsCloseGrace)
+	for _, c := range idle {
+		// Politely say goodbye; the read deadline drops clients that never answer
+		msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "idle timeout")
+		if err := c.WriteControl(websocket.CloseMessage, msg, deadline); err != nil {
+			slog.Debug("ws close failed", "error", err)
+		}
+		c.SetReadDeadline(deadline)
+		res.IdleClosed++
+	}
+	for _, c := range alive {
+		c.WriteControl(websocket.PingMessage, nil, deadline)
+	}
+
+	h.sweeps.sweeps.Add(1)
+	h.sweeps.idleClosed.Add(int64(res.IdleClosed))
+	h.sweeps.swept.Add(int64(res.SweptSubscriptions))
+	h.sweeps.lastSweep.Store(now.UnixNano())
+	if res.IdleClosed > 0 || res.SweptSubscriptions > 0 {
+		slog.Info("ws sweep", "idle_closed", res.IdleClosed, "swept_subscriptions", res.SweptSubscriptions)
+	}
+	return res
+}
+
+// StartSweeper runs Sweep every interval in the background
+func (h *WebSocketHandler) StartSweeper(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			h.Sweep()
+		}
+	}()
+}
+
+// Status reports live connections, subscriptions and sweep totals
+func (h *WebSocketHandler) Status() WSStatus {
+	h.mu.RLock()
+	status := WSStatus{
+		Connections:        len(h.conns),
+		QueueSubscribers:   len(h.queueSubs),
+		SubscribedPatients: len(h.patientSubs),
+	}
+	for _, subs := range h.patientSubs {
+		status.PatientSubscriptions += len(subs)
+	}
+	h.mu.RUnlock()
+
+	status.MaxIdleSeconds = int64(h.MaxIdle / time.Second)
+	status.TerminalTTLSeconds = int64(h.TerminalTTL / time.Second)
+	status.Sweeps = h.sweeps.sweeps.Load()
+	status.IdleClosed = h.sweeps.idleClosed.Load()
+	status.SweptSubscriptions = h.sweeps.swept.Load()
+	if last := h.sweeps.lastSweep.Load(); last != 0 {
+		t := time.Unix(0, last)
+		status.LastSweep = &t
+	}
+	return status
+}
+
+// GetStatus serves Status
+// GET /api/admin/ws/status
+func (h *WebSocketHandler) GetStatus(c *fiber.Ctx) error {
+	return c.JSON(h.Status())
+}
+
+// CloseAll sends every client a going-away close frame, as on shutdown, and
+// waits until they have all disconnected or ctx is done
+func (h *WebSocketHandler) CloseAll(ctx context.Context) error {
+	deadline := time.Now().Add(wsCloseGrace)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+
+	h.mu.RLock()
+	for c, client := range h.conns {
+		client.closing.Store(true)
+		if err := c.WriteControl(websocket.CloseMessage, msg, deadline); err != nil {
+			slog.Debug("ws close failed", "error", err)
+		}
+		c.SetReadDeadline(deadline)
+	}
+	h.mu.RUnlock()
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		h.mu.RLock()
+		open := len(h.conns)
+		h.mu.RUnlock()
+		if open == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d websocket connection(s) still open: %w", open, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
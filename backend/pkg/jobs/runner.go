@@ -0,0 +1,134 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Job is a unit of background work. The context is cancelled when the runner stops.
+type Job func(ctx context.Context)
+
+// Stats reports runner throughput for admin/status endpoints
+type Stats struct {
+	Name      string `json:"name"`
+	Submitted int64  `json:"submitted"`
+	Completed int64  `json:"completed"`
+	Dropped   int64  `json:"dropped"`
+	Queued    int    `json:"queued"`
+	Workers   int    `json:"workers"`
+}
+
+// Runner executes jobs on a bounded worker pool with an optional rate cap.
+// Submission never blocks: when the queue is full the job is dropped, so
+// background work can never slow down the request path that enqueued it.
+type Runner struct {
+	name    string
+	workers int
+	queue   chan Job
+	limiter *time.Ticker
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	submitted atomic.Int64
+	completed atomic.Int64
+	dropped   atomic.Int64
+}
+
+// NewRunner starts a runner. ratePerSecond <= 0 disables the rate cap.
+func NewRunner(name string, workers, queueSize, ratePerSecond int) *Runner {
+	if workers < 1 {
+		workers = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Runner{
+		name:    name,
+		workers: workers,
+		queue:   make(chan Job, queueSize),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	if ratePerSecond > 0 {
+		r.limiter = time.NewTicker(time.Second / time.Duration(ratePerSecond))
+	}
+
+	for i := 0; i < workers; i++ {
+		r.wg.Add(1)
+		go r.work()
+	}
+	return r
+}
+
+func (r *Runner) work() {
+	defer r.wg.Done()
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case job := <-r.queue:
+			if r.limiter != nil {
+				select {
+				case <-r.limiter.C:
+				case <-r.ctx.Done():
+					return
+				}
+			}
+			r.run(job)
+		}
+	}
+}
+
+func (r *Runner) run(job Job) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			slog.Error("job runner: job panicked", "runner", r.name, "panic", rec)
+		}
+		r.completed.Add(1)
+	}()
+	job(r.ctx)
+}
+
+// Submit enqueues a job without blocking. It returns false if the job was dropped.
+func (r *Runner) Submit(job Job) bool {
+	select {
+	case <-r.ctx.Done():
+		r.dropped.Add(1)
+		return false
+	default:
+	}
+
+	select {
+	case r.queue <- job:
+		r.submitted.Add(1)
+		return true
+	default:
+		r.dropped.Add(1)
+		slog.Warn("job runner: queue full, job dropped", "runner", r.name)
+		return false
+	}
+}
+
+// Stop cancels running jobs and waits for the workers to exit
+func (r *Runner) Stop() {
+	r.cancel()
+	if r.limiter != nil {
+		r.limiter.Stop()
+	}
+	r.wg.Wait()
+}
+
+// Stats returns a snapshot of the runner counters
+func (r *Runner) Stats() Stats {
+	return Stats{
+		Name:      r.name,
+		Submitted: r.submitted.Load(),
+		Completed: r.completed.Load(),
+		Dropped:   r.dropped.Load(),
+		Queued:    len(r.queue),
+		Workers:   r.workers,
+	}
+}
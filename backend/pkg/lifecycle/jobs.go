@@ -0,0 +1,59 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Jobs runs the server's periodic background work, such as the drift check,
+// until Stop. A run under way when Stop is called finishes first, so a job
+// can't write audit events after the audit queue has closed.
+type Jobs struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewJobs() *Jobs {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Jobs{ctx: ctx, cancel: cancel}
+}
+
+// Every runs fn every interval, the first time one interval from now
+func (j *Jobs) Every(interval time.Duration, fn func()) {
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-j.ctx.Done():
+				return
+			case <-ticker.C:
+				if j.ctx.Err() == nil { // Stop may have raced the tick
+					fn()
+				}
+			}
+		}
+	}()
+}
+
+// Stop is a shutdown step: no job starts again, and it waits for the runs
+// under way until ctx ends
+func (j *Jobs) Stop(ctx context.Context) error {
+	j.cancel()
+	done := make(chan struct{})
+	go func() {
+		j.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("background jobs still running: %w", ctx.Err())
+	}
+}
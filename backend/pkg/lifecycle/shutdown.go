@@ -0,0 +1,114 @@
+// Package lifecycle runs the server's graceful shutdown: named steps in
+// order, within one time budget, with the step under way reported on
+// /health/live so load balancers stop routing before the listener closes.
+package lifecycle
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// PhaseRunning is reported until shutdown starts; then the phase is the
+// name of the step under way, and PhaseStopped once all have run
+const (
+	PhaseRunning = "running"
+	PhaseStopped = "stopped"
+)
+
+// DefaultShutdownTimeout bounds the whole shutdown
+const DefaultShutdownTimeout = 30 * time.Second
+
+type step struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Shutdown coordinates the steps. Every step runs even once the budget is
+// spent: steps without a context, such as flushing the audit queue, still
+// get their chance to save state.
+type Shutdown struct {
+	Timeout time.Duration
+
+	steps []step
+	phase atomic.Value // string
+	once  sync.Once
+	done  chan struct{}
+}
+
+func NewShutdown(timeout time.Duration) *Shutdown {
+	s := &Shutdown{Timeout: timeout, done: make(chan struct{})}
+	s.phase.Store(PhaseRunning)
+	return s
+}
+
+// Add appends a step; steps run in the order added
+func (s *Shutdown) Add(name string, fn func(ctx context.Context) error) {
+	s.steps = append(s.steps, step{name: name, fn: fn})
+}
+
+// Phase is PhaseRunning, the step under way or PhaseStopped
+func (s *Shutdown) Phase() string {
+	return s.phase.Load().(string)
+}
+
+// ShuttingDown reports whether shutdown has started
+func (s *Shutdown) ShuttingDown() bool {
+	return s.Phase() != PhaseRunning
+}
+
+// Run runs every step once, logging each one's outcome. Later calls wait
+// for the first to finish.
+func (s *Shutdown) Run() {
+	s.once.Do(func() {
+		defer close(s.done)
+		start := time.Now()
+		ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+		defer cancel()
+
+		slog.Info("shutdown started", "steps", len(s.steps), "timeout_ms", s.Timeout.Milliseconds())
+		for _, st := range s.steps {
+			s.phase.Store(st.name)
+			stepStart := time.Now()
+			if err := st.fn(ctx); err != nil {
+				slog.Warn("shutdown step incomplete", "step", st.name, "duration_ms", time.Since(stepStart).Milliseconds(), "error", err)
+				continue
+			}
+			slog.Info("shutdown step done", "step", st.name, "duration_ms", time.Since(stepStart).Milliseconds())
+		}
+		s.phase.Store(PhaseStopped)
+		slog.Info("shutdown finished", "duration_ms", time.Since(start).Milliseconds())
+	})
+	<-s.done
+}
+
+// Done is closed once Run has finished
+func (s *Shutdown) Done() <-chan struct{} {
+	return s.done
+}
+
+// Delay is a step keeping the server up for d, or until the budget runs
+// out, so health checks see the shutdown before the listener closes
+func Delay(d time.Duration) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		select {
+		case <-time.After(d):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Live answers /health/live: 200 while running, then 503 with the phase
+func (s *Shutdown) Live(c *fiber.Ctx) error {
+	phase := s.Phase()
+	if phase == PhaseRunning {
+		return c.JSON(fiber.Map{"status": "live", "uptime": "ok", "phase": phase})
+	}
+	return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"status": "shutting_down", "phase": phase})
+}
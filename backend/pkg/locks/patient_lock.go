@@ -0,0 +1,285 @@
+// Package locks serializes work on a single patient, in-process or across
+// replicas through Redis.
+package locks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"healthcare-backend/pkg/logging"
+)
+
+// ErrLocked means another caller holds the patient's lock
+var ErrLocked = errors.New("patient is locked by an in-flight assessment")
+
+// Backend stores lock values. Values are opaque to the backend; every write
+// after the first is conditional on the caller still owning the key.
+type Backend interface {
+	// Acquire sets key to value if it's free and returns the current value otherwise
+	Acquire(key, value string, ttl time.Duration) (ok bool, current string, err error)
+	// Replace swaps the value only while the key still holds old
+	Replace(key, old, value string, ttl time.Duration) error
+	// Release deletes the key only while it still holds value
+	Release(key, value string) error
+}
+
+// Stats reports lock contention for admin/metrics endpoints
+type Stats struct {
+	Backend       string `json:"backend"`
+	Acquired      int64  `json:"acquired"`
+	Contended     int64  `json:"contended"`      // Had to wait or was turned away
+	Rejected      int64  `json:"rejected"`       // Turned away immediately (reject mode)
+	Timeouts      int64  `json:"timeouts"`       // Gave up waiting
+	BackendErrors int64  `json:"backend_errors"` // Redis failures; the local lock was used instead
+	Held          int64  `json:"held"`
+}
+
+// LockedError carries what's known about the holder
+type LockedError struct {
+	AssessmentID uint // 0 until the in-flight assessment has been stored
+}
+
+func (e *LockedError) Error() string { return ErrLocked.Error() }
+func (e *LockedError) Unwrap() error { return ErrLocked }
+
+// PatientLocks hands out per-patient leases. With a shared backend they hold
+// across replicas; if it errors the in-process lock keeps this replica safe.
+type PatientLocks struct {
+	Shared Backend // Optional, e.g. Redis
+	Local  Backend
+	TTL    time.Duration // Expires a lease whose holder died, so nothing deadlocks
+	Wait   time.Duration // How long Acquire waits for a busy patient; 0 rejects at once
+
+	acquired, contended, rejected, timeouts, backendErrors, held atomic.Int64
+}
+
+// NewPatientLocks builds the locks; shared may be nil
+func NewPatientLocks(shared Backend, ttl, wait time.Duration) *PatientLocks {
+	return &PatientLocks{Shared: shared, Local: NewMemoryBackend(), TTL: ttl, Wait: wait}
+}
+
+// Lease is a held lock. Release is safe to call more than once.
+type Lease struct {
+	locks   *PatientLocks
+	backend Backend
+	key     string
+	token   string
+
+	mu       sync.Mutex
+	value    string
+	released bool
+}
+
+func patientKey(patientID uint) string {
+	return fmt.Sprintf("lock:assess:patient:%d", patientID)
+}
+
+// lockValue is "token|assessmentID"
+func lockValue(token string, assessmentID uint) string {
+	return token + "|" + strconv.FormatUint(uint64(assessmentID), 10)
+}
+
+func holderAssessment(value string) uint {
+	_, id, _ := strings.Cut(value, "|")
+	n, _ := strconv.ParseUint(id, 10, 64)
+	return uint(n)
+}
+
+func newToken() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Acquire takes the patient's lock, waiting up to Wait for the holder to
+// finish. When it can't, the error is a *LockedError.
+func (l *PatientLocks) Acquire(ctx context.Context, patientID uint) (*Lease, error) {
+	key := patientKey(patientID)
+	token := newToken()
+	value := lockValue(token, 0)
+
+	var deadline time.Time
+	if l.Wait > 0 {
+		deadline = time.Now().Add(l.Wait)
+	}
+
+	backoff := 10 * time.Millisecond
+	counted := false
+	for {
+		backend, ok, current := l.try(key, value)
+		if ok {
+			l.acquired.Add(1)
+			l.held.Add(1)
+			return &Lease{locks: l, backend: backend, key: key, token: token, value: value}, nil
+		}
+
+		if !counted {
+			l.contended.Add(1)
+			counted = true
+		}
+		locked := &LockedError{AssessmentID: holderAssessment(current)}
+		if deadline.IsZero() {
+			l.rejected.Add(1)
+			return nil, locked
+		}
+		if !time.Now().Before(deadline) {
+			l.timeouts.Add(1)
+			logging.FromContext(ctx).Warn("timed out waiting for the assessment lock", "patient_id", patientID, "wait_ms", l.Wait.Milliseconds())
+			return nil, locked
+		}
+
+		select {
+		case <-ctx.Done():
+			l.timeouts.Add(1)
+			return nil, locked
+		case <-time.After(backoff):
+		}
+		if backoff < 100*time.Millisecond {
+			backoff *= 2
+		}
+	}
+}
+
+// try acquires on the shared backend, falling back to the local one when it errors
+func (l *PatientLocks) try(key, value string) (Backend, bool, string) {
+	if l.Shared != nil {
+		ok, current, err := l.Shared.Acquire(key, value, l.TTL)
+		if err == nil {
+			return l.Shared, ok, current
+		}
+		l.backendErrors.Add(1)
+		slog.Warn("shared patient lock unavailable, using local lock", "error", err)
+	}
+	ok, current, _ := l.Local.Acquire(key, value, l.TTL)
+	return l.Local, ok, current
+}
+
+// SetAssessment records the in-flight assessment so waiting callers can be pointed at it
+func (s *Lease) SetAssessment(assessmentID uint) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.released {
+		return
+	}
+	value := lockValue(s.token, assessmentID)
+	if err := s.backend.Replace(s.key, s.value, value, s.locks.TTL); err != nil {
+		s.locks.backendErrors.Add(1)
+		slog.Warn("failed to annotate patient lock", "error", err)
+		return
+	}
+	s.value = value
+}
+
+// Release frees the lock if this lease still owns it
+func (s *Lease) Release() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.released {
+		return
+	}
+	s.released = true
+	s.locks.held.Add(-1)
+	if err := s.backend.Release(s.key, s.value); err != nil {
+		s.locks.backendErrors.Add(1)
+		slog.Warn("failed to release patient lock", "expires_in_ms", s.locks.TTL.Milliseconds(), "error", err)
+	}
+}
+
+// Stats returns a snapshot of the lock counters
+func (l *PatientLocks) Stats() Stats {
+	backend := "local"
+	if l.Shared != nil {
+		backend = "redis"
+	}
+	return Stats{
+		Backend:       backend,
+		Acquired:      l.acquired.Load(),
+		Contended:     l.contended.Load(),
+		Rejected:      l.rejected.Load(),
+		Timeouts:      l.timeouts.Load(),
+		BackendErrors: l.backendErrors.Load(),
+		Held:          l.held.Load(),
+	}
+}
+
+// --- In-process backend ---
+
+const memoryStripes = 64
+
+type memoryEntry struct {
+	value   string
+	expires time.Time
+}
+
+type memoryStripe struct {
+	mu   sync.Mutex
+	held map[string]memoryEntry
+}
+
+// MemoryBackend is a striped in-process lock table: patients hashing to
+// different stripes never contend on the same mutex
+type MemoryBackend struct {
+	stripes [memoryStripes]memoryStripe
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	b := &MemoryBackend{}
+	for i := range b.stripes {
+		b.stripes[i].held = map[string]memoryEntry{}
+	}
+	return b
+}
+
+func (b *MemoryBackend) stripe(key string) *memoryStripe {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &b.stripes[h.Sum32()%memoryStripes]
+}
+
+func (b *MemoryBackend) Acquire(key, value string, ttl time.Duration) (bool, string, error) {
+	s := b.stripe(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.held[key]; ok && time.Now().Before(e.expires) {
+		return false, e.value, nil
+	}
+	s.held[key] = memoryEntry{value: value, expires: time.Now().Add(ttl)}
+	return true, value, nil
+}
+
+func (b *MemoryBackend) Replace(key, old, value string, ttl time.Duration) error {
+	s := b.stripe(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.held[key]; !ok || e.value != old {
+		return errors.New("lock no longer held")
+	}
+	s.held[key] = memoryEntry{value: value, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+func (b *MemoryBackend) Release(key, value string) error {
+	s := b.stripe(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.held[key]; ok && e.value == value {
+		delete(s.held, key)
+	}
+	return nil
+}
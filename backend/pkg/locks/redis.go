@@ -0,0 +1,60 @@
+package locks
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Compare-and-swap and compare-and-delete, so a lease that expired and was
+// taken over can't clobber the new holder
+var (
+	replaceScript = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3]) else return false end`)
+	releaseScript = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`)
+)
+
+// RedisBackend keeps locks in Redis so they hold across replicas
+type RedisBackend struct {
+	Client  redis.Cmdable
+	Timeout time.Duration // Per call; a slow Redis falls back to the local lock
+}
+
+func NewRedisBackend(client redis.Cmdable) *RedisBackend {
+	return &RedisBackend{Client: client, Timeout: 200 * time.Millisecond}
+}
+
+func (b *RedisBackend) ctx() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), b.Timeout)
+}
+
+func (b *RedisBackend) Acquire(key, value string, ttl time.Duration) (bool, string, error) {
+	ctx, cancel := b.ctx()
+	defer cancel()
+	ok, err := b.Client.SetNX(ctx, key, value, ttl).Result()
+	if err != nil || ok {
+		return ok, value, err
+	}
+	current, err := b.Client.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return false, "", nil // Released in between; the caller retries
+	}
+	return false, current, err
+}
+
+func (b *RedisBackend) Replace(key, old, value string, ttl time.Duration) error {
+	ctx, cancel := b.ctx()
+	defer cancel()
+	err := replaceScript.Run(ctx, b.Client, []string{key}, old, value, ttl.Milliseconds()).Err()
+	if errors.Is(err, redis.Nil) {
+		return errors.New("lock no longer held")
+	}
+	return err
+}
+
+func (b *RedisBackend) Release(key, value string) error {
+	ctx, cancel := b.ctx()
+	defer cancel()
+	return releaseScript.Run(ctx, b.Client, []string{key}, value).Err()
+}
@@ -3,7 +3,10 @@ package mcp
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/logging"
 	"healthcare-backend/pkg/models"
 	"healthcare-backend/pkg/services"
 
@@ -13,21 +16,27 @@ import (
 )
 
 type MCPServer struct {
-	DB   *gorm.DB
-	RAG  *services.RAGService
-	serv *server.MCPServer
+	DB    *gorm.DB
+	RAG    *services.RAGService
+	Search *services.SearchService
+	Audit  *services.AuditService
+	Actor auditctx.Identity // Recorded for every tool call
+	serv  *server.MCPServer
 }
 
-func NewMCPServer(db *gorm.DB, rag *services.RAGService) *MCPServer {
+func NewMCPServer(db *gorm.DB, rag *services.RAGService, audit *services.AuditService, actor auditctx.Identity) *MCPServer {
 	s := server.NewMCPServer(
 		"Healthcare clinical Context Server",
 		"1.0.0",
 	)
 
 	m := &MCPServer{
-		DB:   db,
-		RAG:  rag,
-		serv: s,
+		DB:    db,
+		RAG:    rag,
+		Search: services.NewSearchService(db),
+		Audit:  audit,
+		Actor: actor,
+		serv:  s,
 	}
 
 	m.registerTools()
@@ -43,14 +52,29 @@ func (m *MCPServer) registerTools() {
 		mcp.WithNumber("glucose", mcp.Required()),
 		mcp.WithNumber("bmi", mcp.Required()),
 	)
-	m.serv.AddTool(getSimilarPatientsTool, m.handleGetSimilarPatients)
+	m.serv.AddTool(getSimilarPatientsTool, m.audited("get_similar_patients", m.handleGetSimilarPatients))
 
 	// 2. Tool: Search Feedback
 	searchFeedbackTool := mcp.NewTool("search_feedback",
 		mcp.WithDescription("Search through historical doctor notes for specific symptoms or keywords"),
 		mcp.WithString("query", mcp.Required()),
 	)
-	m.serv.AddTool(searchFeedbackTool, m.handleSearchFeedback)
+	m.serv.AddTool(searchFeedbackTool, m.audited("search_feedback", m.handleSearchFeedback))
+}
+
+// audited records each tool call against the configured MCP actor, since
+// tools read clinical data without going through the HTTP API
+func (m *MCPServer) audited(tool string, next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if m.Audit != nil {
+			if _, err := m.Audit.LogEvent("MCP_TOOL_CALLED", 0, map[string]any{
+				"tool": tool, "arguments": request.Params.Arguments,
+			}, m.Actor); err != nil {
+				logging.FromContext(ctx).Warn("audit event failed", "event", "MCP_TOOL_CALLED", "tool", tool, "error", err)
+			}
+		}
+		return next(ctx, request)
+	}
 }
 
 func (m *MCPServer) handleGetSimilarPatients(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -78,7 +102,7 @@ func (m *MCPServer) handleGetSimilarPatients(ctx context.Context, request mcp.Ca
 		BMI:        input.BMI,
 	}
 
-	contextStr := m.RAG.FindSimilarCases(patient)
+	contextStr, _ := m.RAG.FindSimilarCases(patient, 0)
 
 	return mcp.NewToolResultText(contextStr), nil
 }
@@ -97,16 +121,19 @@ func (m *MCPServer) handleSearchFeedback(ctx context.Context, request mcp.CallTo
 		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
 	}
 
-	var feedbacks []models.Feedback
-	m.DB.Where("doctor_notes LIKE ?", "%"+input.Query+"%").Limit(5).Find(&feedbacks)
+	// Same ranked, stemmed search as GET /api/search, over notes only
+	hits, err := m.Search.Search(services.SearchQuery{Text: input.Query, Sources: []string{models.SearchFeedback}, Limit: 5})
+	if err != nil && !errors.Is(err, services.ErrEmptySearch) {
+		return mcp.NewToolResultError(fmt.Sprintf("Search failed: %v", err)), nil
+	}
 
-	if len(feedbacks) == 0 {
+	if len(hits) == 0 {
 		return mcp.NewToolResultText("No relevant feedback found for query: " + input.Query), nil
 	}
 
 	result := "Found historical clinical feedback:\n"
-	for _, f := range feedbacks {
-		result += fmt.Sprintf("- [Case %s]: %s\n", f.AssessmentID, f.DoctorNotes)
+	for _, h := range hits {
+		result += fmt.Sprintf("- [Case %d]: %s\n", h.AssessmentID, h.Snippet)
 	}
 
 	return mcp.NewToolResultText(result), nil
@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"healthcare-backend/pkg/auditctx"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// APIKey is a configured machine credential
+type APIKey struct {
+	ID   string
+	Role string
+	Key  string
+}
+
+// CredentialLookup resolves API keys stored outside the config, e.g. in the database
+type CredentialLookup interface {
+	Lookup(key string) (auditctx.Identity, bool)
+}
+
+// RevocationList reports JWTs revoked before they expired, e.g. on logout
+type RevocationList interface {
+	Revoked(jti string) bool
+}
+
+// ActorConfig lists the credentials the actor middleware accepts
+type ActorConfig struct {
+	JWTSecret   []byte // HS256; JWTs are ignored when empty
+	APIKeys     []APIKey
+	Credentials CredentialLookup // Optional; consulted after APIKeys
+	Revocations RevocationList   // Optional; JWTs whose jti it lists are ignored
+}
+
+// Token describes the JWT a request was authenticated with
+type Token struct {
+	ID        string    // jti; empty for tokens issued without one
+	ExpiresAt time.Time // Zero when the token never expires
+}
+
+const tokenLocalsKey = "token"
+
+// RequestToken returns the JWT the request was authenticated with, if any
+func RequestToken(c *fiber.Ctx) (Token, bool) {
+	t, ok := c.Locals(tokenLocalsKey).(Token)
+	return t, ok
+}
+
+// ResolveActor puts the caller's identity into c.Locals for auditing: the
+// subject of a valid bearer JWT, else the ID of a matching X-API-Key, else
+// anonymous. It only identifies; it never rejects a request.
+func ResolveActor(cfg ActorConfig) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		auditctx.Set(c, cfg.resolve(c))
+		return c.Next()
+	}
+}
+
+// RequireRole only lets through callers whose resolved role is one of
+// roles: anonymous callers get 401, everyone else 403. ResolveActor must run first.
+func RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		actor := auditctx.Actor(c)
+		if actor.Role == auditctx.RoleAnonymous {
+			return c.Status(401).JSON(fiber.Map{"error": "Authentication required"})
+		}
+		if !slices.Contains(roles, actor.Role) {
+			return c.Status(403).JSON(fiber.Map{"error": "Insufficient role"})
+		}
+		return c.Next()
+	}
+}
+
+func (cfg ActorConfig) resolve(c *fiber.Ctx) auditctx.Identity {
+	if token, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer "); ok {
+		if id, ok := cfg.verifyJWT(c, strings.TrimSpace(token)); ok {
+			return id
+		}
+	}
+	if key := c.Get("X-API-Key"); key != "" {
+		for _, k := range cfg.APIKeys {
+			if subtle.ConstantTimeCompare([]byte(key), []byte(k.Key)) == 1 {
+				return auditctx.Identity{ID: "apikey:" + k.ID, Role: k.Role}
+			}
+		}
+		if cfg.Credentials != nil {
+			if id, ok := cfg.Credentials.Lookup(key); ok {
+				return id
+			}
+		}
+	}
+	return auditctx.Anonymous
+}
+
+// verifyJWT checks a token's signature, lifetime and revocation, and
+// records it for RequestToken
+func (cfg ActorConfig) verifyJWT(c *fiber.Ctx, token string) (auditctx.Identity, bool) {
+	if len(cfg.JWTSecret) == 0 {
+		return auditctx.Identity{}, false
+	}
+	id, t, err := parseJWT(token, cfg.JWTSecret, time.Now())
+	if err != nil || (t.ID != "" && cfg.Revocations != nil && cfg.Revocations.Revoked(t.ID)) {
+		return auditctx.Identity{}, false
+	}
+	c.Locals(tokenLocalsKey, t)
+	return id, true
+}
+
+var errInvalidJWT = errors.New("invalid token")
+
+// parseJWT verifies an HS256 token and returns its subject and role claims
+func parseJWT(token string, secret []byte, now time.Time) (auditctx.Identity, Token, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return auditctx.Identity{}, Token{}, errInvalidJWT
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return auditctx.Identity{}, Token{}, errInvalidJWT
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return auditctx.Identity{}, Token{}, errInvalidJWT
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return auditctx.Identity{}, Token{}, errInvalidJWT
+	}
+
+	var claims struct {
+		Sub  string `json:"sub"`
+		Role string `json:"role"`
+		Jti  string `json:"jti"`
+		Exp  int64  `json:"exp"`
+		Nbf  int64  `json:"nbf"`
+	}
+	if err := decodeJWTPart(parts[1], &claims); err != nil || claims.Sub == "" {
+		return auditctx.Identity{}, Token{}, errInvalidJWT
+	}
+	if (claims.Exp != 0 && now.Unix() >= claims.Exp) || (claims.Nbf != 0 && now.Unix() < claims.Nbf) {
+		return auditctx.Identity{}, Token{}, errInvalidJWT
+	}
+	if claims.Role == "" {
+		claims.Role = auditctx.RoleUser
+	}
+	t := Token{ID: claims.Jti}
+	if claims.Exp != 0 {
+		t.ExpiresAt = time.Unix(claims.Exp, 0)
+	}
+	return auditctx.Identity{ID: claims.Sub, Role: claims.Role}, t, nil
+}
+
+func decodeJWTPart(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// ParseAPIKeys reads "id:role:key" entries; the key may itself contain colons
+func ParseAPIKeys(entries []string) ([]APIKey, error) {
+	keys := make([]APIKey, 0, len(entries))
+	for _, e := range entries {
+		parts := strings.SplitN(e, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return nil, errors.New("API key entries must look like id:role:key")
+		}
+		keys = append(keys, APIKey{ID: parts[0], Role: parts[1], Key: parts[2]})
+	}
+	return keys, nil
+}
@@ -1,9 +1,13 @@
 package middleware
 
 import (
-	"log"
+	"errors"
+	"fmt"
 	"runtime/debug"
 
+	"healthcare-backend/pkg/apierrors"
+	"healthcare-backend/pkg/logging"
+
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -19,7 +23,7 @@ func ErrorHandler(c *fiber.Ctx) error {
 	// Recover from panics
 	defer func() {
 		if r := recover(); r != nil {
-			log.Printf("🔥 PANIC RECOVERED: %v\n%s", r, debug.Stack())
+			logging.Request(c).Error("panic recovered", "panic", r, "stack", string(debug.Stack()))
 			c.Status(500).JSON(ErrorResponse{
 				Success: false,
 				Error:   "Internal Server Error",
@@ -32,7 +36,37 @@ func ErrorHandler(c *fiber.Ctx) error {
 
 	if err != nil {
 		// Log the error
-		log.Printf("❌ Error: %v | Path: %s | Method: %s", err, c.Path(), c.Method())
+		logging.Request(c).Error("request failed", "method", c.Method(), "path", c.Path(), "error", err)
+
+		// Storage contention: tell the client when to come back
+		var busy *apierrors.StorageBusy
+		if errors.As(err, &busy) {
+			c.Set(fiber.HeaderRetryAfter, fmt.Sprintf("%d", int(busy.RetryAfter.Seconds())))
+			return c.Status(fiber.StatusServiceUnavailable).JSON(ErrorResponse{
+				Success: false,
+				Error:   "Storage temporarily busy, please retry",
+				Code:    fiber.StatusServiceUnavailable,
+			})
+		}
+
+		// Failed writes: never report success, and say why in HTTP terms
+		err = apierrors.FromDB(err)
+		var conflict *apierrors.Conflict
+		if errors.As(err, &conflict) {
+			return c.Status(fiber.StatusConflict).JSON(ErrorResponse{
+				Success: false,
+				Error:   "Record conflicts with an existing one",
+				Code:    fiber.StatusConflict,
+			})
+		}
+		var unavailable *apierrors.StorageUnavailable
+		if errors.As(err, &unavailable) {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(ErrorResponse{
+				Success: false,
+				Error:   "Storage unavailable",
+				Code:    fiber.StatusServiceUnavailable,
+			})
+		}
 
 		// Check if it's a Fiber error
 		if e, ok := err.(*fiber.Error); ok {
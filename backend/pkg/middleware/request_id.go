@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"healthcare-backend/pkg/auditctx"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// maxRequestIDLength bounds the request IDs accepted from callers
+const maxRequestIDLength = 128
+
+// validRequestID accepts printable ASCII without spaces, so a caller's ID
+// can't forge log fields or headers
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestID tags the request with the caller's X-Request-ID, or a new UUID
+// when it sent none or an invalid one, and echoes it on the response. The
+// ID ends up in the request's logs, its audit entries and its ML calls.
+func RequestID(c *fiber.Ctx) error {
+	id := c.Get(auditctx.RequestIDHeader)
+	if !validRequestID(id) {
+		id = uuid.NewString()
+	}
+	auditctx.SetRequestID(c, id)
+	c.Set(auditctx.RequestIDHeader, id)
+	return c.Next()
+}
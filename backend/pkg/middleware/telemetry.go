@@ -13,7 +13,18 @@ var (
 	ErrorCount   uint64
 )
 
+// SelfTestPath is excluded from request counters, metrics and rate limits
+const SelfTestPath = "/api/admin/selftest"
+
+// IsSelfTest reports whether the request is the synthetic self-test
+func IsSelfTest(c *fiber.Ctx) bool {
+	return c.Path() == SelfTestPath
+}
+
 func PerformanceMiddleware(c *fiber.Ctx) error {
+	if IsSelfTest(c) {
+		return c.Next()
+	}
 	atomic.AddUint64(&RequestCount, 1)
 	
 	err := c.Next()
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/tracing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing opens a server span for each request, continuing the caller's
+// trace when it sent a traceparent, and puts it in the request's user
+// context for the spans started below it. RequestID must run first.
+func Tracing(c *fiber.Ctx) error {
+	parent := otel.GetTextMapPropagator().Extract(c.UserContext(), propagation.HeaderCarrier(http.Header(c.GetReqHeaders())))
+	ctx, span := tracing.Start(parent, c.Method()+" "+c.Path(), trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+	c.SetUserContext(ctx)
+
+	err := c.Next()
+
+	// The route is only known once matched
+	span.SetName(c.Method() + " " + c.Route().Path)
+	status := c.Response().StatusCode()
+	span.SetAttributes(
+		attribute.String("http.request.method", c.Method()),
+		attribute.String("http.route", c.Route().Path),
+		attribute.Int("http.response.status_code", status),
+		attribute.String("request_id", auditctx.RequestID(c)),
+	)
+	if err != nil || status >= 500 {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+	return err
+}
@@ -16,9 +16,18 @@ type ValidationError struct {
 
 // ValidateStruct validates a struct and returns formatted errors
 func ValidateStruct(s interface{}) []ValidationError {
+	return validationErrors(validate.Struct(s))
+}
+
+// ValidateStructExcept is ValidateStruct without the named fields, e.g. the
+// ones a partial update leaves out
+func ValidateStructExcept(s interface{}, fields ...string) []ValidationError {
+	return validationErrors(validate.StructExcept(s, fields...))
+}
+
+func validationErrors(err error) []ValidationError {
 	var errors []ValidationError
 
-	err := validate.Struct(s)
 	if err != nil {
 		for _, err := range err.(validator.ValidationErrors) {
 			var element ValidationError
@@ -0,0 +1,13 @@
+package models
+
+import (
+	"healthcare-backend/pkg/privacy"
+
+	"gorm.io/gorm/schema"
+)
+
+// Fields tagged `gorm:"serializer:encrypted"` are sealed with the keys
+// installed by privacy.UseFieldKeys
+func init() {
+	schema.RegisterSerializer("encrypted", privacy.EncryptedSerializer{})
+}
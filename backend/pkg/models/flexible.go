@@ -0,0 +1,231 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNotANumber       = errors.New("not a number")
+	ErrAmbiguousNumber  = errors.New("ambiguous: could be a thousands or a decimal separator")
+	ErrBadGrouping      = errors.New("misplaced thousands separator")
+	ErrNotWholeNumber   = errors.New("must be a whole number")
+	ErrNumberOutOfRange = errors.New("number out of range")
+)
+
+// FlexibleFloat decodes a JSON number or a string written with either
+// decimal separator ("24.5", "24,5", "1.234,5", "1,234.5"). Values that
+// could be read two ways, like "1.234", are rejected instead of guessed.
+// An empty string or null leaves it at zero.
+type FlexibleFloat float64
+
+func (f *FlexibleFloat) UnmarshalJSON(data []byte) error {
+	v, err := decodeFlexible(data)
+	if err != nil {
+		return err
+	}
+	*f = FlexibleFloat(v)
+	return nil
+}
+
+// FlexibleInt is FlexibleFloat for whole numbers: "120", "8.500,0" and
+// 120.0 are fine, "24,5" is an error rather than a truncated 24.
+type FlexibleInt int
+
+func (n *FlexibleInt) UnmarshalJSON(data []byte) error {
+	v, err := decodeFlexible(data)
+	if err != nil {
+		return err
+	}
+	if v != math.Trunc(v) {
+		return ErrNotWholeNumber
+	}
+	if v > math.MaxInt32 || v < math.MinInt32 {
+		return ErrNumberOutOfRange
+	}
+	*n = FlexibleInt(v)
+	return nil
+}
+
+func decodeFlexible(data []byte) (float64, error) {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return 0, nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return 0, ErrNotANumber
+		}
+		return ParseLocalizedNumber(s)
+	}
+	var v float64
+	if err := json.Unmarshal(data, &v); err != nil {
+		return 0, ErrNotANumber
+	}
+	return v, nil
+}
+
+// groupOnly separators can only ever group thousands
+func groupOnly(r rune) bool {
+	return r == ' ' || r == '\'' || r == '\u00a0' || r == '\u202f'
+}
+
+// ParseLocalizedNumber reads a number typed by a person in either the
+// "1,234.5" or the "1.234,5" convention. A lone separator followed by
+// exactly three digits ("1.234", "24,500") is ambiguous and rejected.
+func ParseLocalizedNumber(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	sign := ""
+	if s[0] == '-' || s[0] == '+' {
+		sign, s = s[:1], s[1:]
+	}
+	runes := []rune(s)
+	if len(runes) == 0 || !isDigit(runes[0]) || !isDigit(runes[len(runes)-1]) {
+		return 0, ErrNotANumber
+	}
+
+	dots, commas := 0, 0
+	lastDot, lastComma := -1, -1
+	for i, r := range runes {
+		switch {
+		case isDigit(r), groupOnly(r):
+		case r == '.':
+			dots++
+			lastDot = i
+		case r == ',':
+			commas++
+			lastComma = i
+		default:
+			return 0, ErrNotANumber
+		}
+	}
+
+	// Work out which separator, if any, is the decimal point
+	decimal := -1
+	switch {
+	case dots > 0 && commas > 0:
+		decimal = max(lastDot, lastComma)
+		if (runes[decimal] == '.' && dots > 1) || (runes[decimal] == ',' && commas > 1) {
+			return 0, ErrBadGrouping
+		}
+	case dots == 1 || commas == 1:
+		decimal = max(lastDot, lastComma)
+		intPart := runes[:decimal]
+		if len(runes)-decimal-1 == 3 && !strings.ContainsFunc(string(intPart), groupOnly) && intPart[0] != '0' && len(intPart) <= 3 {
+			return 0, ErrAmbiguousNumber
+		}
+	}
+
+	intPart, frac := runes, []rune(nil)
+	if decimal >= 0 {
+		intPart, frac = runes[:decimal], runes[decimal+1:]
+		for _, r := range frac {
+			if !isDigit(r) {
+				return 0, ErrBadGrouping
+			}
+		}
+	}
+	digits, err := ungroup(intPart)
+	if err != nil {
+		return 0, err
+	}
+
+	text := sign + digits
+	if len(frac) > 0 {
+		text += "." + string(frac)
+	}
+	v, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, ErrNumberOutOfRange
+	}
+	return v, nil
+}
+
+// ungroup strips thousands separators, which must all be the same character
+// and split the digits into a leading group of 1-3 followed by groups of 3
+func ungroup(part []rune) (string, error) {
+	var sep rune
+	var digits strings.Builder
+	groups := []int{0}
+	for _, r := range part {
+		if isDigit(r) {
+			digits.WriteRune(r)
+			groups[len(groups)-1]++
+			continue
+		}
+		if sep != 0 && r != sep {
+			return "", ErrBadGrouping
+		}
+		sep = r
+		groups = append(groups, 0)
+	}
+	if len(groups) > 1 {
+		if groups[0] < 1 || groups[0] > 3 {
+			return "", ErrBadGrouping
+		}
+		for _, g := range groups[1:] {
+			if g != 3 {
+				return "", ErrBadGrouping
+			}
+		}
+	}
+	return digits.String(), nil
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// NumberFieldError is a numeric field that couldn't be read
+type NumberFieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// NumberFieldErrors lists every unreadable numeric field in a request
+type NumberFieldErrors []NumberFieldError
+
+func (e NumberFieldErrors) Error() string {
+	parts := make([]string, len(e))
+	for i, fe := range e {
+		parts[i] = fe.Field + ": " + fe.Message
+	}
+	return "invalid numbers: " + strings.Join(parts, "; ")
+}
+
+// decodeNumbers decodes each named field into its target, collecting errors
+// per field, and returns the remaining fields for ordinary decoding
+func decodeNumbers(data []byte, targets map[string]json.Unmarshaler) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	var errs NumberFieldErrors
+	for name, target := range targets {
+		raw, ok := fields[name]
+		if !ok {
+			continue
+		}
+		delete(fields, name)
+		if err := target.UnmarshalJSON(raw); err != nil {
+			errs = append(errs, NumberFieldError{Field: name, Message: fmt.Sprintf("%v (got %s)", err, raw)})
+		}
+	}
+	if len(errs) > 0 {
+		sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+		return nil, errs
+	}
+	return json.Marshal(fields)
+}
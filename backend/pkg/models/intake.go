@@ -0,0 +1,244 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// ClinicDefaultAnswer is assumed for habit and history questions a new
+// patient's intake leaves unanswered
+const ClinicDefaultAnswer = "No"
+
+// ImputableFields are measurements the ML model imputes itself when they're
+// missing from its payload (see PatientData in src/api/ml_api/main.py), so
+// they get no clinic default
+var ImputableFields = []string{"cholesterol", "heart_rate", "steps"}
+
+// PatientIntake is the body of the assess and patient update endpoints.
+// Fields are pointers so an omitted field is nil rather than a zero value;
+// null, and "" for numbers and choices, also count as omitted. Numbers may
+// be written the way people type them, see FlexibleFloat.
+//
+// Required fields must be present when creating a patient; see NewPatient
+// and ApplyTo for how omitted fields are filled.
+type PatientIntake struct {
+	Name                *string        `json:"name,omitempty"`
+	Age                 *FlexibleInt   `json:"age" validate:"required,min=0,max=150"`
+	Gender              *string        `json:"gender" validate:"required,oneof=Male Female Other"`
+	SystolicBP          *FlexibleInt   `json:"systolic_bp" validate:"required,min=50,max=300"`
+	DiastolicBP         *FlexibleInt   `json:"diastolic_bp" validate:"required,min=30,max=200"`
+	Glucose             *FlexibleInt   `json:"glucose" validate:"required,min=20,max=600"`
+	BMI                 *FlexibleFloat `json:"bmi" validate:"required,min=10,max=80"`
+	Cholesterol         *FlexibleInt   `json:"cholesterol" validate:"omitempty,min=50,max=500"`
+	HeartRate           *FlexibleInt   `json:"heart_rate" validate:"omitempty,min=30,max=250"`
+	Steps               *FlexibleInt   `json:"steps" validate:"omitempty,min=0,max=100000"`
+	Smoking             *string        `json:"smoking" validate:"omitempty,oneof=Yes No Former"`
+	Alcohol             *string        `json:"alcohol" validate:"omitempty,oneof=Yes No"`
+	Medications         *string        `json:"medications"`
+	Allergies           *string        `json:"allergies"`
+	HistoryHeartDisease *string        `json:"history_heart_disease" validate:"omitempty,oneof=Yes No"`
+	HistoryStroke       *string        `json:"history_stroke" validate:"omitempty,oneof=Yes No"`
+	HistoryDiabetes     *string        `json:"history_diabetes" validate:"omitempty,oneof=Yes No"`
+	HistoryHighChol     *string        `json:"history_high_chol" validate:"omitempty,oneof=Yes No"`
+	Symptoms            *string        `json:"symptoms"`
+}
+
+// UnmarshalJSON reports every unreadable number as NumberFieldErrors
+func (in *PatientIntake) UnmarshalJSON(data []byte) error {
+	rest, err := decodeNumbers(data, map[string]json.Unmarshaler{
+		"age":          optional(&in.Age),
+		"systolic_bp":  optional(&in.SystolicBP),
+		"diastolic_bp": optional(&in.DiastolicBP),
+		"glucose":      optional(&in.Glucose),
+		"bmi":          optional(&in.BMI),
+		"cholesterol":  optional(&in.Cholesterol),
+		"heart_rate":   optional(&in.HeartRate),
+		"steps":        optional(&in.Steps),
+	})
+	if err != nil {
+		return err
+	}
+	type fields PatientIntake // Same fields, without this method
+	if err := json.Unmarshal(rest, (*fields)(in)); err != nil {
+		return err
+	}
+
+	// A blank choice is unanswered; blank free text (name, medications,
+	// allergies, symptoms) is kept so an update can clear it
+	for _, choice := range []**string{&in.Gender, &in.Smoking, &in.Alcohol,
+		&in.HistoryHeartDisease, &in.HistoryStroke, &in.HistoryDiabetes, &in.HistoryHighChol} {
+		if *choice != nil && strings.TrimSpace(**choice) == "" {
+			*choice = nil
+		}
+	}
+	return nil
+}
+
+// NewPatient builds a new patient record: unanswered habit and history
+// questions take ClinicDefaultAnswer, other omitted text fields stay empty,
+// and omitted ImputableFields are listed in ImputedFields
+func (in PatientIntake) NewPatient() PatientData {
+	patient := PatientData{
+		Smoking:             ClinicDefaultAnswer,
+		Alcohol:             ClinicDefaultAnswer,
+		HistoryHeartDisease: ClinicDefaultAnswer,
+		HistoryStroke:       ClinicDefaultAnswer,
+		HistoryDiabetes:     ClinicDefaultAnswer,
+		HistoryHighChol:     ClinicDefaultAnswer,
+		ImputedFields:       strings.Join(ImputableFields, ","),
+	}
+	in.ApplyTo(&patient)
+	return patient
+}
+
+// ApplyTo overwrites the fields of patient that the intake provides and
+// leaves the rest alone, for partial updates. Provided measurements are
+// taken off ImputedFields.
+func (in PatientIntake) ApplyTo(patient *PatientData) {
+	setString(&patient.Name, in.Name)
+	setInt(&patient.Age, in.Age)
+	setString(&patient.Gender, in.Gender)
+	setInt(&patient.SystolicBP, in.SystolicBP)
+	setInt(&patient.DiastolicBP, in.DiastolicBP)
+	setInt(&patient.Glucose, in.Glucose)
+	if in.BMI != nil {
+		patient.BMI = float64(*in.BMI)
+	}
+	setInt(&patient.Cholesterol, in.Cholesterol)
+	setInt(&patient.HeartRate, in.HeartRate)
+	setInt(&patient.Steps, in.Steps)
+	setString(&patient.Smoking, in.Smoking)
+	setString(&patient.Alcohol, in.Alcohol)
+	setString(&patient.Medications, in.Medications)
+	setString(&patient.Allergies, in.Allergies)
+	setString(&patient.HistoryHeartDisease, in.HistoryHeartDisease)
+	setString(&patient.HistoryStroke, in.HistoryStroke)
+	setString(&patient.HistoryDiabetes, in.HistoryDiabetes)
+	setString(&patient.HistoryHighChol, in.HistoryHighChol)
+	setString(&patient.Symptoms, in.Symptoms)
+
+	provided := map[string]bool{
+		"cholesterol": in.Cholesterol != nil,
+		"heart_rate":  in.HeartRate != nil,
+		"steps":       in.Steps != nil,
+	}
+	var imputed []string
+	for _, field := range patient.Imputed() {
+		if !provided[field] {
+			imputed = append(imputed, field)
+		}
+	}
+	patient.ImputedFields = strings.Join(imputed, ",")
+}
+
+// Omitted lists the Go names of the fields the body left out, so a partial
+// update can be validated without them
+func (in PatientIntake) Omitted() []string {
+	v := reflect.ValueOf(in)
+	var names []string
+	for i := 0; i < v.NumField(); i++ {
+		if v.Field(i).IsNil() {
+			names = append(names, v.Type().Field(i).Name)
+		}
+	}
+	return names
+}
+
+// IntakeOf is the intake view of a stored patient: imputed measurements are
+// nil, so field diffs show them as never provided rather than as zero
+func IntakeOf(p PatientData) PatientIntake {
+	in := PatientIntake{
+		Age:                 ptr(FlexibleInt(p.Age)),
+		Gender:              &p.Gender,
+		SystolicBP:          ptr(FlexibleInt(p.SystolicBP)),
+		DiastolicBP:         ptr(FlexibleInt(p.DiastolicBP)),
+		Glucose:             ptr(FlexibleInt(p.Glucose)),
+		BMI:                 ptr(FlexibleFloat(p.BMI)),
+		Cholesterol:         ptr(FlexibleInt(p.Cholesterol)),
+		HeartRate:           ptr(FlexibleInt(p.HeartRate)),
+		Steps:               ptr(FlexibleInt(p.Steps)),
+		Smoking:             &p.Smoking,
+		Alcohol:             &p.Alcohol,
+		Medications:         &p.Medications,
+		Allergies:           &p.Allergies,
+		HistoryHeartDisease: &p.HistoryHeartDisease,
+		HistoryStroke:       &p.HistoryStroke,
+		HistoryDiabetes:     &p.HistoryDiabetes,
+		HistoryHighChol:     &p.HistoryHighChol,
+		Symptoms:            &p.Symptoms,
+	}
+	if p.Name != "" {
+		in.Name = &p.Name
+	}
+	imputed := p.Imputed()
+	if slices.Contains(imputed, "cholesterol") {
+		in.Cholesterol = nil
+	}
+	if slices.Contains(imputed, "heart_rate") {
+		in.HeartRate = nil
+	}
+	if slices.Contains(imputed, "steps") {
+		in.Steps = nil
+	}
+	return in
+}
+
+// Imputed lists the JSON names in ImputedFields
+func (p PatientData) Imputed() []string {
+	if p.ImputedFields == "" {
+		return nil
+	}
+	return strings.Split(p.ImputedFields, ",")
+}
+
+// optional decodes into a newly allocated *target, leaving it nil for null
+// or a blank string
+func optional[T any, PT interface {
+	*T
+	json.Unmarshaler
+}](target **T) json.Unmarshaler {
+	return unmarshalFunc(func(data []byte) error {
+		if blankJSON(data) {
+			*target = nil
+			return nil
+		}
+		v := new(T)
+		if err := PT(v).UnmarshalJSON(data); err != nil {
+			return err
+		}
+		*target = v
+		return nil
+	})
+}
+
+type unmarshalFunc func([]byte) error
+
+func (f unmarshalFunc) UnmarshalJSON(data []byte) error { return f(data) }
+
+func blankJSON(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return true
+	}
+	var s string
+	return json.Unmarshal(data, &s) == nil && strings.TrimSpace(s) == ""
+}
+
+func setString(dst *string, src *string) {
+	if src != nil {
+		*dst = *src
+	}
+}
+
+func setInt(dst *int, src *FlexibleInt) {
+	if src != nil {
+		*dst = int(*src)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
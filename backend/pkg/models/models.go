@@ -1,31 +1,70 @@
 package models
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // -- Database Models --
 
+// PatientData is one patient record. Free text that identifies the patient
+// or describes their care (name, medications, allergies, symptoms) is
+// encrypted at rest once FIELD_ENCRYPTION_KEY is set. The rest stays
+// plaintext because SQL reads it: vitals and demographics for the list and
+// cohort filters, the dashboard's high-risk count and drift statistics;
+// history flags, smoking and alcohol are short enums also used in cohort
+// filters; source, clinic, imputed fields and the provider are routing
+// metadata. Similar-case distances and the prediction cache hash are
+// computed on the loaded row, after decryption.
 type PatientData struct {
 	ID          uint      `gorm:"primaryKey" json:"id"`
 	CreatedAt   time.Time `json:"created_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"` // Soft delete: audit entries and assessments keep pointing at the row
+	Name        string    `gorm:"serializer:encrypted" json:"name,omitempty"` // Optional display name; never sent to the LLM
 	Age         int       `json:"age" validate:"required,min=0,max=150"`
 	Gender      string    `json:"gender" validate:"required,oneof=Male Female Other"`
 	SystolicBP  int       `json:"systolic_bp" validate:"required,min=50,max=300"`
 	DiastolicBP int       `json:"diastolic_bp" validate:"required,min=30,max=200"`
 	Glucose     int       `json:"glucose" validate:"required,min=20,max=600"`
 	BMI         float64   `json:"bmi" validate:"required,min=10,max=80"`
-	Cholesterol int       `json:"cholesterol" validate:"min=50,max=500"`
-	HeartRate   int       `json:"heart_rate" validate:"min=30,max=250"`
+	Cholesterol int       `json:"cholesterol" validate:"omitempty,min=50,max=500"` // Zero when listed in ImputedFields
+	HeartRate   int       `json:"heart_rate" validate:"omitempty,min=30,max=250"`
 	Steps       int       `json:"steps" validate:"min=0,max=100000"`
 	Smoking     string    `json:"smoking" validate:"oneof=Yes No Former"`
 	Alcohol     string    `json:"alcohol" validate:"oneof=Yes No"`
-	Medications string    `json:"medications"` // Comma-separated
+	Medications string    `gorm:"serializer:encrypted" json:"medications"` // Comma-separated
+	Allergies   string    `gorm:"serializer:encrypted" json:"allergies"`   // Comma-separated, e.g. "Penicillin, Latex"
 	HistoryHeartDisease string `json:"history_heart_disease" validate:"oneof=Yes No"`
 	HistoryStroke       string `json:"history_stroke" validate:"oneof=Yes No"`
 	HistoryDiabetes     string `json:"history_diabetes" validate:"oneof=Yes No"`
 	HistoryHighChol     string `json:"history_high_chol" validate:"oneof=Yes No"`
-	Symptoms            string `json:"symptoms"` // Comma-separated list for ML
+	Symptoms            string `gorm:"serializer:encrypted" json:"symptoms"` // Comma-separated list for ML
+	Source              string `json:"source,omitempty"` // "kiosk" for self-reported intake, "screening" for batch assessments, "import" for CSV imports, "fhir" for FHIR bundles, "hl7" for HL7 v2 messages; empty for clinician-entered
+	ImputedFields       string `json:"imputed_fields,omitempty"` // Comma-separated measurements never provided; left to the ML model to impute
+	Clinic              string `gorm:"index" json:"clinic,omitempty"` // Site the patient was seen at; sets notification working hours
+
+	// Responsible clinician; only changed through POST /api/patients/:id/assign
+	AssignedProviderID *uint     `gorm:"index" json:"assigned_provider_id,omitempty"`
+	AssignedProvider   *Provider `gorm:"foreignKey:AssignedProviderID" json:"assigned_provider,omitempty"`
+}
+
+// Provider is a clinician in the directory who can be responsible for patients
+type Provider struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    *uint     `gorm:"uniqueIndex" json:"user_id,omitempty"` // Login account, when the provider has one
+	Name      string    `json:"name" validate:"required,max=200"`
+	Specialty string    `json:"specialty" validate:"max=100"`
+	OnCall    bool      `gorm:"index" json:"on_call"`
+	Active    bool      `json:"active"`
 }
 
 type Feedback struct {
@@ -36,6 +75,200 @@ type Feedback struct {
 	DoctorApproved bool      `json:"doctor_approved"`
 	DoctorNotes    string    `json:"doctor_notes"`
 	RiskProfile    string    `gorm:"type:text" json:"risk_profile"` // JSON string of risks
+	ICD10Codes     string    `json:"icd10_codes,omitempty"`              // Comma-separated codes the doctor confirmed
+	AuthorID       string    `json:"author_id,omitempty"`                // Actor who submitted it; empty on feedback from before authors were kept
+}
+
+// FeedbackRevision is one version of a feedback's doctor notes. Rows are
+// only ever added: the first amendment copies the original in as revision
+// 1, then each amendment appends the notes it set. Feedback never amended
+// has no revisions.
+type FeedbackRevision struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time `json:"created_at"` // When this version was written
+	FeedbackID  uint      `gorm:"uniqueIndex:idx_feedback_revisions_feedback_revision" json:"feedback_id"`
+	Revision    int       `gorm:"uniqueIndex:idx_feedback_revisions_feedback_revision" json:"revision"`
+	DoctorNotes string    `json:"doctor_notes"`
+	AuthorID    string    `json:"author_id,omitempty"`
+	Reason      string    `json:"reason,omitempty"` // Why the notes were amended; empty for the original
+}
+
+// FeedbackPage is one page of GET /api/feedback
+type FeedbackPage struct {
+	Total int64      `json:"total"` // Matching feedback across all pages
+	Page  int        `json:"page"`
+	Limit int        `json:"limit"`
+	Items []Feedback `json:"items"`
+}
+
+// Assessment is the persisted outcome of a single risk assessment run
+type Assessment struct {
+	ID                 uint                  `gorm:"primaryKey" json:"id"`
+	CreatedAt          time.Time             `gorm:"index" json:"created_at"`
+	PatientID          uint                  `gorm:"index" json:"patient_id"`
+	HeartRisk          float64               `json:"heart_risk_score"`
+	DiabetesRisk       float64               `json:"diabetes_risk_score"`
+	StrokeRisk         float64               `json:"stroke_risk_score"`
+	KidneyRisk         float64               `json:"kidney_risk_score"`
+	GeneralHealthScore float64               `json:"general_health_score"`
+	ClinicalConfidence float64               `json:"clinical_confidence"`
+	RuleBased          bool                  `json:"rule_based"` // Heuristic fallback, ML not consulted
+	MLBackend          string                `json:"ml_backend"` // "primary" or "canary"
+	Emergency          bool                  `json:"emergency"`
+	AuditHash          string                `json:"audit_hash"`
+	Precisions         []AssessmentPrecision `gorm:"foreignKey:AssessmentID" json:"precisions,omitempty"`
+
+	// Patient data exactly as submitted (after validation, before symptom
+	// splitting or name stripping). Reports read this, never the live row.
+	PatientSnapshot    string `gorm:"type:text;serializer:encrypted" json:"-"`
+	SnapshotHash       string `json:"snapshot_hash"`
+	SnapshotBackfilled bool   `json:"snapshot_backfilled"` // Rebuilt from the live row by migration
+
+	// RiskExplanations as JSON; empty until risks are known
+	Explanations string `gorm:"type:text" json:"-"`
+	// The ML service's per-model feature contributions as JSON; empty for
+	// rule-based fallbacks, whose factors are rebuilt from the snapshot
+	Contributions string `gorm:"type:text" json:"-"`
+}
+
+// SetPatientSnapshot freezes the patient data this assessment was based on
+func (a *Assessment) SetPatientSnapshot(p PatientData) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	a.PatientSnapshot = string(data)
+	a.SnapshotHash = SnapshotHash(a.PatientSnapshot)
+	return nil
+}
+
+// Patient decodes the frozen patient data
+func (a *Assessment) Patient() (PatientData, error) {
+	var p PatientData
+	err := json.Unmarshal([]byte(a.PatientSnapshot), &p)
+	return p, err
+}
+
+// SetExplanations stores the plain-language risk explanations
+func (a *Assessment) SetExplanations(e []RiskExplanation) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	a.Explanations = string(data)
+	return nil
+}
+
+// SetContributions stores the raw per-model feature contributions
+func (a *Assessment) SetContributions(c map[string]map[string]float64) error {
+	a.Contributions = ""
+	if len(c) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	a.Contributions = string(data)
+	return nil
+}
+
+// FeatureContributions decodes the stored contributions
+func (a *Assessment) FeatureContributions() map[string]map[string]float64 {
+	contributions := map[string]map[string]float64{}
+	if a.Contributions != "" {
+		json.Unmarshal([]byte(a.Contributions), &contributions)
+	}
+	return contributions
+}
+
+// RiskExplanations decodes the stored explanations; assessments from before
+// explanations were kept have none
+func (a *Assessment) RiskExplanations() []RiskExplanation {
+	explanations := []RiskExplanation{}
+	if a.Explanations != "" {
+		json.Unmarshal([]byte(a.Explanations), &explanations)
+	}
+	return explanations
+}
+
+// SnapshotHash is the SHA-256 of a serialized patient snapshot
+func SnapshotHash(snapshot string) string {
+	sum := sha256.Sum256([]byte(snapshot))
+	return hex.EncodeToString(sum[:])
+}
+
+// Assessment components run under the request latency budget
+const (
+	ComponentRisks       = "risks"
+	ComponentUrgency     = "urgency"
+	ComponentMedications = "medications"
+)
+
+// Component statuses
+const (
+	ComponentReady   = "ready"
+	ComponentPending = "pending"
+	ComponentError   = "error"
+)
+
+// AssessmentComponent records how one part of an assessment finished.
+// Components that missed the latency budget are stored as pending and
+// updated by the completion job.
+type AssessmentComponent struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	AssessmentID uint      `gorm:"index" json:"assessment_id"`
+	Component    string    `json:"component"`
+	Status       string    `json:"status"`
+	Late         bool      `json:"late"` // Finished after the response was sent
+	LatencyMs    int64     `json:"latency_ms"`
+	Error        string    `json:"error,omitempty"`
+	Result       any       `gorm:"serializer:json;type:text" json:"result,omitempty"`
+}
+
+// BudgetReport tells the client which components made it into the response
+type BudgetReport struct {
+	BudgetMs  int64    `json:"budget_ms"`
+	ElapsedMs int64    `json:"elapsed_ms"`
+	Completed []string `json:"completed"`
+	Pending   []string `json:"pending"`
+}
+
+// Self-test stage outcomes
+const (
+	SelfTestPass = "pass"
+	SelfTestFail = "fail"
+	SelfTestSkip = "skip" // Optional dependency not available; doesn't fail the run
+)
+
+// SelfTestStage is one step of POST /api/admin/selftest
+type SelfTestStage struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	DurationMs int64  `json:"duration_ms"`
+	Detail     string `json:"detail,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// SelfTestReport is the result of running a synthetic patient through the pipeline
+type SelfTestReport struct {
+	Passed     bool            `json:"passed"`
+	StartedAt  time.Time       `json:"started_at"`
+	DurationMs int64           `json:"duration_ms"`
+	Stages     []SelfTestStage `json:"stages"`
+	CleanedUp  bool            `json:"cleaned_up"` // Scratch database and ledger discarded
+}
+
+// AssessmentPrecision keeps each model's confidence for drift monitoring
+type AssessmentPrecision struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time `gorm:"index" json:"created_at"`
+	AssessmentID uint      `gorm:"index" json:"assessment_id"`
+	ModelName    string    `gorm:"index" json:"model_name"`
+	Confidence   float64   `json:"confidence"`
+	RuleBased    bool      `json:"rule_based"`
 }
 
 // -- API Communication Structs --
@@ -46,6 +279,32 @@ type APIResponse struct {
 	Error   string `json:"error,omitempty"`
 }
 
+// ScoreScale is the top of the risk score scale. Risk scores, the general
+// health score and clinical confidence are percentages whether they come from
+// the ML service or the rule-based fallback.
+const ScoreScale = 100.0
+
+// EmergencyHeartRisk is the heart risk above which an assessment is an emergency
+const EmergencyHeartRisk = 0.85 * ScoreScale
+
+// RiskThresholds split the highest of a patient's four risk scores into
+// the dashboard's Low, Medium and High levels
+type RiskThresholds struct {
+	Medium float64 `json:"medium"` // Scores from here up are Medium
+	High   float64 `json:"high"`   // Scores from here up are High
+}
+
+// Level names the level of a risk score
+func (t RiskThresholds) Level(score float64) string {
+	switch {
+	case score >= t.High:
+		return "High"
+	case score >= t.Medium:
+		return "Medium"
+	}
+	return "Low"
+}
+
 type PredictResponse struct {
 	HeartRisk          float64                       `json:"heart_risk_score"`
 	DiabetesRisk       float64                       `json:"diabetes_risk_score"`
@@ -55,12 +314,411 @@ type PredictResponse struct {
 	ClinicalConfidence float64                       `json:"clinical_confidence"`
 	ModelPrecisions    map[string]float64            `json:"model_precisions"`
 	Explanations       map[string]map[string]float64 `json:"explanations"`
+	Backend            string                        `json:"ml_backend,omitempty"` // Which ML deployment served it
+	Degraded           bool                          `json:"degraded"`             // Rule-based fallback, ML unavailable
+	Source             string                        `json:"source"`               // PredictionSource*; empty while the risks are pending
+}
+
+// ParseRiskProfile reads a risk snapshot sent with feedback. It must be a
+// PredictResponse, unknown fields and all scores outside 0-100 rejected, so
+// the decision can later be compared with what the models said. Empty or
+// null is no snapshot.
+func ParseRiskProfile(raw []byte) (*PredictResponse, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+	var risks PredictResponse
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&risks); err != nil {
+		return nil, fmt.Errorf("risks must be an assessment's risk scores: %w", err)
+	}
+	scores := map[string]float64{
+		"heart_risk_score": risks.HeartRisk, "diabetes_risk_score": risks.DiabetesRisk,
+		"stroke_risk_score": risks.StrokeRisk, "kidney_risk_score": risks.KidneyRisk,
+		"general_health_score": risks.GeneralHealthScore, "clinical_confidence": risks.ClinicalConfidence,
+	}
+	for name, score := range scores {
+		if score < 0 || score > ScoreScale {
+			return nil, fmt.Errorf("risks: %s must be between 0 and %.0f", name, ScoreScale)
+		}
+	}
+	return &risks, nil
+}
+
+// Where a risk prediction came from
+const (
+	PredictionSourceML        = "ml"
+	PredictionSourceRuleBased = "rule_based" // Clinical heuristics; the ML models weren't consulted
+	PredictionSourceCached    = "cached"     // An earlier ML result for the same inputs
+)
+
+// PatientPage is one page of GET /api/patients
+type PatientPage struct {
+	Total int64         `json:"total"` // Matching patients across all pages
+	Page  int           `json:"page"`
+	Limit int           `json:"limit"`
+	Items []PatientData `json:"items"`
 }
 
+// RiskExplanation is a one-sentence, plain-language reason for a model's score
+type RiskExplanation struct {
+	Model   string `json:"model"`  // "heart", "diabetes", "stroke" or "kidney"
+	Source  string `json:"source"` // "shap" for ML feature contributions, "rules" for the fallback heuristics
+	Summary string `json:"summary"`
+}
+
+// RiskFactor is one feature's part in a model's score
+type RiskFactor struct {
+	Feature      string  `json:"feature"` // Model feature key, e.g. "systolic_bp"
+	Label        string  `json:"label"`   // e.g. "Systolic Blood Pressure"
+	Contribution float64 `json:"contribution"`
+}
+
+// RuleThreshold is a fallback heuristic check that fired
+type RuleThreshold struct {
+	Feature string  `json:"feature"`
+	Label   string  `json:"label"`
+	Value   float64 `json:"value"`
+	Above   float64 `json:"above"`
+}
+
+// ModelFactors lists what drove one model's score. ML results carry
+// factors, largest absolute contribution first; rule-based fallbacks carry
+// the thresholds that fired instead.
+type ModelFactors struct {
+	Model      string          `json:"model"`
+	Source     string          `json:"source"`          // As in RiskExplanation
+	Level      string          `json:"level,omitempty"` // Rung of the fallback heuristic that applied
+	Factors    []RiskFactor    `json:"factors"`
+	Thresholds []RuleThreshold `json:"thresholds,omitempty"`
+}
+
+// APICredential is an API key managed from the database (healthctl), checked
+// alongside the API_KEYS environment entries. Only the SHA-256 of the key is stored.
+type APICredential struct {
+	ID        string     `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Role      string     `json:"role"`
+	KeyHash   string     `gorm:"uniqueIndex" json:"-"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+}
+
+// User is a person who signs in with a password for a bearer JWT. Only the
+// bcrypt hash of the password is stored.
+type User struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	Email        string    `gorm:"uniqueIndex" json:"email"`
+	Name         string    `json:"name,omitempty"`
+	Role         string    `json:"role"` // "doctor", "admin" or "user"
+	PasswordHash string    `json:"-"`
+}
+
+// RefreshToken lets a User get new access JWTs without signing in again.
+// Only the SHA-256 of the token is stored; each is exchanged once.
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    uint       `gorm:"index" json:"user_id"`
+	TokenHash string     `gorm:"uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"` // On exchange or logout
+}
+
+// UploadedFile tracks a file saved to the uploads volume so it can be
+// cleaned up once its analysis is done
+type UploadedFile struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time  `gorm:"index" json:"created_at"`
+	Path       string     `gorm:"uniqueIndex" json:"path"`
+	SHA256     string     `gorm:"column:sha256" json:"sha256"`
+	SizeBytes  int64      `json:"size_bytes"`
+	Purpose    string     `json:"purpose"` // e.g. "vitals"
+	Owner      string     `json:"owner"`   // Actor that uploaded it
+	Status     string     `gorm:"index" json:"status"` // processing, analyzed, failed, deleted
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// ConfigOverride is a runtime setting changed without a redeploy, e.g. a feature flag
+type ConfigOverride struct {
+	Key       string    `gorm:"primaryKey" json:"key"`
+	Value     string    `json:"value"` // JSON, interpreted by the owner of the key
+	UpdatedAt time.Time `json:"updated_at"`
+	UpdatedBy string    `json:"updated_by"`
+}
+
+// PrivacyBudget is the differential-privacy epsilon a caller has spent on
+// aggregate queries in one period (calendar month, UTC)
+type PrivacyBudget struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	Subject   string    `gorm:"uniqueIndex:idx_privacy_budget_period" json:"subject"` // Actor ID, e.g. "apikey:partner"
+	Period    string    `gorm:"uniqueIndex:idx_privacy_budget_period" json:"period"`  // "2026-10"
+	Spent     float64   `json:"spent"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Clinic holds per-site settings. ID matches intake token clinics and the X-Clinic-ID header.
+type Clinic struct {
+	ID        string          `gorm:"primaryKey" json:"id"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	Name      string          `json:"name"`
+	Timezone  string          `json:"timezone"`                                  // IANA name, e.g. "Europe/Istanbul"
+	Hours     []WorkingWindow `gorm:"serializer:json;type:text" json:"hours"`    // No windows means always open
+	Holidays  []string        `gorm:"serializer:json;type:text" json:"holidays"` // Closed days, "2026-12-25" in clinic time
+}
+
+// WorkingWindow is one staffed period on a weekday, in clinic local time
+type WorkingWindow struct {
+	Weekday time.Weekday `json:"weekday"` // 0 = Sunday
+	Start   string       `json:"start"`   // "08:30"
+	End     string       `json:"end"`     // "17:00", after Start on the same day
+}
+
+// NotificationLog records each clinical notification and when and to whom it
+// went, including why it was deferred or escalated outside working hours
+type NotificationLog struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time  `gorm:"index" json:"created_at"`
+	Kind        string     `gorm:"index" json:"kind"` // "emergency_alert" or "emergency_webhook"
+	Critical    bool       `json:"critical"`
+	PatientID   uint       `gorm:"index" json:"patient_id"`
+	Clinic      string     `json:"clinic,omitempty"`
+	Decision    string     `gorm:"index" json:"decision"` // "sent", "deferred" or "escalated"; webhooks "sent" or "failed"
+	Reason      string     `json:"reason"`
+	Recipients  []uint     `gorm:"serializer:json;type:text" json:"recipients"` // Provider IDs, filled in when sent
+	Destination string     `json:"destination,omitempty"`                       // Webhook URL
+	Attempts    int        `json:"attempts,omitempty"`                          // Webhook POSTs made, retries included
+	Payload     string     `gorm:"type:text" json:"-"`
+	DeliverAt   time.Time  `gorm:"index" json:"deliver_at"`
+	SentAt      *time.Time `json:"sent_at,omitempty"`
+}
+
+// EmergencyWebhook is the JSON POSTed to each EMERGENCY_WEBHOOK_URLS
+// destination when an assessment is flagged as an emergency
+type EmergencyWebhook struct {
+	Event          string             `json:"event"` // "emergency"
+	PatientID      uint               `json:"patient_id"`
+	AssessmentID   uint               `json:"assessment_id"`
+	TriggeredRules []string           `json:"triggered_rules"`
+	RiskScores     map[string]float64 `json:"risk_scores"`
+	Timestamp      time.Time          `json:"timestamp"`
+	Link           string             `json:"link"` // The assessment report
+}
+
+// IntakeToken is a single-use credential letting a waiting-room kiosk create one patient.
+// Only the SHA-256 of the token is stored.
+type IntakeToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	TokenHash string     `gorm:"uniqueIndex" json:"-"`
+	Clinic    string     `gorm:"index" json:"clinic"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	PatientID *uint      `json:"patient_id,omitempty"`
+}
+
+// KioskIntakeRequest is everything a patient may self-report at a kiosk.
+// Vitals and history stay clinician-entered.
+// ErasureConfirmation is a single-use token an admin must send back to
+// erase a patient, so one stray request can't wipe a record. Only the
+// SHA-256 of the token is stored.
+type ErasureConfirmation struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	TokenHash string     `gorm:"uniqueIndex" json:"-"`
+	PatientID uint       `gorm:"index" json:"patient_id"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+// ErasureReport lists what a GDPR erasure removed from a patient's records
+// and what it kept, with the legal basis for keeping it
+type ErasureReport struct {
+	PatientID uint          `json:"patient_id"`
+	ErasedAt  time.Time     `json:"erased_at"`
+	Removed   []ErasureItem `json:"removed"`
+	Retained  []ErasureItem `json:"retained"`
+}
+
+// ErasureItem is one kind of record in an ErasureReport
+type ErasureItem struct {
+	Table      string `json:"table"`
+	Records    int64  `json:"records"`
+	Action     string `json:"action,omitempty"` // "overwritten", "redacted", "deleted" or "purged"
+	Data       string `json:"data"`             // What was removed or kept
+	LegalBasis string `json:"legal_basis,omitempty"`
+}
+
+type KioskIntakeRequest struct {
+	Age         int    `json:"age" validate:"required,min=0,max=150"`
+	Gender      string `json:"gender" validate:"required,oneof=Male Female Other"`
+	Smoking     string `json:"smoking" validate:"omitempty,oneof=Yes No Former"`
+	Alcohol     string `json:"alcohol" validate:"omitempty,oneof=Yes No"`
+	Symptoms    string `json:"symptoms" validate:"max=1000"`
+	Medications string `json:"medications" validate:"max=1000"`
+	Allergies   string `json:"allergies" validate:"max=1000"`
+}
+
+// FeedbackRequest is the doctor's verdict on an assessment
+type FeedbackRequest struct {
+	AssessmentID    int             `json:"assessment_id" validate:"required,min=1"`
+	Approved        bool            `json:"approved"`
+	Notes           string          `json:"notes" validate:"max=5000"`
+	Risks           json.RawMessage `json:"risks"` // The assessment's PredictResponse as the doctor saw it; required when rejecting
+	OverrideDetails *OverrideLog    `json:"override_details"`
+	ICD10Codes      []string        `json:"icd10_codes" validate:"max=20"` // Confirmed billing codes, from the suggestions or the code table
+}
+
+// FeedbackAmendment replaces a feedback's doctor notes, keeping the old ones
+type FeedbackAmendment struct {
+	Notes  string `json:"notes" validate:"required,max=5000"`
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+// Diagnosis queue priorities; emergencies are diagnosed ahead of routine patients
+const (
+	DiagnosisPriorityRoutine   = "routine"
+	DiagnosisPriorityEmergency = "emergency"
+)
+
 type DiagnosisRequest struct {
 	Patient     PatientData     `json:"patient"`
 	RiskScores  PredictResponse `json:"risk_scores"`
 	PastContext string          `json:"past_context"` // RAG-Lite: Past doctor feedbacks
+	Generation  uint64          `json:"generation,omitempty"` // Diagnosis token; stale generations are discarded
+	Priority    string          `json:"priority,omitempty"`   // DiagnosisPriorityEmergency jumps the queue; empty is routine
+	RequestID   string          `json:"request_id,omitempty"` // X-Request-ID of the assessment, for the worker's logs and ML call
+}
+
+// DiagnosisContext records the RAG context actually attached to an LLM request,
+// after PHI redaction, so we can prove what left the backend.
+type DiagnosisContext struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	PatientID      uint      `gorm:"index" json:"patient_id"`
+	PastContext    string    `gorm:"type:text" json:"past_context"`
+	PrivacyMode    string    `json:"privacy_mode"`
+	RedactionCount int       `json:"redaction_count"`
+	Blocked        bool      `json:"blocked"`
+
+	// What was queued for the LLM, recorded once the diagnosis starts. Rows
+	// from before prompt persistence leave these empty.
+	AssessmentID  uint   `gorm:"index" json:"assessment_id,omitempty"`
+	PromptVersion string `json:"prompt_version,omitempty"`
+	SystemPrompt  string `gorm:"type:text" json:"-"`
+	Prompt        string `gorm:"type:text;serializer:encrypted" json:"-"`
+	LLMModel      string `json:"llm_model,omitempty"`
+	Request       string `gorm:"type:text;serializer:encrypted" json:"-"` // Exact body posted to the ML /diagnose endpoint
+	MLEndpoint    string `json:"ml_endpoint,omitempty"`
+	Generation    uint64 `json:"generation,omitempty"`
+
+	// The finished diagnosis, saved when it's ready so search can find it
+	// after the cache has expired
+	Diagnosis string `gorm:"type:text;serializer:encrypted" json:"-"`
+}
+
+// DiagnosisPrompt is everything sent to the LLM for one assessment's
+// diagnosis, assembled for transparency review
+type DiagnosisPrompt struct {
+	AssessmentID   uint            `json:"assessment_id"`
+	PatientID      uint            `json:"patient_id"`
+	ContextID      uint            `json:"context_id"`
+	RecordedAt     time.Time       `json:"recorded_at"`
+	PromptVersion  string          `json:"prompt_version"`
+	LLMModel       string          `json:"llm_model"`
+	SystemPrompt   string          `json:"system_prompt"`
+	Prompt         string          `json:"prompt"`
+	PastContext    string          `json:"past_context"` // After PHI redaction
+	PrivacyMode    string          `json:"privacy_mode"`
+	RedactionCount int             `json:"redaction_count"`
+	ContextBlocked bool            `json:"context_blocked"`
+	RiskScores     PredictResponse `json:"risk_scores"`
+	MLEndpoint     string          `json:"ml_endpoint"`
+	MLBackend      string          `json:"ml_backend"` // Risk model deployment that scored the patient
+	RuleBased      bool            `json:"rule_based"`
+	Generation     uint64          `json:"generation"`
+	Request        json.RawMessage `json:"request"`
+}
+
+// DiagnosisFailure is an LLM diagnosis task that failed every attempt and
+// was dead-lettered. Requeueing starts a fresh diagnosis from Request.
+type DiagnosisFailure struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time  `gorm:"index" json:"created_at"`
+	PatientID  uint       `gorm:"index" json:"patient_id"`
+	Generation uint64     `json:"generation"`
+	Attempts   int        `json:"attempts"`
+	Error      string     `json:"error"`
+	Request    string     `gorm:"type:text" json:"-"` // The DiagnosisRequest as sent, JSON
+	RequeuedAt *time.Time `gorm:"index" json:"requeued_at,omitempty"`
+}
+
+// Search document sources
+const (
+	SearchFeedback  = "feedback"  // Feedback.DoctorNotes
+	SearchDiagnosis = "diagnosis" // DiagnosisContext.Diagnosis
+)
+
+// SearchDocument is a piece of free text the search index covers, copied
+// from its source row whenever that row is saved
+type SearchDocument struct {
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	Source       string `gorm:"uniqueIndex:idx_search_documents_source" json:"source"`
+	SourceID     uint   `gorm:"uniqueIndex:idx_search_documents_source" json:"source_id"`
+	PatientID    uint   `gorm:"index" json:"patient_id"`
+	AssessmentID uint   `json:"assessment_id"`
+	Body         string `gorm:"type:text" json:"-"`
+}
+
+// SearchDocument is the feedback's doctor notes as search indexes them
+func (f Feedback) SearchDocument() SearchDocument {
+	assessmentID, _ := strconv.ParseUint(f.AssessmentID, 10, 64)
+	return SearchDocument{Source: SearchFeedback, SourceID: f.ID, PatientID: f.PatientID, AssessmentID: uint(assessmentID), Body: f.DoctorNotes}
+}
+
+// Risks decodes the risk snapshot stored with the feedback; nil when none
+// was sent
+func (f Feedback) Risks() (*PredictResponse, error) {
+	if f.RiskProfile == "" {
+		return nil, nil
+	}
+	var risks PredictResponse
+	if err := json.Unmarshal([]byte(f.RiskProfile), &risks); err != nil {
+		return nil, fmt.Errorf("feedback %d risk profile: %w", f.ID, err)
+	}
+	return &risks, nil
+}
+
+// SearchDocument is the finished diagnosis as search indexes it
+func (d DiagnosisContext) SearchDocument() SearchDocument {
+	return SearchDocument{Source: SearchDiagnosis, SourceID: d.ID, PatientID: d.PatientID, AssessmentID: d.AssessmentID, Body: d.Diagnosis}
+}
+
+// NoteEmbedding is the vector of an approved feedback's doctor notes, as
+// little-endian float32s, from the embedding model named
+type NoteEmbedding struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	FeedbackID uint      `gorm:"uniqueIndex" json:"feedback_id"`
+	Model      string    `json:"model"`
+	Vector     []byte    `json:"-"`
+}
+
+// OutboxMessage is a NATS message saved in the transaction whose rows it
+// announces, and sent once that transaction has committed
+type OutboxMessage struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	PatientID uint       `gorm:"index" json:"patient_id,omitempty"` // The patient the message is about, if any
+	Subject   string     `json:"subject"`
+	Data      string     `gorm:"type:text;serializer:encrypted" json:"-"` // Cleared once sent
+	Header    string     `gorm:"type:text" json:"-"`                      // JSON nats.Header, e.g. the trace context
+	Attempts  int        `json:"attempts"`
+	LastError string     `json:"last_error,omitempty"`
+	SentAt    *time.Time `gorm:"index" json:"sent_at,omitempty"`
 }
 
 type DiagnosisResponse struct {
@@ -69,21 +727,188 @@ type DiagnosisResponse struct {
 }
 
 type FullAssessmentResponse struct {
-	ID              uint              `json:"id"`
-	Risks           PredictResponse   `json:"risks"`
-	Urgency         UrgencyResponse   `json:"urgency"`
-	Diagnosis       string            `json:"diagnosis"`
-	DiagnosisStatus string            `json:"diagnosis_status"` // "pending", "ready", "error"
-	Emergency       bool              `json:"emergency"`
-	Patient         PatientData       `json:"patient"`
-	Medications     InteractionResult `json:"medication_analysis"`
-	ModelPrecisions []ModelPrecision  `json:"model_precisions"`
-	AuditHash       string            `json:"audit_hash"`
+	ID               uint              `json:"id"`
+	AssessmentID     uint              `json:"assessment_id"`
+	Risks            PredictResponse   `json:"risks"`
+	Urgency          UrgencyResponse   `json:"urgency"`
+	Diagnosis        string            `json:"diagnosis"`
+	DiagnosisStatus  string            `json:"diagnosis_status"` // "pending", "ready", "error"
+	Emergency        bool              `json:"emergency"`
+	EmergencyReasons []string          `json:"emergency_reasons"` // Descriptions of the emergency rules that fired
+	RequiresReview   bool              `json:"requires_review"` // A medication conflicts with a recorded allergy
+	Patient          PatientData       `json:"patient"`
+	Medications      InteractionResult `json:"medication_analysis"`
+	ModelPrecisions  []ModelPrecision  `json:"model_precisions"`
+	AuditHash        string            `json:"audit_hash"`
+	CodedSymptoms    []CodedSymptom    `json:"coded_symptoms"`
+	UnmappedSymptoms []string          `json:"unmapped_symptoms"`
+	Budget           *BudgetReport     `json:"budget,omitempty"` // Set when a latency budget is enforced
+	PredictionSource string            `json:"prediction_source"` // Risks.Source, for the degraded-mode banner
+
+	ExplanationSummaries []RiskExplanation `json:"explanation_summaries"`
+	Explanations         []ModelFactors    `json:"explanations"` // Top contributing factors per model
+}
+
+// BatchAssessResponse is the outcome of a batch assessment: one result per
+// assessed row and one error per row that was invalid or failed
+type BatchAssessResponse struct {
+	Total           int                 `json:"total"`
+	Assessed        int                 `json:"assessed"`
+	DiagnosisQueued bool                `json:"diagnosis_queued"` // LLM diagnoses were started for the assessed rows
+	Results         []BatchAssessResult `json:"results"`
+	Errors          []BatchRowError     `json:"errors"`
+}
+
+// BatchAssessResult is one assessed row; Row is its index in the request
+type BatchAssessResult struct {
+	Row              int             `json:"row"`
+	PatientID        uint            `json:"patient_id"`
+	AssessmentID     uint            `json:"assessment_id"`
+	Risks            PredictResponse `json:"risks"`
+	Emergency        bool            `json:"emergency"`
+	EmergencyReasons []string        `json:"emergency_reasons"`
+	AuditHash        string          `json:"audit_hash"`
+	PredictionSource string          `json:"prediction_source"`
+}
+
+// BatchRowError is a row that was not assessed
+type BatchRowError struct {
+	Row    int    `json:"row"`
+	Error  string `json:"error"`
+	Fields any    `json:"fields,omitempty"` // Per-field errors, as /api/assess returns them
+}
+
+// ImportSummary is the outcome of a CSV patient import. In a dry run
+// Created counts the patients that would have been created.
+type ImportSummary struct {
+	DryRun          bool             `json:"dry_run"`
+	Created         int              `json:"created"`
+	Skipped         int              `json:"skipped"` // Rows that were invalid
+	Errors          []ImportRowError `json:"errors"`
+	ErrorsTruncated bool             `json:"errors_truncated,omitempty"` // More rows were skipped than are listed
+	IgnoredColumns  []string         `json:"ignored_columns"`            // Headers that map to no patient field
+}
+
+// ImportRowError is a skipped row; Row is its line in the file, the header being line 1
+type ImportRowError struct {
+	Row    int    `json:"row"`
+	Reason string `json:"reason"`
+}
+
+// FHIRImportResponse is the outcome of ingesting a FHIR bundle. Warnings
+// list the parts of the bundle that were not used; Assessment is set when
+// one was requested.
+type FHIRImportResponse struct {
+	PatientID  uint               `json:"patient_id"`
+	Patient    PatientData        `json:"patient"`
+	Warnings   []string           `json:"warnings"`
+	Assessment *BatchAssessResult `json:"assessment,omitempty"`
+}
+
+// PatientQueueItem is the compact sidebar entry for a patient
+type PatientQueueItem struct {
+	ID         uint      `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	Name       string    `json:"name,omitempty"`
+	Age        int       `json:"age"`
+	Gender     string    `json:"gender"`
+	SystolicBP int       `json:"systolic_bp"`
+	Symptoms   string    `json:"symptoms,omitempty"`
+	Source     string    `json:"source,omitempty"`
+
+	AssignedProvider *Provider `json:"assigned_provider,omitempty"`
+}
+
+// NewPatientQueueItem projects a patient onto its queue entry
+func NewPatientQueueItem(p PatientData) PatientQueueItem {
+	return PatientQueueItem{
+		ID:         p.ID,
+		CreatedAt:  p.CreatedAt,
+		Name:       p.Name,
+		Age:        p.Age,
+		Gender:     p.Gender,
+		SystolicBP: p.SystolicBP,
+		Symptoms:   p.Symptoms,
+		Source:     p.Source,
+
+		AssignedProvider: p.AssignedProvider,
+	}
+}
+
+// QueueEvent is a "queue_update" WebSocket message. Seq increases
+// monotonically, so clients drop any event older than the last one they
+// applied for the same patient.
+type QueueEvent struct {
+	Type    string           `json:"type"`
+	Action  string           `json:"action"` // created, updated, deleted
+	Seq     int64            `json:"seq"`
+	Patient PatientQueueItem `json:"patient"`
+}
+
+// DiagnosisUpdate is published on cache.DiagnosisChannel when an LLM worker
+// finishes, so every backend instance can push it to its WebSocket clients
+type DiagnosisUpdate struct {
+	PatientID uint   `json:"patient_id"`
+	Diagnosis string `json:"diagnosis"`
+	Status    string `json:"status"`
+}
+
+// DBBackup describes one backup file in the backup directory
+type DBBackup struct {
+	Filename  string    `json:"filename"`
+	SizeBytes int64     `json:"size_bytes"`
+	SHA256    string    `json:"sha256"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CodedSymptom is a free-text symptom resolved to a SNOMED CT concept
+type CodedSymptom struct {
+	Text    string `json:"text"`
+	Code    string `json:"code"`
+	Display string `json:"display"`
 }
 
 type InteractionResult struct {
-	Risky []string `json:"risky"`
-	Safe  []string `json:"safe"`
+	Risky        []string      `json:"risky"`
+	Safe         []string      `json:"safe"`
+	Interactions []Interaction `json:"interactions"` // Why each risky medication was flagged
+
+	AllergyConflicts []AllergyConflict `json:"allergy_conflicts"`
+}
+
+// AllergyConflict is a listed medication that matches a recorded allergy
+type AllergyConflict struct {
+	Medication string `json:"medication"` // As entered
+	Allergy    string `json:"allergy"`    // As recorded
+	Reason     string `json:"reason"`
+}
+
+// Interaction is one warning found in a patient's medication list, e.g.
+// Metformin + Contrast: risk of lactic acidosis (major)
+type Interaction struct {
+	DrugA    string `json:"drug_a"`
+	DrugB    string `json:"drug_b"`
+	Severity string `json:"severity"` // minor, moderate or major
+	Reason   string `json:"reason"`
+}
+
+// DrugInteraction is a row of the interaction table. DrugB is a second drug,
+// or with Condition set an exposure the medication list can't rule out (such
+// as iodinated contrast), which is flagged whenever DrugA is taken.
+type DrugInteraction struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	DrugA       string `gorm:"uniqueIndex:idx_drug_interactions_pair" json:"drug_a"`
+	DrugB       string `gorm:"uniqueIndex:idx_drug_interactions_pair" json:"drug_b"`
+	Condition   bool   `json:"condition"`
+	Severity    string `json:"severity"`
+	Description string `json:"description"`
+}
+
+// DrugAlias maps a brand or alternative name to the generic name used in
+// the interaction table
+type DrugAlias struct {
+	Alias   string `gorm:"primaryKey" json:"alias"` // Lowercase
+	Generic string `json:"generic"`
 }
 
 type ModelPrecision struct {
@@ -104,13 +929,41 @@ type DiseasePrediction struct {
 	Confidence  string  `json:"confidence"`
 }
 
+// ICD10Code is a row of the ICD-10 code table. Keywords are matched against
+// diagnosis text and disease prediction names to suggest the code.
+type ICD10Code struct {
+	Code        string `gorm:"primaryKey" json:"code"` // e.g. I50.9
+	Description string `json:"description"`
+	Keywords    string `json:"keywords"` // Comma-separated, e.g. "heart failure, chf"
+}
+
+// ICD10Suggestion is a code suggested for a diagnosis, with the evidence
+// behind it so a doctor can see why it was suggested
+type ICD10Suggestion struct {
+	Code            string   `json:"code"`
+	Description     string   `json:"description"`
+	Confidence      float64  `json:"confidence"`                 // 0-1
+	MatchedKeywords []string `json:"matched_keywords"`           // Found in the diagnosis text
+	MatchedDiseases []string `json:"matched_diseases,omitempty"` // Top disease predictions naming a keyword
+}
+
+// CachedDiagnosis is a patient's latest diagnosis as the cache holds it
+type CachedDiagnosis struct {
+	Diagnosis        string            `json:"diagnosis"`
+	Status           string            `json:"status"`
+	ICD10Suggestions []ICD10Suggestion `json:"icd10_suggestions,omitempty"`
+}
+
 type DiseaseResponse struct {
-	Predictions []DiseasePrediction `json:"predictions"`
+	Predictions      []DiseasePrediction `json:"predictions"`
+	CodedSymptoms    []CodedSymptom      `json:"coded_symptoms,omitempty"`
+	UnmappedSymptoms []string            `json:"unmapped_symptoms,omitempty"`
 }
 
 type EKGRequest struct {
 	Signal       []float64 `json:"signal"`
 	SamplingRate int       `json:"sampling_rate"`
+	PatientID    uint      `json:"patient_id,omitempty"` // Stores the analysis for trend tracking
 }
 
 type EKGPrediction struct {
@@ -120,9 +973,32 @@ type EKGPrediction struct {
 }
 
 type EKGResponse struct {
-	Status      string          `json:"status"`
-	Predictions []EKGPrediction `json:"predictions"`
-	Features    map[string]any  `json:"features"`
+	Status         string          `json:"status"`
+	Predictions    []EKGPrediction `json:"predictions"`
+	Features       map[string]any  `json:"features"`
+	ParsedFeatures *EKGFeatures    `json:"parsed_features,omitempty"`
+	AnalysisID     uint            `json:"analysis_id,omitempty"`
+}
+
+// EKGFeatures are the typed heart-rate variability features we track over time.
+// Nil means the ML service didn't provide (or couldn't compute) the value.
+type EKGFeatures struct {
+	MeanHR      *float64 `json:"mean_hr,omitempty"`         // bpm
+	SDNN        *float64 `json:"sdnn_ms,omitempty"`         // Std dev of RR intervals
+	RMSSD       *float64 `json:"rmssd_ms,omitempty"`        // Root mean square of successive RR differences
+	QRSDuration *float64 `json:"qrs_duration_ms,omitempty"` // Only some models report it
+}
+
+// EKGAnalysis is a stored EKG result for trend charts
+type EKGAnalysis struct {
+	ID             uint           `gorm:"primaryKey" json:"id"`
+	CreatedAt      time.Time      `gorm:"index" json:"created_at"`
+	PatientID      uint           `gorm:"index" json:"patient_id"`
+	Status         string         `json:"status"`
+	TopCondition   string         `json:"top_condition"`
+	TopProbability float64        `json:"top_probability"`
+	EKGFeatures    `gorm:"embedded" json:"features"`
+	Extras         map[string]any `gorm:"serializer:json;type:text" json:"extras,omitempty"` // Unrecognized feature keys, kept verbatim
 }
 
 // -- Medical Urgency Structs --
@@ -133,6 +1009,41 @@ type UrgencyResponse struct {
 	Probability       float64 `json:"probability"`
 	Confidence        string  `json:"confidence"`
 	GoldenHourMinutes *int    `json:"golden_hour_minutes"`
+	Degraded          bool    `json:"degraded"` // ML unavailable: level 0 means unknown, not "no urgency"
+}
+
+// UrgencyRequest is the body of POST /api/urgency/predict. Omitted
+// cholesterol and heart rate are left for the ML model to impute.
+type UrgencyRequest struct {
+	Symptoms    []string `json:"symptoms" validate:"required,min=1,dive,required,max=200"`
+	Age         int      `json:"age" validate:"required,min=0,max=150"`
+	SystolicBP  int      `json:"systolic_bp" validate:"required,min=50,max=300"`
+	DiastolicBP int      `json:"diastolic_bp" validate:"required,min=30,max=200"`
+	Glucose     int      `json:"glucose" validate:"required,min=20,max=600"`
+	BMI         float64  `json:"bmi" validate:"required,min=10,max=80"`
+	Cholesterol int      `json:"cholesterol" validate:"omitempty,min=50,max=500"`
+	HeartRate   int      `json:"heart_rate" validate:"omitempty,min=30,max=250"`
+}
+
+// Patient converts the request to the vitals PredictUrgency sends
+func (r UrgencyRequest) Patient() PatientData {
+	var imputed []string
+	if r.Cholesterol == 0 {
+		imputed = append(imputed, "cholesterol")
+	}
+	if r.HeartRate == 0 {
+		imputed = append(imputed, "heart_rate")
+	}
+	return PatientData{
+		Age:           r.Age,
+		SystolicBP:    r.SystolicBP,
+		DiastolicBP:   r.DiastolicBP,
+		Glucose:       r.Glucose,
+		BMI:           r.BMI,
+		Cholesterol:   r.Cholesterol,
+		HeartRate:     r.HeartRate,
+		ImputedFields: strings.Join(imputed, ","),
+	}
 }
 
 type MLUrgencyRequest struct {
@@ -144,45 +1055,281 @@ type MLUrgencyRequest struct {
 
 // AuditLog represents a single entry in the cryptographic audit chain.
 // Each entry contains the hash of the previous entry, making any tampering detectable.
+
 type AuditLog struct {
 	ID             uint      `gorm:"primaryKey" json:"id"`
 	Timestamp      time.Time `json:"timestamp"`
-	EventType      string    `json:"event_type"`      // AI_PREDICTION, DOCTOR_FEEDBACK, PATIENT_CREATED, etc.
-	PatientIDHash  string    `json:"patient_id_hash"` // SHA-256 hash of patient ID for privacy
-	PayloadHash    string    `json:"payload_hash"`    // SHA-256 hash of the full event payload
-	PrevHash       string    `json:"prev_hash"`       // Hash of the previous audit entry (chain link)
-	CurrentHash    string    `json:"current_hash"`    // Hash of this entire entry
-	ActorID        string    `json:"actor_id"`        // Who triggered this event (e.g., "system", "doctor_123")
-	ActorSignature string    `json:"actor_signature"` // Ed25519 signature of the event
-	ActorPublicKey string    `json:"actor_public_key"` // Public key to verify the signature
+	EventType      string    `json:"event_type"`                        // AI_PREDICTION, DOCTOR_FEEDBACK, PATIENT_CREATED, etc.
+	PatientIDHash  string    `json:"patient_id_hash"`                   // SHA-256 hash of patient ID for privacy
+	PayloadHash    string    `json:"payload_hash"`                      // SHA-256 hash of the full event payload
+	PrevHash       string    `json:"prev_hash"`                         // Hash of the previous audit entry (chain link)
+	CurrentHash    string    `json:"current_hash"`                      // Hash of this entire entry
+	ActorID        string    `gorm:"index" json:"actor_id"`             // Who triggered this event (e.g., "system", "user:12", "apikey:triage-bot")
+	ActorRole      string    `json:"actor_role"`                        // Role the actor held (e.g., "system", "clinician", "anonymous")
+	ActorSignature string    `json:"actor_signature"`                   // Ed25519 signature of the event
+	ActorPublicKey string    `json:"actor_public_key"`                  // Public key to verify the signature
+	KeyID          string    `gorm:"index" json:"key_id"`               // Fingerprint of ActorPublicKey; empty on entries from before persistent keys
+	RequestID      string    `gorm:"index" json:"request_id,omitempty"` // X-Request-ID the event was logged in; empty outside requests
+}
+
+// AuditLogPage is one page of audit entries
+type AuditLogPage struct {
+	Total int64      `json:"total"` // Matching entries across all pages
+	Page  int        `json:"page"`
+	Limit int        `json:"limit"`
+	Items []AuditLog `json:"items"`
+}
+
+// AuditKey is a signing key that appears in the audit log
+type AuditKey struct {
+	KeyID     string    `json:"key_id"`
+	PublicKey string    `json:"public_key"` // Hex-encoded Ed25519 public key
+	FirstUsed time.Time `json:"first_used"`
+	LastUsed  time.Time `json:"last_used"`
+	Entries   int64     `json:"entries"`
+	Current   bool      `json:"current"` // The key signing new entries
 }
 
 // OverrideLog captures detailed human-in-the-loop decisions for AI Act Article 14 compliance
 type OverrideLog struct {
-	OriginalPrediction string `json:"original_prediction"`
-	DoctorOverride     string `json:"doctor_override"`
-	Reason             string `json:"reason"`         // "Clinical Intuition", "Patient History Discrepancy"
-	OversightType      string `json:"oversight_type"` // "Human-in-the-Loop"
+	ID                 uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt          time.Time `gorm:"index" json:"created_at"`
+	FeedbackID         uint      `gorm:"index" json:"feedback_id"`
+	PatientID          uint      `gorm:"index" json:"patient_id"`
+	OriginalPrediction string    `json:"original_prediction"`
+	DoctorOverride     string    `json:"doctor_override"`
+	ReasonCode         string    `gorm:"index" json:"reason_code"` // Key into the OverrideReason taxonomy
+	Reason             string    `json:"reason"`                   // "Clinical Intuition", "Patient History Discrepancy"
+	ReasonText         string    `gorm:"type:text" json:"reason_text,omitempty"`
+	ModelName          string    `gorm:"index" json:"model_name"`                            // Which model's output was overridden
+	ModelsOverridden   []string  `gorm:"serializer:json;type:text" json:"models_overridden"` // Every risk model the doctor disagreed with, e.g. "Heart_Model"
+	OversightType      string    `json:"oversight_type"`                                     // "Human-in-the-Loop"
+	DoctorID           string    `gorm:"index" json:"doctor_id,omitempty"`                   // Actor who overrode; empty on overrides from before it was kept
+}
+
+// EmergencyRule flags an assessment as an emergency when Field compares to
+// Threshold with Operator. Admins edit them under /api/admin/emergency-rules.
+type EmergencyRule struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Field       string    `json:"field"`    // A vital such as "glucose", a risk score such as "heart_risk_score", or "urgency_level"
+	Operator    string    `json:"operator"` // ">", ">=", "<" or "<="
+	Threshold   float64   `json:"threshold"`
+	Enabled     bool      `json:"enabled"`
+	Description string    `json:"description"` // Shown in the alert banner when the rule fires
+}
+
+// OverrideReason is one entry of the admin-editable override taxonomy
+type OverrideReason struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	Code         string    `gorm:"uniqueIndex" json:"code"`
+	Label        string    `json:"label"`
+	RequiresText bool      `json:"requires_text"` // Submissions must explain in reason_text
+	Active       bool      `json:"active"`        // Inactive reasons stay reportable but can't be chosen
+}
+
+// OverrideCount is one bucket of the override report
+type OverrideCount struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+// OverrideGroup is one bucket of the override analytics
+type OverrideGroup struct {
+	Key     string  `json:"key"`
+	Count   int64   `json:"count"`
+	Percent float64 `json:"percent_of_assessments"` // Count per 100 assessments in the range
+}
+
+// OverrideAnalytics answers how often doctors overrode the models in a
+// time range, grouped one way, with the latest overrides in full
+type OverrideAnalytics struct {
+	From        *time.Time      `json:"from,omitempty"`
+	To          *time.Time      `json:"to,omitempty"`
+	GroupBy     string          `json:"group_by"`
+	Total       int64           `json:"total"`       // Overrides in the range
+	Assessments int64           `json:"assessments"` // Assessments in the range
+	Percent     float64         `json:"percent_of_assessments"`
+	Groups      []OverrideGroup `json:"groups"`
+	Recent      []OverrideLog   `json:"recent"` // Newest first
+}
+
+// OverrideReport aggregates overrides for Article 14 reporting
+type OverrideReport struct {
+	Since    time.Time       `json:"since"`
+	Total    int64           `json:"total"`
+	ByReason []OverrideCount `json:"by_reason"`
+	ByModel  []OverrideCount `json:"by_model"`
+	ByMonth  []OverrideCount `json:"by_month"`
 }
 
 // -- Dashboard Structs --
 
 type DashboardSummary struct {
-	TotalPatients     int64              `json:"total_patients"`
-	HighRiskPatients  int64              `json:"high_risk_patients"`
-	RecentAssessments int64              `json:"recent_assessments"`
-	SystemHealth      string             `json:"system_health"`
-	MLServicePulse    string             `json:"ml_service_pulse"`
-	AuditChainValid   bool               `json:"audit_chain_valid"`
-	RiskDistribution  map[string]int64    `json:"risk_distribution"`
-	Performance       PerformanceMetrics `json:"performance"`
+	TotalPatients       int64              `json:"total_patients"`
+	HighRiskPatients    int64              `json:"high_risk_patients"`
+	RecentAssessments   int64              `json:"recent_assessments"`
+	SystemHealth        string             `json:"system_health"`
+	MLServicePulse      string             `json:"ml_service_pulse"`
+	AuditChainValid     bool               `json:"audit_chain_valid"`
+	RiskDistribution    map[string]int64   `json:"risk_distribution"`
+	RiskMethods         RiskMethods        `json:"risk_distribution_methods"` // How the patients in RiskDistribution were leveled
+	Performance         PerformanceMetrics `json:"performance"`
+	DiagnosisBacklog    map[string]int     `json:"diagnosis_backlog,omitempty"` // Queued diagnoses by priority
+	FallbackAssessments int64              `json:"fallback_assessments_24h"`    // Assessments scored by the rule-based fallback in the last 24h
+}
+
+// RiskMethods splits the dashboard risk distribution by how each patient
+// was leveled: by the risk scores of their latest ML assessment, or by
+// systolic BP for patients the ML models never scored
+type RiskMethods struct {
+	MLScore    map[string]int64 `json:"ml_score"`
+	SystolicBP map[string]int64 `json:"systolic_bp"`
+	Thresholds RiskThresholds   `json:"thresholds"` // Applied to ml_score
+}
+
+// TrendPoint summarizes the assessments of one dashboard trend bucket
+type TrendPoint struct {
+	Start           time.Time `json:"start"`
+	Assessments     int64     `json:"assessments"`
+	Emergencies     int64     `json:"emergencies"`
+	AvgHeartRisk    *float64  `json:"avg_heart_risk"` // Null for a bucket without assessments
+	AvgDiabetesRisk *float64  `json:"avg_diabetes_risk"`
+	FallbackRate    *float64  `json:"fallback_rate"` // Fraction scored by the rule-based fallback
+}
+
+// DashboardTrends is the time series behind the dashboard charts
+type DashboardTrends struct {
+	Window      string       `json:"window"`
+	Bucket      string       `json:"bucket"`
+	Since       time.Time    `json:"since"` // Start of the first bucket
+	Until       time.Time    `json:"until"` // End of the last bucket
+	GeneratedAt time.Time    `json:"generated_at"`
+	Points      []TrendPoint `json:"points"`
+}
+
+// MLBackendMetrics compares ML deployments during a canary rollout
+type MLBackendMetrics struct {
+	Name         string  `json:"name"`
+	URL          string  `json:"url"`
+	Requests     int64   `json:"requests"`
+	Failures     int64   `json:"failures"`
+	SuccessRate  float64 `json:"success_rate"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	CircuitState string  `json:"circuit_state"`
+}
+
+// ShadowComparison stores a primary prediction next to the shadow model's answer
+type ShadowComparison struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt       time.Time `gorm:"index" json:"created_at"`
+	PatientID       uint      `gorm:"index" json:"patient_id"`
+	PrimaryBackend  string    `json:"primary_backend"`
+	PrimaryHeart    float64   `json:"primary_heart"`
+	PrimaryDiab     float64   `json:"primary_diabetes"`
+	PrimaryStroke   float64   `json:"primary_stroke"`
+	PrimaryKidney   float64   `json:"primary_kidney"`
+	ShadowHeart     float64   `json:"shadow_heart"`
+	ShadowDiab      float64   `json:"shadow_diabetes"`
+	ShadowStroke    float64   `json:"shadow_stroke"`
+	ShadowKidney    float64   `json:"shadow_kidney"`
+	DeltaHeart      float64   `json:"delta_heart"`
+	DeltaDiab       float64   `json:"delta_diabetes"`
+	DeltaStroke     float64   `json:"delta_stroke"`
+	DeltaKidney     float64   `json:"delta_kidney"`
+	MaxAbsDelta     float64   `gorm:"index" json:"max_abs_delta"`
+	Agree           bool      `json:"agree"`
+	ShadowError     string    `json:"shadow_error,omitempty"`
+	ShadowLatencyMs int64     `json:"shadow_latency_ms"`
+}
+
+// ShadowReport summarizes primary vs shadow agreement
+type ShadowReport struct {
+	Since                time.Time          `json:"since"`
+	Tolerance            float64            `json:"tolerance"`
+	Total                int64              `json:"total"`
+	Errors               int64              `json:"errors"`
+	Agreed               int64              `json:"agreed"`
+	AgreementRate        float64            `json:"agreement_rate"`
+	MeanAbsDeltaHeart    float64            `json:"mean_abs_delta_heart"`
+	MeanAbsDeltaDiabetes float64            `json:"mean_abs_delta_diabetes"`
+	MeanAbsDeltaStroke   float64            `json:"mean_abs_delta_stroke"`
+	MeanAbsDeltaKidney   float64            `json:"mean_abs_delta_kidney"`
+	LargestDisagreements []ShadowComparison `gorm:"-" json:"largest_disagreements"`
+}
+
+// ModelDriftPoint is one week of confidence statistics for a single model
+type ModelDriftPoint struct {
+	ModelName      string    `json:"model_name"`
+	WeekStart      time.Time `json:"week_start"`
+	MeanConfidence float64   `json:"mean_confidence"` // ML predictions only
+	FallbackShare  float64   `json:"fallback_share"`  // Fraction served by the rule-based fallback
+	Samples        int64     `json:"samples"`
+}
+
+// ModelPerformance is how one risk model's assessments fared with doctors.
+// Feedback reviews a whole assessment, so every model shares the same
+// reviews; approvals are the reviews that didn't override this model.
+type ModelPerformance struct {
+	ModelName      string                  `json:"model_name"`
+	Assessments    int64                   `json:"assessments"`    // Scored by the model, ML or fallback
+	AvgConfidence  *float64                `json:"avg_confidence"` // The model's confidence over ML predictions; null without any
+	Reviews        int64                   `json:"reviews"`
+	Approvals      int64                   `json:"approvals"`
+	Overrides      int64                   `json:"overrides"`
+	AcceptanceRate *float64                `json:"acceptance_rate"` // Approvals / reviews; null without reviews
+	Weekly         []ModelPerformancePoint `json:"weekly,omitempty"` // Weeks with feedback, oldest first
+}
+
+// ModelPerformancePoint is one week of a model's acceptance by doctors
+type ModelPerformancePoint struct {
+	WeekStart      time.Time `json:"week_start"`
+	Reviews        int64     `json:"reviews"`
+	Overrides      int64     `json:"overrides"`
+	AcceptanceRate *float64  `json:"acceptance_rate"`
+}
+
+// ModelPerformanceReport covers every risk model since a given time
+type ModelPerformanceReport struct {
+	Since  time.Time          `json:"since"`
+	Models []ModelPerformance `json:"models"`
+}
+
+// ModelDriftCheck is the periodic MODEL_DRIFT_CHECK audit record for AI
+// Act post-market monitoring
+type ModelDriftCheck struct {
+	CheckedAt time.Time          `json:"checked_at"`
+	Since     time.Time          `json:"since"`
+	Models    []ModelPerformance `json:"models"`
+	Alerts    []ModelDriftAlert  `json:"alerts"`
+}
+
+// ModelDriftAlert is raised when a model's recent confidence falls below its trailing month
+type ModelDriftAlert struct {
+	ModelName    string    `json:"model_name"`
+	RecentMean   float64   `json:"recent_mean"`
+	BaselineMean float64   `json:"baseline_mean"`
+	Drop         float64   `json:"drop"`
+	Threshold    float64   `json:"threshold"`
+	DetectedAt   time.Time `json:"detected_at"`
 }
 
 type PerformanceMetrics struct {
-	AvgMLInferenceTimeMs int64   `json:"avg_ml_inference_time_ms"`
-	UptimeSeconds        float64 `json:"uptime_seconds"`
-	RequestCount         int64   `json:"request_count"`
-	ErrorRate            float64 `json:"error_rate"` // % of last 100 requests
+	AvgMLInferenceTimeMs int64                `json:"avg_ml_inference_time_ms"`
+	UptimeSeconds        float64              `json:"uptime_seconds"`
+	RequestCount         int64                `json:"request_count"`
+	ErrorRate            float64              `json:"error_rate"` // % of last 100 requests
+	PredictionCache      PredictionCacheStats `json:"prediction_cache"`
+}
+
+// PredictionCacheStats counts risk prediction cache lookups
+type PredictionCacheStats struct {
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hit_rate"` // % of lookups served from the cache
 }
 
 // -- Vitals / MediaPipe Structs --
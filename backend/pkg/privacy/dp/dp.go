@@ -0,0 +1,76 @@
+// Package dp adds Laplace noise to aggregate statistics for epsilon-differential
+// privacy. Counts have sensitivity 1; means are computed from a noisy clamped
+// sum over a noisy count, so a single record moves either by a bounded amount.
+package dp
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sync"
+)
+
+var ErrInvalidEpsilon = errors.New("epsilon must be positive")
+
+// Mechanism draws Laplace noise. It is safe for concurrent use.
+type Mechanism struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewMechanism draws from src; tests pass a fixed seed for reproducible noise
+func NewMechanism(src rand.Source) *Mechanism {
+	return &Mechanism{rng: rand.New(src)}
+}
+
+// Laplace samples from Laplace(0, scale) by inverse CDF
+func (m *Mechanism) Laplace(scale float64) float64 {
+	m.mu.Lock()
+	u := m.rng.Float64() - 0.5
+	m.mu.Unlock()
+	if u == -0.5 {
+		u = -0.5 + math.SmallestNonzeroFloat64
+	}
+	return -scale * sign(u) * math.Log(1-2*math.Abs(u))
+}
+
+func sign(x float64) float64 {
+	if x < 0 {
+		return -1
+	}
+	return 1
+}
+
+// Count releases a count under epsilon. The result is rounded and never negative.
+func (m *Mechanism) Count(n int, epsilon float64) (float64, error) {
+	if epsilon <= 0 {
+		return 0, ErrInvalidEpsilon
+	}
+	return math.Max(0, math.Round(float64(n)+m.Laplace(1/epsilon))), nil
+}
+
+// Mean releases the mean of values clamped to [lo, hi] under epsilon, spent
+// half on the sum and half on the count. The result is clamped to [lo, hi].
+func (m *Mechanism) Mean(values []float64, lo, hi, epsilon float64) (float64, error) {
+	if epsilon <= 0 {
+		return 0, ErrInvalidEpsilon
+	}
+	half := epsilon / 2
+
+	sum := 0.0
+	for _, v := range values {
+		sum += Clamp(v, lo, hi)
+	}
+	// Shifting by lo keeps the sum's sensitivity at hi-lo whatever the sign of the bounds
+	noisySum := sum - lo*float64(len(values)) + m.Laplace((hi-lo)/half)
+	noisyCount := float64(len(values)) + m.Laplace(1/half)
+	if noisyCount < 1 {
+		noisyCount = 1
+	}
+	return Clamp(lo+noisySum/noisyCount, lo, hi), nil
+}
+
+// Clamp bounds v to [lo, hi]
+func Clamp(v, lo, hi float64) float64 {
+	return math.Min(hi, math.Max(lo, v))
+}
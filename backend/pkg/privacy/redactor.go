@@ -0,0 +1,175 @@
+package privacy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Mode controls what happens when PHI is detected in outbound text
+type Mode string
+
+const (
+	ModeRedact Mode = "redact" // Replace PHI with placeholders and send the rest
+	ModeBlock  Mode = "block"  // Refuse to send any text that contained PHI
+)
+
+// BlockedNotice replaces the whole text in block mode
+const BlockedNotice = "[CONTEXT WITHHELD: contained protected health information]"
+
+// ParseMode validates a mode string
+func ParseMode(s string) (Mode, error) {
+	switch Mode(strings.ToLower(strings.TrimSpace(s))) {
+	case ModeRedact:
+		return ModeRedact, nil
+	case ModeBlock:
+		return ModeBlock, nil
+	}
+	return "", fmt.Errorf("unknown redaction mode %q (expected redact or block)", s)
+}
+
+type rule struct {
+	re          *regexp.Regexp
+	placeholder string
+}
+
+// Result describes a single redaction pass
+type Result struct {
+	Text       string `json:"text"`
+	Redactions int    `json:"redactions"`
+	Blocked    bool   `json:"blocked"`
+	Mode       Mode   `json:"mode"`
+}
+
+// Redactor strips names, phone numbers and identifiers from free text before
+// it is sent to external services (e.g. the LLM prompt's PastContext).
+type Redactor struct {
+	mu    sync.RWMutex
+	mode  Mode
+	rules []rule
+}
+
+// Built-in detectors. Order matters: longer identifiers run before phone numbers
+// so an 11-digit TC Kimlik No is not half-matched as a phone.
+var builtinRules = []struct {
+	pattern     string
+	placeholder string
+}{
+	{`[\w.+-]+@[\w-]+\.[\w.-]+`, "[REDACTED-EMAIL]"},
+	{`(?i)\b(?:MRN|TCKN|TC|SSN|ID)\s*(?:No\.?|#)?\s*[:=]?\s*\d{4,}`, "[REDACTED-ID]"},
+	{`\b[1-9]\d{10}\b`, "[REDACTED-ID]"},       // TC Kimlik No
+	{`\b\d{3}-\d{2}-\d{4}\b`, "[REDACTED-ID]"}, // US SSN
+	{`(?:\+|00)\d{1,3}[\s.-]?\(?\d{2,4}\)?(?:[\s.-]?\d{2,4}){2,3}`, "[REDACTED-PHONE]"},
+	{`\(?\b0?\d{3}\)?[\s.-]\d{3}[\s.-]?\d{2}[\s.-]?\d{2}\b`, "[REDACTED-PHONE]"},
+	// Turkish honorific forms: "Ayşe Hanım", "Mehmet Bey"
+	{`\p{Lu}[\p{Ll}]+(?:\s+\p{Lu}[\p{Ll}]+)?\s+(?:Bey|Hanım|Hanim|Beyefendi|Hanımefendi)\b`, "[REDACTED-NAME]"},
+}
+
+// NewRedactor builds a redactor with the built-in detectors plus custom regex patterns
+func NewRedactor(mode Mode, customPatterns []string) (*Redactor, error) {
+	r := &Redactor{mode: mode}
+	for _, b := range builtinRules {
+		r.rules = append(r.rules, rule{re: regexp.MustCompile(b.pattern), placeholder: b.placeholder})
+	}
+	for _, p := range customPatterns {
+		if strings.TrimSpace(p) == "" {
+			continue
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid custom PHI pattern %q: %w", p, err)
+		}
+		r.rules = append(r.rules, rule{re: re, placeholder: "[REDACTED]"})
+	}
+	return r, nil
+}
+
+// Mode returns the active mode
+func (r *Redactor) Mode() Mode {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.mode
+}
+
+// SetMode switches between redact and block at runtime
+func (r *Redactor) SetMode(m Mode) {
+	r.mu.Lock()
+	r.mode = m
+	r.mu.Unlock()
+}
+
+// Redact removes known patient names and pattern-matched PHI from text.
+// In block mode any detection replaces the whole text with BlockedNotice.
+func (r *Redactor) Redact(text string, knownNames []string) Result {
+	count := 0
+
+	for _, name := range knownNames {
+		re := nameRegexp(name)
+		if re == nil {
+			continue
+		}
+		// Adjacent occurrences share a boundary character, so repeat until stable
+		for pass := 0; pass < 3; pass++ {
+			replaced := re.ReplaceAllStringFunc(text, func(m string) string {
+				count++
+				// Keep the boundary characters the pattern consumed
+				sub := re.FindStringSubmatch(m)
+				return sub[1] + "[REDACTED-NAME]" + sub[2]
+			})
+			if replaced == text {
+				break
+			}
+			text = replaced
+		}
+	}
+
+	r.mu.RLock()
+	rules, mode := r.rules, r.mode
+	r.mu.RUnlock()
+
+	for _, rl := range rules {
+		text = rl.re.ReplaceAllStringFunc(text, func(string) string {
+			count++
+			return rl.placeholder
+		})
+	}
+
+	if count > 0 && mode == ModeBlock {
+		return Result{Text: BlockedNotice, Redactions: count, Blocked: true, Mode: mode}
+	}
+	return Result{Text: text, Redactions: count, Mode: mode}
+}
+
+// nameRegexp builds a case-insensitive, Unicode-boundary matcher for a name.
+// Turkish dotted/dotless i variants are folded together since Go's (?i)
+// does not treat İ/ı as case pairs of i/I.
+func nameRegexp(name string) *regexp.Regexp {
+	name = strings.TrimSpace(name)
+	if len([]rune(name)) < 2 {
+		return nil
+	}
+
+	var b strings.Builder
+	b.WriteString(`(?i)(^|[^\p{L}\p{N}])`)
+	for i, part := range strings.Fields(name) {
+		if i > 0 {
+			b.WriteString(`\s+`)
+		}
+		for _, ch := range part {
+			switch ch {
+			case 'i', 'I', 'İ', 'ı':
+				b.WriteString(`[iIİı]`)
+			default:
+				b.WriteString(regexp.QuoteMeta(string(ch)))
+			}
+		}
+	}
+	b.WriteString(`([^\p{L}\p{N}]|$)`)
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil
+	}
+	return re
+}
@@ -1,15 +1,24 @@
 package queue
 
 import (
-	"log"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
 	"time"
 
+	"healthcare-backend/pkg/chaos"
+
 	"github.com/nats-io/nats.go"
 )
 
 var (
 	NC *nats.Conn
 	JS nats.JetStreamContext
+
+	// Subjects captured by a stream that EnsureStream has confirmed
+	durableMu       sync.RWMutex
+	durableSubjects = map[string]bool{}
 )
 
 // InitNATS initializes the NATS connection
@@ -25,26 +34,29 @@ func InitNATS(url string) {
 		if err == nil {
 			break
 		}
-		log.Printf("⚠️ NATS connection failed (attempt %d): %v", i+1, err)
+		slog.Warn("nats connection failed", "attempt", i+1, "error", err)
 		time.Sleep(time.Duration(i+1) * time.Second)
 	}
 
 	if err != nil {
-		log.Printf("❌ Fatal: Could not connect to NATS: %v", err)
+		slog.Error("could not connect to nats", "error", err)
 		return
 	}
 
 	// Initialize JetStream (for persistent streams if needed)
 	JS, err = NC.JetStream()
 	if err != nil {
-		log.Printf("⚠️ JetStream initialization failed: %v", err)
+		slog.Warn("jetstream initialization failed", "error", err)
 	}
 
-	log.Println("⚡ NATS connected successfully")
+	slog.Info("nats connected")
 }
 
 // Publish sends a message to a subject
 func Publish(subject string, data []byte) error {
+	if err := chaos.Inject(chaos.TargetNATS, ""); err != nil {
+		return err
+	}
 	return NC.Publish(subject, data)
 }
 
@@ -53,6 +65,77 @@ func Subscribe(subject string, cb nats.MsgHandler) (*nats.Subscription, error) {
 	return NC.Subscribe(subject, cb)
 }
 
+// EnsureStream creates a work-queue stream persisting subjects, or checks
+// that it already exists. Until it succeeds, PublishDurable and
+// SubscribeDurable fall back to plain NATS for those subjects.
+func EnsureStream(name string, subjects ...string) error {
+	if JS == nil || !IsConnected() {
+		return errors.New("jetstream unavailable")
+	}
+	info, err := JS.StreamInfo(name)
+	switch {
+	case errors.Is(err, nats.ErrStreamNotFound):
+		_, err = JS.AddStream(&nats.StreamConfig{
+			Name:      name,
+			Subjects:  subjects,
+			Storage:   nats.FileStorage,
+			Retention: nats.WorkQueuePolicy, // Acked messages are removed
+		})
+	case err == nil && !slices.Equal(info.Config.Subjects, subjects):
+		// A newer release captures more subjects
+		cfg := info.Config
+		cfg.Subjects = subjects
+		_, err = JS.UpdateStream(&cfg)
+	}
+	if err != nil {
+		return err
+	}
+
+	durableMu.Lock()
+	for _, s := range subjects {
+		durableSubjects[s] = true
+	}
+	durableMu.Unlock()
+	return nil
+}
+
+// IsDurable reports whether subject is persisted by JetStream
+func IsDurable(subject string) bool {
+	durableMu.RLock()
+	defer durableMu.RUnlock()
+	return durableSubjects[subject]
+}
+
+// PublishDurable sends a message that survives restarts, returning once
+// JetStream has stored it. Subjects without a stream are plainly published.
+func PublishDurable(subject string, data []byte) error {
+	return PublishDurableMsg(&nats.Msg{Subject: subject, Data: data})
+}
+
+// PublishDurableMsg is PublishDurable for a message with headers
+func PublishDurableMsg(m *nats.Msg) error {
+	if err := chaos.Inject(chaos.TargetNATS, ""); err != nil {
+		return err
+	}
+	if !IsDurable(m.Subject) {
+		return NC.PublishMsg(m)
+	}
+	_, err := JS.PublishMsg(m)
+	return err
+}
+
+// SubscribeDurable shares subject's messages across the group's members.
+// With JetStream the consumer is durable and acks manually: the handler must
+// Ack, Nak or Term each message, and m.Metadata() reports the delivery
+// count. Otherwise it is a plain queue subscription and opts are ignored.
+func SubscribeDurable(subject, group string, cb nats.MsgHandler, opts ...nats.SubOpt) (*nats.Subscription, error) {
+	if !IsDurable(subject) {
+		return NC.QueueSubscribe(subject, group, cb)
+	}
+	opts = append([]nats.SubOpt{nats.Durable(group), nats.ManualAck()}, opts...)
+	return JS.QueueSubscribe(subject, group, cb, opts...)
+}
+
 // Close closes the NATS connection
 func Close() {
 	if NC != nil {
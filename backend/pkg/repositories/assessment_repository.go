@@ -0,0 +1,87 @@
+package repositories
+
+import (
+	"healthcare-backend/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// AssessmentRepository abstracts database operations for persisted assessments
+type AssessmentRepository interface {
+	Create(assessment *models.Assessment) error
+	GetByID(id uint) (*models.Assessment, error)
+	GetLatestForPatient(patientID uint) (*models.Assessment, error)
+	ListForPatient(patientID uint) ([]models.Assessment, error)
+	Save(assessment *models.Assessment) error
+	SaveComponent(component *models.AssessmentComponent) error
+	GetComponents(assessmentID uint) ([]models.AssessmentComponent, error)
+}
+
+type assessmentRepository struct {
+	db *gorm.DB
+}
+
+// NewAssessmentRepository creates a new instance of AssessmentRepository
+func NewAssessmentRepository(db *gorm.DB) AssessmentRepository {
+	return &assessmentRepository{db: db}
+}
+
+// Create stores the assessment together with its per-model precisions
+func (r *assessmentRepository) Create(assessment *models.Assessment) error {
+	err := withBusyRetry(func() error {
+		return r.db.Create(assessment).Error
+	})
+	if err == nil && assessment.ID == 0 {
+		return ErrNoPrimaryKey
+	}
+	return err
+}
+
+func (r *assessmentRepository) GetByID(id uint) (*models.Assessment, error) {
+	var assessment models.Assessment
+	if err := r.db.Preload("Precisions").First(&assessment, id).Error; err != nil {
+		return nil, err
+	}
+	return &assessment, nil
+}
+
+func (r *assessmentRepository) GetLatestForPatient(patientID uint) (*models.Assessment, error) {
+	var assessment models.Assessment
+	err := r.db.Preload("Precisions").
+		Where("patient_id = ?", patientID).
+		Order("created_at desc, id desc").
+		First(&assessment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &assessment, nil
+}
+
+// ListForPatient returns every assessment of the patient, oldest first
+func (r *assessmentRepository) ListForPatient(patientID uint) ([]models.Assessment, error) {
+	var assessments []models.Assessment
+	err := r.db.Preload("Precisions").
+		Where("patient_id = ?", patientID).
+		Order("created_at, id").
+		Find(&assessments).Error
+	return assessments, err
+}
+
+// Save updates an assessment, e.g. when a late component completes
+func (r *assessmentRepository) Save(assessment *models.Assessment) error {
+	return withBusyRetry(func() error {
+		return r.db.Save(assessment).Error
+	})
+}
+
+func (r *assessmentRepository) SaveComponent(component *models.AssessmentComponent) error {
+	return withBusyRetry(func() error {
+		return r.db.Save(component).Error
+	})
+}
+
+func (r *assessmentRepository) GetComponents(assessmentID uint) ([]models.AssessmentComponent, error) {
+	var components []models.AssessmentComponent
+	err := r.db.Where("assessment_id = ?", assessmentID).Order("id").Find(&components).Error
+	return components, err
+}
@@ -1,6 +1,8 @@
 package repositories
 
 import (
+	"strconv"
+
 	"healthcare-backend/pkg/models"
 
 	"gorm.io/gorm"
@@ -10,6 +12,25 @@ import (
 type FeedbackRepository interface {
 	Create(feedback *models.Feedback) error
 	GetApproved() ([]models.Feedback, error)
+	GetApprovedWithPatients(limit int) ([]ApprovedCase, error)
+	ListForPatient(patientID uint) ([]models.Feedback, error)
+	List(filter FeedbackFilter) (*models.FeedbackPage, error)
+	GetByID(id uint) (*models.Feedback, error)
+	Amend(id uint, notes, reason, authorID string) (*models.FeedbackRevision, error)
+	Revisions(feedbackID uint) ([]models.FeedbackRevision, error)
+}
+
+// FeedbackFilter narrows GET /api/feedback
+type FeedbackFilter struct {
+	PatientID    uint  // 0 matches any
+	AssessmentID uint  // 0 matches any
+	Approved     *bool // nil matches both
+	Page         int   // 1-based
+	Limit        int   // 0 returns every match on one page
+
+	// Only feedback on patients assigned to this provider; 0 matches none.
+	// Used to scope a doctor's listing.
+	AssignedTo *uint
 }
 
 type feedbackRepository struct {
@@ -22,7 +43,13 @@ func NewFeedbackRepository(db *gorm.DB) FeedbackRepository {
 }
 
 func (r *feedbackRepository) Create(feedback *models.Feedback) error {
-	return r.db.Create(feedback).Error
+	err := withBusyRetry(func() error {
+		return r.db.Create(feedback).Error
+	})
+	if err == nil && feedback.ID == 0 {
+		return ErrNoPrimaryKey
+	}
+	return err
 }
 
 func (r *feedbackRepository) GetApproved() ([]models.Feedback, error) {
@@ -30,3 +57,119 @@ func (r *feedbackRepository) GetApproved() ([]models.Feedback, error) {
 	err := r.db.Where("doctor_approved = ?", true).Find(&feedbacks).Error
 	return feedbacks, err
 }
+
+// ApprovedCase is approved feedback with the vitals and history of the
+// patient it was given on; the patient's other fields are left empty
+type ApprovedCase struct {
+	models.Feedback
+	Patient models.PatientData `gorm:"foreignKey:PatientID"`
+}
+
+func (ApprovedCase) TableName() string { return "feedbacks" }
+
+// GetApprovedWithPatients returns the most recent approved feedback, at
+// most limit entries (0 is all), newest first, in one query with its
+// patient. Feedback whose patient is gone is left out.
+func (r *feedbackRepository) GetApprovedWithPatients(limit int) ([]ApprovedCase, error) {
+	var cases []ApprovedCase
+	q := r.db.InnerJoins("Patient", r.db.Select("id", "age", "gender", "systolic_bp", "glucose", "bmi", "smoking",
+		"history_heart_disease", "history_stroke", "history_diabetes", "history_high_chol")).
+		Where("feedbacks.doctor_approved = ?", true).
+		Order("feedbacks.created_at DESC, feedbacks.id DESC")
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	err := q.Find(&cases).Error
+	return cases, err
+}
+
+// ListForPatient returns all feedback on the patient, oldest first
+func (r *feedbackRepository) ListForPatient(patientID uint) ([]models.Feedback, error) {
+	var feedbacks []models.Feedback
+	err := r.db.Where("patient_id = ?", patientID).Order("created_at, id").Find(&feedbacks).Error
+	return feedbacks, err
+}
+
+// List returns one page of the matching feedback, newest first
+func (r *feedbackRepository) List(f FeedbackFilter) (*models.FeedbackPage, error) {
+	q := r.db.Model(&models.Feedback{})
+	if f.PatientID != 0 {
+		q = q.Where("patient_id = ?", f.PatientID)
+	}
+	if f.AssessmentID != 0 {
+		q = q.Where("assessment_id = ?", strconv.FormatUint(uint64(f.AssessmentID), 10))
+	}
+	if f.Approved != nil {
+		q = q.Where("doctor_approved = ?", *f.Approved)
+	}
+	if f.AssignedTo != nil {
+		q = q.Where("patient_id IN (?)", r.db.Model(&models.PatientData{}).Select("id").Where("assigned_provider_id = ?", *f.AssignedTo))
+	}
+
+	page := &models.FeedbackPage{Page: f.Page, Limit: f.Limit, Items: []models.Feedback{}}
+	if err := q.Count(&page.Total).Error; err != nil {
+		return nil, err
+	}
+	q = q.Order("created_at desc, id desc")
+	if f.Limit > 0 {
+		q = q.Offset((f.Page - 1) * f.Limit).Limit(f.Limit)
+	}
+	if err := q.Find(&page.Items).Error; err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func (r *feedbackRepository) GetByID(id uint) (*models.Feedback, error) {
+	var feedback models.Feedback
+	if err := r.db.First(&feedback, id).Error; err != nil {
+		return nil, err
+	}
+	return &feedback, nil
+}
+
+// Amend replaces the feedback's doctor notes and records them as its next
+// revision, copying the original in as revision 1 the first time. The notes
+// are updated through the feedback row, so search reindexes them.
+func (r *feedbackRepository) Amend(id uint, notes, reason, authorID string) (*models.FeedbackRevision, error) {
+	var rev models.FeedbackRevision
+	err := withBusyRetry(func() error {
+		return r.db.Transaction(func(tx *gorm.DB) error {
+			var feedback models.Feedback
+			if err := tx.First(&feedback, id).Error; err != nil {
+				return err
+			}
+			var last int
+			err := tx.Model(&models.FeedbackRevision{}).Where("feedback_id = ?", id).
+				Select("COALESCE(MAX(revision), 0)").Scan(&last).Error
+			if err != nil {
+				return err
+			}
+			if last == 0 {
+				original := models.FeedbackRevision{CreatedAt: feedback.CreatedAt, FeedbackID: id, Revision: 1,
+					DoctorNotes: feedback.DoctorNotes, AuthorID: feedback.AuthorID}
+				if err := tx.Create(&original).Error; err != nil {
+					return err
+				}
+				last = 1
+			}
+			rev = models.FeedbackRevision{FeedbackID: id, Revision: last + 1, DoctorNotes: notes, AuthorID: authorID, Reason: reason}
+			if err := tx.Create(&rev).Error; err != nil {
+				return err
+			}
+			return tx.Model(&feedback).Update("doctor_notes", notes).Error
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// Revisions returns every version of the feedback's notes, oldest first;
+// none when they were never amended
+func (r *feedbackRepository) Revisions(feedbackID uint) ([]models.FeedbackRevision, error) {
+	revisions := []models.FeedbackRevision{}
+	err := r.db.Where("feedback_id = ?", feedbackID).Order("revision").Find(&revisions).Error
+	return revisions, err
+}
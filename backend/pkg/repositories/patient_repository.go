@@ -6,11 +6,33 @@ import (
 	"gorm.io/gorm"
 )
 
+// HighRiskCondition is the dashboard's "high risk" heuristic, shared by the
+// summary count and the patient list filter so the two agree
+const HighRiskCondition = "systolic_bp > 160"
+
+// PatientFilter narrows and pages a patient listing. Zero values don't filter.
+type PatientFilter struct {
+	Gender   string
+	MinAge   int
+	MaxAge   int
+	HighRisk bool
+	Page     int // 1-based
+	Limit    int
+
+	// Only patients assigned to this provider; 0 matches none. Used to
+	// scope a doctor's listing.
+	AssignedTo *uint
+}
+
 // PatientRepository abstracts database operations for patients
 type PatientRepository interface {
 	Create(patient *models.PatientData) error
 	GetByID(id uint) (*models.PatientData, error)
 	GetAll() ([]models.PatientData, error)
+	List(filter PatientFilter) (*models.PatientPage, error)
+	Update(patient *models.PatientData) error
+	Delete(id uint) error
+	KnownNames() ([]string, error)
 }
 
 type patientRepository struct {
@@ -23,7 +45,13 @@ func NewPatientRepository(db *gorm.DB) PatientRepository {
 }
 
 func (r *patientRepository) Create(patient *models.PatientData) error {
-	return r.db.Create(patient).Error
+	err := withBusyRetry(func() error {
+		return r.db.Create(patient).Error
+	})
+	if err == nil && patient.ID == 0 {
+		return ErrNoPrimaryKey
+	}
+	return err
 }
 
 func (r *patientRepository) GetByID(id uint) (*models.PatientData, error) {
@@ -41,3 +69,68 @@ func (r *patientRepository) GetAll() ([]models.PatientData, error) {
 	}
 	return patients, nil
 }
+
+// List returns one page of patients, newest first, with their providers
+func (r *patientRepository) List(f PatientFilter) (*models.PatientPage, error) {
+	q := r.db.Model(&models.PatientData{})
+	if f.Gender != "" {
+		q = q.Where("gender = ?", f.Gender)
+	}
+	if f.MinAge > 0 {
+		q = q.Where("age >= ?", f.MinAge)
+	}
+	if f.MaxAge > 0 {
+		q = q.Where("age <= ?", f.MaxAge)
+	}
+	if f.HighRisk {
+		q = q.Where(HighRiskCondition)
+	}
+	if f.AssignedTo != nil {
+		q = q.Where("assigned_provider_id = ?", *f.AssignedTo)
+	}
+
+	page := &models.PatientPage{Page: f.Page, Limit: f.Limit, Items: []models.PatientData{}}
+	if err := q.Count(&page.Total).Error; err != nil {
+		return nil, err
+	}
+	err := q.Preload("AssignedProvider").
+		Order("created_at desc, id desc").
+		Offset((f.Page - 1) * f.Limit).
+		Limit(f.Limit).
+		Find(&page.Items).Error
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func (r *patientRepository) Update(patient *models.PatientData) error {
+	return withBusyRetry(func() error {
+		return r.db.Save(patient).Error
+	})
+}
+
+func (r *patientRepository) Delete(id uint) error {
+	return withBusyRetry(func() error {
+		return r.db.Delete(&models.PatientData{}, id).Error
+	})
+}
+
+// KnownNames returns every distinct non-empty patient name, used for PHI
+// redaction. Names may be encrypted, so they're decrypted and deduplicated
+// here rather than in SQL.
+func (r *patientRepository) KnownNames() ([]string, error) {
+	var patients []models.PatientData
+	if err := r.db.Select("id", "name").Where("name <> ''").Find(&patients).Error; err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	names := []string{}
+	for _, p := range patients {
+		if !seen[p.Name] {
+			seen[p.Name] = true
+			names = append(names, p.Name)
+		}
+	}
+	return names, nil
+}
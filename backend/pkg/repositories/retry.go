@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"healthcare-backend/pkg/apierrors"
+)
+
+// Retry policy for SQLite "database is locked" contention
+var (
+	BusyRetryDeadline  = 2 * time.Second
+	BusyRetryBaseDelay = 10 * time.Millisecond
+	BusyRetryMaxDelay  = 200 * time.Millisecond
+	BusyRetryAfterHint = 1 * time.Second
+)
+
+// ErrNoPrimaryKey stops an insert from being reported as a success without an ID,
+// which would leave the frontend polling record 0
+var ErrNoPrimaryKey = errors.New("insert returned no primary key")
+
+// isBusyError detects SQLite BUSY/LOCKED conditions across driver error formats
+func isBusyError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "database is locked") ||
+		strings.Contains(msg, "database table is locked") ||
+		strings.Contains(msg, "sqlite_busy") ||
+		strings.Contains(msg, "sqlite_locked")
+}
+
+// withBusyRetry runs op, retrying with exponential backoff while the database
+// reports it is locked. Once the deadline passes a typed StorageBusy error is
+// returned; other failures come back typed by apierrors.FromDB.
+func withBusyRetry(op func() error) error {
+	deadline := time.Now().Add(BusyRetryDeadline)
+	delay := BusyRetryBaseDelay
+
+	for {
+		err := op()
+		if !isBusyError(err) {
+			return apierrors.FromDB(err)
+		}
+		if time.Now().Add(delay).After(deadline) {
+			return &apierrors.StorageBusy{RetryAfter: BusyRetryAfterHint, Err: err}
+		}
+		time.Sleep(delay)
+		delay *= 2
+		if delay > BusyRetryMaxDelay {
+			delay = BusyRetryMaxDelay
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package resilience
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
 	"time"
 
 	"github.com/sony/gobreaker"
@@ -18,8 +20,12 @@ func NewCircuitBreaker(name string) *gobreaker.CircuitBreaker {
 			failureRatio := float64(counts.TotalFailures) / float64(counts.Requests)
 			return counts.Requests >= 5 && failureRatio >= 0.6
 		},
+		// A caller giving up (e.g. a request's latency budget) says nothing about the service's health
+		IsSuccessful: func(err error) bool {
+			return err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+		},
 		OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
-			log.Printf("🔌 Circuit Breaker [%s]: %s -> %s", name, from, to)
+			slog.Warn("circuit breaker state changed", "breaker", name, "from", from.String(), "to", to.String())
 		},
 	}
 
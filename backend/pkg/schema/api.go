@@ -0,0 +1,36 @@
+package schema
+
+import "healthcare-backend/pkg/models"
+
+// contract lists the request and response bodies of the public API.
+// Types they reference are picked up automatically.
+var contract = []any{
+	// Requests
+	models.PatientData{},
+	models.PatientIntake{},
+	models.FeedbackRequest{},
+	models.DiseaseRequest{},
+	models.EKGRequest{},
+	models.KioskIntakeRequest{},
+
+	// Responses
+	models.FullAssessmentResponse{},
+	models.DiagnosisResponse{},
+	models.DiseaseResponse{},
+	models.EKGResponse{},
+	models.EKGAnalysis{},
+	models.VitalsResponse{},
+	models.DashboardSummary{},
+	models.OverrideReason{},
+	models.QueueEvent{},
+	models.APIResponse{},
+}
+
+// API generates the schema document for the public API
+func API() Document {
+	g := NewGenerator()
+	for _, v := range contract {
+		g.Add(v)
+	}
+	return g.Document("Healthcare Clinical Decision Support API", "1.0")
+}
@@ -0,0 +1,249 @@
+// Package schema generates an OpenAPI-style description of the API contract
+// from the Go structs themselves, so the docs can never drift from the code.
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is the subset of OpenAPI's schema object we can derive from Go types
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     bool               `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Validate             string             `json:"x-validate,omitempty"` // Raw validator tag, for rules we don't translate
+}
+
+// Document is the served schema: just the components section of an OpenAPI spec
+type Document struct {
+	OpenAPI    string `json:"openapi"`
+	Info       Info   `json:"info"`
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	rawJSONType = reflect.TypeOf(json.RawMessage(nil)) // Any JSON value, checked by the handler
+)
+
+// Generator collects named struct schemas; nested structs are registered as they're found
+type Generator struct {
+	schemas map[string]*Schema
+}
+
+func NewGenerator() *Generator {
+	return &Generator{schemas: map[string]*Schema{}}
+}
+
+// Add registers v's struct type (and every struct it references) under its Go type name
+func (g *Generator) Add(v any) {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	g.schemaFor(t)
+}
+
+// Document returns everything registered so far
+func (g *Generator) Document(title, version string) Document {
+	doc := Document{OpenAPI: "3.0.3", Info: Info{Title: title, Version: version}}
+	doc.Components.Schemas = g.schemas
+	return doc
+}
+
+func (g *Generator) schemaFor(t reflect.Type) *Schema {
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	if t == rawJSONType {
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := g.schemaFor(t.Elem())
+		if s.Ref != "" {
+			// $ref siblings are ignored by OpenAPI 3.0, so nullable refs keep the ref alone
+			return s
+		}
+		s.Nullable = true
+		return s
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		if _, seen := g.schemas[t.Name()]; !seen {
+			g.schemas[t.Name()] = &Schema{} // Placeholder so recursive types terminate
+			g.schemas[t.Name()] = g.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + t.Name()}
+	}
+	// interface{} and anything else: any JSON value
+	return &Schema{}
+}
+
+func (g *Generator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	g.addFields(s, t)
+	sort.Strings(s.Required)
+	return s
+}
+
+func (g *Generator) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		// Untagged embedded structs are flattened, as encoding/json does
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.addFields(s, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := g.schemaFor(f.Type)
+		if rules := f.Tag.Get("validate"); rules != "" {
+			if applyValidation(prop, rules) {
+				s.Required = append(s.Required, name)
+			}
+		}
+		s.Properties[name] = prop
+	}
+}
+
+// applyValidation translates validator rules into schema constraints and
+// reports whether the field is required
+func applyValidation(s *Schema, rules string) bool {
+	if s.Ref != "" {
+		// Constraints can't sit next to a $ref; keep the raw tag instead
+		s.Validate = rules
+		return strings.Contains(","+rules+",", ",required,")
+	}
+	s.Validate = rules
+
+	required := false
+	for _, rule := range strings.Split(rules, ",") {
+		key, arg, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+			required = true
+		case "oneof":
+			s.Enum = strings.Fields(arg)
+		case "min", "gte":
+			setBound(s, arg, true, false)
+		case "max", "lte":
+			setBound(s, arg, false, false)
+		case "gt":
+			setBound(s, arg, true, true)
+		case "lt":
+			setBound(s, arg, false, true)
+		case "len":
+			setBound(s, arg, true, false)
+			setBound(s, arg, false, false)
+		case "email":
+			s.Format = "email"
+		case "url":
+			s.Format = "uri"
+		case "uuid":
+			s.Format = "uuid"
+		case "dive":
+			// Rules after dive apply to elements, which we don't describe
+			return required
+		}
+	}
+	return required
+}
+
+// setBound applies min/max to whatever "size" means for the schema's type
+func setBound(s *Schema, arg string, lower, exclusive bool) {
+	switch s.Type {
+	case "integer", "number":
+		v, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return
+		}
+		if lower {
+			s.Minimum, s.ExclusiveMinimum = &v, exclusive
+		} else {
+			s.Maximum, s.ExclusiveMaximum = &v, exclusive
+		}
+	case "string", "array":
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return
+		}
+		if exclusive && lower {
+			n++
+		} else if exclusive {
+			n--
+		}
+		switch {
+		case s.Type == "string" && lower:
+			s.MinLength = &n
+		case s.Type == "string":
+			s.MaxLength = &n
+		case lower:
+			s.MinItems = &n
+		default:
+			s.MaxItems = &n
+		}
+	}
+}
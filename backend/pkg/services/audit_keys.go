@@ -0,0 +1,174 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"healthcare-backend/pkg/models"
+)
+
+// KeyID fingerprints a public key: the first 16 hex digits of its SHA-256
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// LoadSigningKey returns the audit signing key from PEM text if given, else
+// from the PEM file at path, generating and writing one there on first boot.
+// If the new key can't be written it is still used, for this process only.
+func LoadSigningKey(pemText, path string) (ed25519.PrivateKey, error) {
+	if pemText != "" {
+		return parseSigningKey([]byte(pemText))
+	}
+	if path == "" {
+		return nil, errors.New("no signing key or key path configured")
+	}
+
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return parseSigningKey(data)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeSigningKey(path, priv); err != nil {
+		slog.Warn("could not save the new audit signing key, it will change on restart", "error", err)
+	} else {
+		slog.Info("generated audit signing key", "key_id", KeyID(priv.Public().(ed25519.PublicKey)), "path", path)
+	}
+	return priv, nil
+}
+
+func parseSigningKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("signing key is not PEM encoded")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse signing key: %w", err)
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key is %T, not Ed25519", key)
+	}
+	return priv, nil
+}
+
+func writeSigningKey(path string, priv ed25519.PrivateKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	// O_EXCL: never overwrite a key another replica just wrote
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if err := pem.Encode(f, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// UseSigningKey switches the key that signs new entries
+func (a *AuditService) UseSigningKey(priv ed25519.PrivateKey) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.privateKey = priv
+	a.publicKey = priv.Public().(ed25519.PublicKey)
+}
+
+// signedMessage is what each entry's signature covers
+func signedMessage(entry models.AuditLog) []byte {
+	return []byte(fmt.Sprintf("%s|%s", entry.PayloadHash, entry.Timestamp.UTC().Format(time.RFC3339)))
+}
+
+// VerifySignature checks an entry's signature against the public key stored
+// with it, and that the key matches the entry's key ID when it has one
+func (a *AuditService) VerifySignature(entry models.AuditLog) error {
+	pub, err := hex.DecodeString(entry.ActorPublicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return errors.New("malformed public key")
+	}
+	if entry.KeyID != "" && entry.KeyID != KeyID(pub) {
+		return fmt.Errorf("public key does not match key ID %s", entry.KeyID)
+	}
+	sig, err := hex.DecodeString(entry.ActorSignature)
+	if err != nil || !ed25519.Verify(pub, signedMessage(entry), sig) {
+		return errors.New("bad signature")
+	}
+	return nil
+}
+
+// ListKeys returns every key that has signed an entry, oldest first, plus
+// the current key if it hasn't signed anything yet
+func (a *AuditService) ListKeys() ([]models.AuditKey, error) {
+	if err := a.Flush(); err != nil {
+		return nil, err
+	}
+	var groups []struct {
+		ActorPublicKey string
+		FirstID        uint
+		LastID         uint
+		Entries        int64
+	}
+	err := a.DB.Model(&models.AuditLog{}).
+		Select("actor_public_key, MIN(id) AS first_id, MAX(id) AS last_id, COUNT(*) AS entries").
+		Group("actor_public_key").Order("first_id").Scan(&groups).Error
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	pub := a.publicKey
+	a.mu.Unlock()
+	current := hex.EncodeToString(pub)
+
+	keys := make([]models.AuditKey, 0, len(groups)+1)
+	seenCurrent := false
+	for _, g := range groups {
+		var first, last models.AuditLog
+		if err := a.DB.Select("timestamp").First(&first, g.FirstID).Error; err != nil {
+			return nil, err
+		}
+		if err := a.DB.Select("timestamp").First(&last, g.LastID).Error; err != nil {
+			return nil, err
+		}
+		key := models.AuditKey{
+			PublicKey: g.ActorPublicKey,
+			FirstUsed: first.Timestamp,
+			LastUsed:  last.Timestamp,
+			Entries:   g.Entries,
+			Current:   g.ActorPublicKey == current,
+		}
+		if signer, err := hex.DecodeString(g.ActorPublicKey); err == nil {
+			key.KeyID = KeyID(signer)
+		}
+		seenCurrent = seenCurrent || key.Current
+		keys = append(keys, key)
+	}
+	if !seenCurrent {
+		keys = append(keys, models.AuditKey{KeyID: KeyID(pub), PublicKey: current, Current: true})
+	}
+	return keys, nil
+}
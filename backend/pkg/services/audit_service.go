@@ -1,39 +1,89 @@
 package services
 
 import (
+	"bufio"
+	"context"
 	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"healthcare-backend/pkg/auditctx"
 	"healthcare-backend/pkg/blockchain"
+	"healthcare-backend/pkg/logging"
 	"healthcare-backend/pkg/models"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"gorm.io/gorm"
 )
 
+// ErrMissingActor is returned in strict mode for events without an actor
+var ErrMissingActor = errors.New("audit event has no actor")
+
 type AuditService struct {
-	DB         *gorm.DB
-	mu         sync.Mutex
-	lastHash   string
-	privateKey ed25519.PrivateKey
-	publicKey  ed25519.PublicKey
+	DB           *gorm.DB
+	Chain        *blockchain.Blockchain // In-memory ledger mirrored on every event
+	RequireActor bool                   // Strict mode: refuse events with an empty actor ID or role
+	mu           sync.Mutex
+	lastHash     string
+	verifyMu     sync.Mutex      // Serialises verification passes
+	checkpoint   chainCheckpoint // Guarded by verifyMu
+	privateKey   ed25519.PrivateKey
+	publicKey    ed25519.PublicKey
+
+	// Read-access events wait in accessPending and are chained in batches
+	// by FlushAccess, so reads don't each pay for an insert
+	AccessBatchSize int // Pending events that trigger an early flush
+	accessMu        sync.Mutex
+	accessPending   []pendingEvent
+	accessFull      chan struct{}
+
+	// Set by StartWriter: sealed entries are saved by one background writer
+	queue                    chan auditWrite
+	queued, written, dropped atomic.Int64
+	stop                     chan struct{} // Closed by Close: the writer stops retrying
+	stopOnce                 sync.Once
 }
 
+// pendingEvent is an audit entry not yet chained or signed
+type pendingEvent struct {
+	entry     models.AuditLog
+	patientID uint
+}
+
+// DefaultAccessBatchSize is AccessBatchSize when not configured
+const DefaultAccessBatchSize = 100
+
+// Read-access event types, recorded with LogAccess
+const (
+	EventPatientViewed   = "PATIENT_VIEWED"
+	EventDiagnosisViewed = "DIAGNOSIS_VIEWED"
+	EventDashboardViewed = "DASHBOARD_VIEWED"
+)
+
+// AccessEvents are the event types AccessHistory returns
+var AccessEvents = []string{EventPatientViewed, EventDiagnosisViewed, EventDashboardViewed}
+
 func NewAuditService(db *gorm.DB) *AuditService {
 	// Initialize the high-performance blockchain ledger
 	if blockchain.GlobalChain == nil {
 		blockchain.InitBlockchain()
 	}
+	return NewAuditServiceOnChain(db, blockchain.GlobalChain)
+}
 
-	// Auto-migrate the AuditLog table
-	db.AutoMigrate(&models.AuditLog{})
-
+// NewAuditServiceOnChain writes to the given ledger instead of the global one,
+// e.g. a throwaway chain for the self-test
+func NewAuditServiceOnChain(db *gorm.DB, chain *blockchain.Blockchain) *AuditService {
 	// Get the last hash from the chain (if any)
 	var lastEntry models.AuditLog
 	lastHash := "GENESIS" // Genesis block has no previous hash
@@ -41,14 +91,18 @@ func NewAuditService(db *gorm.DB) *AuditService {
 		lastHash = lastEntry.CurrentHash
 	}
 
-	// 🔑 Generate ephemeral Ed25519 keys for this session (In prod: Load from HSM/Vault)
+	// 🔑 Ephemeral Ed25519 keys until UseSigningKey installs the persistent one
 	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
 
 	return &AuditService{
-		DB:         db,
-		lastHash:   lastHash,
-		privateKey: priv,
-		publicKey:  pub,
+		DB:              db,
+		Chain:           chain,
+		lastHash:        lastHash,
+		privateKey:      priv,
+		publicKey:       pub,
+		AccessBatchSize: DefaultAccessBatchSize,
+		accessFull:      make(chan struct{}, 1),
+		stop:            make(chan struct{}),
 	}
 }
 
@@ -58,29 +112,10 @@ func hashString(s string) string {
 	return hex.EncodeToString(h[:])
 }
 
-// LogEvent creates a new audit log entry chained to the previous one
-func (a *AuditService) LogEvent(eventType string, patientID uint, payload interface{}, actorID string) (models.AuditLog, error) {
-	a.mu.Lock()
-	defer a.mu.Unlock()
-
-	// Hash the patient ID for privacy
-	patientIDHash := hashString(fmt.Sprintf("%d", patientID))
-
-	// Hash the payload
-	payloadBytes, _ := json.Marshal(payload)
-	payloadHash := hashString(string(payloadBytes))
-
-	// Create the entry
-	entry := models.AuditLog{
-		Timestamp:     time.Now().UTC(),
-		EventType:     eventType,
-		PatientIDHash: patientIDHash,
-		PayloadHash:   payloadHash,
-		PrevHash:      a.lastHash,
-		ActorID:       actorID,
-	}
-
-	// Calculate the current hash (hash of entire entry except CurrentHash)
+// entryHash covers every field except CurrentHash, the signature and key
+// fields. The role and request ID are appended only when set, so entries
+// written before they existed still verify.
+func entryHash(entry models.AuditLog) string {
 	entryData := fmt.Sprintf("%s|%s|%s|%s|%s|%s",
 		entry.Timestamp.Format(time.RFC3339Nano),
 		entry.EventType,
@@ -89,92 +124,725 @@ func (a *AuditService) LogEvent(eventType string, patientID uint, payload interf
 		entry.PrevHash,
 		entry.ActorID,
 	)
-	entry.CurrentHash = hashString(entryData)
+	if entry.ActorRole != "" {
+		entryData += "|" + entry.ActorRole
+	}
+	if entry.RequestID != "" {
+		entryData += "|request:" + entry.RequestID
+	}
+	return hashString(entryData)
+}
+
+// newEvent hashes an event's patient ID and payload into an unchained entry
+func (a *AuditService) newEvent(eventType string, patientID uint, payload interface{}, actor auditctx.Identity) (pendingEvent, error) {
+	if a.RequireActor && (actor.ID == "" || actor.Role == "") {
+		logging.ForRequest(actor.RequestID).Error("audit event refused", "event", eventType, "error", ErrMissingActor)
+		return pendingEvent{}, fmt.Errorf("%s: %w", eventType, ErrMissingActor)
+	}
+
+	// Hash the payload
+	payloadBytes, _ := json.Marshal(payload)
+
+	return pendingEvent{
+		entry: models.AuditLog{
+			Timestamp:     time.Now().UTC(),
+			EventType:     eventType,
+			PatientIDHash: PatientHash(patientID), // Hash the patient ID for privacy
+			PayloadHash:   hashString(string(payloadBytes)),
+			ActorID:       actor.ID,
+			ActorRole:     actor.Role,
+			RequestID:     actor.RequestID,
+		},
+		patientID: patientID,
+	}, nil
+}
+
+// seal chains the entry to prevHash and signs it. Callers hold a.mu.
+func (a *AuditService) seal(entry *models.AuditLog, prevHash string) {
+	entry.PrevHash = prevHash
+
+	// Calculate the current hash (hash of entire entry except CurrentHash)
+	entry.CurrentHash = entryHash(*entry)
 
 	// ✍️ DIGITAL SIGNATURE (Phase 1 Compliance)
 	// Sign the (PayloadHash + Timestamp) to prove authenticity
-	msg := []byte(fmt.Sprintf("%s|%s", payloadHash, entry.Timestamp.Format(time.RFC3339)))
-	signature := ed25519.Sign(a.privateKey, msg)
-	
+	signature := ed25519.Sign(a.privateKey, signedMessage(*entry))
+
 	entry.ActorSignature = hex.EncodeToString(signature)
 	entry.ActorPublicKey = hex.EncodeToString(a.publicKey)
+	entry.KeyID = KeyID(a.publicKey)
+}
 
-	// Save to database
-	if err := a.DB.Create(&entry).Error; err != nil {
-		log.Printf("❌ Audit Log Error: %v", err)
-		return entry, err
-	}
-
-	// Update the chain
-	a.lastHash = entry.CurrentHash
-
-	// --- BLOCKCHAIN INTEGRATION ---
-	// Also write to the in-memory high-performance ledger
-	blockPayload := map[string]interface{}{
-		"event_type": eventType,
+// mirror writes a saved entry to the in-memory high-performance ledger
+func (a *AuditService) mirror(entry models.AuditLog, patientID uint) {
+	a.Chain.AddBlock(map[string]interface{}{
+		"event_type": entry.EventType,
 		"entity_id":  patientID,
-		"data_hash":  payloadHash,
+		"data_hash":  entry.PayloadHash,
 		"timestamp":  entry.Timestamp,
-		"actor":      actorID,
+		"actor":      entry.ActorID,
+		"actor_role": entry.ActorRole,
 		"signature":  entry.ActorSignature, // Add signature to block
+	})
+}
+
+// LogEvent creates a new audit log entry chained to the previous one. With
+// the writer started, the entry is returned sealed and saved in the background.
+func (a *AuditService) LogEvent(eventType string, patientID uint, payload interface{}, actor auditctx.Identity) (models.AuditLog, error) {
+	event, err := a.newEvent(eventType, patientID, payload, actor)
+	if err != nil {
+		return models.AuditLog{}, err
 	}
-	blockchain.GlobalChain.AddBlock(blockPayload)
-	// -------------------------------
+	entries, err := a.commit([]pendingEvent{event})
+	if err != nil {
+		return models.AuditLog{}, err
+	}
+	entry := entries[0]
 
-	log.Printf("📜 Audit Log: [%s] Patient %s | Hash: %s...%s | Signed: ✅",
-		eventType,
-		patientIDHash[:8],
-		entry.CurrentHash[:8],
-		entry.CurrentHash[len(entry.CurrentHash)-8:],
-	)
+	logging.ForRequest(actor.RequestID).Debug("audit event logged", "event", eventType,
+		"patient_hash", entry.PatientIDHash[:8], "hash", entry.CurrentHash[:8])
 
 	return entry, nil
 }
 
-// VerifyChain checks if the entire audit chain is intact (no tampering)
+// commit chains and signs events in order, then saves them: directly, or
+// by handing them to the writer, blocking while its queue is full. Sealing
+// and queueing happen under a.mu, so the queue holds entries in chain order.
+func (a *AuditService) commit(events []pendingEvent) ([]models.AuditLog, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entries := make([]models.AuditLog, len(events))
+	prev := a.lastHash
+	for i, event := range events {
+		entries[i] = event.entry
+		a.seal(&entries[i], prev)
+		prev = entries[i].CurrentHash
+	}
+
+	if a.queue != nil {
+		a.queued.Add(int64(len(entries)))
+		a.queue <- auditWrite{entries: slices.Clone(entries)} // The insert sets IDs; callers keep their copy
+	} else if err := a.insert(entries); err != nil {
+		slog.Error("audit write failed", "events", len(entries), "error", err)
+		return nil, err
+	}
+
+	// Update the chain
+	a.lastHash = prev
+	for i, event := range events {
+		a.mirror(entries[i], event.patientID)
+	}
+	return entries, nil
+}
+
+func (a *AuditService) insert(entries []models.AuditLog) error {
+	return a.DB.CreateInBatches(entries, 100).Error
+}
+
+// AuditTx logs events inside a transaction opened by InTransaction
+type AuditTx struct {
+	a       *AuditService
+	tx      *gorm.DB
+	prev    string
+	entries []pendingEvent
+}
+
+// LogEvent chains and saves an entry with the transaction; it reaches the
+// in-memory ledger only once the transaction commits
+func (atx *AuditTx) LogEvent(eventType string, patientID uint, payload interface{}, actor auditctx.Identity) (models.AuditLog, error) {
+	event, err := atx.a.newEvent(eventType, patientID, payload, actor)
+	if err != nil {
+		return models.AuditLog{}, err
+	}
+	atx.a.seal(&event.entry, atx.prev)
+	if err := atx.tx.Create(&event.entry).Error; err != nil {
+		return models.AuditLog{}, err
+	}
+	atx.prev = event.entry.CurrentHash
+	atx.entries = append(atx.entries, event)
+	return event.entry, nil
+}
+
+// InTransaction runs fn in a database transaction whose audit entries commit
+// or roll back with the rest of its writes. Other events wait until it ends,
+// so the chain never links to an entry that was rolled back; entries already
+// queued for the writer are saved first, keeping IDs in chain order.
+func (a *AuditService) InTransaction(fn func(tx *gorm.DB, audit *AuditTx) error) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.queue != nil {
+		flushed := make(chan struct{})
+		a.queue <- auditWrite{flushed: flushed}
+		<-flushed
+	}
+
+	atx := &AuditTx{a: a, prev: a.lastHash}
+	err := a.DB.Transaction(func(tx *gorm.DB) error {
+		atx.tx = tx
+		return fn(tx, atx)
+	})
+	if err != nil {
+		return err
+	}
+
+	a.lastHash = atx.prev
+	for _, event := range atx.entries {
+		a.mirror(event.entry, event.patientID)
+	}
+	return nil
+}
+
+// auditWrite is one item on the writer's queue: entries to save, or a
+// flush marker closed once everything queued before it is saved
+type auditWrite struct {
+	entries []models.AuditLog
+	flushed chan struct{}
+}
+
+// Writer backoff between attempts at a batch, doubling up to the cap
+const (
+	auditWriteBackoff    = 100 * time.Millisecond
+	auditWriteMaxBackoff = 5 * time.Second
+)
+
+// StartWriter moves database inserts off the callers of LogEvent onto one
+// background writer with a queue of queueSize batches. Call it once, before
+// logging anything; without it every event is saved synchronously.
+func (a *AuditService) StartWriter(queueSize int) {
+	if queueSize < 1 {
+		queueSize = 1
+	}
+	a.mu.Lock()
+	a.queue = make(chan auditWrite, queueSize)
+	a.mu.Unlock()
+
+	go func() {
+		for w := range a.queue {
+			if w.flushed != nil {
+				close(w.flushed)
+				continue
+			}
+			a.write(w.entries)
+		}
+	}()
+}
+
+// write saves a batch for the writer, retrying with capped backoff until it
+// succeeds. Entries are already chained, so a lost batch would leave a
+// permanent gap; while the database is down the queue fills and callers
+// wait instead. Only Close makes the writer give up, dropping and counting
+// what it can't save.
+func (a *AuditService) write(entries []models.AuditLog) {
+	defer a.queued.Add(-int64(len(entries)))
+	backoff := auditWriteBackoff
+	for attempt := 1; ; attempt++ {
+		err := a.insert(entries)
+		if err == nil {
+			a.written.Add(int64(len(entries)))
+			return
+		}
+		select {
+		case <-a.stop:
+			a.dropped.Add(int64(len(entries)))
+			slog.Error("audit events dropped at shutdown, the chain now has a gap", "events", len(entries), "attempts", attempt, "error", err)
+			return
+		case <-time.After(backoff):
+		}
+		slog.Warn("audit write failed, retrying", "events", len(entries), "attempt", attempt, "error", err)
+		backoff = min(2*backoff, auditWriteMaxBackoff)
+	}
+}
+
+// Flush writes the buffered access events and waits until every event
+// logged before the call is in the database. Reads of the audit log call it
+// first, as does graceful shutdown.
+func (a *AuditService) Flush() error {
+	_, err := a.FlushAccess()
+
+	a.mu.Lock()
+	if a.queue == nil {
+		a.mu.Unlock()
+		return err
+	}
+	flushed := make(chan struct{})
+	a.queue <- auditWrite{flushed: flushed}
+	a.mu.Unlock()
+
+	<-flushed
+	return err
+}
+
+// Close flushes like Flush for graceful shutdown. If ctx ends first, the
+// writer stops retrying: what it still can't save is dropped and counted,
+// and Close returns ctx's error.
+func (a *AuditService) Close(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() { done <- a.Flush() }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	a.stopOnce.Do(func() { close(a.stop) })
+	<-done
+	return fmt.Errorf("audit writer stopped with %d events unsaved: %w", a.dropped.Load(), ctx.Err())
+}
+
+// AuditWriterStats counts events through the background writer
+type AuditWriterStats struct {
+	Queued  int64 `json:"queued"` // Logged, not yet saved
+	Written int64 `json:"written"`
+	Dropped int64 `json:"dropped"` // Given up on at shutdown; should stay 0
+}
+
+func (a *AuditService) Stats() AuditWriterStats {
+	return AuditWriterStats{Queued: a.queued.Load(), Written: a.written.Load(), Dropped: a.dropped.Load()}
+}
+
+// Register exposes the writer counters on a Prometheus registry
+func (a *AuditService) Register(reg prometheus.Registerer) error {
+	metrics := []prometheus.Collector{
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: "audit_events_queued", Help: "Audit events waiting for the background writer."}, func() float64 {
+			return float64(a.queued.Load())
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{Name: "audit_events_written_total", Help: "Audit events saved by the background writer."}, func() float64 {
+			return float64(a.written.Load())
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{Name: "audit_events_dropped_total", Help: "Audit events the background writer failed to save."}, func() float64 {
+			return float64(a.dropped.Load())
+		}),
+	}
+	for _, m := range metrics {
+		if err := reg.Register(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LogAccess records that actor read a patient's data (patient 0 for views
+// that span patients). The event is buffered: it reaches the chain on the
+// next FlushAccess, keeping the time of the read.
+func (a *AuditService) LogAccess(eventType string, patientID uint, route string, actor auditctx.Identity) error {
+	event, err := a.newEvent(eventType, patientID, map[string]string{"route": route}, actor)
+	if err != nil {
+		return err
+	}
+
+	a.accessMu.Lock()
+	a.accessPending = append(a.accessPending, event)
+	full := len(a.accessPending) >= a.AccessBatchSize
+	a.accessMu.Unlock()
+	if full {
+		select {
+		case a.accessFull <- struct{}{}:
+		default: // A flush is already due
+		}
+	}
+	return nil
+}
+
+// FlushAccess chains and saves the buffered access events as one batch.
+// If saving them directly fails they stay buffered for the next flush.
+func (a *AuditService) FlushAccess() (int, error) {
+	a.accessMu.Lock()
+	batch := a.accessPending
+	a.accessPending = nil
+	a.accessMu.Unlock()
+	if len(batch) == 0 {
+		return 0, nil
+	}
+
+	entries, err := a.commit(batch)
+	if err != nil {
+		slog.Error("audit access events kept for retry", "events", len(batch), "error", err)
+		a.accessMu.Lock()
+		a.accessPending = append(batch, a.accessPending...)
+		a.accessMu.Unlock()
+		return 0, err
+	}
+	last := entries[len(entries)-1].CurrentHash
+	slog.Debug("audit access events logged", "events", len(entries), "hash", last[:8])
+	return len(entries), nil
+}
+
+// StartAccessFlusher runs FlushAccess every interval, and sooner whenever
+// AccessBatchSize events are waiting
+func (a *AuditService) StartAccessFlusher(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-a.accessFull:
+			}
+			a.FlushAccess()
+		}
+	}()
+}
+
+// AccessHistory returns one page of the read-access events for a patient
+// hash, newest first. Buffered events are flushed first so it is current.
+func (a *AuditService) AccessHistory(patientIDHash string, page, limit int) (*models.AuditLogPage, error) {
+	if err := a.Flush(); err != nil {
+		return nil, err
+	}
+	q := a.DB.Model(&models.AuditLog{}).Where("patient_id_hash = ? AND event_type IN ?", patientIDHash, AccessEvents)
+	result := &models.AuditLogPage{Page: page, Limit: limit, Items: []models.AuditLog{}}
+	if err := q.Count(&result.Total).Error; err != nil {
+		return nil, err
+	}
+	err := q.Order("timestamp DESC, id DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&result.Items).Error
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// PatientHash is how a patient ID appears in audit entries
+func PatientHash(patientID uint) string {
+	return hashString(fmt.Sprintf("%d", patientID))
+}
+
+// ByActor returns one page of the events an actor triggered, newest first
+func (a *AuditService) ByActor(actorID string, page, limit int) (*models.AuditLogPage, error) {
+	if err := a.Flush(); err != nil {
+		return nil, err
+	}
+	q := a.DB.Model(&models.AuditLog{}).Where("actor_id = ?", actorID)
+	result := &models.AuditLogPage{Page: page, Limit: limit, Items: []models.AuditLog{}}
+	if err := q.Count(&result.Total).Error; err != nil {
+		return nil, err
+	}
+	err := q.Order("id DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&result.Items).Error
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// AuditRange selects audit entries in chain order, a page at a time
+type AuditRange struct {
+	From, To *time.Time // Bounds on the timestamp, From inclusive and To exclusive; nil is open
+	After    uint       // Start after this entry ID, the previous page's last
+	Limit    int        // Entries per page
+}
+
+// auditRangeBatch is how many entries EachInRange reads per query
+const auditRangeBatch = 100
+
+func (a *AuditService) rangeQuery(r AuditRange) *gorm.DB {
+	q := a.DB.Model(&models.AuditLog{})
+	if r.From != nil {
+		q = q.Where("timestamp >= ?", *r.From)
+	}
+	if r.To != nil {
+		q = q.Where("timestamp < ?", *r.To)
+	}
+	return q
+}
+
+// CountInRange counts the entries within the range's time bounds, across
+// all pages. Buffered events are flushed first so it is current.
+func (a *AuditService) CountInRange(r AuditRange) (int64, error) {
+	if err := a.Flush(); err != nil {
+		return 0, err
+	}
+	var total int64
+	err := a.rangeQuery(r).Count(&total).Error
+	return total, err
+}
+
+// EachInRange calls fn with one page of the range's entries, oldest first,
+// reading a few at a time. It returns the ID of the last entry, to start the
+// next page after, and whether there is a next page.
+func (a *AuditService) EachInRange(r AuditRange, fn func(models.AuditLog) error) (uint, bool, error) {
+	last := r.After
+	for remaining := r.Limit; remaining > 0; {
+		var batch []models.AuditLog
+		err := a.rangeQuery(r).Where("id > ?", last).Order("id").Limit(min(remaining, auditRangeBatch)).Find(&batch).Error
+		if err != nil {
+			return last, false, err
+		}
+		for _, entry := range batch {
+			if err := fn(entry); err != nil {
+				return last, false, err
+			}
+			last = entry.ID
+		}
+		if len(batch) < min(remaining, auditRangeBatch) {
+			return last, false, nil
+		}
+		remaining -= len(batch)
+	}
+	var next []uint
+	err := a.rangeQuery(r).Where("id > ?", last).Order("id").Limit(1).Pluck("id", &next).Error
+	return last, len(next) > 0, err
+}
+
+// ChainVerification reports one verification pass over the audit chain
+type ChainVerification struct {
+	Valid       bool   `json:"valid"`
+	Incremental bool   `json:"incremental"`  // Started from the last checkpoint rather than genesis
+	CheckedFrom uint   `json:"checked_from"` // ID of the first entry checked, 0 if none were new
+	CheckedTo   uint   `json:"checked_to"`   // ID of the last entry checked, or the broken one
+	Checked     int    `json:"checked"`      // Entries found intact in this pass
+	Length      int64  `json:"length"`       // Entries in the whole chain
+	Error       string `json:"error,omitempty"`
+}
+
+// chainCheckpoint is the newest entry a verification found intact. Entries
+// up to it are not re-read on the next incremental pass.
+type chainCheckpoint struct {
+	ID   uint
+	Hash string
+}
+
+var genesisCheckpoint = chainCheckpoint{Hash: "GENESIS"} // Genesis block has no previous hash
+
+// VerifyChain checks if the entire audit chain is intact (no tampering) and
+// every entry carries a valid signature
 func (a *AuditService) VerifyChain() (bool, int, error) {
-	var entries []models.AuditLog
-	if err := a.DB.Order("id ASC").Find(&entries).Error; err != nil {
+	res, err := a.Verify(true)
+	if err != nil {
 		return false, 0, err
 	}
+	if !res.Valid {
+		return false, res.Checked, errors.New(res.Error)
+	}
+	return true, res.Checked, nil
+}
+
+// Verify checks the entries added since the last checkpoint, or the whole
+// chain when full is set or the checkpoint entry itself has changed. Edits
+// to entries before the checkpoint are only caught by a full pass.
+func (a *AuditService) Verify(full bool) (ChainVerification, error) {
+	if err := a.Flush(); err != nil {
+		return ChainVerification{}, err
+	}
+	a.verifyMu.Lock()
+	defer a.verifyMu.Unlock()
+
+	from := a.checkpoint
+	if full || from.ID == 0 {
+		from = genesisCheckpoint
+	} else {
+		var anchor models.AuditLog
+		if err := a.DB.Select("current_hash").First(&anchor, from.ID).Error; err != nil || anchor.CurrentHash != from.Hash {
+			from = genesisCheckpoint
+		}
+	}
+
+	res := ChainVerification{Incremental: from.ID != 0}
+	if err := a.DB.Model(&models.AuditLog{}).Count(&res.Length).Error; err != nil {
+		return res, err
+	}
+	reached, err := a.verifyFrom(from, &res)
+	if err != nil {
+		return res, err
+	}
+	if res.Valid {
+		a.checkpoint = reached
+	}
+	return res, nil
+}
 
-	if len(entries) == 0 {
-		return true, 0, nil
+// verifyFrom checks every entry after the checkpoint, streaming rows so the
+// table is never loaded whole, and returns the newest intact entry
+func (a *AuditService) verifyFrom(from chainCheckpoint, res *ChainVerification) (chainCheckpoint, error) {
+	rows, err := a.DB.Model(&models.AuditLog{}).Where("id > ?", from.ID).Order("id ASC").Rows()
+	if err != nil {
+		return from, err
 	}
+	defer rows.Close()
+
+	prevHash := from.Hash
+	for rows.Next() {
+		var entry models.AuditLog
+		if err := a.DB.ScanRows(rows, &entry); err != nil {
+			return from, err
+		}
+		if res.CheckedFrom == 0 {
+			res.CheckedFrom = entry.ID
+		}
+		res.CheckedTo = entry.ID
+		i := res.Checked
 
-	prevHash := "GENESIS"
-	for i, entry := range entries {
 		// Verify the previous hash matches
 		if entry.PrevHash != prevHash {
-			return false, i, fmt.Errorf("chain broken at entry %d: expected prev_hash %s, got %s", i, prevHash, entry.PrevHash)
+			res.Error = fmt.Sprintf("chain broken at entry %d (id %d): expected prev_hash %s, got %s", i, entry.ID, prevHash, entry.PrevHash)
+			return from, nil
 		}
 
 		// Recalculate the current hash to verify integrity
-		entryData := fmt.Sprintf("%s|%s|%s|%s|%s|%s",
-			entry.Timestamp.Format(time.RFC3339Nano),
-			entry.EventType,
-			entry.PatientIDHash,
-			entry.PayloadHash,
-			entry.PrevHash,
-			entry.ActorID,
-		)
-		expectedHash := hashString(entryData)
+		expectedHash := entryHash(entry)
 
 		if entry.CurrentHash != expectedHash {
-			return false, i, fmt.Errorf("hash mismatch at entry %d: expected %s, got %s", i, expectedHash, entry.CurrentHash)
+			res.Error = fmt.Sprintf("hash mismatch at entry %d (id %d): expected %s, got %s", i, entry.ID, expectedHash, entry.CurrentHash)
+			return from, nil
+		}
+
+		if err := a.VerifySignature(entry); err != nil {
+			res.Error = fmt.Sprintf("signature invalid at entry %d (id %d): %v", i, entry.ID, err)
+			return from, nil
 		}
 
 		prevHash = entry.CurrentHash
+		from = chainCheckpoint{ID: entry.ID, Hash: entry.CurrentHash}
+		res.Checked++
+	}
+	if err := rows.Err(); err != nil {
+		return from, err
 	}
 
-	return true, len(entries), nil
+	res.Valid = true
+	return from, nil
 }
 
-// ExportChain retrieves the full chain for backup
-func (a *AuditService) ExportChain() ([]byte, error) {
-	var entries []models.AuditLog
-	if err := a.DB.Order("id ASC").Find(&entries).Error; err != nil {
-		return nil, err
+// auditExportBatch is how many rows an export reads per query
+const auditExportBatch = 1000
+
+// ExportNDJSON writes the chain to w as one JSON entry per line, oldest first.
+// Rows are read in batches by ID rather than through one open cursor, so a
+// slow reader never holds the table.
+func (a *AuditService) ExportNDJSON(w io.Writer) (int, error) {
+	if err := a.Flush(); err != nil {
+		return 0, err
+	}
+	enc := json.NewEncoder(w)
+	n := 0
+	var batch []models.AuditLog
+	err := a.DB.FindInBatches(&batch, auditExportBatch, func(tx *gorm.DB, _ int) error {
+		for _, entry := range batch {
+			if err := enc.Encode(entry); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	}).Error
+	return n, err
+}
+
+// maxReportedDiffs caps the entry IDs listed in a ChainComparison
+const maxReportedDiffs = 100
+
+// EntryMismatch is a backed-up entry whose hash differs from the local copy
+type EntryMismatch struct {
+	ID         uint   `json:"id"`
+	BackupHash string `json:"backup_hash"`
+	LocalHash  string `json:"local_hash"`
+}
+
+// ChainComparison reports how a backed-up chain differs from the local one
+type ChainComparison struct {
+	Entries       int             `json:"entries"` // Entries in the backup
+	Matched       int             `json:"matched"`
+	MismatchCount int             `json:"mismatch_count"`
+	Mismatched    []EntryMismatch `json:"mismatched"` // First maxReportedDiffs
+	MissingCount  int             `json:"missing_count"`
+	Missing       []uint          `json:"missing"`       // In the backup but not local; first maxReportedDiffs
+	NewerLocally  int64           `json:"newer_locally"` // Local entries written after the backup
+	BackupIntact  bool            `json:"backup_intact"` // The backup's own hashes, links and signatures check out
+	BackupError   string          `json:"backup_error,omitempty"`
+}
+
+// Consistent reports whether the backup is intact and agrees with every local entry
+func (c ChainComparison) Consistent() bool {
+	return c.BackupIntact && c.MismatchCount == 0 && c.MissingCount == 0
+}
+
+// CompareNDJSON checks an exported chain, as written by ExportNDJSON, against
+// itself and against the local audit log, a batch of entries at a time
+func (a *AuditService) CompareNDJSON(r io.Reader) (ChainComparison, error) {
+	if err := a.Flush(); err != nil {
+		return ChainComparison{}, err
+	}
+	cmp := ChainComparison{Mismatched: []EntryMismatch{}, Missing: []uint{}, BackupIntact: true}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+
+	prevHash := genesisCheckpoint.Hash
+	var lastID uint
+	batch := make([]models.AuditLog, 0, auditExportBatch)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		ids := make([]uint, len(batch))
+		for i, entry := range batch {
+			ids[i] = entry.ID
+		}
+		var local []models.AuditLog
+		if err := a.DB.Select("id, current_hash").Where("id IN ?", ids).Find(&local).Error; err != nil {
+			return err
+		}
+		localHash := make(map[uint]string, len(local))
+		for _, entry := range local {
+			localHash[entry.ID] = entry.CurrentHash
+		}
+		for _, entry := range batch {
+			hash, ok := localHash[entry.ID]
+			switch {
+			case !ok:
+				if cmp.MissingCount++; len(cmp.Missing) < maxReportedDiffs {
+					cmp.Missing = append(cmp.Missing, entry.ID)
+				}
+			case hash != entry.CurrentHash:
+				if cmp.MismatchCount++; len(cmp.Mismatched) < maxReportedDiffs {
+					cmp.Mismatched = append(cmp.Mismatched, EntryMismatch{ID: entry.ID, BackupHash: entry.CurrentHash, LocalHash: hash})
+				}
+			default:
+				cmp.Matched++
+			}
+		}
+		batch = batch[:0]
+		return nil
 	}
-	return json.Marshal(entries)
+
+	for scanner.Scan() {
+		var entry models.AuditLog
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return cmp, fmt.Errorf("backup entry %d: %w", cmp.Entries, err)
+		}
+		if cmp.BackupIntact {
+			switch {
+			case entry.PrevHash != prevHash:
+				cmp.BackupError = fmt.Sprintf("chain broken at backup entry %d (id %d)", cmp.Entries, entry.ID)
+			case entry.CurrentHash != entryHash(entry):
+				cmp.BackupError = fmt.Sprintf("hash mismatch at backup entry %d (id %d)", cmp.Entries, entry.ID)
+			case a.VerifySignature(entry) != nil:
+				cmp.BackupError = fmt.Sprintf("signature invalid at backup entry %d (id %d)", cmp.Entries, entry.ID)
+			}
+			cmp.BackupIntact = cmp.BackupError == ""
+		}
+		prevHash = entry.CurrentHash
+		lastID = entry.ID
+		cmp.Entries++
+
+		if batch = append(batch, entry); len(batch) == auditExportBatch {
+			if err := flush(); err != nil {
+				return cmp, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return cmp, err
+	}
+	if err := flush(); err != nil {
+		return cmp, err
+	}
+
+	err := a.DB.Model(&models.AuditLog{}).Where("id > ?", lastID).Count(&cmp.NewerLocally).Error
+	return cmp, err
 }
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"healthcare-backend/pkg/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var (
+	// ErrBackupUnsupported is returned for non-SQLite deployments
+	ErrBackupUnsupported = errors.New("database backups are only supported for SQLite deployments")
+	// ErrBackupTooLarge is returned when the database exceeds the configured limit
+	ErrBackupTooLarge = errors.New("database exceeds the backup size limit")
+	// ErrBackupNotFound is returned for unknown or malformed backup names
+	ErrBackupNotFound = errors.New("backup not found")
+)
+
+var backupNamePattern = regexp.MustCompile(`^clinical-\d{8}T\d{6}(\.\d+)?Z\.db$`)
+
+// BackupService takes online SQLite backups with VACUUM INTO. Each backup is
+// written next to a .sha256 sidecar so integrity can be checked before restore.
+type BackupService struct {
+	DB       *gorm.DB
+	Dir      string
+	MaxBytes int64
+}
+
+func NewBackupService(db *gorm.DB, dir string, maxBytes int64) *BackupService {
+	return &BackupService{DB: db, Dir: dir, MaxBytes: maxBytes}
+}
+
+// BackupVerification is the result of checking a backup before restore
+type BackupVerification struct {
+	Backup         models.DBBackup `json:"backup"`
+	ChecksumValid  bool            `json:"checksum_valid"`
+	IntegrityCheck string          `json:"integrity_check"`
+	Valid          bool            `json:"valid"`
+}
+
+func (s *BackupService) supported() error {
+	if s.DB.Dialector.Name() != "sqlite" {
+		return ErrBackupUnsupported
+	}
+	return nil
+}
+
+// Backup writes a consistent copy of the live database without blocking writers
+func (s *BackupService) Backup() (*models.DBBackup, error) {
+	if err := s.supported(); err != nil {
+		return nil, err
+	}
+
+	var pageCount, pageSize int64
+	s.DB.Raw("PRAGMA page_count").Scan(&pageCount)
+	s.DB.Raw("PRAGMA page_size").Scan(&pageSize)
+	if s.MaxBytes > 0 && pageCount*pageSize > s.MaxBytes {
+		return nil, fmt.Errorf("%w (%d > %d bytes)", ErrBackupTooLarge, pageCount*pageSize, s.MaxBytes)
+	}
+
+	if err := os.MkdirAll(s.Dir, 0750); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	name := fmt.Sprintf("clinical-%s.db", now.Format("20060102T150405.000Z"))
+	path := filepath.Join(s.Dir, name)
+	if err := s.DB.Exec("VACUUM INTO ?", path).Error; err != nil {
+		return nil, fmt.Errorf("vacuum into: %w", err)
+	}
+
+	sum, size, err := fileChecksum(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path+".sha256", []byte(sum+"  "+name+"\n"), 0640); err != nil {
+		return nil, err
+	}
+
+	return &models.DBBackup{Filename: name, SizeBytes: size, SHA256: sum, CreatedAt: now}, nil
+}
+
+// List returns backups, newest first
+func (s *BackupService) List() ([]models.DBBackup, error) {
+	if err := s.supported(); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []models.DBBackup{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []models.DBBackup{}
+	for _, e := range entries {
+		if !backupNamePattern.MatchString(e.Name()) {
+			continue
+		}
+		if b, err := s.describe(e.Name()); err == nil {
+			backups = append(backups, *b)
+		}
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Filename > backups[j].Filename })
+	return backups, nil
+}
+
+// Path resolves a backup name to its file, rejecting anything outside the backup dir
+func (s *BackupService) Path(name string) (string, error) {
+	if !backupNamePattern.MatchString(name) {
+		return "", ErrBackupNotFound
+	}
+	path := filepath.Join(s.Dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", ErrBackupNotFound
+	}
+	return path, nil
+}
+
+// Verify recomputes the checksum and runs PRAGMA integrity_check on the backup
+func (s *BackupService) Verify(name string) (*BackupVerification, error) {
+	if err := s.supported(); err != nil {
+		return nil, err
+	}
+	path, err := s.Path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := s.describe(name)
+	if err != nil {
+		return nil, err
+	}
+	sum, _, err := fileChecksum(path)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BackupVerification{Backup: *b, ChecksumValid: sum == b.SHA256}
+
+	backupDB, err := gorm.Open(sqlite.Open("file:"+path+"?mode=ro"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		result.IntegrityCheck = err.Error()
+		return result, nil
+	}
+	if sqlDB, err := backupDB.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+	var check string
+	if err := backupDB.Raw("PRAGMA integrity_check").Scan(&check).Error; err != nil {
+		check = err.Error()
+	}
+	result.IntegrityCheck = check
+	result.Valid = result.ChecksumValid && check == "ok"
+	return result, nil
+}
+
+// describe reads size and the recorded checksum for a backup
+func (s *BackupService) describe(name string) (*models.DBBackup, error) {
+	path := filepath.Join(s.Dir, name)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	sidecar, err := os.ReadFile(path + ".sha256")
+	if err != nil {
+		return nil, fmt.Errorf("missing checksum for %s: %w", name, err)
+	}
+	fields := strings.Fields(string(sidecar))
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty checksum for %s", name)
+	}
+
+	created, _ := time.Parse("20060102T150405.000Z", strings.TrimSuffix(strings.TrimPrefix(name, "clinical-"), ".db"))
+	return &models.DBBackup{Filename: name, SizeBytes: info.Size(), SHA256: fields[0], CreatedAt: created}, nil
+}
+
+func fileChecksum(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"healthcare-backend/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrClinicNotFound     = errors.New("clinic not found")
+	ErrInvalidClinicHours = errors.New("invalid clinic working hours")
+)
+
+// ClinicService stores per-clinic settings such as working hours
+type ClinicService struct {
+	DB *gorm.DB
+}
+
+func NewClinicService(db *gorm.DB) *ClinicService {
+	return &ClinicService{DB: db}
+}
+
+func (s *ClinicService) List() ([]models.Clinic, error) {
+	var clinics []models.Clinic
+	err := s.DB.Order("id").Find(&clinics).Error
+	return clinics, err
+}
+
+func (s *ClinicService) Get(id string) (*models.Clinic, error) {
+	var c models.Clinic
+	if err := s.DB.Where("id = ?", id).First(&c).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrClinicNotFound
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Save validates and creates or replaces a clinic
+func (s *ClinicService) Save(c *models.Clinic) error {
+	c.ID = strings.TrimSpace(c.ID)
+	if c.ID == "" {
+		return ErrClinicRequired
+	}
+	if _, err := NewClinicHours(*c); err != nil {
+		return err
+	}
+	return s.DB.Save(c).Error
+}
+
+// clinicWindow is a WorkingWindow in minutes after local midnight
+type clinicWindow struct {
+	start, end int
+}
+
+// ClinicHours answers whether a clinic is staffed at a given instant
+type ClinicHours struct {
+	loc      *time.Location
+	windows  [7][]clinicWindow
+	holidays map[string]bool
+	always   bool // No windows configured
+}
+
+// NewClinicHours parses a clinic's timezone, windows and holidays
+func NewClinicHours(c models.Clinic) (*ClinicHours, error) {
+	loc := time.UTC
+	if c.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(c.Timezone); err != nil {
+			return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidClinicHours, c.Timezone)
+		}
+	}
+
+	h := &ClinicHours{loc: loc, holidays: map[string]bool{}, always: len(c.Hours) == 0}
+	for _, w := range c.Hours {
+		if w.Weekday < time.Sunday || w.Weekday > time.Saturday {
+			return nil, fmt.Errorf("%w: weekday %d out of range", ErrInvalidClinicHours, w.Weekday)
+		}
+		start, err := parseClock(w.Start)
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseClock(w.End)
+		if err != nil {
+			return nil, err
+		}
+		if end <= start {
+			return nil, fmt.Errorf("%w: %s-%s must end after it starts", ErrInvalidClinicHours, w.Start, w.End)
+		}
+		h.windows[w.Weekday] = append(h.windows[w.Weekday], clinicWindow{start, end})
+	}
+	for _, d := range c.Holidays {
+		if _, err := time.Parse(time.DateOnly, d); err != nil {
+			return nil, fmt.Errorf("%w: holiday %q is not YYYY-MM-DD", ErrInvalidClinicHours, d)
+		}
+		h.holidays[d] = true
+	}
+	return h, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("%w: time %q is not HH:MM", ErrInvalidClinicHours, s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Location is the clinic's timezone
+func (h *ClinicHours) Location() *time.Location {
+	return h.loc
+}
+
+// Open reports whether t falls inside a working window on a non-holiday
+func (h *ClinicHours) Open(t time.Time) bool {
+	if h.always {
+		return true
+	}
+	local := t.In(h.loc)
+	if h.holidays[local.Format(time.DateOnly)] {
+		return false
+	}
+	minute := local.Hour()*60 + local.Minute()
+	for _, w := range h.windows[local.Weekday()] {
+		if minute >= w.start && minute < w.end {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpen returns the first instant at or after t when the clinic is open,
+// or false when no window opens within a year (every day a holiday)
+func (h *ClinicHours) NextOpen(t time.Time) (time.Time, bool) {
+	if h.Open(t) {
+		return t, true
+	}
+	local := t.In(h.loc)
+	for day := 0; day <= 366; day++ {
+		date := local.AddDate(0, 0, day)
+		if h.holidays[date.Format(time.DateOnly)] {
+			continue
+		}
+		var best time.Time
+		for _, w := range h.windows[date.Weekday()] {
+			// time.Date resolves DST gaps, so 02:30 on a spring-forward day still maps to an instant
+			start := time.Date(date.Year(), date.Month(), date.Day(), w.start/60, w.start%60, 0, 0, h.loc)
+			if start.Before(t) {
+				continue
+			}
+			if best.IsZero() || start.Before(best) {
+				best = start
+			}
+		}
+		if !best.IsZero() {
+			return best, true
+		}
+	}
+	return time.Time{}, false
+}
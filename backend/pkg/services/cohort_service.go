@@ -0,0 +1,187 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/privacy/dp"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrPrivacyBudgetExhausted = errors.New("privacy budget exhausted for this period")
+
+// CohortFilter selects the patients a cohort query aggregates over
+type CohortFilter struct {
+	MinAge  int
+	MaxAge  int    // 0 means no upper bound
+	Gender  string // Empty means any
+	Smoking string
+}
+
+// CohortPrivacy declares how a differentially private answer was produced
+type CohortPrivacy struct {
+	Mechanism       string  `json:"mechanism"`        // "laplace"
+	Epsilon         float64 `json:"epsilon"`          // Total spent on this query
+	EpsilonPerStat  float64 `json:"epsilon_per_stat"` // Split evenly across the count and each mean
+	Period          string  `json:"period"`
+	BudgetRemaining float64 `json:"budget_remaining"`
+}
+
+// CohortStats is an aggregate over a cohort. Means are over clamped values.
+type CohortStats struct {
+	Count   float64            `json:"count"`
+	Means   map[string]float64 `json:"means"`
+	Privacy *CohortPrivacy     `json:"privacy,omitempty"`
+}
+
+// cohortField is a numeric column and the bounds its values are clamped to
+type cohortField struct {
+	Column string
+	Lo, Hi float64
+}
+
+var cohortFields = []cohortField{
+	{"age", 0, 120},
+	{"systolic_bp", 60, 250},
+	{"glucose", 40, 400},
+	{"bmi", 10, 70},
+}
+
+// CohortService answers aggregate questions about patient cohorts
+type CohortService struct {
+	DB *gorm.DB
+}
+
+func NewCohortService(db *gorm.DB) *CohortService {
+	return &CohortService{DB: db}
+}
+
+func (s *CohortService) query(f CohortFilter) *gorm.DB {
+	q := s.DB.Model(&models.PatientData{}).Where("age >= ?", f.MinAge)
+	if f.MaxAge > 0 {
+		q = q.Where("age <= ?", f.MaxAge)
+	}
+	if f.Gender != "" {
+		q = q.Where("gender = ?", f.Gender)
+	}
+	if f.Smoking != "" {
+		q = q.Where("smoking = ?", f.Smoking)
+	}
+	return q
+}
+
+// rows loads the clamped-field values of the cohort, one slice per field
+func (s *CohortService) rows(f CohortFilter) ([][]float64, error) {
+	var patients []models.PatientData
+	if err := s.query(f).Select("age, systolic_bp, glucose, bmi").Find(&patients).Error; err != nil {
+		return nil, err
+	}
+	values := make([][]float64, len(cohortFields))
+	for _, p := range patients {
+		for i, v := range []float64{float64(p.Age), float64(p.SystolicBP), float64(p.Glucose), p.BMI} {
+			values[i] = append(values[i], v)
+		}
+	}
+	return values, nil
+}
+
+// Exact returns the true count and clamped means
+func (s *CohortService) Exact(f CohortFilter) (*CohortStats, error) {
+	values, err := s.rows(f)
+	if err != nil {
+		return nil, err
+	}
+	stats := &CohortStats{Count: float64(len(values[0])), Means: map[string]float64{}}
+	for i, field := range cohortFields {
+		if len(values[i]) == 0 {
+			continue
+		}
+		sum := 0.0
+		for _, v := range values[i] {
+			sum += dp.Clamp(v, field.Lo, field.Hi)
+		}
+		stats.Means[field.Column] = sum / float64(len(values[i]))
+	}
+	return stats, nil
+}
+
+// Private returns the count and means with Laplace noise, epsilon split
+// evenly across them. The caller must already have spent epsilon.
+func (s *CohortService) Private(f CohortFilter, mech *dp.Mechanism, epsilon float64) (*CohortStats, error) {
+	values, err := s.rows(f)
+	if err != nil {
+		return nil, err
+	}
+	perStat := epsilon / float64(len(cohortFields)+1)
+
+	count, err := mech.Count(len(values[0]), perStat)
+	if err != nil {
+		return nil, err
+	}
+	stats := &CohortStats{Count: count, Means: map[string]float64{}}
+	for i, field := range cohortFields {
+		mean, err := mech.Mean(values[i], field.Lo, field.Hi, perStat)
+		if err != nil {
+			return nil, err
+		}
+		stats.Means[field.Column] = mean
+	}
+	stats.Privacy = &CohortPrivacy{Mechanism: "laplace", Epsilon: epsilon, EpsilonPerStat: perStat}
+	return stats, nil
+}
+
+// PrivacyBudgetService tracks epsilon spent per caller per calendar month
+type PrivacyBudgetService struct {
+	DB    *gorm.DB
+	Limit float64 // Epsilon each caller may spend per period
+}
+
+func NewPrivacyBudgetService(db *gorm.DB, limit float64) *PrivacyBudgetService {
+	return &PrivacyBudgetService{DB: db, Limit: limit}
+}
+
+// Period is the budget period containing t
+func (s *PrivacyBudgetService) Period(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// Spend deducts epsilon from the subject's budget and returns what is left.
+// The check and the deduction are one UPDATE, so concurrent queries cannot overspend.
+func (s *PrivacyBudgetService) Spend(subject string, epsilon float64, now time.Time) (float64, error) {
+	if epsilon <= 0 {
+		return 0, dp.ErrInvalidEpsilon
+	}
+	period := s.Period(now)
+
+	row := models.PrivacyBudget{Subject: subject, Period: period, UpdatedAt: now}
+	if err := s.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&row).Error; err != nil {
+		return 0, err
+	}
+	// The 1e-9 slack keeps a budget spent in equal fractions from failing on rounding
+	res := s.DB.Model(&models.PrivacyBudget{}).
+		Where("subject = ? AND period = ? AND spent + ? <= ?", subject, period, epsilon, s.Limit+1e-9).
+		Updates(map[string]any{"spent": gorm.Expr("spent + ?", epsilon), "updated_at": now})
+	if res.Error != nil {
+		return 0, res.Error
+	}
+
+	remaining, err := s.Remaining(subject, now)
+	if err != nil {
+		return 0, err
+	}
+	if res.RowsAffected == 0 {
+		return remaining, fmt.Errorf("%w: %.3g of %.3g epsilon left in %s, query needs %.3g", ErrPrivacyBudgetExhausted, remaining, s.Limit, period, epsilon)
+	}
+	return remaining, nil
+}
+
+// Remaining is the epsilon the subject may still spend this period
+func (s *PrivacyBudgetService) Remaining(subject string, now time.Time) (float64, error) {
+	var b models.PrivacyBudget
+	err := s.DB.Where("subject = ? AND period = ?", subject, s.Period(now)).Limit(1).Find(&b).Error
+	return s.Limit - b.Spent, err
+}
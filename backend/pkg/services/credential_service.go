@@ -0,0 +1,106 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCredentialIDRequired = errors.New("credential id is required")
+	ErrCredentialExists     = errors.New("credential already exists")
+	ErrCredentialNotFound   = errors.New("credential not found")
+)
+
+// CredentialService manages database-backed API keys
+type CredentialService struct {
+	DB *gorm.DB
+}
+
+func NewCredentialService(db *gorm.DB) *CredentialService {
+	return &CredentialService{DB: db}
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func newAPIKey() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// Create adds a credential with a fresh key. The plaintext key is returned
+// once and never stored.
+func (s *CredentialService) Create(id, role string) (string, models.APICredential, error) {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return "", models.APICredential{}, ErrCredentialIDRequired
+	}
+	var existing int64
+	if err := s.DB.Model(&models.APICredential{}).Where("id = ?", id).Count(&existing).Error; err != nil {
+		return "", models.APICredential{}, err
+	}
+	if existing > 0 {
+		return "", models.APICredential{}, ErrCredentialExists
+	}
+
+	key, err := newAPIKey()
+	if err != nil {
+		return "", models.APICredential{}, err
+	}
+	cred := models.APICredential{ID: id, Role: role, KeyHash: hashAPIKey(key)}
+	if err := s.DB.Create(&cred).Error; err != nil {
+		return "", models.APICredential{}, err
+	}
+	return key, cred, nil
+}
+
+// CreateAdmin is Create with the admin role
+func (s *CredentialService) CreateAdmin(id string) (string, models.APICredential, error) {
+	return s.Create(id, auditctx.RoleAdmin)
+}
+
+// Rotate replaces a credential's key; the old key stops working immediately
+func (s *CredentialService) Rotate(id string) (string, models.APICredential, error) {
+	var cred models.APICredential
+	if err := s.DB.First(&cred, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", cred, ErrCredentialNotFound
+		}
+		return "", cred, err
+	}
+
+	key, err := newAPIKey()
+	if err != nil {
+		return "", cred, err
+	}
+	now := time.Now().UTC()
+	if err := s.DB.Model(&cred).Updates(map[string]any{"key_hash": hashAPIKey(key), "rotated_at": now}).Error; err != nil {
+		return "", cred, err
+	}
+	cred.RotatedAt = &now
+	return key, cred, nil
+}
+
+// Lookup resolves a presented key to its identity
+func (s *CredentialService) Lookup(key string) (auditctx.Identity, bool) {
+	var cred models.APICredential
+	if err := s.DB.Where("key_hash = ?", hashAPIKey(key)).Limit(1).Find(&cred).Error; err != nil || cred.ID == "" {
+		return auditctx.Identity{}, false
+	}
+	return auditctx.Identity{ID: "apikey:" + cred.ID, Role: cred.Role}, true
+}
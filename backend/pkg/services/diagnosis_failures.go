@@ -0,0 +1,79 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"healthcare-backend/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDiagnosisFailureNotFound = errors.New("diagnosis failure not found")
+	ErrAlreadyRequeued          = errors.New("diagnosis failure already requeued")
+)
+
+// DiagnosisFailureService keeps the dead-lettered LLM diagnosis tasks
+type DiagnosisFailureService struct {
+	DB *gorm.DB
+}
+
+func NewDiagnosisFailureService(db *gorm.DB) *DiagnosisFailureService {
+	return &DiagnosisFailureService{DB: db}
+}
+
+// Record stores a task that ran out of attempts
+func (s *DiagnosisFailureService) Record(req models.DiagnosisRequest, attempts int, cause error) (*models.DiagnosisFailure, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	failure := &models.DiagnosisFailure{
+		PatientID:  req.Patient.ID,
+		Generation: req.Generation,
+		Attempts:   attempts,
+		Error:      cause.Error(),
+		Request:    string(data),
+	}
+	return failure, s.DB.Create(failure).Error
+}
+
+// List returns failures newest first, by default only those not yet requeued
+func (s *DiagnosisFailureService) List(includeRequeued bool) ([]models.DiagnosisFailure, error) {
+	var failures []models.DiagnosisFailure
+	q := s.DB.Order("id DESC")
+	if !includeRequeued {
+		q = q.Where("requeued_at IS NULL")
+	}
+	err := q.Find(&failures).Error
+	return failures, err
+}
+
+// Requeue marks the failure requeued and returns the request to send again.
+// The update is conditional, so two admins can't requeue it twice.
+func (s *DiagnosisFailureService) Requeue(id uint) (*models.DiagnosisFailure, models.DiagnosisRequest, error) {
+	var failure models.DiagnosisFailure
+	var req models.DiagnosisRequest
+	if err := s.DB.First(&failure, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, req, ErrDiagnosisFailureNotFound
+		}
+		return nil, req, err
+	}
+	if err := json.Unmarshal([]byte(failure.Request), &req); err != nil {
+		return nil, req, err
+	}
+
+	now := time.Now()
+	res := s.DB.Model(&models.DiagnosisFailure{}).Where("id = ? AND requeued_at IS NULL", id).Update("requeued_at", now)
+	if res.Error != nil {
+		return nil, req, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, req, ErrAlreadyRequeued
+	}
+	failure.RequeuedAt = &now
+	return &failure, req, nil
+}
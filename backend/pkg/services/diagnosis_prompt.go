@@ -0,0 +1,78 @@
+package services
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"healthcare-backend/pkg/models"
+)
+
+// The ML service renders the LLM prompt itself (src/api/ml_api/main.py,
+// /diagnose). These mirror that template so transparency records can show
+// the prompt a request produced; bump DiagnosisPromptVersion whenever the
+// Python template, system message or model changes.
+const (
+	DiagnosisPromptVersion = "diagnose-v2"
+	DiagnosisLLMModel      = "openai/gpt-4o"
+	DiagnosisSystemPrompt  = "You are a professional medical assistant."
+)
+
+// RenderDiagnosisPrompt reproduces the user prompt the ML service builds
+// for req, without the source file's leading indentation
+func RenderDiagnosisPrompt(req models.DiagnosisRequest) string {
+	p, r := req.Patient, req.RiskScores
+	var b strings.Builder
+	line := func(parts ...string) {
+		b.WriteString(strings.Join(parts, ""))
+		b.WriteByte('\n')
+	}
+	itoa := strconv.Itoa
+
+	line("You are an expert Clinical Decision Support System (Cardiologist & Endocrinologist).")
+	line()
+	line("PAST CLINICAL KNOWLEDGE (RAG):")
+	line(req.PastContext)
+	line()
+	line("PATIENT PROFILE:")
+	line("- Age: ", itoa(p.Age), ", Gender: ", p.Gender, ", BMI: ", pyFloat(p.BMI))
+	line("- Vitals: BP ", itoa(p.SystolicBP), "/", itoa(p.DiastolicBP), ", Glucose ", itoa(p.Glucose), ", Chol ", itoa(p.Cholesterol))
+	line("- Habits: Smoking ", p.Smoking, ", Alcohol ", p.Alcohol)
+	line("- Medical History: ")
+	line("    - Heart Disease: ", p.HistoryHeartDisease)
+	line("    - Stroke: ", p.HistoryStroke)
+	line("    - Diabetes: ", p.HistoryDiabetes)
+	line("    - High Cholesterol: ", p.HistoryHighChol)
+	line()
+	line("AI RISK ASSESSMENT (Validated ML Models):")
+	line("- Heart Attack 10y Risk: ", pyNumber(r.HeartRisk), "%")
+	line("- Diabetes Probability: ", pyNumber(r.DiabetesRisk), "%")
+	line("- Stroke Risk Score: ", pyNumber(r.StrokeRisk), "%")
+	line("- Kidney Disease Risk: ", pyNumber(r.KidneyRisk), "%")
+	line()
+	line("INSTRUCTIONS:")
+	line("1. Analyze features and correlations, especially the new Medical History flags.")
+	line("2. Review 'PAST CLINICAL KNOWLEDGE' to see if similar cases were corrected by doctors before.")
+	line("3. Provide concise differential diagnosis and 3 next steps.")
+	line()
+	line("OUTPUT FORMAT:")
+	b.WriteString("Markdown. Use headings. Keep it under 200 words.")
+	return b.String()
+}
+
+// pyNumber prints a JSON number the way Python prints it after json.loads:
+// whole numbers arrive as int ("77"), others as float ("77.5")
+func pyNumber(v float64) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// pyFloat prints a value Pydantic coerced to float, which always shows a
+// fractional part ("25.0")
+func pyFloat(v float64) string {
+	s := pyNumber(v)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"healthcare-backend/pkg/cache"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DiagnosisRegistry hands out a generation token per patient for async
+// diagnoses. Starting a new diagnosis or deleting the patient bumps the
+// token, and a result that comes back with an older token is discarded, so a
+// slow LLM call can't resurrect a record that was replaced or removed.
+//
+// The token lives in memory and, when Redis is up, in diag:gen:<id> so every
+// replica's worker agrees on which diagnosis is current.
+type DiagnosisRegistry struct {
+	mu   sync.Mutex
+	gens map[uint]uint64
+
+	issued    atomic.Int64
+	cancelled atomic.Int64
+	discarded atomic.Int64
+}
+
+// DiagnosisStats counts registry activity since startup
+type DiagnosisStats struct {
+	Issued    int64 `json:"issued"`    // Diagnoses started
+	Cancelled int64 `json:"cancelled"` // Patients deleted with a diagnosis token
+	Discarded int64 `json:"discarded"` // Stale results dropped by a worker
+}
+
+func NewDiagnosisRegistry() *DiagnosisRegistry {
+	return &DiagnosisRegistry{gens: map[uint]uint64{}}
+}
+
+func diagnosisGenKey(patientID uint) string {
+	return fmt.Sprintf("diag:gen:%d", patientID)
+}
+
+// bump advances the patient's generation. Callers hold r.mu.
+func (r *DiagnosisRegistry) bump(patientID uint) uint64 {
+	gen := r.gens[patientID] + 1
+	if shared, err := cache.Incr(diagnosisGenKey(patientID)); err == nil && uint64(shared) > gen {
+		gen = uint64(shared)
+	}
+	r.gens[patientID] = gen
+	return gen
+}
+
+// current is the newest generation known locally or in Redis. Callers hold r.mu.
+func (r *DiagnosisRegistry) current(patientID uint) uint64 {
+	gen := r.gens[patientID]
+	if val, err := cache.Get(diagnosisGenKey(patientID)); err == nil {
+		if shared, err := strconv.ParseUint(val, 10, 64); err == nil && shared > gen {
+			gen = shared
+		}
+	}
+	return gen
+}
+
+// Next starts a new diagnosis for the patient, superseding any in flight
+func (r *DiagnosisRegistry) Next(patientID uint) uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.issued.Add(1)
+	return r.bump(patientID)
+}
+
+// Cancel invalidates whatever diagnosis is in flight for the patient
+func (r *DiagnosisRegistry) Cancel(patientID uint) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.current(patientID) == 0 {
+		return // Never diagnosed, nothing to cancel
+	}
+	r.cancelled.Add(1)
+	r.bump(patientID)
+}
+
+// Current reports whether gen is still the patient's newest diagnosis
+func (r *DiagnosisRegistry) Current(patientID uint, gen uint64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return gen == r.current(patientID)
+}
+
+// Complete runs write only if gen is still the patient's current generation,
+// and reports whether it did. The check and the write share the registry
+// lock, so a Cancel can't slip in between them on this replica.
+func (r *DiagnosisRegistry) Complete(patientID uint, gen uint64, write func()) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if gen != r.current(patientID) {
+		r.discarded.Add(1)
+		return false
+	}
+	write()
+	return true
+}
+
+func (r *DiagnosisRegistry) Stats() DiagnosisStats {
+	return DiagnosisStats{
+		Issued:    r.issued.Load(),
+		Cancelled: r.cancelled.Load(),
+		Discarded: r.discarded.Load(),
+	}
+}
+
+// Register exposes the registry counters on a Prometheus registry
+func (r *DiagnosisRegistry) Register(reg prometheus.Registerer) error {
+	counters := []struct {
+		name, help string
+		value      *atomic.Int64
+	}{
+		{"diagnosis_issued_total", "Async diagnoses started.", &r.issued},
+		{"diagnosis_cancelled_total", "Async diagnoses cancelled by patient deletion.", &r.cancelled},
+		{"diagnosis_stale_discarded_total", "Async diagnosis results discarded because a newer generation superseded them.", &r.discarded},
+	}
+	for _, c := range counters {
+		value := c.value
+		if err := reg.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{Name: c.name, Help: c.help}, func() float64 {
+			return float64(value.Load())
+		})); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"healthcare-backend/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// DiagnosisStore saves finished diagnoses on the context record of the
+// task that produced them, so they outlive the cache and can be searched
+type DiagnosisStore struct {
+	DB *gorm.DB
+}
+
+func NewDiagnosisStore(db *gorm.DB) *DiagnosisStore {
+	return &DiagnosisStore{DB: db}
+}
+
+// Save stores a ready diagnosis on the newest context recorded for its
+// task. Errors aren't kept, nor are tasks without a context record, such
+// as requeued ones.
+func (s *DiagnosisStore) Save(patientID uint, gen uint64, diagnosis, status string) error {
+	if status != "ready" || diagnosis == "" {
+		return nil
+	}
+	var record models.DiagnosisContext
+	err := s.DB.Where("patient_id = ? AND generation = ?", patientID, gen).Order("id DESC").Limit(1).Find(&record).Error
+	if err != nil || record.ID == 0 {
+		return err
+	}
+	// A struct update, so the column is sealed; map updates bypass the serializer
+	record.Diagnosis = diagnosis
+	return s.DB.Model(&record).Select("diagnosis").Updates(&record).Error
+}
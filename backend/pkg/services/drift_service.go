@@ -0,0 +1,131 @@
+package services
+
+import (
+	"time"
+
+	"healthcare-backend/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// weekEpoch is a Monday; week buckets are counted from here so they start on Mondays
+var weekEpoch = time.Date(1970, 1, 5, 0, 0, 0, 0, time.UTC)
+
+// DriftService aggregates stored model precisions to detect confidence drift
+type DriftService struct {
+	DB    *gorm.DB
+	Delta float64 // Minimum drop in mean confidence that raises an alert
+}
+
+func NewDriftService(db *gorm.DB, delta float64) *DriftService {
+	return &DriftService{DB: db, Delta: delta}
+}
+
+// weekBucketExpr returns a SQL expression numbering the week (since weekEpoch) of created_at
+func weekBucketExpr(db *gorm.DB) string {
+	if db.Dialector.Name() == "postgres" {
+		return "FLOOR(EXTRACT(EPOCH FROM (created_at - TIMESTAMPTZ '1970-01-05 00:00:00+00')) / 604800)"
+	}
+	return "CAST((julianday(created_at) - julianday('1970-01-05')) / 7 AS INTEGER)"
+}
+
+// IsRuleBased reports whether a prediction came from the heuristic fallback
+// (flagged as degraded, or zero precision for every model)
+func IsRuleBased(r *models.PredictResponse) bool {
+	if r == nil || r.Degraded || len(r.ModelPrecisions) == 0 {
+		return true
+	}
+	for _, p := range r.ModelPrecisions {
+		if p != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// WeeklySeries returns per-model weekly mean confidence and fallback share since the given time
+func (s *DriftService) WeeklySeries(since time.Time) ([]models.ModelDriftPoint, error) {
+	type row struct {
+		ModelName      string
+		Week           int64
+		MeanConfidence *float64
+		FallbackShare  float64
+		Samples        int64
+	}
+	var rows []row
+
+	bucket := weekBucketExpr(s.DB)
+	err := s.DB.Model(&models.AssessmentPrecision{}).
+		Select("model_name, "+bucket+" AS week, "+
+			"AVG(CASE WHEN rule_based THEN NULL ELSE confidence END) AS mean_confidence, "+
+			"AVG(CASE WHEN rule_based THEN 1.0 ELSE 0.0 END) AS fallback_share, "+
+			"COUNT(*) AS samples").
+		Where("created_at >= ?", since).
+		Group("model_name, week").
+		Order("model_name, week").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]models.ModelDriftPoint, 0, len(rows))
+	for _, r := range rows {
+		p := models.ModelDriftPoint{
+			ModelName:     r.ModelName,
+			WeekStart:     weekEpoch.AddDate(0, 0, int(r.Week)*7),
+			FallbackShare: r.FallbackShare,
+			Samples:       r.Samples,
+		}
+		if r.MeanConfidence != nil {
+			p.MeanConfidence = *r.MeanConfidence
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
+
+// CheckDrift compares each model's mean confidence over the last week with
+// the four weeks before it and returns an alert for every drop above Delta.
+func (s *DriftService) CheckDrift(now time.Time) ([]models.ModelDriftAlert, error) {
+	recentStart := now.AddDate(0, 0, -7)
+	baselineStart := recentStart.AddDate(0, 0, -28)
+
+	type row struct {
+		ModelName    string
+		RecentMean   *float64
+		BaselineMean *float64
+	}
+	var rows []row
+
+	err := s.DB.Model(&models.AssessmentPrecision{}).
+		Select("model_name, "+
+			"AVG(CASE WHEN created_at >= ? THEN confidence END) AS recent_mean, "+
+			"AVG(CASE WHEN created_at < ? THEN confidence END) AS baseline_mean",
+			recentStart, recentStart).
+		Where("rule_based = ? AND created_at >= ? AND created_at < ?", false, baselineStart, now).
+		Group("model_name").
+		Order("model_name").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	alerts := []models.ModelDriftAlert{}
+	for _, r := range rows {
+		if r.RecentMean == nil || r.BaselineMean == nil {
+			continue
+		}
+		drop := *r.BaselineMean - *r.RecentMean
+		if drop > s.Delta {
+			alerts = append(alerts, models.ModelDriftAlert{
+				ModelName:    r.ModelName,
+				RecentMean:   *r.RecentMean,
+				BaselineMean: *r.BaselineMean,
+				Drop:         drop,
+				Threshold:    s.Delta,
+				DetectedAt:   now,
+			})
+		}
+	}
+	return alerts, nil
+}
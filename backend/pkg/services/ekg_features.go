@@ -0,0 +1,118 @@
+package services
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"healthcare-backend/pkg/models"
+)
+
+// ekgFeatureAliases maps normalized key spellings onto typed features.
+// Normalization lowercases and drops everything but letters and digits,
+// so "Mean HR", "mean_hr" and "meanHR" all become "meanhr".
+var ekgFeatureAliases = map[string]string{
+	"heartrate":     "mean_hr",
+	"meanhr":        "mean_hr",
+	"meanheartrate": "mean_hr",
+	"hr":            "mean_hr",
+	"bpm":           "mean_hr",
+	"sdnn":          "sdnn",
+	"sdnnms":        "sdnn",
+	"sdrr":          "sdnn",
+	"rrstd":         "sdnn", // Our ML service reports SDNN as rr_std
+	"rmssd":         "rmssd",
+	"rmssdms":       "rmssd",
+	"qrs":           "qrs",
+	"qrsduration":   "qrs",
+	"qrsdurationms": "qrs",
+	"qrsms":         "qrs",
+}
+
+func normalizeFeatureKey(k string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(k) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// featureNumber accepts numbers, numeric strings and strings with a unit suffix ("42 ms")
+func featureNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		s := strings.TrimSpace(strings.ToLower(n))
+		for _, unit := range []string{"bpm", "ms"} {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit))
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// ParseEKGFeatures extracts the typed features from the ML features map.
+// Keys it doesn't recognize (or can't parse) come back in extras untouched.
+// Non-positive values are treated as "not computed": the analyzer reports 0
+// when it finds too few R peaks.
+func ParseEKGFeatures(raw map[string]any) (models.EKGFeatures, map[string]any) {
+	var features models.EKGFeatures
+	extras := map[string]any{}
+
+	for key, value := range raw {
+		target, known := ekgFeatureAliases[normalizeFeatureKey(key)]
+		f, ok := featureNumber(value)
+		if !known || !ok {
+			extras[key] = value
+			continue
+		}
+		if f <= 0 {
+			continue
+		}
+
+		v := f
+		switch target {
+		case "mean_hr":
+			features.MeanHR = &v
+		case "sdnn":
+			features.SDNN = &v
+		case "rmssd":
+			features.RMSSD = &v
+		case "qrs":
+			features.QRSDuration = &v
+		}
+	}
+	return features, extras
+}
+
+// NewEKGAnalysis builds the stored record for a patient's EKG result
+func NewEKGAnalysis(patientID uint, resp *models.EKGResponse) models.EKGAnalysis {
+	features, extras := ParseEKGFeatures(resp.Features)
+	analysis := models.EKGAnalysis{
+		PatientID:   patientID,
+		Status:      resp.Status,
+		EKGFeatures: features,
+		Extras:      extras,
+	}
+	for _, p := range resp.Predictions {
+		if p.Probability > analysis.TopProbability || analysis.TopCondition == "" {
+			analysis.TopCondition = p.Condition
+			analysis.TopProbability = p.Probability
+		}
+	}
+	return analysis
+}
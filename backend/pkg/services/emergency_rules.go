@@ -0,0 +1,225 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"healthcare-backend/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// DefaultEmergencyRules seeds the rule table on first start. The first three
+// are the triggers that used to be hardcoded.
+var DefaultEmergencyRules = []models.EmergencyRule{
+	{Field: "heart_risk_score", Operator: ">", Threshold: models.EmergencyHeartRisk, Enabled: true, Description: "Heart risk above 85%"},
+	{Field: "systolic_bp", Operator: ">", Threshold: 180, Enabled: true, Description: "Systolic BP above 180 mmHg (hypertensive crisis)"},
+	{Field: "urgency_level", Operator: ">=", Threshold: 4, Enabled: true, Description: "Symptom urgency level 4 or higher"},
+	{Field: "glucose", Operator: ">", Threshold: 350, Enabled: true, Description: "Glucose above 350 mg/dL (severe hyperglycaemia)"},
+	{Field: "heart_rate", Operator: ">", Threshold: 140, Enabled: true, Description: "Heart rate above 140 bpm"},
+	{Field: "stroke_risk_score", Operator: ">", Threshold: 80, Enabled: true, Description: "Stroke risk above 80%"},
+}
+
+// EmergencyRuleFields are the values a rule can test
+var EmergencyRuleFields = []string{
+	"age", "systolic_bp", "diastolic_bp", "glucose", "bmi", "cholesterol", "heart_rate",
+	"heart_risk_score", "diabetes_risk_score", "stroke_risk_score", "kidney_risk_score",
+	"urgency_level",
+}
+
+var emergencyOperators = []string{">", ">=", "<", "<="}
+
+// ErrInvalidEmergencyRule is returned for rules on unknown fields or operators
+var ErrInvalidEmergencyRule = errors.New("invalid emergency rule")
+
+// ErrEmergencyRuleNotFound is returned for an unknown rule ID
+var ErrEmergencyRuleNotFound = errors.New("emergency rule not found")
+
+// EmergencyRuleService owns the configurable emergency triggers
+type EmergencyRuleService struct {
+	DB *gorm.DB
+}
+
+func NewEmergencyRuleService(db *gorm.DB) *EmergencyRuleService {
+	return &EmergencyRuleService{DB: db}
+}
+
+// Seed inserts the defaults when no rules exist, so defaults an admin
+// deleted stay deleted
+func (s *EmergencyRuleService) Seed() error {
+	var n int64
+	if err := s.DB.Model(&models.EmergencyRule{}).Count(&n).Error; err != nil || n > 0 {
+		return err
+	}
+	rules := slices.Clone(DefaultEmergencyRules)
+	return s.DB.Create(&rules).Error
+}
+
+// List returns every rule, enabled or not
+func (s *EmergencyRuleService) List() ([]models.EmergencyRule, error) {
+	rules := []models.EmergencyRule{}
+	err := s.DB.Order("id").Find(&rules).Error
+	return rules, err
+}
+
+func (s *EmergencyRuleService) get(id uint) (*models.EmergencyRule, error) {
+	var rule models.EmergencyRule
+	err := s.DB.First(&rule, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrEmergencyRuleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func validateEmergencyRule(r *models.EmergencyRule) error {
+	r.Field = strings.TrimSpace(r.Field)
+	r.Operator = strings.TrimSpace(r.Operator)
+	r.Description = strings.TrimSpace(r.Description)
+	if !slices.Contains(EmergencyRuleFields, r.Field) {
+		return fmt.Errorf("%w: unknown field %q", ErrInvalidEmergencyRule, r.Field)
+	}
+	if !slices.Contains(emergencyOperators, r.Operator) {
+		return fmt.Errorf("%w: operator must be one of %s", ErrInvalidEmergencyRule, strings.Join(emergencyOperators, " "))
+	}
+	if r.Description == "" {
+		return fmt.Errorf("%w: description is required", ErrInvalidEmergencyRule)
+	}
+	return nil
+}
+
+// Create adds a rule
+func (s *EmergencyRuleService) Create(r models.EmergencyRule) (*models.EmergencyRule, error) {
+	if err := validateEmergencyRule(&r); err != nil {
+		return nil, err
+	}
+	r.ID = 0
+	if err := s.DB.Create(&r).Error; err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Update replaces a rule, returning the previous version
+func (s *EmergencyRuleService) Update(id uint, r models.EmergencyRule) (*models.EmergencyRule, *models.EmergencyRule, error) {
+	if err := validateEmergencyRule(&r); err != nil {
+		return nil, nil, err
+	}
+	existing, err := s.get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	previous := *existing
+	existing.Field = r.Field
+	existing.Operator = r.Operator
+	existing.Threshold = r.Threshold
+	existing.Enabled = r.Enabled
+	existing.Description = r.Description
+	if err := s.DB.Save(existing).Error; err != nil {
+		return nil, nil, err
+	}
+	return existing, &previous, nil
+}
+
+// Delete removes a rule, returning it
+func (s *EmergencyRuleService) Delete(id uint) (*models.EmergencyRule, error) {
+	rule, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.DB.Delete(rule).Error; err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// Evaluate returns the descriptions of the enabled rules the assessment
+// triggers. If the rules can't be loaded the defaults apply, so an outage
+// never hides an emergency.
+func (s *EmergencyRuleService) Evaluate(p models.PatientData, risks *models.PredictResponse, urgency *models.UrgencyResponse) []string {
+	var rules []models.EmergencyRule
+	if err := s.DB.Where("enabled = ?", true).Order("id").Find(&rules).Error; err != nil {
+		slog.Warn("emergency rules unavailable, using defaults", "error", err)
+		rules = DefaultEmergencyRules
+	}
+	return EvaluateEmergencyRules(rules, p, risks, urgency)
+}
+
+// EvaluateEmergencyRules returns the descriptions of the enabled rules that
+// fire. Rules on values that aren't known - pending risks, a degraded
+// urgency, an unmeasured vital - don't fire.
+func EvaluateEmergencyRules(rules []models.EmergencyRule, p models.PatientData, risks *models.PredictResponse, urgency *models.UrgencyResponse) []string {
+	triggered := []string{}
+	for _, r := range rules {
+		if !r.Enabled {
+			continue
+		}
+		v, ok := emergencyValue(r.Field, p, risks, urgency)
+		if !ok {
+			continue
+		}
+		var fired bool
+		switch r.Operator {
+		case ">":
+			fired = v > r.Threshold
+		case ">=":
+			fired = v >= r.Threshold
+		case "<":
+			fired = v < r.Threshold
+		case "<=":
+			fired = v <= r.Threshold
+		}
+		if fired {
+			triggered = append(triggered, r.Description)
+		}
+	}
+	return triggered
+}
+
+func emergencyValue(field string, p models.PatientData, risks *models.PredictResponse, urgency *models.UrgencyResponse) (float64, bool) {
+	switch field {
+	case "heart_risk_score", "diabetes_risk_score", "stroke_risk_score", "kidney_risk_score":
+		if risks == nil {
+			return 0, false
+		}
+		return map[string]float64{
+			"heart_risk_score":    risks.HeartRisk,
+			"diabetes_risk_score": risks.DiabetesRisk,
+			"stroke_risk_score":   risks.StrokeRisk,
+			"kidney_risk_score":   risks.KidneyRisk,
+		}[field], true
+	case "urgency_level":
+		if urgency == nil || urgency.Degraded {
+			return 0, false
+		}
+		return float64(urgency.UrgencyLevel), true
+	}
+
+	if imputed(p, field) {
+		return 0, false
+	}
+	var v float64
+	switch field {
+	case "age":
+		return float64(p.Age), true // Zero is a newborn
+	case "systolic_bp":
+		v = float64(p.SystolicBP)
+	case "diastolic_bp":
+		v = float64(p.DiastolicBP)
+	case "glucose":
+		v = float64(p.Glucose)
+	case "bmi":
+		v = p.BMI
+	case "cholesterol":
+		v = float64(p.Cholesterol)
+	case "heart_rate":
+		v = float64(p.HeartRate)
+	}
+	return v, v != 0 // A zero vital was never taken
+}
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"healthcare-backend/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// ErasureConfirmationTTL is how long an erasure confirmation token is valid
+const ErasureConfirmationTTL = 10 * time.Minute
+
+// RedactedText replaces erased free text in rows that are otherwise kept
+const RedactedText = "[REDACTED]"
+
+var ErrErasureTokenInvalid = errors.New("erasure confirmation token is invalid, used or expired")
+
+// Legal bases for what an erasure keeps (GDPR Art. 17(3) exemptions)
+const (
+	basisAuditTrail = "GDPR Art. 17(3)(b) and (e): EU AI Act Art. 12 record-keeping; entries hold only hashes"
+	basisAIOutputs  = "GDPR Art. 17(3)(b): EU AI Act Art. 12 logging of high-risk AI system outputs"
+	basisOversight  = "GDPR Art. 17(3)(b): EU AI Act Art. 14 human oversight records"
+	basisTombstone  = "No personal data; keeps audit entries and assessments resolvable"
+)
+
+// ErasureService carries out GDPR right-to-erasure requests. Personal data
+// is overwritten or redacted in place rather than deleted, so the audit
+// chain and the AI Act logs that reference the patient by ID stay intact.
+type ErasureService struct {
+	DB         *gorm.DB
+	Prediction *PredictionService // Caches to purge; nil skips them
+	TTL        time.Duration
+}
+
+func NewErasureService(db *gorm.DB, pred *PredictionService) *ErasureService {
+	return &ErasureService{DB: db, Prediction: pred, TTL: ErasureConfirmationTTL}
+}
+
+// RequestConfirmation issues the single-use token Erase needs for this
+// patient. The plaintext token is returned once and never stored.
+func (s *ErasureService) RequestConfirmation(patientID uint) (string, models.ErasureConfirmation, error) {
+	if _, err := s.patient(s.DB, patientID); err != nil {
+		return "", models.ErasureConfirmation{}, err
+	}
+
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return "", models.ErasureConfirmation{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	record := models.ErasureConfirmation{
+		TokenHash: hashIntakeToken(token),
+		PatientID: patientID,
+		ExpiresAt: time.Now().Add(s.TTL),
+	}
+	if err := s.DB.Create(&record).Error; err != nil {
+		return "", models.ErasureConfirmation{}, err
+	}
+	return token, record, nil
+}
+
+// patient loads a patient, soft-deleted ones included: deleting a patient
+// hides them but keeps their data, so they can still be erased
+func (s *ErasureService) patient(db *gorm.DB, id uint) (*models.PatientData, error) {
+	var p models.PatientData
+	if err := db.Unscoped().First(&p, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPatientNotFound
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Erase burns the confirmation token and anonymizes the patient in one
+// transaction, then purges their cached prediction and diagnosis
+func (s *ErasureService) Erase(patientID uint, token string) (*models.ErasureReport, error) {
+	report := &models.ErasureReport{PatientID: patientID, ErasedAt: time.Now().UTC()}
+	var patient *models.PatientData
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if patient, err = s.patient(tx, patientID); err != nil {
+			return err
+		}
+		claim := tx.Model(&models.ErasureConfirmation{}).
+			Where("token_hash = ? AND patient_id = ? AND used_at IS NULL AND expires_at > ?", hashIntakeToken(token), patientID, time.Now()).
+			Update("used_at", time.Now())
+		if claim.Error != nil {
+			return claim.Error
+		}
+		if claim.RowsAffected != 1 {
+			return ErrErasureTokenInvalid
+		}
+
+		return s.anonymize(tx, patientID, report)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Prediction != nil {
+		// The prediction cache is keyed by the vitals as they were before erasure
+		s.Prediction.InvalidatePrediction(*patient)
+		s.Prediction.CancelDiagnosis(patientID)
+		report.Removed = append(report.Removed, models.ErasureItem{
+			Table: "cache", Action: "purged", Data: "Cached risk prediction and diagnosis (memory and Redis)",
+		})
+	}
+	return report, nil
+}
+
+// anonymize overwrites everything that identifies or describes the patient
+// and records each step in the report
+func (s *ErasureService) anonymize(tx *gorm.DB, patientID uint, report *models.ErasureReport) error {
+	removed := func(table, action, data string, res *gorm.DB) error {
+		if res.Error != nil {
+			return fmt.Errorf("erasing %s: %w", table, res.Error)
+		}
+		report.Removed = append(report.Removed, models.ErasureItem{Table: table, Records: res.RowsAffected, Action: action, Data: data})
+		return nil
+	}
+	retained := func(table, data, basis string, q *gorm.DB) error {
+		var n int64
+		if err := q.Count(&n).Error; err != nil {
+			return err
+		}
+		report.Retained = append(report.Retained, models.ErasureItem{Table: table, Records: n, Data: data, LegalBasis: basis})
+		return nil
+	}
+	now := time.Now()
+	ofPatient := tx.Model(&models.Assessment{}).Select("id").Where("patient_id = ?", patientID)
+
+	steps := []func() error{
+		// The row itself stays as a hidden tombstone; these are all the
+		// columns that hold personal data
+		func() error {
+			return removed("patient_data", "overwritten", "Name, demographics, vitals, medications, allergies, history, symptoms and clinic",
+				tx.Unscoped().Model(&models.PatientData{}).Where("id = ?", patientID).Updates(map[string]any{
+					"name": "", "age": 0, "gender": "", "systolic_bp": 0, "diastolic_bp": 0, "glucose": 0, "bmi": 0,
+					"cholesterol": 0, "heart_rate": 0, "steps": 0, "smoking": "", "alcohol": "", "medications": "",
+					"allergies": "", "history_heart_disease": "", "history_stroke": "", "history_diabetes": "",
+					"history_high_chol": "", "symptoms": "", "imputed_fields": "", "clinic": "",
+					"deleted_at": gorm.Expr("COALESCE(deleted_at, ?)", now),
+				}))
+		},
+		func() error {
+			return removed("feedback", "redacted", "Doctor notes",
+				tx.Model(&models.Feedback{}).Where("patient_id = ? AND doctor_notes <> ''", patientID).Update("doctor_notes", RedactedText))
+		},
+		func() error {
+			return removed("feedback_revisions", "redacted", "Earlier versions of doctor notes and why they were amended",
+				tx.Model(&models.FeedbackRevision{}).Where("feedback_id IN (?)", tx.Model(&models.Feedback{}).Select("id").Where("patient_id = ?", patientID)).
+					Updates(map[string]any{"doctor_notes": RedactedText, "reason": ""}))
+		},
+		func() error {
+			return removed("note_embeddings", "deleted", "Vectors of doctor notes",
+				tx.Where("feedback_id IN (?)", tx.Model(&models.Feedback{}).Select("id").Where("patient_id = ?", patientID)).Delete(&models.NoteEmbedding{}))
+		},
+		func() error {
+			return removed("override_logs", "redacted", "Free-text override reasons",
+				tx.Model(&models.OverrideLog{}).Where("patient_id = ? AND reason_text <> ''", patientID).Update("reason_text", RedactedText))
+		},
+		func() error {
+			return removed("assessments", "overwritten", "Patient snapshots, risk explanations and feature contributions",
+				tx.Model(&models.Assessment{}).Where("patient_id = ?", patientID).Updates(map[string]any{
+					"patient_snapshot": "", "explanations": "", "contributions": "",
+				}))
+		},
+		func() error {
+			return removed("assessment_components", "overwritten", "Component results, e.g. medication interactions",
+				tx.Model(&models.AssessmentComponent{}).Where("assessment_id IN (?)", ofPatient).Update("result", gorm.Expr("NULL")))
+		},
+		func() error {
+			return removed("diagnosis_contexts", "overwritten", "Past context, LLM prompts and diagnoses",
+				tx.Model(&models.DiagnosisContext{}).Where("patient_id = ?", patientID).Updates(map[string]any{
+					"past_context": "", "system_prompt": "", "prompt": "", "request": "", "diagnosis": "",
+				}))
+		},
+		func() error {
+			// The redactions above name no rows, so the search hooks don't see them
+			return removed("search_documents", "deleted", "Searchable copies of doctor notes and diagnoses",
+				tx.Where("patient_id = ?", patientID).Delete(&models.SearchDocument{}))
+		},
+		func() error {
+			return removed("diagnosis_failures", "overwritten", "Dead-lettered diagnosis requests",
+				tx.Model(&models.DiagnosisFailure{}).Where("patient_id = ?", patientID).Update("request", ""))
+		},
+		func() error {
+			return removed("outbox_messages", "deleted", "Queued diagnosis tasks",
+				tx.Where("patient_id = ?", patientID).Delete(&models.OutboxMessage{}))
+		},
+		func() error {
+			return removed("notification_logs", "overwritten", "Alert payloads",
+				tx.Model(&models.NotificationLog{}).Where("patient_id = ?", patientID).Update("payload", ""))
+		},
+		func() error {
+			return removed("ekg_analyses", "deleted", "EKG analyses and features",
+				tx.Where("patient_id = ?", patientID).Delete(&models.EKGAnalysis{}))
+		},
+		func() error {
+			return retained("patient_data", "Patient ID, creation time, source and assigned provider", basisTombstone,
+				tx.Unscoped().Model(&models.PatientData{}).Where("id = ?", patientID))
+		},
+		func() error {
+			return retained("audit_logs", "Hash-chained audit entries", basisAuditTrail,
+				tx.Model(&models.AuditLog{}).Where("patient_id_hash = ?", PatientHash(patientID)))
+		},
+		func() error {
+			return retained("assessments", "Risk scores, confidence, emergency flag and snapshot hashes", basisAIOutputs,
+				tx.Model(&models.Assessment{}).Where("patient_id = ?", patientID))
+		},
+		func() error {
+			return retained("shadow_comparisons", "Primary and shadow model scores", basisAIOutputs,
+				tx.Model(&models.ShadowComparison{}).Where("patient_id = ?", patientID))
+		},
+		func() error {
+			return retained("feedback", "Approval decisions and the risk profiles they reviewed", basisOversight,
+				tx.Model(&models.Feedback{}).Where("patient_id = ?", patientID))
+		},
+		func() error {
+			return retained("override_logs", "Overridden predictions and reason codes", basisOversight,
+				tx.Model(&models.OverrideLog{}).Where("patient_id = ?", patientID))
+		},
+		func() error {
+			return retained("notification_logs", "Emergency alert decisions, recipients and delivery times", basisOversight,
+				tx.Model(&models.NotificationLog{}).Where("patient_id = ?", patientID))
+		},
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,323 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"healthcare-backend/pkg/models"
+)
+
+// Explanation sources
+const (
+	ExplanationSHAP  = "shap"
+	ExplanationRules = "rules"
+)
+
+// explainedModels are the risk models in report order, with display names
+var explainedModels = []struct{ Key, Name string }{
+	{"heart", "Heart"},
+	{"diabetes", "Diabetes"},
+	{"stroke", "Stroke"},
+	{"kidney", "Kidney"},
+}
+
+// ExplanationSummarizer turns a prediction's explanations into one short
+// sentence per model. It uses templates and thresholds, not the LLM, so it
+// works offline and always says the same thing for the same input.
+type ExplanationSummarizer struct {
+	MaxDrivers int     // Features named per sentence
+	MinImpact  float64 // SHAP contributions smaller than this aren't worth naming
+}
+
+func NewExplanationSummarizer() *ExplanationSummarizer {
+	return &ExplanationSummarizer{MaxDrivers: 2, MinImpact: 0.05}
+}
+
+// Summarize explains each model's score. ML results are described from
+// their SHAP contributions; rule-based fallbacks from the heuristics that
+// fired. ML models that sent no explanation are left out.
+func (s *ExplanationSummarizer) Summarize(p models.PatientData, risks models.PredictResponse) []models.RiskExplanation {
+	ruleBased := IsRuleBased(&risks)
+	summaries := []models.RiskExplanation{}
+	for _, m := range explainedModels {
+		if ruleBased {
+			summaries = append(summaries, models.RiskExplanation{Model: m.Key, Source: ExplanationRules, Summary: describeRules(m.Key, m.Name, p)})
+			continue
+		}
+		if contribs := risks.Explanations[m.Key]; len(contribs) > 0 {
+			summaries = append(summaries, models.RiskExplanation{Model: m.Key, Source: ExplanationSHAP, Summary: s.describeSHAP(m.Name, contribs, p)})
+		}
+	}
+	return summaries
+}
+
+func (s *ExplanationSummarizer) describeSHAP(name string, contribs map[string]float64, p models.PatientData) string {
+	features := byImpact(contribs)
+	var raising, lowering []string
+	for _, f := range features {
+		v := contribs[f]
+		switch {
+		case v >= s.MinImpact && len(raising) < s.MaxDrivers:
+			raising = append(raising, describeFeature(f, p))
+		case v <= -s.MinImpact && len(lowering) < s.MaxDrivers:
+			lowering = append(lowering, describeFeature(f, p))
+		}
+	}
+
+	switch {
+	case len(raising) > 0 && len(lowering) > 0:
+		return fmt.Sprintf("%s risk driven mainly by %s, partly offset by %s.", name, joinAnd(raising), lowering[0])
+	case len(raising) > 0:
+		return fmt.Sprintf("%s risk driven mainly by %s.", name, joinAnd(raising))
+	case len(lowering) > 0:
+		return fmt.Sprintf("%s risk kept down mainly by %s.", name, joinAnd(lowering))
+	}
+	return name + " risk has no single dominant factor."
+}
+
+// byImpact orders features largest absolute contribution first, ties by
+// name so the order is stable
+func byImpact(contribs map[string]float64) []string {
+	features := make([]string, 0, len(contribs))
+	for f := range contribs {
+		features = append(features, f)
+	}
+	sort.Slice(features, func(i, j int) bool {
+		a, b := math.Abs(contribs[features[i]]), math.Abs(contribs[features[j]])
+		if a != b {
+			return a > b
+		}
+		return features[i] < features[j]
+	})
+	return features
+}
+
+// RankFactors lists what drove each model's score. ML contributions are
+// ranked by absolute size; rule-based fallbacks report the thresholds that
+// fired, and models no rule covers are left out.
+func RankFactors(p models.PatientData, risks models.PredictResponse) []models.ModelFactors {
+	ruleBased := IsRuleBased(&risks)
+	ranked := []models.ModelFactors{}
+	for _, m := range explainedModels {
+		if ruleBased {
+			rule, ok := firedRule(m.Key, p)
+			if !ok {
+				continue
+			}
+			thresholds := []models.RuleThreshold{}
+			for _, c := range rule.Checks {
+				if c.value(p) > c.Above && !imputed(p, c.Field) {
+					thresholds = append(thresholds, models.RuleThreshold{Feature: c.Field, Label: FeatureName(c.Field), Value: c.value(p), Above: c.Above})
+				}
+			}
+			ranked = append(ranked, models.ModelFactors{Model: m.Key, Source: ExplanationRules, Level: rule.Level, Factors: []models.RiskFactor{}, Thresholds: thresholds})
+			continue
+		}
+		contribs := risks.Explanations[m.Key]
+		if len(contribs) == 0 {
+			continue
+		}
+		factors := make([]models.RiskFactor, 0, len(contribs))
+		for _, f := range byImpact(contribs) {
+			factors = append(factors, models.RiskFactor{Feature: f, Label: FeatureName(f), Contribution: contribs[f]})
+		}
+		ranked = append(ranked, models.ModelFactors{Model: m.Key, Source: ExplanationSHAP, Factors: factors})
+	}
+	return ranked
+}
+
+// describeRules says which fallback heuristic set the score and why
+func describeRules(model, name string, p models.PatientData) string {
+	rule, ok := firedRule(model, p)
+	if !ok {
+		return name + " risk not estimated: no clinical rule covers it while the ML service is unavailable."
+	}
+
+	var reasons []string
+	if len(rule.Checks) > 0 {
+		for _, c := range rule.Checks {
+			switch {
+			case c.value(p) <= c.Above || imputed(p, c.Field):
+			case c.flag():
+				reasons = append(reasons, c.Label)
+			default:
+				reasons = append(reasons, fmt.Sprintf("%s %s above %s", c.Label, formatValue(c.value(p)), formatValue(c.Above)))
+			}
+		}
+	} else {
+		// Nothing fired: say what kept the patient under the lowest raised rung
+		rungs := fallbackRules[model]
+		for _, c := range rungs[len(rungs)-2].Checks {
+			switch {
+			case imputed(p, c.Field):
+				reasons = append(reasons, c.Label+" not measured")
+			case c.flag() && c.value(p) <= c.Above:
+				reasons = append(reasons, "no "+c.Label)
+			case c.value(p) <= c.Above:
+				reasons = append(reasons, fmt.Sprintf("%s %s at or below %s", c.Label, formatValue(c.value(p)), formatValue(c.Above)))
+			}
+		}
+	}
+	return fmt.Sprintf("%s risk estimated %s by clinical rules (ML unavailable): %s.", name, rule.Level, joinAnd(reasons))
+}
+
+// featureLabels name the model features the ML service derives from intake
+// fields; see transform_features in src/api/ml_api/main.py
+var featureLabels = map[string]func(p models.PatientData) string{
+	"age":          func(p models.PatientData) string { return fmt.Sprintf("age (%d)", p.Age) },
+	"Age":          func(p models.PatientData) string { return fmt.Sprintf("age (%d)", p.Age) },
+	"sex":          func(p models.PatientData) string { return "sex (" + strings.ToLower(p.Gender) + ")" },
+	"gender":       func(p models.PatientData) string { return "sex (" + strings.ToLower(p.Gender) + ")" },
+	"systolic_bp":  func(p models.PatientData) string { return fmt.Sprintf("systolic BP (%d)", p.SystolicBP) },
+	"diastolic_bp": func(p models.PatientData) string { return fmt.Sprintf("diastolic BP (%d)", p.DiastolicBP) },
+	"hypertension": func(p models.PatientData) string { return fmt.Sprintf("blood pressure (systolic %d)", p.SystolicBP) },
+	"history_bp":   func(p models.PatientData) string { return fmt.Sprintf("blood pressure (systolic %d)", p.SystolicBP) },
+	"glucose":      func(p models.PatientData) string { return fmt.Sprintf("glucose (%d)", p.Glucose) },
+	"fasting_bs":   func(p models.PatientData) string { return fmt.Sprintf("fasting blood sugar (glucose %d)", p.Glucose) },
+	"bmi":          func(p models.PatientData) string { return fmt.Sprintf("BMI (%.1f)", p.BMI) },
+	"cholesterol": func(p models.PatientData) string {
+		return measurement(p, "cholesterol", "cholesterol", p.Cholesterol, "")
+	},
+	"heart_rate": func(p models.PatientData) string {
+		return measurement(p, "heart_rate", "heart rate", p.HeartRate, "")
+	},
+	"PhysActivity": func(p models.PatientData) string {
+		return measurement(p, "steps", "physical activity", p.Steps, " steps")
+	},
+	"Smoker":  smokingLabel,
+	"smoking": smokingLabel,
+	"history_chol": func(p models.PatientData) string {
+		return yesNo(p.HistoryHighChol, "high cholesterol history", "cholesterol history")
+	},
+	"history_heart_disease": heartHistoryLabel,
+	"heart_disease":         heartHistoryLabel,
+	"HeartDiseaseorAttack":  heartHistoryLabel,
+	"history_stroke":        strokeHistoryLabel,
+	"Stroke":                strokeHistoryLabel,
+	"history_diabetes":      func(p models.PatientData) string { return fmt.Sprintf("diabetes indicator (glucose %d)", p.Glucose) },
+}
+
+// modelDefaults are features the ML service fills with a fixed value because
+// intake doesn't collect them
+var modelDefaults = map[string]string{
+	"cp":      "chest pain type",
+	"restecg": "resting ECG",
+	"exang":   "exercise-induced angina",
+	"oldpeak": "ST depression",
+	"slope":   "ST slope",
+	"ca":      "major vessel count",
+	"thal":    "thalassemia",
+}
+
+// featureNames are display labels for the model feature keys, including the
+// ML service's derived and dataset-specific names
+var featureNames = map[string]string{
+	"age":                   "Age",
+	"Age":                   "Age",
+	"sex":                   "Sex",
+	"gender":                "Sex",
+	"systolic_bp":           "Systolic Blood Pressure",
+	"diastolic_bp":          "Diastolic Blood Pressure",
+	"hypertension":          "Hypertension",
+	"history_bp":            "High Blood Pressure History",
+	"glucose":               "Blood Glucose",
+	"fasting_bs":            "Fasting Blood Sugar",
+	"bmi":                   "Body Mass Index",
+	"cholesterol":           "Total Cholesterol",
+	"heart_rate":            "Heart Rate",
+	"steps":                 "Daily Steps",
+	"PhysActivity":          "Physical Activity",
+	"Smoker":                "Smoking",
+	"smoking":               "Smoking",
+	"alcohol":               "Alcohol Use",
+	"history_chol":          "High Cholesterol History",
+	"history_high_chol":     "High Cholesterol History",
+	"history_heart_disease": "Heart Disease History",
+	"heart_disease":         "Heart Disease History",
+	"HeartDiseaseorAttack":  "Heart Disease History",
+	"history_stroke":        "Stroke History",
+	"Stroke":                "Stroke History",
+	"history_diabetes":      "Diabetes History",
+	"cp":                    "Chest Pain Type",
+	"restecg":               "Resting ECG",
+	"exang":                 "Exercise-Induced Angina",
+	"oldpeak":               "ST Depression",
+	"slope":                 "ST Slope",
+	"ca":                    "Major Vessel Count",
+	"thal":                  "Thalassemia",
+}
+
+// FeatureName is the display label for a model feature key. Unknown keys
+// are title-cased, so a new model feature still reads sensibly.
+func FeatureName(feature string) string {
+	if name, ok := featureNames[feature]; ok {
+		return name
+	}
+	words := strings.Fields(strings.ReplaceAll(feature, "_", " "))
+	for i, w := range words {
+		words[i] = strings.ToUpper(w[:1]) + w[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+func describeFeature(feature string, p models.PatientData) string {
+	if label, ok := featureLabels[feature]; ok {
+		return label(p)
+	}
+	if label, ok := modelDefaults[feature]; ok {
+		return label + " (model default)"
+	}
+	return strings.ReplaceAll(feature, "_", " ")
+}
+
+func measurement(p models.PatientData, field, label string, v int, unit string) string {
+	if imputed(p, field) {
+		return label + " (not measured)"
+	}
+	return fmt.Sprintf("%s (%d%s)", label, v, unit)
+}
+
+func smokingLabel(p models.PatientData) string {
+	switch p.Smoking {
+	case "Yes":
+		return "smoking"
+	case "Former":
+		return "former smoking"
+	}
+	return "not smoking"
+}
+
+func heartHistoryLabel(p models.PatientData) string {
+	return yesNo(p.HistoryHeartDisease, "heart disease history", "no heart disease history")
+}
+
+func strokeHistoryLabel(p models.PatientData) string {
+	return yesNo(p.HistoryStroke, "stroke history", "no stroke history")
+}
+
+func yesNo(answer, yes, no string) string {
+	if answer == "Yes" {
+		return yes
+	}
+	return no
+}
+
+func imputed(p models.PatientData, field string) bool {
+	return slices.Contains(p.Imputed(), field)
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// joinAnd lists phrases as "a", "a and b" or "a, b and c"
+func joinAnd(phrases []string) string {
+	if len(phrases) <= 1 {
+		return strings.Join(phrases, "")
+	}
+	return strings.Join(phrases[:len(phrases)-1], ", ") + " and " + phrases[len(phrases)-1]
+}
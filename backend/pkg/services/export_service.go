@@ -0,0 +1,280 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"healthcare-backend/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// Patient export formats
+const (
+	ExportCSV    = "csv"
+	ExportNDJSON = "json"
+)
+
+// patientExportBatch is how many patients an export reads per query
+const patientExportBatch = 500
+
+var ErrExportField = errors.New("unknown export field")
+
+// exportColumn is one column of a patient export. Assessment columns come
+// from the patient's latest assessment and are blank without one.
+type exportColumn struct {
+	name       string
+	assessment bool
+	text       bool // Free text, guarded against spreadsheet formulas
+	value      func(p *models.PatientData, a *models.Assessment) any
+}
+
+// patientExportColumns is the export's column order, which is stable
+var patientExportColumns = []exportColumn{
+	{name: "id", value: func(p *models.PatientData, _ *models.Assessment) any { return p.ID }},
+	{name: "created_at", value: func(p *models.PatientData, _ *models.Assessment) any { return p.CreatedAt.UTC() }},
+	{name: "name", text: true, value: func(p *models.PatientData, _ *models.Assessment) any { return p.Name }},
+	{name: "age", value: func(p *models.PatientData, _ *models.Assessment) any { return p.Age }},
+	{name: "gender", value: func(p *models.PatientData, _ *models.Assessment) any { return p.Gender }},
+	{name: "systolic_bp", value: func(p *models.PatientData, _ *models.Assessment) any { return p.SystolicBP }},
+	{name: "diastolic_bp", value: func(p *models.PatientData, _ *models.Assessment) any { return p.DiastolicBP }},
+	{name: "glucose", value: func(p *models.PatientData, _ *models.Assessment) any { return p.Glucose }},
+	{name: "bmi", value: func(p *models.PatientData, _ *models.Assessment) any { return p.BMI }},
+	{name: "cholesterol", value: func(p *models.PatientData, _ *models.Assessment) any { return p.Cholesterol }},
+	{name: "heart_rate", value: func(p *models.PatientData, _ *models.Assessment) any { return p.HeartRate }},
+	{name: "steps", value: func(p *models.PatientData, _ *models.Assessment) any { return p.Steps }},
+	{name: "smoking", value: func(p *models.PatientData, _ *models.Assessment) any { return p.Smoking }},
+	{name: "alcohol", value: func(p *models.PatientData, _ *models.Assessment) any { return p.Alcohol }},
+	{name: "medications", text: true, value: func(p *models.PatientData, _ *models.Assessment) any { return p.Medications }},
+	{name: "allergies", text: true, value: func(p *models.PatientData, _ *models.Assessment) any { return p.Allergies }},
+	{name: "history_heart_disease", value: func(p *models.PatientData, _ *models.Assessment) any { return p.HistoryHeartDisease }},
+	{name: "history_stroke", value: func(p *models.PatientData, _ *models.Assessment) any { return p.HistoryStroke }},
+	{name: "history_diabetes", value: func(p *models.PatientData, _ *models.Assessment) any { return p.HistoryDiabetes }},
+	{name: "history_high_chol", value: func(p *models.PatientData, _ *models.Assessment) any { return p.HistoryHighChol }},
+	{name: "symptoms", text: true, value: func(p *models.PatientData, _ *models.Assessment) any { return p.Symptoms }},
+	{name: "source", value: func(p *models.PatientData, _ *models.Assessment) any { return p.Source }},
+	{name: "clinic", text: true, value: func(p *models.PatientData, _ *models.Assessment) any { return p.Clinic }},
+	{name: "assigned_provider_id", value: func(p *models.PatientData, _ *models.Assessment) any { return p.AssignedProviderID }},
+
+	{name: "assessment_id", assessment: true, value: func(_ *models.PatientData, a *models.Assessment) any { return a.ID }},
+	{name: "assessed_at", assessment: true, value: func(_ *models.PatientData, a *models.Assessment) any { return a.CreatedAt.UTC() }},
+	{name: "heart_risk_score", assessment: true, value: func(_ *models.PatientData, a *models.Assessment) any { return a.HeartRisk }},
+	{name: "diabetes_risk_score", assessment: true, value: func(_ *models.PatientData, a *models.Assessment) any { return a.DiabetesRisk }},
+	{name: "stroke_risk_score", assessment: true, value: func(_ *models.PatientData, a *models.Assessment) any { return a.StrokeRisk }},
+	{name: "kidney_risk_score", assessment: true, value: func(_ *models.PatientData, a *models.Assessment) any { return a.KidneyRisk }},
+	{name: "general_health_score", assessment: true, value: func(_ *models.PatientData, a *models.Assessment) any { return a.GeneralHealthScore }},
+	{name: "emergency", assessment: true, value: func(_ *models.PatientData, a *models.Assessment) any { return a.Emergency }},
+}
+
+// PatientExportFields lists every field an export can select, in column order
+func PatientExportFields() []string {
+	names := make([]string, len(patientExportColumns))
+	for i, col := range patientExportColumns {
+		names[i] = col.name
+	}
+	return names
+}
+
+// PatientExport selects what an export contains
+type PatientExport struct {
+	Format      string
+	From, To    *time.Time // Bounds on the patient's created_at; nil is open
+	Assessments bool       // Add the latest assessment's risk scores
+	Fields      []string   // Columns to keep, in any order; empty keeps all
+	Scope       PatientScope
+
+	columns []exportColumn
+	maxID   uint
+}
+
+// Filter describes the export for its audit event
+func (e *PatientExport) Filter() map[string]any {
+	filter := map[string]any{"format": e.Format, "assessments": e.Assessments}
+	if e.From != nil {
+		filter["from"] = e.From
+	}
+	if e.To != nil {
+		filter["to"] = e.To
+	}
+	if len(e.Fields) > 0 {
+		filter["fields"] = e.Fields
+	}
+	if !e.Scope.All {
+		filter["provider_id"] = e.Scope.ProviderID
+	}
+	return filter
+}
+
+// ExportService streams patients, with their latest risk scores, as CSV or NDJSON
+type ExportService struct {
+	DB *gorm.DB
+}
+
+func NewExportService(db *gorm.DB) *ExportService {
+	return &ExportService{DB: db}
+}
+
+func (s *ExportService) query(e *PatientExport) *gorm.DB {
+	q := s.DB.Model(&models.PatientData{})
+	if e.From != nil {
+		q = q.Where("created_at >= ?", *e.From)
+	}
+	if e.To != nil {
+		q = q.Where("created_at < ?", *e.To)
+	}
+	if !e.Scope.All {
+		q = q.Where("assigned_provider_id = ?", e.Scope.ProviderID)
+	}
+	return q
+}
+
+// Prepare checks the selected fields and counts the patients the export
+// will write. The export is pinned to the patients that exist now, so the
+// count holds however long the download takes.
+func (s *ExportService) Prepare(e *PatientExport) (int64, error) {
+	selected := map[string]bool{}
+	for _, f := range e.Fields {
+		selected[strings.TrimSpace(f)] = true
+	}
+	e.columns = nil
+	picked := len(selected) > 0
+	for _, col := range patientExportColumns {
+		if picked {
+			if !selected[col.name] {
+				continue
+			}
+			delete(selected, col.name)
+			e.Assessments = e.Assessments || col.assessment
+		} else if col.assessment && !e.Assessments {
+			continue
+		}
+		e.columns = append(e.columns, col)
+	}
+	for f := range selected {
+		return 0, fmt.Errorf("%w %q", ErrExportField, f)
+	}
+
+	var stats struct {
+		Count int64
+		MaxID uint
+	}
+	if err := s.query(e).Select("COUNT(*) AS count, COALESCE(MAX(id), 0) AS max_id").Scan(&stats).Error; err != nil {
+		return 0, err
+	}
+	e.maxID = stats.MaxID
+	return stats.Count, nil
+}
+
+// Write streams a prepared export to w, a batch of patients at a time
+func (s *ExportService) Write(w io.Writer, e *PatientExport) (int, error) {
+	var emit func(p *models.PatientData, a *models.Assessment) error
+	var flush func() error
+	if e.Format == ExportCSV {
+		cw := csv.NewWriter(w)
+		header := make([]string, len(e.columns))
+		for i, col := range e.columns {
+			header[i] = col.name
+		}
+		if err := cw.Write(header); err != nil {
+			return 0, err
+		}
+		record := make([]string, len(e.columns))
+		emit = func(p *models.PatientData, a *models.Assessment) error {
+			for i, col := range e.columns {
+				record[i] = csvCell(col, p, a)
+			}
+			return cw.Write(record)
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	} else {
+		enc := json.NewEncoder(w)
+		emit = func(p *models.PatientData, a *models.Assessment) error {
+			row := make(map[string]any, len(e.columns))
+			for _, col := range e.columns {
+				if col.assessment && a == nil {
+					row[col.name] = nil
+					continue
+				}
+				row[col.name] = col.value(p, a)
+			}
+			return enc.Encode(row)
+		}
+		flush = func() error { return nil }
+	}
+
+	n := 0
+	var batch []models.PatientData
+	err := s.query(e).Where("id <= ?", e.maxID).FindInBatches(&batch, patientExportBatch, func(tx *gorm.DB, _ int) error {
+		latest, err := s.latestAssessments(e, batch)
+		if err != nil {
+			return err
+		}
+		for i := range batch {
+			if err := emit(&batch[i], latest[batch[i].ID]); err != nil {
+				return err
+			}
+			n++
+		}
+		return flush()
+	}).Error
+	if err != nil {
+		return n, err
+	}
+	return n, flush()
+}
+
+// latestAssessments loads the newest assessment of each patient in a batch
+func (s *ExportService) latestAssessments(e *PatientExport, batch []models.PatientData) (map[uint]*models.Assessment, error) {
+	latest := map[uint]*models.Assessment{}
+	if !e.Assessments || len(batch) == 0 {
+		return latest, nil
+	}
+	ids := make([]uint, len(batch))
+	for i, p := range batch {
+		ids[i] = p.ID
+	}
+	newest := s.DB.Model(&models.Assessment{}).Select("MAX(id)").Where("patient_id IN ?", ids).Group("patient_id")
+	var assessments []models.Assessment
+	if err := s.DB.Where("id IN (?)", newest).Find(&assessments).Error; err != nil {
+		return nil, err
+	}
+	for i := range assessments {
+		latest[assessments[i].PatientID] = &assessments[i]
+	}
+	return latest, nil
+}
+
+// csvCell formats one CSV value. Free text that a spreadsheet would run as
+// a formula gets a leading apostrophe; quoting is left to the csv writer.
+func csvCell(col exportColumn, p *models.PatientData, a *models.Assessment) string {
+	if col.assessment && a == nil {
+		return ""
+	}
+	switch v := col.value(p, a).(type) {
+	case string:
+		if col.text && v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+			return "'" + v
+		}
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case *uint:
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatUint(uint64(*v), 10)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/terminology"
+
+	"gorm.io/gorm"
+)
+
+var ErrUnknownICD10 = errors.New("unknown ICD-10 code")
+
+// DefaultICD10Codes seeds the code table on first start: the conditions our
+// risk models and the disease classifier most often point at
+var DefaultICD10Codes = []models.ICD10Code{
+	{Code: "I10", Description: "Essential (primary) hypertension", Keywords: "hypertension, high blood pressure, elevated blood pressure, htn"},
+	{Code: "I11.9", Description: "Hypertensive heart disease without heart failure", Keywords: "hypertensive heart disease"},
+	{Code: "I20.9", Description: "Angina pectoris, unspecified", Keywords: "angina, angina pectoris"},
+	{Code: "I21.9", Description: "Acute myocardial infarction, unspecified", Keywords: "myocardial infarction, heart attack, stemi, nstemi"},
+	{Code: "I25.10", Description: "Atherosclerotic heart disease of native coronary artery", Keywords: "coronary artery disease, coronary atherosclerosis, ischemic heart disease, cad"},
+	{Code: "I48.91", Description: "Unspecified atrial fibrillation", Keywords: "atrial fibrillation, afib"},
+	{Code: "I50.9", Description: "Heart failure, unspecified", Keywords: "heart failure, congestive heart failure, chf"},
+	{Code: "I63.9", Description: "Cerebral infarction, unspecified", Keywords: "stroke, cerebral infarction, cerebrovascular accident, cva"},
+	{Code: "G45.9", Description: "Transient cerebral ischemic attack, unspecified", Keywords: "transient ischemic attack, tia"},
+	{Code: "E11.9", Description: "Type 2 diabetes mellitus without complications", Keywords: "diabetes, type 2 diabetes, diabetes mellitus, t2dm"},
+	{Code: "E11.65", Description: "Type 2 diabetes mellitus with hyperglycemia", Keywords: "hyperglycemia, uncontrolled diabetes"},
+	{Code: "R73.03", Description: "Prediabetes", Keywords: "prediabetes, impaired fasting glucose, insulin resistance"},
+	{Code: "E78.5", Description: "Hyperlipidemia, unspecified", Keywords: "hyperlipidemia, dyslipidemia, high cholesterol, hypercholesterolemia"},
+	{Code: "E66.9", Description: "Obesity, unspecified", Keywords: "obesity, obese"},
+	{Code: "N18.9", Description: "Chronic kidney disease, unspecified", Keywords: "chronic kidney disease, ckd, kidney failure, renal insufficiency"},
+	{Code: "N17.9", Description: "Acute kidney failure, unspecified", Keywords: "acute kidney injury, aki"},
+	{Code: "J44.9", Description: "Chronic obstructive pulmonary disease, unspecified", Keywords: "copd, chronic obstructive pulmonary disease"},
+	{Code: "J45.909", Description: "Unspecified asthma, uncomplicated", Keywords: "asthma"},
+	{Code: "J18.9", Description: "Pneumonia, unspecified organism", Keywords: "pneumonia"},
+	{Code: "I26.99", Description: "Other pulmonary embolism without acute cor pulmonale", Keywords: "pulmonary embolism"},
+	{Code: "I82.409", Description: "Acute embolism and thrombosis of unspecified deep veins of lower extremity", Keywords: "deep vein thrombosis, dvt"},
+	{Code: "D64.9", Description: "Anemia, unspecified", Keywords: "anemia, anaemia"},
+	{Code: "G43.909", Description: "Migraine, unspecified", Keywords: "migraine"},
+	{Code: "F41.9", Description: "Anxiety disorder, unspecified", Keywords: "anxiety"},
+	{Code: "K21.9", Description: "Gastro-esophageal reflux disease without esophagitis", Keywords: "gastroesophageal reflux, gerd, acid reflux"},
+	{Code: "N39.0", Description: "Urinary tract infection, site not specified", Keywords: "urinary tract infection, uti"},
+	{Code: "A41.9", Description: "Sepsis, unspecified organism", Keywords: "sepsis"},
+	{Code: "F17.210", Description: "Nicotine dependence, cigarettes, uncomplicated", Keywords: "smoker, tobacco use, nicotine dependence"},
+}
+
+// ICD-10 coding weights: a keyword in the diagnosis text counts
+// icd10KeywordWeight, a disease prediction its probability. Independent
+// evidence combines as 1 - (1-w1)(1-w2)...
+const (
+	icd10KeywordWeight  = 0.4
+	icd10TopDiseases    = 3 // Disease predictions considered, most probable first
+	MaxICD10Suggestions = 5
+)
+
+// icd10Negations are words that, shortly before a keyword, mean the text
+// rules the condition out ("no signs of stroke")
+var icd10Negations = []string{"no", "not", "without", "denies", "negative", "excluded"}
+
+var icd10Pattern = regexp.MustCompile(`^[A-Z][0-9][0-9A-Z](\.[0-9A-Z]{1,4})?$`)
+
+type icd10Entry struct {
+	code     models.ICD10Code
+	keywords [][]string // Normalized, split into words
+}
+
+// ICD10Coder suggests ICD-10 codes for a diagnosis by keyword matching.
+// Matching ignores case, diacritics and punctuation, as the symptom
+// terminology does, and only matches whole words.
+type ICD10Coder struct {
+	entries []icd10Entry
+}
+
+func NewICD10Coder(codes []models.ICD10Code) *ICD10Coder {
+	c := &ICD10Coder{}
+	for _, code := range codes {
+		e := icd10Entry{code: code}
+		for _, kw := range strings.Split(code.Keywords, ",") {
+			if words := strings.Fields(terminology.Normalize(kw)); len(words) > 0 {
+				e.keywords = append(e.keywords, words)
+			}
+		}
+		c.entries = append(c.entries, e)
+	}
+	return c
+}
+
+// defaultICD10Coder serves PredictionServices built without a database
+var defaultICD10Coder = NewICD10Coder(DefaultICD10Codes)
+
+// Suggest codes the diagnosis text and the most probable disease
+// predictions (probabilities in percent), best supported first. Each
+// suggestion lists the keywords and predictions that matched.
+func (c *ICD10Coder) Suggest(diagnosis string, predictions []models.DiseasePrediction) []models.ICD10Suggestion {
+	text := strings.Fields(terminology.Normalize(diagnosis))
+	top := slices.Clone(predictions)
+	sort.SliceStable(top, func(i, j int) bool { return top[i].Probability > top[j].Probability })
+	if len(top) > icd10TopDiseases {
+		top = top[:icd10TopDiseases]
+	}
+
+	suggestions := []models.ICD10Suggestion{}
+	for _, e := range c.entries {
+		s := models.ICD10Suggestion{Code: e.code.Code, Description: e.code.Description, MatchedKeywords: []string{}}
+		doubt := 1.0 // Product of (1 - weight) over the evidence
+		for _, kw := range e.keywords {
+			if mentions(text, kw, true) {
+				s.MatchedKeywords = append(s.MatchedKeywords, strings.Join(kw, " "))
+				doubt *= 1 - icd10KeywordWeight
+			}
+		}
+		for _, p := range top {
+			disease := strings.Fields(terminology.Normalize(p.Disease))
+			if slices.ContainsFunc(e.keywords, func(kw []string) bool { return mentions(disease, kw, false) }) {
+				s.MatchedDiseases = append(s.MatchedDiseases, p.Disease)
+				doubt *= 1 - math.Min(math.Max(p.Probability/100, 0), 1)
+			}
+		}
+		if doubt == 1 {
+			continue
+		}
+		s.Confidence = math.Round((1-doubt)*100) / 100
+		suggestions = append(suggestions, s)
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		if suggestions[i].Confidence != suggestions[j].Confidence {
+			return suggestions[i].Confidence > suggestions[j].Confidence
+		}
+		return suggestions[i].Code < suggestions[j].Code
+	})
+	if len(suggestions) > MaxICD10Suggestions {
+		suggestions = suggestions[:MaxICD10Suggestions]
+	}
+	return suggestions
+}
+
+// mentions reports whether the phrase occurs in text as whole words; with
+// negation set, occurrences up to three words after a negation don't count
+func mentions(text, phrase []string, negation bool) bool {
+	for i := 0; i+len(phrase) <= len(text); i++ {
+		if !slices.Equal(text[i:i+len(phrase)], phrase) {
+			continue
+		}
+		if negation && slices.ContainsFunc(text[max(0, i-3):i], func(w string) bool { return slices.Contains(icd10Negations, w) }) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// ICD10Service owns the ICD-10 code table
+type ICD10Service struct {
+	DB *gorm.DB
+}
+
+func NewICD10Service(db *gorm.DB) *ICD10Service {
+	return &ICD10Service{DB: db}
+}
+
+// Seed inserts any default code that doesn't exist yet, leaving edited rows alone
+func (s *ICD10Service) Seed() error {
+	for _, c := range DefaultICD10Codes {
+		row := c
+		if err := s.DB.Where("code = ?", row.Code).FirstOrCreate(&row).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Coder loads the current table into an ICD10Coder
+func (s *ICD10Service) Coder() (*ICD10Coder, error) {
+	var codes []models.ICD10Code
+	if err := s.DB.Order("code").Find(&codes).Error; err != nil {
+		return nil, err
+	}
+	return NewICD10Coder(codes), nil
+}
+
+// NormalizeICD10 upper-cases and deduplicates codes, keeping their order,
+// and rejects anything not shaped like an ICD-10 code
+func NormalizeICD10(codes []string) ([]string, error) {
+	var normalized []string
+	for _, c := range codes {
+		c = strings.ToUpper(strings.TrimSpace(c))
+		if !icd10Pattern.MatchString(c) {
+			return nil, fmt.Errorf("%w: %q is not an ICD-10 code", ErrUnknownICD10, c)
+		}
+		if !slices.Contains(normalized, c) {
+			normalized = append(normalized, c)
+		}
+	}
+	return normalized, nil
+}
+
+// Resolve normalizes codes a doctor confirmed and checks each is in the table
+func (s *ICD10Service) Resolve(codes []string) ([]string, error) {
+	normalized, err := NormalizeICD10(codes)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range normalized {
+		var n int64
+		if err := s.DB.Model(&models.ICD10Code{}).Where("code = ?", c).Count(&n).Error; err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return nil, fmt.Errorf("%w: %s is not in the code table", ErrUnknownICD10, c)
+		}
+	}
+	return normalized, nil
+}
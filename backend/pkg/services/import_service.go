@@ -0,0 +1,199 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/middleware"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/repositories"
+)
+
+// DefaultMaxImportErrors caps the row errors an import reports
+const DefaultMaxImportErrors = 100
+
+var (
+	ErrImportHeader    = errors.New("CSV header line is missing or unreadable")
+	ErrImportNoColumns = errors.New("no column in the CSV header maps to a patient field")
+)
+
+// PatientImportColumns maps CSV headers to PatientIntake fields. Headers are
+// matched case-insensitively, with spaces and hyphens read as underscores;
+// every field's own JSON name is accepted too.
+var PatientImportColumns = map[string]string{
+	"patient_name": "name", "full_name": "name",
+	"sex":      "gender",
+	"systolic": "systolic_bp", "sbp": "systolic_bp",
+	"diastolic": "diastolic_bp", "dbp": "diastolic_bp",
+	"blood_glucose":     "glucose",
+	"total_cholesterol": "cholesterol",
+	"hr":                "heart_rate", "pulse": "heart_rate",
+	"daily_steps": "steps",
+	"smoker":      "smoking",
+	"meds":        "medications",
+}
+
+// importFields are the PatientIntake JSON names, each accepted as a header
+var importFields = []string{"name", "age", "gender", "systolic_bp", "diastolic_bp", "glucose", "bmi",
+	"cholesterol", "heart_rate", "steps", "smoking", "alcohol", "medications", "allergies",
+	"history_heart_disease", "history_stroke", "history_diabetes", "history_high_chol", "symptoms"}
+
+// importChoices normalizes the spellings of choice answers EHR exports use
+var importChoices = map[string]string{
+	"m": "Male", "male": "Male", "f": "Female", "female": "Female", "other": "Other",
+	"y": "Yes", "yes": "Yes", "true": "Yes", "1": "Yes",
+	"n": "No", "no": "No", "false": "No", "0": "No",
+	"former": "Former",
+}
+
+// ImportService creates patients from CSV exports of other systems
+type ImportService struct {
+	Patients  repositories.PatientRepository
+	Audit     *AuditService
+	MaxErrors int // Row errors kept in the summary; the rest are only counted
+}
+
+func NewImportService(patients repositories.PatientRepository, audit *AuditService) *ImportService {
+	return &ImportService{Patients: patients, Audit: audit, MaxErrors: DefaultMaxImportErrors}
+}
+
+// importColumn resolves a CSV header to a PatientIntake field, or ""
+func importColumn(header string) string {
+	key := strings.NewReplacer(" ", "_", "-", "_").Replace(strings.ToLower(strings.TrimSpace(header)))
+	if field, ok := PatientImportColumns[key]; ok {
+		return field
+	}
+	for _, field := range importFields {
+		if key == field {
+			return field
+		}
+	}
+	return ""
+}
+
+// Import reads a CSV with a header line one record at a time, validating
+// each row as /api/assess would. Valid rows are created and audited as
+// PATIENT_IMPORTED, and onCreated is called for each; with dryRun nothing
+// is written and Created counts what would have been.
+func (s *ImportService) Import(r io.Reader, actor auditctx.Identity, dryRun bool, onCreated func(models.PatientData)) (*models.ImportSummary, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // Short and long rows are reported per row
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrImportHeader, err)
+	}
+	summary := &models.ImportSummary{DryRun: dryRun, Errors: []models.ImportRowError{}, IgnoredColumns: []string{}}
+	columns := make([]string, len(header))
+	mapped := 0
+	for i, h := range header {
+		// Excel starts UTF-8 CSVs with a byte order mark
+		if columns[i] = importColumn(strings.TrimPrefix(h, "\ufeff")); columns[i] != "" {
+			mapped++
+		} else {
+			summary.IgnoredColumns = append(summary.IgnoredColumns, h)
+		}
+	}
+	if mapped == 0 {
+		return nil, ErrImportNoColumns
+	}
+
+	skip := func(row int, reason string) {
+		summary.Skipped++
+		if len(summary.Errors) < s.MaxErrors {
+			summary.Errors = append(summary.Errors, models.ImportRowError{Row: row, Reason: reason})
+		} else {
+			summary.ErrorsTruncated = true
+		}
+	}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+			skip(parseErr.StartLine, parseErr.Err.Error())
+			continue
+		}
+		row, _ := reader.FieldPos(0)
+
+		patient, reason := importRow(columns, record)
+		if reason != "" {
+			skip(row, reason)
+			continue
+		}
+		if dryRun {
+			summary.Created++
+			continue
+		}
+		if err := s.Patients.Create(&patient); err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		if _, err := s.Audit.LogEvent("PATIENT_IMPORTED", patient.ID, patient, actor); err != nil {
+			slog.Warn("audit event failed", "event", "PATIENT_IMPORTED", "error", err)
+		}
+		summary.Created++
+		if onCreated != nil {
+			onCreated(patient)
+		}
+	}
+	return summary, nil
+}
+
+// importRow turns a CSV record into a new patient, or says why it can't.
+// Cells go through PatientIntake, so numbers are read as typed and empty
+// cells count as omitted.
+func importRow(columns, record []string) (models.PatientData, string) {
+	if len(record) != len(columns) {
+		return models.PatientData{}, fmt.Sprintf("expected %d columns, got %d", len(columns), len(record))
+	}
+	fields := map[string]string{}
+	for i, cell := range record {
+		cell = strings.TrimSpace(cell)
+		if columns[i] == "" || cell == "" {
+			continue
+		}
+		if choice, ok := importChoices[strings.ToLower(cell)]; ok && isImportChoice(columns[i]) {
+			cell = choice
+		}
+		fields[columns[i]] = cell
+	}
+
+	body, _ := json.Marshal(fields)
+	var intake models.PatientIntake
+	if err := json.Unmarshal(body, &intake); err != nil {
+		return models.PatientData{}, err.Error()
+	}
+	if errs := middleware.ValidateStruct(intake); len(errs) > 0 {
+		reasons := make([]string, len(errs))
+		for i, e := range errs {
+			reasons[i] = e.Field + ": " + e.Message
+		}
+		return models.PatientData{}, strings.Join(reasons, "; ")
+	}
+	patient := intake.NewPatient()
+	patient.Source = PatientSourceImport
+	return patient, ""
+}
+
+// isImportChoice reports whether a field takes one of a fixed set of
+// answers; numbers and free text are kept as written
+func isImportChoice(field string) bool {
+	switch field {
+	case "gender", "smoking", "alcohol", "history_heart_disease", "history_stroke", "history_diabetes", "history_high_chol":
+		return true
+	}
+	return false
+}
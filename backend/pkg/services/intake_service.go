@@ -0,0 +1,127 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"healthcare-backend/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// PatientSourceKiosk marks patients created through self-service intake
+const PatientSourceKiosk = "kiosk"
+
+// PatientSourceScreening marks patients created by a batch assessment
+const PatientSourceScreening = "screening"
+
+// PatientSourceImport marks patients created by a CSV import
+const PatientSourceImport = "import"
+
+// PatientSourceFHIR marks patients ingested from a FHIR bundle
+const PatientSourceFHIR = "fhir"
+
+// PatientSourceHL7 marks patients ingested from an HL7 v2 message
+const PatientSourceHL7 = "hl7"
+
+var (
+	ErrClinicRequired     = errors.New("clinic is required")
+	ErrIntakeTokenInvalid = errors.New("intake token is invalid")
+	ErrIntakeTokenUsed    = errors.New("intake token has already been used or has expired")
+)
+
+// IntakeService issues and redeems single-use kiosk intake tokens
+type IntakeService struct {
+	DB  *gorm.DB
+	TTL time.Duration
+}
+
+func NewIntakeService(db *gorm.DB, ttl time.Duration) *IntakeService {
+	return &IntakeService{DB: db, TTL: ttl}
+}
+
+func hashIntakeToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Issue creates a token bound to a clinic. The plaintext token is returned
+// once and never stored.
+func (s *IntakeService) Issue(clinic string) (string, models.IntakeToken, error) {
+	clinic = strings.TrimSpace(clinic)
+	if clinic == "" {
+		return "", models.IntakeToken{}, ErrClinicRequired
+	}
+
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return "", models.IntakeToken{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	record := models.IntakeToken{
+		TokenHash: hashIntakeToken(token),
+		Clinic:    clinic,
+		ExpiresAt: time.Now().Add(s.TTL),
+	}
+	if err := s.DB.Create(&record).Error; err != nil {
+		return "", models.IntakeToken{}, err
+	}
+	return token, record, nil
+}
+
+// Redeem consumes the token and creates the kiosk patient in one transaction.
+// The token is claimed with a conditional update, so two concurrent
+// submissions can't both succeed.
+func (s *IntakeService) Redeem(token string, req models.KioskIntakeRequest) (models.PatientData, models.IntakeToken, error) {
+	var patient models.PatientData
+	var record models.IntakeToken
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("token_hash = ?", hashIntakeToken(token)).First(&record).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrIntakeTokenInvalid
+			}
+			return err
+		}
+
+		now := time.Now()
+		claim := tx.Model(&models.IntakeToken{}).
+			Where("id = ? AND used_at IS NULL AND expires_at > ?", record.ID, now).
+			Update("used_at", now)
+		if claim.Error != nil {
+			return claim.Error
+		}
+		if claim.RowsAffected != 1 {
+			return ErrIntakeTokenUsed
+		}
+
+		patient = models.PatientData{
+			Age:         req.Age,
+			Gender:      req.Gender,
+			Smoking:     req.Smoking,
+			Alcohol:     req.Alcohol,
+			Symptoms:    req.Symptoms,
+			Medications: req.Medications,
+			Allergies:   req.Allergies,
+			Source:      PatientSourceKiosk,
+			Clinic:      record.Clinic,
+
+			// Vitals and history are left for the clinician
+			ImputedFields: strings.Join(models.ImputableFields, ","),
+		}
+		if err := tx.Create(&patient).Error; err != nil {
+			return err
+		}
+
+		record.UsedAt = &now
+		record.PatientID = &patient.ID
+		return tx.Model(&record).Update("patient_id", patient.ID).Error
+	})
+	return patient, record, err
+}
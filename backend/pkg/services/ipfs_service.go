@@ -4,70 +4,186 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
-	"crypto/sha256"
 	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"log"
-	"time"
+	"log/slog"
 )
 
 // IPFSService handles decentralized storage backups
 type IPFSService struct {
-	// In a real app, we would hold the IPFS shell client here
-	// shell *shell.Shell
-	EncryptionKey []byte
+	Store         IPFSStore
+	EncryptionKey []byte // AES-256; backups are only recoverable with the same key
 }
 
-func NewIPFSService() *IPFSService {
-	// Generate a random encryption key for this session
-	key := make([]byte, 32)
-	if _, err := io.ReadFull(rand.Reader, key); err != nil {
-		log.Printf("⚠️ Failed to generate IPFS encryption key: %v", err)
+// NewIPFSService backs up to store, or to a SimulatedIPFS when nil. Without a
+// configured key a random one is used, and backups die with the process.
+func NewIPFSService(store IPFSStore, key []byte) *IPFSService {
+	if store == nil {
+		store = NewSimulatedIPFS()
 	}
-	return &IPFSService{EncryptionKey: key}
+	if key == nil {
+		slog.Warn("no IPFS_BACKUP_KEY set: backups are encrypted with a per-boot key and can't be restored after a restart")
+		key = make([]byte, 32)
+		if _, err := io.ReadFull(rand.Reader, key); err != nil {
+			slog.Error("failed to generate IPFS encryption key", "error", err)
+		}
+	}
+	return &IPFSService{Store: store, EncryptionKey: key}
 }
 
-// BackupChain encrypts the audit chain and uploads it to IPFS (Simulated)
-// Returns the CID (Content Identifier)
-func (s *IPFSService) BackupChain(chainData []byte) (string, error) {
-	// 1. Encrypt Data (AES-GCM)
-	encrypted, err := s.encrypt(chainData)
+// ParseBackupKey decodes a 32-byte AES key given as hex or base64
+func ParseBackupKey(s string) ([]byte, error) {
+	key, err := hex.DecodeString(s)
+	if err != nil {
+		key, err = base64.StdEncoding.DecodeString(s)
+	}
 	if err != nil {
-		return "", err
+		return nil, errors.New("backup key must be hex or base64")
 	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("backup key must be 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
 
-	// 2. Upload to IPFS (Simulated)
-	// In a real app: cid, err := s.shell.Add(bytes.NewReader(encrypted))
-	
-	// Simulate network latency
-	time.Sleep(100 * time.Millisecond)
+// backupChunkSize is how much plaintext each sealed chunk of a backup holds
+const backupChunkSize = 64 << 10
 
-	// Generate a deterministic fake CID based on content hash
-	// Real IPFS CIDs look like "QmX..."
-	hash := sha256.Sum256(encrypted)
-	cid := fmt.Sprintf("Qm%s", base64.RawURLEncoding.EncodeToString(hash[:]))[:46] // Truncate to typical CID length
+// BackupChain encrypts the audit chain as it is read and uploads it to IPFS.
+// Returns the CID (Content Identifier) and the encrypted size.
+func (s *IPFSService) BackupChain(chain io.Reader) (string, int64, error) {
+	// Encrypt Data (chunked AES-GCM) straight into the upload
+	pr, pw := io.Pipe()
+	sizes := make(chan int64, 1)
+	go func() {
+		size, err := s.EncryptStream(pw, chain)
+		sizes <- size
+		pw.CloseWithError(err)
+	}()
 
-	log.Printf("☁️ IPFS Backup: Uploaded %d bytes (encrypted) -> CID: %s", len(encrypted), cid)
-	
-	return cid, nil
+	cid, err := s.Store.Add(pr)
+	pr.CloseWithError(err) // Unblocks the encryption if the upload gave up early
+	size := <-sizes
+	if err != nil {
+		return "", size, err
+	}
+
+	slog.Info("ipfs backup uploaded", "bytes", size, "store", s.Store.Name(), "cid", cid)
+	return cid, size, nil
 }
 
-func (s *IPFSService) encrypt(data []byte) ([]byte, error) {
-	block, err := aes.NewCipher(s.EncryptionKey)
+// RestoreChain fetches a backup by CID and writes the decrypted chain to dst
+func (s *IPFSService) RestoreChain(cid string, dst io.Writer) error {
+	body, err := s.Store.Cat(cid)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer body.Close()
+	return s.DecryptStream(dst, body)
+}
 
-	gcm, err := cipher.NewGCM(block)
+// EncryptStream seals src in chunks so no more than one chunk is held in
+// memory. The output is an 8-byte random nonce prefix followed by frames of
+// a 4-byte length and a GCM-sealed chunk. Each chunk's nonce is the prefix
+// plus its index, and the last chunk is marked final, so reordered or
+// truncated backups fail to decrypt.
+func (s *IPFSService) EncryptStream(dst io.Writer, src io.Reader) (int64, error) {
+	gcm, err := s.gcm()
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+	prefix := make([]byte, 8)
+	if _, err := io.ReadFull(rand.Reader, prefix); err != nil {
+		return 0, err
+	}
+	written, err := dst.Write(prefix)
+	if err != nil {
+		return int64(written), err
+	}
+	total := int64(written)
+
+	buf := make([]byte, backupChunkSize)
+	for index := uint32(0); ; index++ {
+		n, rerr := io.ReadFull(src, buf)
+		final := rerr == io.EOF || rerr == io.ErrUnexpectedEOF
+		if rerr != nil && !final {
+			return total, rerr
+		}
+
+		sealed := gcm.Seal(nil, chunkNonce(prefix, index), buf[:n], chunkAAD(final))
+		frame := binary.BigEndian.AppendUint32(nil, uint32(len(sealed)))
+		written, err := dst.Write(append(frame, sealed...))
+		total += int64(written)
+		if err != nil {
+			return total, err
+		}
+		if final {
+			return total, nil
+		}
+	}
+}
+
+// DecryptStream reverses EncryptStream, failing on any altered, reordered or
+// missing chunk
+func (s *IPFSService) DecryptStream(dst io.Writer, src io.Reader) error {
+	gcm, err := s.gcm()
+	if err != nil {
+		return err
+	}
+
+	prefix := make([]byte, 8)
+	if _, err := io.ReadFull(src, prefix); err != nil {
+		return fmt.Errorf("read backup header: %w", err)
+	}
+
+	var size [4]byte
+	for index := uint32(0); ; index++ {
+		if _, err := io.ReadFull(src, size[:]); err != nil {
+			return fmt.Errorf("backup truncated at chunk %d: %w", index, err)
+		}
+		sealed := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(src, sealed); err != nil {
+			return fmt.Errorf("backup truncated at chunk %d: %w", index, err)
+		}
+
+		nonce := chunkNonce(prefix, index)
+		plain, err := gcm.Open(nil, nonce, sealed, chunkAAD(false))
+		final := false
+		if err != nil {
+			if plain, err = gcm.Open(nil, nonce, sealed, chunkAAD(true)); err != nil {
+				return fmt.Errorf("chunk %d failed authentication", index)
+			}
+			final = true
+		}
+		if _, err := dst.Write(plain); err != nil {
+			return err
+		}
+		if final {
+			return nil
+		}
+	}
+}
+
+func (s *IPFSService) gcm() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(s.EncryptionKey)
+	if err != nil {
 		return nil, err
 	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(prefix []byte, index uint32) []byte {
+	return binary.BigEndian.AppendUint32(append([]byte{}, prefix...), index)
+}
 
-	return gcm.Seal(nonce, nonce, data, nil), nil
+func chunkAAD(final bool) []byte {
+	if final {
+		return []byte{1}
+	}
+	return []byte{0}
 }
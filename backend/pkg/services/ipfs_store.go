@@ -0,0 +1,139 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrCIDNotFound is returned when a store has no content for a CID
+var ErrCIDNotFound = errors.New("cid not found")
+
+// IPFSStore adds and fetches content by CID
+type IPFSStore interface {
+	Add(r io.Reader) (string, error)
+	Cat(cid string) (io.ReadCloser, error)
+	Name() string // Shown as the backup provider
+}
+
+// SimulatedIPFS keeps content in memory under a fake CID, so backup and
+// restore work end to end without an IPFS node. Nothing survives a restart.
+type SimulatedIPFS struct {
+	mu      sync.RWMutex
+	content map[string][]byte
+	Latency time.Duration // Simulated network latency per call
+}
+
+func NewSimulatedIPFS() *SimulatedIPFS {
+	return &SimulatedIPFS{content: map[string][]byte{}, Latency: 100 * time.Millisecond}
+}
+
+func (s *SimulatedIPFS) Add(r io.Reader) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	time.Sleep(s.Latency)
+
+	// Generate a deterministic fake CID based on content hash
+	// Real IPFS CIDs look like "QmX..."
+	hash := sha256.Sum256(data)
+	cid := fmt.Sprintf("Qm%s", base64.RawURLEncoding.EncodeToString(hash[:])) // 45 chars, close to a real CIDv0
+
+	s.mu.Lock()
+	s.content[cid] = data
+	s.mu.Unlock()
+	return cid, nil
+}
+
+func (s *SimulatedIPFS) Cat(cid string) (io.ReadCloser, error) {
+	s.mu.RLock()
+	data, ok := s.content[cid]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrCIDNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *SimulatedIPFS) Name() string { return "IPFS (Simulated)" }
+
+// KuboClient talks to an IPFS node's HTTP RPC API (Kubo, formerly go-ipfs),
+// pinning everything it adds
+type KuboClient struct {
+	APIURL string // e.g. http://ipfs:5001
+	Client *http.Client
+}
+
+func NewKuboClient(apiURL string) *KuboClient {
+	// No overall timeout: backups stream for as long as the chain takes
+	return &KuboClient{APIURL: strings.TrimRight(apiURL, "/"), Client: &http.Client{}}
+}
+
+// Add streams r to /api/v0/add as a multipart upload
+func (k *KuboClient) Add(r io.Reader) (string, error) {
+	pr, pw := io.Pipe()
+	form := multipart.NewWriter(pw)
+	go func() {
+		part, err := form.CreateFormFile("file", "audit-chain.enc")
+		if err == nil {
+			_, err = io.Copy(part, r)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	resp, err := k.Client.Post(k.APIURL+"/api/v0/add?pin=true&cid-version=0", form.FormDataContentType(), pr)
+	pr.CloseWithError(err) // Stops the copy if the node never read the body
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("ipfs add: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var added struct {
+		Hash string
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&added); err != nil {
+		return "", fmt.Errorf("ipfs add: %w", err)
+	}
+	if added.Hash == "" {
+		return "", errors.New("ipfs add: no CID in response")
+	}
+	return added.Hash, nil
+}
+
+// Cat fetches content through /api/v0/cat; the caller closes the body
+func (k *KuboClient) Cat(cid string) (io.ReadCloser, error) {
+	resp, err := k.Client.Post(k.APIURL+"/api/v0/cat?arg="+url.QueryEscape(cid), "", nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		// Kubo answers 500 for unknown or malformed CIDs
+		if strings.Contains(string(body), "not found") || strings.Contains(string(body), "invalid") {
+			return nil, ErrCIDNotFound
+		}
+		return nil, fmt.Errorf("ipfs cat: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return resp.Body, nil
+}
+
+func (k *KuboClient) Name() string { return "IPFS (Decentralized)" }
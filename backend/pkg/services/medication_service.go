@@ -0,0 +1,254 @@
+package services
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"healthcare-backend/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// Interaction severities, mildest first
+const (
+	SeverityMinor    = "minor"
+	SeverityModerate = "moderate"
+	SeverityMajor    = "major"
+)
+
+// DefaultDrugInteractions seeds the interaction table on first start
+var DefaultDrugInteractions = []models.DrugInteraction{
+	{DrugA: "Metformin", DrugB: "Contrast", Condition: true, Severity: SeverityMajor, Description: "risk of lactic acidosis; hold around iodinated contrast"},
+	{DrugA: "NSAIDs", DrugB: "Kidney disease", Condition: true, Severity: SeverityModerate, Description: "can worsen kidney function; check renal status"},
+	{DrugA: "Metformin", DrugB: "Alcohol", Severity: SeverityModerate, Description: "risk of lactic acidosis and hypoglycaemia"},
+	{DrugA: "Warfarin", DrugB: "Aspirin", Severity: SeverityMajor, Description: "additive bleeding risk"},
+	{DrugA: "Warfarin", DrugB: "NSAIDs", Severity: SeverityMajor, Description: "increased bleeding risk"},
+	{DrugA: "Lisinopril", DrugB: "Potassium", Severity: SeverityModerate, Description: "risk of hyperkalaemia"},
+	{DrugA: "Lisinopril", DrugB: "NSAIDs", Severity: SeverityModerate, Description: "reduced blood pressure control and risk of kidney injury"},
+	{DrugA: "Atorvastatin", DrugB: "Grapefruit", Severity: SeverityModerate, Description: "raised statin levels, risk of myopathy"},
+	{DrugA: "Atorvastatin", DrugB: "Amlodipine", Severity: SeverityMinor, Description: "modestly raised atorvastatin exposure"},
+}
+
+// DefaultDrugAliases map brand and alternative names to the generics above
+var DefaultDrugAliases = []models.DrugAlias{
+	{Alias: "glucophage", Generic: "Metformin"},
+	{Alias: "coumadin", Generic: "Warfarin"},
+	{Alias: "jantoven", Generic: "Warfarin"},
+	{Alias: "zestril", Generic: "Lisinopril"},
+	{Alias: "prinivil", Generic: "Lisinopril"},
+	{Alias: "lipitor", Generic: "Atorvastatin"},
+	{Alias: "norvasc", Generic: "Amlodipine"},
+	{Alias: "asa", Generic: "Aspirin"},
+	{Alias: "acetylsalicylic acid", Generic: "Aspirin"},
+	{Alias: "nsaid", Generic: "NSAIDs"},
+	{Alias: "ibuprofen", Generic: "NSAIDs"},
+	{Alias: "advil", Generic: "NSAIDs"},
+	{Alias: "motrin", Generic: "NSAIDs"},
+	{Alias: "naproxen", Generic: "NSAIDs"},
+	{Alias: "aleve", Generic: "NSAIDs"},
+	{Alias: "diclofenac", Generic: "NSAIDs"},
+	{Alias: "potassium chloride", Generic: "Potassium"},
+	{Alias: "potassium supplements", Generic: "Potassium"},
+	{Alias: "grapefruit juice", Generic: "Grapefruit"},
+	{Alias: "ethanol", Generic: "Alcohol"},
+	{Alias: "excessive alcohol", Generic: "Alcohol"},
+}
+
+// InteractionChecker matches a medication list against an interaction table.
+// Matching is case-insensitive and resolves brand names through the aliases.
+type InteractionChecker struct {
+	interactions []models.DrugInteraction
+	aliases      map[string]string // Lowercase alias or generic -> generic
+}
+
+func NewInteractionChecker(interactions []models.DrugInteraction, aliases []models.DrugAlias) *InteractionChecker {
+	c := &InteractionChecker{interactions: interactions, aliases: map[string]string{}}
+	for _, i := range interactions {
+		c.aliases[strings.ToLower(i.DrugA)] = i.DrugA
+		if !i.Condition {
+			c.aliases[strings.ToLower(i.DrugB)] = i.DrugB
+		}
+	}
+	for _, a := range aliases {
+		c.aliases[strings.ToLower(a.Alias)] = a.Generic
+	}
+	return c
+}
+
+// defaultInteractions serves PredictionServices built without a database
+var defaultInteractions = NewInteractionChecker(DefaultDrugInteractions, DefaultDrugAliases)
+
+// generic resolves a medication as entered ("Glucophage 500mg") to the
+// interaction table's name, trying the whole entry and then its first word
+func (c *InteractionChecker) generic(med string) (string, bool) {
+	name := normalizeDrug(med)
+	if g, ok := c.aliases[name]; ok {
+		return g, true
+	}
+	if first, _, found := strings.Cut(name, " "); found {
+		g, ok := c.aliases[first]
+		return g, ok
+	}
+	return "", false
+}
+
+// Check splits a comma-separated medication list into risky and safe
+// entries, listing every interaction that made an entry risky
+func (c *InteractionChecker) Check(medStr string) models.InteractionResult {
+	result := models.InteractionResult{Risky: []string{}, Safe: []string{}, Interactions: []models.Interaction{}}
+
+	var meds []string
+	taking := map[string][]string{} // Generic -> entries as typed
+	for _, m := range strings.Split(medStr, ",") {
+		m = strings.TrimSpace(m)
+		if m == "" {
+			continue
+		}
+		meds = append(meds, m)
+		if g, ok := c.generic(m); ok {
+			taking[g] = append(taking[g], m)
+		}
+	}
+
+	risky := map[string]bool{}
+	for _, i := range c.interactions {
+		if len(taking[i.DrugA]) == 0 || (!i.Condition && len(taking[i.DrugB]) == 0) {
+			continue
+		}
+		result.Interactions = append(result.Interactions, models.Interaction{
+			DrugA:    i.DrugA,
+			DrugB:    i.DrugB,
+			Severity: i.Severity,
+			Reason:   i.Description,
+		})
+		for _, m := range taking[i.DrugA] {
+			risky[m] = true
+		}
+		if !i.Condition {
+			for _, m := range taking[i.DrugB] {
+				risky[m] = true
+			}
+		}
+	}
+
+	for _, m := range meds {
+		if risky[m] {
+			result.Risky = append(result.Risky, m)
+		} else {
+			result.Safe = append(result.Safe, m)
+		}
+	}
+	// Most severe first so the UI can lead with the major warnings
+	sort.SliceStable(result.Interactions, func(a, b int) bool {
+		return severityRank(result.Interactions[a].Severity) > severityRank(result.Interactions[b].Severity)
+	})
+	return result
+}
+
+// allergyClasses lists drugs that cross-react with a recorded allergy, keyed
+// by the lowercase allergy
+var allergyClasses = map[string][]string{
+	"penicillin": {"amoxicillin", "ampicillin", "augmentin", "piperacillin", "flucloxacillin", "dicloxacillin"},
+	"sulfa":      {"sulfamethoxazole", "bactrim", "septra", "sulfasalazine"},
+	"nsaids":     {"aspirin"},
+	"statins":    {"atorvastatin", "simvastatin", "rosuvastatin", "pravastatin"},
+}
+
+// normalizeDrug lowercases a name and collapses its whitespace
+func normalizeDrug(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// CheckAllergies lists the medications that match a recorded allergy: by
+// partial name either way ("amoxicil" vs "Amoxicillin 500mg"), by resolving
+// to the same generic ("Advil" vs "ibuprofen"), or through a cross-reacting
+// class ("Penicillin" vs "Amoxicillin")
+func (c *InteractionChecker) CheckAllergies(medStr, allergyStr string) []models.AllergyConflict {
+	conflicts := []models.AllergyConflict{}
+	for _, med := range strings.Split(medStr, ",") {
+		med = strings.TrimSpace(med)
+		m := normalizeDrug(med)
+		if m == "" {
+			continue
+		}
+		mWord, _, _ := strings.Cut(m, " ")
+		mGeneric, mKnown := c.generic(med)
+
+		for _, allergy := range strings.Split(allergyStr, ",") {
+			allergy = strings.TrimSpace(allergy)
+			a := normalizeDrug(allergy)
+			if len(a) < 4 {
+				continue // Too short to match on safely
+			}
+			aGeneric, aKnown := c.generic(allergy)
+
+			reason := ""
+			switch {
+			case strings.Contains(m, a) || strings.Contains(a, mWord) && len(mWord) >= 4:
+				reason = "matches the recorded allergy"
+			case mKnown && aKnown && mGeneric == aGeneric:
+				reason = "same drug as the recorded allergy (" + mGeneric + ")"
+			case slices.Contains(allergyClasses[strings.TrimSuffix(a, "s")], mWord) ||
+				slices.Contains(allergyClasses[a], mWord) ||
+				mKnown && slices.Contains(allergyClasses[a], strings.ToLower(mGeneric)):
+				reason = "cross-reacts with a " + allergy + " allergy"
+			}
+			if reason != "" {
+				conflicts = append(conflicts, models.AllergyConflict{Medication: med, Allergy: allergy, Reason: reason})
+				break
+			}
+		}
+	}
+	return conflicts
+}
+
+func severityRank(s string) int {
+	switch s {
+	case SeverityMajor:
+		return 2
+	case SeverityModerate:
+		return 1
+	}
+	return 0
+}
+
+// MedicationService owns the interaction table and its aliases
+type MedicationService struct {
+	DB *gorm.DB
+}
+
+func NewMedicationService(db *gorm.DB) *MedicationService {
+	return &MedicationService{DB: db}
+}
+
+// Seed inserts any default interaction or alias that doesn't exist yet,
+// leaving edited rows alone
+func (s *MedicationService) Seed() error {
+	for _, i := range DefaultDrugInteractions {
+		row := i
+		if err := s.DB.Where("drug_a = ? AND drug_b = ?", row.DrugA, row.DrugB).FirstOrCreate(&row).Error; err != nil {
+			return err
+		}
+	}
+	for _, a := range DefaultDrugAliases {
+		row := a
+		if err := s.DB.Where("alias = ?", row.Alias).FirstOrCreate(&row).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Checker loads the current table into an InteractionChecker
+func (s *MedicationService) Checker() (*InteractionChecker, error) {
+	var interactions []models.DrugInteraction
+	if err := s.DB.Order("id").Find(&interactions).Error; err != nil {
+		return nil, err
+	}
+	var aliases []models.DrugAlias
+	if err := s.DB.Find(&aliases).Error; err != nil {
+		return nil, err
+	}
+	return NewInteractionChecker(interactions, aliases), nil
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sony/gobreaker"
+)
+
+// Metrics are the Prometheus series behind the ML and LLM Grafana panels.
+// Their names are part of the dashboards' contract; don't rename them. A
+// nil Metrics records nothing.
+type Metrics struct {
+	cacheLookups     *prometheus.CounterVec
+	predictDuration  *prometheus.HistogramVec
+	diagnoseDuration *prometheus.HistogramVec
+}
+
+// LLM calls take seconds to minutes, far beyond the default buckets
+var diagnoseBuckets = []float64{0.5, 1, 2.5, 5, 10, 20, 30, 60, 120}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		cacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "healthcare_prediction_cache_lookups_total",
+			Help: "Risk prediction cache lookups by result (hit or miss).",
+		}, []string{"result"}),
+		predictDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "healthcare_ml_predict_duration_seconds",
+			Help:    "ML /predict calls by backend and outcome (ok or error).",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"backend", "outcome"}),
+		diagnoseDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "healthcare_llm_diagnose_duration_seconds",
+			Help:    "LLM /diagnose calls by outcome (ok or error), from the workers and the direct fallback.",
+			Buckets: diagnoseBuckets,
+		}, []string{"outcome"}),
+	}
+}
+
+// Register exposes the metrics on a Prometheus registry
+func (m *Metrics) Register(reg prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{m.cacheLookups, m.predictDuration, m.diagnoseDuration} {
+		if err := reg.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func outcome(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// CacheLookup counts a prediction cache hit or miss
+func (m *Metrics) CacheLookup(hit bool) {
+	if m == nil {
+		return
+	}
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.cacheLookups.WithLabelValues(result).Inc()
+}
+
+// ObservePredict records one /predict call to a backend
+func (m *Metrics) ObservePredict(backend string, took time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.predictDuration.WithLabelValues(backend, outcome(err)).Observe(took.Seconds())
+}
+
+// ObserveDiagnose records one /diagnose call
+func (m *Metrics) ObserveDiagnose(took time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.diagnoseDuration.WithLabelValues(outcome(err)).Observe(took.Seconds())
+}
+
+// breakerCollector reports each ML backend's circuit breaker state when
+// scraped, so a canary added at runtime shows up too
+type breakerCollector struct {
+	desc *prometheus.Desc
+	pred *PredictionService
+}
+
+func (c *breakerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect maps gobreaker's states to 0 (closed), 1 (half-open) and 2 (open)
+func (c *breakerCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, b := range []*MLBackend{c.pred.Primary, c.pred.Canary} {
+		if b == nil {
+			continue
+		}
+		var state float64
+		switch b.CB.State() {
+		case gobreaker.StateHalfOpen:
+			state = 1
+		case gobreaker.StateOpen:
+			state = 2
+		}
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, state, b.Name)
+	}
+}
+
+// RegisterMetrics exposes the ML metrics, creating Metrics if unset, and
+// each backend's circuit breaker state on a Prometheus registry
+func (s *PredictionService) RegisterMetrics(reg prometheus.Registerer) error {
+	if s.Metrics == nil {
+		s.Metrics = NewMetrics()
+	}
+	if err := s.Metrics.Register(reg); err != nil {
+		return err
+	}
+	return reg.Register(&breakerCollector{
+		desc: prometheus.NewDesc("healthcare_ml_circuit_breaker_state",
+			"ML circuit breaker state by backend: 0 closed, 1 half-open, 2 open.", []string{"backend"}, nil),
+		pred: s,
+	})
+}
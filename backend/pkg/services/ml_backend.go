@@ -0,0 +1,193 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/chaos"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/resilience"
+	"healthcare-backend/pkg/tracing"
+
+	"github.com/sony/gobreaker"
+)
+
+// Backend names recorded on assessments
+const (
+	BackendPrimary = "primary"
+	BackendCanary  = "canary"
+)
+
+// MLBackend is one deployed version of the ML service with its own breaker and stats
+type MLBackend struct {
+	Name string
+	URL  string
+	CB   *gobreaker.CircuitBreaker
+
+	Contract *ContractMonitor // Set by PredictionService.SetContractMonitor
+	Client   *http.Client     // Set by PredictionService.SetClient
+
+	requests  atomic.Int64
+	failures  atomic.Int64
+	latencyMs atomic.Int64 // Cumulative latency of successful calls
+}
+
+func NewMLBackend(name, url string) *MLBackend {
+	return &MLBackend{
+		Name:   name,
+		URL:    url,
+		CB:     resilience.NewCircuitBreaker("ML-Service-" + name),
+		Client: NewMLClient(DefaultMLTimeout),
+	}
+}
+
+const (
+	// DefaultMLTimeout bounds each prediction, urgency, disease, EKG and
+	// vitals call, so a hung ML service trips the breaker instead of
+	// pinning goroutines
+	DefaultMLTimeout = 15 * time.Second
+	// DefaultLLMTimeout bounds one /diagnose call. It stays under the
+	// worker's ack wait, so JetStream never redelivers a live task.
+	DefaultLLMTimeout = 90 * time.Second
+)
+
+// NewMLClient makes calls to the ML service that give up after timeout, or
+// never when it's zero. Its transport gives each a client span and passes
+// the trace context on.
+func NewMLClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: tracing.Transport(http.DefaultTransport)}
+}
+
+// ErrMLTimeout is an ML call outlasting the client's timeout
+var ErrMLTimeout = errors.New("ml service timed out")
+
+// doML sends req through client. Running out of the client's own time
+// becomes ErrMLTimeout: the breaker excuses deadline errors as the caller
+// giving up, but a hung service must count against it.
+func doML(client *http.Client, req *http.Request) (*http.Response, error) {
+	resp, err := client.Do(req)
+	if err != nil && req.Context().Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%w: %v", ErrMLTimeout, err)
+	}
+	return resp, err
+}
+
+// newMLRequest builds a JSON POST to the ML service that forwards the
+// request ID ctx carries, so its logs line up with ours
+func newMLRequest(ctx context.Context, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if id := auditctx.RequestIDFrom(ctx); id != "" {
+		req.Header.Set(auditctx.RequestIDHeader, id)
+	}
+	return req, nil
+}
+
+// predict calls /predict on this backend through its own circuit breaker
+func (b *MLBackend) predict(ctx context.Context, payload []byte) (*models.PredictResponse, error) {
+	start := time.Now()
+	b.requests.Add(1)
+
+	body, err := b.CB.Execute(func() (interface{}, error) {
+		if err := chaos.Inject(chaos.TargetML, "/predict"); err != nil {
+			return nil, err
+		}
+		req, err := newMLRequest(ctx, b.URL+"/predict", bytes.NewBuffer(payload))
+		if err != nil {
+			return nil, err
+		}
+		resp, err := doML(b.Client, req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("ML API returned status %d", resp.StatusCode)
+		}
+
+		raw, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		b.Contract.Check("/predict", raw)
+
+		var risks models.PredictResponse
+		if err := json.Unmarshal(raw, &risks); err != nil {
+			return nil, err
+		}
+		return &risks, nil
+	})
+	if err != nil {
+		b.failures.Add(1)
+		return nil, err
+	}
+
+	b.latencyMs.Add(time.Since(start).Milliseconds())
+	risks := body.(*models.PredictResponse)
+	risks.Backend = b.Name
+	return risks, nil
+}
+
+// Ping reports whether this backend is reachable: an open breaker answers
+// at once, otherwise /health must. It bypasses the breaker and the stats,
+// so probes neither trip nor skew them.
+func (b *MLBackend) Ping(ctx context.Context) error {
+	if b.CB.State() == gobreaker.StateOpen {
+		return gobreaker.ErrOpenState
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.URL+"/health", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := b.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ML API returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Metrics returns the success and latency counters for rollout comparison
+func (b *MLBackend) Metrics() models.MLBackendMetrics {
+	requests := b.requests.Load()
+	failures := b.failures.Load()
+
+	m := models.MLBackendMetrics{
+		Name:         b.Name,
+		URL:          b.URL,
+		Requests:     requests,
+		Failures:     failures,
+		CircuitState: b.CB.State().String(),
+	}
+	if requests > 0 {
+		m.SuccessRate = float64(requests-failures) / float64(requests)
+	}
+	if successes := requests - failures; successes > 0 {
+		m.AvgLatencyMs = float64(b.latencyMs.Load()) / float64(successes)
+	}
+	return m
+}
+
+// canaryBucket maps a patient deterministically into [0, 100)
+func canaryBucket(patientID uint) int {
+	h := fnv.New32a()
+	h.Write([]byte(strconv.FormatUint(uint64(patientID), 10)))
+	return int(h.Sum32() % 100)
+}
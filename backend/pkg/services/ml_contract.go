@@ -0,0 +1,112 @@
+package services
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MLContract lists the top-level snake_case keys exchanged with one ML endpoint.
+// Fixtures under tests/unit/testdata/ml_contract pin the full payload shapes.
+type MLContract struct {
+	Request  []string // Keys the backend sends
+	Response []string // Keys the backend reads and expects on every response
+	Optional []string // Response keys the ML service may leave out, or that the backend ignores
+}
+
+// MLContracts mirrors the Pydantic models and return values in src/api/ml_api
+var MLContracts = map[string]MLContract{
+	"/predict": {
+		Request: []string{"age", "gender", "systolic_bp", "diastolic_bp", "glucose", "bmi", "cholesterol", "heart_rate", "steps",
+			"smoking", "alcohol", "medications", "history_heart_disease", "history_stroke", "history_diabetes", "history_high_chol", "symptoms"},
+		Response: []string{"heart_risk_score", "diabetes_risk_score", "stroke_risk_score", "general_health_score",
+			"clinical_confidence", "model_precisions", "explanations"},
+		Optional: []string{"kidney_risk_score"}, // Only when the kidney model is loaded
+	},
+	"/urgency/predict": {
+		Request:  []string{"symptoms", "patient_data"},
+		Response: []string{"urgency_level", "urgency_name", "probability", "confidence"},
+		Optional: []string{"golden_hour_minutes"},
+	},
+	"/disease/predict": {
+		Request:  []string{"symptoms", "patient_id"},
+		Response: []string{"predictions"},
+	},
+	"/ekg/analyze": {
+		Request:  []string{"signal", "sampling_rate", "patient_id"},
+		Response: []string{"status", "predictions", "features"},
+		Optional: []string{"timestamp"},
+	},
+	"/diagnose": {
+		Request:  []string{"patient", "risk_scores", "past_context", "generation"},
+		Response: []string{"diagnosis", "status"},
+	},
+}
+
+// CheckResponse compares the top-level keys of an ML response body against
+// the endpoint's contract. Bodies that aren't JSON objects report nothing.
+func (c MLContract) CheckResponse(body []byte) (unknown, missing []string) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, nil
+	}
+	known := map[string]bool{}
+	for _, k := range c.Response {
+		known[k] = true
+		if _, ok := fields[k]; !ok {
+			missing = append(missing, k)
+		}
+	}
+	for _, k := range c.Optional {
+		known[k] = true
+	}
+	for k := range fields {
+		if !known[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown, missing
+}
+
+// ContractMonitor checks ML responses against MLContracts when ML_STRICT_CONTRACT
+// is on. A nil monitor checks nothing.
+type ContractMonitor struct {
+	violations *prometheus.CounterVec
+}
+
+func NewContractMonitor() *ContractMonitor {
+	return &ContractMonitor{
+		violations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ml_contract_violations_total",
+			Help: "ML response keys that were unknown to or missing from the backend contract.",
+		}, []string{"endpoint", "kind"}),
+	}
+}
+
+// Register exposes the violation counter on a Prometheus registry
+func (m *ContractMonitor) Register(reg prometheus.Registerer) error {
+	return reg.Register(m.violations)
+}
+
+// Check logs and counts every contract violation in an ML response
+func (m *ContractMonitor) Check(endpoint string, body []byte) {
+	if m == nil {
+		return
+	}
+	contract, ok := MLContracts[endpoint]
+	if !ok {
+		return
+	}
+	unknown, missing := contract.CheckResponse(body)
+	if len(unknown) > 0 {
+		m.violations.WithLabelValues(endpoint, "unknown").Add(float64(len(unknown)))
+		slog.Warn("ml contract: unknown keys in response", "endpoint", endpoint, "keys", unknown)
+	}
+	if len(missing) > 0 {
+		m.violations.WithLabelValues(endpoint, "missing").Add(float64(len(missing)))
+		slog.Warn("ml contract: keys missing from response", "endpoint", endpoint, "keys", missing)
+	}
+}
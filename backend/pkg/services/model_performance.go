@@ -0,0 +1,162 @@
+package services
+
+import (
+	"slices"
+	"sort"
+	"time"
+
+	"healthcare-backend/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// ModelPerformanceService reports how often doctors accept each risk model
+type ModelPerformanceService struct {
+	DB *gorm.DB
+}
+
+func NewModelPerformanceService(db *gorm.DB) *ModelPerformanceService {
+	return &ModelPerformanceService{DB: db}
+}
+
+// weekOf numbers the week of t since weekEpoch, like weekBucketExpr
+func weekOf(t time.Time) int64 {
+	return int64(t.Sub(weekEpoch) / (7 * 24 * time.Hour))
+}
+
+// acceptance is the share of reviews that didn't override, nil without reviews
+func acceptance(reviews, overrides int64) *float64 {
+	if reviews == 0 {
+		return nil
+	}
+	rate := float64(max(reviews-overrides, 0)) / float64(reviews)
+	return &rate
+}
+
+// Report counts each model's assessments, reviews, approvals and overrides
+// since the given time, with weekly acceptance. Every risk model is listed,
+// plus any other model named in precisions or overrides. Overrides recorded
+// before models_overridden count against their model_name.
+func (s *ModelPerformanceService) Report(since time.Time) (*models.ModelPerformanceReport, error) {
+	type assessmentRow struct {
+		ModelName     string
+		Assessments   int64
+		AvgConfidence *float64
+	}
+	var assessed []assessmentRow
+	err := s.DB.Model(&models.AssessmentPrecision{}).
+		Select("model_name, COUNT(*) AS assessments, "+
+			"AVG(CASE WHEN rule_based THEN NULL ELSE confidence END) AS avg_confidence").
+		Where("created_at >= ?", since).
+		Group("model_name").
+		Scan(&assessed).Error
+	if err != nil {
+		return nil, err
+	}
+
+	type reviewRow struct {
+		Week    int64
+		Reviews int64
+	}
+	var reviewed []reviewRow
+	err = s.DB.Model(&models.Feedback{}).
+		Select(weekBucketExpr(s.DB)+" AS week, COUNT(*) AS reviews").
+		Where("created_at >= ?", since).
+		Group("week").
+		Scan(&reviewed).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var overrides []models.OverrideLog
+	err = s.DB.Select("created_at", "model_name", "models_overridden").
+		Where("created_at >= ?", since).
+		Find(&overrides).Error
+	if err != nil {
+		return nil, err
+	}
+
+	perf := map[string]*models.ModelPerformance{}
+	weeklyOverrides := map[string]map[int64]int64{}
+	model := func(name string) *models.ModelPerformance {
+		if perf[name] == nil {
+			perf[name] = &models.ModelPerformance{ModelName: name}
+			weeklyOverrides[name] = map[int64]int64{}
+		}
+		return perf[name]
+	}
+	for _, name := range RiskModelNames {
+		model(name)
+	}
+	for _, r := range assessed {
+		m := model(r.ModelName)
+		m.Assessments = r.Assessments
+		m.AvgConfidence = r.AvgConfidence
+	}
+	for _, o := range overrides {
+		names := o.ModelsOverridden
+		if len(names) == 0 {
+			names = []string{o.ModelName}
+		}
+		for _, name := range names {
+			model(name).Overrides++
+			weeklyOverrides[name][weekOf(o.CreatedAt)]++
+		}
+	}
+
+	var reviews int64
+	weeks := []int64{}
+	weeklyReviews := map[int64]int64{}
+	for _, r := range reviewed {
+		reviews += r.Reviews
+		weeklyReviews[r.Week] += r.Reviews
+		if !slices.Contains(weeks, r.Week) {
+			weeks = append(weeks, r.Week)
+		}
+	}
+	slices.Sort(weeks)
+
+	report := &models.ModelPerformanceReport{Since: since, Models: []models.ModelPerformance{}}
+	for name, m := range perf {
+		m.Reviews = reviews
+		m.Approvals = max(reviews-m.Overrides, 0)
+		m.AcceptanceRate = acceptance(m.Reviews, m.Overrides)
+		for _, w := range weeks {
+			m.Weekly = append(m.Weekly, models.ModelPerformancePoint{
+				WeekStart:      weekEpoch.AddDate(0, 0, int(w)*7),
+				Reviews:        weeklyReviews[w],
+				Overrides:      weeklyOverrides[name][w],
+				AcceptanceRate: acceptance(weeklyReviews[w], weeklyOverrides[name][w]),
+			})
+		}
+		report.Models = append(report.Models, *m)
+	}
+	sort.Slice(report.Models, func(i, j int) bool {
+		ri, rj := slices.Index(RiskModelNames, report.Models[i].ModelName), slices.Index(RiskModelNames, report.Models[j].ModelName)
+		if (ri < 0) != (rj < 0) {
+			return ri >= 0
+		}
+		if ri != rj {
+			return ri < rj
+		}
+		return report.Models[i].ModelName < report.Models[j].ModelName
+	})
+	return report, nil
+}
+
+// Check records each model's last four weeks, without the weekly series,
+// next to the drift alerts standing now
+func (s *ModelPerformanceService) Check(drift *DriftService, now time.Time) (*models.ModelDriftCheck, error) {
+	report, err := s.Report(now.AddDate(0, 0, -28))
+	if err != nil {
+		return nil, err
+	}
+	alerts, err := drift.CheckDrift(now)
+	if err != nil {
+		return nil, err
+	}
+	for i := range report.Models {
+		report.Models[i].Weekly = nil
+	}
+	return &models.ModelDriftCheck{CheckedAt: now, Since: report.Since, Models: report.Models, Alerts: alerts}, nil
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+
+	"healthcare-backend/pkg/jobs"
+	"healthcare-backend/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const noteEmbeddingBatch = 32 // Notes sent per embedding call when backfilling
+
+// NoteEmbeddingService stores vectors of approved doctor notes, so similar
+// cases can be found by what doctors wrote and not only by vitals.
+// Submitted notes are embedded on their own runner, off the request path.
+type NoteEmbeddingService struct {
+	DB     *gorm.DB
+	Client EmbeddingClient
+	Model  string       // Recorded with each vector; vectors from other models are ignored
+	Runner *jobs.Runner // Nil leaves embedding to Embed and Backfill
+}
+
+func NewNoteEmbeddingService(db *gorm.DB, client EmbeddingClient, model string, runner *jobs.Runner) *NoteEmbeddingService {
+	return &NoteEmbeddingService{DB: db, Client: client, Model: model, Runner: runner}
+}
+
+// embeddable is approved feedback with notes; nothing else is retrieved
+func embeddable(fb models.Feedback) bool {
+	return fb.DoctorApproved && strings.TrimSpace(fb.DoctorNotes) != ""
+}
+
+// Index schedules embedding the feedback's notes. Failures are logged;
+// Backfill retries them.
+func (s *NoteEmbeddingService) Index(fb models.Feedback) {
+	if s.Runner == nil || !embeddable(fb) {
+		return
+	}
+	s.Runner.Submit(func(ctx context.Context) {
+		if err := s.Embed(ctx, fb); err != nil {
+			slog.Warn("note embedding failed", "feedback_id", fb.ID, "error", err)
+		}
+	})
+}
+
+// Embed vectorizes the feedback's notes now and stores the vector
+func (s *NoteEmbeddingService) Embed(ctx context.Context, fb models.Feedback) error {
+	if !embeddable(fb) {
+		return nil
+	}
+	vectors, err := s.embed(ctx, []string{fb.DoctorNotes})
+	if err != nil {
+		return err
+	}
+	return s.save(fb.ID, vectors[0])
+}
+
+// embed calls the client, checking it answered every text
+func (s *NoteEmbeddingService) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors, err := s.Client.Embed(ctx, texts)
+	if err == nil && len(vectors) != len(texts) {
+		err = fmt.Errorf("embedding service returned %d vectors for %d texts", len(vectors), len(texts))
+	}
+	return vectors, err
+}
+
+func (s *NoteEmbeddingService) save(feedbackID uint, vector []float32) error {
+	row := models.NoteEmbedding{FeedbackID: feedbackID, Model: s.Model, Vector: encodeVector(vector)}
+	return s.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "feedback_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"created_at", "model", "vector"}),
+	}).Create(&row).Error
+}
+
+// Backfill embeds the approved notes that have no vector from the current
+// model, such as those saved before embeddings were configured, and
+// returns how many it stored
+func (s *NoteEmbeddingService) Backfill(ctx context.Context) (int, error) {
+	done := 0
+	for {
+		var batch []models.Feedback
+		current := s.DB.Model(&models.NoteEmbedding{}).Select("feedback_id").Where("model = ?", s.Model)
+		err := s.DB.Where("doctor_approved = ? AND doctor_notes <> '' AND id NOT IN (?)", true, current).
+			Order("id").Limit(noteEmbeddingBatch).Find(&batch).Error
+		if err != nil || len(batch) == 0 {
+			return done, err
+		}
+		texts := make([]string, len(batch))
+		for i, fb := range batch {
+			texts[i] = fb.DoctorNotes
+		}
+		vectors, err := s.embed(ctx, texts)
+		if err != nil {
+			return done, err
+		}
+		for i, fb := range batch {
+			if err := s.save(fb.ID, vectors[i]); err != nil {
+				return done, err
+			}
+			done++
+		}
+	}
+}
+
+// EmbedQuery vectorizes free text to compare with the stored notes
+func (s *NoteEmbeddingService) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	vectors, err := s.embed(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// Vectors returns the current model's vectors for the feedback that has one
+func (s *NoteEmbeddingService) Vectors(feedbackIDs []uint) (map[uint][]float32, error) {
+	var rows []models.NoteEmbedding
+	err := s.DB.Where("model = ? AND feedback_id IN ?", s.Model, feedbackIDs).Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	vectors := make(map[uint][]float32, len(rows))
+	for _, r := range rows {
+		v, err := decodeVector(r.Vector)
+		if err != nil {
+			return nil, fmt.Errorf("note embedding %d: %w", r.ID, err)
+		}
+		vectors[r.FeedbackID] = v
+	}
+	return vectors, nil
+}
+
+func encodeVector(v []float32) []byte {
+	b := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(f))
+	}
+	return b
+}
+
+func decodeVector(b []byte) ([]float32, error) {
+	if len(b)%4 != 0 {
+		return nil, fmt.Errorf("vector of %d bytes isn't float32s", len(b))
+	}
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return v, nil
+}
+
+// cosine is the cosine similarity of a and b; ok is false when they can't
+// be compared, being of different lengths or all zeros
+func cosine(a, b []float32) (sim float64, ok bool) {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0, false
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0, false
+	}
+	return dot / math.Sqrt(na*nb), true
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"healthcare-backend/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// Notification kinds and timing decisions recorded on models.NotificationLog
+const (
+	NotificationEmergency = "emergency_alert"
+
+	NotificationSent      = "sent"
+	NotificationDeferred  = "deferred"  // Held until the clinic's next working window
+	NotificationEscalated = "escalated" // Critical after hours: routed to the on-call chain
+)
+
+// Broadcaster delivers a notification to connected clients (the WebSocket hub)
+type Broadcaster interface {
+	BroadcastAll(msg any)
+}
+
+// Notification is a clinical alert or reminder about a patient
+type Notification struct {
+	Kind     string
+	Critical bool
+	Patient  models.PatientData
+	Payload  map[string]any // Sent as-is, plus "recipients"
+}
+
+// NotificationService times notifications against the patient's clinic
+// working hours and records every decision
+type NotificationService struct {
+	DB            *gorm.DB
+	Clinics       *ClinicService
+	Providers     *ProviderService
+	Sender        Broadcaster
+	DefaultClinic string           // For patients without a clinic
+	Now           func() time.Time // Injectable clock
+}
+
+func NewNotificationService(db *gorm.DB, providers *ProviderService, sender Broadcaster, defaultClinic string) *NotificationService {
+	return &NotificationService{
+		DB:            db,
+		Clinics:       NewClinicService(db),
+		Providers:     providers,
+		Sender:        sender,
+		DefaultClinic: defaultClinic,
+		Now:           time.Now,
+	}
+}
+
+// hours loads the working hours for a patient's clinic. Nil means no hours
+// are configured and the clinic counts as always open.
+func (s *NotificationService) hours(clinicID string) (*ClinicHours, error) {
+	if clinicID == "" {
+		return nil, nil
+	}
+	clinic, err := s.Clinics.Get(clinicID)
+	if errors.Is(err, ErrClinicNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewClinicHours(*clinic)
+}
+
+// Notify sends n now during working hours. After hours a critical
+// notification goes straight to the on-call chain and anything else is
+// deferred to the next working window for DispatchDue to send.
+func (s *NotificationService) Notify(n Notification) (*models.NotificationLog, error) {
+	now := s.Now().UTC() // SQLite compares times as text, so store one zone
+	clinicID := n.Patient.Clinic
+	if clinicID == "" {
+		clinicID = s.DefaultClinic
+	}
+	payload, err := json.Marshal(n.Payload)
+	if err != nil {
+		return nil, err
+	}
+	entry := &models.NotificationLog{
+		Kind:      n.Kind,
+		Critical:  n.Critical,
+		PatientID: n.Patient.ID,
+		Clinic:    clinicID,
+		Payload:   string(payload),
+		DeliverAt: now,
+	}
+
+	hours, err := s.hours(clinicID)
+	if err != nil {
+		// Never hold an alert back because the clinic settings can't be read
+		slog.Warn("could not load clinic working hours", "clinic", clinicID, "error", err)
+	}
+
+	var recipients []models.Provider
+	switch {
+	case hours == nil:
+		entry.Decision, entry.Reason = NotificationSent, "no working hours configured"
+		if err != nil {
+			entry.Reason = "working hours unavailable: " + err.Error()
+		}
+		recipients, err = s.Providers.EscalationTargets(n.Patient)
+	case hours.Open(now):
+		entry.Decision, entry.Reason = NotificationSent, "within working hours"
+		recipients, err = s.Providers.EscalationTargets(n.Patient)
+	case n.Critical:
+		entry.Decision, entry.Reason = NotificationEscalated, "after hours: critical, routed to on-call providers"
+		recipients, err = s.Providers.List(true)
+		if err == nil && len(recipients) == 0 {
+			entry.Reason = "after hours: critical, nobody on call, sent to all escalation targets"
+			recipients, err = s.Providers.EscalationTargets(n.Patient)
+		}
+	default:
+		next, ok := hours.NextOpen(now)
+		if !ok {
+			entry.Decision, entry.Reason = NotificationSent, "after hours: no upcoming working window, sent now"
+			recipients, err = s.Providers.EscalationTargets(n.Patient)
+			break
+		}
+		entry.Decision = NotificationDeferred
+		entry.Reason = fmt.Sprintf("after hours: deferred to next working window %s", next.In(hours.Location()).Format("Mon 2006-01-02 15:04 MST"))
+		entry.DeliverAt = next.UTC()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.Decision != NotificationDeferred {
+		s.send(entry, n.Payload, recipients, now)
+	}
+	if err := s.DB.Create(entry).Error; err != nil {
+		return entry, err
+	}
+	return entry, nil
+}
+
+// DispatchDue sends deferred notifications whose working window has opened.
+// Recipients are resolved at delivery time, so they reflect the current rota.
+func (s *NotificationService) DispatchDue() (int, error) {
+	now := s.Now().UTC()
+	var due []models.NotificationLog
+	if err := s.DB.Where("decision = ? AND sent_at IS NULL AND deliver_at <= ?", NotificationDeferred, now).
+		Order("deliver_at, id").Find(&due).Error; err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for i := range due {
+		entry := &due[i]
+		var patient models.PatientData
+		if err := s.DB.First(&patient, entry.PatientID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				// Patient deleted meanwhile: close the entry without sending
+				s.DB.Model(entry).Updates(map[string]any{"sent_at": now, "reason": entry.Reason + "; dropped, patient deleted"})
+				continue
+			}
+			return sent, err
+		}
+		recipients, err := s.Providers.EscalationTargets(patient)
+		if err != nil {
+			return sent, err
+		}
+		var payload map[string]any
+		json.Unmarshal([]byte(entry.Payload), &payload)
+
+		s.send(entry, payload, recipients, now)
+		if err := s.DB.Model(entry).Select("sent_at", "recipients").Updates(entry).Error; err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// send broadcasts the payload with its recipients and stamps the log entry
+func (s *NotificationService) send(entry *models.NotificationLog, payload map[string]any, recipients []models.Provider, now time.Time) {
+	msg := map[string]any{}
+	for k, v := range payload {
+		msg[k] = v
+	}
+	msg["recipients"] = recipients
+
+	entry.Recipients = make([]uint, 0, len(recipients))
+	for _, p := range recipients {
+		entry.Recipients = append(entry.Recipients, p.ID)
+	}
+	entry.SentAt = &now
+	s.Sender.BroadcastAll(msg)
+}
+
+// Log returns recorded notifications, newest first, optionally for one
+// patient or of one kind
+func (s *NotificationService) Log(patientID uint, kind string, limit int) ([]models.NotificationLog, error) {
+	var entries []models.NotificationLog
+	q := s.DB.Order("created_at DESC, id DESC").Limit(limit)
+	if patientID != 0 {
+		q = q.Where("patient_id = ?", patientID)
+	}
+	if kind != "" {
+		q = q.Where("kind = ?", kind)
+	}
+	err := q.Find(&entries).Error
+	return entries, err
+}
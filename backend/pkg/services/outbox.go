@@ -0,0 +1,97 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"healthcare-backend/pkg/models"
+
+	"github.com/nats-io/nats.go"
+	"gorm.io/gorm"
+)
+
+// Outbox sends NATS messages saved in the same transaction as the rows they
+// announce: a message goes out only if those rows committed, and still goes
+// out when the process dies between the commit and the send. Delivery is at
+// least once; each message carries its row ID as Nats-Msg-Id, so JetStream
+// drops a resend within its duplicate window.
+type Outbox struct {
+	DB        *gorm.DB
+	Deliver   func(*nats.Msg) error // Sends one message, e.g. queue.PublishDurableMsg
+	BatchSize int
+
+	kick chan struct{}
+}
+
+func NewOutbox(db *gorm.DB, deliver func(*nats.Msg) error) *Outbox {
+	return &Outbox{DB: db, Deliver: deliver, BatchSize: 100, kick: make(chan struct{}, 1)}
+}
+
+// Add saves msg about patientID (0 for none) with tx. It's sent by the next
+// Dispatch after tx commits.
+func (o *Outbox) Add(tx *gorm.DB, patientID uint, msg *nats.Msg) error {
+	header, err := json.Marshal(msg.Header)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.OutboxMessage{PatientID: patientID, Subject: msg.Subject, Data: string(msg.Data), Header: string(header)}).Error
+}
+
+// Notify asks the dispatcher to run now, e.g. right after a commit
+func (o *Outbox) Notify() {
+	select {
+	case o.kick <- struct{}{}:
+	default: // A run is already due
+	}
+}
+
+// Dispatch sends the unsent messages oldest first, marking each one sent
+// and clearing its data, which can hold patient details. It stops at the
+// first failure, keeping the rest in order for the next run.
+func (o *Outbox) Dispatch() (int, error) {
+	var pending []models.OutboxMessage
+	if err := o.DB.Where("sent_at IS NULL").Order("id").Limit(o.BatchSize).Find(&pending).Error; err != nil {
+		return 0, err
+	}
+
+	for i, m := range pending {
+		msg := &nats.Msg{Subject: m.Subject, Data: []byte(m.Data), Header: nats.Header{}}
+		if m.Header != "" {
+			if err := json.Unmarshal([]byte(m.Header), &msg.Header); err != nil {
+				return i, fmt.Errorf("outbox message %d: %w", m.ID, err)
+			}
+		}
+		msg.Header.Set(nats.MsgIdHdr, fmt.Sprintf("outbox-%d", m.ID))
+
+		if err := o.Deliver(msg); err != nil {
+			o.DB.Model(&m).Updates(map[string]any{"attempts": m.Attempts + 1, "last_error": err.Error()})
+			return i, fmt.Errorf("outbox message %d: %w", m.ID, err)
+		}
+		now := time.Now().UTC()
+		if err := o.DB.Model(&m).Updates(map[string]any{"attempts": m.Attempts + 1, "sent_at": now, "data": ""}).Error; err != nil {
+			return i + 1, err // Sent, but may be resent: the ID deduplicates it
+		}
+	}
+	return len(pending), nil
+}
+
+// Start runs Dispatch on every Notify and every interval
+func (o *Outbox) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-o.kick:
+			}
+			if n, err := o.Dispatch(); err != nil {
+				slog.Warn("outbox dispatch stopped", "sent", n, "error", err)
+			} else if n > 0 {
+				slog.Debug("outbox dispatched", "sent", n)
+			}
+		}
+	}()
+}
@@ -0,0 +1,319 @@
+package services
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"healthcare-backend/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// Reason codes with special handling
+const (
+	OverrideReasonOther       = "other"
+	OverrideReasonOtherLegacy = "other_legacy"
+	OverrideModelUnspecified  = "unspecified"
+)
+
+// RiskModelNames are the risk models a doctor can override, as named in
+// assessment precisions
+var RiskModelNames = []string{"Heart_Model", "Diabetes_Model", "Stroke_Model", "Kidney_Model"}
+
+// DefaultOverrideReasons seeds the taxonomy on first start
+var DefaultOverrideReasons = []models.OverrideReason{
+	{Code: "clinical_intuition", Label: "Clinical Intuition", Active: true},
+	{Code: "data_quality_issue", Label: "Data Quality Issue", Active: true},
+	{Code: "patient_history_discrepancy", Label: "Patient History Discrepancy", Active: true},
+	{Code: "model_known_limitation", Label: "Model Known Limitation", Active: true},
+	{Code: OverrideReasonOther, Label: "Other", RequiresText: true, Active: true},
+	{Code: OverrideReasonOtherLegacy, Label: "Other (legacy)", Active: false},
+}
+
+// Groupings for override analytics
+const (
+	OverrideGroupReason = "reason"
+	OverrideGroupModel  = "model"
+	OverrideGroupDoctor = "doctor"
+)
+
+// Recent overrides returned with the analytics
+const (
+	DefaultOverrideRecent = 10
+	MaxOverrideRecent     = 100
+)
+
+// overrideDoctorUnknown groups overrides recorded before the doctor was kept
+const overrideDoctorUnknown = "unknown"
+
+// ErrInvalidOverride is returned when an override doesn't match the taxonomy
+var ErrInvalidOverride = errors.New("invalid override")
+
+// OverrideService owns the override-reason taxonomy and override reporting
+type OverrideService struct {
+	DB *gorm.DB
+}
+
+func NewOverrideService(db *gorm.DB) *OverrideService {
+	return &OverrideService{DB: db}
+}
+
+// Seed inserts any default reason that doesn't exist yet, leaving admin edits alone
+func (s *OverrideService) Seed() error {
+	for _, r := range DefaultOverrideReasons {
+		reason := r
+		if err := s.DB.Where("code = ?", reason.Code).FirstOrCreate(&reason).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MigrateLegacy gives every rejected feedback without an override record an
+// "Other (legacy)" override, keeping the free-text notes as the reason text.
+func (s *OverrideService) MigrateLegacy() (int, error) {
+	var legacy []models.Feedback
+	err := s.DB.Where("doctor_approved = ? AND id NOT IN (?)", false,
+		s.DB.Model(&models.OverrideLog{}).Select("feedback_id")).
+		Find(&legacy).Error
+	if err != nil {
+		return 0, err
+	}
+
+	for _, fb := range legacy {
+		override := models.OverrideLog{
+			CreatedAt:     fb.CreatedAt,
+			FeedbackID:    fb.ID,
+			PatientID:     fb.PatientID,
+			ReasonCode:    OverrideReasonOtherLegacy,
+			Reason:        "Other (legacy)",
+			ReasonText:    fb.DoctorNotes,
+			ModelName:     OverrideModelUnspecified,
+			OversightType: "Human-in-the-Loop",
+		}
+		if err := s.DB.Create(&override).Error; err != nil {
+			return 0, err
+		}
+	}
+	return len(legacy), nil
+}
+
+// Reasons lists the taxonomy; inactive entries only when asked
+func (s *OverrideService) Reasons(includeInactive bool) ([]models.OverrideReason, error) {
+	var reasons []models.OverrideReason
+	q := s.DB.Order("id")
+	if !includeInactive {
+		q = q.Where("active = ?", true)
+	}
+	err := q.Find(&reasons).Error
+	return reasons, err
+}
+
+// Resolve validates an override against the active taxonomy. Clients may send
+// either reason_code or the reason label; both are filled in on success.
+func (s *OverrideService) Resolve(o *models.OverrideLog) error {
+	var reason models.OverrideReason
+	q := s.DB.Where("active = ?", true)
+	if code := strings.TrimSpace(o.ReasonCode); code != "" {
+		q = q.Where("code = ?", code)
+	} else {
+		q = q.Where("LOWER(label) = ?", strings.ToLower(strings.TrimSpace(o.Reason)))
+	}
+	if err := q.First(&reason).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: unknown reason %q", ErrInvalidOverride, o.ReasonCode+o.Reason)
+	} else if err != nil {
+		return err
+	}
+
+	o.ReasonText = strings.TrimSpace(o.ReasonText)
+	if reason.RequiresText && o.ReasonText == "" {
+		return fmt.Errorf("%w: reason %q requires reason_text", ErrInvalidOverride, reason.Label)
+	}
+
+	o.ReasonCode = reason.Code
+	o.Reason = reason.Label
+
+	overridden, err := resolveRiskModels(o.ModelsOverridden)
+	if err != nil {
+		return err
+	}
+	o.ModelName = strings.TrimSpace(o.ModelName)
+	if len(overridden) == 0 && slices.Contains(RiskModelNames, o.ModelName) {
+		overridden = []string{o.ModelName}
+	}
+	o.ModelsOverridden = overridden
+	if o.ModelName == "" && len(overridden) > 0 {
+		o.ModelName = overridden[0]
+	}
+	if o.ModelName == "" {
+		o.ModelName = OverrideModelUnspecified
+	}
+	return nil
+}
+
+// resolveRiskModels canonicalizes overridden model names ("heart" and
+// "heart_model" both mean Heart_Model), dropping duplicates
+func resolveRiskModels(names []string) ([]string, error) {
+	resolved := []string{}
+	for _, name := range names {
+		key := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), "_model")
+		i := slices.IndexFunc(RiskModelNames, func(m string) bool { return strings.EqualFold(m, key+"_model") })
+		if i < 0 {
+			return nil, fmt.Errorf("%w: unknown model %q, expected one of %s", ErrInvalidOverride, name, strings.Join(RiskModelNames, ", "))
+		}
+		if !slices.Contains(resolved, RiskModelNames[i]) {
+			resolved = append(resolved, RiskModelNames[i])
+		}
+	}
+	return resolved, nil
+}
+
+// SaveReason creates or updates a reason by code, returning the previous version if any
+func (s *OverrideService) SaveReason(r models.OverrideReason) (*models.OverrideReason, *models.OverrideReason, error) {
+	r.Code = strings.TrimSpace(r.Code)
+	r.Label = strings.TrimSpace(r.Label)
+	if r.Code == "" || r.Label == "" {
+		return nil, nil, fmt.Errorf("%w: code and label are required", ErrInvalidOverride)
+	}
+	if r.Code == OverrideReasonOtherLegacy {
+		return nil, nil, fmt.Errorf("%w: %s is reserved for migrated rows", ErrInvalidOverride, r.Code)
+	}
+
+	var existing models.OverrideReason
+	err := s.DB.Where("code = ?", r.Code).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if err := s.DB.Create(&r).Error; err != nil {
+			return nil, nil, err
+		}
+		return &r, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	previous := existing
+	existing.Label = r.Label
+	existing.RequiresText = r.RequiresText
+	existing.Active = r.Active
+	if err := s.DB.Save(&existing).Error; err != nil {
+		return nil, nil, err
+	}
+	return &existing, &previous, nil
+}
+
+// monthBucketExpr returns a SQL expression formatting created_at as YYYY-MM
+func monthBucketExpr(db *gorm.DB) string {
+	if db.Dialector.Name() == "postgres" {
+		return "TO_CHAR(created_at, 'YYYY-MM')"
+	}
+	return "strftime('%Y-%m', created_at)"
+}
+
+// Report counts overrides per reason, per model and per month since the given time
+func (s *OverrideService) Report(since time.Time) (*models.OverrideReport, error) {
+	report := &models.OverrideReport{Since: since}
+
+	count := func(expr, order string) ([]models.OverrideCount, error) {
+		counts := []models.OverrideCount{}
+		err := s.DB.Model(&models.OverrideLog{}).
+			Select(expr+" AS key, COUNT(*) AS count").
+			Where("created_at >= ?", since).
+			Group("key").
+			Order(order).
+			Scan(&counts).Error
+		return counts, err
+	}
+
+	var err error
+	if report.ByReason, err = count("reason", "count desc, key"); err != nil {
+		return nil, err
+	}
+	if report.ByModel, err = count("model_name", "count desc, key"); err != nil {
+		return nil, err
+	}
+	if report.ByMonth, err = count(monthBucketExpr(s.DB), "key"); err != nil {
+		return nil, err
+	}
+	for _, c := range report.ByReason {
+		report.Total += c.Count
+	}
+	return report, nil
+}
+
+// OverrideQuery selects the overrides to analyze
+type OverrideQuery struct {
+	From, To *time.Time // Bounds on created_at, To exclusive; nil is open
+	GroupBy  string     // OverrideGroupReason, OverrideGroupModel or OverrideGroupDoctor
+	Recent   int        // Latest overrides to return in full
+}
+
+// Analytics counts the overrides in a range per reason, model or doctor,
+// relative to the assessments made in the same range. An override of
+// several models counts once for each when grouped by model.
+func (s *OverrideService) Analytics(q OverrideQuery) (*models.OverrideAnalytics, error) {
+	if q.GroupBy != OverrideGroupReason && q.GroupBy != OverrideGroupModel && q.GroupBy != OverrideGroupDoctor {
+		return nil, fmt.Errorf("%w: group_by must be reason, model or doctor", ErrInvalidOverride)
+	}
+	inRange := func(db *gorm.DB) *gorm.DB {
+		if q.From != nil {
+			db = db.Where("created_at >= ?", *q.From)
+		}
+		if q.To != nil {
+			db = db.Where("created_at < ?", *q.To)
+		}
+		return db
+	}
+
+	var logs []models.OverrideLog
+	if err := s.DB.Scopes(inRange).Order("created_at desc, id desc").Find(&logs).Error; err != nil {
+		return nil, err
+	}
+	out := &models.OverrideAnalytics{From: q.From, To: q.To, GroupBy: q.GroupBy, Total: int64(len(logs)), Groups: []models.OverrideGroup{}}
+	if err := s.DB.Model(&models.Assessment{}).Scopes(inRange).Count(&out.Assessments).Error; err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int64{}
+	for _, o := range logs {
+		for _, key := range overrideKeys(o, q.GroupBy) {
+			counts[key]++
+		}
+	}
+	for key, n := range counts {
+		out.Groups = append(out.Groups, models.OverrideGroup{Key: key, Count: n, Percent: percentOf(n, out.Assessments)})
+	}
+	slices.SortFunc(out.Groups, func(a, b models.OverrideGroup) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Key, b.Key))
+	})
+	out.Percent = percentOf(out.Total, out.Assessments)
+	out.Recent = logs[:min(q.Recent, len(logs))]
+	return out, nil
+}
+
+// overrideKeys are the groups an override counts towards
+func overrideKeys(o models.OverrideLog, groupBy string) []string {
+	switch groupBy {
+	case OverrideGroupModel:
+		if len(o.ModelsOverridden) > 0 {
+			return o.ModelsOverridden
+		}
+		return []string{cmp.Or(o.ModelName, OverrideModelUnspecified)}
+	case OverrideGroupDoctor:
+		return []string{cmp.Or(o.DoctorID, overrideDoctorUnknown)}
+	default:
+		return []string{cmp.Or(o.Reason, o.ReasonCode)}
+	}
+}
+
+// percentOf is n per 100 of total to two decimals, 0 when total is
+func percentOf(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(n)*10000/float64(total)) / 100
+}
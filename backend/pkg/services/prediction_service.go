@@ -2,158 +2,425 @@ package services
 
 import (
 	"bytes"
+	"container/list"
+	"context"
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"healthcare-backend/pkg/auditctx"
 	"healthcare-backend/pkg/cache"
+	"healthcare-backend/pkg/chaos"
+	"healthcare-backend/pkg/logging"
 	"healthcare-backend/pkg/models"
 	"healthcare-backend/pkg/queue"
 	"healthcare-backend/pkg/resilience"
+	"healthcare-backend/pkg/tracing"
 
+	"github.com/nats-io/nats.go"
 	"github.com/sony/gobreaker"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
 )
 
 // -- Diagnosis Cache (HYBRID: In-Memory + Redis) --
 
-type DiagnosisCache struct{
-	memCache map[uint]map[string]string
+// Diagnosis cache bounds. The TTL matches the Redis copy.
+const (
+	DefaultDiagnosisCacheSize = 10000
+	DiagnosisCacheTTL         = 1 * time.Hour
+)
+
+// DiagnosisCache keeps each patient's latest diagnosis in memory, in front of
+// Redis. Memory is bounded: entries expire after TTL and the least recently
+// used patient is evicted beyond MaxEntries. Safe for concurrent use.
+type DiagnosisCache struct {
+	mu         sync.Mutex
+	entries    map[uint]*list.Element // Values are *diagnosisEntry
+	lru        *list.List             // Front is most recently used
+	MaxEntries int
+	TTL        time.Duration
+	Now        func() time.Time // Injectable clock
+}
+
+type diagnosisEntry struct {
+	id        uint
+	diagnosis models.CachedDiagnosis
+	diseases  []models.DiseasePrediction // Latest disease predictions, for ICD-10 coding
+	storedAt  time.Time
 }
 
 func NewDiagnosisCache() *DiagnosisCache {
 	return &DiagnosisCache{
-		memCache: make(map[uint]map[string]string),
+		entries:    make(map[uint]*list.Element),
+		lru:        list.New(),
+		MaxEntries: DefaultDiagnosisCacheSize,
+		TTL:        DiagnosisCacheTTL,
+		Now:        time.Now,
 	}
 }
 
 func (c *DiagnosisCache) Set(id uint, diagnosis string, status string) {
-	data := map[string]string{
-		"diagnosis": diagnosis,
-		"status":    status,
-	}
-	
+	c.SetCoded(id, models.CachedDiagnosis{Diagnosis: diagnosis, Status: status})
+}
+
+// SetCoded stores a diagnosis along with its ICD-10 suggestions
+func (c *DiagnosisCache) SetCoded(id uint, d models.CachedDiagnosis) {
 	// Store in memory (always works)
-	c.memCache[id] = data
-	
+	c.store(id, d)
+
 	// Try Redis as secondary (may fail silently)
-	jsonData, _ := json.Marshal(data)
-	cache.Set(fmt.Sprintf("diag:status:%d", id), jsonData, 1*time.Hour)
+	jsonData, _ := json.Marshal(d)
+	cache.Set(fmt.Sprintf("diag:status:%d", id), jsonData, c.TTL)
 }
 
 func (c *DiagnosisCache) Get(id uint) (string, string) {
+	d := c.Lookup(id)
+	return d.Diagnosis, d.Status
+}
+
+// Lookup is the patient's cached diagnosis with its ICD-10 suggestions;
+// zero when there is none
+func (c *DiagnosisCache) Lookup(id uint) models.CachedDiagnosis {
 	// Try Redis first as workers update Redis
 	val, err := cache.Get(fmt.Sprintf("diag:status:%d", id))
 	if err == nil {
-		var data map[string]string
+		var data models.CachedDiagnosis
 		if err := json.Unmarshal([]byte(val), &data); err == nil {
 			// Update local memory for faster subsequent hits
-			c.memCache[id] = data
-			return data["diagnosis"], data["status"]
+			c.store(id, data)
+			return data
 		}
 	}
-	
+
 	// Fallback to memory
-	if data, ok := c.memCache[id]; ok {
-		return data["diagnosis"], data["status"]
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[id]
+	if !ok {
+		return models.CachedDiagnosis{}
 	}
-	
-	return "", ""
+	e := el.Value.(*diagnosisEntry)
+	if c.Now().Sub(e.storedAt) > c.TTL {
+		c.remove(el)
+		return models.CachedDiagnosis{}
+	}
+	c.lru.MoveToFront(el)
+	return e.diagnosis
+}
+
+// SetDiseases remembers the patient's latest disease predictions, which
+// the ICD-10 coding of their next diagnosis takes into account
+func (c *DiagnosisCache) SetDiseases(id uint, predictions []models.DiseasePrediction) {
+	c.update(id, func(e *diagnosisEntry) { e.diseases = predictions })
+	jsonData, _ := json.Marshal(predictions)
+	cache.Set(fmt.Sprintf("diag:diseases:%d", id), jsonData, c.TTL)
+}
+
+// Diseases are the patient's latest disease predictions, if any
+func (c *DiagnosisCache) Diseases(id uint) []models.DiseasePrediction {
+	if val, err := cache.Get(fmt.Sprintf("diag:diseases:%d", id)); err == nil {
+		var predictions []models.DiseasePrediction
+		if err := json.Unmarshal([]byte(val), &predictions); err == nil {
+			return predictions
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[id]
+	if !ok || c.Now().Sub(el.Value.(*diagnosisEntry).storedAt) > c.TTL {
+		return nil
+	}
+	return el.Value.(*diagnosisEntry).diseases
+}
+
+// Len is the number of patients held in memory, expired entries included
+func (c *DiagnosisCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// store writes the in-memory diagnosis, keeping the disease predictions
+func (c *DiagnosisCache) store(id uint, d models.CachedDiagnosis) {
+	c.update(id, func(e *diagnosisEntry) { e.diagnosis = d })
+}
+
+// update changes the patient's in-memory entry, creating it if needed, and
+// evicts past MaxEntries
+func (c *DiagnosisCache) update(id uint, change func(e *diagnosisEntry)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.Now()
+	if el, ok := c.entries[id]; ok {
+		e := el.Value.(*diagnosisEntry)
+		change(e)
+		e.storedAt = now
+		c.lru.MoveToFront(el)
+		return
+	}
+	e := &diagnosisEntry{id: id, storedAt: now}
+	change(e)
+	c.entries[id] = c.lru.PushFront(e)
+	for c.MaxEntries > 0 && c.lru.Len() > c.MaxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove drops an entry; the caller holds mu
+func (c *DiagnosisCache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*diagnosisEntry).id)
+}
+
+// Delete forgets a patient's diagnosis
+func (c *DiagnosisCache) Delete(id uint) {
+	c.mu.Lock()
+	if el, ok := c.entries[id]; ok {
+		c.remove(el)
+	}
+	c.mu.Unlock()
+	cache.Delete(fmt.Sprintf("diag:status:%d", id))
+	cache.Delete(fmt.Sprintf("diag:diseases:%d", id))
 }
 
 // -- Service --
 
 type PredictionService struct {
 	MLServiceURL  string
+	Client        *http.Client  // Every ML call but /diagnose; replace with SetClient
+	LLMTimeout    time.Duration // Bound on a direct diagnosis, none when zero
 	Cache         *DiagnosisCache
+	Diagnoses     *DiagnosisRegistry
 	CB            *gobreaker.CircuitBreaker
 	LastMLLatency int64 // Ms
+
+	// Risk prediction backends: primary always, canary when a rollout is configured
+	Primary       *MLBackend
+	Canary        *MLBackend
+	canaryPercent atomic.Int64
+
+	// Optional shadow deployment: receives a copy of every live prediction
+	Shadow *ShadowService
+
+	Summarizer *ExplanationSummarizer // Plain-language explanations of each prediction
+
+	// Optional strict contract mode: flags ML responses with unknown or missing keys
+	Contract *ContractMonitor
+
+	// Medication interaction table; nil uses DefaultDrugInteractions
+	Interactions *InteractionChecker
+
+	// ICD-10 code table for finished diagnoses; nil uses DefaultICD10Codes
+	ICD10 *ICD10Coder
+
+	// Prometheus series; nil until RegisterMetrics, recording nothing
+	Metrics *Metrics
+
+	// Optional: QueueDiagnosisTx saves diagnosis tasks here, sent on commit
+	Outbox *Outbox
+
+	// Optional: finished diagnoses are saved here too, for search
+	Store *DiagnosisStore
+
+	// PerPatientCache keys cached predictions by patient as well as vitals,
+	// so patients with identical vitals never share a result
+	PerPatientCache bool
+	cacheHits       atomic.Int64
+	cacheMisses     atomic.Int64
 }
 
 func NewPredictionService(mlURL string) *PredictionService {
+	cb := resilience.NewCircuitBreaker("ML-Service")
+	client := NewMLClient(DefaultMLTimeout)
 	return &PredictionService{
 		MLServiceURL: mlURL,
+		Client:       client,
+		LLMTimeout:   DefaultLLMTimeout,
 		Cache:        NewDiagnosisCache(),
-		CB:           resilience.NewCircuitBreaker("ML-Service"),
+		Diagnoses:    NewDiagnosisRegistry(),
+		CB:           cb,
+		Primary:      &MLBackend{Name: BackendPrimary, URL: mlURL, CB: cb, Client: client},
+		Summarizer:   NewExplanationSummarizer(),
+	}
+}
+
+// SetClient makes every ML backend call through client, e.g. one from
+// NewMLClient with a configured timeout
+func (s *PredictionService) SetClient(client *http.Client) {
+	s.Client = client
+	s.Primary.Client = client
+	if s.Canary != nil {
+		s.Canary.Client = client
 	}
 }
 
+// SetCanary enables routing a percentage of patients to a second ML deployment
+func (s *PredictionService) SetCanary(url string, percent int) error {
+	if url == "" {
+		s.Canary = nil
+		return nil
+	}
+	s.Canary = NewMLBackend(BackendCanary, url)
+	s.Canary.Contract = s.Contract
+	s.Canary.Client = s.Client
+	return s.SetCanaryPercent(percent)
+}
+
+// SetCanaryPercent adjusts the rollout share at runtime
+func (s *PredictionService) SetCanaryPercent(percent int) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("canary percent must be between 0 and 100, got %d", percent)
+	}
+	s.canaryPercent.Store(int64(percent))
+	return nil
+}
+
+// SetContractMonitor turns on strict contract checks for every ML backend
+func (s *PredictionService) SetContractMonitor(m *ContractMonitor) {
+	s.Contract = m
+	s.Primary.Contract = m
+	if s.Canary != nil {
+		s.Canary.Contract = m
+	}
+}
+
+// CanaryPercent returns the current rollout share
+func (s *PredictionService) CanaryPercent() int {
+	return int(s.canaryPercent.Load())
+}
+
+// RoutesToCanary reports whether this patient is served by the canary backend.
+// The split is deterministic so a patient always sees the same model version.
+func (s *PredictionService) RoutesToCanary(patientID uint) bool {
+	if s.Canary == nil {
+		return false
+	}
+	return canaryBucket(patientID) < s.CanaryPercent()
+}
+
+// BackendMetrics returns per-backend success and latency stats
+func (s *PredictionService) BackendMetrics() []models.MLBackendMetrics {
+	metrics := []models.MLBackendMetrics{s.Primary.Metrics()}
+	if s.Canary != nil {
+		metrics = append(metrics, s.Canary.Metrics())
+	}
+	return metrics
+}
+
+// HashVitals hashes exactly what /predict is sent, so two patients share a
+// hash only when the model would see the same input
 func (s *PredictionService) HashVitals(p models.PatientData) string {
-	raw := fmt.Sprintf("%d|%d|%d|%.2f|%d|%s", p.Age, p.SystolicBP, p.Glucose, p.BMI, p.Cholesterol, p.Smoking)
-	h := sha256.Sum256([]byte(raw))
+	h := sha256.Sum256(BuildPredictPayload(p))
 	return fmt.Sprintf("%x", h)
 }
 
+// predictionCacheKey is shared by every patient with the same vitals on the
+// same backend, unless PerPatientCache is set
+func (s *PredictionService) predictionCacheKey(p models.PatientData) string {
+	key := "predict:"
+	if s.RoutesToCanary(p.ID) {
+		key += BackendCanary + ":"
+	}
+	if s.PerPatientCache {
+		key += fmt.Sprintf("%d:", p.ID)
+	}
+	return key + s.HashVitals(p)
+}
+
+// CacheStats counts prediction cache lookups since startup
+func (s *PredictionService) CacheStats() models.PredictionCacheStats {
+	stats := models.PredictionCacheStats{Hits: s.cacheHits.Load(), Misses: s.cacheMisses.Load()}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total) * 100
+	}
+	return stats
+}
+
+// InvalidatePrediction drops the cached risk prediction for the patient's
+// vitals, so the next PredictRisks calls the model
+func (s *PredictionService) InvalidatePrediction(p models.PatientData) {
+	cache.Delete(s.predictionCacheKey(p))
+}
+
 func (s *PredictionService) PredictRisks(patient models.PatientData) (*models.PredictResponse, error) {
+	return s.PredictRisksCtx(context.Background(), patient)
+}
+
+// PredictRisksCtx is PredictRisks bounded by ctx. When ctx ends first it
+// returns ctx's error instead of the rule-based fallback, so callers can
+// tell "too slow" apart from "ML down".
+func (s *PredictionService) PredictRisksCtx(ctx context.Context, patient models.PatientData) (*models.PredictResponse, error) {
 	mlStart := time.Now()
+	ctx, span := tracing.Start(ctx, "ml.predict", trace.WithAttributes(attribute.Int64("patient.id", int64(patient.ID))))
+	defer span.End()
 
 	// 1. Check Cache
-	cacheKey := fmt.Sprintf("predict:%d:%s", patient.ID, s.HashVitals(patient))
+	cacheKey := s.predictionCacheKey(patient)
 	if cached, err := cache.Get(cacheKey); err == nil {
 		var risks models.PredictResponse
 		if err := json.Unmarshal([]byte(cached), &risks); err == nil {
-			log.Printf("🚀 ML Predict (CACHED): %v", time.Since(mlStart))
+			s.cacheHits.Add(1)
+			s.Metrics.CacheLookup(true)
+			span.SetAttributes(attribute.Bool("cache.hit", true), attribute.String("prediction.source", models.PredictionSourceCached))
+			risks.Source = models.PredictionSourceCached
+			logging.FromContext(ctx).Debug("ml predict", "patient_id", patient.ID, "source", risks.Source, "duration_ms", time.Since(mlStart).Milliseconds())
 			return &risks, nil
 		}
 	}
+	s.cacheMisses.Add(1)
+	s.Metrics.CacheLookup(false)
+	span.SetAttributes(attribute.Bool("cache.hit", false))
 
-	// 2. Cache Miss - Call ML API (with Circuit Breaker)
-	body, err := s.CB.Execute(func() (interface{}, error) {
-		// Convert patient to map to handle symptoms as list
-		symptomsSlice := []string{}
-		if patient.Symptoms != "" {
-			parts := strings.Split(patient.Symptoms, ",")
-			for _, p := range parts {
-				symptomsSlice = append(symptomsSlice, strings.TrimSpace(p))
-			}
-		}
+	// 2. Cache Miss - Call ML API (with Circuit Breaker per backend)
+	predictPayload := BuildPredictPayload(patient)
 
-		predictPayload, _ := json.Marshal(map[string]interface{}{
-			"age":                   patient.Age,
-			"gender":                patient.Gender,
-			"systolic_bp":           patient.SystolicBP,
-			"diastolic_bp":          patient.DiastolicBP,
-			"glucose":               patient.Glucose,
-			"bmi":                   patient.BMI,
-			"cholesterol":           patient.Cholesterol,
-			"heart_rate":            patient.HeartRate,
-			"steps":                 patient.Steps,
-			"smoking":               patient.Smoking,
-			"alcohol":               patient.Alcohol,
-			"medications":           patient.Medications,
-			"history_heart_disease": patient.HistoryHeartDisease,
-			"history_stroke":        patient.HistoryStroke,
-			"history_diabetes":      patient.HistoryDiabetes,
-			"history_high_chol":     patient.HistoryHighChol,
-			"symptoms":              symptomsSlice,
-		})
-
-		resp, err := http.Post(s.MLServiceURL+"/predict", "application/json", bytes.NewBuffer(predictPayload))
+	var risks *models.PredictResponse
+	var err error
+	predict := func(b *MLBackend) (*models.PredictResponse, error) {
+		start := time.Now()
+		risks, err := b.predict(ctx, predictPayload)
+		s.Metrics.ObservePredict(b.Name, time.Since(start), err)
+		return risks, err
+	}
+	if s.RoutesToCanary(patient.ID) {
+		risks, err = predict(s.Canary)
 		if err != nil {
-			return nil, err
+			logging.FromContext(ctx).Warn("canary ml predict failed, falling back to primary", "patient_id", patient.ID, "error", err)
 		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("ML API returned status %d", resp.StatusCode)
-		}
-
-		var risks models.PredictResponse
-		if err := json.NewDecoder(resp.Body).Decode(&risks); err != nil {
-			return nil, err
-		}
-		return &risks, nil
-	})
+	}
+	if risks == nil {
+		risks, err = predict(s.Primary)
+	}
 
+	if err != nil && ctx.Err() != nil {
+		span.RecordError(ctx.Err())
+		return nil, ctx.Err()
+	}
 	if err != nil {
-		log.Printf("🔌 ML Service Error (CB): %v. Activating Rule-Based Fallback!", err)
-		return s.ruleBasedPredictRisks(patient), nil
+		logging.FromContext(ctx).Warn("ml predict failed, using rule-based fallback", "patient_id", patient.ID, "error", err)
+		span.RecordError(err)
+		span.SetAttributes(attribute.String("prediction.source", models.PredictionSourceRuleBased))
+		return s.RuleBasedPredictRisks(patient), nil
+	}
+
+	// Mirror to the shadow model (async, never affects this response)
+	if s.Shadow != nil {
+		s.Shadow.Compare(patient.ID, predictPayload, *risks)
 	}
 
-	risks := body.(*models.PredictResponse)
+	risks.Source = models.PredictionSourceML
+	span.SetAttributes(attribute.String("prediction.source", risks.Source), attribute.String("ml.backend", risks.Backend))
 
 	// 3. Set Cache (TTL: 5 minutes)
 	if risksData, err := json.Marshal(risks); err == nil {
@@ -161,67 +428,256 @@ func (s *PredictionService) PredictRisks(patient models.PatientData) (*models.Pr
 	}
 
 	s.LastMLLatency = time.Since(mlStart).Milliseconds()
-	log.Printf("⏱️ ML Predict (LIVE): %v", time.Since(mlStart))
+	logging.FromContext(ctx).Info("ml predict", "patient_id", patient.ID, "source", risks.Source, "backend", risks.Backend, "duration_ms", s.LastMLLatency)
 	return risks, nil
 }
 
-// ruleBasedPredictRisks provides a clinical heuristic fallback when ML service is down
-func (s *PredictionService) ruleBasedPredictRisks(p models.PatientData) *models.PredictResponse {
-	risks := &models.PredictResponse{
-		ModelPrecisions: map[string]float64{
-			"Heart_Model":    0.0, // Indicated as rule-based
-			"Diabetes_Model": 0.0,
-			"Stroke_Model":   0.0,
-			"Kidney_Model":   0.0,
-		},
-		Explanations: make(map[string]map[string]float64),
-	}
-
-	// Heuristic 1: Heart Risk
-	if p.SystolicBP > 160 || p.Cholesterol > 240 {
-		risks.HeartRisk = 0.85
-	} else if p.SystolicBP > 140 || p.Cholesterol > 200 {
-		risks.HeartRisk = 0.45
-	} else {
-		risks.HeartRisk = 0.15
+// BuildPredictPayload converts the patient to the ML /predict contract
+// (symptoms as a list). Imputed measurements are left out so the model
+// applies its own imputation instead of reading them as zero.
+func BuildPredictPayload(patient models.PatientData) []byte {
+	symptomsSlice := []string{}
+	if patient.Symptoms != "" {
+		parts := strings.Split(patient.Symptoms, ",")
+		for _, p := range parts {
+			symptomsSlice = append(symptomsSlice, strings.TrimSpace(p))
+		}
 	}
 
-	// Heuristic 2: Diabetes Risk
-	if p.Glucose > 200 || p.BMI > 35 {
-		risks.DiabetesRisk = 0.90
-	} else if p.Glucose > 125 || p.BMI > 30 {
-		risks.DiabetesRisk = 0.50
-	} else {
-		risks.DiabetesRisk = 0.10
+	payload := map[string]interface{}{
+		"age":                   patient.Age,
+		"gender":                patient.Gender,
+		"systolic_bp":           patient.SystolicBP,
+		"diastolic_bp":          patient.DiastolicBP,
+		"glucose":               patient.Glucose,
+		"bmi":                   patient.BMI,
+		"cholesterol":           patient.Cholesterol,
+		"heart_rate":            patient.HeartRate,
+		"steps":                 patient.Steps,
+		"smoking":               patient.Smoking,
+		"alcohol":               patient.Alcohol,
+		"medications":           patient.Medications,
+		"history_heart_disease": patient.HistoryHeartDisease,
+		"history_stroke":        patient.HistoryStroke,
+		"history_diabetes":      patient.HistoryDiabetes,
+		"history_high_chol":     patient.HistoryHighChol,
+		"symptoms":              symptomsSlice,
 	}
+	for _, field := range patient.Imputed() {
+		delete(payload, field)
+	}
+	predictPayload, _ := json.Marshal(payload)
+	return predictPayload
+}
 
-	// Heuristic 3: Stroke Risk
-	if p.Age > 65 && p.SystolicBP > 160 {
-		risks.StrokeRisk = 0.75
-	} else if p.Age > 50 && p.SystolicBP > 140 {
-		risks.StrokeRisk = 0.35
-	} else {
-		risks.StrokeRisk = 0.05
+// BuildUrgencyPayload converts symptoms and vitals to the ML /urgency/predict
+// contract, leaving imputed measurements out like BuildPredictPayload
+func BuildUrgencyPayload(symptoms []string, patient models.PatientData) []byte {
+	patientMap := map[string]interface{}{
+		"age":          patient.Age,
+		"systolic_bp":  patient.SystolicBP,
+		"diastolic_bp": patient.DiastolicBP,
+		"glucose":      patient.Glucose,
+		"bmi":          patient.BMI,
+		"cholesterol":  patient.Cholesterol,
+		"heart_rate":   patient.HeartRate,
+	}
+	for _, field := range patient.Imputed() {
+		delete(patientMap, field)
+	}
+
+	payload, _ := json.Marshal(models.MLUrgencyRequest{
+		Symptoms:    symptoms,
+		PatientData: patientMap,
+	})
+	return payload
+}
+
+// riskCheck is one threshold a fallback rule looks at
+type riskCheck struct {
+	Field string // JSON name of the patient field
+	Label string
+	Above float64
+}
+
+func (c riskCheck) value(p models.PatientData) float64 {
+	switch c.Field {
+	case "age":
+		return float64(p.Age)
+	case "systolic_bp":
+		return float64(p.SystolicBP)
+	case "glucose":
+		return float64(p.Glucose)
+	case "bmi":
+		return p.BMI
+	case "cholesterol":
+		return float64(p.Cholesterol)
+	case "history_diabetes":
+		if p.HistoryDiabetes == "Yes" {
+			return 1
+		}
+	}
+	return 0
+}
+
+// flag reports whether c tests a yes/no history answer (Above 0) rather
+// than a measurement
+func (c riskCheck) flag() bool {
+	return strings.HasPrefix(c.Field, "history_")
+}
+
+// riskRule is one rung of a fallback heuristic. It fires when any of its
+// checks passes (all of them with All); a rung without checks always fires.
+type riskRule struct {
+	Score  float64 // Out of models.ScoreScale, like the ML service's scores
+	Level  string // "high", "moderate" or "low"
+	All    bool
+	Checks []riskCheck
+}
+
+func (r riskRule) fires(p models.PatientData) bool {
+	if len(r.Checks) == 0 {
+		return true
+	}
+	for _, c := range r.Checks {
+		if passed := c.value(p) > c.Above; passed != r.All {
+			return passed
+		}
+	}
+	return r.All
+}
+
+// fallbackRules are the rule-based heuristics per model, highest rung first.
+// A high heart risk is above models.EmergencyHeartRisk, so it raises the
+// emergency a live model would.
+var fallbackRules = map[string][]riskRule{
+	"heart": {
+		{Score: 90, Level: "high", Checks: []riskCheck{{"systolic_bp", "systolic BP", 160}, {"cholesterol", "cholesterol", 240}}},
+		{Score: 45, Level: "moderate", Checks: []riskCheck{{"systolic_bp", "systolic BP", 140}, {"cholesterol", "cholesterol", 200}}},
+		{Score: 15, Level: "low"},
+	},
+	"diabetes": {
+		{Score: 90, Level: "high", Checks: []riskCheck{{"glucose", "glucose", 200}, {"bmi", "BMI", 35}}},
+		{Score: 50, Level: "moderate", Checks: []riskCheck{{"glucose", "glucose", 125}, {"bmi", "BMI", 30}}},
+		{Score: 10, Level: "low"},
+	},
+	"stroke": {
+		{Score: 75, Level: "high", All: true, Checks: []riskCheck{{"age", "age", 65}, {"systolic_bp", "systolic BP", 160}}},
+		{Score: 35, Level: "moderate", All: true, Checks: []riskCheck{{"age", "age", 50}, {"systolic_bp", "systolic BP", 140}}},
+		{Score: 5, Level: "low"},
+	},
+	// No creatinine at intake, so this follows the CKD risk factors eGFR
+	// screening targets: age, hypertension and diabetes
+	"kidney": {
+		{Score: 70, Level: "high", All: true, Checks: []riskCheck{{"age", "age", 60}, {"systolic_bp", "systolic BP", 140}, {"history_diabetes", "diabetes history", 0}}},
+		{Score: 40, Level: "moderate", Checks: []riskCheck{{"age", "age", 75}, {"systolic_bp", "systolic BP", 160}, {"glucose", "glucose", 180}, {"history_diabetes", "diabetes history", 0}}},
+		{Score: 10, Level: "low"},
+	},
+}
+
+// fallbackConfidence is how far each model's heuristic can be trusted, as a
+// percentage like the ML precisions. Kidney has the least to go on.
+var fallbackConfidence = map[string]float64{
+	"heart":    60,
+	"diabetes": 70,
+	"stroke":   55,
+	"kidney":   45,
+}
+
+// ruleConfidence discounts a model's fallback confidence by the share of its
+// rule inputs that were never measured
+func ruleConfidence(model string, p models.PatientData) float64 {
+	fields := map[string]bool{}
+	for _, rule := range fallbackRules[model] {
+		for _, c := range rule.Checks {
+			fields[c.Field] = true
+		}
+	}
+	if len(fields) == 0 {
+		return 0
+	}
+	measured := 0
+	for f := range fields {
+		if !imputed(p, f) {
+			measured++
+		}
+	}
+	return fallbackConfidence[model] * float64(measured) / float64(len(fields))
+}
+
+// firedRule is the first rung of model's fallback heuristic that applies to p
+func firedRule(model string, p models.PatientData) (riskRule, bool) {
+	for _, rule := range fallbackRules[model] {
+		if rule.fires(p) {
+			return rule, true
+		}
+	}
+	return riskRule{}, false
+}
+
+// DegradedUrgency stands in for the urgency prediction when the ML service is
+// down. Level 0 with Degraded set tells clients the urgency is unknown.
+func DegradedUrgency() *models.UrgencyResponse {
+	return &models.UrgencyResponse{UrgencyLevel: 0, UrgencyName: "Unavailable", Confidence: "none", Degraded: true}
+}
+
+// RuleBasedPredictRisks provides a clinical heuristic fallback when ML service is down
+func (s *PredictionService) RuleBasedPredictRisks(p models.PatientData) *models.PredictResponse {
+	risks := &models.PredictResponse{
+		Degraded:        true,
+		Source:          models.PredictionSourceRuleBased,
+		ModelPrecisions: make(map[string]float64),
+		Explanations:    make(map[string]map[string]float64),
+	}
+
+	scores := map[string]*float64{
+		"heart":    &risks.HeartRisk,
+		"diabetes": &risks.DiabetesRisk,
+		"stroke":   &risks.StrokeRisk,
+		"kidney":   &risks.KidneyRisk,
+	}
+	for _, m := range explainedModels {
+		rule, _ := firedRule(m.Key, p)
+		*scores[m.Key] = rule.Score
+		risks.ModelPrecisions[m.Name+"_Model"] = ruleConfidence(m.Key, p)
 	}
 
 	// Global Stats
-	risks.GeneralHealthScore = 1.0 - (risks.HeartRisk+risks.DiabetesRisk+risks.StrokeRisk)/3.0
-	risks.ClinicalConfidence = 0.50 // Low confidence since it's rule-based
+	risks.GeneralHealthScore = models.ScoreScale - (risks.HeartRisk+risks.DiabetesRisk+risks.StrokeRisk+risks.KidneyRisk)/4.0
+	risks.ClinicalConfidence = 0.50 * models.ScoreScale // Low confidence since it's rule-based
 	
 	return risks
 }
 
 func (s *PredictionService) PredictDisease(req models.DiseaseRequest) (*models.DiseaseResponse, error) {
+	return s.PredictDiseaseCtx(context.Background(), req)
+}
+
+// PredictDiseaseCtx is PredictDisease bounded by ctx
+func (s *PredictionService) PredictDiseaseCtx(ctx context.Context, req models.DiseaseRequest) (*models.DiseaseResponse, error) {
 	body, err := s.CB.Execute(func() (interface{}, error) {
+		if err := chaos.Inject(chaos.TargetML, "/disease/predict"); err != nil {
+			return nil, err
+		}
 		payload, _ := json.Marshal(req)
-		resp, err := http.Post(s.MLServiceURL+"/disease/predict", "application/json", bytes.NewBuffer(payload))
+		httpReq, err := newMLRequest(ctx, s.MLServiceURL+"/disease/predict", bytes.NewBuffer(payload))
+		if err != nil {
+			return nil, err
+		}
+		resp, err := doML(s.Client, httpReq)
 		if err != nil {
 			return nil, err
 		}
 		defer resp.Body.Close()
 
+		raw, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		s.Contract.Check("/disease/predict", raw)
+
 		var result models.DiseaseResponse
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		if err := json.Unmarshal(raw, &result); err != nil {
 			return nil, err
 		}
 		return &result, nil
@@ -230,20 +686,42 @@ func (s *PredictionService) PredictDisease(req models.DiseaseRequest) (*models.D
 	if err != nil {
 		return nil, err
 	}
-	return body.(*models.DiseaseResponse), nil
+	result := body.(*models.DiseaseResponse)
+	if id, err := strconv.ParseUint(req.PatientID, 10, 64); err == nil && id > 0 {
+		s.Cache.SetDiseases(uint(id), result.Predictions)
+	}
+	return result, nil
 }
 
 func (s *PredictionService) AnalyzeEKG(req models.EKGRequest) (*models.EKGResponse, error) {
+	return s.AnalyzeEKGCtx(context.Background(), req)
+}
+
+// AnalyzeEKGCtx is AnalyzeEKG bounded by ctx
+func (s *PredictionService) AnalyzeEKGCtx(ctx context.Context, req models.EKGRequest) (*models.EKGResponse, error) {
 	body, err := s.CB.Execute(func() (interface{}, error) {
+		if err := chaos.Inject(chaos.TargetML, "/ekg/analyze"); err != nil {
+			return nil, err
+		}
 		payload, _ := json.Marshal(req)
-		resp, err := http.Post(s.MLServiceURL+"/ekg/analyze", "application/json", bytes.NewBuffer(payload))
+		httpReq, err := newMLRequest(ctx, s.MLServiceURL+"/ekg/analyze", bytes.NewBuffer(payload))
+		if err != nil {
+			return nil, err
+		}
+		resp, err := doML(s.Client, httpReq)
 		if err != nil {
 			return nil, err
 		}
 		defer resp.Body.Close()
 
+		raw, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		s.Contract.Check("/ekg/analyze", raw)
+
 		var result models.EKGResponse
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		if err := json.Unmarshal(raw, &result); err != nil {
 			return nil, err
 		}
 		return &result, nil
@@ -256,32 +734,34 @@ func (s *PredictionService) AnalyzeEKG(req models.EKGRequest) (*models.EKGRespon
 }
 
 func (s *PredictionService) PredictUrgency(symptoms []string, patient models.PatientData) (*models.UrgencyResponse, error) {
+	return s.PredictUrgencyCtx(context.Background(), symptoms, patient)
+}
+
+// PredictUrgencyCtx is PredictUrgency bounded by ctx
+func (s *PredictionService) PredictUrgencyCtx(ctx context.Context, symptoms []string, patient models.PatientData) (*models.UrgencyResponse, error) {
 	body, err := s.CB.Execute(func() (interface{}, error) {
-		// Prepare patient data as map for the ML API
-		patientMap := map[string]interface{}{
-			"age":          patient.Age,
-			"systolic_bp":  patient.SystolicBP,
-			"diastolic_bp": patient.DiastolicBP,
-			"glucose":      patient.Glucose,
-			"bmi":          patient.BMI,
-			"cholesterol":  patient.Cholesterol,
-			"heart_rate":   patient.HeartRate,
+		if err := chaos.Inject(chaos.TargetML, "/urgency/predict"); err != nil {
+			return nil, err
 		}
-
-		req := models.MLUrgencyRequest{
-			Symptoms:    symptoms,
-			PatientData: patientMap,
+		payload := BuildUrgencyPayload(symptoms, patient)
+		httpReq, err := newMLRequest(ctx, s.MLServiceURL+"/urgency/predict", bytes.NewBuffer(payload))
+		if err != nil {
+			return nil, err
 		}
-
-		payload, _ := json.Marshal(req)
-		resp, err := http.Post(s.MLServiceURL+"/urgency/predict", "application/json", bytes.NewBuffer(payload))
+		resp, err := doML(s.Client, httpReq)
 		if err != nil {
 			return nil, err
 		}
 		defer resp.Body.Close()
 
+		raw, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		s.Contract.Check("/urgency/predict", raw)
+
 		var urgency models.UrgencyResponse
-		if err := json.NewDecoder(resp.Body).Decode(&urgency); err != nil {
+		if err := json.Unmarshal(raw, &urgency); err != nil {
 			return nil, err
 		}
 		return &urgency, nil
@@ -293,93 +773,269 @@ func (s *PredictionService) PredictUrgency(symptoms []string, patient models.Pat
 	return body.(*models.UrgencyResponse), nil
 }
 
-func (s *PredictionService) StartAsyncDiagnosis(patientID uint, req models.DiagnosisRequest, onComplete func(uint, string, string)) {
-	// 1. Mark as pending in Redis
+// StartAsyncDiagnosis queues an LLM diagnosis and returns the request as
+// sent. It supersedes any diagnosis already in flight for the patient: that
+// one's result will be discarded.
+func (s *PredictionService) StartAsyncDiagnosis(patientID uint, req models.DiagnosisRequest, onComplete func(uint, string, string)) models.DiagnosisRequest {
+	return s.StartAsyncDiagnosisCtx(context.Background(), patientID, req, onComplete)
+}
+
+// StartAsyncDiagnosisCtx is StartAsyncDiagnosis continuing ctx's trace: the
+// task carries it in its NATS headers for the worker. ctx's cancellation
+// doesn't reach the diagnosis.
+func (s *PredictionService) StartAsyncDiagnosisCtx(ctx context.Context, patientID uint, req models.DiagnosisRequest, onComplete func(uint, string, string)) models.DiagnosisRequest {
+	ctx, span := tracing.Start(context.WithoutCancel(ctx), "llm.enqueue", trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.Int64("patient.id", int64(patientID)), attribute.String("diagnosis.priority", req.Priority)))
+	defer span.End()
+
+	req, msg := s.diagnosisTask(ctx, patientID, req)
+
+	// Try to publish to NATS for Worker pick-up
+	if err := queue.PublishDurableMsg(msg); err != nil {
+		logging.FromContext(ctx).Warn("nats unavailable, calling the LLM directly", "patient_id", patientID, "error", err)
+		span.SetAttributes(attribute.Bool("diagnosis.direct", true))
+		// Fallback: Call LLM directly in a goroutine
+		go s.callLLMDirectly(ctx, patientID, req, onComplete)
+		return req
+	}
+
+	logging.FromContext(ctx).Info("llm task published", "patient_id", patientID, "subject", msg.Subject)
+	return req
+}
+
+// diagnosisTask marks the patient's diagnosis pending and builds its NATS
+// task, carrying ctx's trace; emergencies go to the priority subject
+func (s *PredictionService) diagnosisTask(ctx context.Context, patientID uint, req models.DiagnosisRequest) (models.DiagnosisRequest, *nats.Msg) {
+	req.Generation = s.Diagnoses.Next(patientID)
 	s.Cache.Set(patientID, "", "pending")
-	
-	// 2. Try to publish to NATS for Worker pick-up
+
+	subject := "llm.tasks"
+	if req.Priority == models.DiagnosisPriorityEmergency {
+		subject = "llm.tasks.priority"
+	}
 	reqData, _ := json.Marshal(req)
-	if err := queue.Publish("llm.tasks", reqData); err != nil {
-		log.Printf("⚠️ NATS unavailable, falling back to sync LLM call for patient %d", patientID)
-		// Fallback: Call LLM directly in a goroutine
-		go s.callLLMDirectly(patientID, req, onComplete)
-		return
+	msg := &nats.Msg{Subject: subject, Data: reqData, Header: nats.Header{}}
+	tracing.Inject(ctx, msg.Header)
+	return req, msg
+}
+
+// QueueDiagnosisTx is StartAsyncDiagnosisCtx saving the task to Outbox with
+// tx instead of publishing it, so it's sent only if tx commits. On rollback
+// the caller cancels the diagnosis with CancelDiagnosis. Requires Outbox.
+func (s *PredictionService) QueueDiagnosisTx(ctx context.Context, tx *gorm.DB, patientID uint, req models.DiagnosisRequest) (models.DiagnosisRequest, error) {
+	ctx, span := tracing.Start(context.WithoutCancel(ctx), "llm.enqueue", trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.Int64("patient.id", int64(patientID)), attribute.String("diagnosis.priority", req.Priority)))
+	defer span.End()
+
+	req, msg := s.diagnosisTask(ctx, patientID, req)
+	if err := s.Outbox.Add(tx, patientID, msg); err != nil {
+		span.RecordError(err)
+		return req, err
 	}
+	return req, nil
+}
 
-	log.Printf("🚀 LLM Task Published to NATS for patient %d", patientID)
+// DeliverDiagnosis is the Outbox's delivery for diagnosis tasks: it
+// publishes them, calling the LLM directly when NATS is unavailable, as
+// StartAsyncDiagnosis does
+func (s *PredictionService) DeliverDiagnosis(onComplete func(uint, string, string)) func(*nats.Msg) error {
+	return func(msg *nats.Msg) error {
+		ctx := tracing.Extract(context.Background(), msg.Header)
+		var req models.DiagnosisRequest
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			return err
+		}
+		if err := queue.PublishDurableMsg(msg); err != nil {
+			logging.FromContext(ctx).Warn("nats unavailable, calling the LLM directly", "patient_id", req.Patient.ID, "error", err)
+			go s.callLLMDirectly(ctx, req.Patient.ID, req, onComplete)
+			return nil
+		}
+		logging.FromContext(ctx).Info("llm task published", "patient_id", req.Patient.ID, "subject", msg.Subject)
+		return nil
+	}
 }
 
-// callLLMDirectly is a fallback when NATS is unavailable
-func (s *PredictionService) callLLMDirectly(patientID uint, req models.DiagnosisRequest, onComplete func(uint, string, string)) {
+// DiagnosisTimedOut is the diagnosis left when the LLM call runs out of time
+const DiagnosisTimedOut = "Diagnosis unavailable - error: timeout"
+
+// callLLMDirectly is a fallback when NATS is unavailable. It gives up after
+// LLMTimeout, leaving DiagnosisTimedOut.
+func (s *PredictionService) callLLMDirectly(ctx context.Context, patientID uint, req models.DiagnosisRequest, onComplete func(uint, string, string)) {
 	llmStart := time.Now()
+	ctx, span := tracing.Start(ctx, "llm.diagnose", trace.WithAttributes(attribute.Int64("patient.id", int64(patientID))))
+	defer span.End()
+	if s.LLMTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.LLMTimeout)
+		defer cancel()
+	}
 	diagPayload, _ := json.Marshal(req)
-	
-	resp, err := http.Post(s.MLServiceURL+"/diagnose", "application/json", bytes.NewBuffer(diagPayload))
-	if err != nil {
-		log.Printf("❌ LLM Direct Call Error: %v", err)
-		s.Cache.Set(patientID, "Diagnosis unavailable - LLM service error", "error")
-		if onComplete != nil {
-			onComplete(patientID, "Diagnosis unavailable - LLM service error", "error")
+
+	var resp *http.Response
+	err := chaos.Inject(chaos.TargetML, "/diagnose")
+	if err == nil {
+		var httpReq *http.Request
+		if httpReq, err = newMLRequest(auditctx.WithRequestID(ctx, req.RequestID), s.MLServiceURL+"/diagnose", bytes.NewBuffer(diagPayload)); err == nil {
+			resp, err = s.diagnoseClient().Do(httpReq)
 		}
+	}
+	if err != nil {
+		s.Metrics.ObserveDiagnose(time.Since(llmStart), err)
+		s.failDiagnosis(ctx, span, patientID, req, "Diagnosis unavailable - LLM service error", err, onComplete)
 		return
 	}
 	defer resp.Body.Close()
 
 	var diagRes models.DiagnosisResponse
-	if err := json.NewDecoder(resp.Body).Decode(&diagRes); err != nil {
-		log.Printf("❌ LLM Direct Decode Error: %v", err)
-		s.Cache.Set(patientID, "Diagnosis unavailable - Decode error", "error")
-		if onComplete != nil {
-			onComplete(patientID, "Diagnosis unavailable - Decode error", "error")
-		}
+	raw, err := io.ReadAll(resp.Body)
+	if err == nil {
+		s.Contract.Check("/diagnose", raw)
+		err = json.Unmarshal(raw, &diagRes)
+	}
+	s.Metrics.ObserveDiagnose(time.Since(llmStart), err)
+	if err != nil {
+		s.failDiagnosis(ctx, span, patientID, req, "Diagnosis unavailable - Decode error", err, onComplete)
 		return
 	}
 
-	log.Printf("✅ LLM Direct Call completed for patient %d in %v", patientID, time.Since(llmStart))
-	s.Cache.Set(patientID, diagRes.Diagnosis, "ready")
+	logging.FromContext(ctx).Info("direct llm call completed", "patient_id", patientID, "duration_ms", time.Since(llmStart).Milliseconds())
+	s.finishDiagnosis(patientID, req.Generation, diagRes.Diagnosis, "ready", onComplete)
+}
+
+// diagnoseClient is Client without its own timeout, which is meant for
+// quick calls: a diagnosis is bounded by LLMTimeout instead
+func (s *PredictionService) diagnoseClient() *http.Client {
+	client := *s.Client
+	client.Timeout = 0
+	return &client
+}
+
+// failDiagnosis leaves an error diagnosis, DiagnosisTimedOut if ctx's
+// deadline passed
+func (s *PredictionService) failDiagnosis(ctx context.Context, span trace.Span, patientID uint, req models.DiagnosisRequest, diagnosis string, err error, onComplete func(uint, string, string)) {
+	span.RecordError(err)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		diagnosis = DiagnosisTimedOut
+		logging.FromContext(ctx).Error("direct llm call timed out", "patient_id", patientID, "timeout_ms", s.LLMTimeout.Milliseconds())
+	} else {
+		logging.FromContext(ctx).Error("direct llm call failed", "patient_id", patientID, "error", err)
+	}
+	s.finishDiagnosis(patientID, req.Generation, diagnosis, "error", onComplete)
+}
+
+// finishDiagnosis stores and announces a result unless a newer diagnosis or
+// a deletion has superseded it
+func (s *PredictionService) finishDiagnosis(patientID uint, gen uint64, diagnosis, status string, onComplete func(uint, string, string)) {
+	stored := s.Diagnoses.Complete(patientID, gen, func() {
+		s.Cache.SetCoded(patientID, s.CodeDiagnosis(patientID, diagnosis, status))
+	})
+	if !stored {
+		slog.Info("discarded stale diagnosis", "patient_id", patientID, "generation", gen)
+		return
+	}
+	if s.Store != nil {
+		if err := s.Store.Save(patientID, gen, diagnosis, status); err != nil {
+			slog.Warn("failed to save diagnosis", "patient_id", patientID, "error", err)
+		}
+	}
 	if onComplete != nil {
-		onComplete(patientID, diagRes.Diagnosis, "ready")
+		onComplete(patientID, diagnosis, status)
+	}
+}
+
+// CodeDiagnosis is a finished diagnosis as cached, with ICD-10 codes
+// suggested from its text and the patient's latest disease predictions.
+// Only ready diagnoses are coded; errors carry no suggestions.
+func (s *PredictionService) CodeDiagnosis(patientID uint, diagnosis, status string) models.CachedDiagnosis {
+	d := models.CachedDiagnosis{Diagnosis: diagnosis, Status: status}
+	if status != "ready" {
+		return d
+	}
+	coder := s.ICD10
+	if coder == nil {
+		coder = defaultICD10Coder
 	}
+	d.ICD10Suggestions = coder.Suggest(diagnosis, s.Cache.Diseases(patientID))
+	return d
 }
 
+// CancelDiagnosis drops the patient's diagnosis and invalidates any still in flight
+func (s *PredictionService) CancelDiagnosis(patientID uint) {
+	s.Diagnoses.Cancel(patientID)
+	s.Cache.Delete(patientID)
+}
+
+// ProbeML sends one prediction straight to the primary ML service. It skips
+// the cache, circuit breaker, shadow and latency stats, so probes never show
+// up in them.
+func (s *PredictionService) ProbeML(ctx context.Context, patient models.PatientData) (*models.PredictResponse, error) {
+	var risks models.PredictResponse
+	if err := s.probe(ctx, "/predict", BuildPredictPayload(patient), &risks); err != nil {
+		return nil, err
+	}
+	return &risks, nil
+}
+
+// ProbeLLM makes one synchronous diagnosis call without touching the diagnosis cache
+func (s *PredictionService) ProbeLLM(ctx context.Context, req models.DiagnosisRequest) (*models.DiagnosisResponse, error) {
+	payload, _ := json.Marshal(req)
+	var diag models.DiagnosisResponse
+	if err := s.probe(ctx, "/diagnose", payload, &diag); err != nil {
+		return nil, err
+	}
+	return &diag, nil
+}
+
+func (s *PredictionService) probe(ctx context.Context, path string, payload []byte, out any) error {
+	req, err := newMLRequest(ctx, s.MLServiceURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", path, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// CheckMedications flags interactions in a comma-separated medication list,
+// using the seeded defaults until Interactions is loaded from the database
 func (s *PredictionService) CheckMedications(medStr string) models.InteractionResult {
-	if medStr == "" {
-		return models.InteractionResult{Risky: []string{}, Safe: []string{}}
+	if s.Interactions == nil {
+		return defaultInteractions.Check(medStr)
 	}
-	
-	meds := strings.Split(medStr, ",")
-	for i, m := range meds {
-		meds[i] = strings.TrimSpace(m)
-	}
-
-	conflicts := []string{"Metformin", "Contrast", "Alcohol", "NSAIDs"}
-	risky := []string{}
-	safe := []string{}
-
-	for _, m := range meds {
-		if m == "" { continue }
-		isRisky := false
-		for _, c := range conflicts {
-			if strings.Contains(strings.ToLower(m), strings.ToLower(c)) {
-				isRisky = true
-				break
-			}
-		}
-		if isRisky {
-			risky = append(risky, m)
-		} else {
-			safe = append(safe, m)
-		}
+	return s.Interactions.Check(medStr)
+}
+
+// CheckAllergies lists medications that conflict with recorded allergies
+func (s *PredictionService) CheckAllergies(medStr, allergyStr string) []models.AllergyConflict {
+	if s.Interactions == nil {
+		return defaultInteractions.CheckAllergies(medStr, allergyStr)
 	}
-	
-	return models.InteractionResult{Risky: risky, Safe: safe}
+	return s.Interactions.CheckAllergies(medStr, allergyStr)
 }
 
 func (s *PredictionService) AnalyzeVitals(filePath string) (*models.VitalsResponse, error) {
+	return s.AnalyzeVitalsCtx(context.Background(), filePath)
+}
+
+// AnalyzeVitalsCtx is AnalyzeVitals bounded by ctx
+func (s *PredictionService) AnalyzeVitalsCtx(ctx context.Context, filePath string) (*models.VitalsResponse, error) {
 	// Call ML API /vitals/analyze?file_path=...
 	url := fmt.Sprintf("%s/vitals/analyze?file_path=%s", s.MLServiceURL, filePath)
 	
-	resp, err := http.Post(url, "application/json", nil)
+	if err := chaos.Inject(chaos.TargetML, "/vitals/analyze"); err != nil {
+		return nil, err
+	}
+	req, err := newMLRequest(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.Client.Do(req)
 	if err != nil {
 		return nil, err
 	}
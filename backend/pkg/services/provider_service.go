@@ -0,0 +1,151 @@
+package services
+
+import (
+	"errors"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrProviderNotFound = errors.New("provider not found")
+	ErrPatientNotFound  = errors.New("patient not found")
+)
+
+// ProviderService manages the clinician directory and patient assignments
+type ProviderService struct {
+	DB *gorm.DB
+}
+
+func NewProviderService(db *gorm.DB) *ProviderService {
+	return &ProviderService{DB: db}
+}
+
+// Create adds an active provider to the directory
+func (s *ProviderService) Create(p *models.Provider) error {
+	p.ID = 0
+	p.Active = true
+	return s.DB.Create(p).Error
+}
+
+// List returns the directory, optionally only providers currently on call
+func (s *ProviderService) List(onCallOnly bool) ([]models.Provider, error) {
+	var providers []models.Provider
+	q := s.DB.Where("active = ?", true)
+	if onCallOnly {
+		q = q.Where("on_call = ?", true)
+	}
+	err := q.Order("name, id").Find(&providers).Error
+	return providers, err
+}
+
+func (s *ProviderService) Get(id uint) (*models.Provider, error) {
+	var p models.Provider
+	if err := s.DB.First(&p, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProviderNotFound
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SetStatus updates a provider's on-call and active flags
+func (s *ProviderService) SetStatus(id uint, onCall, active bool) (*models.Provider, error) {
+	p, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.DB.Model(p).Updates(map[string]any{"on_call": onCall, "active": active}).Error; err != nil {
+		return nil, err
+	}
+	p.OnCall, p.Active = onCall, active
+	return p, nil
+}
+
+// Assign makes the provider responsible for the patient and returns the
+// updated patient along with the previously assigned provider ID, if any
+func (s *ProviderService) Assign(patientID, providerID uint) (*models.PatientData, *uint, error) {
+	provider, err := s.Get(providerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !provider.Active {
+		return nil, nil, ErrProviderNotFound
+	}
+
+	var patient models.PatientData
+	if err := s.DB.First(&patient, patientID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrPatientNotFound
+		}
+		return nil, nil, err
+	}
+
+	previous := patient.AssignedProviderID
+	if err := s.DB.Model(&patient).Update("assigned_provider_id", provider.ID).Error; err != nil {
+		return nil, nil, err
+	}
+	patient.AssignedProviderID = &provider.ID
+	patient.AssignedProvider = provider
+	return &patient, previous, nil
+}
+
+// PatientScope is the set of patients a caller may see
+type PatientScope struct {
+	All        bool // Admins, service credentials and background jobs
+	ProviderID uint // Otherwise only patients assigned to this provider; 0 is none
+}
+
+// Allows reports whether the scope covers the patient
+func (s PatientScope) Allows(p models.PatientData) bool {
+	return s.All || (p.AssignedProviderID != nil && *p.AssignedProviderID == s.ProviderID && s.ProviderID != 0)
+}
+
+// ScopeFor limits doctors to the patients assigned to the provider linked
+// to their account. A doctor without a linked provider sees no patients,
+// and neither does any other role without full access, such as plain
+// users, kiosks and anonymous callers.
+func (s *ProviderService) ScopeFor(actor auditctx.Identity) (PatientScope, error) {
+	switch actor.Role {
+	case auditctx.RoleAdmin, auditctx.RoleService, auditctx.RoleSystem:
+		return PatientScope{All: true}, nil
+	case auditctx.RoleDoctor:
+	default:
+		return PatientScope{}, nil
+	}
+	userID, ok := UserIDOf(actor)
+	if !ok {
+		return PatientScope{}, nil
+	}
+	var p models.Provider
+	if err := s.DB.Where("user_id = ?", userID).Limit(1).Find(&p).Error; err != nil {
+		return PatientScope{}, err
+	}
+	return PatientScope{ProviderID: p.ID}, nil
+}
+
+// EscalationTargets lists who to notify about a patient, in order: the
+// assigned provider first, then everyone on call
+func (s *ProviderService) EscalationTargets(patient models.PatientData) ([]models.Provider, error) {
+	onCall, err := s.List(true)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]models.Provider, 0, len(onCall)+1)
+	if patient.AssignedProviderID != nil {
+		if p, err := s.Get(*patient.AssignedProviderID); err == nil && p.Active {
+			targets = append(targets, *p)
+		}
+	}
+	for _, p := range onCall {
+		if len(targets) > 0 && targets[0].ID == p.ID {
+			continue
+		}
+		targets = append(targets, p)
+	}
+	return targets, nil
+}
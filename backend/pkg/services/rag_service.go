@@ -1,70 +1,349 @@
 package services
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
+	"log/slog"
 	"math"
 	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"healthcare-backend/pkg/cache"
 	"healthcare-backend/pkg/models"
 	"healthcare-backend/pkg/repositories"
 )
 
+// Retrieval defaults; RAG_TOP_K, RAG_MAX_DISTANCE and RAG_MAX_CANDIDATES
+// override them
+const (
+	DefaultRAGTopK          = 3
+	DefaultRAGMaxDistance   = 0.5 // About 20 years, 30 mmHg, 50 mg/dL and 5 BMI apart at once
+	DefaultRAGMaxCandidates = 500
+	DefaultRAGCacheTTL      = 5 * time.Minute
+	DefaultRAGNoteWeight    = 0.3 // Share of the distance taken by notes when embeddings are on
+
+	ragQueryTimeout = 2 * time.Second // Longest wait to embed the patient's symptoms
+)
+
+// RAGWeights scales each feature's part of the similarity distance. Every
+// feature is first normalized so that 1 is as different as it gets: vitals
+// by a typical spread, capped at 1, and categories one-hot, so any two
+// different ones are 1 apart. A weight of 0 ignores the feature.
+type RAGWeights struct {
+	Age, SystolicBP, Glucose, BMI float64
+	Gender, Smoking               float64
+	HeartDisease, Stroke          float64 // History flags
+	Diabetes, HighChol            float64
+}
+
+// DefaultRAGWeights counts each categorical mismatch like about 16 years
+// of age, enough to rank a matching case first without pushing an
+// otherwise close case past DefaultRAGMaxDistance
+var DefaultRAGWeights = RAGWeights{
+	Age: 1, SystolicBP: 1, Glucose: 1, BMI: 1,
+	Gender: 0.2, Smoking: 0.2,
+	HeartDisease: 0.2, Stroke: 0.2, Diabetes: 0.2, HighChol: 0.2,
+}
+
+// fields names the weights by their patient JSON fields
+func (w *RAGWeights) fields() map[string]*float64 {
+	return map[string]*float64{
+		"age": &w.Age, "systolic_bp": &w.SystolicBP, "glucose": &w.Glucose, "bmi": &w.BMI,
+		"gender": &w.Gender, "smoking": &w.Smoking,
+		"history_heart_disease": &w.HeartDisease, "history_stroke": &w.Stroke,
+		"history_diabetes": &w.Diabetes, "history_high_chol": &w.HighChol,
+	}
+}
+
+// ParseRAGWeights reads "gender=0.5,history_stroke=1" over the defaults.
+// Keys are the patient's JSON field names.
+func ParseRAGWeights(s string) (RAGWeights, error) {
+	w := DefaultRAGWeights
+	fields := w.fields()
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		field := fields[strings.TrimSpace(key)]
+		if !ok || field == nil {
+			return w, fmt.Errorf("unknown RAG weight %q (expected name=weight with a name among age, systolic_bp, glucose, bmi, gender, smoking, history_*)", pair)
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || f < 0 || math.IsInf(f, 0) {
+			return w, fmt.Errorf("RAG weight %q must be a number of at least 0", pair)
+		}
+		*field = f
+	}
+	return w, nil
+}
+
+var (
+	ragGenders = []string{"Male", "Female", "Other"}
+	ragSmoking = []string{"Yes", "No", "Former"}
+	ragYesNo   = []string{"Yes", "No"}
+)
+
+// vitalDistance is how far apart two measurements are over a typical spread
+func vitalDistance(a, b, spread float64) float64 {
+	return math.Min(math.Abs(a-b)/spread, 1)
+}
+
+// oneHotDistance compares a and b one-hot encoded over categories, scaled
+// so different categories are 1 apart. A value that isn't one of them,
+// like a field the MCP tool never asks for, has nothing to compare and
+// counts as a match.
+func oneHotDistance(categories []string, a, b string) float64 {
+	x, y := oneHot(categories, a), oneHot(categories, b)
+	if x == nil || y == nil {
+		return 0
+	}
+	sum := 0.0
+	for i := range x {
+		sum += (x[i] - y[i]) * (x[i] - y[i])
+	}
+	return math.Sqrt(sum / 2)
+}
+
+func oneHot(categories []string, value string) []float64 {
+	for i, c := range categories {
+		if strings.EqualFold(strings.TrimSpace(value), c) {
+			v := make([]float64, len(categories))
+			v[i] = 1
+			return v
+		}
+	}
+	return nil
+}
+
+// distance is the weighted Euclidean distance between two patients
+func (w RAGWeights) distance(a, b models.PatientData) float64 {
+	// Spreads: Age (0-100), SystolicBP (90-200), Glucose (70-300), BMI (15-50)
+	parts := []float64{
+		w.Age * vitalDistance(float64(a.Age), float64(b.Age), 80),
+		w.SystolicBP * vitalDistance(float64(a.SystolicBP), float64(b.SystolicBP), 100),
+		w.Glucose * vitalDistance(float64(a.Glucose), float64(b.Glucose), 200),
+		w.BMI * vitalDistance(a.BMI, b.BMI, 35),
+		w.Gender * oneHotDistance(ragGenders, a.Gender, b.Gender),
+		w.Smoking * oneHotDistance(ragSmoking, a.Smoking, b.Smoking),
+		w.HeartDisease * oneHotDistance(ragYesNo, a.HistoryHeartDisease, b.HistoryHeartDisease),
+		w.Stroke * oneHotDistance(ragYesNo, a.HistoryStroke, b.HistoryStroke),
+		w.Diabetes * oneHotDistance(ragYesNo, a.HistoryDiabetes, b.HistoryDiabetes),
+		w.HighChol * oneHotDistance(ragYesNo, a.HistoryHighChol, b.HistoryHighChol),
+	}
+	sum := 0.0
+	for _, d := range parts {
+		sum += d * d
+	}
+	return math.Sqrt(sum)
+}
+
 type RAGService struct {
 	PatientRepo  repositories.PatientRepository
 	FeedbackRepo repositories.FeedbackRepository
+
+	K             int           // Most cases returned
+	MaxDistance   float64       // Cases further away are left out; 0 keeps every case
+	Weights       RAGWeights    // How much each feature counts towards the distance
+	MaxCandidates int           // Only the most recent approved cases are compared; 0 is all
+	CacheTTL      time.Duration // How long Redis keeps results; 0 doesn't cache
+
+	// Notes, when set, compares the patient's symptoms with the embedded
+	// notes of each case; NoteWeight of the distance comes from that and
+	// the rest from the features above. Nil compares features only.
+	Notes      *NoteEmbeddingService
+	NoteWeight float64
 }
 
 func NewRAGService(patientRepo repositories.PatientRepository, feedbackRepo repositories.FeedbackRepository) *RAGService {
 	return &RAGService{
 		PatientRepo:  patientRepo,
 		FeedbackRepo: feedbackRepo,
+		K:            DefaultRAGTopK,
+		MaxDistance:  DefaultRAGMaxDistance,
+		Weights:       DefaultRAGWeights,
+		MaxCandidates: DefaultRAGMaxCandidates,
+		CacheTTL:      DefaultRAGCacheTTL,
+		NoteWeight:    DefaultRAGNoteWeight,
 	}
 }
 
-type ScoredFeedback struct {
-	Feedback models.Feedback
-	Score    float64 // Lower is better (distance)
+// SimilarCase is an approved past case close to the patient, nearest first
+type SimilarCase struct {
+	PatientID uint    `json:"patient_id"`
+	Distance  float64 `json:"distance"` // Weighted distance over vitals, gender, smoking and history, blended with notes when embedded
+	Notes     string  `json:"notes"`
 }
 
-func (s *RAGService) FindSimilarCases(patient models.PatientData) string {
-	approvedFeedbacks, err := s.FeedbackRepo.GetApproved()
+// FindSimilarCases returns up to K approved cases within MaxDistance of the
+// patient, formatted for the LLM's past context and as data. Feedback on
+// excludePatientID, usually the patient being assessed, is skipped so a
+// patient's own history isn't offered as a similar case; 0 skips nothing.
+func (s *RAGService) FindSimilarCases(patient models.PatientData, excludePatientID uint) (string, []SimilarCase) {
+	nearby, err := s.nearbyCases(patient)
 	if err != nil {
-		return "Error fetching past cases."
-	}
-
-	var scored []ScoredFeedback
-
-	for _, f := range approvedFeedbacks {
-		// Fetch associated patient data
-		histP, err := s.PatientRepo.GetByID(f.PatientID)
-		if err == nil {
-			// Calculate Normalized Euclidean Distance
-			// Features: Age (0-100), SystolicBP (90-200), Glucose (70-300), BMI (15-50)
-			
-			dAge := float64(patient.Age - histP.Age) / 80.0
-			dBP := float64(patient.SystolicBP - histP.SystolicBP) / 100.0
-			dGluc := float64(patient.Glucose - histP.Glucose) / 200.0
-			dBMI := (patient.BMI - histP.BMI) / 35.0
-			
-			dist := math.Sqrt(dAge*dAge + dBP*dBP + dGluc*dGluc + dBMI*dBMI)
-			scored = append(scored, ScoredFeedback{Feedback: f, Score: dist})
+		return "Error fetching past cases.", nil
+	}
+
+	k := s.K
+	if k <= 0 {
+		k = DefaultRAGTopK
+	}
+	cases := []SimilarCase{}
+	for _, c := range nearby {
+		if len(cases) == k {
+			break
+		}
+		if excludePatientID != 0 && c.PatientID == excludePatientID {
+			continue
 		}
+		cases = append(cases, c)
 	}
-	
-	// Sort by nearest neighbors (lowest distance)
-	sort.Slice(scored, func(i, j int) bool {
-		return scored[i].Score < scored[j].Score
+	return formatSimilarCases(cases), cases
+}
+
+// nearbyCases returns every candidate within MaxDistance, nearest first.
+// They don't depend on who is excluded, so patients with the same features
+// share them in Redis for CacheTTL.
+func (s *RAGService) nearbyCases(patient models.PatientData) ([]SimilarCase, error) {
+	key := s.cacheKey(patient)
+	if s.CacheTTL > 0 {
+		if cached, err := cache.Get(key); err == nil {
+			var cases []SimilarCase
+			if err := json.Unmarshal([]byte(cached), &cases); err == nil {
+				return cases, nil
+			}
+		}
+	}
+
+	candidates, err := s.FeedbackRepo.GetApprovedWithPatients(s.MaxCandidates)
+	if err != nil {
+		return nil, err
+	}
+	query, vectors, err := s.noteVectors(patient, candidates)
+	if err != nil {
+		// Features alone still find cases; don't cache the lesser result
+		slog.Debug("RAG falling back to features only", "error", err)
+	}
+	cases := []SimilarCase{}
+	for _, c := range candidates {
+		dist := s.Weights.distance(patient, c.Patient)
+		if query != nil {
+			dist = (1-s.NoteWeight)*dist + s.NoteWeight*noteDistance(query, vectors[c.ID])
+		}
+		if s.MaxDistance > 0 && dist > s.MaxDistance {
+			continue
+		}
+		cases = append(cases, SimilarCase{PatientID: c.PatientID, Distance: dist, Notes: c.DoctorNotes})
+	}
+	// Nearest first; ties keep the newer case first
+	sort.SliceStable(cases, func(i, j int) bool {
+		return cases[i].Distance < cases[j].Distance
 	})
-	
-	// Take top 3 relevant cases
+
+	if s.CacheTTL > 0 && err == nil {
+		if data, err := json.Marshal(cases); err == nil {
+			cache.Set(key, data, s.CacheTTL)
+		}
+	}
+	return cases, nil
+}
+
+// notesEnabled reports whether notes count towards the patient's distances
+func (s *RAGService) notesEnabled(patient models.PatientData) bool {
+	return s.Notes != nil && s.NoteWeight > 0 && strings.TrimSpace(patient.Symptoms) != ""
+}
+
+// noteVectors embeds the patient's symptoms and loads the candidates' note
+// vectors. A nil query, with or without an error, means notes don't count.
+func (s *RAGService) noteVectors(patient models.PatientData, candidates []repositories.ApprovedCase) ([]float32, map[uint][]float32, error) {
+	if !s.notesEnabled(patient) || len(candidates) == 0 {
+		return nil, nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ragQueryTimeout)
+	defer cancel()
+	query, err := s.Notes.EmbedQuery(ctx, patient.Symptoms)
+	if err != nil {
+		return nil, nil, err
+	}
+	ids := make([]uint, len(candidates))
+	for i, c := range candidates {
+		ids[i] = c.ID
+	}
+	vectors, err := s.Notes.Vectors(ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	return query, vectors, nil
+}
+
+// noteDistance maps cosine similarity onto 0 (same meaning) to 1
+// (opposite). Notes not embedded yet sit halfway, neither near nor far.
+func noteDistance(query, note []float32) float64 {
+	sim, ok := cosine(query, note)
+	if !ok {
+		return 0.5
+	}
+	return (1 - sim) / 2
+}
+
+// cacheKey hashes the features the distance reads and the settings that
+// shape the result, so differently configured servers don't share entries
+func (s *RAGService) cacheKey(p models.PatientData) string {
+	features := fmt.Sprintf("%d|%d|%d|%g|%s|%s|%s|%s|%s|%s|%g|%d|%+v",
+		p.Age, p.SystolicBP, p.Glucose, p.BMI, p.Gender, p.Smoking,
+		p.HistoryHeartDisease, p.HistoryStroke, p.HistoryDiabetes, p.HistoryHighChol,
+		s.MaxDistance, s.MaxCandidates, s.Weights)
+	if s.notesEnabled(p) {
+		features += fmt.Sprintf("|%g|%s|%s", s.NoteWeight, s.Notes.Model, p.Symptoms)
+	}
+	return fmt.Sprintf("rag:%x", sha256.Sum256([]byte(features)))
+}
+
+// formatSimilarCases renders cases as the LLM's past context
+func formatSimilarCases(cases []SimilarCase) string {
 	contextStr := "PAST SIMILAR CLINICAL CASES (RAG):\n"
-	if len(scored) == 0 {
+	if len(cases) == 0 {
 		contextStr += "None available.\n"
 	}
-	
-	for i := 0; i < len(scored) && i < 3; i++ {
-		f := scored[i].Feedback
-		contextStr += fmt.Sprintf("- Similar Case (Dist: %.2f): %s\n", scored[i].Score, f.DoctorNotes)
+	for _, c := range cases {
+		contextStr += fmt.Sprintf("- Similar Case (Dist: %.2f): %s\n", c.Distance, c.Notes)
 	}
-	
 	return contextStr
 }
+
+// RAGIndexStats describes the approved cases retrieval can draw on
+type RAGIndexStats struct {
+	Approved int `json:"approved"`           // Approved feedback entries
+	Indexed  int `json:"indexed"`            // Of those, cases whose patient still exists
+	Orphaned int `json:"orphaned"`           // Approved feedback whose patient is gone
+	Embedded int `json:"embedded,omitempty"` // Notes embedded by this run
+}
+
+// Reindex reports which approved cases retrieval can use, with the same
+// query FindSimilarCases reads them with, uncapped. Retrieval reads the
+// corpus on every uncached call, so the only thing persisted is note
+// vectors: with Notes set, notes missing one are embedded first.
+func (s *RAGService) Reindex() (RAGIndexStats, error) {
+	var stats RAGIndexStats
+	if s.Notes != nil {
+		n, err := s.Notes.Backfill(context.Background())
+		if err != nil {
+			return RAGIndexStats{}, fmt.Errorf("embedding notes (%d done): %w", n, err)
+		}
+		stats.Embedded = n
+	}
+	approved, err := s.FeedbackRepo.GetApproved()
+	if err != nil {
+		return RAGIndexStats{}, err
+	}
+	usable, err := s.FeedbackRepo.GetApprovedWithPatients(0)
+	if err != nil {
+		return RAGIndexStats{}, err
+	}
+	stats.Approved, stats.Indexed, stats.Orphaned = len(approved), len(usable), len(approved)-len(usable)
+	return stats, nil
+}
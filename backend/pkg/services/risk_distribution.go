@@ -0,0 +1,67 @@
+package services
+
+import (
+	"fmt"
+	"math"
+
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/repositories"
+
+	"gorm.io/gorm"
+)
+
+// DefaultRiskThresholds level the highest risk score: under 40 Low, under
+// 70 Medium, High from 70
+var DefaultRiskThresholds = models.RiskThresholds{Medium: 0.40 * models.ScoreScale, High: 0.70 * models.ScoreScale}
+
+// ValidateRiskThresholds checks the thresholds rise within the score scale
+func ValidateRiskThresholds(t models.RiskThresholds) error {
+	if t.Medium <= 0 || t.Medium >= t.High || t.High > models.ScoreScale {
+		return fmt.Errorf("risk thresholds must satisfy 0 < medium (%.1f) < high (%.1f) <= %.0f", t.Medium, t.High, models.ScoreScale)
+	}
+	return nil
+}
+
+// RiskDistribution levels every patient by the highest of the four risk
+// scores of their latest ML assessment. Patients the ML models never
+// scored, only the rule-based fallback or nothing at all, are leveled by
+// systolic BP instead: High above 160 (the patient list's high-risk
+// filter), Medium from 140.
+func RiskDistribution(db *gorm.DB, t models.RiskThresholds) (models.RiskMethods, error) {
+	methods := models.RiskMethods{
+		MLScore:    map[string]int64{"Low": 0, "Medium": 0, "High": 0},
+		SystolicBP: map[string]int64{"Low": 0, "Medium": 0, "High": 0},
+		Thresholds: t,
+	}
+
+	patients := db.Model(&models.PatientData{}).Select("id")
+	latest := db.Model(&models.Assessment{}).Select("MAX(id)").
+		Where("rule_based = ? AND patient_id IN (?)", false, patients).
+		Group("patient_id")
+	var scored []models.Assessment
+	err := db.Select("heart_risk", "diabetes_risk", "stroke_risk", "kidney_risk").
+		Where("id IN (?)", latest).
+		Find(&scored).Error
+	if err != nil {
+		return methods, err
+	}
+	for _, a := range scored {
+		score := math.Max(math.Max(a.HeartRisk, a.DiabetesRisk), math.Max(a.StrokeRisk, a.KidneyRisk))
+		methods.MLScore[t.Level(score)]++
+	}
+
+	mlScored := db.Model(&models.Assessment{}).Select("patient_id").Where("rule_based = ?", false)
+	for level, cond := range map[string]string{
+		"Low":    "systolic_bp < 140",
+		"Medium": "systolic_bp >= 140 AND NOT (" + repositories.HighRiskCondition + ")",
+		"High":   repositories.HighRiskCondition,
+	} {
+		var n int64
+		err := db.Model(&models.PatientData{}).Where("id NOT IN (?)", mlScored).Where(cond).Count(&n).Error
+		if err != nil {
+			return methods, err
+		}
+		methods.SystolicBP[level] = n
+	}
+	return methods, nil
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"healthcare-backend/pkg/database"
+	"healthcare-backend/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+	searchSnippetWords = 12
+)
+
+var (
+	ErrEmptySearch        = errors.New("search needs at least one word")
+	ErrSearchIndexMissing = errors.New("search index missing; run migrations")
+)
+
+// SearchQuery is a full-text search over doctor notes and diagnoses
+type SearchQuery struct {
+	Text    string
+	Sources []string      // models.SearchFeedback, models.SearchDiagnosis; empty is both
+	Scope   *PatientScope // Limits hits to these patients; nil is everyone
+	Limit   int
+}
+
+// SearchHit is one matching note or diagnosis. Snippet is the best
+// matching passage with the terms in [brackets]. Rank orders hits, higher
+// first; it's only comparable within one search.
+type SearchHit struct {
+	Source       string  `json:"source"`
+	SourceID     uint    `json:"source_id"`
+	PatientID    uint    `json:"patient_id"`
+	AssessmentID uint    `json:"assessment_id,omitempty"`
+	Snippet      string  `json:"snippet"`
+	Rank         float64 `json:"rank"`
+}
+
+// SearchService searches the text copied into search_documents, with
+// SQLite FTS5 (FTS4 when the driver lacks it) or Postgres text search.
+// All of them stem English words, so "ache" also finds "aching".
+type SearchService struct {
+	DB *gorm.DB
+}
+
+func NewSearchService(db *gorm.DB) *SearchService {
+	return &SearchService{DB: db}
+}
+
+// searchTerms splits text into words, dropping the punctuation FTS query
+// syntax would read as operators
+func searchTerms(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+}
+
+// Search returns the documents containing every word of the query, best first
+func (s *SearchService) Search(q SearchQuery) ([]SearchHit, error) {
+	terms := searchTerms(q.Text)
+	if len(terms) == 0 {
+		return nil, ErrEmptySearch
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultSearchLimit
+	}
+
+	var hits []SearchHit
+	var err error
+	if s.DB.Dialector.Name() == database.DriverPostgres {
+		hits, err = s.searchPostgres(terms, q)
+	} else {
+		hits, err = s.searchSQLite(terms, q)
+	}
+	if hits == nil {
+		hits = []SearchHit{}
+	}
+	return hits, err
+}
+
+// filter narrows a search_documents query, aliased d, to the wanted
+// sources and patients
+func (s *SearchService) filter(db *gorm.DB, q SearchQuery) *gorm.DB {
+	if len(q.Sources) > 0 {
+		db = db.Where("d.source IN ?", q.Sources)
+	}
+	if q.Scope != nil && !q.Scope.All {
+		db = db.Where("d.patient_id IN (?)", s.DB.Model(&models.PatientData{}).Select("id").Where("assigned_provider_id = ?", q.Scope.ProviderID))
+	}
+	return db
+}
+
+func (s *SearchService) searchPostgres(terms []string, q SearchQuery) ([]SearchHit, error) {
+	const headline = "StartSel=[, StopSel=], MinWords=5, MaxWords=24"
+	text := strings.Join(terms, " ")
+	var hits []SearchHit
+	db := s.DB.Table("search_documents d").
+		Select("d.source, d.source_id, d.patient_id, d.assessment_id, "+
+			"ts_headline('english', d.body, plainto_tsquery('english', ?), ?) AS snippet, "+
+			"ts_rank(to_tsvector('english', d.body), plainto_tsquery('english', ?)) AS rank", text, headline, text).
+		Where("to_tsvector('english', d.body) @@ plainto_tsquery('english', ?)", text)
+	err := s.filter(db, q).Order("rank DESC, d.id DESC").Limit(q.Limit).Scan(&hits).Error
+	return hits, err
+}
+
+func (s *SearchService) searchSQLite(terms []string, q SearchQuery) ([]SearchHit, error) {
+	module, err := database.SearchIndexModule(s.DB)
+	if err != nil {
+		return nil, err
+	}
+	if module == "" {
+		return nil, ErrSearchIndexMissing
+	}
+
+	// Every term quoted, so they're all required and none is an operator
+	quoted := make([]string, len(terms))
+	for i, t := range terms {
+		quoted[i] = `"` + t + `"`
+	}
+	match := strings.Join(quoted, " ")
+
+	if module == "fts5" {
+		var hits []SearchHit
+		db := s.DB.Table("search_fts").
+			Select("d.source, d.source_id, d.patient_id, d.assessment_id, "+
+				"snippet(search_fts, 0, '[', ']', '…', ?) AS snippet, -bm25(search_fts) AS rank", searchSnippetWords).
+			Joins("JOIN search_documents d ON d.id = search_fts.rowid").
+			Where("search_fts MATCH ?", match)
+		err := s.filter(db, q).Order("bm25(search_fts), d.id DESC").Limit(q.Limit).Scan(&hits).Error
+		return hits, err
+	}
+
+	// FTS4 has no ranking function, only the statistics to compute one
+	var rows []struct {
+		SearchHit
+		MatchInfo []byte
+	}
+	db := s.DB.Table("search_fts").
+		Select("d.source, d.source_id, d.patient_id, d.assessment_id, "+
+			"snippet(search_fts, '[', ']', '…', -1, ?) AS snippet, matchinfo(search_fts, 'pcnalx') AS match_info", searchSnippetWords).
+		Joins("JOIN search_documents d ON d.id = search_fts.docid").
+		Where("search_fts MATCH ?", match)
+	if err := s.filter(db, q).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	hits := make([]SearchHit, len(rows))
+	for i, r := range rows {
+		hits[i] = r.SearchHit
+		hits[i].Rank = bm25(r.MatchInfo)
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Rank > hits[j].Rank })
+	if len(hits) > q.Limit {
+		hits = hits[:q.Limit]
+	}
+	return hits, nil
+}
+
+// bm25 scores a row from FTS4's matchinfo 'pcnalx' statistics with Okapi
+// BM25 and the usual parameters, so FTS4 ranks much as FTS5's bm25() does
+func bm25(info []byte) float64 {
+	const k1, b = 1.2, 0.75
+	n := len(info) / 4
+	at := func(i int) float64 {
+		if i >= n {
+			return 0
+		}
+		return float64(binary.NativeEndian.Uint32(info[i*4:]))
+	}
+	phrases, cols := int(at(0)), int(at(1))
+	docs := at(2)
+	avg, length := 3, 3+cols // Average and this row's length, per column
+	x := 3 + 2*cols          // Per phrase and column: hits here, hits everywhere, rows with a hit
+
+	score := 0.0
+	for p := 0; p < phrases; p++ {
+		for c := 0; c < cols; c++ {
+			i := x + 3*(p*cols+c)
+			tf, withHit := at(i), at(i+2)
+			if tf == 0 {
+				continue
+			}
+			idf := math.Log((docs-withHit+0.5)/(withHit+0.5) + 1)
+			norm := 1 - b + b*at(length+c)/math.Max(at(avg+c), 1)
+			score += idf * tf * (k1 + 1) / (tf + k1*norm)
+		}
+	}
+	return score
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/blockchain"
+	"healthcare-backend/pkg/middleware"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/repositories"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Self-test stage names, in the order they run
+const (
+	StageValidation = "validation"
+	StageRuleBased  = "rule_based_prediction"
+	StageML         = "ml_prediction"
+	StageMedication = "medication_analysis"
+	StageRAG        = "rag_context"
+	StageAudit      = "audit_log"
+	StageLLM        = "llm"
+)
+
+var errStageSkipped = errors.New("skipped")
+
+// selfTestPatient is synthetic and exists only in the scratch database
+var selfTestPatient = models.PatientData{
+	Name: "Self Test", Age: 58, Gender: "Male",
+	SystolicBP: 150, DiastolicBP: 95, Glucose: 140, BMI: 31, Cholesterol: 230, HeartRate: 82, Steps: 4000,
+	Smoking: "No", Alcohol: "No",
+	HistoryHeartDisease: "No", HistoryStroke: "No", HistoryDiabetes: "No", HistoryHighChol: "Yes",
+	Medications: "Metformin, Lisinopril",
+	Symptoms:    "chest pain",
+}
+
+// selfTestCorpus is the canned RAG corpus; the first case is the expected nearest neighbour
+var selfTestCorpus = []struct {
+	patient models.PatientData
+	notes   string
+}{
+	{models.PatientData{Age: 60, SystolicBP: 152, Glucose: 138, BMI: 30}, "selftest: hypertensive, started ACE inhibitor"},
+	{models.PatientData{Age: 25, SystolicBP: 110, Glucose: 85, BMI: 21}, "selftest: healthy young adult, no action"},
+	{models.PatientData{Age: 80, SystolicBP: 190, Glucose: 300, BMI: 40}, "selftest: hypertensive crisis, admitted"},
+}
+
+// SelfTestService runs a synthetic patient through every pipeline stage.
+// Everything it writes goes to a scratch in-memory database and ledger that
+// are discarded afterwards, and ML calls bypass the cache, shadow and
+// latency stats, so a self-test never shows up in analytics.
+type SelfTestService struct {
+	Prediction *PredictionService
+	MLTimeout  time.Duration
+	LLMTimeout time.Duration
+}
+
+func NewSelfTestService(pred *PredictionService) *SelfTestService {
+	return &SelfTestService{Prediction: pred, MLTimeout: 3 * time.Second, LLMTimeout: 5 * time.Second}
+}
+
+// Run executes every stage and always cleans up, even when a stage fails
+func (s *SelfTestService) Run(ctx context.Context) models.SelfTestReport {
+	report := models.SelfTestReport{StartedAt: time.Now(), Stages: []models.SelfTestStage{}}
+	stage := func(name string, fn func() (string, error)) bool {
+		start := time.Now()
+		detail, err := fn()
+		st := models.SelfTestStage{Name: name, Status: models.SelfTestPass, Detail: detail, DurationMs: time.Since(start).Milliseconds()}
+		switch {
+		case errors.Is(err, errStageSkipped):
+			st.Status = models.SelfTestSkip
+			st.Error = err.Error()
+		case err != nil:
+			st.Status = models.SelfTestFail
+			st.Error = err.Error()
+		}
+		report.Stages = append(report.Stages, st)
+		return st.Status == models.SelfTestPass
+	}
+
+	patient := selfTestPatient
+
+	stage(StageValidation, func() (string, error) {
+		if errs := middleware.ValidateStruct(patient); len(errs) > 0 {
+			return "", fmt.Errorf("synthetic patient rejected: %s %s", errs[0].Field, errs[0].Message)
+		}
+		return "synthetic patient accepted", nil
+	})
+
+	var risks *models.PredictResponse
+	stage(StageRuleBased, func() (string, error) {
+		risks = s.Prediction.RuleBasedPredictRisks(patient)
+		if risks == nil || risks.HeartRisk <= 0 {
+			return "", errors.New("rule-based fallback returned no heart risk")
+		}
+		return fmt.Sprintf("heart risk %.2f", risks.HeartRisk), nil
+	})
+
+	mlUp := stage(StageML, func() (string, error) {
+		mlCtx, cancel := context.WithTimeout(ctx, s.MLTimeout)
+		defer cancel()
+		live, err := s.Prediction.ProbeML(mlCtx, patient)
+		if err != nil {
+			return "rule-based fallback would serve assessments", fmt.Errorf("%w: ML service unavailable: %v", errStageSkipped, err)
+		}
+		risks = live
+		return fmt.Sprintf("heart risk %.2f, %d models", live.HeartRisk, len(live.ModelPrecisions)), nil
+	})
+
+	stage(StageMedication, func() (string, error) {
+		meds := s.Prediction.CheckMedications(patient.Medications)
+		if len(meds.Risky) != 1 || meds.Risky[0] != "Metformin" {
+			return "", fmt.Errorf("expected Metformin flagged as risky, got %v", meds.Risky)
+		}
+		return fmt.Sprintf("%d risky, %d safe", len(meds.Risky), len(meds.Safe)), nil
+	})
+
+	scratch, err := openScratchDB()
+	if err != nil {
+		stage(StageRAG, func() (string, error) { return "", fmt.Errorf("scratch database: %w", err) })
+		stage(StageAudit, func() (string, error) { return "", fmt.Errorf("scratch database: %w", err) })
+		stage(StageLLM, func() (string, error) { return "", fmt.Errorf("%w: no RAG context", errStageSkipped) })
+	} else {
+		var ragContext string
+		stage(StageRAG, func() (string, error) {
+			ragContext, err = s.runRAG(scratch, patient)
+			if err != nil {
+				return "", err
+			}
+			return "nearest canned case found", nil
+		})
+		stage(StageAudit, func() (string, error) { return runScratchAudit(scratch) })
+
+		stage(StageLLM, func() (string, error) {
+			if !mlUp {
+				return "", fmt.Errorf("%w: ML service unavailable", errStageSkipped)
+			}
+			llmCtx, cancel := context.WithTimeout(ctx, s.LLMTimeout)
+			defer cancel()
+			llmPatient := patient
+			llmPatient.Name = ""
+			diag, err := s.Prediction.ProbeLLM(llmCtx, models.DiagnosisRequest{Patient: llmPatient, RiskScores: *risks, PastContext: ragContext})
+			if err != nil {
+				return "", err
+			}
+			if strings.TrimSpace(diag.Diagnosis) == "" {
+				return "", errors.New("empty diagnosis")
+			}
+			return "status " + diag.Status, nil
+		})
+
+		if sqlDB, err := scratch.DB(); err == nil {
+			report.CleanedUp = sqlDB.Close() == nil
+		}
+	}
+
+	report.Passed = true
+	for _, st := range report.Stages {
+		if st.Status == models.SelfTestFail {
+			report.Passed = false
+		}
+	}
+	report.DurationMs = time.Since(report.StartedAt).Milliseconds()
+	return report
+}
+
+// openScratchDB opens a private in-memory database with the corpus tables
+func openScratchDB() (*gorm.DB, error) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(1) // Every connection to :memory: is a new database
+	if err := db.AutoMigrate(&models.PatientData{}, &models.Feedback{}, &models.AuditLog{}); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func (s *SelfTestService) runRAG(db *gorm.DB, patient models.PatientData) (string, error) {
+	for _, c := range selfTestCorpus {
+		p := c.patient
+		if err := db.Create(&p).Error; err != nil {
+			return "", err
+		}
+		if err := db.Create(&models.Feedback{PatientID: p.ID, DoctorApproved: true, DoctorNotes: c.notes}).Error; err != nil {
+			return "", err
+		}
+	}
+
+	rag := NewRAGService(repositories.NewPatientRepository(db), repositories.NewFeedbackRepository(db))
+	rag.CacheTTL = 0 // The scratch corpus must never reach the shared cache
+	found, _ := rag.FindSimilarCases(patient, patient.ID)
+	if !strings.Contains(found, selfTestCorpus[0].notes) {
+		return found, errors.New("nearest canned case missing from RAG context")
+	}
+	return found, nil
+}
+
+// runScratchAudit writes and verifies events on a throwaway chain, never the live ledger
+func runScratchAudit(db *gorm.DB) (string, error) {
+	chain := &blockchain.Blockchain{Chain: []blockchain.Block{blockchain.GenesisBlock()}}
+	audit := NewAuditServiceOnChain(db, chain)
+	actor := auditctx.Identity{ID: "selftest", Role: auditctx.RoleSystem}
+	for _, event := range []string{"SELFTEST_STARTED", "SELFTEST_PREDICTION"} {
+		if _, err := audit.LogEvent(event, 0, map[string]string{"stage": event}, actor); err != nil {
+			return "", err
+		}
+	}
+	ok, n, err := audit.VerifyChain()
+	if !ok {
+		return "", fmt.Errorf("scratch audit chain invalid: %v", err)
+	}
+	if !chain.IsChainValid() {
+		return "", errors.New("scratch ledger invalid")
+	}
+	return fmt.Sprintf("%d entries verified", n), nil
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"time"
+
+	"healthcare-backend/pkg/jobs"
+	"healthcare-backend/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// ShadowService mirrors live predictions to a candidate ML deployment for offline
+// evaluation. Results are stored but never returned to the user, and calls run on
+// their own job runner and HTTP client so a slow shadow cannot affect the primary.
+type ShadowService struct {
+	URL       string
+	Tolerance float64 // Max per-risk delta still counted as agreement
+	DB        *gorm.DB
+	Client    *http.Client
+	Runner    *jobs.Runner
+}
+
+func NewShadowService(db *gorm.DB, url string, tolerance float64, runner *jobs.Runner) *ShadowService {
+	return &ShadowService{
+		URL:       url,
+		Tolerance: tolerance,
+		DB:        db,
+		Client:    NewMLClient(10 * time.Second),
+		Runner:    runner,
+	}
+}
+
+// Compare schedules a shadow call with the same payload the primary received
+func (s *ShadowService) Compare(patientID uint, payload []byte, primary models.PredictResponse) {
+	s.Runner.Submit(func(ctx context.Context) {
+		s.run(ctx, patientID, payload, primary)
+	})
+}
+
+func (s *ShadowService) run(ctx context.Context, patientID uint, payload []byte, primary models.PredictResponse) {
+	start := time.Now()
+	comparison := models.ShadowComparison{
+		PatientID:      patientID,
+		PrimaryBackend: primary.Backend,
+		PrimaryHeart:   primary.HeartRisk,
+		PrimaryDiab:    primary.DiabetesRisk,
+		PrimaryStroke:  primary.StrokeRisk,
+		PrimaryKidney:  primary.KidneyRisk,
+	}
+
+	shadow, err := s.predict(ctx, payload)
+	comparison.ShadowLatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		comparison.ShadowError = err.Error()
+	} else {
+		comparison.ShadowHeart = shadow.HeartRisk
+		comparison.ShadowDiab = shadow.DiabetesRisk
+		comparison.ShadowStroke = shadow.StrokeRisk
+		comparison.ShadowKidney = shadow.KidneyRisk
+		comparison.DeltaHeart = shadow.HeartRisk - primary.HeartRisk
+		comparison.DeltaDiab = shadow.DiabetesRisk - primary.DiabetesRisk
+		comparison.DeltaStroke = shadow.StrokeRisk - primary.StrokeRisk
+		comparison.DeltaKidney = shadow.KidneyRisk - primary.KidneyRisk
+		comparison.MaxAbsDelta = math.Max(
+			math.Max(math.Abs(comparison.DeltaHeart), math.Abs(comparison.DeltaDiab)),
+			math.Max(math.Abs(comparison.DeltaStroke), math.Abs(comparison.DeltaKidney)),
+		)
+		comparison.Agree = comparison.MaxAbsDelta <= s.Tolerance
+	}
+
+	if err := s.DB.Create(&comparison).Error; err != nil {
+		slog.Warn("shadow: failed to store comparison", "patient_id", patientID, "error", err)
+	}
+}
+
+func (s *ShadowService) predict(ctx context.Context, payload []byte) (*models.PredictResponse, error) {
+	req, err := newMLRequest(ctx, s.URL+"/predict", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("shadow ML API returned status %d", resp.StatusCode)
+	}
+
+	var risks models.PredictResponse
+	if err := json.NewDecoder(resp.Body).Decode(&risks); err != nil {
+		return nil, err
+	}
+	return &risks, nil
+}
+
+// Report summarizes agreement between primary and shadow since the given time
+func (s *ShadowService) Report(since time.Time, limit int) (*models.ShadowReport, error) {
+	report := &models.ShadowReport{Since: since, Tolerance: s.Tolerance}
+
+	err := s.DB.Model(&models.ShadowComparison{}).
+		Select("COUNT(*) AS total, "+
+			"SUM(CASE WHEN shadow_error <> '' THEN 1 ELSE 0 END) AS errors, "+
+			"SUM(CASE WHEN shadow_error = '' AND agree THEN 1 ELSE 0 END) AS agreed, "+
+			"COALESCE(AVG(CASE WHEN shadow_error = '' THEN ABS(delta_heart) END), 0) AS mean_abs_delta_heart, "+
+			"COALESCE(AVG(CASE WHEN shadow_error = '' THEN ABS(delta_diab) END), 0) AS mean_abs_delta_diabetes, "+
+			"COALESCE(AVG(CASE WHEN shadow_error = '' THEN ABS(delta_stroke) END), 0) AS mean_abs_delta_stroke, "+
+			"COALESCE(AVG(CASE WHEN shadow_error = '' THEN ABS(delta_kidney) END), 0) AS mean_abs_delta_kidney").
+		Where("created_at >= ?", since).
+		Scan(report).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if compared := report.Total - report.Errors; compared > 0 {
+		report.AgreementRate = float64(report.Agreed) / float64(compared)
+	}
+
+	report.LargestDisagreements = []models.ShadowComparison{}
+	err = s.DB.Where("created_at >= ? AND shadow_error = ''", since).
+		Order("max_abs_delta desc").
+		Limit(limit).
+		Find(&report.LargestDisagreements).Error
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"healthcare-backend/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// Timeline event types
+const (
+	TimelineAssessment = "assessment"
+	TimelineAudit      = "audit"
+	TimelineFeedback   = "feedback"
+	TimelineOverride   = "override"
+)
+
+const (
+	DefaultTimelinePageSize = 50
+	MaxTimelinePageSize     = 200
+	timelineNoteLength      = 120 // Doctor notes are cut to this many characters in summaries
+)
+
+var ErrInvalidTimelineCursor = errors.New("invalid timeline cursor")
+
+// TimelineAuditEvents are the audit events shown on a patient's timeline,
+// with their summaries. Predictions, feedback and overrides have their own
+// rows and read access is left to the access history, so neither is listed.
+var TimelineAuditEvents = map[string]string{
+	"PATIENT_CREATED":    "Patient record created",
+	"PATIENT_IMPORTED":   "Patient record imported",
+	"PATIENT_UPDATED":    "Patient record updated",
+	"PATIENT_ASSIGNED":   "Assigned to a provider",
+	"PATIENT_REASSIGNED": "Reassigned to another provider",
+	"DIAGNOSIS_REQUEUED": "Failed diagnosis requeued",
+}
+
+// TimelineEvent is one entry on a patient's timeline. RefID is the ID of
+// the row behind it in the table its type names.
+type TimelineEvent struct {
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	Summary   string    `json:"summary"`
+	RefID     uint      `json:"ref_id"`
+}
+
+// before orders events newest first, then by type and ID so ties are stable
+func (e TimelineEvent) before(o TimelineEvent) bool {
+	if !e.Timestamp.Equal(o.Timestamp) {
+		return e.Timestamp.After(o.Timestamp)
+	}
+	if e.Type != o.Type {
+		return e.Type > o.Type
+	}
+	return e.RefID > o.RefID
+}
+
+// Cursor encodes the event as the start of the page after it
+func (e TimelineEvent) Cursor() string {
+	raw := e.Timestamp.Format(time.RFC3339Nano) + "|" + e.Type + "|" + strconv.FormatUint(uint64(e.RefID), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseTimelineCursor reads a cursor written by TimelineEvent.Cursor
+func ParseTimelineCursor(s string) (*TimelineEvent, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidTimelineCursor
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 {
+		return nil, ErrInvalidTimelineCursor
+	}
+	ts, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, ErrInvalidTimelineCursor
+	}
+	id, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidTimelineCursor
+	}
+	return &TimelineEvent{Timestamp: ts, Type: parts[1], RefID: uint(id)}, nil
+}
+
+// TimelineQuery selects one page of a patient's timeline
+type TimelineQuery struct {
+	PatientID uint
+	From, To  *time.Time     // Bounds on the timestamp, From inclusive and To exclusive; nil is open
+	After     *TimelineEvent // Start after this event, the previous page's last
+	Limit     int
+}
+
+// TimelinePage is one page of a timeline, newest first. NextCursor is
+// empty on the last page.
+type TimelinePage struct {
+	Events     []TimelineEvent `json:"events"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// TimelineService merges what happened to a patient, recorded across
+// several tables, into one time-ordered list
+type TimelineService struct {
+	DB    *gorm.DB
+	Audit *AuditService
+}
+
+func NewTimelineService(db *gorm.DB, audit *AuditService) *TimelineService {
+	return &TimelineService{DB: db, Audit: audit}
+}
+
+// timelineSource reads up to limit events of one type, newest first, from
+// a query already scoped to the patient, window and cursor
+type timelineSource struct {
+	eventType string
+	column    string // The timestamp column
+	query     func(db *gorm.DB, q TimelineQuery) *gorm.DB
+	read      func(q *gorm.DB, limit int) ([]TimelineEvent, error)
+}
+
+// Timeline returns a page of the patient's events. Each source is asked
+// for one more event than the page holds, so merging them is enough to
+// tell whether another page follows.
+func (s *TimelineService) Timeline(q TimelineQuery) (*TimelinePage, error) {
+	if s.Audit != nil {
+		if err := s.Audit.Flush(); err != nil {
+			return nil, err
+		}
+	}
+	var events []TimelineEvent
+	for _, src := range s.sources() {
+		found, err := src.read(s.scope(src, q), q.Limit+1)
+		if err != nil {
+			return nil, fmt.Errorf("%s events: %w", src.eventType, err)
+		}
+		events = append(events, found...)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].before(events[j]) })
+
+	page := &TimelinePage{Events: []TimelineEvent{}}
+	if len(events) > q.Limit {
+		events = events[:q.Limit]
+		page.NextCursor = events[len(events)-1].Cursor()
+	}
+	page.Events = append(page.Events, events...)
+	return page, nil
+}
+
+// scope narrows a source to the query's window and to the events ordered
+// after the cursor: older, or as old with a smaller type and ID
+func (s *TimelineService) scope(src timelineSource, q TimelineQuery) *gorm.DB {
+	db := src.query(s.DB, q)
+	col := src.column
+	if q.From != nil {
+		db = db.Where(col+" >= ?", *q.From)
+	}
+	if q.To != nil {
+		db = db.Where(col+" < ?", *q.To)
+	}
+	if c := q.After; c != nil {
+		switch {
+		case src.eventType < c.Type:
+			db = db.Where(col+" <= ?", c.Timestamp)
+		case src.eventType == c.Type:
+			db = db.Where("("+col+" < ? OR ("+col+" = ? AND id < ?))", c.Timestamp, c.Timestamp, c.RefID)
+		default:
+			db = db.Where(col+" < ?", c.Timestamp)
+		}
+	}
+	return db.Order(col + " DESC, id DESC")
+}
+
+func (s *TimelineService) sources() []timelineSource {
+	return []timelineSource{
+		{
+			eventType: TimelineAssessment,
+			column:    "created_at",
+			query: func(db *gorm.DB, q TimelineQuery) *gorm.DB {
+				return db.Model(&models.Assessment{}).Where("patient_id = ?", q.PatientID)
+			},
+			read: func(q *gorm.DB, limit int) ([]TimelineEvent, error) {
+				var rows []models.Assessment
+				if err := q.Omit("patient_snapshot").Limit(limit).Find(&rows).Error; err != nil {
+					return nil, err
+				}
+				events := make([]TimelineEvent, len(rows))
+				for i, a := range rows {
+					events[i] = TimelineEvent{Type: TimelineAssessment, Timestamp: a.CreatedAt, Summary: assessmentSummary(a), RefID: a.ID}
+				}
+				return events, nil
+			},
+		},
+		{
+			eventType: TimelineAudit,
+			column:    "timestamp",
+			query: func(db *gorm.DB, q TimelineQuery) *gorm.DB {
+				events := make([]string, 0, len(TimelineAuditEvents))
+				for event := range TimelineAuditEvents {
+					events = append(events, event)
+				}
+				return db.Model(&models.AuditLog{}).Where("patient_id_hash = ? AND event_type IN ?", PatientHash(q.PatientID), events)
+			},
+			read: func(q *gorm.DB, limit int) ([]TimelineEvent, error) {
+				var rows []models.AuditLog
+				if err := q.Limit(limit).Find(&rows).Error; err != nil {
+					return nil, err
+				}
+				events := make([]TimelineEvent, len(rows))
+				for i, e := range rows {
+					events[i] = TimelineEvent{Type: TimelineAudit, Timestamp: e.Timestamp, Summary: TimelineAuditEvents[e.EventType] + " by " + e.ActorID, RefID: e.ID}
+				}
+				return events, nil
+			},
+		},
+		{
+			// Feedback that overrode the prediction appears as the override
+			eventType: TimelineFeedback,
+			column:    "created_at",
+			query: func(db *gorm.DB, q TimelineQuery) *gorm.DB {
+				overridden := db.Model(&models.OverrideLog{}).Select("feedback_id")
+				return db.Model(&models.Feedback{}).Where("patient_id = ? AND id NOT IN (?)", q.PatientID, overridden)
+			},
+			read: func(q *gorm.DB, limit int) ([]TimelineEvent, error) {
+				var rows []models.Feedback
+				if err := q.Limit(limit).Find(&rows).Error; err != nil {
+					return nil, err
+				}
+				events := make([]TimelineEvent, len(rows))
+				for i, f := range rows {
+					events[i] = TimelineEvent{Type: TimelineFeedback, Timestamp: f.CreatedAt, Summary: feedbackSummary(f), RefID: f.ID}
+				}
+				return events, nil
+			},
+		},
+		{
+			eventType: TimelineOverride,
+			column:    "created_at",
+			query: func(db *gorm.DB, q TimelineQuery) *gorm.DB {
+				return db.Model(&models.OverrideLog{}).Where("patient_id = ?", q.PatientID)
+			},
+			read: func(q *gorm.DB, limit int) ([]TimelineEvent, error) {
+				var rows []models.OverrideLog
+				if err := q.Limit(limit).Find(&rows).Error; err != nil {
+					return nil, err
+				}
+				events := make([]TimelineEvent, len(rows))
+				for i, o := range rows {
+					events[i] = TimelineEvent{Type: TimelineOverride, Timestamp: o.CreatedAt, Summary: overrideSummary(o), RefID: o.ID}
+				}
+				return events, nil
+			},
+		},
+	}
+}
+
+// assessmentSummary gives the headline risk scores
+func assessmentSummary(a models.Assessment) string {
+	summary := fmt.Sprintf("Heart %.0f%%, diabetes %.0f%%, stroke %.0f%%, kidney %.0f%%",
+		a.HeartRisk, a.DiabetesRisk, a.StrokeRisk, a.KidneyRisk)
+	if a.RuleBased {
+		summary += " (rule-based)"
+	}
+	if a.Emergency {
+		summary = "Emergency: " + summary
+	}
+	return summary
+}
+
+func feedbackSummary(f models.Feedback) string {
+	summary := "Doctor rejected the assessment"
+	if f.DoctorApproved {
+		summary = "Doctor approved the assessment"
+	}
+	if notes := []rune(strings.TrimSpace(f.DoctorNotes)); len(notes) > 0 {
+		if len(notes) > timelineNoteLength {
+			notes = append(notes[:timelineNoteLength], '…')
+		}
+		summary += ": " + string(notes)
+	}
+	return summary
+}
+
+func overrideSummary(o models.OverrideLog) string {
+	overridden := strings.Join(o.ModelsOverridden, ", ")
+	if overridden == "" {
+		overridden = o.ModelName
+	}
+	summary := "Doctor overrode the prediction"
+	if overridden != "" {
+		summary = "Doctor overrode " + overridden
+	}
+	if o.Reason != "" {
+		summary += ": " + o.Reason
+	}
+	return summary
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"healthcare-backend/pkg/cache"
+	"healthcare-backend/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidRefreshToken is returned for unknown, expired or revoked refresh tokens
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
+// TokenRevocations lists access tokens revoked before they expired, by jti.
+// Entries live in memory and, when Redis is up, in Redis so every replica
+// sees a logout; each is dropped once its token would have expired anyway.
+type TokenRevocations struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time // jti -> token expiry
+}
+
+func NewTokenRevocations() *TokenRevocations {
+	return &TokenRevocations{revoked: map[string]time.Time{}}
+}
+
+func revocationKey(jti string) string {
+	return "revoked_jti:" + jti
+}
+
+// Revoke blacklists a token until it expires
+func (r *TokenRevocations) Revoke(jti string, expiresAt time.Time) {
+	ttl := time.Until(expiresAt)
+	if jti == "" || ttl <= 0 {
+		return
+	}
+	r.mu.Lock()
+	r.revoked[jti] = expiresAt
+	r.mu.Unlock()
+	cache.Set(revocationKey(jti), 1, ttl) // Best effort; this replica already knows
+}
+
+// Revoked reports whether a token was revoked, here or on another replica
+func (r *TokenRevocations) Revoked(jti string) bool {
+	now := time.Now()
+	r.mu.Lock()
+	exp, ok := r.revoked[jti]
+	if ok && !now.Before(exp) {
+		delete(r.revoked, jti)
+		ok = false
+	}
+	r.mu.Unlock()
+	if ok {
+		return true
+	}
+
+	_, err := cache.Get(revocationKey(jti)) // Missing, or Redis is down: not revoked
+	return err == nil
+}
+
+// Sweep drops entries for tokens that have expired
+func (r *TokenRevocations) Sweep() {
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for jti, exp := range r.revoked {
+		if !now.Before(exp) {
+			delete(r.revoked, jti)
+		}
+	}
+}
+
+// IssueRefreshToken creates a refresh token for user. The plaintext is
+// returned once; only its SHA-256 is stored, as for API keys.
+func (s *UserService) IssueRefreshToken(user *models.User, ttl time.Duration) (string, *models.RefreshToken, error) {
+	token, err := newAPIKey()
+	if err != nil {
+		return "", nil, err
+	}
+	rt := models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: hashAPIKey(token),
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	}
+	if err := s.DB.Create(&rt).Error; err != nil {
+		return "", nil, err
+	}
+	return token, &rt, nil
+}
+
+// Refresh exchanges a refresh token for a new one, revoking the old. A
+// token presented again after it was exchanged was probably stolen, so
+// reuse revokes every refresh token the user holds.
+func (s *UserService) Refresh(token string, ttl time.Duration) (*models.User, string, *models.RefreshToken, error) {
+	var rt models.RefreshToken
+	if err := s.DB.Where("token_hash = ?", hashAPIKey(token)).Limit(1).Find(&rt).Error; err != nil {
+		return nil, "", nil, err
+	}
+	now := time.Now().UTC()
+	if rt.ID == 0 || !now.Before(rt.ExpiresAt) {
+		return nil, "", nil, ErrInvalidRefreshToken
+	}
+	if rt.RevokedAt != nil {
+		if err := s.RevokeAllRefreshTokens(rt.UserID); err != nil {
+			return nil, "", nil, err
+		}
+		return nil, "", nil, ErrInvalidRefreshToken
+	}
+
+	var user models.User
+	if err := s.DB.First(&user, rt.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", nil, ErrInvalidRefreshToken
+		}
+		return nil, "", nil, err
+	}
+
+	// Only the first of two concurrent refreshes wins the token
+	res := s.DB.Model(&models.RefreshToken{}).Where("id = ? AND revoked_at IS NULL", rt.ID).Update("revoked_at", now)
+	if res.Error != nil {
+		return nil, "", nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, "", nil, ErrInvalidRefreshToken
+	}
+	next, issued, err := s.IssueRefreshToken(&user, ttl)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return &user, next, issued, nil
+}
+
+// RevokeRefreshToken revokes one refresh token, e.g. on logout. Unknown
+// tokens are ignored so logout never fails.
+func (s *UserService) RevokeRefreshToken(token string) error {
+	return s.DB.Model(&models.RefreshToken{}).
+		Where("token_hash = ? AND revoked_at IS NULL", hashAPIKey(token)).
+		Update("revoked_at", time.Now().UTC()).Error
+}
+
+// RevokeAllRefreshTokens signs a user out everywhere once their access tokens expire
+func (s *UserService) RevokeAllRefreshTokens(userID uint) error {
+	return s.DB.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now().UTC()).Error
+}
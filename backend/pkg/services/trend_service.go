@@ -0,0 +1,163 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"healthcare-backend/pkg/cache"
+	"healthcare-backend/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	MaxTrendWindow = 90 * 24 * time.Hour
+	MinTrendBucket = time.Hour
+	trendCacheTTL  = 60 * time.Second
+)
+
+var ErrInvalidTrendSpan = errors.New("invalid trend span")
+
+// TrendService turns stored assessments into time-bucketed dashboard series
+type TrendService struct {
+	DB  *gorm.DB
+	Now func() time.Time // Injectable clock
+}
+
+func NewTrendService(db *gorm.DB) *TrendService {
+	return &TrendService{DB: db, Now: time.Now}
+}
+
+// ParseTrendSpan reads a window or bucket length: a whole number of days
+// ("7d") or a Go duration ("12h")
+func ParseTrendSpan(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("%q is not a number of days", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%q is not a duration such as 7d or 12h", s)
+	}
+	return d, nil
+}
+
+// formatTrendSpan writes whole days as "7d", anything else as a Go duration
+func formatTrendSpan(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return strconv.Itoa(int(d/(24*time.Hour))) + "d"
+	}
+	return d.String()
+}
+
+// epochSecondsExpr returns a SQL expression for created_at in Unix seconds
+func epochSecondsExpr(db *gorm.DB) string {
+	if db.Dialector.Name() == "postgres" {
+		return "EXTRACT(EPOCH FROM created_at)"
+	}
+	return "(julianday(created_at) - 2440587.5) * 86400"
+}
+
+// trendBucketExpr numbers the bucket of created_at, counting from the
+// first two arguments: the series start in Unix seconds and the bucket length
+func trendBucketExpr(db *gorm.DB) string {
+	if db.Dialector.Name() == "postgres" {
+		return "FLOOR((" + epochSecondsExpr(db) + " - ?) / ?)"
+	}
+	return "CAST((" + epochSecondsExpr(db) + " - ?) / ? AS INTEGER)"
+}
+
+// Trends returns one point per bucket covering the last window, oldest
+// first. Buckets are aligned to multiples of the bucket length in UTC, so
+// the first starts at or before the window and the last is still filling.
+// Empty buckets are included. Series are cached in Redis for a minute.
+func (s *TrendService) Trends(window, bucket time.Duration) (*models.DashboardTrends, error) {
+	if window > MaxTrendWindow {
+		return nil, fmt.Errorf("%w: window must be at most 90d", ErrInvalidTrendSpan)
+	}
+	if bucket < MinTrendBucket || bucket > window {
+		return nil, fmt.Errorf("%w: bucket must be between 1h and the window", ErrInvalidTrendSpan)
+	}
+
+	key := fmt.Sprintf("dashboard:trends:%d:%d", int64(window.Seconds()), int64(bucket.Seconds()))
+	if cached, err := cache.Get(key); err == nil {
+		var trends models.DashboardTrends
+		if err := json.Unmarshal([]byte(cached), &trends); err == nil {
+			return &trends, nil
+		}
+	}
+
+	now := s.Now().UTC()
+	start := now.Add(-window).Truncate(bucket)
+	n := int((now.Sub(start) + bucket - 1) / bucket)
+	end := start.Add(time.Duration(n) * bucket)
+
+	type row struct {
+		Bucket       int64
+		Assessments  int64
+		Emergencies  int64
+		HeartRisk    float64
+		DiabetesRisk float64
+		FallbackRate float64
+	}
+	var rows []row
+	err := s.DB.Model(&models.Assessment{}).
+		Select(trendBucketExpr(s.DB)+" AS bucket, "+
+			"COUNT(*) AS assessments, "+
+			"SUM(CASE WHEN emergency THEN 1 ELSE 0 END) AS emergencies, "+
+			"AVG(heart_risk) AS heart_risk, "+
+			"AVG(diabetes_risk) AS diabetes_risk, "+
+			"AVG(CASE WHEN rule_based THEN 1.0 ELSE 0.0 END) AS fallback_rate",
+			start.Unix(), int64(bucket.Seconds())).
+		Where("created_at >= ? AND created_at < ?", start, end).
+		Group("bucket").
+		Order("bucket").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	trends := &models.DashboardTrends{
+		Window:      formatTrendSpan(window),
+		Bucket:      formatTrendSpan(bucket),
+		Since:       start,
+		Until:       end,
+		GeneratedAt: now,
+		Points:      make([]models.TrendPoint, n),
+	}
+	for i := range trends.Points {
+		trends.Points[i].Start = start.Add(time.Duration(i) * bucket)
+	}
+	for _, r := range rows {
+		// Rounding in the database can put a row on a boundary one bucket off
+		i := min(max(int(r.Bucket), 0), n-1)
+		p := &trends.Points[i]
+		p.Assessments += r.Assessments
+		p.Emergencies += r.Emergencies
+		p.AvgHeartRisk = mergeMean(p.AvgHeartRisk, p.Assessments-r.Assessments, r.HeartRisk, r.Assessments)
+		p.AvgDiabetesRisk = mergeMean(p.AvgDiabetesRisk, p.Assessments-r.Assessments, r.DiabetesRisk, r.Assessments)
+		p.FallbackRate = mergeMean(p.FallbackRate, p.Assessments-r.Assessments, r.FallbackRate, r.Assessments)
+	}
+
+	if data, err := json.Marshal(trends); err == nil {
+		cache.Set(key, data, trendCacheTTL)
+	}
+	return trends, nil
+}
+
+// mergeMean combines a running mean over n samples (nil when n is 0) with
+// the mean of m more
+func mergeMean(mean *float64, n int64, other float64, m int64) *float64 {
+	if mean == nil {
+		return &other
+	}
+	merged := (*mean*float64(n) + other*float64(m)) / float64(n+m)
+	return &merged
+}
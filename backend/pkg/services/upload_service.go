@@ -0,0 +1,232 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"healthcare-backend/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// Upload statuses
+const (
+	UploadProcessing = "processing"
+	UploadAnalyzed   = "analyzed"
+	UploadFailed     = "failed"
+	UploadDeleted    = "deleted"
+)
+
+// UploadService records every file saved to the uploads volume and removes
+// them once their analysis has finished or they outlive the retention.
+// Files of an in-flight analysis in this process are never removed.
+type UploadService struct {
+	DB        *gorm.DB
+	Dir       string
+	Retention time.Duration
+
+	mu       sync.Mutex
+	inFlight map[uint]bool
+}
+
+func NewUploadService(db *gorm.DB, dir string, retention time.Duration) *UploadService {
+	return &UploadService{DB: db, Dir: dir, Retention: retention, inFlight: map[uint]bool{}}
+}
+
+// UploadUsage reconciles the uploads directory with the registry
+type UploadUsage struct {
+	Dir          string   `json:"dir"`
+	Files        int      `json:"files"`         // Files on disk
+	Bytes        int64    `json:"bytes"`         // Their total size
+	Tracked      int      `json:"tracked"`       // Registry rows whose file is on disk
+	InFlight     int      `json:"in_flight"`     // Files an analysis is still reading
+	OrphanFiles  []string `json:"orphan_files"`  // On disk, no registry row
+	MissingFiles []string `json:"missing_files"` // Registry row, file gone
+}
+
+// CleanupResult summarises one cleanup pass
+type CleanupResult struct {
+	Removed        int   `json:"removed"`         // Tracked files deleted
+	OrphansRemoved int   `json:"orphans_removed"` // Untracked files deleted
+	MissingRows    int   `json:"missing_rows"`    // Rows closed because their file was already gone
+	FreedBytes     int64 `json:"freed_bytes"`
+}
+
+// Register records a freshly saved file and marks it in flight until Finish
+func (s *UploadService) Register(path, purpose, owner string) (*models.UploadedFile, error) {
+	sum, size, err := hashFile(path)
+	if err != nil {
+		return nil, err
+	}
+	record := models.UploadedFile{
+		Path:      path,
+		SHA256:    sum,
+		SizeBytes: size,
+		Purpose:   purpose,
+		Owner:     owner,
+		Status:    UploadProcessing,
+	}
+	if err := s.DB.Create(&record).Error; err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.inFlight[record.ID] = true
+	s.mu.Unlock()
+	return &record, nil
+}
+
+// Finish records the analysis outcome; the file becomes eligible for cleanup
+func (s *UploadService) Finish(id uint, analyzed bool) error {
+	s.mu.Lock()
+	delete(s.inFlight, id)
+	s.mu.Unlock()
+
+	status := UploadFailed
+	if analyzed {
+		status = UploadAnalyzed
+	}
+	return s.DB.Model(&models.UploadedFile{}).Where("id = ?", id).
+		Updates(map[string]any{"status": status, "finished_at": time.Now().UTC()}).Error
+}
+
+func (s *UploadService) isInFlight(id uint) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inFlight[id]
+}
+
+// diskFiles lists regular files directly in Dir. Subdirectories (e.g. backups) are not uploads.
+func (s *UploadService) diskFiles() (map[string]os.FileInfo, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return map[string]os.FileInfo{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]os.FileInfo, len(entries))
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue // Removed while listing
+		}
+		files[filepath.Join(s.Dir, e.Name())] = info
+	}
+	return files, nil
+}
+
+func (s *UploadService) liveRows() ([]models.UploadedFile, error) {
+	var rows []models.UploadedFile
+	err := s.DB.Where("status <> ?", UploadDeleted).Order("id").Find(&rows).Error
+	return rows, err
+}
+
+// Usage reports disk usage and both kinds of orphan
+func (s *UploadService) Usage() (UploadUsage, error) {
+	usage := UploadUsage{Dir: s.Dir, OrphanFiles: []string{}, MissingFiles: []string{}}
+	files, err := s.diskFiles()
+	if err != nil {
+		return usage, err
+	}
+	rows, err := s.liveRows()
+	if err != nil {
+		return usage, err
+	}
+
+	tracked := make(map[string]bool, len(rows))
+	for _, r := range rows {
+		tracked[r.Path] = true
+		if _, ok := files[r.Path]; !ok {
+			usage.MissingFiles = append(usage.MissingFiles, r.Path)
+			continue
+		}
+		usage.Tracked++
+		if s.isInFlight(r.ID) {
+			usage.InFlight++
+		}
+	}
+	for path, info := range files {
+		usage.Files++
+		usage.Bytes += info.Size()
+		if !tracked[path] {
+			usage.OrphanFiles = append(usage.OrphanFiles, path)
+		}
+	}
+	sort.Strings(usage.OrphanFiles)
+	return usage, nil
+}
+
+// Cleanup deletes finished uploads, uploads older than the retention (unless
+// in flight here), and untracked files older than the retention. The age
+// check on untracked files covers a file saved but not yet registered.
+func (s *UploadService) Cleanup(now time.Time) (CleanupResult, error) {
+	var res CleanupResult
+	files, err := s.diskFiles()
+	if err != nil {
+		return res, err
+	}
+	rows, err := s.liveRows()
+	if err != nil {
+		return res, err
+	}
+	cutoff := now.Add(-s.Retention)
+
+	tracked := make(map[string]bool, len(rows))
+	for _, r := range rows {
+		tracked[r.Path] = true
+		if s.isInFlight(r.ID) {
+			continue
+		}
+		info, onDisk := files[r.Path]
+		if !onDisk {
+			res.MissingRows++
+		} else if r.Status == UploadProcessing && !r.CreatedAt.Before(cutoff) {
+			continue // Possibly another replica's in-flight job
+		} else {
+			if err := os.Remove(r.Path); err != nil && !os.IsNotExist(err) {
+				return res, err
+			}
+			res.Removed++
+			res.FreedBytes += info.Size()
+		}
+		if err := s.DB.Model(&models.UploadedFile{}).Where("id = ?", r.ID).Update("status", UploadDeleted).Error; err != nil {
+			return res, err
+		}
+	}
+
+	for path, info := range files {
+		if tracked[path] || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return res, err
+		}
+		res.OrphansRemoved++
+		res.FreedBytes += info.Size()
+	}
+	return res, nil
+}
+
+func hashFile(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
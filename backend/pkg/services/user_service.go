@@ -0,0 +1,117 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"slices"
+	"strconv"
+	"strings"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/models"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidUser  = errors.New("invalid user")
+	ErrUserExists   = errors.New("user already exists")
+	ErrInvalidLogin = errors.New("invalid email or password")
+)
+
+// UserRoles are the roles an account can be registered with
+var UserRoles = []string{auditctx.RoleDoctor, auditctx.RoleAdmin, auditctx.RoleUser}
+
+// MinPasswordLength is the shortest password Register accepts
+const MinPasswordLength = 8
+
+// UserService manages password accounts
+type UserService struct {
+	DB *gorm.DB
+
+	// Compared against when the email is unknown, so a login takes as long
+	// whether or not the account exists
+	dummyHash []byte
+}
+
+func NewUserService(db *gorm.DB) *UserService {
+	dummy, _ := bcrypt.GenerateFromPassword([]byte("not-a-real-password"), bcrypt.DefaultCost)
+	return &UserService{DB: db, dummyHash: dummy}
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Register creates an account. The password is stored as a bcrypt hash.
+func (s *UserService) Register(email, name, password, role string) (*models.User, error) {
+	email = normalizeEmail(email)
+	if _, err := mail.ParseAddress(email); err != nil {
+		return nil, fmt.Errorf("%w: a valid email is required", ErrInvalidUser)
+	}
+	if len(password) < MinPasswordLength {
+		return nil, fmt.Errorf("%w: password must be at least %d characters", ErrInvalidUser, MinPasswordLength)
+	}
+	if len(password) > 72 {
+		return nil, fmt.Errorf("%w: password must be at most 72 bytes", ErrInvalidUser) // bcrypt's limit
+	}
+	if role == "" {
+		role = auditctx.RoleUser
+	}
+	if !slices.Contains(UserRoles, role) {
+		return nil, fmt.Errorf("%w: role must be one of %s", ErrInvalidUser, strings.Join(UserRoles, ", "))
+	}
+
+	var existing int64
+	if err := s.DB.Model(&models.User{}).Where("email = ?", email).Count(&existing).Error; err != nil {
+		return nil, err
+	}
+	if existing > 0 {
+		return nil, ErrUserExists
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	user := models.User{Email: email, Name: strings.TrimSpace(name), Role: role, PasswordHash: string(hash)}
+	if err := s.DB.Create(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Authenticate checks a password, returning ErrInvalidLogin for an unknown
+// email or a wrong password alike
+func (s *UserService) Authenticate(email, password string) (*models.User, error) {
+	var user models.User
+	err := s.DB.Where("email = ?", normalizeEmail(email)).Limit(1).Find(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password))
+		return nil, ErrInvalidLogin
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return nil, ErrInvalidLogin
+	}
+	return &user, nil
+}
+
+// UserIdentity is how an account appears in tokens and the audit log
+func UserIdentity(u *models.User) auditctx.Identity {
+	return auditctx.Identity{ID: fmt.Sprintf("user:%d", u.ID), Role: u.Role}
+}
+
+// UserIDOf reverses UserIdentity; ok is false for actors that aren't
+// accounts, like API keys
+func UserIDOf(id auditctx.Identity) (uint, bool) {
+	n, err := strconv.ParseUint(strings.TrimPrefix(id.ID, "user:"), 10, 64)
+	if err != nil || !strings.HasPrefix(id.ID, "user:") {
+		return 0, false
+	}
+	return uint(n), true
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/resilience"
+
+	"github.com/sony/gobreaker"
+	"gorm.io/gorm"
+)
+
+// NotificationWebhook is the kind recorded for webhook deliveries
+const NotificationWebhook = "emergency_webhook"
+
+// NotificationFailed marks a webhook delivery that failed every attempt
+const NotificationFailed = "failed"
+
+// Webhook defaults, used when the notifier's fields are zero
+const (
+	DefaultWebhookRetries = 3
+	DefaultWebhookBackoff = time.Second
+	DefaultWebhookTimeout = 5 * time.Second
+)
+
+// webhookDestination is one URL with its own breaker, so a dead receiver
+// doesn't slow deliveries to the others
+type webhookDestination struct {
+	URL string
+	CB  *gobreaker.CircuitBreaker
+}
+
+// WebhookNotifier POSTs emergency alerts to external URLs (paging systems,
+// chat) in the background and logs every delivery to notification_logs
+type WebhookNotifier struct {
+	DB      *gorm.DB
+	Client  *http.Client
+	Retries int           // After the first attempt
+	Backoff time.Duration // Before the first retry, doubling after each
+
+	destinations []webhookDestination
+	wg           sync.WaitGroup
+}
+
+func NewWebhookNotifier(db *gorm.DB, urls []string) *WebhookNotifier {
+	n := &WebhookNotifier{
+		DB:      db,
+		Client:  &http.Client{Timeout: DefaultWebhookTimeout},
+		Retries: DefaultWebhookRetries,
+		Backoff: DefaultWebhookBackoff,
+	}
+	for _, url := range urls {
+		n.destinations = append(n.destinations, webhookDestination{URL: url, CB: resilience.NewCircuitBreaker("Webhook-" + url)})
+	}
+	return n
+}
+
+// Send delivers the alert to every destination without waiting
+func (n *WebhookNotifier) Send(alert models.EmergencyWebhook) {
+	payload, err := json.Marshal(alert)
+	if err != nil {
+		slog.Warn("emergency webhook not sent", "patient_id", alert.PatientID, "error", err)
+		return
+	}
+	for _, d := range n.destinations {
+		n.wg.Add(1)
+		go func(d webhookDestination) {
+			defer n.wg.Done()
+			n.deliver(d, alert.PatientID, payload)
+		}(d)
+	}
+}
+
+// Wait blocks until every delivery in progress has finished
+func (n *WebhookNotifier) Wait() {
+	n.wg.Wait()
+}
+
+func (n *WebhookNotifier) deliver(d webhookDestination, patientID uint, payload []byte) {
+	entry := &models.NotificationLog{
+		Kind:        NotificationWebhook,
+		Critical:    true,
+		PatientID:   patientID,
+		Destination: d.URL,
+		Payload:     string(payload),
+		DeliverAt:   time.Now().UTC(),
+	}
+
+	backoff := n.Backoff
+	var err error
+	for attempt := 0; attempt <= n.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		entry.Attempts++
+		_, err = d.CB.Execute(func() (interface{}, error) {
+			return nil, n.post(d.URL, payload)
+		})
+		// An open breaker fails fast; retrying would only wait it out
+		if err == nil || errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+			break
+		}
+	}
+
+	if err != nil {
+		entry.Decision, entry.Reason = NotificationFailed, err.Error()
+		slog.Warn("emergency webhook failed", "patient_id", patientID, "url", d.URL, "attempts", entry.Attempts, "error", err)
+	} else {
+		now := time.Now().UTC()
+		entry.Decision, entry.Reason, entry.SentAt = NotificationSent, "delivered", &now
+	}
+	if err := n.DB.Create(entry).Error; err != nil {
+		slog.Warn("failed to record emergency webhook", "patient_id", patientID, "error", err)
+	}
+}
+
+func (n *WebhookNotifier) post(url string, payload []byte) error {
+	resp, err := n.Client.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package terminology
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+
+	"healthcare-backend/pkg/models"
+)
+
+// SNOMEDSystem is the FHIR coding system URI for SNOMED CT
+const SNOMEDSystem = "http://snomed.info/sct"
+
+//go:embed snomed_symptoms.csv
+var bundledSymptoms string
+
+type concept struct {
+	Code    string
+	Display string
+}
+
+// Mapper resolves free-text symptoms to SNOMED CT concepts.
+// Lookups ignore case, diacritics and punctuation, so "Baş ağrısı",
+// "bas agrisi" and "HEADACHE" all hit the same entry.
+type Mapper struct {
+	mu    sync.RWMutex
+	terms map[string]concept // normalized term -> concept
+	codes map[string]bool
+}
+
+// NewMapper loads the bundled symptom table
+func NewMapper() (*Mapper, error) {
+	m := &Mapper{terms: map[string]concept{}, codes: map[string]bool{}}
+	if _, err := m.Load(strings.NewReader(bundledSymptoms)); err != nil {
+		return nil, fmt.Errorf("bundled SNOMED table: %w", err)
+	}
+	return m, nil
+}
+
+// Load merges a CSV table (code,display,synonyms) into the mapper.
+// Synonyms are separated by "|". Existing terms are overwritten, so an
+// uploaded table can correct bundled entries. Returns the number of concepts read.
+func (m *Mapper) Load(r io.Reader) (int, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return 0, err
+	}
+
+	parsed := map[string]concept{}
+	count := 0
+	for i, rec := range records {
+		if i == 0 && len(rec) > 0 && strings.EqualFold(strings.TrimSpace(rec[0]), "code") {
+			continue // header
+		}
+		if len(rec) < 2 || strings.TrimSpace(rec[0]) == "" || strings.TrimSpace(rec[1]) == "" {
+			return 0, fmt.Errorf("line %d: expected code,display[,synonyms]", i+1)
+		}
+
+		c := concept{Code: strings.TrimSpace(rec[0]), Display: strings.TrimSpace(rec[1])}
+		for _, d := range c.Code {
+			if d < '0' || d > '9' {
+				return 0, fmt.Errorf("line %d: invalid SNOMED code %q", i+1, c.Code)
+			}
+		}
+
+		parsed[Normalize(c.Display)] = c
+		if len(rec) > 2 {
+			for _, syn := range strings.Split(rec[2], "|") {
+				if key := Normalize(syn); key != "" {
+					parsed[key] = c
+				}
+			}
+		}
+		count++
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for term, c := range parsed {
+		m.terms[term] = c
+		m.codes[c.Code] = true
+	}
+	return count, nil
+}
+
+// Lookup returns the concept for a single term
+func (m *Mapper) Lookup(term string) (models.CodedSymptom, bool) {
+	m.mu.RLock()
+	c, ok := m.terms[Normalize(term)]
+	m.mu.RUnlock()
+	if !ok {
+		return models.CodedSymptom{}, false
+	}
+	return models.CodedSymptom{Text: strings.TrimSpace(term), Code: c.Code, Display: c.Display}, true
+}
+
+// Map codes every term it can and returns the rest as unmapped
+func (m *Mapper) Map(terms []string) ([]models.CodedSymptom, []string) {
+	coded := []models.CodedSymptom{}
+	unmapped := []string{}
+	for _, term := range terms {
+		if strings.TrimSpace(term) == "" {
+			continue
+		}
+		if cs, ok := m.Lookup(term); ok {
+			coded = append(coded, cs)
+		} else {
+			unmapped = append(unmapped, strings.TrimSpace(term))
+		}
+	}
+	return coded, unmapped
+}
+
+// Stats reports table size for the admin endpoint
+func (m *Mapper) Stats() (terms, concepts int) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.terms), len(m.codes)
+}
+
+// Normalize folds case and diacritics and collapses separators
+func Normalize(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range norm.NFD.String(s) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			continue // combining mark left over from NFD (ş -> s, ğ -> g, é -> e)
+		case r == 'ı' || r == 'İ':
+			r = 'i' // Turkish dotless/dotted i have no decomposition
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			r = unicode.ToLower(r)
+		default:
+			space = b.Len() > 0
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
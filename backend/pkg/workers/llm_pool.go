@@ -0,0 +1,236 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/queue"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultLLMConcurrency is used when the worker's Concurrency is zero
+const DefaultLLMConcurrency = 4
+
+// ErrWorkerStopped is returned for tasks enqueued after Stop
+var ErrWorkerStopped = errors.New("llm worker stopped")
+
+// WorkerStatus is a snapshot of the LLM worker pool
+type WorkerStatus struct {
+	Concurrency int   `json:"concurrency"`
+	InFlight    int64 `json:"in_flight"`
+	Queued      int   `json:"queued"` // Buffered for the pool plus still pending on the subscriptions
+	Processed   int64 `json:"processed"`
+	Durable     bool  `json:"durable"` // Tasks are persisted by JetStream
+	Stopped     bool  `json:"stopped"`
+
+	// Backlog counts waiting tasks by priority. With JetStream it includes
+	// those not yet delivered to any instance.
+	Backlog map[string]int `json:"backlog"`
+}
+
+func (w *LLMWorker) concurrency() int {
+	if w.Concurrency <= 0 {
+		return DefaultLLMConcurrency
+	}
+	return w.Concurrency
+}
+
+// StartPool starts the goroutines that process enqueued tasks. Start calls
+// it before subscribing.
+func (w *LLMWorker) StartPool() {
+	n := w.concurrency()
+	w.tasks = make(chan *nats.Msg, n)
+	w.priorityTasks = make(chan *nats.Msg, n)
+	w.quit = make(chan struct{})
+	for i := 0; i < n; i++ {
+		w.wg.Add(1)
+		go w.run()
+	}
+}
+
+func (w *LLMWorker) run() {
+	defer w.wg.Done()
+	for {
+		m, ok := w.next()
+		if !ok {
+			return
+		}
+		w.inFlight.Add(1)
+		w.handle(m)
+		w.inFlight.Add(-1)
+		w.processed.Add(1)
+	}
+}
+
+// next waits for a task, taking an emergency whenever one is queued.
+// It reports false once the pool is stopping.
+func (w *LLMWorker) next() (*nats.Msg, bool) {
+	var m *nats.Msg
+	select {
+	case m = <-w.priorityTasks:
+	default:
+		select {
+		case <-w.quit:
+			return nil, false
+		case m = <-w.priorityTasks:
+		case m = <-w.tasks:
+		}
+	}
+	// A task and the stop may have been ready together; stopping wins
+	select {
+	case <-w.quit:
+		nak(m)
+		return nil, false
+	default:
+		return m, true
+	}
+}
+
+// Enqueue hands a task to the pool, blocking while every worker is busy and
+// the task's buffer is full. Tasks on llm.tasks.priority are taken first.
+func (w *LLMWorker) Enqueue(m *nats.Msg) error {
+	select {
+	case <-w.quit:
+		nak(m)
+		return ErrWorkerStopped
+	default:
+	}
+	tasks := w.tasks
+	if m.Subject == LLMPriorityTaskSubject {
+		tasks = w.priorityTasks
+	}
+	select {
+	case tasks <- m:
+		return nil
+	case <-w.quit:
+		nak(m)
+		return ErrWorkerStopped
+	}
+}
+
+// Stop takes no more tasks, hands back the queued ones and waits for those
+// in flight until ctx is done. JetStream redelivers what was handed back;
+// with plain NATS it is lost.
+func (w *LLMWorker) Stop(ctx context.Context) error {
+	w.stopOnce.Do(func() {
+		// Unsubscribing a JetStream subscription would delete the durable
+		// consumer the other replicas share; closing the connection won't
+		if !queue.IsDurable(LLMTaskSubject) {
+			for _, sub := range []*nats.Subscription{w.prioritySub, w.sub} {
+				if sub != nil {
+					sub.Unsubscribe()
+				}
+			}
+		}
+		close(w.quit)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	for {
+		select {
+		case m := <-w.priorityTasks:
+			nak(m)
+		case m := <-w.tasks:
+			nak(m)
+		default:
+			if err != nil {
+				slog.Warn("llm worker: stopped with diagnoses still in flight", "in_flight", w.inFlight.Load())
+			}
+			return err
+		}
+	}
+}
+
+// Status reports the pool's concurrency, queue depth and in-flight count
+func (w *LLMWorker) Status() WorkerStatus {
+	local := func(sub *nats.Subscription, tasks chan *nats.Msg) int {
+		n := len(tasks)
+		if sub != nil {
+			if pending, _, err := sub.Pending(); err == nil {
+				n += pending
+			}
+		}
+		return n
+	}
+	routine := local(w.sub, w.tasks)
+	emergency := local(w.prioritySub, w.priorityTasks)
+	queued := routine + emergency
+	stopped := false
+	select {
+	case <-w.quit:
+		stopped = true
+	default:
+	}
+	return WorkerStatus{
+		Concurrency: w.concurrency(),
+		InFlight:    w.inFlight.Load(),
+		Queued:      queued,
+		Processed:   w.processed.Load(),
+		Durable:     queue.IsDurable(LLMTaskSubject),
+		Stopped:     stopped,
+		Backlog: map[string]int{
+			models.DiagnosisPriorityRoutine:   routine + undelivered(w.sub),
+			models.DiagnosisPriorityEmergency: emergency + undelivered(w.prioritySub),
+		},
+	}
+}
+
+// undelivered counts a JetStream consumer's tasks not yet sent to any instance
+func undelivered(sub *nats.Subscription) int {
+	if sub == nil || !queue.IsDurable(LLMTaskSubject) {
+		return 0
+	}
+	info, err := sub.ConsumerInfo()
+	if err != nil {
+		return 0
+	}
+	return int(info.NumPending)
+}
+
+// nak asks JetStream to redeliver m; plain NATS messages can't be
+func nak(m *nats.Msg) {
+	if _, err := m.Metadata(); err == nil {
+		m.Nak()
+	}
+}
+
+// backlogCollector reports the pool's backlog by priority when scraped
+type backlogCollector struct {
+	desc   *prometheus.Desc
+	worker *LLMWorker
+}
+
+func (c *backlogCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *backlogCollector) Collect(ch chan<- prometheus.Metric) {
+	for priority, n := range c.worker.Status().Backlog {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n), priority)
+	}
+}
+
+// RegisterMetrics exposes the NATS task backlog on a Prometheus registry
+func (w *LLMWorker) RegisterMetrics(reg prometheus.Registerer) error {
+	return reg.Register(&backlogCollector{
+		desc: prometheus.NewDesc("healthcare_llm_queue_backlog",
+			"LLM diagnosis tasks waiting in NATS by priority.", []string{"priority"}, nil),
+		worker: w,
+	})
+}
@@ -5,83 +5,297 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"healthcare-backend/pkg/auditctx"
 	"healthcare-backend/pkg/cache"
+	"healthcare-backend/pkg/logging"
 	"healthcare-backend/pkg/models"
 	"healthcare-backend/pkg/queue"
+	"healthcare-backend/pkg/services"
+	"healthcare-backend/pkg/tracing"
 	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// Subjects of the durable LLM task pipeline
+const (
+	LLMTaskSubject         = "llm.tasks"
+	LLMPriorityTaskSubject = "llm.tasks.priority" // Emergencies, consumed first
+	LLMDeadLetterSubject   = "llm.tasks.dlq"
+	llmTaskStream          = "LLM_TASKS"
+	llmWorkerGroup         = "llm-workers"
+	llmPriorityWorkerGroup = "llm-workers-priority"
+)
+
+// Retry defaults set by NewLLMWorker
+const (
+	DefaultLLMMaxRetries   = 3
+	DefaultLLMRetryBackoff = time.Second
+)
+
+// llmAckWait is how long JetStream waits on a diagnosis before redelivering
+// it; LLM calls are slow, so it's well above the default 30s
+const llmAckWait = 2 * time.Minute
+
 type LLMWorker struct {
 	MLServiceURL string
+	Client       *http.Client // Gives up on a /diagnose call after its timeout, counting a failed attempt
+
+	// Diagnoses rejects results superseded by a newer diagnosis or a deletion
+	Diagnoses *services.DiagnosisRegistry
+
+	// Failures keeps tasks that ran out of retries; nil only logs them
+	Failures *services.DiagnosisFailureService
+
+	// Coder adds ICD-10 suggestions to finished diagnoses, usually
+	// PredictionService.CodeDiagnosis; nil caches them uncoded
+	Coder func(patientID uint, diagnosis, status string) models.CachedDiagnosis
+
+	// Metrics records /diagnose latency; nil records nothing
+	Metrics *services.Metrics
+
+	// Store keeps finished diagnoses past the cache, for search; nil doesn't
+	Store *services.DiagnosisStore
+
+	MaxRetries   int           // Retries after the first failed attempt
+	RetryBackoff time.Duration // Wait before the first retry, doubling after each
+	Concurrency  int           // Tasks processed at once; DefaultLLMConcurrency when zero
+
+	// Worker pool, see llm_pool.go
+	sub           *nats.Subscription
+	prioritySub   *nats.Subscription
+	tasks         chan *nats.Msg
+	priorityTasks chan *nats.Msg
+	quit          chan struct{}
+	wg        sync.WaitGroup
+	stopOnce  sync.Once
+	inFlight  atomic.Int64
+	processed atomic.Int64
 }
 
 func NewLLMWorker(mlURL string) *LLMWorker {
-	return &LLMWorker{MLServiceURL: mlURL}
+	return &LLMWorker{
+		MLServiceURL: mlURL,
+		Client:       services.NewMLClient(services.DefaultLLMTimeout),
+		MaxRetries:   DefaultLLMMaxRetries,
+		RetryBackoff: DefaultLLMRetryBackoff,
+	}
 }
 
+// Start consumes llm.tasks and llm.tasks.priority through the worker pool.
+// With JetStream the tasks survive restarts and retries are redeliveries;
+// with plain NATS they are retried in process.
 func (w *LLMWorker) Start() {
-	_, err := queue.Subscribe("llm.tasks", func(m *nats.Msg) {
-		var req models.DiagnosisRequest
-		if err := json.Unmarshal(m.Data, &req); err != nil {
-			log.Printf("❌ LLM Worker: Failed to unmarshal request: %v", err)
-			return
-		}
+	w.StartPool()
+	if err := queue.EnsureStream(llmTaskStream, LLMTaskSubject, LLMPriorityTaskSubject, LLMDeadLetterSubject); err != nil {
+		slog.Warn("llm worker: jetstream unavailable, tasks won't survive a restart", "error", err)
+	}
 
-		log.Printf("🤖 LLM Worker: Processing diagnosis for patient %d", req.Patient.ID)
-		
-		llmStart := time.Now()
-		diagPayload, _ := json.Marshal(req)
-		
-		resp, err := http.Post(w.MLServiceURL+"/diagnose", "application/json", bytes.NewBuffer(diagPayload))
+	// Enqueue blocks while the pool is full. JetStream holds back anything
+	// beyond the busy and buffered tasks, so none wait out the ack timer here.
+	subscribe := func(subject, group string) *nats.Subscription {
+		sub, err := queue.SubscribeDurable(subject, group, func(m *nats.Msg) { w.Enqueue(m) },
+			nats.AckWait(llmAckWait), nats.MaxAckPending(2*w.concurrency()))
 		if err != nil {
-			log.Printf("❌ LLM Worker: HTTP error: %v", err)
-			w.updateStatus(req.Patient.ID, "Diagnosis unavailable - LLM service error", "error")
-			return
+			slog.Error("llm worker: failed to subscribe", "subject", subject, "error", err)
 		}
-		defer resp.Body.Close()
+		return sub
+	}
+	w.prioritySub = subscribe(LLMPriorityTaskSubject, llmPriorityWorkerGroup)
+	w.sub = subscribe(LLMTaskSubject, llmWorkerGroup)
+	if w.sub != nil {
+		slog.Info("llm worker: started", "subject", LLMTaskSubject, "durable", queue.IsDurable(LLMTaskSubject), "concurrency", w.concurrency())
+	}
+}
+
+func (w *LLMWorker) handle(m *nats.Msg) {
+	var req models.DiagnosisRequest
+	if err := json.Unmarshal(m.Data, &req); err != nil {
+		slog.Error("llm worker: undecodable task", "error", err)
+		m.Term() // Redelivery can't fix it
+		return
+	}
+
+	// Continue the assessment's trace
+	ctx, span := tracing.Start(tracing.Extract(context.Background(), m.Header), "llm.diagnose",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attribute.Int64("patient.id", int64(req.Patient.ID)), attribute.String("diagnosis.priority", req.Priority)))
+	defer span.End()
+
+	meta, err := m.Metadata()
+	if err != nil {
+		// Plain NATS never redelivers
+		w.process(ctx, req)
+		return
+	}
+
+	m.InProgress() // Restart the ack timer now that it's being worked on
+	attempt := int(meta.NumDelivered)
+	span.SetAttributes(attribute.Int("diagnosis.attempt", attempt))
+	taskLog(req).Info("llm worker: processing diagnosis", "attempt", attempt)
+	diagnosis, err := w.diagnose(ctx, req)
+	switch {
+	case err == nil:
+		w.updateStatus(req.Patient.ID, req.Generation, diagnosis, "ready")
+	case attempt <= w.maxRetries() && w.current(req):
+		taskLog(req).Warn("llm worker: diagnosis failed, retrying", "attempt", attempt, "error", err)
+		m.NakWithDelay(w.backoff(attempt))
+		return
+	default:
+		span.RecordError(err)
+		w.deadLetter(req, attempt, err)
+	}
+	m.Ack()
+}
 
-		var diagRes models.DiagnosisResponse
-		if err := json.NewDecoder(resp.Body).Decode(&diagRes); err != nil {
-			log.Printf("❌ LLM Worker: Decode error: %v", err)
-			w.updateStatus(req.Patient.ID, "Diagnosis unavailable - Decode error", "error")
+// Process runs one diagnosis task, retrying with backoff in process, and
+// publishes its result
+func (w *LLMWorker) Process(req models.DiagnosisRequest) {
+	w.process(context.Background(), req)
+}
+
+func (w *LLMWorker) process(ctx context.Context, req models.DiagnosisRequest) {
+	taskLog(req).Info("llm worker: processing diagnosis")
+	for attempt := 1; ; attempt++ {
+		diagnosis, err := w.diagnose(ctx, req)
+		if err == nil {
+			w.updateStatus(req.Patient.ID, req.Generation, diagnosis, "ready")
 			return
 		}
+		if attempt > w.maxRetries() || !w.current(req) {
+			w.deadLetter(req, attempt, err)
+			return
+		}
+		taskLog(req).Warn("llm worker: diagnosis failed, retrying", "attempt", attempt, "error", err)
+		time.Sleep(w.backoff(attempt))
+	}
+}
 
-		log.Printf("✅ LLM Worker: Completed diagnosis for patient %d in %v", req.Patient.ID, time.Since(llmStart))
-		w.updateStatus(req.Patient.ID, diagRes.Diagnosis, "ready")
-	})
+// diagnose makes one /diagnose call within ctx's trace
+func (w *LLMWorker) diagnose(ctx context.Context, req models.DiagnosisRequest) (_ string, err error) {
+	llmStart := time.Now()
+	defer func() { w.Metrics.ObserveDiagnose(time.Since(llmStart), err) }()
+	diagPayload, _ := json.Marshal(req)
 
+	httpReq, err := http.NewRequestWithContext(auditctx.WithRequestID(ctx, req.RequestID), http.MethodPost, w.MLServiceURL+"/diagnose", bytes.NewBuffer(diagPayload))
 	if err != nil {
-		log.Printf("❌ LLM Worker: Failed to subscribe: %v", err)
-	} else {
-		log.Println("👷 LLM Worker started and listening on llm.tasks")
+		return "", err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if req.RequestID != "" {
+		httpReq.Header.Set(auditctx.RequestIDHeader, req.RequestID)
 	}
+	resp, err := w.Client.Do(httpReq)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("ml service: %s", resp.Status)
+	}
+
+	var diagRes models.DiagnosisResponse
+	if err := json.NewDecoder(resp.Body).Decode(&diagRes); err != nil {
+		return "", fmt.Errorf("decode: %w", err)
+	}
+
+	taskLog(req).Info("llm worker: diagnosis completed", "duration_ms", time.Since(llmStart).Milliseconds())
+	return diagRes.Diagnosis, nil
 }
 
-func (w *LLMWorker) updateStatus(patientID uint, diagnosis string, status string) {
-	// Update Redis cache for polling/state
-	// We use a JSON string or separate keys. Let's use what PredictionService uses if possible.
-	// PredictionService uses internal map, which is BAD for scalability.
-	// We should move that to Redis too.
-	
-	// Update Redis (Phase 3 already set up some Redis, let's use it here)
-	cacheData := map[string]string{
-		"diagnosis": diagnosis,
-		"status":    status,
+// deadLetter gives up on a task: the patient sees the error, and the task
+// goes to llm.tasks.dlq and the failures table for an admin to requeue.
+// Superseded tasks are simply dropped.
+func (w *LLMWorker) deadLetter(req models.DiagnosisRequest, attempts int, cause error) {
+	if !w.current(req) {
+		w.updateStatus(req.Patient.ID, req.Generation, "", "error") // Counts the discard
+		return
+	}
+	taskLog(req).Error("llm worker: giving up on diagnosis", "attempts", attempts, "error", cause)
+	w.updateStatus(req.Patient.ID, req.Generation, "Diagnosis unavailable - LLM service error", "error")
+
+	failure := &models.DiagnosisFailure{PatientID: req.Patient.ID, Generation: req.Generation, Attempts: attempts, Error: cause.Error()}
+	if w.Failures != nil {
+		recorded, err := w.Failures.Record(req, attempts, cause)
+		if err != nil {
+			taskLog(req).Warn("llm worker: failed to record diagnosis failure", "error", err)
+		} else {
+			failure = recorded
+		}
+	}
+	data, _ := json.Marshal(failure)
+	if err := queue.PublishDurable(LLMDeadLetterSubject, data); err != nil {
+		taskLog(req).Warn("llm worker: failed to dead-letter diagnosis", "error", err)
+	}
+}
+
+// taskContext carries the request ID of the assessment that queued the task
+func taskContext(req models.DiagnosisRequest) context.Context {
+	return auditctx.WithRequestID(context.Background(), req.RequestID)
+}
+
+// taskLog is the logger for one task, correlated with the request that queued it
+func taskLog(req models.DiagnosisRequest) *slog.Logger {
+	return logging.FromContext(taskContext(req)).With("patient_id", req.Patient.ID)
+}
+
+func (w *LLMWorker) current(req models.DiagnosisRequest) bool {
+	return w.Diagnoses == nil || w.Diagnoses.Current(req.Patient.ID, req.Generation)
+}
+
+func (w *LLMWorker) maxRetries() int {
+	if w.MaxRetries < 0 {
+		return 0
+	}
+	return w.MaxRetries
+}
+
+// backoff is the wait after the given failed attempt
+func (w *LLMWorker) backoff(attempt int) time.Duration {
+	base := w.RetryBackoff
+	if base <= 0 {
+		base = DefaultLLMRetryBackoff
+	}
+	return base << (attempt - 1)
+}
+
+// updateStatus publishes the result unless it has been superseded
+func (w *LLMWorker) updateStatus(patientID uint, gen uint64, diagnosis string, status string) {
+	if w.Diagnoses == nil {
+		w.writeStatus(patientID, diagnosis, status)
+		return
+	}
+	if !w.Diagnoses.Complete(patientID, gen, func() { w.writeStatus(patientID, diagnosis, status) }) {
+		slog.Info("llm worker: discarded stale diagnosis", "patient_id", patientID, "generation", gen)
+		return
+	}
+	if w.Store != nil {
+		if err := w.Store.Save(patientID, gen, diagnosis, status); err != nil {
+			slog.Warn("llm worker: failed to save diagnosis", "patient_id", patientID, "error", err)
+		}
+	}
+}
+
+// writeStatus stores the result for polling, then publishes it so the
+// WebSocket listeners on every instance push a "diagnosis_update"
+func (w *LLMWorker) writeStatus(patientID uint, diagnosis string, status string) {
+	cacheData := models.CachedDiagnosis{Diagnosis: diagnosis, Status: status}
+	if w.Coder != nil {
+		cacheData = w.Coder(patientID, diagnosis, status)
 	}
 	jsonData, _ := json.Marshal(cacheData)
-	cache.Set(fmt.Sprintf("diag:status:%d", patientID), jsonData, 1*time.Hour)
-	
-	// Globally broadcast via Redis Pub/Sub (Phase 6)
-	broadcastPayload := map[string]interface{}{
-		"patient_id": patientID,
-		"diagnosis":  diagnosis,
-		"status":     status,
-	}
-	bpJSON, _ := json.Marshal(broadcastPayload)
-	cache.RedisClient.Publish(context.Background(), "diagnosis_updates", bpJSON)
+	if err := cache.Set(fmt.Sprintf("diag:status:%d", patientID), jsonData, 1*time.Hour); err != nil {
+		slog.Warn("llm worker: failed to store diagnosis status", "patient_id", patientID, "error", err)
+	}
+
+	update, _ := json.Marshal(models.DiagnosisUpdate{PatientID: patientID, Diagnosis: diagnosis, Status: status})
+	if err := cache.Publish(cache.DiagnosisChannel, update); err != nil {
+		slog.Warn("llm worker: failed to publish diagnosis update", "patient_id", patientID, "error", err)
+	}
 }
@@ -54,6 +54,20 @@ export default function MedicationSafety({ analysis }: MedicationSafetyProps) {
                 )}
             </div>
 
+            {/* Interaction Warnings */}
+            {analysis?.interactions && analysis.interactions.length > 0 && (
+                <div className="mt-3 space-y-1">
+                    {analysis.interactions.map((x, i) => (
+                        <p key={i} className="text-[10px] text-slate-400">
+                            <span className={x.severity === 'major' ? 'text-red-400 font-bold' : 'text-amber-400'}>
+                                {x.drug_a} + {x.drug_b}
+                            </span>
+                            : {x.reason} ({x.severity})
+                        </p>
+                    ))}
+                </div>
+            )}
+
             {/* Footer Summary */}
             {analysis && analysis.risky.length > 0 && (
                 <div className="mt-4 pt-3 border-t border-white/5 flex items-center gap-2">
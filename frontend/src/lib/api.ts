@@ -55,14 +55,41 @@ export async function pollDiagnosis(patientId: number): Promise<DiagnosisRespons
 }
 
 /**
- * Fetch all patients
+ * Follow a patient's diagnosis over Server-Sent Events instead of polling.
+ * onUpdate gets the current state at once, then every change until it is
+ * ready or errored. Returns a function that closes the stream.
+ */
+export function streamDiagnosis(
+    patientId: number,
+    onUpdate: (diagnosis: DiagnosisResponse) => void,
+    onTimeout?: () => void,
+): () => void {
+    const source = new EventSource(`${API_BASE_URL}/api/diagnosis/${patientId}/stream`);
+    source.onmessage = (event) => {
+        const diagnosis: DiagnosisResponse = JSON.parse(event.data);
+        onUpdate(diagnosis);
+        if (diagnosis.status === 'ready' || diagnosis.status === 'error') {
+            source.close();
+        }
+    };
+    // The server ends long streams; stop EventSource from reconnecting on its own
+    source.addEventListener('timeout', () => {
+        source.close();
+        onTimeout?.();
+    });
+    return () => source.close();
+}
+
+/**
+ * Fetch the newest patients (first page of the queue)
  */
 export async function fetchPatients(): Promise<PatientRecord[]> {
     const response = await fetch(`${API_BASE_URL}/api/patients`);
     if (!response.ok) {
         throw new Error('Failed to fetch patients');
     }
-    return response.json();
+    const page: { total: number; page: number; items: PatientRecord[] } = await response.json();
+    return page.items;
 }
 
 /**
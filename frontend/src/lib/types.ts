@@ -31,9 +31,24 @@ export interface RiskScores {
     clinical_confidence: number;
 }
 
+export interface MedicationInteraction {
+    drug_a: string;
+    drug_b: string;
+    severity: 'minor' | 'moderate' | 'major';
+    reason: string;
+}
+
+export interface AllergyConflict {
+    medication: string;
+    allergy: string;
+    reason: string;
+}
+
 export interface MedicationAnalysis {
     risky: string[];
     safe: string[];
+    interactions?: MedicationInteraction[];
+    allergy_conflicts?: AllergyConflict[];
 }
 
 export interface ModelPrecision {
@@ -70,6 +85,7 @@ export interface PatientFormData {
     smoking: 'Yes' | 'No' | 'Former';
     alcohol: 'Yes' | 'No';
     medications: string; // Comma-separated list
+    allergies?: string; // Comma-separated list
 }
 
 /**
@@ -99,10 +115,13 @@ export interface AssessmentAPIResponse {
     diagnosis: string;
     diagnosis_status: 'pending' | 'ready' | 'error';
     emergency: boolean;
+    requires_review?: boolean; // A medication conflicts with a recorded allergy
     patient: PatientFormData;
     medication_analysis: {
         risky: string[];
         safe: string[];
+        interactions?: MedicationInteraction[];
+        allergy_conflicts?: AllergyConflict[];
     };
     model_precisions: Array<{ model_name: string; confidence: number }>;
 }
@@ -442,6 +442,8 @@ async def diagnose_patient(request: DiagnosisRequest):
         
     try:
         # Construct Context-Aware Prompt
+        # Mirrored by the backend (services.RenderDiagnosisPrompt) for transparency
+        # records: bump DiagnosisPromptVersion there when editing this prompt or the model.
         prompt = f"""
         You are an expert Clinical Decision Support System (Cardiologist & Endocrinologist).
         
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"os"
 	"testing"
 	"time"
 )
@@ -15,6 +16,21 @@ const (
 	MLServiceURL = "http://localhost:8000"
 )
 
+// apiKeyTransport signs /api requests with INTEGRATION_API_KEY, since the
+// backend rejects anonymous callers
+type apiKeyTransport struct{}
+
+func (apiKeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if key := os.Getenv("INTEGRATION_API_KEY"); key != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("X-API-Key", key)
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// apiClient is http.DefaultClient with credentials
+var apiClient = &http.Client{Transport: apiKeyTransport{}}
+
 // isServiceRunning checks if a service is available
 func isServiceRunning(url string) bool {
 	endpoint := "/health"
@@ -73,7 +89,7 @@ func TestGetPatients_Integration(t *testing.T) {
 		t.Skip("Backend not running, skipping integration test")
 	}
 
-	resp, err := http.Get(BackendURL + "/api/patients")
+	resp, err := apiClient.Get(BackendURL + "/api/patients")
 	if err != nil {
 		t.Fatalf("Get patients failed: %v", err)
 	}
@@ -110,7 +126,7 @@ func TestFullAssessmentFlow(t *testing.T) {
 
 	payloadBytes, _ := json.Marshal(patient)
 
-	resp, err := http.Post(BackendURL+"/api/assess", "application/json", bytes.NewReader(payloadBytes))
+	resp, err := apiClient.Post(BackendURL+"/api/assess", "application/json", bytes.NewReader(payloadBytes))
 	if err != nil {
 		t.Fatalf("Assessment request failed: %v", err)
 	}
@@ -156,7 +172,7 @@ func TestDiagnosisPoll(t *testing.T) {
 	}
 
 	payloadBytes, _ := json.Marshal(patient)
-	assessResp, err := http.Post(BackendURL+"/api/assess", "application/json", bytes.NewReader(payloadBytes))
+	assessResp, err := apiClient.Post(BackendURL+"/api/assess", "application/json", bytes.NewReader(payloadBytes))
 	if err != nil {
 		t.Fatalf("Assessment failed: %v", err)
 	}
@@ -172,7 +188,7 @@ func TestDiagnosisPoll(t *testing.T) {
 	}
 
 	// Poll for diagnosis (up to 30 seconds)
-	client := &http.Client{Timeout: 5 * time.Second}
+	client := &http.Client{Timeout: 5 * time.Second, Transport: apiKeyTransport{}}
 	var finalStatus string
 
 	for i := 0; i < 15; i++ {
@@ -0,0 +1,62 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/cache"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/workers"
+)
+
+// TestLLMWorker_PublishesDiagnosisUpdate tests that a finished diagnosis is
+// both stored for polling and published for the WebSocket listeners
+func TestLLMWorker_PublishesDiagnosisUpdate(t *testing.T) {
+	addr := os.Getenv("REDIS_URL")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	cache.InitRedis(addr)
+	if cache.Ping() != nil {
+		t.Skip("Redis not running, skipping integration test")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	pubsub := cache.RedisClient.Subscribe(ctx, cache.DiagnosisChannel)
+	defer pubsub.Close()
+	if _, err := pubsub.Receive(ctx); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	ml := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(models.DiagnosisResponse{Diagnosis: "Stage 1 hypertension", Status: "ready"})
+	}))
+	defer ml.Close()
+
+	const patientID = 987654
+	workers.NewLLMWorker(ml.URL).Process(models.DiagnosisRequest{Patient: models.PatientData{ID: patientID}})
+
+	msg, err := pubsub.ReceiveMessage(ctx)
+	if err != nil {
+		t.Fatalf("No diagnosis update received: %v", err)
+	}
+	var update models.DiagnosisUpdate
+	if err := json.Unmarshal([]byte(msg.Payload), &update); err != nil {
+		t.Fatalf("Malformed update %q: %v", msg.Payload, err)
+	}
+	if update.PatientID != patientID || update.Status != "ready" || update.Diagnosis != "Stage 1 hypertension" {
+		t.Errorf("Unexpected update %+v", update)
+	}
+
+	// The status key is written before the publish, so it's already there
+	if _, err := cache.Get("diag:status:987654"); err != nil {
+		t.Errorf("Expected the status key set, got %v", err)
+	}
+	cache.Delete("diag:status:987654")
+}
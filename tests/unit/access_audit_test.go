@@ -0,0 +1,139 @@
+package unit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/middleware"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var accessReader = auditctx.Identity{ID: "user:7", Role: auditctx.RoleDoctor}
+
+// newAccessAuditApp serves audited reads of patients 1-9 (others 404) as
+// accessReader, and the access history as an admin
+func newAccessAuditApp(t *testing.T) (*fiber.App, *gorm.DB, *services.AuditService) {
+	db := openTestAuditDB(t)
+	audit := services.NewAuditService(db)
+	h := handlers.NewBlockchainHandler(audit, nil)
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		if c.Get("X-Test-Admin") != "" {
+			auditctx.Set(c, auditctx.Identity{ID: "user:1", Role: auditctx.RoleAdmin})
+		} else {
+			auditctx.Set(c, accessReader)
+		}
+		return c.Next()
+	})
+	app.Get("/api/patients/:id", handlers.AuditReads(audit, services.EventPatientViewed), func(c *fiber.Ctx) error {
+		if id, _ := c.ParamsInt("id"); id > 9 {
+			return c.Status(404).JSON(fiber.Map{"error": "Patient not found"})
+		}
+		return c.JSON(fiber.Map{})
+	})
+	app.Get("/api/dashboard/summary", handlers.AuditReads(audit, services.EventDashboardViewed), func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{})
+	})
+	app.Get("/api/audit/patient/:id/access", middleware.RequireRole(auditctx.RoleAdmin), h.ListPatientAccess)
+	return app, db, audit
+}
+
+func getAccess(t *testing.T, app *fiber.App, path string, admin bool) (int, models.AuditLogPage) {
+	req := httptest.NewRequest("GET", path, nil)
+	if admin {
+		req.Header.Set("X-Test-Admin", "1")
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", path, err)
+	}
+	var page models.AuditLogPage
+	json.NewDecoder(resp.Body).Decode(&page)
+	return resp.StatusCode, page
+}
+
+func countAudit(db *gorm.DB, eventType string) int64 {
+	var n int64
+	db.Model(&models.AuditLog{}).Where("event_type = ?", eventType).Count(&n)
+	return n
+}
+
+func TestAccessAudit_BuffersReadsUntilFlushed(t *testing.T) {
+	app, db, audit := newAccessAuditApp(t)
+
+	for _, path := range []string{"/api/patients/3", "/api/patients/3", "/api/patients/4", "/api/patients/42", "/api/dashboard/summary"} {
+		getAccess(t, app, path, false)
+	}
+	audit.LogEvent("PATIENT_UPDATED", 3, nil, accessReader) // Written straight away, between buffered reads
+	if n := countAudit(db, services.EventPatientViewed); n != 0 {
+		t.Fatalf("Expected reads buffered, found %d written", n)
+	}
+
+	n, err := audit.FlushAccess()
+	if err != nil || n != 4 {
+		t.Fatalf("Expected 4 reads flushed (not the 404), got %d %v", n, err)
+	}
+	if countAudit(db, services.EventDashboardViewed) != 1 {
+		t.Error("Expected the dashboard view recorded")
+	}
+	if valid, _, err := audit.VerifyChain(); err != nil || !valid {
+		t.Errorf("Expected the chain intact after a batch, got %v %v", valid, err)
+	}
+	if n, _ := audit.FlushAccess(); n != 0 {
+		t.Errorf("Expected nothing left to flush, got %d", n)
+	}
+}
+
+func TestAccessAudit_HistoryByPatientIDOrHash(t *testing.T) {
+	app, _, audit := newAccessAuditApp(t)
+	getAccess(t, app, "/api/patients/3", false)
+	getAccess(t, app, "/api/patients/3", false)
+	getAccess(t, app, "/api/patients/4", false)
+	audit.LogEvent("PATIENT_UPDATED", 3, nil, accessReader) // Not a read
+
+	// Unflushed reads show up: the history flushes first
+	for _, id := range []string{"3", services.PatientHash(3)} {
+		code, page := getAccess(t, app, "/api/audit/patient/"+id+"/access", true)
+		if code != 200 || page.Total != 2 || len(page.Items) != 2 {
+			t.Fatalf("%s: expected 2 reads of patient 3, got %d total=%d", id, code, page.Total)
+		}
+		for _, e := range page.Items {
+			if e.EventType != services.EventPatientViewed || e.ActorID != accessReader.ID || e.ActorRole != accessReader.Role {
+				t.Errorf("Unexpected access entry %+v", e)
+			}
+		}
+	}
+
+	if code, _ := getAccess(t, app, "/api/audit/patient/3/access?limit=1&page=2", true); code != 200 {
+		t.Errorf("Expected paging accepted, got %d", code)
+	}
+	for _, bad := range []string{"abc", "0", "-1", "deadbeef"} {
+		if code, _ := getAccess(t, app, "/api/audit/patient/"+bad+"/access", true); code != 400 {
+			t.Errorf("%s: expected 400, got %d", bad, code)
+		}
+	}
+	if code, _ := getAccess(t, app, "/api/audit/patient/3/access", false); code != 403 {
+		t.Errorf("Expected non-admins refused, got %d", code)
+	}
+}
+
+func TestAccessAudit_FlushesEarlyWhenBatchFills(t *testing.T) {
+	app, db, audit := newAccessAuditApp(t)
+	audit.AccessBatchSize = 3
+	audit.StartAccessFlusher(time.Hour)
+
+	for i := 1; i <= 3; i++ {
+		getAccess(t, app, fmt.Sprintf("/api/patients/%d", i), false)
+	}
+	waitFor(t, "a full batch to be written", func() bool { return countAudit(db, services.EventPatientViewed) == 3 })
+}
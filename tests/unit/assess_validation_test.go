@@ -0,0 +1,153 @@
+package unit
+
+import (
+	"encoding/json"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/repositories"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func newValidationApp(t *testing.T) *fiber.App {
+	var hits atomic.Int64
+	h, db, _ := newTestPatientHandler(t, newFakeFullML(t, &hits).URL, handlers.NewWebSocketHandler())
+	db.AutoMigrate(&models.OverrideLog{}, &models.OverrideReason{})
+	feedback := handlers.NewFeedbackHandler(db, repositories.NewFeedbackRepository(db), services.NewOverrideService(db), services.NewAuditService(db))
+
+	app := fiber.New()
+	app.Post("/api/assess", h.AssessPatient)
+	app.Post("/api/feedback", feedback.SubmitFeedback)
+	return app
+}
+
+// assessWith posts a valid patient with the given fields changed
+func assessWith(t *testing.T, app *fiber.App, changes map[string]any) (int, map[string]any) {
+	patient := map[string]any{"age": 50, "gender": "Male", "systolic_bp": 120, "diastolic_bp": 80, "glucose": 100, "bmi": 25}
+	for k, v := range changes {
+		patient[k] = v
+	}
+	body, _ := json.Marshal(patient)
+	return postJSON(t, app, "/api/assess", string(body))
+}
+
+// failedFields lists the fields named in a 400's structured errors
+func failedFields(body map[string]any) string {
+	errs, _ := body["errors"].([]any)
+	var fields []string
+	for _, e := range errs {
+		if m, ok := e.(map[string]any); ok {
+			fields = append(fields, m["field"].(string))
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+func TestAssessPatient_RejectsOutOfRangeVitals(t *testing.T) {
+	app := newValidationApp(t)
+
+	bounds := []struct {
+		json, field string
+		min, max    int
+	}{
+		{"age", "Age", 0, 150},
+		{"systolic_bp", "SystolicBP", 50, 300},
+		{"diastolic_bp", "DiastolicBP", 30, 200},
+		{"glucose", "Glucose", 20, 600},
+		{"bmi", "BMI", 10, 80},
+		{"cholesterol", "Cholesterol", 50, 500},
+		{"heart_rate", "HeartRate", 30, 250},
+		{"steps", "Steps", 0, 100000},
+	}
+	for _, b := range bounds {
+		for _, v := range []int{b.min, b.max} {
+			if code, body := assessWith(t, app, map[string]any{b.json: v}); code != 200 {
+				t.Errorf("%s=%v: expected the boundary accepted, got %d %v", b.json, v, code, body)
+			}
+		}
+		for _, v := range []int{b.min - 1, b.max + 1} {
+			code, body := assessWith(t, app, map[string]any{b.json: v})
+			if code != 400 || failedFields(body) != b.field {
+				t.Errorf("%s=%v: expected 400 naming %s, got %d %v", b.json, v, b.field, code, body)
+			}
+		}
+	}
+
+	// The example from the bug report reports both fields at once
+	code, body := assessWith(t, app, map[string]any{"age": -5, "glucose": 9999})
+	if code != 400 || failedFields(body) != "Age,Glucose" || body["success"] != false {
+		t.Errorf("Expected both fields rejected, got %d %v", code, body)
+	}
+}
+
+func TestAssessPatient_RejectsUnknownChoices(t *testing.T) {
+	app := newValidationApp(t)
+
+	choices := []struct {
+		json, field string
+		valid       []string
+	}{
+		{"gender", "Gender", []string{"Male", "Female", "Other"}},
+		{"smoking", "Smoking", []string{"Yes", "No", "Former"}},
+		{"alcohol", "Alcohol", []string{"Yes", "No"}},
+		{"history_heart_disease", "HistoryHeartDisease", []string{"Yes", "No"}},
+		{"history_stroke", "HistoryStroke", []string{"Yes", "No"}},
+		{"history_diabetes", "HistoryDiabetes", []string{"Yes", "No"}},
+		{"history_high_chol", "HistoryHighChol", []string{"Yes", "No"}},
+	}
+	for _, c := range choices {
+		for _, v := range c.valid {
+			if code, body := assessWith(t, app, map[string]any{c.json: v}); code != 200 {
+				t.Errorf("%s=%q: expected it accepted, got %d %v", c.json, v, code, body)
+			}
+		}
+		code, body := assessWith(t, app, map[string]any{c.json: "Sometimes"})
+		if code != 400 || failedFields(body) != c.field {
+			t.Errorf("%s: expected 400 naming %s, got %d %v", c.json, c.field, code, body)
+		}
+	}
+}
+
+func TestAssessPatient_RequiredAndOptionalFields(t *testing.T) {
+	app := newValidationApp(t)
+
+	// Zero steps and omitted optional fields are fine
+	if code, body := assessWith(t, app, map[string]any{"steps": 0}); code != 200 {
+		t.Errorf("Expected zero steps accepted, got %d %v", code, body)
+	}
+	// Age 0 is a newborn, not a missing value
+	if code, body := assessWith(t, app, map[string]any{"age": 0}); code != 200 {
+		t.Errorf("Expected age 0 accepted, got %d %v", code, body)
+	}
+	for _, field := range []string{"age", "gender", "systolic_bp", "diastolic_bp", "glucose", "bmi"} {
+		if code, body := assessWith(t, app, map[string]any{field: nil}); code != 400 {
+			t.Errorf("Expected 400 without %s, got %d %v", field, code, body)
+		}
+	}
+}
+
+func TestSubmitFeedback_Validates(t *testing.T) {
+	app := newValidationApp(t)
+
+	cases := []struct {
+		body, field string
+	}{
+		{`{"approved": true}`, "AssessmentID"},
+		{`{"assessment_id": -1, "approved": true}`, "AssessmentID"},
+		{`{"assessment_id": 1, "notes": "` + strings.Repeat("x", 5001) + `"}`, "Notes"},
+	}
+	for _, c := range cases {
+		code, body := postJSON(t, app, "/api/feedback", c.body)
+		if code != 400 || failedFields(body) != c.field {
+			t.Errorf("Expected 400 naming %s, got %d %v", c.field, code, body)
+		}
+	}
+	if code, body := postJSON(t, app, "/api/feedback", `{"assessment_id": 1, "approved": true, "notes": "agree"}`); code != 200 {
+		t.Errorf("Expected valid feedback recorded, got %d %v", code, body)
+	}
+}
@@ -0,0 +1,137 @@
+package unit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"healthcare-backend/pkg/database"
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/repositories"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupSnapshotApp(t *testing.T) (*fiber.App, *gorm.DB, repositories.AssessmentRepository) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	db.AutoMigrate(&models.PatientData{}, &models.Assessment{}, &models.AssessmentPrecision{})
+
+	repo := repositories.NewAssessmentRepository(db)
+	h := handlers.NewAssessmentHandler(repo)
+
+	app := fiber.New()
+	app.Get("/api/assessments/:id/report", h.GetReport)
+	app.Get("/api/assessments/:id/verify", h.VerifySnapshot)
+	app.Get("/api/assessments/:id/compare/:other", h.Compare)
+	return app, db, repo
+}
+
+func getJSON(t *testing.T, app *fiber.App, path string) map[string]any {
+	resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		t.Fatalf("Expected 200 for %s, got %d: %s", path, resp.StatusCode, body)
+	}
+	out := map[string]any{}
+	json.Unmarshal(body, &out)
+	return out
+}
+
+// TestAssessmentSnapshot_ReportSurvivesPatientEdit tests that editing a patient doesn't rewrite history
+func TestAssessmentSnapshot_ReportSurvivesPatientEdit(t *testing.T) {
+	app, db, repo := setupSnapshotApp(t)
+
+	patient := models.PatientData{Age: 52, Gender: "Male", SystolicBP: 150, Glucose: 110}
+	db.Create(&patient)
+
+	assessment := models.Assessment{PatientID: patient.ID, HeartRisk: 42}
+	if err := assessment.SetPatientSnapshot(patient); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	repo.Create(&assessment)
+
+	before := getJSON(t, app, "/api/assessments/1/report")
+
+	db.Model(&models.PatientData{}).Where("id = ?", patient.ID).Updates(map[string]any{"age": 80, "systolic_bp": 190})
+
+	after := getJSON(t, app, "/api/assessments/1/report")
+	if after["patient"].(map[string]any)["age"] != float64(52) {
+		t.Errorf("Expected snapshot age 52, got %v", after["patient"].(map[string]any)["age"])
+	}
+	beforeJSON, _ := json.Marshal(before)
+	afterJSON, _ := json.Marshal(after)
+	if string(beforeJSON) != string(afterJSON) {
+		t.Errorf("Report changed after patient edit:\nbefore: %s\nafter:  %s", beforeJSON, afterJSON)
+	}
+
+	verify := getJSON(t, app, "/api/assessments/1/verify")
+	if verify["valid"] != true {
+		t.Errorf("Expected intact snapshot, got %v", verify)
+	}
+}
+
+// TestAssessmentSnapshot_TamperAndCompare tests hash verification and snapshot comparison
+func TestAssessmentSnapshot_TamperAndCompare(t *testing.T) {
+	app, db, repo := setupSnapshotApp(t)
+
+	first := models.Assessment{PatientID: 1, HeartRisk: 30}
+	first.SetPatientSnapshot(models.PatientData{ID: 1, Age: 50, Gender: "Female", Glucose: 100})
+	second := models.Assessment{PatientID: 2, HeartRisk: 45}
+	second.SetPatientSnapshot(models.PatientData{ID: 2, Age: 50, Gender: "Female", Glucose: 160})
+	repo.Create(&first)
+	repo.Create(&second)
+
+	cmp := getJSON(t, app, "/api/assessments/1/compare/2")
+	changes := cmp["patient_inputs"].([]any)
+	if len(changes) != 1 || changes[0].(map[string]any)["field"] != "glucose" {
+		t.Errorf("Expected only glucose to differ, got %v", changes)
+	}
+	if cmp["risk_delta"].(map[string]any)["heart_risk_score"] != float64(15) {
+		t.Errorf("Expected heart delta 15, got %v", cmp["risk_delta"])
+	}
+
+	db.Model(&models.Assessment{}).Where("id = ?", first.ID).Update("patient_snapshot", `{"age":20}`)
+	if verify := getJSON(t, app, "/api/assessments/1/verify"); verify["valid"] != false {
+		t.Errorf("Expected tampered snapshot to fail verification, got %v", verify)
+	}
+}
+
+// TestAssessmentSnapshot_Backfill tests that legacy assessments get a flagged snapshot
+func TestAssessmentSnapshot_Backfill(t *testing.T) {
+	_, db, repo := setupSnapshotApp(t)
+
+	patient := models.PatientData{Age: 61, Gender: "Other"}
+	db.Create(&patient)
+	legacy := models.Assessment{PatientID: patient.ID}
+	repo.Create(&legacy)
+
+	n, err := database.BackfillAssessmentSnapshots(db)
+	if err != nil || n != 1 {
+		t.Fatalf("Expected 1 backfilled assessment, got %d (%v)", n, err)
+	}
+
+	stored, _ := repo.GetByID(legacy.ID)
+	if !stored.SnapshotBackfilled {
+		t.Error("Expected backfilled marker")
+	}
+	if p, err := stored.Patient(); err != nil || p.Age != 61 {
+		t.Errorf("Expected backfilled age 61, got %+v (%v)", p, err)
+	}
+
+	if n, _ := database.BackfillAssessmentSnapshots(db); n != 0 {
+		t.Errorf("Expected backfill to be idempotent, got %d", n)
+	}
+}
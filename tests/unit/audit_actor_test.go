@@ -0,0 +1,225 @@
+package unit
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/middleware"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/repositories"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var testJWTSecret = []byte("test-secret")
+
+func setupActorApp(t *testing.T) (*fiber.App, *gorm.DB, *services.AuditService) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	db.AutoMigrate(&models.Feedback{}, &models.OverrideLog{}, &models.OverrideReason{}, &models.AuditLog{})
+
+	audit := services.NewAuditService(db)
+	h := handlers.NewFeedbackHandler(db, repositories.NewFeedbackRepository(db), services.NewOverrideService(db), audit)
+
+	app := fiber.New()
+	app.Use(middleware.ResolveActor(middleware.ActorConfig{
+		JWTSecret: testJWTSecret,
+		APIKeys:   []middleware.APIKey{{ID: "triage-bot", Role: "service", Key: "k-123"}},
+	}))
+	app.Post("/api/feedback", h.SubmitFeedback)
+	return app, db, audit
+}
+
+func signJWT(t *testing.T, secret []byte, claims map[string]any) string {
+	enc := func(v any) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	unsigned := enc(map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + enc(claims)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// submitFeedback posts feedback with the given headers and returns the audit row it wrote
+func submitFeedback(t *testing.T, app *fiber.App, db *gorm.DB, headers map[string]string) models.AuditLog {
+	req := httptest.NewRequest("POST", "/api/feedback", strings.NewReader(`{"assessment_id": 1, "approved": true, "notes": "agree"}`))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := app.Test(req)
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("Feedback failed: %v (status %v)", err, resp.StatusCode)
+	}
+
+	var entry models.AuditLog
+	if err := db.Where("event_type = ?", "DOCTOR_FEEDBACK").Order("id DESC").First(&entry).Error; err != nil {
+		t.Fatalf("No audit row: %v", err)
+	}
+	return entry
+}
+
+func TestAuditActor_JWTSubject(t *testing.T) {
+	app, db, _ := setupActorApp(t)
+	token := signJWT(t, testJWTSecret, map[string]any{"sub": "dr.yilmaz", "role": "clinician", "exp": time.Now().Add(time.Hour).Unix()})
+
+	entry := submitFeedback(t, app, db, map[string]string{"Authorization": "Bearer " + token})
+	if entry.ActorID != "dr.yilmaz" || entry.ActorRole != "clinician" {
+		t.Errorf("Expected dr.yilmaz/clinician, got %s/%s", entry.ActorID, entry.ActorRole)
+	}
+}
+
+func TestAuditActor_APIKey(t *testing.T) {
+	app, db, _ := setupActorApp(t)
+
+	entry := submitFeedback(t, app, db, map[string]string{"X-API-Key": "k-123"})
+	if entry.ActorID != "apikey:triage-bot" || entry.ActorRole != "service" {
+		t.Errorf("Expected apikey:triage-bot/service, got %s/%s", entry.ActorID, entry.ActorRole)
+	}
+}
+
+func TestAuditActor_Anonymous(t *testing.T) {
+	app, db, _ := setupActorApp(t)
+
+	cases := map[string]map[string]string{
+		"no credentials": {},
+		"forged jwt":     {"Authorization": "Bearer " + signJWT(t, []byte("wrong"), map[string]any{"sub": "mallory", "role": "admin"})},
+		"expired jwt":    {"Authorization": "Bearer " + signJWT(t, testJWTSecret, map[string]any{"sub": "dr.old", "exp": time.Now().Add(-time.Minute).Unix()})},
+		"unknown key":    {"X-API-Key": "nope"},
+	}
+	for name, headers := range cases {
+		entry := submitFeedback(t, app, db, headers)
+		if entry.ActorID != "anonymous" || entry.ActorRole != "anonymous" {
+			t.Errorf("%s: expected anonymous, got %s/%s", name, entry.ActorID, entry.ActorRole)
+		}
+	}
+}
+
+func TestAuditActor_RoleIsPartOfChain(t *testing.T) {
+	app, db, audit := setupActorApp(t)
+	submitFeedback(t, app, db, map[string]string{"X-API-Key": "k-123"})
+
+	if ok, _, err := audit.VerifyChain(); !ok {
+		t.Fatalf("Chain should verify: %v", err)
+	}
+
+	db.Model(&models.AuditLog{}).Where("1 = 1").Update("actor_role", "admin")
+	if ok, _, _ := audit.VerifyChain(); ok {
+		t.Error("Changing the recorded role should break the chain")
+	}
+}
+
+func TestParseAPIKeys(t *testing.T) {
+	keys, err := middleware.ParseAPIKeys([]string{"bot:service:abc:def"})
+	if err != nil || len(keys) != 1 || keys[0].ID != "bot" || keys[0].Role != "service" || keys[0].Key != "abc:def" {
+		t.Errorf("Unexpected parse: %+v, %v", keys, err)
+	}
+	if _, err := middleware.ParseAPIKeys([]string{"missing-role"}); err == nil {
+		t.Error("Expected malformed entry to be rejected")
+	}
+}
+
+func TestAuditActor_StrictModeRefusesEmptyActor(t *testing.T) {
+	audit := services.NewAuditService(openTestAuditDB(t))
+	if _, err := audit.LogEvent("TEST_EVENT", 1, nil, auditctx.Identity{}); err != nil {
+		t.Fatalf("Expected lenient mode to log, got %v", err)
+	}
+
+	audit.RequireActor = true
+	for _, actor := range []auditctx.Identity{{}, {ID: "dr.x"}, {Role: auditctx.RoleDoctor}} {
+		if _, err := audit.LogEvent("TEST_EVENT", 1, nil, actor); !errors.Is(err, services.ErrMissingActor) {
+			t.Errorf("Expected %+v refused, got %v", actor, err)
+		}
+	}
+	if _, err := audit.LogEvent("TEST_EVENT", 1, nil, auditctx.System); err != nil {
+		t.Errorf("Expected a named actor logged, got %v", err)
+	}
+	if ok, _, err := audit.VerifyChain(); !ok {
+		t.Errorf("Expected refused events to leave the chain intact: %v", err)
+	}
+}
+
+func TestAuditActor_ListEventsByActor(t *testing.T) {
+	db := openTestAuditDB(t)
+	audit := services.NewAuditService(db)
+	doctor := auditctx.Identity{ID: "user:7", Role: auditctx.RoleDoctor}
+	for i := 0; i < 5; i++ {
+		audit.LogEvent("DOCTOR_FEEDBACK", uint(i), nil, doctor)
+		audit.LogEvent("AI_PREDICTION", uint(i), nil, auditctx.System)
+	}
+
+	h := handlers.NewBlockchainHandler(audit, nil)
+	app := fiber.New()
+	app.Use(middleware.ResolveActor(middleware.ActorConfig{
+		JWTSecret: testJWTSecret,
+		APIKeys:   []middleware.APIKey{{ID: "ops", Role: auditctx.RoleAdmin, Key: "k-admin"}},
+	}))
+	app.Get("/api/audit/actors/:id", h.ListActorEvents)
+	get := func(path string, headers map[string]string) (int, models.AuditLogPage) {
+		req := httptest.NewRequest("GET", path, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		var page models.AuditLogPage
+		json.NewDecoder(resp.Body).Decode(&page)
+		return resp.StatusCode, page
+	}
+	admin := map[string]string{"X-API-Key": "k-admin"}
+
+	code, page := get("/api/audit/actors/user:7?page=2&limit=2", admin)
+	if code != 200 || page.Total != 5 || len(page.Items) != 2 || page.Page != 2 {
+		t.Fatalf("Expected page 2 of the doctor's 5 events, got %d %+v", code, page)
+	}
+	for _, e := range page.Items {
+		if e.ActorID != "user:7" || e.ActorRole != auditctx.RoleDoctor || e.EventType != "DOCTOR_FEEDBACK" {
+			t.Errorf("Unexpected entry %+v", e)
+		}
+	}
+	if page.Items[0].ID <= page.Items[1].ID {
+		t.Error("Expected newest first")
+	}
+	if code, page := get("/api/audit/actors/nobody", admin); code != 200 || page.Total != 0 || page.Items == nil {
+		t.Errorf("Expected an empty page for an unknown actor, got %d %+v", code, page)
+	}
+
+	// Everyone else may only list their own events
+	self, _ := middleware.IssueJWT(doctor, testJWTSecret, time.Hour, time.Now())
+	bearer := map[string]string{"Authorization": "Bearer " + self}
+	if code, page := get("/api/audit/actors/user:7", bearer); code != 200 || page.Total != 5 {
+		t.Errorf("Expected the doctor to list their own events, got %d %+v", code, page)
+	}
+	if code, _ := get("/api/audit/actors/system", bearer); code != 403 {
+		t.Errorf("Expected another actor's events refused, got %d", code)
+	}
+	// Anonymous callers (with AUTH_REQUIRED=false) would see every other one's trail
+	audit.LogEvent("PATIENT_VIEWED", 3, nil, auditctx.Anonymous)
+	if code, _ := get("/api/audit/actors/anonymous", nil); code != 403 {
+		t.Errorf("Expected the shared anonymous trail refused, got %d", code)
+	}
+	if code, _ := get("/api/audit/actors/user:7?limit=0", admin); code != 400 {
+		t.Errorf("Expected a bad limit rejected, got %d", code)
+	}
+}
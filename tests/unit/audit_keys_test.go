@@ -0,0 +1,88 @@
+package unit
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+)
+
+func TestLoadSigningKey_GeneratesOnceThenReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "audit.pem")
+
+	first, err := services.LoadSigningKey("", path)
+	if err != nil {
+		t.Fatalf("First boot failed: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("Expected the generated key written with mode 0600, got %v %v", info, err)
+	}
+	again, err := services.LoadSigningKey("", path)
+	if err != nil || !first.Equal(again) {
+		t.Fatalf("Expected the same key on the next boot, got %v", err)
+	}
+
+	pemText, _ := os.ReadFile(path)
+	fromEnv, err := services.LoadSigningKey(string(pemText), "/nonexistent/ignored.pem")
+	if err != nil || !first.Equal(fromEnv) {
+		t.Errorf("Expected PEM text to take precedence over the path, got %v", err)
+	}
+	if _, err := services.LoadSigningKey("not a key", ""); err == nil {
+		t.Error("Expected malformed PEM text rejected")
+	}
+}
+
+func TestAuditKeys_SignaturesVerifyAcrossRestarts(t *testing.T) {
+	db := openTestAuditDB(t)
+	_, oldKey, _ := ed25519.GenerateKey(nil)
+	_, newKey, _ := ed25519.GenerateKey(nil)
+
+	// Yesterday's process
+	before := services.NewAuditService(db)
+	before.UseSigningKey(oldKey)
+	entry, _ := before.LogEvent("AI_PREDICTION", 7, map[string]any{"heart_risk": 30}, auditctx.System)
+	if entry.KeyID != services.KeyID(oldKey.Public().(ed25519.PublicKey)) {
+		t.Fatalf("Expected the entry tagged with its key ID, got %q", entry.KeyID)
+	}
+
+	// Today's process, after a key rotation
+	after := services.NewAuditService(db)
+	after.UseSigningKey(newKey)
+	after.LogEvent("DOCTOR_FEEDBACK", 7, map[string]any{"approved": true}, auditctx.System)
+	if ok, n, err := after.VerifyChain(); !ok || n != 2 {
+		t.Fatalf("Expected both keys' entries to verify, got %v %d %v", ok, n, err)
+	}
+
+	keys, err := after.ListKeys()
+	if err != nil || len(keys) != 2 {
+		t.Fatalf("Expected two signing keys, got %+v %v", keys, err)
+	}
+	if keys[0].KeyID != entry.KeyID || keys[0].Current || !keys[1].Current || keys[1].Entries != 1 {
+		t.Errorf("Expected the old key first and the new one current, got %+v", keys)
+	}
+
+	// A forged signature breaks verification even though every hash still matches
+	var forged models.AuditLog
+	db.First(&forged, entry.ID)
+	_, mallory, _ := ed25519.GenerateKey(nil)
+	db.Model(&forged).Update("actor_signature", hex.EncodeToString(ed25519.Sign(mallory, []byte("anything"))))
+	if ok, n, _ := after.VerifyChain(); ok || n != 0 {
+		t.Errorf("Expected the forged signature detected at entry 0, got %v %d", ok, n)
+	}
+
+	// So does swapping in another key under the original key ID
+	db.Model(&forged).Updates(map[string]any{
+		"actor_signature":  entry.ActorSignature,
+		"actor_public_key": hex.EncodeToString(mallory.Public().(ed25519.PublicKey)),
+	})
+	var swapped models.AuditLog
+	db.First(&swapped, entry.ID)
+	if err := after.VerifySignature(swapped); err == nil {
+		t.Error("Expected a public key that doesn't match the key ID rejected")
+	}
+}
@@ -0,0 +1,113 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestAuditVerify_IncrementalFromCheckpoint(t *testing.T) {
+	db := openTestAuditDB(t)
+	audit := services.NewAuditService(db)
+	logEvents := func(n int) {
+		for i := 0; i < n; i++ {
+			audit.LogEvent("AI_PREDICTION", uint(i), map[string]any{"n": i}, auditctx.System)
+		}
+	}
+
+	logEvents(5)
+	res, err := audit.Verify(false)
+	if err != nil || !res.Valid || res.Incremental || res.Checked != 5 || res.CheckedFrom != 1 || res.CheckedTo != 5 {
+		t.Fatalf("Expected the first pass to check all 5 entries, got %+v %v", res, err)
+	}
+
+	// Tamper with an entry behind the checkpoint: the fast path never re-reads it
+	db.Model(&models.AuditLog{}).Where("id = ?", 2).Update("payload_hash", "forged")
+	logEvents(2)
+	res, err = audit.Verify(false)
+	if err != nil || !res.Valid || !res.Incremental || res.Checked != 2 || res.CheckedFrom != 6 || res.CheckedTo != 7 || res.Length != 7 {
+		t.Fatalf("Expected only entries 6-7 checked out of 7, got %+v %v", res, err)
+	}
+	if res, _ := audit.Verify(false); res.Checked != 0 || !res.Valid {
+		t.Errorf("Expected nothing new to check, got %+v", res)
+	}
+
+	// The escape hatch re-scans everything and finds it
+	res, err = audit.Verify(true)
+	if err != nil || res.Valid || res.CheckedTo != 2 || res.Checked != 1 {
+		t.Fatalf("Expected a full scan to stop at entry 2, got %+v %v", res, err)
+	}
+}
+
+func TestAuditVerify_ChangedCheckpointForcesFullScan(t *testing.T) {
+	db := openTestAuditDB(t)
+	audit := services.NewAuditService(db)
+	for i := 0; i < 3; i++ {
+		audit.LogEvent("AI_PREDICTION", uint(i), map[string]any{"n": i}, auditctx.System)
+	}
+	audit.Verify(false)
+
+	// Rewriting the checkpoint entry itself can't slip past
+	db.Model(&models.AuditLog{}).Where("id = ?", 3).Update("current_hash", "forged")
+	res, err := audit.Verify(false)
+	if err != nil || res.Valid || res.Incremental || res.CheckedTo != 3 {
+		t.Errorf("Expected a full re-scan catching entry 3, got %+v %v", res, err)
+	}
+}
+
+func TestAuditVerify_Endpoint(t *testing.T) {
+	db := openTestAuditDB(t)
+	audit := services.NewAuditService(db)
+	for i := 0; i < 4; i++ {
+		audit.LogEvent("AI_PREDICTION", uint(i), map[string]any{"n": i}, auditctx.System)
+	}
+	app := fiber.New()
+	app.Get("/api/blockchain/verify", handlers.NewBlockchainHandler(audit, nil).VerifyChain)
+
+	verify := func(url string) map[string]any {
+		resp, err := app.Test(httptest.NewRequest("GET", url, nil))
+		if err != nil || resp.StatusCode != 200 {
+			t.Fatalf("Verify failed: %v %v", err, resp)
+		}
+		var body map[string]any
+		json.NewDecoder(resp.Body).Decode(&body)
+		return body
+	}
+
+	verify("/api/blockchain/verify")
+	audit.LogEvent("DOCTOR_FEEDBACK", 1, map[string]any{"approved": true}, auditctx.System)
+	body := verify("/api/blockchain/verify")
+	if body["checked_from"] != float64(5) || body["checked_to"] != float64(5) || body["length"] != float64(5) || body["incremental"] != true {
+		t.Errorf("Expected only the new entry checked, got %v", body)
+	}
+	body = verify("/api/blockchain/verify?full=true")
+	if body["checked_from"] != float64(1) || body["checked"] != float64(5) || body["incremental"] != false {
+		t.Errorf("Expected ?full=true to re-scan from the start, got %v", body)
+	}
+}
+
+// BenchmarkAuditVerify_Incremental measures a verify call with one new entry
+// on top of a long verified chain
+func BenchmarkAuditVerify_Incremental(b *testing.B) {
+	db := openTestAuditDB(b)
+	audit := services.NewAuditService(db)
+	for i := 0; i < 5000; i++ {
+		audit.LogEvent("AI_PREDICTION", uint(i), map[string]any{"n": i}, auditctx.System)
+	}
+	audit.Verify(true)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		audit.LogEvent("AI_PREDICTION", 1, map[string]any{"n": i}, auditctx.System)
+		if res, _ := audit.Verify(false); res.Checked != 1 {
+			b.Fatalf("Expected one entry checked, got %+v", res)
+		}
+	}
+}
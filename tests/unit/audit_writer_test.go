@@ -0,0 +1,106 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"gorm.io/gorm"
+)
+
+func TestAuditWriter_ConcurrentEventsChainInOrder(t *testing.T) {
+	db := openTestAuditDB(t)
+	audit := services.NewAuditService(db)
+	audit.StartWriter(1) // Callers block on the full queue rather than dropping
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 40; i++ {
+		wg.Add(1)
+		go func(id uint) {
+			defer wg.Done()
+			if entry, err := audit.LogEvent("PATIENT_UPDATED", id, nil, auditctx.System); err != nil || entry.CurrentHash == "" {
+				t.Errorf("Expected a sealed entry, got %+v %v", entry, err)
+			}
+		}(uint(i))
+	}
+	audit.LogAccess(services.EventPatientViewed, 1, "/api/patients/1", auditctx.System)
+	wg.Wait()
+
+	if err := audit.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	var n int64
+	db.Model(&models.AuditLog{}).Count(&n)
+	if n != 41 {
+		t.Fatalf("Expected 41 entries saved after Flush, got %d", n)
+	}
+	if valid, _, err := audit.VerifyChain(); err != nil || !valid {
+		t.Errorf("Expected the chain intact, got %v %v", valid, err)
+	}
+	if stats := audit.Stats(); stats.Written != 41 || stats.Queued != 0 || stats.Dropped != 0 {
+		t.Errorf("Unexpected writer stats %+v", stats)
+	}
+}
+
+func TestAuditWriter_RetriesUntilSaved(t *testing.T) {
+	db := openTestAuditDB(t)
+	audit := services.NewAuditService(db)
+	audit.StartWriter(8)
+
+	// The database refuses the next three audit inserts
+	var failures atomic.Int32
+	failures.Store(3)
+	db.Callback().Create().Before("gorm:create").Register("test:fail_audit", func(tx *gorm.DB) {
+		if tx.Statement.Table == "audit_logs" && failures.Add(-1) >= 0 {
+			tx.AddError(errors.New("database is down"))
+		}
+	})
+
+	// Callers are not failed by the writer: the entries are sealed and queued
+	for i := uint(1); i <= 3; i++ {
+		if _, err := audit.LogEvent("PATIENT_UPDATED", i, nil, auditctx.System); err != nil {
+			t.Fatalf("Expected LogEvent to succeed while the database is down, got %v", err)
+		}
+	}
+	if err := audit.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	var n int64
+	db.Model(&models.AuditLog{}).Count(&n)
+	if n != 3 {
+		t.Fatalf("Expected every entry saved once the database is back, got %d", n)
+	}
+	if valid, _, err := audit.VerifyChain(); err != nil || !valid {
+		t.Errorf("Expected the chain intact, got %v %v", valid, err)
+	}
+	if stats := audit.Stats(); stats.Written != 3 || stats.Dropped != 0 || stats.Queued != 0 {
+		t.Errorf("Expected nothing dropped, got %+v", stats)
+	}
+}
+
+func TestAuditWriter_CloseGivesUpAtDeadline(t *testing.T) {
+	db := openTestAuditDB(t)
+	audit := services.NewAuditService(db)
+	audit.StartWriter(8)
+	db.Migrator().DropTable(&models.AuditLog{})
+
+	if _, err := audit.LogEvent("PATIENT_UPDATED", 1, nil, auditctx.System); err != nil {
+		t.Fatalf("LogEvent failed: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if err := audit.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected Close to report the deadline, got %v", err)
+	}
+	if stats := audit.Stats(); stats.Written != 0 || stats.Dropped != 1 || stats.Queued != 0 {
+		t.Errorf("Expected the unsaved entry dropped at shutdown, got %+v", stats)
+	}
+}
@@ -0,0 +1,285 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/middleware"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const authAdminKey = "auth-admin-key"
+
+// newAuthApp wires auth the way cmd/server does, with stub handlers behind it
+func newAuthApp(t *testing.T) (*fiber.App, *gorm.DB) {
+	db := openTestAuditDB(t)
+	db.AutoMigrate(&models.User{})
+	db.AutoMigrate(&models.RefreshToken{})
+	revocations := services.NewTokenRevocations()
+	actors := middleware.ActorConfig{
+		JWTSecret:   testJWTSecret,
+		APIKeys:     []middleware.APIKey{{ID: "ops", Role: auditctx.RoleAdmin, Key: authAdminKey}},
+		Revocations: revocations,
+	}
+	auth := handlers.NewAuthHandler(services.NewUserService(db), services.NewAuditService(db), revocations, testJWTSecret)
+
+	ok := func(c *fiber.Ctx) error { return c.JSON(fiber.Map{"actor": auditctx.Actor(c)}) }
+	app := fiber.New()
+	app.Use(middleware.ResolveActor(actors))
+	app.Use(middleware.RequireAuth("/api/auth/login", "/api/auth/refresh", "/api/auth/logout", "/api/intake/"))
+	app.Use("/ws", middleware.WebSocketAuth(actors))
+	app.Post("/api/auth/login", auth.Login)
+	app.Post("/api/auth/refresh", auth.Refresh)
+	app.Post("/api/auth/logout", auth.Logout)
+	app.Post("/api/auth/register", middleware.RequireRole(auditctx.RoleAdmin), auth.Register)
+	app.Get("/api/patients", ok)
+	app.Post("/api/intake/:token", ok)
+	app.Post("/api/feedback", middleware.RequireRole(auditctx.RoleDoctor), ok)
+	app.Post("/api/blockchain/backup", middleware.RequireRole(auditctx.RoleAdmin), ok)
+	admin := app.Group("/api/admin", middleware.RequireRole(auditctx.RoleAdmin))
+	admin.Get("/db/backups/:name/download", ok)
+	admin.Put("/privacy/mode", ok)
+	admin.Put("/flags/:name", ok)
+	admin.Post("/chaos", ok)
+	app.Post(middleware.SelfTestPath, ok)
+	app.Get("/api/notifications", middleware.RequireRole(auditctx.RoleAdmin), ok)
+	app.Get("/ws/diagnostics", ok)
+	app.Get("/health/live", ok)
+	return app, db
+}
+
+func authRequest(t *testing.T, app *fiber.App, method, path, body string, headers map[string]string) (int, map[string]any) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := app.Test(req, 10000)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	var out map[string]any
+	json.NewDecoder(resp.Body).Decode(&out)
+	return resp.StatusCode, out
+}
+
+// login registers an account as the admin key and returns its access token
+func login(t *testing.T, app *fiber.App, email, role string) string {
+	token, _ := loginWithRefresh(t, app, email, role)
+	return token
+}
+
+func loginWithRefresh(t *testing.T, app *fiber.App, email, role string) (string, string) {
+	admin := map[string]string{"X-API-Key": authAdminKey}
+	if code, body := authRequest(t, app, "POST", "/api/auth/register", `{"email": "`+email+`", "password": "correct horse", "role": "`+role+`"}`, admin); code != 201 {
+		t.Fatalf("Register %s failed: %d %v", email, code, body)
+	}
+	code, body := authRequest(t, app, "POST", "/api/auth/login", `{"email": "`+strings.ToUpper(email)+`", "password": "correct horse"}`, nil)
+	if code != 200 || body["token"] == nil || body["refresh_token"] == nil {
+		t.Fatalf("Login %s failed: %d %v", email, code, body)
+	}
+	return body["token"].(string), body["refresh_token"].(string)
+}
+
+func TestAuth_LoginIssuesAWorkingToken(t *testing.T) {
+	app, db := newAuthApp(t)
+	token := login(t, app, "dr.demir@clinic.example", auditctx.RoleDoctor)
+
+	code, body := authRequest(t, app, "GET", "/api/patients", "", map[string]string{"Authorization": "Bearer " + token})
+	actor, _ := body["actor"].(map[string]any)
+	if code != 200 || actor["role"] != auditctx.RoleDoctor || !strings.HasPrefix(actor["id"].(string), "user:") {
+		t.Errorf("Expected the token accepted as the doctor, got %d %v", code, body)
+	}
+
+	var user models.User
+	db.First(&user)
+	if user.Email != "dr.demir@clinic.example" || user.PasswordHash == "" || strings.Contains(user.PasswordHash, "correct horse") {
+		t.Errorf("Expected a normalised email and a hashed password, got %+v", user)
+	}
+	var logins int64
+	db.Model(&models.AuditLog{}).Where("event_type = ?", "LOGIN").Count(&logins)
+	if logins != 1 {
+		t.Errorf("Expected the login audited, got %d", logins)
+	}
+}
+
+func TestAuth_RejectsBadCredentials(t *testing.T) {
+	app, _ := newAuthApp(t)
+	login(t, app, "nurse@clinic.example", auditctx.RoleUser)
+
+	for _, body := range []string{
+		`{"email": "nurse@clinic.example", "password": "wrong password"}`,
+		`{"email": "nobody@clinic.example", "password": "correct horse"}`,
+	} {
+		if code, resp := authRequest(t, app, "POST", "/api/auth/login", body, nil); code != 401 || resp["token"] != nil {
+			t.Errorf("Expected 401 for %s, got %d %v", body, code, resp)
+		}
+	}
+
+	expired, _ := middleware.IssueJWT(auditctx.Identity{ID: "user:1", Role: auditctx.RoleAdmin}, testJWTSecret, time.Hour, time.Now().Add(-2*time.Hour))
+	forged, _ := middleware.IssueJWT(auditctx.Identity{ID: "user:1", Role: auditctx.RoleAdmin}, []byte("wrong"), time.Hour, time.Now())
+	for name, token := range map[string]string{"expired": expired, "forged": forged} {
+		if code, _ := authRequest(t, app, "GET", "/api/patients", "", map[string]string{"Authorization": "Bearer " + token}); code != 401 {
+			t.Errorf("Expected a %s token rejected, got %d", name, code)
+		}
+	}
+}
+
+func TestAuth_RegisterIsAdminOnlyAndValidates(t *testing.T) {
+	app, _ := newAuthApp(t)
+	doctor := map[string]string{"Authorization": "Bearer " + login(t, app, "dr.kaya@clinic.example", auditctx.RoleDoctor)}
+	admin := map[string]string{"X-API-Key": authAdminKey}
+
+	if code, _ := authRequest(t, app, "POST", "/api/auth/register", `{"email": "x@clinic.example", "password": "long enough", "role": "admin"}`, doctor); code != 403 {
+		t.Errorf("Expected a doctor unable to register accounts, got %d", code)
+	}
+	if code, _ := authRequest(t, app, "POST", "/api/auth/register", `{"email": "x@clinic.example", "password": "long enough"}`, nil); code != 401 {
+		t.Errorf("Expected anonymous registration refused, got %d", code)
+	}
+	cases := map[string]int{
+		`{"email": "not-an-email", "password": "long enough"}`:                      400,
+		`{"email": "y@clinic.example", "password": "short"}`:                        400,
+		`{"email": "y@clinic.example", "password": "long enough", "role": "owner"}`: 400,
+		`{"email": "dr.kaya@clinic.example", "password": "long enough"}`:            409,
+	}
+	for body, want := range cases {
+		if code, resp := authRequest(t, app, "POST", "/api/auth/register", body, admin); code != want {
+			t.Errorf("%s: expected %d, got %d %v", body, want, code, resp)
+		}
+	}
+}
+
+func TestAuth_ProtectsAPIAndEnforcesRoles(t *testing.T) {
+	app, _ := newAuthApp(t)
+	doctor := map[string]string{"Authorization": "Bearer " + login(t, app, "dr.aydin@clinic.example", auditctx.RoleDoctor)}
+	user := map[string]string{"Authorization": "Bearer " + login(t, app, "clerk@clinic.example", auditctx.RoleUser)}
+	admin := map[string]string{"X-API-Key": authAdminKey}
+
+	cases := []struct {
+		method, path string
+		headers      map[string]string
+		want         int
+	}{
+		{"GET", "/api/patients", nil, 401},
+		{"GET", "/API/patients", nil, 401}, // Routes match case-insensitively, so must auth
+		{"GET", "/Api/Patients", nil, 401},
+		{"POST", "/API/admin/chaos", doctor, 403},
+		{"POST", "/API/Intake/abc", nil, 200},
+		{"GET", "/api/patients", user, 200},
+		{"POST", "/api/intake/abc", nil, 200}, // Kiosks carry their own token
+		{"GET", "/health/live", nil, 200},
+		{"POST", "/api/feedback", nil, 401},
+		{"POST", "/api/feedback", user, 403},
+		{"POST", "/api/feedback", admin, 403},
+		{"POST", "/api/feedback", doctor, 200},
+		{"POST", "/api/blockchain/backup", doctor, 403},
+		{"POST", "/api/blockchain/backup", admin, 200},
+	}
+	for _, c := range cases {
+		if code, body := authRequest(t, app, c.method, c.path, "{}", c.headers); code != c.want {
+			t.Errorf("%s %s: expected %d, got %d %v", c.method, c.path, c.want, code, body)
+		}
+	}
+
+	// Every /api/admin route is admin only, whatever its handler checks
+	callers := map[string]map[string]string{"user": user, "doctor": doctor, "admin": admin}
+	for _, route := range [][2]string{
+		{"GET", "/api/admin/db/backups/nightly.db/download"},
+		{"PUT", "/api/admin/privacy/mode"},
+		{"PUT", "/api/admin/flags/ai_services"},
+		{"POST", "/api/admin/chaos"},
+		{"POST", middleware.SelfTestPath},
+		{"GET", "/api/notifications"}, // The admin notification log under another path
+	} {
+		for who, want := range map[string]int{"user": 403, "doctor": 403, "admin": 200} {
+			if code, body := authRequest(t, app, route[0], route[1], "{}", callers[who]); code != want {
+				t.Errorf("%s %s as %s: expected %d, got %d %v", route[0], route[1], who, want, code, body)
+			}
+		}
+	}
+}
+
+func TestAuth_WebSocketTokenInQueryOrHeader(t *testing.T) {
+	app, _ := newAuthApp(t)
+	token := login(t, app, "dr.sahin@clinic.example", auditctx.RoleDoctor)
+
+	if code, _ := authRequest(t, app, "GET", "/ws/diagnostics", "", nil); code != 401 {
+		t.Errorf("Expected an unauthenticated upgrade refused, got %d", code)
+	}
+	if code, _ := authRequest(t, app, "GET", "/ws/diagnostics?token=garbage", "", nil); code != 401 {
+		t.Errorf("Expected an invalid query token refused, got %d", code)
+	}
+	if code, body := authRequest(t, app, "GET", "/ws/diagnostics?token="+token, "", nil); code != 200 {
+		t.Errorf("Expected the query token accepted, got %d %v", code, body)
+	}
+	if code, body := authRequest(t, app, "GET", "/ws/diagnostics", "", map[string]string{"Authorization": "Bearer " + token}); code != 200 {
+		t.Errorf("Expected the header token accepted, got %d %v", code, body)
+	}
+}
+
+func TestAuth_RefreshRotatesAndDetectsReuse(t *testing.T) {
+	app, _ := newAuthApp(t)
+	_, refresh := loginWithRefresh(t, app, "dr.celik@clinic.example", auditctx.RoleDoctor)
+
+	code, body := authRequest(t, app, "POST", "/api/auth/refresh", `{"refresh_token": "`+refresh+`"}`, nil)
+	if code != 200 || body["token"] == nil || body["refresh_token"] == refresh {
+		t.Fatalf("Expected a new token pair, got %d %v", code, body)
+	}
+	next := body["refresh_token"].(string)
+	bearer := map[string]string{"Authorization": "Bearer " + body["token"].(string)}
+	if code, _ := authRequest(t, app, "GET", "/api/patients", "", bearer); code != 200 {
+		t.Errorf("Expected the refreshed access token accepted, got %d", code)
+	}
+
+	// The old token was exchanged; presenting it again looks like theft
+	if code, _ := authRequest(t, app, "POST", "/api/auth/refresh", `{"refresh_token": "`+refresh+`"}`, nil); code != 401 {
+		t.Errorf("Expected a reused refresh token refused, got %d", code)
+	}
+	if code, _ := authRequest(t, app, "POST", "/api/auth/refresh", `{"refresh_token": "`+next+`"}`, nil); code != 401 {
+		t.Errorf("Expected reuse to revoke the user's other refresh tokens, got %d", code)
+	}
+	if code, _ := authRequest(t, app, "POST", "/api/auth/refresh", `{"refresh_token": "made-up"}`, nil); code != 401 {
+		t.Errorf("Expected an unknown refresh token refused, got %d", code)
+	}
+}
+
+func TestAuth_LogoutRevokesBothTokens(t *testing.T) {
+	app, _ := newAuthApp(t)
+	access, refresh := loginWithRefresh(t, app, "dr.arslan@clinic.example", auditctx.RoleDoctor)
+	other := login(t, app, "dr.ozturk@clinic.example", auditctx.RoleDoctor)
+	bearer := map[string]string{"Authorization": "Bearer " + access}
+
+	if code, _ := authRequest(t, app, "POST", "/api/auth/logout", `{"refresh_token": "`+refresh+`"}`, bearer); code != 204 {
+		t.Fatalf("Logout failed: %d", code)
+	}
+	if code, _ := authRequest(t, app, "GET", "/api/patients", "", bearer); code != 401 {
+		t.Errorf("Expected the access token revoked, got %d", code)
+	}
+	if code, _ := authRequest(t, app, "GET", "/ws/diagnostics?token="+access, "", nil); code != 401 {
+		t.Errorf("Expected the revoked token refused for WebSockets too, got %d", code)
+	}
+	if code, _ := authRequest(t, app, "POST", "/api/auth/refresh", `{"refresh_token": "`+refresh+`"}`, nil); code != 401 {
+		t.Errorf("Expected the refresh token revoked, got %d", code)
+	}
+	if code, _ := authRequest(t, app, "GET", "/api/patients", "", map[string]string{"Authorization": "Bearer " + other}); code != 200 {
+		t.Errorf("Expected other sessions untouched, got %d", code)
+	}
+}
+
+func TestTokenRevocations_ForgetExpiredTokens(t *testing.T) {
+	r := services.NewTokenRevocations()
+	r.Revoke("live", time.Now().Add(time.Hour))
+	r.Revoke("stale", time.Now().Add(-time.Minute)) // Already expired: nothing to revoke
+	if !r.Revoked("live") || r.Revoked("stale") || r.Revoked("never") {
+		t.Error("Expected only the live token revoked")
+	}
+}
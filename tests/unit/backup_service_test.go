@@ -0,0 +1,78 @@
+package unit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"healthcare-backend/pkg/database"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+)
+
+func newBackupService(t *testing.T, maxBytes int64) *services.BackupService {
+	dir := t.TempDir()
+	db, err := database.Open(filepath.Join(dir, "clinical.db"))
+	if err != nil {
+		t.Fatalf("Failed to open db: %v", err)
+	}
+	db.AutoMigrate(&models.PatientData{})
+	for i := 0; i < 20; i++ {
+		db.Create(&models.PatientData{Age: 30 + i, Gender: "Female"})
+	}
+	return services.NewBackupService(db, filepath.Join(dir, "backups"), maxBytes)
+}
+
+// TestBackup_ChecksumAndIntegrity tests that a backup matches its checksum and passes integrity_check
+func TestBackup_ChecksumAndIntegrity(t *testing.T) {
+	svc := newBackupService(t, 64<<20)
+
+	backup, err := svc.Backup()
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(svc.Dir, backup.Filename))
+	if err != nil {
+		t.Fatalf("Backup file missing: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != backup.SHA256 {
+		t.Errorf("Checksum mismatch: file %x, reported %s", sum, backup.SHA256)
+	}
+
+	list, err := svc.List()
+	if err != nil || len(list) != 1 || list[0].SHA256 != backup.SHA256 {
+		t.Fatalf("Expected backup in listing, got %+v (%v)", list, err)
+	}
+
+	result, err := svc.Verify(backup.Filename)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !result.Valid || result.IntegrityCheck != "ok" {
+		t.Errorf("Expected valid backup, got %+v", result)
+	}
+
+	// Corrupting the file must fail the checksum
+	os.WriteFile(filepath.Join(svc.Dir, backup.Filename), append(data, 0x00), 0640)
+	result, _ = svc.Verify(backup.Filename)
+	if result.Valid || result.ChecksumValid {
+		t.Errorf("Expected tampered backup to fail verification, got %+v", result)
+	}
+}
+
+// TestBackup_Limits tests size limits and name validation
+func TestBackup_Limits(t *testing.T) {
+	svc := newBackupService(t, 1024)
+	if _, err := svc.Backup(); !errors.Is(err, services.ErrBackupTooLarge) {
+		t.Errorf("Expected ErrBackupTooLarge, got %v", err)
+	}
+
+	if _, err := svc.Verify("../clinical.db"); !errors.Is(err, services.ErrBackupNotFound) {
+		t.Errorf("Expected path traversal to be rejected, got %v", err)
+	}
+}
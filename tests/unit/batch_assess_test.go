@@ -0,0 +1,133 @@
+package unit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func batchRowJSON(age int) string {
+	return fmt.Sprintf(`{"age":%d,"gender":"Female","systolic_bp":130,"diastolic_bp":85,"glucose":105,"bmi":26}`, age)
+}
+
+func postBatch(t *testing.T, app *fiber.App, body string) (int, models.BatchAssessResponse) {
+	req := httptest.NewRequest("POST", "/api/assess/batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, 10000)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	var result models.BatchAssessResponse
+	json.NewDecoder(resp.Body).Decode(&result)
+	return resp.StatusCode, result
+}
+
+func TestAssessBatch_AssessesValidRowsAndListsInvalidOnes(t *testing.T) {
+	var predictHits atomic.Int64
+	ml := newFakeFullML(t, &predictHits)
+	h, db, _ := newTestPatientHandler(t, ml.URL, handlers.NewWebSocketHandler())
+	app := fiber.New()
+	app.Post("/api/assess/batch", h.AssessBatch)
+
+	rows := []string{
+		batchRowJSON(40),
+		`{"age":41,"gender":"Female"}`, // Vitals missing
+		batchRowJSON(42),
+		`{"age":"forty","gender":"Female","systolic_bp":130,"diastolic_bp":85,"glucose":105,"bmi":26}`,
+		`"not a patient"`,
+		batchRowJSON(45),
+	}
+	code, result := postBatch(t, app, "["+strings.Join(rows, ",")+"]")
+	if code != 200 || result.Total != 6 || result.Assessed != 3 || len(result.Results) != 3 {
+		t.Fatalf("Expected 3 of 6 rows assessed, got %d %+v", code, result)
+	}
+	for i, want := range []int{0, 2, 5} {
+		r := result.Results[i]
+		if r.Row != want || r.PatientID == 0 || r.AssessmentID == 0 || r.AuditHash == "" || r.Risks.HeartRisk != 40 {
+			t.Errorf("Unexpected result for row %d: %+v", want, r)
+		}
+	}
+	if len(result.Errors) != 3 || result.Errors[0].Row != 1 || result.Errors[1].Row != 3 || result.Errors[2].Row != 4 {
+		t.Fatalf("Expected rows 1, 3 and 4 rejected in order, got %+v", result.Errors)
+	}
+	if result.Errors[0].Fields == nil || result.Errors[1].Fields == nil {
+		t.Errorf("Expected per-field errors for invalid rows, got %+v", result.Errors)
+	}
+	if predictHits.Load() != 3 {
+		t.Errorf("Expected the model called once per valid row, got %d", predictHits.Load())
+	}
+
+	var screened, assessments, contexts int64
+	db.Model(&models.PatientData{}).Where("source = ?", services.PatientSourceScreening).Count(&screened)
+	db.Model(&models.Assessment{}).Count(&assessments)
+	db.Model(&models.DiagnosisContext{}).Count(&contexts)
+	if screened != 3 || assessments != 3 {
+		t.Errorf("Expected 3 screening patients and assessments, got %d and %d", screened, assessments)
+	}
+	if contexts != 0 || result.DiagnosisQueued {
+		t.Errorf("Expected no LLM diagnosis by default, got %d requests", contexts)
+	}
+}
+
+func TestAssessBatch_BoundsConcurrentPredictions(t *testing.T) {
+	var inFlight, peak atomic.Int64
+	ml := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(20 * time.Millisecond)
+		json.NewEncoder(w).Encode(models.PredictResponse{HeartRisk: 10})
+	}))
+	t.Cleanup(ml.Close)
+
+	h, db, _ := newTestPatientHandler(t, ml.URL, handlers.NewWebSocketHandler())
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1) // One in-memory database for every worker
+	h.BatchWorkers = 3
+	app := fiber.New()
+	app.Post("/api/assess/batch", h.AssessBatch)
+
+	rows := make([]string, 12)
+	for i := range rows {
+		rows[i] = batchRowJSON(30 + i) // Distinct rows, so none is served from cache
+	}
+	if code, result := postBatch(t, app, "["+strings.Join(rows, ",")+"]"); code != 200 || result.Assessed != 12 {
+		t.Fatalf("Expected all 12 rows assessed, got %d %+v", code, result.Errors)
+	}
+	if p := peak.Load(); p < 2 || p > 3 {
+		t.Errorf("Expected up to 3 predictions at once, saw %d", p)
+	}
+}
+
+func TestAssessBatch_RejectsBadBatches(t *testing.T) {
+	h, _, _ := newTestPatientHandler(t, "http://ml.invalid", handlers.NewWebSocketHandler())
+	app := fiber.New()
+	app.Post("/api/assess/batch", h.AssessBatch)
+
+	tooMany := make([]string, handlers.MaxBatchAssessRows+1)
+	for i := range tooMany {
+		tooMany[i] = batchRowJSON(50)
+	}
+	cases := map[string]int{
+		`{"age":50}`:                           400,
+		`[]`:                                   400,
+		"[" + strings.Join(tooMany, ",") + "]": 413,
+	}
+	for body, want := range cases {
+		if code, _ := postBatch(t, app, body); code != want {
+			t.Errorf("Expected %d for a %d-byte body, got %d", want, len(body), code)
+		}
+	}
+}
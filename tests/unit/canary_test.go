@@ -0,0 +1,123 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+)
+
+// newFakeML returns an ML server that answers /predict with the given heart risk
+func newFakeML(t *testing.T, heartRisk float64, hits *atomic.Int64) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		json.NewEncoder(w).Encode(models.PredictResponse{
+			HeartRisk:       heartRisk,
+			ModelPrecisions: map[string]float64{"Heart_Model": 0.9},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// TestCanary_DeterministicSplit tests that routing is stable per patient and near the target share
+func TestCanary_DeterministicSplit(t *testing.T) {
+	service := services.NewPredictionService("http://primary")
+	service.SetCanary("http://canary", 10)
+
+	canary := 0
+	for id := uint(1); id <= 10000; id++ {
+		first := service.RoutesToCanary(id)
+		if first != service.RoutesToCanary(id) {
+			t.Fatalf("Routing for patient %d is not deterministic", id)
+		}
+		if first {
+			canary++
+		}
+	}
+
+	if canary < 800 || canary > 1200 {
+		t.Errorf("Expected ~10%% of patients on canary, got %d/10000", canary)
+	}
+}
+
+// TestCanary_PercentBounds tests runtime percentage validation
+func TestCanary_PercentBounds(t *testing.T) {
+	service := services.NewPredictionService("http://primary")
+	service.SetCanary("http://canary", 0)
+
+	if service.RoutesToCanary(42) {
+		t.Error("Expected no canary routing at 0%")
+	}
+	if err := service.SetCanaryPercent(101); err == nil {
+		t.Error("Expected error for percent > 100")
+	}
+	service.SetCanaryPercent(100)
+	if !service.RoutesToCanary(42) {
+		t.Error("Expected canary routing at 100%")
+	}
+}
+
+// TestCanary_ServesCanaryPrediction tests that canary-routed patients get the canary result
+func TestCanary_ServesCanaryPrediction(t *testing.T) {
+	var primaryHits, canaryHits atomic.Int64
+	primary := newFakeML(t, 10, &primaryHits)
+	canary := newFakeML(t, 20, &canaryHits)
+
+	service := services.NewPredictionService(primary.URL)
+	service.SetCanary(canary.URL, 100)
+
+	risks, _ := service.PredictRisks(models.PatientData{ID: 7, Age: 50})
+
+	if risks.Backend != services.BackendCanary || risks.HeartRisk != 20 {
+		t.Errorf("Expected canary prediction, got backend=%s heart=%.0f", risks.Backend, risks.HeartRisk)
+	}
+	if primaryHits.Load() != 0 {
+		t.Errorf("Expected primary untouched, got %d hits", primaryHits.Load())
+	}
+}
+
+// TestCanary_FailureFallsBackToPrimary tests that canary errors use the primary, not the rule-based fallback
+func TestCanary_FailureFallsBackToPrimary(t *testing.T) {
+	var primaryHits atomic.Int64
+	primary := newFakeML(t, 10, &primaryHits)
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+
+	service := services.NewPredictionService(primary.URL)
+	service.SetCanary(broken.URL, 100)
+
+	for i := 0; i < 10; i++ {
+		risks, _ := service.PredictRisks(models.PatientData{ID: 7, Age: 50 + i})
+		if risks.Backend != services.BackendPrimary {
+			t.Fatalf("Expected primary fallback, got backend=%q", risks.Backend)
+		}
+		if services.IsRuleBased(risks) {
+			t.Fatal("Canary failures must not trigger the rule-based fallback")
+		}
+	}
+
+	// Canary breaker trips independently; the primary breaker stays closed
+	if service.Canary.CB.State().String() != "open" {
+		t.Errorf("Expected canary breaker open, got %s", service.Canary.CB.State())
+	}
+	if service.CB.State().String() != "closed" {
+		t.Errorf("Expected primary breaker closed, got %s", service.CB.State())
+	}
+
+	var canaryMetrics models.MLBackendMetrics
+	for _, m := range service.BackendMetrics() {
+		if m.Name == services.BackendCanary {
+			canaryMetrics = m
+		}
+	}
+	if canaryMetrics.Failures == 0 || canaryMetrics.SuccessRate != 0 {
+		t.Errorf("Expected canary failures recorded, got %+v", canaryMetrics)
+	}
+}
@@ -0,0 +1,108 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/chaos"
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sony/gobreaker"
+)
+
+// TestChaos_Validation tests that faults are rejected when disabled or malformed
+func TestChaos_Validation(t *testing.T) {
+	inj := chaos.NewInjector()
+	if _, err := inj.Set(chaos.Fault{Target: chaos.TargetML, ErrorRate: 1}, time.Minute); err == nil {
+		t.Error("Expected disabled injector to refuse faults")
+	}
+	if err := inj.Inject(chaos.TargetML, "/predict"); err != nil {
+		t.Errorf("Disabled injector must be a no-op, got %v", err)
+	}
+
+	inj.Enable()
+	bad := []chaos.Fault{
+		{Target: "postgres", ErrorRate: 1},
+		{Target: chaos.TargetRedis, Endpoint: "/predict", ErrorRate: 1},
+		{Target: chaos.TargetML, ErrorRate: 1.5},
+	}
+	for _, f := range bad {
+		if _, err := inj.Set(f, time.Minute); err == nil {
+			t.Errorf("Expected %+v to be rejected", f)
+		}
+	}
+	if _, err := inj.Set(chaos.Fault{Target: chaos.TargetML, ErrorRate: 1}, 2*time.Hour); err == nil {
+		t.Error("Expected TTL over 1h to be rejected")
+	}
+
+	inj.Set(chaos.Fault{Target: chaos.TargetML, Endpoint: "/predict", ErrorRate: 1}, time.Minute)
+	if err := inj.Inject(chaos.TargetML, "/predict"); !errors.Is(err, chaos.ErrInjected) {
+		t.Errorf("Expected injected error on /predict, got %v", err)
+	}
+	if err := inj.Inject(chaos.TargetML, "/urgency/predict"); err != nil {
+		t.Errorf("Fault on /predict must not affect other endpoints, got %v", err)
+	}
+	if len(inj.Active()) != 1 {
+		t.Errorf("Expected 1 active fault, got %v", inj.Active())
+	}
+}
+
+// TestChaos_MLFailureFallsBackEndToEnd injects ML failures and drives /api/assess
+func TestChaos_MLFailureFallsBackEndToEnd(t *testing.T) {
+	var predictHits atomic.Int64
+	ml := newFakeFullML(t, &predictHits)
+	h, db, pred := newTestPatientHandler(t, ml.URL, handlers.NewWebSocketHandler())
+
+	app := fiber.New()
+	app.Post("/api/assess", h.AssessPatient)
+
+	chaos.Default.Enable()
+	t.Cleanup(chaos.Default.Disable)
+	if _, err := chaos.Default.Set(chaos.Fault{Target: chaos.TargetML, Endpoint: "/predict", ErrorRate: 1}, time.Minute); err != nil {
+		t.Fatalf("Failed to inject fault: %v", err)
+	}
+
+	body, _ := json.Marshal(models.PatientData{Age: 70, Gender: "Male", SystolicBP: 170, DiastolicBP: 95, Glucose: 130, BMI: 31, Cholesterol: 240, HeartRate: 88})
+	for i := 0; i < 5; i++ {
+		req := httptest.NewRequest("POST", "/api/assess", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		raw, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != 200 {
+			t.Fatalf("Expected 200 with fallback, got %d: %s", resp.StatusCode, raw)
+		}
+
+		var result models.FullAssessmentResponse
+		json.Unmarshal(raw, &result)
+		if !result.Risks.Degraded {
+			t.Errorf("Request %d: expected degraded flag on rule-based fallback", i)
+		}
+		if result.Risks.ClinicalConfidence != 50 {
+			t.Errorf("Request %d: expected rule-based confidence 50, got %v", i, result.Risks.ClinicalConfidence)
+		}
+	}
+
+	if predictHits.Load() != 0 {
+		t.Errorf("Injected faults should stop calls before the network, ML saw %d", predictHits.Load())
+	}
+	if state := pred.Primary.CB.State(); state != gobreaker.StateOpen {
+		t.Errorf("Expected circuit breaker to open under injected failures, got %s", state)
+	}
+
+	var ruleBased int64
+	db.Model(&models.Assessment{}).Where("rule_based = ?", true).Count(&ruleBased)
+	if ruleBased != 5 {
+		t.Errorf("Expected 5 rule-based assessments persisted, got %d", ruleBased)
+	}
+}
@@ -0,0 +1,202 @@
+package unit
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// istanbulClinic is open 09:00-17:00 Monday to Friday, Istanbul time (UTC+3)
+var istanbulClinic = models.Clinic{
+	ID:       "north",
+	Timezone: "Europe/Istanbul",
+	Hours: []models.WorkingWindow{
+		{Weekday: time.Monday, Start: "09:00", End: "17:00"},
+		{Weekday: time.Tuesday, Start: "09:00", End: "17:00"},
+		{Weekday: time.Wednesday, Start: "09:00", End: "17:00"},
+		{Weekday: time.Thursday, Start: "09:00", End: "17:00"},
+		{Weekday: time.Friday, Start: "09:00", End: "17:00"},
+	},
+	Holidays: []string{"2026-10-29"}, // Republic Day, a Thursday
+}
+
+func TestClinicHours_TimezoneAndHolidays(t *testing.T) {
+	hours, err := services.NewClinicHours(istanbulClinic)
+	if err != nil {
+		t.Fatalf("NewClinicHours failed: %v", err)
+	}
+
+	// Wednesday 2026-10-28 07:00 UTC is 10:00 in Istanbul
+	if !hours.Open(time.Date(2026, 10, 28, 7, 0, 0, 0, time.UTC)) {
+		t.Error("Expected open at 10:00 local")
+	}
+	// 14:30 UTC is 17:30 local: closed although still "working hours" in UTC
+	if hours.Open(time.Date(2026, 10, 28, 14, 30, 0, 0, time.UTC)) {
+		t.Error("Expected closed at 17:30 local")
+	}
+
+	// Wednesday evening: the holiday Thursday is skipped, so Friday 09:00 local
+	next, ok := hours.NextOpen(time.Date(2026, 10, 28, 20, 0, 0, 0, time.UTC))
+	if want := time.Date(2026, 10, 30, 6, 0, 0, 0, time.UTC); !ok || !next.Equal(want) {
+		t.Errorf("Expected next open %v, got %v", want, next)
+	}
+
+	// Friday evening: next is Monday morning
+	next, _ = hours.NextOpen(time.Date(2026, 10, 30, 18, 0, 0, 0, time.UTC))
+	if want := time.Date(2026, 11, 2, 6, 0, 0, 0, time.UTC); !next.Equal(want) {
+		t.Errorf("Expected Monday 09:00 local, got %v", next.UTC())
+	}
+}
+
+func TestClinicHours_DaylightSaving(t *testing.T) {
+	clinic := models.Clinic{ID: "berlin", Timezone: "Europe/Berlin", Hours: []models.WorkingWindow{
+		{Weekday: time.Monday, Start: "08:00", End: "16:00"},
+	}}
+	hours, err := services.NewClinicHours(clinic)
+	if err != nil {
+		t.Fatalf("NewClinicHours failed: %v", err)
+	}
+	// Clocks go back on Sunday 2026-10-25: Monday 08:00 is UTC+1, not UTC+2
+	next, _ := hours.NextOpen(time.Date(2026, 10, 24, 12, 0, 0, 0, time.UTC))
+	if want := time.Date(2026, 10, 26, 7, 0, 0, 0, time.UTC); !next.Equal(want) {
+		t.Errorf("Expected %v after the DST change, got %v", want, next.UTC())
+	}
+}
+
+func TestClinicHours_RejectsInvalidConfig(t *testing.T) {
+	bad := []models.Clinic{
+		{ID: "a", Timezone: "Mars/Olympus"},
+		{ID: "b", Hours: []models.WorkingWindow{{Weekday: time.Monday, Start: "17:00", End: "09:00"}}},
+		{ID: "c", Hours: []models.WorkingWindow{{Weekday: 9, Start: "09:00", End: "17:00"}}},
+		{ID: "d", Holidays: []string{"29/10/2026"}},
+	}
+	for _, c := range bad {
+		if _, err := services.NewClinicHours(c); !errors.Is(err, services.ErrInvalidClinicHours) {
+			t.Errorf("Clinic %s: expected ErrInvalidClinicHours, got %v", c.ID, err)
+		}
+	}
+}
+
+// recordingSender captures broadcasts instead of writing to WebSockets
+type recordingSender struct {
+	mu   sync.Mutex
+	msgs []map[string]any
+}
+
+func (r *recordingSender) BroadcastAll(msg any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.msgs = append(r.msgs, msg.(map[string]any))
+}
+
+func (r *recordingSender) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.msgs)
+}
+
+func setupNotifications(t *testing.T) (*services.NotificationService, *recordingSender, *time.Time, models.PatientData) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	db.AutoMigrate(&models.Provider{}, &models.PatientData{}, &models.Clinic{}, &models.NotificationLog{})
+
+	providers := services.NewProviderService(db)
+	assigned := models.Provider{Name: "Dr. Day"}
+	onCall := models.Provider{Name: "Dr. Night"}
+	providers.Create(&assigned)
+	providers.Create(&onCall)
+	providers.SetStatus(onCall.ID, true, true)
+
+	patient := models.PatientData{Age: 60, Gender: "Male", Clinic: "north", AssignedProviderID: &assigned.ID}
+	db.Create(&patient)
+
+	sender := &recordingSender{}
+	svc := services.NewNotificationService(db, providers, sender, "")
+	if err := svc.Clinics.Save(&istanbulClinic); err != nil {
+		t.Fatalf("Failed to save clinic: %v", err)
+	}
+	now := time.Date(2026, 10, 28, 0, 0, 0, 0, time.UTC) // 03:00 Wednesday in Istanbul
+	svc.Now = func() time.Time { return now }
+	return svc, sender, &now, patient
+}
+
+func TestNotifications_CriticalAfterHoursGoesToOnCall(t *testing.T) {
+	svc, sender, _, patient := setupNotifications(t)
+
+	entry, err := svc.Notify(services.Notification{Kind: services.NotificationEmergency, Critical: true, Patient: patient,
+		Payload: map[string]any{"type": services.NotificationEmergency}})
+	if err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if entry.Decision != services.NotificationEscalated || entry.SentAt == nil {
+		t.Fatalf("Expected immediate escalation, got %+v", entry)
+	}
+	// Only the on-call provider, not the assigned day-shift clinician
+	if len(entry.Recipients) != 1 || entry.Recipients[0] != 2 {
+		t.Errorf("Expected on-call provider 2 only, got %v", entry.Recipients)
+	}
+	if sender.count() != 1 {
+		t.Errorf("Expected one broadcast, got %d", sender.count())
+	}
+}
+
+func TestNotifications_NonCriticalDeferredThenDispatched(t *testing.T) {
+	svc, sender, now, patient := setupNotifications(t)
+
+	entry, err := svc.Notify(services.Notification{Kind: "follow_up", Patient: patient, Payload: map[string]any{"type": "follow_up"}})
+	if err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if entry.Decision != services.NotificationDeferred || entry.SentAt != nil || sender.count() != 0 {
+		t.Fatalf("Expected deferral, got %+v", entry)
+	}
+	if want := time.Date(2026, 10, 28, 6, 0, 0, 0, time.UTC); !entry.DeliverAt.Equal(want) {
+		t.Errorf("Expected delivery at 09:00 local, got %v", entry.DeliverAt.UTC())
+	}
+	if !strings.Contains(entry.Reason, "after hours") {
+		t.Errorf("Expected the reason recorded, got %q", entry.Reason)
+	}
+
+	if n, _ := svc.DispatchDue(); n != 0 {
+		t.Fatalf("Nothing is due before the window opens, sent %d", n)
+	}
+	*now = time.Date(2026, 10, 28, 6, 1, 0, 0, time.UTC)
+	if n, err := svc.DispatchDue(); err != nil || n != 1 {
+		t.Fatalf("Expected 1 dispatched, got %d (%v)", n, err)
+	}
+	if n, _ := svc.DispatchDue(); n != 0 {
+		t.Errorf("A dispatched notification must not be sent twice, sent %d", n)
+	}
+
+	log, _ := svc.Log(patient.ID, "", 10)
+	if len(log) != 1 || log[0].SentAt == nil || len(log[0].Recipients) != 2 {
+		t.Errorf("Expected the log entry stamped with both escalation targets, got %+v", log)
+	}
+	if sender.msgs[0]["type"] != "follow_up" {
+		t.Errorf("Expected the original payload delivered, got %v", sender.msgs[0])
+	}
+}
+
+func TestNotifications_SentImmediatelyDuringHours(t *testing.T) {
+	svc, _, now, patient := setupNotifications(t)
+	*now = time.Date(2026, 10, 28, 8, 0, 0, 0, time.UTC) // 11:00 local
+
+	entry, err := svc.Notify(services.Notification{Kind: services.NotificationEmergency, Critical: true, Patient: patient})
+	if err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if entry.Decision != services.NotificationSent || len(entry.Recipients) != 2 || entry.Recipients[0] != *patient.AssignedProviderID {
+		t.Errorf("Expected assigned provider first during hours, got %+v", entry)
+	}
+}
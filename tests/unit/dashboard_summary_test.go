@@ -0,0 +1,75 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestDashboardSummary_RiskDistributionPrefersMLScores(t *testing.T) {
+	_, db, pred := newTestPatientHandler(t, "http://ml.invalid", handlers.NewWebSocketHandler())
+	patient := func(systolic int, assessments ...models.Assessment) models.PatientData {
+		p := models.PatientData{Age: 50, Gender: "Female", SystolicBP: systolic}
+		db.Create(&p)
+		for _, a := range assessments {
+			a.PatientID = p.ID
+			db.Create(&a)
+		}
+		return p
+	}
+	patient(170, models.Assessment{HeartRisk: 90}, models.Assessment{HeartRisk: 20, StrokeRisk: 10}) // Latest ML score wins over BP
+	patient(120, models.Assessment{DiabetesRisk: 30, KidneyRisk: 75})
+	patient(150, models.Assessment{HeartRisk: 95, RuleBased: true}) // Fallback scores don't count
+	patient(165)
+	deleted := patient(120, models.Assessment{HeartRisk: 99})
+	db.Delete(&deleted)
+
+	h := handlers.NewDashboardHandler(db, pred, services.NewAuditService(db))
+	h.Thresholds = models.RiskThresholds{Medium: 50, High: 70}
+	app := fiber.New()
+	app.Get("/api/dashboard/summary", h.GetSummary)
+	summary := getJSON(t, app, "/api/dashboard/summary")
+
+	dist, _ := summary["risk_distribution"].(map[string]any)
+	if dist["Low"] != float64(1) || dist["Medium"] != float64(1) || dist["High"] != float64(2) {
+		t.Errorf("Expected Low 1, Medium 1, High 2, got %v", dist)
+	}
+	methods, _ := summary["risk_distribution_methods"].(map[string]any)
+	ml, _ := methods["ml_score"].(map[string]any)
+	bp, _ := methods["systolic_bp"].(map[string]any)
+	if ml["Low"] != float64(1) || ml["High"] != float64(1) || bp["Medium"] != float64(1) || bp["High"] != float64(1) {
+		t.Errorf("Expected each patient counted under the method that leveled them, got %v", methods)
+	}
+	if thresholds, _ := methods["thresholds"].(map[string]any); thresholds["medium"] != float64(50) {
+		t.Errorf("Expected the thresholds reported, got %v", methods["thresholds"])
+	}
+}
+
+func TestDashboardSummary_CountsPatientsOfTheLastDay(t *testing.T) {
+	_, db, pred := newTestPatientHandler(t, "http://ml.invalid", handlers.NewWebSocketHandler())
+	db.Create(&models.PatientData{Age: 50, Gender: "Female"})
+	db.Create(&models.PatientData{Age: 60, Gender: "Male", CreatedAt: time.Now().Add(-25 * time.Hour)})
+
+	h := handlers.NewDashboardHandler(db, pred, services.NewAuditService(db))
+	app := fiber.New()
+	app.Get("/api/dashboard/summary", h.GetSummary)
+	if recent := getJSON(t, app, "/api/dashboard/summary")["recent_assessments"]; recent != float64(1) {
+		t.Errorf("Expected only the patient of the last day, got %v", recent)
+	}
+}
+
+func TestValidateRiskThresholds(t *testing.T) {
+	if err := services.ValidateRiskThresholds(services.DefaultRiskThresholds); err != nil {
+		t.Errorf("Expected the defaults to be valid, got %v", err)
+	}
+	for _, bad := range []models.RiskThresholds{{Medium: 0, High: 70}, {Medium: 70, High: 70}, {Medium: 40, High: 120}} {
+		if services.ValidateRiskThresholds(bad) == nil {
+			t.Errorf("Expected %+v refused", bad)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package unit
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestTrendService_BucketsAssessments(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	db.AutoMigrate(&models.Assessment{})
+	day := func(d, h int) time.Time { return time.Date(2026, 3, d, h, 0, 0, 0, time.UTC) }
+	for _, a := range []models.Assessment{
+		{CreatedAt: day(6, 12), HeartRisk: 90}, // Before the window
+		{CreatedAt: day(7, 6), HeartRisk: 20, DiabetesRisk: 10},
+		{CreatedAt: day(7, 18), HeartRisk: 40, DiabetesRisk: 30, Emergency: true, RuleBased: true},
+		{CreatedAt: day(9, 12).In(time.FixedZone("CET", 3600)), HeartRisk: 60, DiabetesRisk: 50},
+	} {
+		if err := db.Create(&a).Error; err != nil {
+			t.Fatalf("Failed to seed assessment: %v", err)
+		}
+	}
+
+	svc := services.NewTrendService(db)
+	svc.Now = func() time.Time { return day(10, 15) }
+	trends, err := svc.Trends(3*24*time.Hour, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("Trends failed: %v", err)
+	}
+	if trends.Window != "3d" || trends.Bucket != "1d" || !trends.Since.Equal(day(7, 0)) || !trends.Until.Equal(day(11, 0)) {
+		t.Fatalf("Expected day buckets from March 7 to 11, got %+v", trends)
+	}
+	if len(trends.Points) != 4 {
+		t.Fatalf("Expected 4 buckets, empty ones included, got %d", len(trends.Points))
+	}
+
+	first := trends.Points[0]
+	if first.Assessments != 2 || first.Emergencies != 1 || *first.AvgHeartRisk != 30 || *first.AvgDiabetesRisk != 20 || *first.FallbackRate != 0.5 {
+		t.Errorf("Unexpected first bucket: %+v", first)
+	}
+	if empty := trends.Points[1]; empty.Assessments != 0 || empty.AvgHeartRisk != nil || empty.FallbackRate != nil {
+		t.Errorf("Expected an empty second bucket without averages, got %+v", empty)
+	}
+	if third := trends.Points[2]; third.Assessments != 1 || *third.AvgHeartRisk != 60 || *third.FallbackRate != 0 {
+		t.Errorf("Unexpected third bucket: %+v", third)
+	}
+}
+
+func TestDashboardTrends_ValidatesSpans(t *testing.T) {
+	_, db, pred := newTestPatientHandler(t, "http://ml.invalid", handlers.NewWebSocketHandler())
+	db.AutoMigrate(&models.Assessment{})
+	h := handlers.NewDashboardHandler(db, pred, services.NewAuditService(db))
+	app := fiber.New()
+	app.Get("/api/dashboard/trends", h.GetTrends)
+
+	for query, want := range map[string]int{
+		"":                       200,
+		"?window=90d&bucket=12h": 200,
+		"?window=91d":            400,
+		"?window=2d&bucket=30m":  400,
+		"?window=1d&bucket=2d":   400,
+		"?window=week":           400,
+		"?window=7d&bucket=-1h":  400,
+	} {
+		resp, err := app.Test(httptest.NewRequest("GET", "/api/dashboard/trends"+query, nil))
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", query, err)
+		}
+		if resp.StatusCode != want {
+			t.Errorf("%q: expected %d, got %d", query, want, resp.StatusCode)
+		}
+	}
+}
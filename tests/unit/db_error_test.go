@@ -0,0 +1,154 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"healthcare-backend/pkg/apierrors"
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/middleware"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/repositories"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// TestFromDB_Classifies tests the mapping from raw driver errors to typed API errors
+func TestFromDB_Classifies(t *testing.T) {
+	var conflict *apierrors.Conflict
+	if !errors.As(apierrors.FromDB(errors.New("UNIQUE constraint failed: providers.user_id")), &conflict) {
+		t.Error("Expected unique violation to map to Conflict")
+	}
+	for _, msg := range []string{"attempt to write a readonly database", "sql: database is closed", "database or disk is full"} {
+		var unavailable *apierrors.StorageUnavailable
+		if !errors.As(apierrors.FromDB(errors.New(msg)), &unavailable) {
+			t.Errorf("Expected %q to map to StorageUnavailable", msg)
+		}
+	}
+	other := errors.New("FOREIGN KEY constraint failed")
+	if apierrors.FromDB(other) != other {
+		t.Error("Unrecognised errors must pass through unchanged")
+	}
+	if apierrors.FromDB(nil) != nil {
+		t.Error("nil must stay nil")
+	}
+}
+
+// readOnlyDB migrates a file database, then reopens it read-only so every write fails
+func readOnlyDB(t *testing.T, tables ...any) *gorm.DB {
+	path := filepath.Join(t.TempDir(), "ro.db")
+	rw, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	rw.AutoMigrate(tables...)
+	sqlDB, _ := rw.DB()
+	sqlDB.Close()
+
+	ro, err := gorm.Open(sqlite.Open("file:"+path+"?mode=ro"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open read-only: %v", err)
+	}
+	return ro
+}
+
+func decodeError(t *testing.T, body []byte) middleware.ErrorResponse {
+	var resp middleware.ErrorResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("Expected an error body, got %s", body)
+	}
+	return resp
+}
+
+func TestAssessPatient_ClosedDatabaseReturns503(t *testing.T) {
+	h, db, _ := newTestPatientHandler(t, "http://127.0.0.1:1", handlers.NewWebSocketHandler())
+	sqlDB, _ := db.DB()
+	sqlDB.Close()
+
+	app := fiber.New()
+	app.Use(middleware.ErrorHandler)
+	app.Post("/api/assess", h.AssessPatient)
+
+	body, _ := json.Marshal(models.PatientData{Age: 50, Gender: "Male", SystolicBP: 120, DiastolicBP: 80, Glucose: 100, BMI: 25, Cholesterol: 190, HeartRate: 72})
+	req := httptest.NewRequest("POST", "/api/assess", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.StatusCode != 503 {
+		t.Fatalf("Expected 503, got %d", resp.StatusCode)
+	}
+	var buf bytes.Buffer
+	buf.ReadFrom(resp.Body)
+	if e := decodeError(t, buf.Bytes()); e.Success || strings.Contains(buf.String(), `"patient_id"`) {
+		t.Errorf("A failed write must not look like a success: %s", buf.String())
+	}
+}
+
+func TestSubmitFeedback_ReadOnlyDatabaseReturns503(t *testing.T) {
+	db := readOnlyDB(t, &models.Feedback{}, &models.OverrideLog{}, &models.OverrideReason{}, &models.AuditLog{})
+	h := handlers.NewFeedbackHandler(db, repositories.NewFeedbackRepository(db), services.NewOverrideService(db), services.NewAuditService(db))
+
+	app := fiber.New()
+	app.Use(middleware.ErrorHandler)
+	app.Post("/api/feedback", h.SubmitFeedback)
+
+	req := httptest.NewRequest("POST", "/api/feedback", strings.NewReader(`{"assessment_id": 1, "approved": true}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.StatusCode != 503 {
+		t.Errorf("Expected 503 for a read-only database, got %d", resp.StatusCode)
+	}
+}
+
+func TestCreateProvider_DuplicateUserReturns409(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	db.AutoMigrate(&models.Provider{}, &models.AuditLog{})
+	h := handlers.NewProviderHandler(services.NewProviderService(db), services.NewAuditService(db), handlers.NewWebSocketHandler())
+
+	app := fiber.New()
+	app.Use(middleware.ErrorHandler)
+	app.Post("/api/admin/providers", h.CreateProvider)
+
+	post := func() int {
+		req := httptest.NewRequest("POST", "/api/admin/providers", strings.NewReader(`{"name": "Dr. Kaya", "user_id": 7}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		return resp.StatusCode
+	}
+	if code := post(); code != 201 && code != 200 {
+		t.Fatalf("First create failed with %d", code)
+	}
+	if code := post(); code != 409 {
+		t.Errorf("Expected 409 for a duplicate user, got %d", code)
+	}
+}
+
+func TestRepositoryCreate_TypedErrors(t *testing.T) {
+	db := readOnlyDB(t, &models.PatientData{})
+	err := repositories.NewPatientRepository(db).Create(&models.PatientData{Age: 40})
+
+	var unavailable *apierrors.StorageUnavailable
+	if !errors.As(err, &unavailable) {
+		t.Errorf("Expected StorageUnavailable from the repository, got %v", err)
+	}
+}
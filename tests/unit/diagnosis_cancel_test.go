@@ -0,0 +1,166 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+	"healthcare-backend/pkg/workers"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// newBlockingDiagnoseML echoes each request's past_context back as the
+// diagnosis. Requests whose context is "slow" wait for release to close.
+func newBlockingDiagnoseML(t *testing.T, started chan<- string, release <-chan struct{}) *httptest.Server {
+	ml := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req models.DiagnosisRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		started <- req.PastContext
+		if req.PastContext == "slow" {
+			<-release
+		}
+		json.NewEncoder(w).Encode(models.DiagnosisResponse{Diagnosis: req.PastContext, Status: "ready"})
+	}))
+	t.Cleanup(ml.Close)
+	return ml
+}
+
+// diagnosisRecorder collects onComplete callbacks
+type diagnosisRecorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *diagnosisRecorder) onComplete(_ uint, diagnosis, _ string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, diagnosis)
+}
+
+func (r *diagnosisRecorder) snapshot() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.calls...)
+}
+
+func waitForDiscards(t *testing.T, reg *services.DiagnosisRegistry, want int64) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for reg.Stats().Discarded < want {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d discarded diagnoses, got %+v", want, reg.Stats())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDeletedPatientDiagnosisIsDiscarded(t *testing.T) {
+	started := make(chan string, 4)
+	release := make(chan struct{})
+	ml := newBlockingDiagnoseML(t, started, release)
+
+	pred := services.NewPredictionService(ml.URL)
+	rec := &diagnosisRecorder{}
+	pred.StartAsyncDiagnosis(7, models.DiagnosisRequest{PastContext: "slow"}, rec.onComplete)
+	<-started
+
+	pred.CancelDiagnosis(7)
+	close(release)
+	waitForDiscards(t, pred.Diagnoses, 1)
+
+	if calls := rec.snapshot(); len(calls) != 0 {
+		t.Errorf("Stale diagnosis was broadcast: %v", calls)
+	}
+	if diagnosis, status := pred.Cache.Get(7); status != "" || diagnosis != "" {
+		t.Errorf("Deleted patient's diagnosis came back as %q/%q", diagnosis, status)
+	}
+	if stats := pred.Diagnoses.Stats(); stats.Cancelled != 1 || stats.Issued != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestReassessmentSupersedesInFlightDiagnosis(t *testing.T) {
+	started := make(chan string, 4)
+	release := make(chan struct{})
+	ml := newBlockingDiagnoseML(t, started, release)
+
+	pred := services.NewPredictionService(ml.URL)
+	rec := &diagnosisRecorder{}
+	pred.StartAsyncDiagnosis(8, models.DiagnosisRequest{PastContext: "slow"}, rec.onComplete)
+	<-started
+	pred.StartAsyncDiagnosis(8, models.DiagnosisRequest{PastContext: "fresh"}, rec.onComplete)
+	<-started
+
+	deadline := time.Now().Add(3 * time.Second)
+	for len(rec.snapshot()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(release)
+	waitForDiscards(t, pred.Diagnoses, 1)
+
+	if calls := rec.snapshot(); len(calls) != 1 || calls[0] != "fresh" {
+		t.Errorf("Expected only the fresh diagnosis, got %v", calls)
+	}
+	if diagnosis, status := pred.Cache.Get(8); diagnosis != "fresh" || status != "ready" {
+		t.Errorf("Stale result overwrote the fresh one: %q/%q", diagnosis, status)
+	}
+}
+
+func TestWorkerDropsStaleGeneration(t *testing.T) {
+	started := make(chan string, 4)
+	ml := newBlockingDiagnoseML(t, started, nil)
+
+	reg := services.NewDiagnosisRegistry()
+	worker := workers.NewLLMWorker(ml.URL)
+	worker.Diagnoses = reg
+
+	stale := reg.Next(9)
+	current := reg.Next(9)
+
+	worker.Process(models.DiagnosisRequest{Patient: models.PatientData{ID: 9}, PastContext: "old", Generation: stale})
+	if got := reg.Stats().Discarded; got != 1 {
+		t.Fatalf("Expected the stale generation to be discarded, got %d", got)
+	}
+	worker.Process(models.DiagnosisRequest{Patient: models.PatientData{ID: 9}, PastContext: "new", Generation: current})
+	if got := reg.Stats().Discarded; got != 1 {
+		t.Errorf("Current generation was discarded too: %d", got)
+	}
+}
+
+func TestDiagnosisRegistryMetrics(t *testing.T) {
+	reg := services.NewDiagnosisRegistry()
+	metrics := prometheus.NewRegistry()
+	if err := reg.Register(metrics); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	reg.Cancel(1) // Never diagnosed: not a cancellation
+	gen := reg.Next(1)
+	reg.Cancel(1)
+	reg.Complete(1, gen, func() { t.Error("Cancelled diagnosis was written") })
+
+	families, err := metrics.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+	got := map[string]float64{}
+	for _, f := range families {
+		got[f.GetName()] = f.GetMetric()[0].GetCounter().GetValue()
+	}
+	want := map[string]float64{
+		"diagnosis_issued_total":          1,
+		"diagnosis_cancelled_total":       1,
+		"diagnosis_stale_discarded_total": 1,
+	}
+	for name, v := range want {
+		if got[name] != v {
+			t.Errorf("%s = %v, want %v", name, got[name], v)
+		}
+	}
+}
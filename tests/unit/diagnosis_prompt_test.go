@@ -0,0 +1,182 @@
+package unit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/middleware"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/repositories"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	promptAdminKey     = "admin-key"
+	promptClinicianKey = "clinician-key"
+)
+
+// newPromptApp serves assess and the prompt endpoint behind the actor middleware
+func newPromptApp(t *testing.T) (*fiber.App, *gorm.DB, string) {
+	var hits atomic.Int64
+	ml := newFakeFullML(t, &hits)
+	h, db, _ := newTestPatientHandler(t, ml.URL, handlers.NewWebSocketHandler())
+	prompts := handlers.NewDiagnosisPromptHandler(db, repositories.NewAssessmentRepository(db), h.Audit)
+
+	app := fiber.New()
+	app.Use(middleware.ResolveActor(middleware.ActorConfig{APIKeys: []middleware.APIKey{
+		{ID: "ops", Role: auditctx.RoleAdmin, Key: promptAdminKey},
+		{ID: "dr-who", Role: "clinician", Key: promptClinicianKey},
+	}}))
+	app.Post("/api/assess", h.AssessPatient)
+	app.Get("/api/admin/diagnosis/:id/prompt", middleware.RequireRole(auditctx.RoleAdmin), prompts.Get)
+	return app, db, ml.URL
+}
+
+func getPrompt(t *testing.T, app *fiber.App, path, key string) (int, []byte) {
+	t.Helper()
+	req := httptest.NewRequest("GET", path, nil)
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, body
+}
+
+func promptViews(t *testing.T, db *gorm.DB) []models.AuditLog {
+	t.Helper()
+	var logs []models.AuditLog
+	db.Where("event_type = ?", "DIAGNOSIS_PROMPT_VIEWED").Find(&logs)
+	return logs
+}
+
+func TestDiagnosisPromptAssembly(t *testing.T) {
+	app, db, mlURL := newPromptApp(t)
+
+	body := `{"name":"Ayşe Yılmaz","age":58,"gender":"Female","systolic_bp":150,"diastolic_bp":95,"glucose":130,"bmi":31,
+		"cholesterol":240,"heart_rate":88,"smoking":"Former","alcohol":"No","history_heart_disease":"Yes",
+		"history_stroke":"No","history_diabetes":"No","history_high_chol":"Yes"}`
+	req := httptest.NewRequest("POST", "/api/assess", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, 5000)
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("Assessment failed: %v %v", err, resp.StatusCode)
+	}
+	var assessed models.FullAssessmentResponse
+	json.NewDecoder(resp.Body).Decode(&assessed)
+
+	status, raw := getPrompt(t, app, "/api/admin/diagnosis/"+fmt.Sprint(assessed.AssessmentID)+"/prompt", promptAdminKey)
+	if status != 200 {
+		t.Fatalf("Expected 200, got %d: %s", status, raw)
+	}
+	var got models.DiagnosisPrompt
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("Bad response: %v", err)
+	}
+
+	if got.AssessmentID != assessed.AssessmentID || got.PatientID != assessed.ID {
+		t.Errorf("Wrong record: %+v", got)
+	}
+	if got.PromptVersion != services.DiagnosisPromptVersion || got.LLMModel != services.DiagnosisLLMModel || got.SystemPrompt == "" {
+		t.Errorf("Template metadata missing: %q %q %q", got.PromptVersion, got.LLMModel, got.SystemPrompt)
+	}
+	if got.MLEndpoint != mlURL+"/diagnose" || got.MLBackend != services.BackendPrimary {
+		t.Errorf("Endpoint %q backend %q", got.MLEndpoint, got.MLBackend)
+	}
+	if got.RiskScores.HeartRisk != 40 {
+		t.Errorf("Risk scores not included: %+v", got.RiskScores)
+	}
+	if got.Generation == 0 || !strings.Contains(string(got.Request), `"generation":`) {
+		t.Errorf("Request should carry the diagnosis generation: %s", got.Request)
+	}
+	if got.PrivacyMode != "redact" {
+		t.Errorf("Privacy mode %q", got.PrivacyMode)
+	}
+	for _, want := range []string{"BMI: 31.0", "BP 150/95", "Heart Disease: Yes", "Heart Attack 10y Risk: 40%"} {
+		if !strings.Contains(got.Prompt, want) {
+			t.Errorf("Prompt is missing %q:\n%s", want, got.Prompt)
+		}
+	}
+	if strings.Contains(got.Prompt, "Ayşe") || strings.Contains(string(got.Request), "Ayşe") {
+		t.Error("Patient name must not appear in what was sent to the LLM")
+	}
+
+	views := promptViews(t, db)
+	if len(views) != 1 || views[0].ActorID != "apikey:ops" || views[0].ActorRole != auditctx.RoleAdmin {
+		t.Fatalf("Expected one audited view by apikey:ops, got %+v", views)
+	}
+}
+
+func TestDiagnosisPromptIsAdminOnly(t *testing.T) {
+	app, db, _ := newPromptApp(t)
+	assessment := models.Assessment{PatientID: 1}
+	db.Create(&assessment)
+	db.Create(&models.DiagnosisContext{PatientID: 1, AssessmentID: assessment.ID, Request: `{"risk_scores":{}}`, PromptVersion: "diagnose-v2"})
+	path := "/api/admin/diagnosis/" + fmt.Sprint(assessment.ID) + "/prompt"
+
+	if status, _ := getPrompt(t, app, path, ""); status != 401 {
+		t.Errorf("Anonymous caller: expected 401, got %d", status)
+	}
+	if status, _ := getPrompt(t, app, path, promptClinicianKey); status != 403 {
+		t.Errorf("Clinician: expected 403, got %d", status)
+	}
+	if views := promptViews(t, db); len(views) != 0 {
+		t.Errorf("Refused requests must not be logged as views: %+v", views)
+	}
+	if status, _ := getPrompt(t, app, path, promptAdminKey); status != 200 {
+		t.Errorf("Admin: expected 200, got %d", status)
+	}
+	if views := promptViews(t, db); len(views) != 1 {
+		t.Errorf("Expected one audited view, got %d", len(views))
+	}
+}
+
+func TestDiagnosisPromptRefusesUnrecordedDiagnosis(t *testing.T) {
+	app, db, _ := newPromptApp(t)
+	legacy := models.Assessment{PatientID: 2}
+	db.Create(&legacy)
+	// A context row from before prompt persistence: no assessment link, no request
+	db.Create(&models.DiagnosisContext{PatientID: 2, PastContext: "old context", PrivacyMode: "redact"})
+
+	status, raw := getPrompt(t, app, "/api/admin/diagnosis/"+fmt.Sprint(legacy.ID)+"/prompt", promptAdminKey)
+	if status != 404 || !strings.Contains(string(raw), "predates prompt persistence") {
+		t.Errorf("Expected a clear 404 for an unrecorded prompt, got %d: %s", status, raw)
+	}
+	if status, _ := getPrompt(t, app, "/api/admin/diagnosis/9999/prompt", promptAdminKey); status != 404 {
+		t.Errorf("Unknown assessment: expected 404, got %d", status)
+	}
+	if views := promptViews(t, db); len(views) != 0 {
+		t.Errorf("Nothing was served, so nothing should be audited: %+v", views)
+	}
+}
+
+func TestRenderDiagnosisPromptFormatsLikePython(t *testing.T) {
+	prompt := services.RenderDiagnosisPrompt(models.DiagnosisRequest{
+		Patient:     models.PatientData{Age: 40, Gender: "Male", BMI: 25, SystolicBP: 120, DiastolicBP: 80},
+		RiskScores:  models.PredictResponse{HeartRisk: 77, DiabetesRisk: 12.5},
+		PastContext: "Similar case approved.",
+	})
+	for _, want := range []string{
+		"BMI: 25.0", // Pydantic float
+		"Heart Attack 10y Risk: 77%",
+		"Diabetes Probability: 12.5%",
+		"PAST CLINICAL KNOWLEDGE (RAG):\nSimilar case approved.\n",
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("Prompt is missing %q:\n%s", want, prompt)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package unit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/handlers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func streamDiagnosis(t *testing.T, app *fiber.App, id string) (string, string) {
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/diagnosis/"+id+"/stream", nil), 5000)
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return resp.Header.Get("Content-Type"), string(body)
+}
+
+func TestDiagnosisStream_ReplaysThenPushesUntilReady(t *testing.T) {
+	ws := handlers.NewWebSocketHandler()
+	h, _, pred := newTestPatientHandler(t, "http://127.0.0.1:1", ws)
+	app := fiber.New()
+	app.Get("/api/diagnosis/:id/stream", h.StreamDiagnosis)
+
+	pred.Cache.Set(41, "", "pending")
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		ws.BroadcastDiagnosis(41, "", "pending") // Unchanged, not re-sent
+		ws.BroadcastDiagnosis(41, "Stage 1 hypertension", "ready")
+	}()
+
+	contentType, body := streamDiagnosis(t, app, "41")
+	if contentType != "text/event-stream" {
+		t.Errorf("Expected an event stream, got %q", contentType)
+	}
+	want := `data: {"diagnosis":"","id":41,"status":"pending"}` + "\n\n" +
+		`data: {"diagnosis":"Stage 1 hypertension","id":41,"status":"ready"}` + "\n\n"
+	if body != want {
+		t.Errorf("Expected the cached state then the ready update, got %q", body)
+	}
+}
+
+func TestDiagnosisStream_FinishedDiagnosisClosesAtOnce(t *testing.T) {
+	ws := handlers.NewWebSocketHandler()
+	h, _, pred := newTestPatientHandler(t, "http://127.0.0.1:1", ws)
+	app := fiber.New()
+	app.Get("/api/diagnosis/:id/stream", h.StreamDiagnosis)
+
+	pred.Cache.Set(42, "Diagnosis unavailable - LLM service error", "error")
+	if _, body := streamDiagnosis(t, app, "42"); strings.Count(body, "data: ") != 1 || !strings.Contains(body, `"status":"error"`) {
+		t.Errorf("Expected one error event, got %q", body)
+	}
+}
+
+func TestDiagnosisStream_HeartbeatsAndMaxDuration(t *testing.T) {
+	ws := handlers.NewWebSocketHandler()
+	h, _, _ := newTestPatientHandler(t, "http://127.0.0.1:1", ws)
+	h.StreamHeartbeat = 20 * time.Millisecond
+	h.StreamMax = 150 * time.Millisecond
+	app := fiber.New()
+	app.Get("/api/diagnosis/:id/stream", h.StreamDiagnosis)
+
+	_, body := streamDiagnosis(t, app, "43")
+	if !strings.Contains(body, ": heartbeat\n\n") || !strings.HasSuffix(body, "event: timeout\ndata: {}\n\n") {
+		t.Errorf("Expected heartbeats then a timeout event, got %q", body)
+	}
+}
+
+func TestDiagnosisLongPoll(t *testing.T) {
+	ws := handlers.NewWebSocketHandler()
+	h, _, pred := newTestPatientHandler(t, "http://127.0.0.1:1", ws)
+	h.MaxDiagnosisWait = 150 * time.Millisecond
+	app := fiber.New()
+	app.Get("/api/diagnosis/:id", h.GetDiagnosis)
+
+	poll := func(url string) (int, map[string]any, time.Duration) {
+		start := time.Now()
+		resp, err := app.Test(httptest.NewRequest("GET", url, nil), 5000)
+		if err != nil {
+			t.Fatalf("Poll failed: %v", err)
+		}
+		var body map[string]any
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body, time.Since(start)
+	}
+
+	pred.Cache.Set(51, "", "pending")
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		ws.BroadcastDiagnosis(51, "Stage 1 hypertension", "ready")
+	}()
+	if _, body, _ := poll("/api/diagnosis/51?wait=30s"); body["status"] != "ready" || body["diagnosis"] != "Stage 1 hypertension" {
+		t.Errorf("Expected the long-poll to return the finished diagnosis, got %v", body)
+	}
+
+	// The wait is capped, after which the pending state comes back
+	pred.Cache.Set(52, "", "pending")
+	if _, body, took := poll("/api/diagnosis/52?wait=30"); body["status"] != "pending" || took > time.Second {
+		t.Errorf("Expected pending after the capped wait, got %v in %v", body, took)
+	}
+
+	// No wait answers at once, as before
+	if _, body, took := poll("/api/diagnosis/52"); body["status"] != "pending" || took > 100*time.Millisecond {
+		t.Errorf("Expected an instant answer, got %v in %v", body, took)
+	}
+	if code, _, _ := poll("/api/diagnosis/52?wait=soon"); code != 400 {
+		t.Errorf("Expected 400 for a malformed wait, got %d", code)
+	}
+}
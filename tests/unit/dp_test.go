@@ -0,0 +1,173 @@
+package unit
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/privacy/dp"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestDP_SeededNoiseIsDeterministic(t *testing.T) {
+	a := dp.NewMechanism(rand.NewSource(42))
+	b := dp.NewMechanism(rand.NewSource(42))
+	for i := 0; i < 10; i++ {
+		x, _ := a.Count(100, 0.5)
+		y, _ := b.Count(100, 0.5)
+		if x != y {
+			t.Fatalf("Same seed gave %v and %v", x, y)
+		}
+	}
+}
+
+func TestDP_LaplaceScale(t *testing.T) {
+	m := dp.NewMechanism(rand.NewSource(7))
+	const n, scale = 200000, 2.0
+	sum, sumAbs := 0.0, 0.0
+	for i := 0; i < n; i++ {
+		x := m.Laplace(scale)
+		sum += x
+		sumAbs += math.Abs(x)
+	}
+	// Laplace(0, b) has mean 0 and mean absolute deviation b
+	if mean := sum / n; math.Abs(mean) > 0.05 {
+		t.Errorf("Expected mean ~0, got %.4f", mean)
+	}
+	if mad := sumAbs / n; math.Abs(mad-scale) > 0.05 {
+		t.Errorf("Expected mean |x| ~%.1f, got %.4f", scale, mad)
+	}
+}
+
+func TestDP_CountAndMeanBounds(t *testing.T) {
+	m := dp.NewMechanism(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		if c, _ := m.Count(0, 0.1); c < 0 || c != math.Round(c) {
+			t.Fatalf("Count must be a non-negative integer, got %v", c)
+		}
+		if v, _ := m.Mean([]float64{500, -20, 90}, 60, 250, 0.1); v < 60 || v > 250 {
+			t.Fatalf("Mean must stay within the clamp bounds, got %v", v)
+		}
+	}
+
+	// Large epsilon: the answer approaches the truth over clamped values
+	got, _ := m.Mean([]float64{100, 120, 400}, 60, 250, 1e6)
+	if want := (100.0 + 120 + 250) / 3; math.Abs(got-want) > 0.01 {
+		t.Errorf("Expected ~%.2f, got %.4f", want, got)
+	}
+
+	if _, err := m.Count(10, 0); !errors.Is(err, dp.ErrInvalidEpsilon) {
+		t.Errorf("Expected ErrInvalidEpsilon, got %v", err)
+	}
+}
+
+func setupCohortDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	db.AutoMigrate(&models.PatientData{}, &models.PrivacyBudget{})
+	for i := 0; i < 20; i++ {
+		gender := "Male"
+		if i%2 == 0 {
+			gender = "Female"
+		}
+		db.Create(&models.PatientData{Age: 30 + i, Gender: gender, SystolicBP: 120 + i, Glucose: 100, BMI: 25})
+	}
+	return db
+}
+
+func TestPrivacyBudget_ExhaustsPerPeriod(t *testing.T) {
+	budget := services.NewPrivacyBudgetService(setupCohortDB(t), 1.0)
+	oct := time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 10; i++ {
+		if _, err := budget.Spend("apikey:partner", 0.1, oct); err != nil {
+			t.Fatalf("Spend %d failed: %v", i, err)
+		}
+	}
+	remaining, err := budget.Spend("apikey:partner", 0.1, oct)
+	if !errors.Is(err, services.ErrPrivacyBudgetExhausted) {
+		t.Fatalf("Expected budget exhausted, got %v", err)
+	}
+	if math.Abs(remaining) > 1e-9 {
+		t.Errorf("Expected nothing remaining, got %v", remaining)
+	}
+
+	if _, err := budget.Spend("apikey:other", 0.5, oct); err != nil {
+		t.Errorf("Budgets are per caller, got %v", err)
+	}
+	if _, err := budget.Spend("apikey:partner", 0.5, oct.AddDate(0, 1, 0)); err != nil {
+		t.Errorf("Budget should reset next month, got %v", err)
+	}
+}
+
+func cohortApp(db *gorm.DB, actor auditctx.Identity) *fiber.App {
+	h := handlers.NewAnalyticsHandler(services.NewCohortService(db), services.NewPrivacyBudgetService(db, 1.0),
+		dp.NewMechanism(rand.NewSource(3)), 0.5)
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		auditctx.Set(c, actor)
+		return c.Next()
+	})
+	app.Get("/api/analytics/cohort", h.GetCohort)
+	return app
+}
+
+func getCohort(t *testing.T, app *fiber.App, query string) (int, services.CohortStats) {
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/analytics/cohort"+query, nil))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	var stats services.CohortStats
+	json.NewDecoder(resp.Body).Decode(&stats)
+	return resp.StatusCode, stats
+}
+
+func TestCohort_ExactAndPrivate(t *testing.T) {
+	db := setupCohortDB(t)
+	app := cohortApp(db, auditctx.Identity{ID: "apikey:partner", Role: auditctx.RoleService})
+
+	code, exact := getCohort(t, app, "?gender=Female")
+	if code != 200 || exact.Count != 10 || exact.Privacy != nil {
+		t.Fatalf("Unexpected exact answer: %d %+v", code, exact)
+	}
+
+	code, private := getCohort(t, app, "?gender=Female&dp=true&epsilon=0.5")
+	if code != 200 || private.Privacy == nil {
+		t.Fatalf("Unexpected private answer: %d %+v", code, private)
+	}
+	if private.Privacy.Epsilon != 0.5 || private.Privacy.Mechanism != "laplace" || private.Privacy.BudgetRemaining != 0.5 {
+		t.Errorf("Response must declare the epsilon used, got %+v", private.Privacy)
+	}
+	if private.Privacy.EpsilonPerStat != 0.1 {
+		t.Errorf("Expected epsilon split over count and 4 means, got %v", private.Privacy.EpsilonPerStat)
+	}
+
+	getCohort(t, app, "?dp=true&epsilon=0.5")
+	if code, _ := getCohort(t, app, "?dp=true&epsilon=0.5"); code != 429 {
+		t.Errorf("Expected exhausted budget to be refused, got %d", code)
+	}
+	if code, _ := getCohort(t, app, "?dp=true&epsilon=5"); code != 400 {
+		t.Errorf("Expected epsilon above the budget to be rejected, got %d", code)
+	}
+}
+
+func TestCohort_PrivateNeedsIdentity(t *testing.T) {
+	app := cohortApp(setupCohortDB(t), auditctx.Anonymous)
+	if code, _ := getCohort(t, app, "?dp=true"); code != 401 {
+		t.Errorf("Expected anonymous DP query refused, got %d", code)
+	}
+}
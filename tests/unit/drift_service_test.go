@@ -0,0 +1,124 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupDriftDB seeds two months of daily assessments for two models.
+// Heart_Model drops from 0.90 to 0.75 in the final week; Diabetes_Model stays at 0.85.
+func setupDriftDB(t *testing.T, now time.Time) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	db.AutoMigrate(&models.Assessment{}, &models.AssessmentPrecision{})
+
+	for day := 60; day >= 1; day-- {
+		createdAt := now.AddDate(0, 0, -day)
+		heart := 0.90
+		if day <= 7 {
+			heart = 0.75
+		}
+		ruleBased := day%10 == 0 // Occasional ML outage
+
+		assessment := models.Assessment{CreatedAt: createdAt, RuleBased: ruleBased}
+		for name, conf := range map[string]float64{"Heart_Model": heart, "Diabetes_Model": 0.85} {
+			if ruleBased {
+				conf = 0
+			}
+			assessment.Precisions = append(assessment.Precisions, models.AssessmentPrecision{
+				CreatedAt:  createdAt,
+				ModelName:  name,
+				Confidence: conf,
+				RuleBased:  ruleBased,
+			})
+		}
+		if err := db.Create(&assessment).Error; err != nil {
+			t.Fatalf("Failed to seed assessment: %v", err)
+		}
+	}
+	return db
+}
+
+// TestDriftService_CheckDrift tests that only the degraded model raises an alert
+func TestDriftService_CheckDrift(t *testing.T) {
+	now := time.Now()
+	drift := services.NewDriftService(setupDriftDB(t, now), 0.05)
+
+	alerts, err := drift.CheckDrift(now)
+	if err != nil {
+		t.Fatalf("CheckDrift failed: %v", err)
+	}
+
+	if len(alerts) != 1 {
+		t.Fatalf("Expected 1 alert, got %d: %+v", len(alerts), alerts)
+	}
+	if alerts[0].ModelName != "Heart_Model" {
+		t.Errorf("Expected alert for Heart_Model, got %s", alerts[0].ModelName)
+	}
+	if alerts[0].Drop < 0.14 || alerts[0].Drop > 0.16 {
+		t.Errorf("Expected drop of ~0.15, got %.3f", alerts[0].Drop)
+	}
+}
+
+// TestDriftService_CheckDrift_LargeDelta tests that a generous delta suppresses the alert
+func TestDriftService_CheckDrift_LargeDelta(t *testing.T) {
+	now := time.Now()
+	drift := services.NewDriftService(setupDriftDB(t, now), 0.20)
+
+	alerts, err := drift.CheckDrift(now)
+	if err != nil {
+		t.Fatalf("CheckDrift failed: %v", err)
+	}
+	if len(alerts) != 0 {
+		t.Errorf("Expected no alerts, got %+v", alerts)
+	}
+}
+
+// TestDriftService_WeeklySeries tests the weekly aggregation per model
+func TestDriftService_WeeklySeries(t *testing.T) {
+	now := time.Now()
+	drift := services.NewDriftService(setupDriftDB(t, now), 0.05)
+
+	series, err := drift.WeeklySeries(now.AddDate(0, 0, -70))
+	if err != nil {
+		t.Fatalf("WeeklySeries failed: %v", err)
+	}
+
+	var total int64
+	sawFallback := false
+	perModel := map[string]int{}
+	for _, p := range series {
+		total += p.Samples
+		perModel[p.ModelName]++
+		if p.WeekStart.Weekday() != time.Monday {
+			t.Errorf("Expected week to start on Monday, got %s", p.WeekStart.Weekday())
+		}
+		if p.FallbackShare > 0 {
+			sawFallback = true
+		}
+		if p.ModelName == "Diabetes_Model" && p.MeanConfidence != 0.85 && p.FallbackShare < 1 {
+			t.Errorf("Expected Diabetes_Model mean 0.85 excluding fallbacks, got %.3f", p.MeanConfidence)
+		}
+	}
+
+	if total != 120 {
+		t.Errorf("Expected 120 samples across all weeks, got %d", total)
+	}
+	if perModel["Heart_Model"] < 8 || perModel["Diabetes_Model"] < 8 {
+		t.Errorf("Expected at least 8 weekly buckets per model, got %+v", perModel)
+	}
+	if !sawFallback {
+		t.Error("Expected some weeks to report rule-based fallback share")
+	}
+}
@@ -0,0 +1,164 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func floatOrNil(p *float64) any {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+// TestParseEKGFeatures tests tolerant key matching against messy ML payloads
+func TestParseEKGFeatures(t *testing.T) {
+	tests := []struct {
+		name       string
+		raw        map[string]any
+		want       [4]any // mean HR, SDNN, RMSSD, QRS
+		wantExtras []string
+	}{
+		{
+			name:       "ml service keys",
+			raw:        map[string]any{"heart_rate": 72.0, "rr_std": 41.5, "rr_mean": 833.0, "mean": 0.01},
+			want:       [4]any{72.0, 41.5, nil, nil},
+			wantExtras: []string{"rr_mean", "mean"},
+		},
+		{
+			name: "string numbers and unit suffixes",
+			raw:  map[string]any{"Mean HR": "68.5", "SDNN": " 50 ms", "rmssd": "32", "QRS_Duration_ms": "96 ms"},
+			want: [4]any{68.5, 50.0, 32.0, 96.0},
+		},
+		{
+			name: "json numbers and camel case",
+			raw:  map[string]any{"meanHR": json.Number("80"), "qrsDuration": 104},
+			want: [4]any{80.0, nil, nil, 104.0},
+		},
+		{
+			name:       "unparseable values are kept as extras",
+			raw:        map[string]any{"heart_rate": "n/a", "rmssd": nil, "rr_std": []any{1, 2}},
+			want:       [4]any{nil, nil, nil, nil},
+			wantExtras: []string{"heart_rate", "rmssd", "rr_std"},
+		},
+		{
+			name: "zero means not computed",
+			raw:  map[string]any{"heart_rate": 0.0, "rr_std": 0},
+			want: [4]any{nil, nil, nil, nil},
+		},
+		{
+			name: "nil map",
+			raw:  nil,
+			want: [4]any{nil, nil, nil, nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, extras := services.ParseEKGFeatures(tt.raw)
+			got := [4]any{floatOrNil(f.MeanHR), floatOrNil(f.SDNN), floatOrNil(f.RMSSD), floatOrNil(f.QRSDuration)}
+			if got != tt.want {
+				t.Errorf("Expected features %v, got %v", tt.want, got)
+			}
+			if len(extras) != len(tt.wantExtras) {
+				t.Errorf("Expected extras %v, got %v", tt.wantExtras, extras)
+			}
+			for _, k := range tt.wantExtras {
+				if _, ok := extras[k]; !ok {
+					t.Errorf("Expected %q preserved in extras", k)
+				}
+			}
+		})
+	}
+}
+
+// TestEKG_StoresAnalysisAndReturnsTrends tests persistence and the trends endpoint
+func TestEKG_StoresAnalysisAndReturnsTrends(t *testing.T) {
+	heartRates := []any{70.0, "75", 80}
+	call := 0
+	ml := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hr := heartRates[call%len(heartRates)]
+		call++
+		json.NewEncoder(w).Encode(models.EKGResponse{
+			Status:      "success",
+			Predictions: []models.EKGPrediction{{Condition: "Normal", Probability: 0.9}, {Condition: "AFib", Probability: 0.1}},
+			Features:    map[string]any{"heart_rate": hr, "rr_std": "45.2", "rr_mean": 800.0},
+		})
+	}))
+	t.Cleanup(ml.Close)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	db.AutoMigrate(&models.EKGAnalysis{})
+
+	h := handlers.NewEKGHandler(db, services.NewPredictionService(ml.URL))
+	app := fiber.New()
+	app.Post("/api/ekg/analyze", h.Analyze)
+	app.Get("/api/patients/:id/ekg/trends", h.GetTrends)
+
+	post := func(patientID uint) models.EKGResponse {
+		body, _ := json.Marshal(models.EKGRequest{Signal: []float64{0.1, 0.2, 0.1}, SamplingRate: 250, PatientID: patientID})
+		req := httptest.NewRequest("POST", "/api/ekg/analyze", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, 5000)
+		if err != nil || resp.StatusCode != 200 {
+			t.Fatalf("Analyze failed: %v %v", err, resp)
+		}
+		var out models.EKGResponse
+		json.NewDecoder(resp.Body).Decode(&out)
+		return out
+	}
+
+	for i := 0; i < 3; i++ {
+		if out := post(7); out.AnalysisID == 0 || out.ParsedFeatures == nil || out.ParsedFeatures.MeanHR == nil {
+			t.Fatalf("Expected stored analysis with parsed features, got %+v", out)
+		}
+	}
+	if out := post(0); out.AnalysisID != 0 {
+		t.Errorf("Analyses without a patient must not be stored, got ID %d", out.AnalysisID)
+	}
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/patients/7/ekg/trends", nil))
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("Trends failed: %v %v", err, resp)
+	}
+	var trends struct {
+		Count    int                  `json:"count"`
+		Analyses []models.EKGAnalysis `json:"analyses"`
+	}
+	json.NewDecoder(resp.Body).Decode(&trends)
+
+	if trends.Count != 3 {
+		t.Fatalf("Expected 3 analyses, got %d", trends.Count)
+	}
+	for i, want := range []float64{70, 75, 80} {
+		a := trends.Analyses[i]
+		if a.MeanHR == nil || *a.MeanHR != want {
+			t.Errorf("Analysis %d: expected mean HR %v in chronological order, got %v", i, want, floatOrNil(a.MeanHR))
+		}
+		if a.SDNN == nil || *a.SDNN != 45.2 {
+			t.Errorf("Analysis %d: expected SDNN 45.2, got %v", i, floatOrNil(a.SDNN))
+		}
+		if a.TopCondition != "Normal" {
+			t.Errorf("Analysis %d: expected top condition Normal, got %q", i, a.TopCondition)
+		}
+		if a.Extras["rr_mean"] != 800.0 {
+			t.Errorf("Analysis %d: expected rr_mean preserved in extras, got %v", i, a.Extras)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package unit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/middleware"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestEmergencyRules_DefaultsExplainTheTrigger(t *testing.T) {
+	p := models.PatientData{Age: 60, SystolicBP: 130, Glucose: 400, HeartRate: 150}
+	risks := &models.PredictResponse{HeartRisk: 40, StrokeRisk: 85}
+	got := services.EvaluateEmergencyRules(services.DefaultEmergencyRules, p, risks, &models.UrgencyResponse{UrgencyLevel: 2})
+	want := []string{
+		"Glucose above 350 mg/dL (severe hyperglycaemia)",
+		"Heart rate above 140 bpm",
+		"Stroke risk above 80%",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	// Unknown values never fire: pending risks, degraded urgency, unmeasured vitals
+	low := []models.EmergencyRule{
+		{Field: "stroke_risk_score", Operator: "<", Threshold: 1, Enabled: true, Description: "risk"},
+		{Field: "urgency_level", Operator: "<", Threshold: 1, Enabled: true, Description: "urgency"},
+		{Field: "heart_rate", Operator: "<", Threshold: 40, Enabled: true, Description: "bradycardia"},
+		{Field: "cholesterol", Operator: "<", Threshold: 100, Enabled: true, Description: "cholesterol"},
+	}
+	unmeasured := models.PatientData{Age: 60, SystolicBP: 130, ImputedFields: "cholesterol"}
+	if fired := services.EvaluateEmergencyRules(low, unmeasured, nil, services.DegradedUrgency()); len(fired) != 0 {
+		t.Errorf("Expected nothing fired on unknown values, got %v", fired)
+	}
+
+	disabled := []models.EmergencyRule{{Field: "age", Operator: ">=", Threshold: 0, Description: "anyone"}}
+	if fired := services.EvaluateEmergencyRules(disabled, p, risks, nil); len(fired) != 0 {
+		t.Errorf("Expected a disabled rule ignored, got %v", fired)
+	}
+}
+
+func TestEmergencyRules_AdminCRUDDrivesAssessment(t *testing.T) {
+	var hits atomic.Int64
+	h, db, _ := newTestPatientHandler(t, newFakeFullML(t, &hits).URL, handlers.NewWebSocketHandler())
+	db.AutoMigrate(&models.EmergencyRule{})
+	rules := services.NewEmergencyRuleService(db)
+	if err := rules.Seed(); err != nil {
+		t.Fatalf("Seed failed: %v", err)
+	}
+	h.EmergencyRules = rules
+	rh := handlers.NewEmergencyRuleHandler(rules, h.Audit)
+
+	app := fiber.New()
+	app.Use(middleware.ResolveActor(middleware.ActorConfig{APIKeys: []middleware.APIKey{
+		{ID: "ops", Role: auditctx.RoleAdmin, Key: promptAdminKey},
+		{ID: "dr-who", Role: "clinician", Key: promptClinicianKey},
+	}}))
+	admin := middleware.RequireRole(auditctx.RoleAdmin)
+	app.Post("/api/assess", h.AssessPatient)
+	app.Get("/api/admin/emergency-rules", admin, rh.List)
+	app.Post("/api/admin/emergency-rules", admin, rh.Create)
+	app.Put("/api/admin/emergency-rules/:id", admin, rh.Update)
+	app.Delete("/api/admin/emergency-rules/:id", admin, rh.Delete)
+
+	send := func(method, path, key, body string) (int, map[string]any) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", key)
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		var out map[string]any
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+	assess := func(glucose int) models.FullAssessmentResponse {
+		t.Helper()
+		patient := requiredIntake()
+		patient["glucose"] = glucose
+		data, _ := json.Marshal(patient)
+		code, body := send("POST", "/api/assess", promptAdminKey, string(data))
+		if code != 200 {
+			t.Fatalf("Assess failed: %d %v", code, body)
+		}
+		raw, _ := json.Marshal(body)
+		var full models.FullAssessmentResponse
+		json.Unmarshal(raw, &full)
+		return full
+	}
+
+	if code, _ := send("GET", "/api/admin/emergency-rules", promptClinicianKey, ""); code != 403 {
+		t.Errorf("Expected clinicians refused, got %d", code)
+	}
+	code, listed := send("GET", "/api/admin/emergency-rules", promptAdminKey, "")
+	if seeded, _ := listed["rules"].([]any); code != 200 || len(seeded) != len(services.DefaultEmergencyRules) {
+		t.Fatalf("Expected the seeded defaults, got %d %v", code, listed)
+	}
+
+	if full := assess(300); full.Emergency || len(full.EmergencyReasons) != 0 {
+		t.Fatalf("Expected glucose 300 routine under the defaults, got %+v", full.EmergencyReasons)
+	}
+
+	if code, body := send("POST", "/api/admin/emergency-rules", promptAdminKey, `{"field":"pulse","operator":">","threshold":1,"description":"x"}`); code != 400 {
+		t.Errorf("Expected an unknown field rejected, got %d %v", code, body)
+	}
+	code, created := send("POST", "/api/admin/emergency-rules", promptAdminKey,
+		`{"field":"glucose","operator":">=","threshold":250,"enabled":true,"description":"Glucose 250 or higher"}`)
+	if code != 201 {
+		t.Fatalf("Create failed: %d %v", code, created)
+	}
+	if full := assess(300); !full.Emergency || !reflect.DeepEqual(full.EmergencyReasons, []string{"Glucose 250 or higher"}) {
+		t.Errorf("Expected the new rule to flag the emergency, got %v %v", full.Emergency, full.EmergencyReasons)
+	}
+
+	path := fmt.Sprintf("/api/admin/emergency-rules/%v", created["id"])
+	if code, body := send("PUT", path, promptAdminKey, `{"field":"glucose","operator":">=","threshold":250,"enabled":false,"description":"Glucose 250 or higher"}`); code != 200 || body["enabled"] != false {
+		t.Fatalf("Update failed: %d %v", code, body)
+	}
+	if full := assess(300); full.Emergency {
+		t.Errorf("Expected the disabled rule ignored, got %v", full.EmergencyReasons)
+	}
+
+	if code, _ := send("DELETE", path, promptAdminKey, ""); code != 204 {
+		t.Errorf("Delete failed: %d", code)
+	}
+	if code, _ := send("DELETE", path, promptAdminKey, ""); code != 404 {
+		t.Errorf("Expected 404 deleting twice, got %d", code)
+	}
+	var changes int64
+	db.Model(&models.AuditLog{}).Where("event_type = ?", "EMERGENCY_RULE_CHANGED").Count(&changes)
+	if changes != 3 {
+		t.Errorf("Expected create, update and delete audited, got %d", changes)
+	}
+
+	// Deleted defaults aren't reseeded
+	send("DELETE", "/api/admin/emergency-rules/1", promptAdminKey, "")
+	rules.Seed()
+	if all, _ := rules.List(); len(all) != len(services.DefaultEmergencyRules)-1 {
+		t.Errorf("Expected the deleted default to stay deleted, got %d rules", len(all))
+	}
+}
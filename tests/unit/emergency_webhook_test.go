@@ -0,0 +1,164 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// newWebhookApp serves assess and the notification log with webhooks going
+// to the given URLs
+func newWebhookApp(t *testing.T, urls ...string) (*fiber.App, *services.WebhookNotifier, *gorm.DB) {
+	var hits atomic.Int64
+	h, db, _ := newTestPatientHandler(t, newFakeFullML(t, &hits).URL, handlers.NewWebSocketHandler())
+	db.AutoMigrate(&models.NotificationLog{})
+	webhooks := services.NewWebhookNotifier(db, urls)
+	webhooks.Backoff = 10 * time.Millisecond
+	h.Webhooks = webhooks
+	h.PublicURL = "https://health.example"
+	clinics := handlers.NewClinicHandler(services.NewClinicService(db), services.NewNotificationService(db, nil, h.WS, ""), h.Audit)
+
+	app := fiber.New()
+	app.Post("/api/assess", h.AssessPatient)
+	app.Get("/api/notifications", clinics.GetNotifications)
+	t.Cleanup(webhooks.Wait)
+	return app, webhooks, db
+}
+
+func TestEmergencyWebhook_RetriesUntilDelivered(t *testing.T) {
+	var calls atomic.Int64
+	var mu sync.Mutex
+	var received models.EmergencyWebhook
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		mu.Lock()
+		json.NewDecoder(r.Body).Decode(&received)
+		mu.Unlock()
+	}))
+	t.Cleanup(receiver.Close)
+
+	app, webhooks, _ := newWebhookApp(t, receiver.URL)
+	code, body := assessWith(t, app, map[string]any{"systolic_bp": 200})
+	if code != 200 || body["emergency"] != true {
+		t.Fatalf("Expected an emergency, got %d %v", code, body)
+	}
+	webhooks.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	id := uint(body["assessment_id"].(float64))
+	if calls.Load() != 3 || received.Event != "emergency" || received.AssessmentID != id {
+		t.Fatalf("Expected the alert delivered on the third attempt, got %d call(s) and %+v", calls.Load(), received)
+	}
+	if !slices.Contains(received.TriggeredRules, "Systolic BP above 180 mmHg (hypertensive crisis)") {
+		t.Errorf("Expected the fired rule in the payload, got %v", received.TriggeredRules)
+	}
+	if _, ok := received.RiskScores["heart_risk_score"]; !ok || received.Timestamp.IsZero() {
+		t.Errorf("Expected risk scores and a timestamp, got %+v", received)
+	}
+	if !strings.HasPrefix(received.Link, "https://health.example/api/assessments/") {
+		t.Errorf("Unexpected link %q", received.Link)
+	}
+}
+
+func TestEmergencyWebhook_LogsDeliveriesAndFailures(t *testing.T) {
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(ok.Close)
+	var failing atomic.Int64
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failing.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(down.Close)
+
+	app, webhooks, _ := newWebhookApp(t, ok.URL, down.URL)
+	webhooks.Retries = 1
+	if code, body := assessWith(t, app, map[string]any{"systolic_bp": 200}); code != 200 {
+		t.Fatalf("Assess failed: %d %v", code, body)
+	}
+	webhooks.Wait()
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/notifications?kind=emergency_webhook", nil))
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("Listing notifications failed: %v %v", err, resp)
+	}
+	var entries []models.NotificationLog
+	json.NewDecoder(resp.Body).Decode(&entries)
+	if len(entries) != 2 {
+		t.Fatalf("Expected one entry per destination, got %+v", entries)
+	}
+	byURL := map[string]models.NotificationLog{}
+	for _, e := range entries {
+		byURL[e.Destination] = e
+	}
+	if e := byURL[ok.URL]; e.Decision != services.NotificationSent || e.Attempts != 1 || e.SentAt == nil {
+		t.Errorf("Expected the healthy receiver logged as sent, got %+v", e)
+	}
+	if e := byURL[down.URL]; e.Decision != services.NotificationFailed || e.Attempts != 2 || failing.Load() != 2 || !strings.Contains(e.Reason, "500") {
+		t.Errorf("Expected the failing receiver logged as failed after a retry, got %+v", e)
+	}
+}
+
+func TestEmergencyWebhook_SlowReceiverDoesNotBlockAssess(t *testing.T) {
+	release := make(chan struct{})
+	unblock := sync.OnceFunc(func() { close(release) })
+	var delivered atomic.Bool
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		delivered.Store(true)
+	}))
+	t.Cleanup(slow.Close)
+
+	app, webhooks, _ := newWebhookApp(t, slow.URL)
+	t.Cleanup(unblock) // Runs first, so a failure can't leave the delivery hanging
+	start := time.Now()
+	code, body := assessWith(t, app, map[string]any{"systolic_bp": 200})
+	if code != 200 || body["emergency"] != true {
+		t.Fatalf("Expected an emergency, got %d %v", code, body)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Assess waited on the webhook: took %v", elapsed)
+	}
+	if delivered.Load() {
+		t.Error("Expected the webhook still in flight when assess returned")
+	}
+
+	unblock()
+	webhooks.Wait()
+	if !delivered.Load() {
+		t.Error("Expected the webhook delivered once the receiver answered")
+	}
+}
+
+func TestEmergencyWebhook_NotSentForRoutineAssessments(t *testing.T) {
+	var calls atomic.Int64
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { calls.Add(1) }))
+	t.Cleanup(receiver.Close)
+
+	app, webhooks, db := newWebhookApp(t, receiver.URL)
+	if code, body := assessWith(t, app, nil); code != 200 || body["emergency"] != false {
+		t.Fatalf("Expected a routine assessment, got %d %v", code, body)
+	}
+	webhooks.Wait()
+	var logged int64
+	db.Model(&models.NotificationLog{}).Count(&logged)
+	if calls.Load() != 0 || logged != 0 {
+		t.Errorf("Expected no webhook for a routine assessment, got %d call(s), %d log entries", calls.Load(), logged)
+	}
+}
@@ -0,0 +1,200 @@
+package unit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/database"
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/middleware"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newErasureApp serves the erase route as the actor whose role is in X-Test-Role
+func newErasureApp(t *testing.T) (*fiber.App, *gorm.DB, *services.AuditService) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	db.AutoMigrate(database.Models()...)
+
+	audit := services.NewAuditService(db)
+	h := handlers.NewErasureHandler(services.NewErasureService(db, services.NewPredictionService("http://ml.invalid")), audit, handlers.NewWebSocketHandler())
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		auditctx.Set(c, auditctx.Identity{ID: "user:1", Role: c.Get("X-Test-Role")})
+		return c.Next()
+	})
+	app.Delete("/api/patients/:id/erase", middleware.RequireRole(auditctx.RoleAdmin), h.Erase)
+	return app, db, audit
+}
+
+// seedErasable creates a patient with a record in every table erasure touches
+func seedErasable(t *testing.T, db *gorm.DB, audit *services.AuditService, name string) models.PatientData {
+	p := models.PatientData{Name: name, Age: 58, Gender: "Female", SystolicBP: 150, DiastolicBP: 95, Glucose: 130, BMI: 29,
+		Medications: "Warfarin", Allergies: "Penicillin", Symptoms: "chest pain", Smoking: "Yes", Alcohol: "No", Clinic: "north"}
+	db.Create(&p)
+
+	a := models.Assessment{PatientID: p.ID, HeartRisk: 72, Explanations: `[{"model":"heart"}]`}
+	a.SetPatientSnapshot(p)
+	db.Create(&a)
+	db.Create(&models.AssessmentComponent{AssessmentID: a.ID, Component: models.ComponentMedications, Result: map[string]any{"drug": "Warfarin"}})
+	fb := models.Feedback{PatientID: p.ID, DoctorNotes: "Lives alone, " + name, RiskProfile: `{"heart":72}`}
+	db.Create(&fb)
+	db.Create(&models.FeedbackRevision{FeedbackID: fb.ID, Revision: 1, DoctorNotes: "Lives with " + name + "'s daughter", Reason: "Spoke to " + name})
+	db.Create(&models.OverrideLog{PatientID: p.ID, ReasonCode: "history", ReasonText: "Sister had an MI at 50"})
+	db.Create(&models.DiagnosisContext{PatientID: p.ID, PastContext: "Prior visit", Prompt: "58F with chest pain"})
+	db.Create(&models.NotificationLog{PatientID: p.ID, Kind: "emergency_alert", Payload: `{"name":"` + name + `"}`})
+	db.Create(&models.EKGAnalysis{PatientID: p.ID, TopCondition: "AF"})
+	db.Create(&models.OutboxMessage{PatientID: p.ID, Subject: "llm.tasks", Data: `{"patient":{"name":"` + name + `"}}`})
+	if _, err := audit.LogEvent("PATIENT_CREATED", p.ID, p, auditctx.System); err != nil {
+		t.Fatalf("LogEvent failed: %v", err)
+	}
+	return p
+}
+
+func erase(t *testing.T, app *fiber.App, role string, patientID uint, confirm string) (int, map[string]any) {
+	path := fmt.Sprintf("/api/patients/%d/erase", patientID)
+	if confirm != "" {
+		path += "?confirm=" + confirm
+	}
+	req := httptest.NewRequest("DELETE", path, nil)
+	req.Header.Set("X-Test-Role", role)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("DELETE %s failed: %v", path, err)
+	}
+	var body map[string]any
+	json.NewDecoder(resp.Body).Decode(&body)
+	return resp.StatusCode, body
+}
+
+func TestErasure_AnonymizesAndKeepsAuditChain(t *testing.T) {
+	app, db, audit := newErasureApp(t)
+	target := seedErasable(t, db, audit, "Ayse Yilmaz")
+	bystander := seedErasable(t, db, audit, "Mehmet Kaya")
+
+	code, body := erase(t, app, auditctx.RoleAdmin, target.ID, "")
+	token, _ := body["confirmation_token"].(string)
+	if code != 428 || token == "" {
+		t.Fatalf("Expected a confirmation token first, got %d %v", code, body)
+	}
+	var stillThere models.PatientData
+	db.First(&stillThere, target.ID)
+	if stillThere.Name != "Ayse Yilmaz" {
+		t.Fatal("Expected nothing erased before confirmation")
+	}
+
+	code, body = erase(t, app, auditctx.RoleAdmin, target.ID, token)
+	if code != 200 {
+		t.Fatalf("Erase failed: %d %v", code, body)
+	}
+	var report models.ErasureReport
+	raw, _ := json.Marshal(body)
+	json.Unmarshal(raw, &report)
+	removed := map[string]int64{}
+	for _, item := range report.Removed {
+		removed[item.Table] = item.Records
+	}
+	for _, table := range []string{"patient_data", "feedback", "override_logs", "assessments", "assessment_components", "diagnosis_contexts", "notification_logs", "ekg_analyses", "outbox_messages"} {
+		if removed[table] != 1 {
+			t.Errorf("Expected 1 %s record removed, got %d", table, removed[table])
+		}
+	}
+	for _, item := range report.Retained {
+		if item.LegalBasis == "" {
+			t.Errorf("Expected a legal basis for retained %s", item.Table)
+		}
+		if item.Table == "audit_logs" && item.Records != 1 {
+			t.Errorf("Expected the patient's audit entry retained, got %d", item.Records)
+		}
+	}
+
+	var erased models.PatientData
+	db.Unscoped().First(&erased, target.ID)
+	if erased.Name != "" || erased.Age != 0 || erased.Medications != "" || erased.Clinic != "" || !erased.DeletedAt.Valid {
+		t.Errorf("Expected a hidden, blank tombstone, got %+v", erased)
+	}
+	var a models.Assessment
+	db.Where("patient_id = ?", target.ID).First(&a)
+	if a.PatientSnapshot != "" || a.Explanations != "" || a.HeartRisk != 72 || a.SnapshotHash == "" {
+		t.Errorf("Expected the snapshot gone but the risk and hash kept, got %+v", a)
+	}
+	var f models.Feedback
+	db.Where("patient_id = ?", target.ID).First(&f)
+	if f.DoctorNotes != services.RedactedText || f.RiskProfile == "" {
+		t.Errorf("Expected notes redacted and the risk profile kept, got %+v", f)
+	}
+	var rev models.FeedbackRevision
+	db.Where("feedback_id = ?", f.ID).First(&rev)
+	if rev.DoctorNotes != services.RedactedText || rev.Reason != "" {
+		t.Errorf("Expected earlier versions of the notes redacted, got %+v", rev)
+	}
+	var ekgs int64
+	db.Model(&models.EKGAnalysis{}).Where("patient_id = ?", target.ID).Count(&ekgs)
+	if ekgs != 0 {
+		t.Errorf("Expected EKG analyses deleted, got %d", ekgs)
+	}
+	var tasks int64
+	db.Model(&models.OutboxMessage{}).Where("patient_id = ?", target.ID).Count(&tasks)
+	if tasks != 0 {
+		t.Errorf("Expected queued diagnosis tasks deleted, got %d", tasks)
+	}
+
+	var other models.PatientData
+	db.First(&other, bystander.ID)
+	var otherNotes models.Feedback
+	db.Where("patient_id = ?", bystander.ID).First(&otherNotes)
+	if other.Name != "Mehmet Kaya" || otherNotes.DoctorNotes == services.RedactedText {
+		t.Error("Expected other patients untouched")
+	}
+
+	var erasures int64
+	db.Model(&models.AuditLog{}).Where("event_type = ? AND actor_id = ?", "ERASURE_REQUEST", "user:1").Count(&erasures)
+	if valid, _, err := audit.VerifyChain(); err != nil || !valid || erasures != 1 {
+		t.Errorf("Expected the erasure audited on an intact chain, got valid=%v err=%v events=%d", valid, err, erasures)
+	}
+}
+
+func TestErasure_RequiresAdminAndAValidToken(t *testing.T) {
+	app, db, audit := newErasureApp(t)
+	first := seedErasable(t, db, audit, "Ayse Yilmaz")
+	second := seedErasable(t, db, audit, "Mehmet Kaya")
+
+	if code, _ := erase(t, app, auditctx.RoleDoctor, first.ID, ""); code != 403 {
+		t.Errorf("Expected doctors refused, got %d", code)
+	}
+	if code, _ := erase(t, app, auditctx.RoleAdmin, 999, ""); code != 404 {
+		t.Errorf("Expected 404 for an unknown patient, got %d", code)
+	}
+
+	_, body := erase(t, app, auditctx.RoleAdmin, first.ID, "")
+	token := body["confirmation_token"].(string)
+	if code, _ := erase(t, app, auditctx.RoleAdmin, first.ID, "wrong"); code != 400 {
+		t.Errorf("Expected a wrong token refused, got %d", code)
+	}
+	if code, _ := erase(t, app, auditctx.RoleAdmin, second.ID, token); code != 400 {
+		t.Errorf("Expected a token bound to its patient, got %d", code)
+	}
+	if code, _ := erase(t, app, auditctx.RoleAdmin, first.ID, token); code != 200 {
+		t.Errorf("Expected the token to work for its patient, got %d", code)
+	}
+	if code, _ := erase(t, app, auditctx.RoleAdmin, first.ID, token); code != 400 {
+		t.Errorf("Expected the token single-use, got %d", code)
+	}
+
+	var intact models.PatientData
+	db.First(&intact, second.ID)
+	if intact.Name != "Mehmet Kaya" {
+		t.Error("Expected the mismatched token to erase nothing")
+	}
+}
@@ -0,0 +1,34 @@
+package unit
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/apierrors"
+	"healthcare-backend/pkg/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestErrorHandler_StorageBusy tests that a busy database maps to 503 with Retry-After
+func TestErrorHandler_StorageBusy(t *testing.T) {
+	app := fiber.New()
+	app.Use(middleware.ErrorHandler)
+	app.Post("/api/assess", func(c *fiber.Ctx) error {
+		return &apierrors.StorageBusy{RetryAfter: 2 * time.Second, Err: errors.New("database is locked")}
+	})
+
+	resp, err := app.Test(httptest.NewRequest("POST", "/api/assess", nil))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+
+	if resp.StatusCode != 503 {
+		t.Errorf("Expected status 503, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Retry-After") != "2" {
+		t.Errorf("Expected Retry-After '2', got '%s'", resp.Header.Get("Retry-After"))
+	}
+}
@@ -0,0 +1,203 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/repositories"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type explanationFixture struct {
+	Name    string                   `json:"name"`
+	Patient models.PatientData       `json:"patient"`
+	Risks   models.PredictResponse   `json:"risks"`
+	Want    []models.RiskExplanation `json:"want"`
+}
+
+func TestExplanationSummaries_MatchFixtures(t *testing.T) {
+	raw, err := os.ReadFile("testdata/explanation_summaries.json")
+	if err != nil {
+		t.Fatalf("Failed to read fixtures: %v", err)
+	}
+	var fixtures []explanationFixture
+	if err := json.Unmarshal(raw, &fixtures); err != nil {
+		t.Fatalf("Failed to parse fixtures: %v", err)
+	}
+
+	s := services.NewExplanationSummarizer()
+	for _, fx := range fixtures {
+		t.Run(fx.Name, func(t *testing.T) {
+			got := s.Summarize(fx.Patient, fx.Risks)
+			if !reflect.DeepEqual(got, fx.Want) {
+				gotJSON, _ := json.MarshalIndent(got, "", "  ")
+				t.Errorf("Summaries differ from fixture\ngot:  %s", gotJSON)
+			}
+		})
+	}
+}
+
+// TestExplanationSummaries_RuleScoresMatchSentences tests that the fallback
+// scores and the sentences come from the same rule
+func TestExplanationSummaries_RuleScoresMatchSentences(t *testing.T) {
+	p := models.PatientData{Age: 70, SystolicBP: 150, Cholesterol: 250, Glucose: 90, BMI: 22}
+	risks := services.NewPredictionService("").RuleBasedPredictRisks(p)
+	if risks.HeartRisk != 90 || risks.DiabetesRisk != 10 || risks.StrokeRisk != 35 || risks.KidneyRisk != 10 {
+		t.Fatalf("Unexpected fallback scores %+v", risks)
+	}
+
+	want := []string{
+		"Heart risk estimated high by clinical rules (ML unavailable): cholesterol 250 above 240.",
+		"Diabetes risk estimated low by clinical rules (ML unavailable): glucose 90 at or below 125 and BMI 22 at or below 30.",
+		"Stroke risk estimated moderate by clinical rules (ML unavailable): age 70 above 50 and systolic BP 150 above 140.",
+		"Kidney risk estimated low by clinical rules (ML unavailable): age 70 at or below 75, systolic BP 150 at or below 160, glucose 90 at or below 180 and no diabetes history.",
+	}
+	got := services.NewExplanationSummarizer().Summarize(p, *risks)
+	for i, exp := range got {
+		if i >= len(want) || exp.Summary != want[i] || exp.Source != services.ExplanationRules {
+			t.Errorf("Summary %d: got %+v", i, exp)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("Expected %d summaries, got %d", len(want), len(got))
+	}
+}
+
+// TestExplanationSummaries_StoredWithAssessment tests that the summaries in
+// the assess response are the ones the printable report shows later
+func TestExplanationSummaries_StoredWithAssessment(t *testing.T) {
+	h, db, _ := newTestPatientHandler(t, "http://127.0.0.1:1", handlers.NewWebSocketHandler())
+	app := fiber.New()
+	app.Post("/api/assess", h.AssessPatient)
+	app.Get("/api/assessments/:id/report", handlers.NewAssessmentHandler(repositories.NewAssessmentRepository(db)).GetReport)
+
+	body := requiredIntake()
+	body["systolic_bp"] = 170
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest("POST", "/api/assess", bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	raw, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, raw)
+	}
+	var assessed struct {
+		Summaries []models.RiskExplanation `json:"explanation_summaries"`
+	}
+	json.Unmarshal(raw, &assessed)
+	if len(assessed.Summaries) != 4 || assessed.Summaries[0].Summary !=
+		"Heart risk estimated high by clinical rules (ML unavailable): systolic BP 170 above 160." {
+		t.Fatalf("Unexpected summaries in the assess response: %+v", assessed.Summaries)
+	}
+
+	var stored models.Assessment
+	if err := db.Last(&stored).Error; err != nil {
+		t.Fatalf("Assessment not stored: %v", err)
+	}
+	report := getJSON(t, app, fmt.Sprintf("/api/assessments/%d/report", stored.ID))
+	reportJSON, _ := json.Marshal(report["explanation_summaries"])
+	wantJSON, _ := json.Marshal(assessed.Summaries)
+	if string(reportJSON) != string(wantJSON) {
+		t.Errorf("Report summaries differ from the assess response:\nreport: %s\nassess: %s", reportJSON, wantJSON)
+	}
+}
+
+func TestRankFactors_SortsByAbsoluteContribution(t *testing.T) {
+	risks := models.PredictResponse{
+		ModelPrecisions: map[string]float64{"Heart_Model": 0.9},
+		Explanations: map[string]map[string]float64{
+			"heart": {"age": 0.12, "systolic_bp": -0.31, "cholesterol": 0.2, "new_marker": 0.01},
+		},
+	}
+	got := services.RankFactors(models.PatientData{}, risks)
+	if len(got) != 1 || got[0].Model != "heart" || got[0].Source != services.ExplanationSHAP {
+		t.Fatalf("Expected heart factors only, got %+v", got)
+	}
+	want := []models.RiskFactor{
+		{Feature: "systolic_bp", Label: "Systolic Blood Pressure", Contribution: -0.31},
+		{Feature: "cholesterol", Label: "Total Cholesterol", Contribution: 0.2},
+		{Feature: "age", Label: "Age", Contribution: 0.12},
+		{Feature: "new_marker", Label: "New Marker", Contribution: 0.01},
+	}
+	if !reflect.DeepEqual(got[0].Factors, want) {
+		t.Errorf("Unexpected factors %+v", got[0].Factors)
+	}
+}
+
+func TestRankFactors_RuleBasedReportsThresholds(t *testing.T) {
+	p := models.PatientData{Age: 70, SystolicBP: 150, Cholesterol: 250, Glucose: 90, BMI: 22}
+	got := services.RankFactors(p, *services.NewPredictionService("").RuleBasedPredictRisks(p))
+	if len(got) != 4 {
+		t.Fatalf("Expected a rule for every model, got %+v", got)
+	}
+	heart, diabetes, stroke := got[0], got[1], got[2]
+	if heart.Level != "high" || !reflect.DeepEqual(heart.Thresholds, []models.RuleThreshold{{Feature: "cholesterol", Label: "Total Cholesterol", Value: 250, Above: 240}}) {
+		t.Errorf("Unexpected heart thresholds %+v", heart)
+	}
+	if diabetes.Level != "low" || len(diabetes.Thresholds) != 0 {
+		t.Errorf("Expected no diabetes threshold fired, got %+v", diabetes)
+	}
+	if stroke.Level != "moderate" || len(stroke.Thresholds) != 2 || stroke.Source != services.ExplanationRules {
+		t.Errorf("Expected age and systolic BP for stroke, got %+v", stroke)
+	}
+}
+
+func TestPatientExplanations_LatestAssessment(t *testing.T) {
+	ml := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/predict":
+			json.NewEncoder(w).Encode(models.PredictResponse{
+				HeartRisk:       40,
+				ModelPrecisions: map[string]float64{"Heart_Model": 0.9},
+				Explanations:    map[string]map[string]float64{"heart": {"bmi": 0.05, "systolic_bp": 0.4}},
+			})
+		case "/urgency/predict":
+			json.NewEncoder(w).Encode(models.UrgencyResponse{UrgencyLevel: 2})
+		default:
+			json.NewEncoder(w).Encode(models.DiagnosisResponse{Diagnosis: "ok", Status: "ready"})
+		}
+	}))
+	t.Cleanup(ml.Close)
+
+	h, db, _ := newTestPatientHandler(t, ml.URL, handlers.NewWebSocketHandler())
+	app := fiber.New()
+	app.Post("/api/assess", h.AssessPatient)
+	app.Get("/api/patients/:id/explanations", handlers.NewAssessmentHandler(repositories.NewAssessmentRepository(db)).GetPatientExplanations)
+
+	data, _ := json.Marshal(requiredIntake())
+	code, assessed := postJSON(t, app, "/api/assess", string(data))
+	if code != 200 {
+		t.Fatalf("Assess failed: %d %v", code, assessed)
+	}
+	inline, _ := json.Marshal(assessed["explanations"])
+
+	got := getJSON(t, app, fmt.Sprintf("/api/patients/%v/explanations", assessed["id"]))
+	stored, _ := json.Marshal(got["explanations"])
+	if string(stored) != string(inline) {
+		t.Errorf("Endpoint differs from the assess response:\nendpoint: %s\nassess:   %s", stored, inline)
+	}
+	var factors []models.ModelFactors
+	json.Unmarshal(stored, &factors)
+	if len(factors) != 1 || len(factors[0].Factors) != 2 || factors[0].Factors[0].Label != "Systolic Blood Pressure" {
+		t.Errorf("Expected systolic BP ranked first, got %s", stored)
+	}
+
+	req := httptest.NewRequest("GET", "/api/patients/999/explanations", nil)
+	if resp, _ := app.Test(req); resp.StatusCode != 404 {
+		t.Errorf("Expected 404 for a patient without assessments, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,73 @@
+package unit
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func assessForSource(t *testing.T, mlURL string) (*fiber.App, *gorm.DB, map[string]any) {
+	h, db, pred := newTestPatientHandler(t, mlURL, handlers.NewWebSocketHandler())
+	app := fiber.New()
+	app.Post("/api/assess", h.AssessPatient)
+	app.Get("/api/dashboard/summary", handlers.NewDashboardHandler(db, pred, services.NewAuditService(db)).GetSummary)
+
+	data, _ := json.Marshal(requiredIntake())
+	code, body := postJSON(t, app, "/api/assess", string(data))
+	if code != 200 {
+		t.Fatalf("Assess failed: %d %v", code, body)
+	}
+	return app, db, body
+}
+
+func TestAssess_FlagsRuleBasedFallback(t *testing.T) {
+	app, db, body := assessForSource(t, "http://127.0.0.1:1")
+
+	risks, _ := body["risks"].(map[string]any)
+	if risks["source"] != models.PredictionSourceRuleBased || body["prediction_source"] != models.PredictionSourceRuleBased {
+		t.Errorf("Expected the fallback flagged, got risks.source=%v prediction_source=%v", risks["source"], body["prediction_source"])
+	}
+	var events []string
+	db.Model(&models.AuditLog{}).Where("event_type LIKE ?", "%PREDICTION").Pluck("event_type", &events)
+	if len(events) != 1 || events[0] != "FALLBACK_PREDICTION" {
+		t.Errorf("Expected a FALLBACK_PREDICTION audit event in place of AI_PREDICTION, got %v", events)
+	}
+
+	summary := getJSON(t, app, "/api/dashboard/summary")
+	if summary["fallback_assessments_24h"] != float64(1) {
+		t.Errorf("Expected one fallback assessment on the dashboard, got %v", summary["fallback_assessments_24h"])
+	}
+	methods, _ := summary["risk_distribution_methods"].(map[string]any)
+	if bp, _ := methods["systolic_bp"].(map[string]any); bp["Low"] != float64(1) {
+		t.Errorf("Expected the patient without an ML score leveled by systolic BP, got %v", methods)
+	}
+}
+
+func TestAssess_MLResultsAreNotFlagged(t *testing.T) {
+	var hits atomic.Int64
+	app, db, body := assessForSource(t, newFakeFullML(t, &hits).URL)
+
+	if body["prediction_source"] != models.PredictionSourceML {
+		t.Errorf("Expected an ML prediction, got %v", body["prediction_source"])
+	}
+	var fallbacks int64
+	db.Model(&models.AuditLog{}).Where("event_type = ?", "FALLBACK_PREDICTION").Count(&fallbacks)
+	if fallbacks != 0 {
+		t.Errorf("Expected no FALLBACK_PREDICTION event, got %d", fallbacks)
+	}
+	summary := getJSON(t, app, "/api/dashboard/summary")
+	if summary["fallback_assessments_24h"] != float64(0) {
+		t.Errorf("Expected no fallback assessments, got %v", summary["fallback_assessments_24h"])
+	}
+	methods, _ := summary["risk_distribution_methods"].(map[string]any)
+	if ml, _ := methods["ml_score"].(map[string]any); ml["Medium"] != float64(1) {
+		t.Errorf("Expected the patient leveled by their heart risk of 40, got %v", methods)
+	}
+}
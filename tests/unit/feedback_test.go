@@ -0,0 +1,262 @@
+package unit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/middleware"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/repositories"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestFeedbackRepository_RevisionsInOrder(t *testing.T) {
+	db := openSearchDB(t)
+	repo := repositories.NewFeedbackRepository(db)
+	submitted := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	first := models.Feedback{CreatedAt: submitted, PatientID: 1, DoctorApproved: true, DoctorNotes: "Start metformin", AuthorID: "user:1"}
+	second := models.Feedback{CreatedAt: submitted, PatientID: 2, DoctorApproved: true, DoctorNotes: "Repeat ECG", AuthorID: "user:2"}
+	repo.Create(&first)
+	repo.Create(&second)
+
+	// Interleaved, so revision order and row order differ
+	steps := []struct {
+		id    uint
+		notes string
+	}{
+		{first.ID, "Start metformin 500 mg"},
+		{second.ID, "Repeat ECG in a week"},
+		{first.ID, "Start metformin 500 mg twice daily"},
+	}
+	for _, s := range steps {
+		if _, err := repo.Amend(s.id, s.notes, "Dose clarified", "user:4"); err != nil {
+			t.Fatalf("Amend failed: %v", err)
+		}
+	}
+
+	revisions, err := repo.Revisions(first.ID)
+	if err != nil {
+		t.Fatalf("Revisions failed: %v", err)
+	}
+	var got []string
+	for i, r := range revisions {
+		if r.Revision != i+1 {
+			t.Errorf("Expected revision %d at position %d, got %d", i+1, i, r.Revision)
+		}
+		got = append(got, r.DoctorNotes)
+	}
+	want := []string{"Start metformin", "Start metformin 500 mg", "Start metformin 500 mg twice daily"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("Expected the original then each amendment, got %q", got)
+	}
+	if original := revisions[0]; original.AuthorID != "user:1" || !original.CreatedAt.Equal(submitted) || original.Reason != "" {
+		t.Errorf("Expected the original kept with its author and time, got %+v", original)
+	}
+	if revisions[2].AuthorID != "user:4" || revisions[2].Reason != "Dose clarified" {
+		t.Errorf("Expected the amendment's author and reason, got %+v", revisions[2])
+	}
+
+	current, _ := repo.GetByID(first.ID)
+	if current.DoctorNotes != want[2] {
+		t.Errorf("Expected the feedback to carry the latest notes, got %q", current.DoctorNotes)
+	}
+	if hits := search(t, db, services.SearchQuery{Text: "twice"}); len(hits) != 1 {
+		t.Errorf("Expected the amended notes searchable, got %+v", hits)
+	}
+	if revisions, _ := repo.Revisions(9999); len(revisions) != 0 {
+		t.Errorf("Expected no revisions for unamended feedback, got %+v", revisions)
+	}
+}
+
+func TestFeedbackHandler_ListAmendAndScope(t *testing.T) {
+	db := openSearchDB(t)
+	providers := services.NewProviderService(db)
+	houseUser, wilsonUser := uint(1), uint(2)
+	house := models.Provider{Name: "Dr. House", UserID: &houseUser}
+	wilson := models.Provider{Name: "Dr. Wilson", UserID: &wilsonUser}
+	providers.Create(&house)
+	providers.Create(&wilson)
+	mine := models.PatientData{Name: "Ayse", Age: 58, AssignedProviderID: &house.ID}
+	theirs := models.PatientData{Name: "Elif", Age: 35, AssignedProviderID: &wilson.ID}
+	db.Create(&mine)
+	db.Create(&theirs)
+
+	audit := services.NewAuditService(db)
+	h := handlers.NewFeedbackHandler(db, repositories.NewFeedbackRepository(db), services.NewOverrideService(db), audit)
+	h.Providers = providers
+	actors := map[string]auditctx.Identity{"house": doctorHouse, "wilson": doctorWilson, "admin": accessAdmin}
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		auditctx.Set(c, actors[c.Get("X-Test-Actor")])
+		return c.Next()
+	})
+	staff := middleware.RequireRole(auditctx.RoleAdmin, auditctx.RoleDoctor)
+	app.Post("/api/feedback", h.SubmitFeedback)
+	app.Get("/api/feedback", staff, h.ListFeedback)
+	app.Get("/api/feedback/:id", staff, h.GetFeedback)
+	app.Put("/api/feedback/:id", staff, h.AmendFeedback)
+	app.Get("/api/assessments/:id/feedback", staff, h.GetAssessmentFeedback)
+	call := func(actor, method, path, body string) (int, string) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Test-Actor", actor)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		raw, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(raw)
+	}
+
+	submit := func(actor string, patientID uint, approved bool, notes string) uint {
+		code, raw := call(actor, "POST", "/api/feedback", fmt.Sprintf(`{"assessment_id":%d,"approved":%t,"notes":%q,"risks":{"heart_risk_score":64}}`, patientID, approved, notes))
+		var out struct{ ID uint }
+		json.Unmarshal([]byte(raw), &out)
+		if code != 200 || out.ID == 0 {
+			t.Fatalf("Submit failed with %d: %s", code, raw)
+		}
+		return out.ID
+	}
+	ownID := submit("house", mine.ID, true, "Agree, start statin")
+	submit("house", mine.ID, false, "Risk overstated")
+	submit("wilson", theirs.ID, true, "Agree")
+
+	// Doctors list their own patients' feedback; admins see all
+	list := func(actor, query string) models.FeedbackPage {
+		code, raw := call(actor, "GET", "/api/feedback?"+query, "")
+		var page models.FeedbackPage
+		json.Unmarshal([]byte(raw), &page)
+		if code != 200 {
+			t.Fatalf("List %q failed with %d: %s", query, code, raw)
+		}
+		return page
+	}
+	if page := list("house", ""); page.Total != 2 {
+		t.Errorf("Expected house to see 2 entries, got %+v", page)
+	}
+	if page := list("admin", "approved=true"); page.Total != 2 {
+		t.Errorf("Expected 2 approved entries, got %+v", page)
+	}
+	if page := list("admin", fmt.Sprintf("patient_id=%d&limit=1", mine.ID)); page.Total != 2 || len(page.Items) != 1 || page.Items[0].DoctorNotes != "Risk overstated" {
+		t.Errorf("Expected the newest of the patient's 2 entries, got %+v", page)
+	}
+	if page := list("admin", ""); page.Items[0].AuthorID != doctorWilson.ID {
+		t.Errorf("Expected the submitting doctor recorded, got %+v", page.Items[0])
+	}
+	for _, query := range []string{"page=0", "limit=500", "patient_id=x", "approved=maybe"} {
+		if code, raw := call("admin", "GET", "/api/feedback?"+query, ""); code != 400 {
+			t.Errorf("Expected 400 for %s, got %d: %s", query, code, raw)
+		}
+	}
+	code, raw := call("house", "GET", fmt.Sprintf("/api/assessments/%d/feedback", mine.ID), "")
+	if code != 200 || strings.Count(raw, `"doctor_notes"`) != 2 {
+		t.Errorf("Expected both entries on the assessment, got %d: %s", code, raw)
+	}
+	if code, raw := call("wilson", "GET", fmt.Sprintf("/api/assessments/%d/feedback", mine.ID), ""); code != 200 || strings.Contains(raw, "doctor_notes") {
+		t.Errorf("Expected nothing for another doctor's patient, got %d: %s", code, raw)
+	}
+
+	// Only the author or an admin amends; each amendment is a revision
+	path := fmt.Sprintf("/api/feedback/%d", ownID)
+	if code, _ := call("wilson", "PUT", path, `{"notes":"Not mine to change"}`); code != 403 {
+		t.Errorf("Expected 403 for another doctor, got %d", code)
+	}
+	if code, _ := call("house", "PUT", path, `{"notes":""}`); code != 400 {
+		t.Errorf("Expected 400 for empty notes, got %d", code)
+	}
+	if code, _ := call("house", "PUT", "/api/feedback/9999", `{"notes":"x"}`); code != 404 {
+		t.Errorf("Expected 404 for unknown feedback, got %d", code)
+	}
+	if code, raw := call("house", "PUT", path, `{"notes":"Agree, start atorvastatin 20 mg","reason":"Named the drug"}`); code != 200 {
+		t.Fatalf("Author amend failed with %d: %s", code, raw)
+	}
+	if code, raw := call("admin", "PUT", path, `{"notes":"Agree, start atorvastatin 40 mg","reason":"Dose per lipid clinic"}`); code != 200 {
+		t.Fatalf("Admin amend failed with %d: %s", code, raw)
+	}
+
+	code, raw = call("house", "GET", path, "")
+	var body struct {
+		Feedback  models.Feedback
+		Revisions []models.FeedbackRevision
+	}
+	json.Unmarshal([]byte(raw), &body)
+	if code != 200 || body.Feedback.DoctorNotes != "Agree, start atorvastatin 40 mg" || len(body.Revisions) != 3 {
+		t.Fatalf("Expected the latest notes and 3 revisions, got %d: %s", code, raw)
+	}
+	if body.Revisions[0].DoctorNotes != "Agree, start statin" || body.Revisions[1].AuthorID != doctorHouse.ID || body.Revisions[2].AuthorID != accessAdmin.ID {
+		t.Errorf("Expected the original, then house's and the admin's amendments, got %+v", body.Revisions)
+	}
+	if code, _ := call("wilson", "GET", path, ""); code != 403 {
+		t.Errorf("Expected 403 reading another doctor's patient, got %d", code)
+	}
+
+	audit.Flush()
+	var amended []models.AuditLog
+	db.Where("event_type = ?", "FEEDBACK_AMENDED").Order("id").Find(&amended)
+	if len(amended) != 2 || amended[0].ActorID != doctorHouse.ID || amended[1].ActorID != accessAdmin.ID {
+		t.Errorf("Expected both amendments audited by who made them, got %+v", amended)
+	}
+}
+
+func TestSubmitFeedback_StoresValidatedRiskSnapshot(t *testing.T) {
+	db := openSearchDB(t)
+	h := handlers.NewFeedbackHandler(db, repositories.NewFeedbackRepository(db), services.NewOverrideService(db), services.NewAuditService(db))
+	app := fiber.New()
+	app.Post("/api/feedback", h.SubmitFeedback)
+	app.Get("/api/feedback/:id", h.GetFeedback)
+
+	risks := `{"heart_risk_score": 72.5, "diabetes_risk_score": 18, "stroke_risk_score": 9, "kidney_risk_score": 5,
+		"general_health_score": 71.4, "clinical_confidence": 88, "model_precisions": {"heart": 0.91}, "source": "ml"}`
+	code, body := postJSON(t, app, "/api/feedback", `{"assessment_id": 4, "approved": false, "notes": "Heart risk overstated", "risks": `+risks+`}`)
+	if code != 200 {
+		t.Fatalf("Expected 200, got %d: %s", code, body)
+	}
+	var stored models.Feedback
+	db.Last(&stored)
+	snapshot, err := stored.Risks()
+	if err != nil || snapshot == nil || snapshot.HeartRisk != 72.5 || snapshot.ModelPrecisions["heart"] != 0.91 || snapshot.Source != "ml" {
+		t.Fatalf("Expected the risks stored as a PredictResponse, got %q (%v)", stored.RiskProfile, err)
+	}
+
+	out := getJSON(t, app, fmt.Sprintf("/api/feedback/%d", stored.ID))
+	decoded, _ := out["risks"].(map[string]any)
+	if decoded["heart_risk_score"] != 72.5 || out["feedback"].(map[string]any)["doctor_notes"] != "Heart risk overstated" {
+		t.Errorf("Expected the decoded snapshot alongside the notes, got %v", out)
+	}
+
+	// Approvals may leave the risks out; rejections may not
+	if code, body := postJSON(t, app, "/api/feedback", `{"assessment_id": 4, "approved": true}`); code != 200 {
+		t.Errorf("Expected an approval without risks accepted, got %d: %s", code, body)
+	}
+	var approval models.Feedback
+	db.Last(&approval)
+	if out := getJSON(t, app, fmt.Sprintf("/api/feedback/%d", approval.ID)); out["risks"] != nil {
+		t.Errorf("Expected no snapshot, got %v", out["risks"])
+	}
+	for _, bad := range []string{
+		`{"assessment_id": 4, "approved": false}`,
+		`{"assessment_id": 4, "approved": false, "risks": null}`,
+		`{"assessment_id": 4, "approved": false, "risks": {"heart": 42.5}}`,
+		`{"assessment_id": 4, "approved": false, "risks": {"heart_risk_score": "high"}}`,
+		`{"assessment_id": 4, "approved": false, "risks": {"heart_risk_score": 142}}`,
+		`{"assessment_id": 4, "approved": true, "risks": [72.5]}`,
+	} {
+		if code, body := postJSON(t, app, "/api/feedback", bad); code != 400 {
+			t.Errorf("Expected 400 for %s, got %d: %s", bad, code, body)
+		}
+	}
+	var n int64
+	db.Model(&models.Feedback{}).Count(&n)
+	if n != 2 {
+		t.Errorf("Expected rejected payloads not stored, got %d rows", n)
+	}
+}
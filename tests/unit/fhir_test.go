@@ -0,0 +1,461 @@
+package unit
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/adapters"
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/repositories"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// fhirCoding, fhirQuantity and fhirResource decode the parts of FHIR JSON
+// the tests check
+type fhirCoding struct {
+	System, Code, Display string
+}
+
+type fhirQuantity struct {
+	Value              float64
+	Unit, System, Code string
+}
+
+type fhirResource struct {
+	ResourceType      string
+	ID                string
+	Type              string
+	Total             int
+	EffectiveDateTime string
+	Code              struct{ Coding []fhirCoding }
+	Category          []struct{ Coding []fhirCoding }
+	Subject           struct{ Reference string }
+	ValueQuantity     *fhirQuantity
+	Component         []struct {
+		Code          struct{ Coding []fhirCoding }
+		ValueQuantity fhirQuantity
+	}
+	Entry []struct {
+		FullURL  string
+		Resource fhirResource
+		Search   struct{ Mode string }
+	}
+	Issue []struct{ Code string }
+
+	Conclusion                string
+	Summary                   string
+	MedicationCodeableConcept struct{ Text string }
+	Target                    []struct{ Reference string }
+	Link                      []struct{ Relation, URL string }
+	SupportingInfo            []struct{ Reference string }
+}
+
+func newFHIRApp(t *testing.T) (*fiber.App, *gorm.DB, *handlers.PatientHandler) {
+	h, db, _ := newTestPatientHandler(t, "http://ml.invalid", handlers.NewWebSocketHandler())
+	fhir := adapters.NewFHIRAdapter()
+	fhir.BaseURL = "https://health.example/api/fhir"
+	fh := handlers.NewFHIRHandler(h, repositories.NewFeedbackRepository(db), fhir)
+	app := fiber.New()
+	app.Get("/api/fhir/Patient/:id/Observation", fh.GetObservations)
+	app.Get("/api/fhir/Patient/:id/$everything", fh.GetEverything)
+	return app, db, h
+}
+
+func fhirGet(t *testing.T, app *fiber.App, path string) (int, fhirResource) {
+	resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", path, err)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != handlers.FHIRContentType {
+		t.Errorf("Expected %s, got %q", handlers.FHIRContentType, ct)
+	}
+	var r fhirResource
+	json.NewDecoder(resp.Body).Decode(&r)
+	return resp.StatusCode, r
+}
+
+func TestFHIR_VitalObservationsRoundTrip(t *testing.T) {
+	app, db, _ := newFHIRApp(t)
+	p := models.PatientData{CreatedAt: time.Date(2025, 4, 2, 8, 30, 0, 0, time.UTC), Age: 60, Gender: "Male",
+		SystolicBP: 145, DiastolicBP: 92, Glucose: 118, BMI: 27.4, Cholesterol: 0, HeartRate: 71,
+		ImputedFields: "cholesterol"}
+	db.Create(&p)
+
+	code, bundle := fhirGet(t, app, fmt.Sprintf("/api/fhir/Patient/%d/Observation", p.ID))
+	if code != 200 || bundle.ResourceType != "Bundle" || bundle.Type != "searchset" {
+		t.Fatalf("Expected a searchset Bundle, got %d %+v", code, bundle)
+	}
+	if bundle.Total != 4 || len(bundle.Entry) != 4 {
+		t.Fatalf("Expected BP, glucose, BMI and heart rate (no imputed cholesterol), got %d", len(bundle.Entry))
+	}
+
+	values := map[string]fhirQuantity{}
+	for _, e := range bundle.Entry {
+		obs := e.Resource
+		if e.FullURL != "https://health.example/api/fhir/Observation/"+obs.ID || e.Search.Mode != "match" {
+			t.Errorf("Unexpected entry %q %q", e.FullURL, e.Search.Mode)
+		}
+		if obs.ResourceType != "Observation" || obs.Subject.Reference != fmt.Sprintf("Patient/pat-%d", p.ID) || obs.EffectiveDateTime != "2025-04-02T08:30:00Z" {
+			t.Errorf("Unexpected observation %+v", obs)
+		}
+		coding := obs.Code.Coding[0]
+		if coding.System != adapters.LOINCSystem || obs.Category[0].Coding[0].System != adapters.ObservationCategorySystem {
+			t.Errorf("Expected LOINC and observation-category codings, got %+v", obs)
+		}
+		if obs.ValueQuantity != nil {
+			values[coding.Code] = *obs.ValueQuantity
+		}
+		for _, c := range obs.Component {
+			values[c.Code.Coding[0].Code] = c.ValueQuantity
+		}
+	}
+
+	want := map[string]fhirQuantity{
+		"8480-6":  {Value: 145, Unit: "mmHg", Code: "mm[Hg]"},
+		"8462-4":  {Value: 92, Unit: "mmHg", Code: "mm[Hg]"},
+		"2339-0":  {Value: 118, Unit: "mg/dL", Code: "mg/dL"},
+		"39156-5": {Value: 27.4, Unit: "kg/m2", Code: "kg/m2"},
+		"8867-4":  {Value: 71, Unit: "beats/minute", Code: "/min"},
+	}
+	for loinc, w := range want {
+		got, ok := values[loinc]
+		if !ok || got.Value != w.Value || got.Unit != w.Unit || got.Code != w.Code || got.System != adapters.UCUMSystem {
+			t.Errorf("LOINC %s: expected %+v, got %+v", loinc, w, got)
+		}
+	}
+}
+
+func TestFHIR_ObservationsOfUnknownPatient(t *testing.T) {
+	app, _, _ := newFHIRApp(t)
+	code, outcome := fhirGet(t, app, "/api/fhir/Patient/999/Observation")
+	if code != 404 || outcome.ResourceType != "OperationOutcome" || outcome.Issue[0].Code != "not-found" {
+		t.Errorf("Expected a not-found OperationOutcome, got %d %+v", code, outcome)
+	}
+}
+
+func TestFHIR_EverythingBundle(t *testing.T) {
+	app, db, _ := newFHIRApp(t)
+	day := func(d int) time.Time { return time.Date(2025, 5, d, 9, 0, 0, 0, time.UTC) }
+	p := models.PatientData{CreatedAt: day(1), Age: 52, Gender: "Female", SystolicBP: 150, DiastolicBP: 95,
+		Medications: "Metformin, , Lisinopril"}
+	db.Create(&p)
+	first := models.Assessment{CreatedAt: day(1), PatientID: p.ID, HeartRisk: 40}
+	second := models.Assessment{CreatedAt: day(10), PatientID: p.ID, HeartRisk: 72, Emergency: true}
+	db.Create(&first)
+	db.Create(&second)
+	db.Create(&models.Feedback{CreatedAt: day(12), PatientID: p.ID, DoctorApproved: true, DoctorNotes: "Referred to cardiology"})
+
+	code, bundle := fhirGet(t, app, fmt.Sprintf("/api/fhir/Patient/%d/$everything", p.ID))
+	if code != 200 || bundle.ResourceType != "Bundle" || bundle.Type != "collection" {
+		t.Fatalf("Expected a collection Bundle, got %d %+v", code, bundle)
+	}
+
+	byType := map[string][]fhirResource{}
+	ids := map[string]bool{}
+	for _, e := range bundle.Entry {
+		r := e.Resource
+		ref := r.ResourceType + "/" + r.ID
+		if e.FullURL != "https://health.example/api/fhir/"+ref || ids[ref] {
+			t.Errorf("Expected a unique fullUrl for %s, got %q", ref, e.FullURL)
+		}
+		ids[ref] = true
+		byType[r.ResourceType] = append(byType[r.ResourceType], r)
+	}
+	if len(byType["Patient"]) != 1 || byType["Patient"][0].ID != fmt.Sprintf("pat-%d", p.ID) {
+		t.Errorf("Expected the Patient, got %+v", byType["Patient"])
+	}
+	if len(byType["Observation"]) != 1 {
+		t.Errorf("Expected the blood pressure Observation only, got %d", len(byType["Observation"]))
+	}
+	reports := byType["DiagnosticReport"]
+	if len(reports) != 2 || reports[1].ID != fmt.Sprintf("rpt-%d", second.ID) || reports[1].Conclusion != "Emergency" || reports[1].EffectiveDateTime != "2025-05-10T09:00:00Z" {
+		t.Errorf("Expected a report per assessment, oldest first, got %+v", reports)
+	}
+	meds := byType["MedicationStatement"]
+	if len(meds) != 2 || meds[0].MedicationCodeableConcept.Text != "Metformin" || meds[1].MedicationCodeableConcept.Text != "Lisinopril" {
+		t.Errorf("Expected a MedicationStatement per medication, got %+v", meds)
+	}
+
+	// Feedback reviews the latest report before it, and every reference resolves
+	// inside the bundle
+	target := fmt.Sprintf("DiagnosticReport/rpt-%d", second.ID)
+	impressions, provenance := byType["ClinicalImpression"], byType["Provenance"]
+	if len(impressions) != 1 || impressions[0].Summary != "Referred to cardiology" || impressions[0].SupportingInfo[0].Reference != target {
+		t.Errorf("Expected the notes as a ClinicalImpression, got %+v", impressions)
+	}
+	if len(provenance) != 1 || provenance[0].Target[0].Reference != target || !ids[target] {
+		t.Errorf("Expected Provenance on %s, got %+v", target, provenance)
+	}
+}
+
+func TestFHIR_EverythingOfUnknownPatient(t *testing.T) {
+	app, _, _ := newFHIRApp(t)
+	code, outcome := fhirGet(t, app, "/api/fhir/Patient/999/$everything")
+	if code != 404 || outcome.ResourceType != "OperationOutcome" {
+		t.Errorf("Expected a 404 OperationOutcome, got %d %+v", code, outcome)
+	}
+}
+
+func TestFHIR_BundleRoundTrip(t *testing.T) {
+	fhir := adapters.NewFHIRAdapter()
+	original := models.PatientData{ID: 7, CreatedAt: time.Now(), Age: 48, Gender: "Female", Smoking: "Former",
+		SystolicBP: 128, DiastolicBP: 82, Glucose: 97, BMI: 23.8, Cholesterol: 210, HeartRate: 64}
+	resources := append([]map[string]interface{}{fhir.ToFHIRPatient(original)}, fhir.ToFHIRVitalObservations(original)...)
+	// Decode as an integrator's bundle would arrive
+	var bundle map[string]interface{}
+	data, _ := json.Marshal(fhir.ToFHIRBundle("collection", resources))
+	json.Unmarshal(data, &bundle)
+
+	p, warnings, err := fhir.ParseFHIRBundle(bundle)
+	if err != nil || len(warnings) != 0 {
+		t.Fatalf("Expected a clean parse, got %v %v", err, warnings)
+	}
+	if p.Age != 48 || p.Gender != "Female" || p.Smoking != "Former" || p.SystolicBP != 128 || p.DiastolicBP != 82 ||
+		p.Glucose != 97 || p.BMI != 23.8 || p.Cholesterol != 210 || p.HeartRate != 64 {
+		t.Errorf("Expected the vitals back, got %+v", p)
+	}
+	if p.ImputedFields != "steps" || p.Alcohol != models.ClinicDefaultAnswer {
+		t.Errorf("Expected steps left to impute and clinic defaults, got %q %q", p.ImputedFields, p.Alcohol)
+	}
+}
+
+// fhirImportBundle is an integrator's bundle: a Patient, a blood pressure
+// panel, two glucose readings, and observations the adapter can't use
+const fhirImportBundle = `{"resourceType": "Bundle", "type": "collection", "entry": [
+	{"fullUrl": "urn:uuid:p1", "resource": {"resourceType": "Patient", "gender": "male", "birthDate": "1970",
+		"name": [{"given": ["Mehmet"], "family": "Kaya"}]}},
+	{"resource": {"resourceType": "Observation", "id": "bp", "status": "final", "subject": {"reference": "urn:uuid:p1"},
+		"code": {"coding": [{"system": "http://loinc.org", "code": "85354-9"}]},
+		"component": [
+			{"code": {"coding": [{"system": "http://loinc.org", "code": "8480-6"}]}, "valueQuantity": {"value": 141, "code": "mm[Hg]"}},
+			{"code": {"coding": [{"system": "http://loinc.org", "code": "8462-4"}]}, "valueQuantity": {"value": 88, "unit": "mmHg"}}]}},
+	{"resource": {"resourceType": "Observation", "id": "glu-new", "effectiveDateTime": "2025-06-02T08:00:00Z",
+		"code": {"coding": [{"system": "http://loinc.org", "code": "2339-0"}]}, "valueQuantity": {"value": 131, "code": "mg/dL"}}},
+	{"resource": {"resourceType": "Observation", "id": "glu-old", "effectiveDateTime": "2025-01-02T08:00:00Z",
+		"code": {"coding": [{"system": "http://loinc.org", "code": "2339-0"}]}, "valueQuantity": {"value": 99, "code": "mg/dL"}}},
+	{"resource": {"resourceType": "Observation", "id": "bmi", "code": {"coding": [{"system": "http://loinc.org", "code": "39156-5"}]},
+		"valueQuantity": {"value": 29.1, "code": "kg/m2"}}},
+	{"resource": {"resourceType": "Observation", "id": "chol", "code": {"coding": [{"system": "http://loinc.org", "code": "2093-3"}]},
+		"valueQuantity": {"value": 5.2, "code": "mmol/L"}}},
+	{"resource": {"resourceType": "Observation", "id": "spo2", "code": {"coding": [{"system": "http://loinc.org", "code": "59408-5"}]},
+		"valueQuantity": {"value": 97, "code": "%"}}},
+	{"resource": {"resourceType": "Observation", "id": "other", "subject": {"reference": "Patient/someone-else"},
+		"code": {"coding": [{"system": "http://loinc.org", "code": "8867-4"}]}, "valueQuantity": {"value": 70, "code": "/min"}}},
+	{"resource": {"resourceType": "Condition", "id": "c1"}}]}`
+
+func TestFHIR_ParseBundleWarnsAboutWhatItCannotUse(t *testing.T) {
+	var bundle map[string]interface{}
+	json.Unmarshal([]byte(fhirImportBundle), &bundle)
+
+	p, warnings, err := adapters.NewFHIRAdapter().ParseFHIRBundle(bundle)
+	if err != nil {
+		t.Fatalf("Expected the bundle read, got %v", err)
+	}
+	if p.Name != "Mehmet Kaya" || p.Gender != "Male" || p.Age < 54 || p.SystolicBP != 141 || p.DiastolicBP != 88 || p.Glucose != 131 || p.BMI != 29.1 {
+		t.Errorf("Unexpected patient %+v", p)
+	}
+	if p.Cholesterol != 0 || p.HeartRate != 0 {
+		t.Errorf("Expected cholesterol in mmol/L and another patient's heart rate left out, got %+v", p)
+	}
+	for _, want := range []string{"Observation/chol", "Observation/spo2: unrecognized code http://loinc.org|59408-5", "Observation/other", "Condition/c1"} {
+		found := false
+		for _, w := range warnings {
+			found = found || strings.HasPrefix(w, want)
+		}
+		if !found {
+			t.Errorf("Expected a warning for %s, got %v", want, warnings)
+		}
+	}
+	if len(warnings) != 4 {
+		t.Errorf("Expected 4 warnings, got %v", warnings)
+	}
+
+	if _, err := adapters.NewFHIRAdapter().FromFHIRBundle(map[string]interface{}{"resourceType": "Patient"}); !errors.Is(err, adapters.ErrFHIRBundle) {
+		t.Errorf("Expected a Patient alone rejected, got %v", err)
+	}
+}
+
+func postFHIRImport(t *testing.T, app *fiber.App, query, body string) (int, models.FHIRImportResponse, string) {
+	req := httptest.NewRequest("POST", "/api/fhir/import"+query, strings.NewReader(body))
+	req.Header.Set("Content-Type", handlers.FHIRContentType)
+	resp, err := app.Test(req, 10000)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	raw, _ := io.ReadAll(resp.Body)
+	var result models.FHIRImportResponse
+	json.Unmarshal(raw, &result)
+	return resp.StatusCode, result, string(raw)
+}
+
+func TestFHIR_ImportCreatesAndAssesses(t *testing.T) {
+	var predictHits atomic.Int64
+	ml := newFakeFullML(t, &predictHits)
+	h, db, _ := newTestPatientHandler(t, ml.URL, handlers.NewWebSocketHandler())
+	fh := handlers.NewFHIRHandler(h, repositories.NewFeedbackRepository(db), adapters.NewFHIRAdapter())
+	app := fiber.New()
+	app.Post("/api/fhir/import", fh.ImportBundle)
+
+	code, result, body := postFHIRImport(t, app, "", fhirImportBundle)
+	if code != 201 || result.PatientID == 0 || result.Assessment != nil || len(result.Warnings) != 4 {
+		t.Fatalf("Expected the patient created with warnings, got %d %s", code, body)
+	}
+	var stored models.PatientData
+	db.First(&stored, result.PatientID)
+	if stored.Source != services.PatientSourceFHIR || stored.Glucose != 131 || predictHits.Load() != 0 {
+		t.Errorf("Expected a FHIR patient stored unassessed, got %+v", stored)
+	}
+
+	code, result, body = postFHIRImport(t, app, "?assess=true", fhirImportBundle)
+	if code != 201 || result.Assessment == nil || result.Assessment.PatientID != result.PatientID || result.Assessment.Risks.HeartRisk != 40 {
+		t.Fatalf("Expected the patient assessed, got %d %s", code, body)
+	}
+
+	invalid := strings.Replace(fhirImportBundle, `"value": 131`, `"value": 2000`, 1)
+	if code, _, body := postFHIRImport(t, app, "", invalid); code != 400 || !strings.Contains(body, "Glucose") || !strings.Contains(body, "warnings") {
+		t.Errorf("Expected an out of range glucose rejected with the warnings, got %d %s", code, body)
+	}
+	if code, _, _ := postFHIRImport(t, app, "", `{"resourceType": "Patient"}`); code != 400 {
+		t.Errorf("Expected a bare Patient rejected, got %d", code)
+	}
+	var count int64
+	db.Model(&models.PatientData{}).Count(&count)
+	if count != 2 {
+		t.Errorf("Expected only the 2 valid imports stored, got %d", count)
+	}
+}
+
+func TestFHIR_AuditEventMapping(t *testing.T) {
+	audit := services.NewAuditService(openTestAuditDB(t))
+	created, _ := audit.LogEvent("PATIENT_CREATED", 12, fiber.Map{"age": 40}, doctorHouse)
+	denied, _ := audit.LogEvent("ACCESS_DENIED", 0, nil, auditctx.Identity{ID: "user:9", Role: auditctx.RoleKiosk})
+
+	var event struct {
+		fhirResource
+		Action, Outcome, Recorded string
+		Subtype                   []fhirCoding
+		TypeCoding                fhirCoding `json:"type"`
+		Agent                     []struct {
+			Who  struct{ Identifier struct{ Value string } }
+			Role []struct{ Text string }
+		}
+		Entity []struct {
+			What struct{ Identifier struct{ Value string } }
+		}
+		Extension []struct {
+			URL            string
+			Extension      []struct{ URL, ValueString string }
+			ValueSignature struct{ Data string }
+		}
+	}
+	data, _ := json.Marshal(adapters.NewFHIRAdapter().ToFHIRAuditEvent(created))
+	json.Unmarshal(data, &event)
+	if event.ResourceType != "AuditEvent" || event.ID != fmt.Sprintf("audit-%d", created.ID) || event.Action != "C" || event.Outcome != "0" {
+		t.Errorf("Unexpected AuditEvent %s", data)
+	}
+	if event.TypeCoding.System != adapters.DICOMSystem || event.TypeCoding.Code != "110110" || event.Subtype[0].Code != "PATIENT_CREATED" {
+		t.Errorf("Expected a DICOM patient record type keeping our event type, got %s", data)
+	}
+	if event.Agent[0].Who.Identifier.Value != doctorHouse.ID || event.Agent[0].Role[0].Text != doctorHouse.Role {
+		t.Errorf("Expected the actor as the agent, got %s", data)
+	}
+	if len(event.Entity) != 1 || event.Entity[0].What.Identifier.Value != services.PatientHash(12) {
+		t.Errorf("Expected the patient hash as the entity, got %s", data)
+	}
+	if recorded, _ := time.Parse(time.RFC3339Nano, event.Recorded); !recorded.Equal(created.Timestamp) {
+		t.Errorf("Expected the exact timestamp, got %s", event.Recorded)
+	}
+	var chain map[string]string
+	var signature string
+	for _, ext := range event.Extension {
+		if len(ext.Extension) > 0 {
+			chain = map[string]string{}
+			for _, e := range ext.Extension {
+				chain[e.URL] = e.ValueString
+			}
+		}
+		if ext.ValueSignature.Data != "" {
+			signature = ext.ValueSignature.Data
+		}
+	}
+	sig, _ := base64.StdEncoding.DecodeString(signature)
+	if hex.EncodeToString(sig) != created.ActorSignature || chain["currentHash"] != created.CurrentHash || chain["prevHash"] != created.PrevHash {
+		t.Errorf("Expected the signature and chain hashes carried over, got %s", data)
+	}
+
+	data, _ = json.Marshal(adapters.NewFHIRAdapter().ToFHIRAuditEvent(denied))
+	event.Entity = nil
+	json.Unmarshal(data, &event)
+	if event.TypeCoding.Code != "110113" || event.Outcome != "4" || event.Entity != nil {
+		t.Errorf("Expected a failed security alert about no patient, got %s", data)
+	}
+}
+
+func TestFHIR_AuditEventPaging(t *testing.T) {
+	h, db, _ := newTestPatientHandler(t, "http://ml.invalid", handlers.NewWebSocketHandler())
+	fhir := adapters.NewFHIRAdapter()
+	fhir.BaseURL = "https://health.example/api/fhir"
+	fh := handlers.NewFHIRHandler(h, repositories.NewFeedbackRepository(db), fhir)
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		auditctx.Set(c, accessAdmin)
+		return c.Next()
+	})
+	app.Get("/api/fhir/AuditEvent", fh.SearchAuditEvents)
+
+	old, _ := h.Audit.LogEvent("PATIENT_CREATED", 1, nil, doctorHouse)
+	db.Model(&models.AuditLog{}).Where("id = ?", old.ID).Update("timestamp", time.Date(2023, 12, 31, 23, 0, 0, 0, time.UTC))
+	for i := 0; i < 4; i++ {
+		h.Audit.LogEvent("PATIENT_VIEWED", 1, nil, doctorHouse)
+	}
+
+	// 4 views plus the export's own AUDIT_EXPORTED, 2 per page
+	var ids []string
+	path := "/api/fhir/AuditEvent?date=ge2024-01-01&_count=2"
+	for pages := 0; path != ""; pages++ {
+		if pages == 3 {
+			t.Fatalf("Expected 3 pages, still paging at %s", path)
+		}
+		code, bundle := fhirGet(t, app, path)
+		if code != 200 || bundle.Type != "searchset" || bundle.Total != 5 {
+			t.Fatalf("Expected a searchset of 5, got %d %+v", code, bundle)
+		}
+		for _, e := range bundle.Entry {
+			ids = append(ids, e.Resource.ID)
+		}
+		path = ""
+		for _, l := range bundle.Link {
+			if l.Relation == "next" {
+				path = strings.TrimPrefix(l.URL, "https://health.example")
+			}
+		}
+	}
+	if len(ids) != 5 || ids[0] != fmt.Sprintf("audit-%d", old.ID+1) {
+		t.Errorf("Expected the 5 entries since 2024 in chain order, got %v", ids)
+	}
+	var exports int64
+	db.Model(&models.AuditLog{}).Where("event_type = ?", "AUDIT_EXPORTED").Count(&exports)
+	if exports != 1 {
+		t.Errorf("Expected the export recorded once, not per page, got %d", exports)
+	}
+
+	for _, bad := range []string{"?_count=0", "?date=sa2024-01-01", "?date=yesterday", "?_cursor=x"} {
+		if code, outcome := fhirGet(t, app, "/api/fhir/AuditEvent"+bad); code != 400 || outcome.ResourceType != "OperationOutcome" {
+			t.Errorf("%s: expected a 400 OperationOutcome, got %d", bad, code)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/privacy"
+	"healthcare-backend/pkg/repositories"
+	"healthcare-backend/pkg/services"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fieldKey is a 32-byte key filled with b, hex-encoded as configured
+func fieldKey(b byte) string {
+	return hex.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+// useFieldKeys installs keys for the test, built from fieldKey bytes
+func useFieldKeys(t *testing.T, current byte, previous ...byte) *privacy.FieldKeys {
+	t.Helper()
+	var old [][]byte
+	for _, b := range previous {
+		old = append(old, bytes.Repeat([]byte{b}, 32))
+	}
+	keys, err := privacy.NewFieldKeys(bytes.Repeat([]byte{current}, 32), old...)
+	if err != nil {
+		t.Fatalf("NewFieldKeys failed: %v", err)
+	}
+	privacy.UseFieldKeys(keys)
+	t.Cleanup(func() { privacy.UseFieldKeys(nil) })
+	return keys
+}
+
+func openEncryptionDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	db.AutoMigrate(&models.PatientData{}, &models.Assessment{})
+	return db
+}
+
+// rawColumn reads a column as stored, bypassing the serializer
+func rawColumn(t *testing.T, db *gorm.DB, table, column string, id uint) string {
+	t.Helper()
+	var value string
+	if err := db.Table(table).Select(column).Where("id = ?", id).Row().Scan(&value); err != nil {
+		t.Fatalf("Reading %s.%s failed: %v", table, column, err)
+	}
+	return value
+}
+
+func TestFieldEncryption_SealsPatientTextAtRest(t *testing.T) {
+	keys := useFieldKeys(t, 1)
+	db := openEncryptionDB(t)
+
+	patient := models.PatientData{Name: "Ayşe Yılmaz", Age: 58, Gender: "Female", SystolicBP: 150, Glucose: 140, BMI: 29,
+		Medications: "Warfarin, Aspirin", Allergies: "Penicillin", Symptoms: "chest pain, dizziness"}
+	if err := db.Create(&patient).Error; err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	for column, plain := range map[string]string{"name": "Ayşe", "medications": "Warfarin", "allergies": "Penicillin", "symptoms": "chest pain"} {
+		raw := rawColumn(t, db, "patient_data", column, patient.ID)
+		if privacy.SealedWith(raw) != keys.CurrentID() || strings.Contains(raw, plain) {
+			t.Errorf("Expected %s sealed at rest, got %q", column, raw)
+		}
+	}
+	if raw := rawColumn(t, db, "patient_data", "gender", patient.ID); raw != "Female" {
+		t.Errorf("Expected queried fields in plaintext, got gender %q", raw)
+	}
+	var count int64
+	db.Model(&models.PatientData{}).Where("systolic_bp > 140 AND gender = ?", "Female").Count(&count)
+	if count != 1 {
+		t.Error("Expected plaintext fields to stay queryable")
+	}
+
+	var loaded models.PatientData
+	db.First(&loaded, patient.ID)
+	if loaded.Name != patient.Name || loaded.Medications != patient.Medications || loaded.Allergies != patient.Allergies || loaded.Symptoms != patient.Symptoms {
+		t.Errorf("Expected the fields decrypted on read, got %+v", loaded)
+	}
+	pred := services.NewPredictionService("http://unused")
+	if pred.HashVitals(loaded) != pred.HashVitals(patient) {
+		t.Error("Expected the prediction cache key unchanged by a round trip")
+	}
+
+	// The frozen copy on the assessment is sealed as well
+	var assessment models.Assessment
+	assessment.PatientID = patient.ID
+	assessment.SetPatientSnapshot(patient)
+	db.Create(&assessment)
+	if raw := rawColumn(t, db, "assessments", "patient_snapshot", assessment.ID); strings.Contains(raw, "Warfarin") {
+		t.Errorf("Expected the snapshot sealed, got %q", raw)
+	}
+	var stored models.Assessment
+	db.First(&stored, assessment.ID)
+	if snap, err := stored.Patient(); err != nil || snap.Medications != patient.Medications || models.SnapshotHash(stored.PatientSnapshot) != assessment.SnapshotHash {
+		t.Errorf("Expected the snapshot and its hash intact, got %+v (%v)", snap, err)
+	}
+}
+
+func TestFieldEncryption_SealsDiagnosisPromptsAndResults(t *testing.T) {
+	keys := useFieldKeys(t, 1)
+	db := openSearchDB(t)
+
+	record := models.DiagnosisContext{PatientID: 1, AssessmentID: 5, Generation: 2,
+		Prompt:  "Medications: Warfarin. Allergies: Penicillin. Symptoms: chest pain",
+		Request: `{"medications":"Warfarin","allergies":"Penicillin"}`}
+	if err := db.Create(&record).Error; err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := services.NewDiagnosisStore(db).Save(1, 2, "Suspected pulmonary embolism", "ready"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	for column, plain := range map[string]string{"prompt": "Warfarin", "request": "Penicillin", "diagnosis": "embolism"} {
+		raw := rawColumn(t, db, "diagnosis_contexts", column, record.ID)
+		if privacy.SealedWith(raw) != keys.CurrentID() || strings.Contains(raw, plain) {
+			t.Errorf("Expected %s sealed at rest, got %q", column, raw)
+		}
+	}
+	var stored models.DiagnosisContext
+	db.First(&stored, record.ID)
+	if stored.Prompt != record.Prompt || stored.Request != record.Request || stored.Diagnosis != "Suspected pulmonary embolism" {
+		t.Errorf("Expected the columns opened on read, got %+v", stored)
+	}
+	if hits, err := services.NewSearchService(db).Search(services.SearchQuery{Text: "embolism"}); err != nil || len(hits) != 1 {
+		t.Errorf("Expected the sealed diagnosis still searchable, got %+v (%v)", hits, err)
+	}
+}
+
+func TestFieldEncryption_ReadsLegacyPlaintextAndBindsColumns(t *testing.T) {
+	db := openEncryptionDB(t)
+	legacy := models.PatientData{Age: 40, Gender: "Male", Medications: "Metformin"}
+	db.Create(&legacy) // Before encryption was enabled
+
+	keys := useFieldKeys(t, 1)
+	var loaded models.PatientData
+	if err := db.First(&loaded, legacy.ID).Error; err != nil || loaded.Medications != "Metformin" {
+		t.Errorf("Expected plaintext rows readable, got %q (%v)", loaded.Medications, err)
+	}
+
+	sealed, _ := keys.Seal("medications", "Metformin")
+	if _, err := keys.Open("symptoms", sealed); err == nil {
+		t.Error("Expected a value moved to another column to fail to open")
+	}
+	if empty, _ := keys.Seal("name", ""); empty != "" {
+		t.Errorf("Expected empty values left empty, got %q", empty)
+	}
+
+	db.Table("patient_data").Where("id = ?", legacy.ID).Update("medications", sealed)
+	privacy.UseFieldKeys(nil)
+	if err := db.First(&loaded, legacy.ID).Error; !errors.Is(err, privacy.ErrUnknownFieldKey) {
+		t.Errorf("Expected a sealed value to need its key, got %v", err)
+	}
+}
+
+func TestPatientRepository_KnownNamesWhenEncrypted(t *testing.T) {
+	useFieldKeys(t, 1)
+	db := openEncryptionDB(t)
+	for _, name := range []string{"Ayşe Yılmaz", "Ayşe Yılmaz", "", "Mehmet Demir"} {
+		db.Create(&models.PatientData{Name: name, Age: 30, Gender: "Other"})
+	}
+
+	names, err := repositories.NewPatientRepository(db).KnownNames()
+	if err != nil || len(names) != 2 || names[0] != "Ayşe Yılmaz" || names[1] != "Mehmet Demir" {
+		t.Errorf("Expected each name once, decrypted, got %q (%v)", names, err)
+	}
+}
@@ -0,0 +1,150 @@
+package unit
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"healthcare-backend/pkg/flags"
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupFlagsApp(t *testing.T) (*fiber.App, *flags.Store, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	db.AutoMigrate(&models.ConfigOverride{}, &models.AuditLog{})
+
+	store := flags.NewStore(db, flags.Flag{Name: "fhir_api", State: flags.Off})
+	h := handlers.NewFlagsHandler(store, services.NewAuditService(db))
+	app := fiber.New()
+	app.Use(flags.Middleware(store))
+	app.Get("/api/admin/flags", h.GetFlags)
+	app.Put("/api/admin/flags/:name", h.SetFlag)
+	app.Get("/api/fhir/ping", flags.Require("fhir_api"), func(c *fiber.Ctx) error { return c.SendString("pong") })
+	return app, store, db
+}
+
+func flagRequest(t *testing.T, app *fiber.App, method, path, body, clinic string) int {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if clinic != "" {
+		req.Header.Set(flags.ClinicHeader, clinic)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	return resp.StatusCode
+}
+
+func TestFlags_BucketingIsStable(t *testing.T) {
+	f := flags.Flag{Name: "fhir_api", State: flags.Percentage}
+
+	enabledAt := map[string]int{} // Lowest percentage at which each subject is in
+	for pct := 0; pct <= 100; pct++ {
+		f.Percent = pct
+		for i := 0; i < 500; i++ {
+			subject := fmt.Sprintf("clinic-%d", i)
+			on := f.Enabled("", subject)
+			if on != f.Enabled("", subject) {
+				t.Fatalf("%s flipped between two evaluations", subject)
+			}
+			first, seen := enabledAt[subject]
+			if on && !seen {
+				enabledAt[subject] = pct
+			}
+			if seen && pct >= first && !on {
+				t.Fatalf("%s was in at %d%% but out at %d%%", subject, first, pct)
+			}
+		}
+	}
+	if len(enabledAt) != 500 {
+		t.Errorf("Expected every subject enabled at 100%%, got %d", len(enabledAt))
+	}
+
+	// Roughly the requested share, and a different flag buckets differently
+	f.Percent = 30
+	other := flags.Flag{Name: "sse_stream", State: flags.Percentage, Percent: 30}
+	in, differ := 0, 0
+	for i := 0; i < 2000; i++ {
+		subject := fmt.Sprintf("actor-%d", i)
+		if f.Enabled("", subject) {
+			in++
+		}
+		if f.Enabled("", subject) != other.Enabled("", subject) {
+			differ++
+		}
+	}
+	if in < 500 || in > 700 {
+		t.Errorf("Expected ~600 of 2000 enabled at 30%%, got %d", in)
+	}
+	if differ == 0 {
+		t.Error("Expected different flags to bucket subjects independently")
+	}
+}
+
+func TestFlags_ClinicAllowListOverridesState(t *testing.T) {
+	f := flags.Flag{Name: "fhir_api", State: flags.Off, Clinics: []string{"north"}}
+	if !f.Enabled("north", "north") || f.Enabled("south", "south") {
+		t.Error("Only the listed clinic should get an off flag")
+	}
+}
+
+func TestFlags_LiveFlipAndAudit(t *testing.T) {
+	app, store, db := setupFlagsApp(t)
+
+	if code := flagRequest(t, app, "GET", "/api/fhir/ping", "", ""); code != 404 {
+		t.Fatalf("Expected flagged-off route to 404, got %d", code)
+	}
+
+	if code := flagRequest(t, app, "PUT", "/api/admin/flags/fhir_api", `{"state":"on"}`, ""); code != 200 {
+		t.Fatalf("Expected flag update to succeed, got %d", code)
+	}
+	if code := flagRequest(t, app, "GET", "/api/fhir/ping", "", ""); code != 200 {
+		t.Fatalf("Expected route on after flip, got %d", code)
+	}
+
+	// Enable for one clinic only
+	flagRequest(t, app, "PUT", "/api/admin/flags/fhir_api", `{"state":"off","clinics":["north"]}`, "")
+	if code := flagRequest(t, app, "GET", "/api/fhir/ping", "", "north"); code != 200 {
+		t.Errorf("Expected north clinic enabled, got %d", code)
+	}
+	if code := flagRequest(t, app, "GET", "/api/fhir/ping", "", "south"); code != 404 {
+		t.Errorf("Expected south clinic disabled, got %d", code)
+	}
+
+	// Another replica's store sees the change after Refresh
+	replica := flags.NewStore(db, flags.Flag{Name: "fhir_api", State: flags.Off})
+	if err := replica.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if f, _ := replica.Get("fhir_api"); len(f.Clinics) != 1 || f.Clinics[0] != "north" {
+		t.Errorf("Expected replica to load the override, got %+v", f)
+	}
+
+	if code := flagRequest(t, app, "PUT", "/api/admin/flags/fhir_api", `{"state":"percentage","percent":150}`, ""); code != 400 {
+		t.Errorf("Expected invalid percentage to be rejected, got %d", code)
+	}
+	if code := flagRequest(t, app, "PUT", "/api/admin/flags/nope", `{"state":"on"}`, ""); code != 404 {
+		t.Errorf("Expected unknown flag to 404, got %d", code)
+	}
+	if f, _ := store.Get("fhir_api"); f.State != flags.Off {
+		t.Errorf("Rejected updates must not change the flag, got %+v", f)
+	}
+
+	var changes int64
+	db.Model(&models.AuditLog{}).Where("event_type = ?", "FEATURE_FLAG_CHANGED").Count(&changes)
+	if changes != 2 {
+		t.Errorf("Expected 2 audited flag changes, got %d", changes)
+	}
+}
@@ -0,0 +1,260 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestParseLocalizedNumber(t *testing.T) {
+	cases := []struct {
+		in   string
+		want float64
+		err  error
+	}{
+		// Plain and decimal forms
+		{"24", 24, nil},
+		{"24.5", 24.5, nil},
+		{"24,5", 24.5, nil},
+		{"  24,5  ", 24.5, nil},
+		{"0,123", 0.123, nil},
+		{"0.123", 0.123, nil},
+		{"-3,25", -3.25, nil},
+		{"+7.5", 7.5, nil},
+		{"1234,567", 1234.567, nil},
+		{"24,50", 24.5, nil},
+		{"", 0, nil},
+
+		// Thousands separators
+		{"1.234,5", 1234.5, nil},
+		{"1,234.5", 1234.5, nil},
+		{"1.234.567", 1234567, nil},
+		{"1,234,567", 1234567, nil},
+		{"1.234.567,89", 1234567.89, nil},
+		{"1,234,567.89", 1234567.89, nil},
+		{"12 345", 12345, nil},
+		{"12 345,5", 12345.5, nil},
+		{"12\u00a0345,5", 12345.5, nil},
+		{"12\u202f345.5", 12345.5, nil},
+		{"1'234.5", 1234.5, nil},
+
+		// Ambiguous: one separator, exactly three digits after it
+		{"1.234", 0, models.ErrAmbiguousNumber},
+		{"1,234", 0, models.ErrAmbiguousNumber},
+		{"24,500", 0, models.ErrAmbiguousNumber},
+		{"120.000", 0, models.ErrAmbiguousNumber},
+
+		// Misplaced grouping
+		{"1.23.456", 0, models.ErrBadGrouping},
+		{"12,34,567", 0, models.ErrBadGrouping},
+		{"1234.567.890", 0, models.ErrBadGrouping},
+		{"1.234.5", 0, models.ErrBadGrouping},
+		{"1,5.000", 0, models.ErrBadGrouping},
+		{"1.234,5,6", 0, models.ErrBadGrouping},
+		{"1 234.567.890", 0, models.ErrBadGrouping},
+		{"1,23 4", 0, models.ErrBadGrouping},
+
+		// Not numbers at all
+		{"abc", 0, models.ErrNotANumber},
+		{"24.5kg", 0, models.ErrNotANumber},
+		{",5", 0, models.ErrNotANumber},
+		{"5,", 0, models.ErrNotANumber},
+		{"-", 0, models.ErrNotANumber},
+		{"+-5", 0, models.ErrNotANumber},
+		{"1e3", 0, models.ErrNotANumber},
+		{"NaN", 0, models.ErrNotANumber},
+		{"Inf", 0, models.ErrNotANumber},
+		{"0x1F", 0, models.ErrNotANumber},
+	}
+	for _, tc := range cases {
+		got, err := models.ParseLocalizedNumber(tc.in)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("ParseLocalizedNumber(%q) error = %v, want %v", tc.in, err, tc.err)
+			continue
+		}
+		if err == nil && got != tc.want {
+			t.Errorf("ParseLocalizedNumber(%q) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestFlexibleFloatUnmarshal(t *testing.T) {
+	cases := []struct {
+		in   string
+		want float64
+		err  error
+	}{
+		{`24.5`, 24.5, nil},
+		{`24`, 24, nil},
+		{`2.45e1`, 24.5, nil},
+		{`"24,5"`, 24.5, nil},
+		{`"24.5"`, 24.5, nil},
+		{`""`, 0, nil},
+		{`null`, 0, nil},
+		{`"1.234"`, 0, models.ErrAmbiguousNumber},
+		{`true`, 0, models.ErrNotANumber},
+		{`[24]`, 0, models.ErrNotANumber},
+		{`{"v":24}`, 0, models.ErrNotANumber},
+		{`"24,5 kg"`, 0, models.ErrNotANumber},
+	}
+	for _, tc := range cases {
+		var f models.FlexibleFloat
+		err := f.UnmarshalJSON([]byte(tc.in))
+		if !errors.Is(err, tc.err) {
+			t.Errorf("FlexibleFloat(%s) error = %v, want %v", tc.in, err, tc.err)
+			continue
+		}
+		if err == nil && float64(f) != tc.want {
+			t.Errorf("FlexibleFloat(%s) = %v, want %v", tc.in, f, tc.want)
+		}
+	}
+}
+
+func TestFlexibleIntUnmarshal(t *testing.T) {
+	cases := []struct {
+		in   string
+		want int
+		err  error
+	}{
+		{`120`, 120, nil},
+		{`120.0`, 120, nil},
+		{`"120"`, 120, nil},
+		{`"8.500,0"`, 8500, nil},
+		{`"8,500.0"`, 8500, nil},
+		{`"12.345.678"`, 12345678, nil},
+		{`"-5"`, -5, nil},
+		{`null`, 0, nil},
+		{`24.5`, 0, models.ErrNotWholeNumber},
+		{`"24,5"`, 0, models.ErrNotWholeNumber},
+		{`"8.500"`, 0, models.ErrAmbiguousNumber},
+		{`1e12`, 0, models.ErrNumberOutOfRange},
+		{`"abc"`, 0, models.ErrNotANumber},
+	}
+	for _, tc := range cases {
+		var n models.FlexibleInt
+		err := n.UnmarshalJSON([]byte(tc.in))
+		if !errors.Is(err, tc.err) {
+			t.Errorf("FlexibleInt(%s) error = %v, want %v", tc.in, err, tc.err)
+			continue
+		}
+		if err == nil && int(n) != tc.want {
+			t.Errorf("FlexibleInt(%s) = %v, want %v", tc.in, n, tc.want)
+		}
+	}
+}
+
+func TestPatientIntakeDecodesLocalizedNumbers(t *testing.T) {
+	body := `{"age":"54","gender":"Female","systolic_bp":"135","diastolic_bp":85,"glucose":"110,0",
+		"bmi":"24,5","cholesterol":"210","heart_rate":72,"steps":"8.500,0","smoking":"No","symptoms":"cough"}`
+
+	var intake models.PatientIntake
+	if err := json.Unmarshal([]byte(body), &intake); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	p := intake.NewPatient()
+	want := models.PatientData{Age: 54, Gender: "Female", SystolicBP: 135, DiastolicBP: 85, Glucose: 110,
+		BMI: 24.5, Cholesterol: 210, HeartRate: 72, Steps: 8500, Smoking: "No", Symptoms: "cough",
+		Alcohol: "No", HistoryHeartDisease: "No", HistoryStroke: "No", HistoryDiabetes: "No", HistoryHighChol: "No"}
+	if p != want {
+		t.Errorf("Decoded %+v, want %+v", p, want)
+	}
+}
+
+func TestPatientIntakeReportsEveryBadField(t *testing.T) {
+	body := `{"age":"54,5","gender":"Male","bmi":"1.234","glucose":"lots","systolic_bp":120}`
+
+	var intake models.PatientIntake
+	err := json.Unmarshal([]byte(body), &intake)
+	var fieldErrs models.NumberFieldErrors
+	if !errors.As(err, &fieldErrs) {
+		t.Fatalf("Expected NumberFieldErrors, got %v", err)
+	}
+	var fields []string
+	for _, fe := range fieldErrs {
+		fields = append(fields, fe.Field)
+	}
+	if got := strings.Join(fields, ","); got != "age,bmi,glucose" {
+		t.Errorf("Expected errors for age,bmi,glucose, got %s", got)
+	}
+	if !strings.Contains(fieldErrs[1].Message, `"1.234"`) {
+		t.Errorf("Message should echo the rejected value: %q", fieldErrs[1].Message)
+	}
+}
+
+func TestAssessPatientAcceptsDecimalComma(t *testing.T) {
+	var hits atomic.Int64
+	ml := newFakeFullML(t, &hits)
+	h, db, _ := newTestPatientHandler(t, ml.URL, handlers.NewWebSocketHandler())
+
+	app := fiber.New()
+	app.Post("/api/assess", h.AssessPatient)
+
+	body := `{"age":61,"gender":"Male","systolic_bp":"140","diastolic_bp":"90","glucose":"105","bmi":"27,3",
+		"cholesterol":200,"heart_rate":80,"smoking":"No","alcohol":"No","history_heart_disease":"No",
+		"history_stroke":"No","history_diabetes":"No","history_high_chol":"No"}`
+	req := httptest.NewRequest("POST", "/api/assess", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, 5000)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+
+	var stored models.PatientData
+	if err := db.First(&stored).Error; err != nil {
+		t.Fatalf("Patient not stored: %v", err)
+	}
+	if stored.BMI != 27.3 || stored.SystolicBP != 140 {
+		t.Errorf("Stored BMI %v, systolic %d; want 27.3, 140", stored.BMI, stored.SystolicBP)
+	}
+}
+
+func TestUpdatePatientRejectsAmbiguousNumber(t *testing.T) {
+	h, db, _ := newTestPatientHandler(t, "http://127.0.0.1:1", handlers.NewWebSocketHandler())
+	patient := models.PatientData{Age: 40, Gender: "Male", SystolicBP: 120, DiastolicBP: 80, Glucose: 100, BMI: 22,
+		Cholesterol: 180, HeartRate: 70, Smoking: "No", Alcohol: "No",
+		HistoryHeartDisease: "No", HistoryStroke: "No", HistoryDiabetes: "No", HistoryHighChol: "No"}
+	db.Create(&patient)
+
+	app := fiber.New()
+	app.Put("/api/patients/:id", h.UpdatePatient)
+
+	body, _ := json.Marshal(map[string]any{"age": 40, "gender": "Male", "systolic_bp": 120, "diastolic_bp": 80,
+		"glucose": 100, "bmi": "22,5", "cholesterol": 180, "heart_rate": 70, "steps": "1.500",
+		"smoking": "No", "alcohol": "No", "history_heart_disease": "No", "history_stroke": "No",
+		"history_diabetes": "No", "history_high_chol": "No"})
+	req := httptest.NewRequest("PUT", "/api/patients/1", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.StatusCode != 400 {
+		t.Fatalf("Expected 400, got %d", resp.StatusCode)
+	}
+
+	var out struct {
+		Errors []models.NumberFieldError `json:"errors"`
+	}
+	json.NewDecoder(resp.Body).Decode(&out)
+	if len(out.Errors) != 1 || out.Errors[0].Field != "steps" {
+		t.Errorf("Expected a single steps error, got %+v", out.Errors)
+	}
+
+	var stored models.PatientData
+	db.First(&stored, patient.ID)
+	if stored.BMI != 22 || stored.Steps != 0 {
+		t.Errorf("Rejected update must not be stored: %+v", stored)
+	}
+}
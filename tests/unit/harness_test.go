@@ -0,0 +1,55 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/privacy"
+	"healthcare-backend/pkg/repositories"
+	"healthcare-backend/pkg/services"
+	"healthcare-backend/pkg/terminology"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newFakeFullML answers every ML endpoint the assessment flow calls
+func newFakeFullML(t *testing.T, predictHits *atomic.Int64) *httptest.Server {
+	ml := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/predict":
+			predictHits.Add(1)
+			json.NewEncoder(w).Encode(models.PredictResponse{HeartRisk: 40, ModelPrecisions: map[string]float64{"Heart_Model": 0.9}})
+		case "/urgency/predict":
+			json.NewEncoder(w).Encode(models.UrgencyResponse{UrgencyLevel: 2})
+		default:
+			json.NewEncoder(w).Encode(models.DiagnosisResponse{Diagnosis: "ok", Status: "ready"})
+		}
+	}))
+	t.Cleanup(ml.Close)
+	return ml
+}
+
+// newTestPatientHandler wires a PatientHandler against an in-memory DB and the given ML URL
+func newTestPatientHandler(t *testing.T, mlURL string, ws *handlers.WebSocketHandler) (*handlers.PatientHandler, *gorm.DB, *services.PredictionService) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	db.AutoMigrate(&models.PatientData{}, &models.Feedback{}, &models.DiagnosisContext{}, &models.Assessment{}, &models.AssessmentPrecision{}, &models.AssessmentComponent{}, &models.AuditLog{})
+
+	patients := repositories.NewPatientRepository(db)
+	pred := services.NewPredictionService(mlURL)
+	redactor, _ := privacy.NewRedactor(privacy.ModeRedact, nil)
+	terms, _ := terminology.NewMapper()
+	h := handlers.NewPatientHandler(db, patients, repositories.NewAssessmentRepository(db),
+		services.NewRAGService(patients, repositories.NewFeedbackRepository(db)),
+		pred, ws, services.NewAuditService(db), redactor, terms)
+	return h, db, pred
+}
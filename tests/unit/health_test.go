@@ -0,0 +1,157 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type readiness struct {
+	Status       string            `json:"status"`
+	Dependencies map[string]string `json:"dependencies"`
+	LatencyMs    map[string]int64  `json:"latency_ms"`
+}
+
+// check is a dependency check that's up or down
+func check(up bool) func(context.Context) error {
+	return func(context.Context) error {
+		if up {
+			return nil
+		}
+		return errors.New("down")
+	}
+}
+
+func probeReady(t *testing.T, h *handlers.HealthHandler) (int, readiness) {
+	t.Helper()
+	app := fiber.New()
+	app.Get("/health/ready", h.Ready)
+	resp, err := app.Test(httptest.NewRequest("GET", "/health/ready", nil), 5000)
+	if err != nil {
+		t.Fatalf("Readiness request failed: %v", err)
+	}
+	var body readiness
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("Decoding readiness failed: %v", err)
+	}
+	return resp.StatusCode, body
+}
+
+func TestHealthReady_OptionalDependenciesOnlyDegrade(t *testing.T) {
+	for _, requireRedis := range []bool{false, true} {
+		for _, requireNATS := range []bool{false, true} {
+			for _, redisUp := range []bool{false, true} {
+				for _, natsUp := range []bool{false, true} {
+					name := fmt.Sprintf("require redis=%v nats=%v, up redis=%v nats=%v", requireRedis, requireNATS, redisUp, natsUp)
+					t.Run(name, func(t *testing.T) {
+						h := handlers.NewHealthHandler(func() bool { return false },
+							handlers.Dependency{Name: "db", Required: true, Check: check(true)},
+							handlers.Dependency{Name: "redis", Required: requireRedis, Check: check(redisUp)},
+							handlers.Dependency{Name: "nats", Required: requireNATS, Check: check(natsUp)},
+						)
+						code, body := probeReady(t, h)
+
+						wantCode, wantStatus := 200, "ready"
+						if !redisUp || !natsUp {
+							wantStatus = "degraded"
+						}
+						if (requireRedis && !redisUp) || (requireNATS && !natsUp) {
+							wantCode, wantStatus = 503, "not ready"
+						}
+						if code != wantCode || body.Status != wantStatus {
+							t.Errorf("Expected %d %q, got %d %q", wantCode, wantStatus, code, body.Status)
+						}
+
+						for dep, up := range map[string]bool{"redis": redisUp, "nats": natsUp} {
+							required := map[string]bool{"redis": requireRedis, "nats": requireNATS}[dep]
+							want := handlers.DependencyHealthy
+							if !up && required {
+								want = handlers.DependencyUnhealthy
+							} else if !up {
+								want = handlers.DependencyDegraded
+							}
+							if body.Dependencies[dep] != want {
+								t.Errorf("Expected %s %q, got %q", dep, want, body.Dependencies[dep])
+							}
+						}
+					})
+				}
+			}
+		}
+	}
+}
+
+func TestHealthReady_DatabaseAndShutdownFailReadiness(t *testing.T) {
+	h := handlers.NewHealthHandler(func() bool { return false },
+		handlers.Dependency{Name: "db", Required: true, Check: check(false)},
+	)
+	if code, body := probeReady(t, h); code != 503 || body.Dependencies["db"] != handlers.DependencyUnhealthy {
+		t.Errorf("Expected a down database to fail readiness, got %d %+v", code, body)
+	}
+
+	h = handlers.NewHealthHandler(func() bool { return true },
+		handlers.Dependency{Name: "db", Required: true, Check: check(true)},
+	)
+	if code, body := probeReady(t, h); code != 503 || body.Status != "not ready" {
+		t.Errorf("Expected shutdown to fail readiness, got %d %+v", code, body)
+	}
+}
+
+func TestHealthReady_ReportsLatencyAndBoundsChecks(t *testing.T) {
+	hung := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	slow := func(context.Context) error {
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	}
+	h := handlers.NewHealthHandler(func() bool { return false },
+		handlers.Dependency{Name: "db", Required: true, Check: slow},
+		handlers.Dependency{Name: "ml", Check: hung},
+	)
+	h.CheckTimeout = 100 * time.Millisecond
+
+	var code int
+	var body readiness
+	within(t, time.Second, "the probe", func() { code, body = probeReady(t, h) })
+	if code != 200 || body.Status != "degraded" || body.Dependencies["ml"] != handlers.DependencyDegraded {
+		t.Errorf("Expected a hung ML service to only degrade readiness, got %d %+v", code, body)
+	}
+	if body.LatencyMs["db"] < 20 || body.LatencyMs["ml"] < 100 {
+		t.Errorf("Expected each dependency's latency, got %v", body.LatencyMs)
+	}
+}
+
+func TestMLBackend_Ping(t *testing.T) {
+	var down atomic.Bool
+	ml := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" || down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ml.Close()
+
+	backend := services.NewMLBackend(services.BackendPrimary, ml.URL)
+	if err := backend.Ping(context.Background()); err != nil {
+		t.Errorf("Expected a reachable ML service, got %v", err)
+	}
+	down.Store(true)
+	if err := backend.Ping(context.Background()); err == nil {
+		t.Error("Expected a failing /health to be reported")
+	}
+	if counts := backend.CB.Counts(); counts.Requests != 0 {
+		t.Errorf("Expected pings to bypass the breaker, got %+v", counts)
+	}
+}
@@ -0,0 +1,255 @@
+package unit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/cli"
+	"healthcare-backend/pkg/database"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/privacy"
+	"healthcare-backend/pkg/services"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newHealthctlDB creates a migrated SQLite file and returns its path
+func newHealthctlDB(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "clinical.db")
+	if code, out, errOut := healthctl(t, path, "run-migrations"); code != 0 {
+		t.Fatalf("run-migrations exited %d: %s %s", code, out, errOut)
+	}
+	return path
+}
+
+func openHealthctlDB(t *testing.T, path string) *gorm.DB {
+	db, err := database.Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db.Session(&gorm.Session{Logger: logger.Default.LogMode(logger.Silent)})
+}
+
+func healthctl(t *testing.T, path string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := cli.Run(append([]string{"--db", path, "--json"}, args...), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func decodeHealthctl(t *testing.T, out string) map[string]any {
+	var v map[string]any
+	if err := json.Unmarshal([]byte(out), &v); err != nil {
+		t.Fatalf("Output is not JSON: %q", out)
+	}
+	return v
+}
+
+func TestHealthctl_RunMigrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clinical.db")
+	code, out, errOut := healthctl(t, path, "run-migrations")
+	if code != 0 {
+		t.Fatalf("Exited %d: %s", code, errOut)
+	}
+	res := decodeHealthctl(t, out)
+	supported := database.EmbeddedMigrations()
+	if int(res["current_version"].(float64)) != supported[len(supported)-1].Version {
+		t.Errorf("Unexpected result: %v", res)
+	}
+
+	_, out, _ = healthctl(t, path, "run-migrations")
+	if applied := decodeHealthctl(t, out)["applied"].([]any); len(applied) != 0 {
+		t.Errorf("Expected second run to apply nothing, got %v", applied)
+	}
+}
+
+func TestHealthctl_VerifyAndExportAudit(t *testing.T) {
+	path := newHealthctlDB(t)
+	db := openHealthctlDB(t, path)
+	audit := services.NewAuditService(db)
+	for i := uint(1); i <= 3; i++ {
+		audit.LogEvent("PATIENT_CREATED", i, map[string]uint{"id": i}, auditctx.System)
+	}
+
+	code, out, _ := healthctl(t, path, "verify-audit-chain")
+	res := decodeHealthctl(t, out)
+	if code != 0 || res["valid"] != true || res["entries"].(float64) != 3 {
+		t.Fatalf("Expected intact chain of 3, got %d %v", code, res)
+	}
+
+	code, out, _ = healthctl(t, path, "export-audit")
+	if code != 0 {
+		t.Fatalf("export-audit exited %d", code)
+	}
+	var lines []models.AuditLog
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		var entry models.AuditLog
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Line is not an audit entry: %q", scanner.Text())
+		}
+		lines = append(lines, entry)
+	}
+	if len(lines) != 3 || lines[0].PrevHash != "GENESIS" || lines[2].PrevHash != lines[1].CurrentHash {
+		t.Fatalf("Unexpected export: %+v", lines)
+	}
+
+	file := filepath.Join(t.TempDir(), "audit.ndjson")
+	code, out, _ = healthctl(t, path, "export-audit", "--out", file)
+	if code != 0 || decodeHealthctl(t, out)["entries"].(float64) != 3 {
+		t.Fatalf("Unexpected export summary: %d %s", code, out)
+	}
+	if data, _ := os.ReadFile(file); strings.Count(string(data), "\n") != 3 {
+		t.Errorf("Expected 3 lines in %s, got %q", file, data)
+	}
+
+	// Tamper with an entry
+	db.Model(&models.AuditLog{}).Where("id = ?", 2).Update("event_type", "PATIENT_DELETED")
+	code, out, _ = healthctl(t, path, "verify-audit-chain")
+	if code != 1 || decodeHealthctl(t, out)["valid"] != false {
+		t.Errorf("Expected broken chain to exit 1, got %d %s", code, out)
+	}
+}
+
+func TestHealthctl_RebuildRAGIndex(t *testing.T) {
+	path := newHealthctlDB(t)
+	db := openHealthctlDB(t, path)
+	patient := models.PatientData{Age: 50, Gender: "Male"}
+	db.Create(&patient)
+	db.Create(&models.Feedback{PatientID: patient.ID, DoctorApproved: true, DoctorNotes: "stable"})
+	db.Create(&models.Feedback{PatientID: 999, DoctorApproved: true, DoctorNotes: "gone"})
+	db.Create(&models.Feedback{PatientID: patient.ID, DoctorApproved: false})
+
+	code, out, _ := healthctl(t, path, "rebuild-rag-index")
+	res := decodeHealthctl(t, out)
+	if code != 0 || res["approved"].(float64) != 2 || res["indexed"].(float64) != 1 || res["orphaned"].(float64) != 1 {
+		t.Errorf("Unexpected stats: %d %v", code, res)
+	}
+}
+
+func TestHealthctl_CreateAdminAndRotateKey(t *testing.T) {
+	path := newHealthctlDB(t)
+	db := openHealthctlDB(t, path)
+	creds := services.NewCredentialService(db)
+
+	code, out, errOut := healthctl(t, path, "create-admin-user", "--name", "ops-alice")
+	if code != 0 {
+		t.Fatalf("Exited %d: %s", code, errOut)
+	}
+	first := decodeHealthctl(t, out)["api_key"].(string)
+	if id, ok := creds.Lookup(first); !ok || id.ID != "apikey:ops-alice" || id.Role != auditctx.RoleAdmin {
+		t.Fatalf("Expected key to resolve to admin ops-alice, got %+v %v", id, ok)
+	}
+
+	if code, _, errOut := healthctl(t, path, "create-admin-user", "--name", "ops-alice"); code != 1 || !strings.Contains(errOut, "already exists") {
+		t.Errorf("Expected duplicate to fail, got %d %s", code, errOut)
+	}
+
+	code, out, _ = healthctl(t, path, "rotate-api-key", "--id", "ops-alice")
+	if code != 0 {
+		t.Fatalf("rotate-api-key exited %d", code)
+	}
+	second := decodeHealthctl(t, out)["api_key"].(string)
+	if _, ok := creds.Lookup(first); ok {
+		t.Error("Old key should stop working after rotation")
+	}
+	if _, ok := creds.Lookup(second); !ok {
+		t.Error("New key should resolve")
+	}
+
+	if code, _, _ := healthctl(t, path, "rotate-api-key", "--id", "nobody"); code != 1 {
+		t.Errorf("Expected unknown credential to fail, got %d", code)
+	}
+
+	var events []string
+	db.Model(&models.AuditLog{}).Where("actor_id = ?", cli.Operator.ID).Order("id").Pluck("event_type", &events)
+	if len(events) != 2 || events[0] != "API_KEY_CREATED" || events[1] != "API_KEY_ROTATED" {
+		t.Errorf("Expected create and rotate audited, got %v", events)
+	}
+}
+
+func TestHealthctl_RefusesWhileMigrationLocked(t *testing.T) {
+	path := newHealthctlDB(t)
+	release, err := database.AcquireMigrationLock(openHealthctlDB(t, path))
+	if err != nil {
+		t.Fatalf("AcquireMigrationLock failed: %v", err)
+	}
+
+	for name := range cli.Commands {
+		code, out, errOut := healthctl(t, path, name)
+		if code != 1 || out != "" || !strings.Contains(errOut, "migration lock") {
+			t.Errorf("%s: expected refusal, got %d %q %q", name, code, out, errOut)
+		}
+	}
+
+	release()
+	if code, _, errOut := healthctl(t, path, "verify-audit-chain"); code != 0 {
+		t.Errorf("Expected commands to run once released, got %d %s", code, errOut)
+	}
+}
+
+func TestHealthctl_ReencryptFields(t *testing.T) {
+	path := newHealthctlDB(t)
+	db := openHealthctlDB(t, path)
+	legacy := models.PatientData{Age: 40, Gender: "Male", Medications: "Metformin"}
+	db.Create(&legacy) // Written before encryption was enabled
+	old := useFieldKeys(t, 1)
+	rotated := models.PatientData{Age: 60, Gender: "Female", Name: "Ayşe Yılmaz", Symptoms: "fatigue"}
+	db.Create(&rotated)
+	assessment := models.Assessment{PatientID: rotated.ID}
+	assessment.SetPatientSnapshot(rotated)
+	db.Create(&assessment)
+
+	if code, _, errOut := healthctl(t, path, "reencrypt-fields"); code != 1 || !strings.Contains(errOut, "FIELD_ENCRYPTION_KEY") {
+		t.Errorf("Expected a missing key refused, got %d %s", code, errOut)
+	}
+
+	t.Setenv("FIELD_ENCRYPTION_KEY", fieldKey(2))
+	t.Setenv("FIELD_ENCRYPTION_PREVIOUS_KEYS", fieldKey(1))
+	code, out, errOut := healthctl(t, path, "reencrypt-fields")
+	if code != 0 {
+		t.Fatalf("Exited %d: %s", code, errOut)
+	}
+	res := decodeHealthctl(t, out)
+	if res["rewritten"].(float64) != 3 || res["fields"].(float64) != 4 || res["key_id"] == old.CurrentID() {
+		t.Errorf("Expected two patients and the snapshot resealed, got %v", res)
+	}
+	for _, raw := range []string{
+		rawColumn(t, db, "patient_data", "medications", legacy.ID),
+		rawColumn(t, db, "patient_data", "name", rotated.ID),
+		rawColumn(t, db, "patient_data", "symptoms", rotated.ID),
+		rawColumn(t, db, "assessments", "patient_snapshot", assessment.ID),
+	} {
+		if privacy.SealedWith(raw) != res["key_id"] {
+			t.Errorf("Expected every value under the new key, got %q", raw)
+		}
+	}
+
+	// The old key can go: everything opens with the new one alone
+	useFieldKeys(t, 2)
+	var loaded []models.PatientData
+	if err := db.Order("id").Find(&loaded).Error; err != nil || loaded[0].Medications != "Metformin" || loaded[1].Name != "Ayşe Yılmaz" {
+		t.Errorf("Expected the rows readable under the new key, got %+v (%v)", loaded, err)
+	}
+
+	_, out, _ = healthctl(t, path, "reencrypt-fields")
+	if res := decodeHealthctl(t, out); res["rewritten"].(float64) != 0 {
+		t.Errorf("Expected a second pass to change nothing, got %v", res)
+	}
+	var events []string
+	db.Model(&models.AuditLog{}).Where("actor_id = ?", cli.Operator.ID).Pluck("event_type", &events)
+	if len(events) != 2 || events[0] != "FIELD_KEY_ROTATED" {
+		t.Errorf("Expected each pass audited, got %v", events)
+	}
+}
@@ -0,0 +1,231 @@
+package unit
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/adapters"
+	"healthcare-backend/pkg/adapters/hl7"
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// hl7ADT is an admission from the hospital EHR with the vitals taken on the ward
+var hl7ADT = strings.Join([]string{
+	`MSH|^~\&|EPIC|CITYGEN|HEALTHCARE|CARDIO|20240315083000||ADT^A01^ADT_A01|MSG00001|P|2.5.1`,
+	`EVN|A01|20240315083000`,
+	`PID|1||MRN123456^^^CITYGEN^MR||Yilmaz^Ayse^K||19650412|F|||Ataturk Cd 12^^Izmir^^35000^TR`,
+	`PV1|1|I|CARD^201^1^CITYGEN||||1234^House^Gregory|||CAR`,
+	`OBX|1|NM|8480-6^Systolic blood pressure^LN||142|mm[Hg]^mm[Hg]^UCUM|||||F|||20240315081500`,
+	`OBX|2|NM|8462-4^Diastolic blood pressure^LN||91|mm[Hg]^mm[Hg]^UCUM|||||F|||20240315081500`,
+	`OBX|3|NM|2339-0^Glucose [Mass/volume] in Blood^LN||131|mg/dL^mg/dL^UCUM|||||F|||20240315080000`,
+	`OBX|4|NM|39156-5^Body mass index^LN||29.1|kg/m2^kg/m2^UCUM|||||F`,
+	`OBX|5|NM|8867-4^Heart rate^LN||78|/min^beats/minute^UCUM|||||F|||20240315081500`,
+}, "\r") + "\r"
+
+// hl7ORU is a bedside monitor's result feed: a repeated systolic reading, a
+// glucose in mmol/L, an unmapped SpO2 and a heart rate entered in error
+var hl7ORU = strings.Join([]string{
+	`MSH|^~\&|PHILIPS_MON|ICU|HEALTHCARE|CARDIO|20240316101500+0300||ORU^R01^ORU_R01|MON-88812|P|2.4`,
+	`PID|1||MRN778899^^^CITYGEN^MR||Demir^Mehmet||19581103|M`,
+	`OBR|1||OBS-1|85353-1^Vital signs panel^LN|||20240316100000+0300`,
+	`OBX|1|NM|8480-6^Systolic blood pressure^LN||150|mm[Hg]|||||F|||20240316090000+0300`,
+	`OBX|2|NM|8480-6^Systolic blood pressure^LN||138|mmHg|||||F|||20240316100000+0300`,
+	`OBX|3|NM|8462-4^Diastolic blood pressure^LN||88|mm[Hg]|||||F|||20240316100000+0300`,
+	`OBX|4|NM|2339-0^Glucose^LN||6.1|mmol/L^mmol/L^UCUM|||||F`,
+	`OBX|5|NM|GLU^Glucose^L^2339-0^Glucose^LN||104|mg/dL|||||F`,
+	`OBX|6|NM|39156-5^BMI^LN||31.4|kg/m2|||||F`,
+	`OBX|7|NM|59408-5^Oxygen saturation^LN||97|%|||||F`,
+	`OBX|8|NM|8867-4^Heart rate^LN||180|/min|||||W`,
+}, "\n")
+
+func readHL7(raw string) (models.PatientData, []hl7.Issue, error) {
+	msg, err := hl7.Parse(raw)
+	if err != nil {
+		return models.PatientData{}, nil, err
+	}
+	return hl7.ReadPatient(msg)
+}
+
+func TestHL7_ReadPatient(t *testing.T) {
+	now := time.Now()
+	customDelimiters := strings.NewReplacer("|", "#", "^", "*").Replace(hl7ADT)
+
+	tests := []struct {
+		name     string
+		message  string
+		want     models.PatientData
+		warnings []string // Locations
+	}{
+		{"ADT^A01 admission", hl7ADT, models.PatientData{
+			Name: "Ayse K Yilmaz", Age: adapters.AgeOn(time.Date(1965, 4, 12, 0, 0, 0, 0, time.UTC), now), Gender: "Female",
+			SystolicBP: 142, DiastolicBP: 91, Glucose: 131, BMI: 29.1, HeartRate: 78,
+		}, nil},
+		{"ORU^R01 monitor feed", hl7ORU, models.PatientData{
+			Name: "Mehmet Demir", Age: adapters.AgeOn(time.Date(1958, 11, 3, 0, 0, 0, 0, time.UTC), now), Gender: "Male",
+			SystolicBP: 138, DiastolicBP: 88, Glucose: 104, BMI: 31.4,
+		}, []string{"OBX[4]-6", "OBX[7]-3", "OBX[8]-11"}},
+		{"MLLP framed", "\x0b" + hl7ADT + "\x1c\r", models.PatientData{SystolicBP: 142}, nil},
+		{"custom delimiters", customDelimiters, models.PatientData{Name: "Ayse K Yilmaz", SystolicBP: 142}, nil},
+		{"escaped name", strings.Replace(hl7ADT, `Yilmaz^Ayse^K`, `Smith\T\Jones^Ann`, 1),
+			models.PatientData{Name: "Ann Smith&Jones", SystolicBP: 142}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, warnings, err := readHL7(tt.message)
+			if err != nil {
+				t.Fatalf("Expected the message read, got %v", err)
+			}
+			if tt.want.Name != "" && p.Name != tt.want.Name {
+				t.Errorf("Expected name %q, got %q", tt.want.Name, p.Name)
+			}
+			if tt.want.Age != 0 && (p.Age != tt.want.Age || p.Gender != tt.want.Gender) {
+				t.Errorf("Expected %d %s, got %d %s", tt.want.Age, tt.want.Gender, p.Age, p.Gender)
+			}
+			if tt.want.SystolicBP != p.SystolicBP || (tt.want.Glucose != 0 && (p.DiastolicBP != tt.want.DiastolicBP ||
+				p.Glucose != tt.want.Glucose || p.BMI != tt.want.BMI || p.HeartRate != tt.want.HeartRate)) {
+				t.Errorf("Expected vitals %+v, got %+v", tt.want, p)
+			}
+			var locations []string
+			for _, w := range warnings {
+				locations = append(locations, w.Location())
+			}
+			if strings.Join(locations, ",") != strings.Join(tt.warnings, ",") {
+				t.Errorf("Expected warnings at %v, got %v", tt.warnings, warnings)
+			}
+		})
+	}
+
+	p, _, _ := readHL7(hl7ORU)
+	if !strings.Contains(p.ImputedFields, "cholesterol") || !strings.Contains(p.ImputedFields, "heart_rate") {
+		t.Errorf("Expected missing cholesterol and the discarded heart rate left to imputation, got %q", p.ImputedFields)
+	}
+}
+
+func TestHL7_MalformedMessages(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		location string
+		code     int
+		ack      string
+	}{
+		{"not HL7", "hello", "MSH", hl7.CodeSegmentSequence, hl7.AckError},
+		{"no MSH", hl7ADT[strings.Index(hl7ADT, "EVN"):], "MSH", hl7.CodeSegmentSequence, hl7.AckError},
+		{"bad encoding characters", strings.Replace(hl7ADT, `^~\&`, `^^\&`, 1), "MSH-2", hl7.CodeDataType, hl7.AckError},
+		{"bad segment ID", strings.Replace(hl7ADT, "PV1|", "pv1|", 1), "pv1", hl7.CodeSegmentSequence, hl7.AckError},
+		{"two messages", hl7ADT + hl7ADT, "MSH[2]", hl7.CodeSegmentSequence, hl7.AckError},
+		{"unsupported event", strings.Replace(hl7ADT, "ADT^A01", "ADT^A08", 1), "MSH-9", hl7.CodeUnsupportedEventCode, hl7.AckReject},
+		{"unsupported type", strings.Replace(hl7ADT, "ADT^A01", "ORM^O01", 1), "MSH-9", hl7.CodeUnsupportedMessageType, hl7.AckReject},
+		{"no PID", strings.Replace(hl7ADT, "PID|", "PD1|", 1), "PID", hl7.CodeSegmentSequence, hl7.AckError},
+		{"two PIDs", strings.Replace(hl7ORU, "OBR|", "PID|2||MRN1||Doe^Jane||19700101|F\nOBR|", 1), "PID[2]", hl7.CodeSegmentSequence, hl7.AckError},
+		{"no date of birth", strings.Replace(hl7ADT, "|19650412|", "||", 1), "PID-7", hl7.CodeRequiredFieldMissing, hl7.AckError},
+		{"bad date of birth", strings.Replace(hl7ADT, "|19650412|", "|19651312|", 1), "PID-7", hl7.CodeDataType, hl7.AckError},
+		{"unknown sex", strings.Replace(hl7ADT, "|19650412|F|", "|19650412|U|", 1), "PID-8", hl7.CodeTableValueNotFound, hl7.AckError},
+		{"non-numeric value", strings.Replace(hl7ADT, "||131|", "||13l|", 1), "OBX[3]-5", hl7.CodeDataType, hl7.AckError},
+		{"bad observation time", strings.Replace(hl7ADT, "|20240315080000", "|2024-03-15", 1), "OBX[3]-14", hl7.CodeDataType, hl7.AckError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := readHL7(tt.message)
+			var issue *hl7.Issue
+			if !errors.As(err, &issue) {
+				t.Fatalf("Expected a located issue, got %v", err)
+			}
+			if issue.Location() != tt.location || issue.Code != tt.code {
+				t.Errorf("Expected %s code %d, got %s code %d (%s)", tt.location, tt.code, issue.Location(), issue.Code, issue.Text)
+			}
+			if ack := hl7.AckCode(err); ack != tt.ack {
+				t.Errorf("Expected %s, got %s", tt.ack, ack)
+			}
+		})
+	}
+}
+
+func TestHL7_ACK(t *testing.T) {
+	msg, _ := hl7.Parse(hl7ADT)
+	warning := hl7.Issue{Segment: "OBX", Sequence: 7, Field: 3, Code: hl7.CodeTableValueNotFound, Text: "unrecognized observation 59408-5^Oxygen saturation^LN"}
+	segments := strings.Split(strings.TrimSuffix(hl7.ACK(msg, hl7.AckAccept, "Patient 7 created", nil, []hl7.Issue{warning}), "\r"), "\r")
+	if len(segments) != 3 {
+		t.Fatalf("Expected MSH, MSA and one ERR, got %q", segments)
+	}
+	msh := strings.Split(segments[0], "|")
+	if msh[1] != `^~\&` || msh[2] != "HEALTHCARE" || msh[4] != "EPIC" || msh[5] != "CITYGEN" || msh[8] != "ACK^A01^ACK" || msh[11] != "2.5.1" {
+		t.Errorf("Expected the ACK sent back to EPIC for the A01, got %s", segments[0])
+	}
+	if segments[1] != "MSA|AA|MSG00001|Patient 7 created" {
+		t.Errorf("Expected the control ID echoed, got %s", segments[1])
+	}
+	if segments[2] != `ERR||OBX^7^3|103^Table value not found^HL70357|W||||unrecognized observation 59408-5\S\Oxygen saturation\S\LN` {
+		t.Errorf("Expected a located, escaped warning, got %s", segments[2])
+	}
+
+	custom, _ := hl7.Parse(strings.NewReplacer("|", "#", "^", "*").Replace(hl7ADT))
+	if ack := hl7.ACK(custom, hl7.AckAccept, "ok", nil, nil); !strings.HasPrefix(ack, `MSH#*~\&#HEALTHCARE#`) || !strings.Contains(ack, "MSA#AA#MSG00001#ok") {
+		t.Errorf("Expected the ACK in the message's delimiters, got %q", ack)
+	}
+	if ack := hl7.ACK(nil, hl7.AckError, "Malformed message", nil, nil); !strings.Contains(ack, "MSA|AE||Malformed message") {
+		t.Errorf("Expected an AE without a control ID for an unreadable message, got %q", ack)
+	}
+}
+
+func TestHL7_Ingest(t *testing.T) {
+	h, db, _ := newTestPatientHandler(t, "http://ml.invalid", handlers.NewWebSocketHandler())
+	app := fiber.New()
+	app.Post("/api/hl7/ingest", handlers.NewHL7Handler(h).Ingest)
+	post := func(body string) (int, string, string) {
+		req := httptest.NewRequest("POST", "/api/hl7/ingest", strings.NewReader(body))
+		req.Header.Set("Content-Type", hl7.ContentType)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("POST failed: %v", err)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != hl7.ContentType {
+			t.Errorf("Expected an HL7 ACK, got %q", ct)
+		}
+		ack, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, resp.Header.Get("X-Patient-ID"), string(ack)
+	}
+
+	code, id, ack := post(hl7ADT)
+	if code != 201 || id == "" || !strings.Contains(ack, "MSA|AA|MSG00001|Patient "+id+" created") {
+		t.Fatalf("Expected the admission accepted, got %d %q", code, ack)
+	}
+	var stored models.PatientData
+	db.First(&stored, id)
+	if stored.Source != services.PatientSourceHL7 || stored.Gender != "Female" || stored.Glucose != 131 {
+		t.Errorf("Expected an HL7 patient stored, got %+v", stored)
+	}
+	var created int64
+	db.Model(&models.AuditLog{}).Where("event_type = ?", "PATIENT_CREATED").Count(&created)
+	if created != 1 {
+		t.Errorf("Expected the creation audited, got %d", created)
+	}
+
+	if code, _, ack := post("\x0b" + hl7ORU + "\x1c\r"); code != 201 || strings.Count(ack, "|W|") != 3 {
+		t.Errorf("Expected the monitor feed accepted with 3 warnings, got %d %q", code, ack)
+	}
+
+	for _, tt := range []struct{ name, message, want string }{
+		{"malformed OBX", strings.Replace(hl7ADT, "||131|", "||13l|", 1), "ERR||OBX^3^5|102^Data type error^HL70357|E"},
+		{"unsupported event", strings.Replace(hl7ADT, "ADT^A01", "ADT^A08", 1), "MSA|AR|MSG00001|"},
+		{"out of range", strings.Replace(hl7ADT, "||131|", "||2000|", 1), "Glucose: Value exceeds maximum"},
+		{"garbage", "not an HL7 message", "MSA|AE||Malformed message"},
+	} {
+		if code, id, ack := post(tt.message); code != 400 || id != "" || !strings.Contains(ack, tt.want) {
+			t.Errorf("%s: expected a 400 with %q, got %d %q", tt.name, tt.want, code, ack)
+		}
+	}
+	var count int64
+	db.Model(&models.PatientData{}).Count(&count)
+	if count != 2 {
+		t.Errorf("Expected nothing stored from refused messages, got %d patients", count)
+	}
+}
@@ -0,0 +1,163 @@
+package unit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/repositories"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestICD10Coder_SuggestsWithEvidence(t *testing.T) {
+	coder := services.NewICD10Coder(services.DefaultICD10Codes)
+	diagnosis := "Findings are consistent with uncontrolled Type-2 diabetes and hypertension. No signs of stroke."
+	predictions := []models.DiseasePrediction{
+		{Disease: "migraine", Probability: 1},
+		{Disease: "heart failure", Probability: 62},
+		{Disease: "anxiety", Probability: 5},
+		{Disease: "depression", Probability: 3},
+	}
+
+	got := coder.Suggest(diagnosis, predictions)
+	var codes []string
+	for _, s := range got {
+		codes = append(codes, fmt.Sprintf("%s=%.2f", s.Code, s.Confidence))
+	}
+	want := []string{"E11.9=0.64", "I50.9=0.62", "I10=0.40", "F41.9=0.05"}
+	if !slices.Equal(codes, want) {
+		t.Fatalf("Expected %v, got %v", want, codes)
+	}
+	if !slices.Equal(got[0].MatchedKeywords, []string{"diabetes", "type 2 diabetes"}) || got[0].Description == "" {
+		t.Errorf("Expected the diabetes keywords listed, got %+v", got[0])
+	}
+	if len(got[1].MatchedKeywords) != 0 || !slices.Equal(got[1].MatchedDiseases, []string{"heart failure"}) {
+		t.Errorf("Expected heart failure suggested by the disease prediction alone, got %+v", got[1])
+	}
+
+	if s := coder.Suggest("Patient denies chest pain. Without hypertension.", nil); len(s) != 0 {
+		t.Errorf("Expected negated mentions ignored, got %+v", s)
+	}
+	if s := coder.Suggest("Strokes of luck; cadence normal", nil); len(s) != 0 {
+		t.Errorf("Expected only whole words to match, got %+v", s)
+	}
+}
+
+func TestICD10Service_SeedAndResolve(t *testing.T) {
+	_, db, _ := newTestPatientHandler(t, "http://ml.invalid", handlers.NewWebSocketHandler())
+	db.AutoMigrate(&models.ICD10Code{})
+	svc := services.NewICD10Service(db)
+	if err := svc.Seed(); err != nil {
+		t.Fatalf("Seed failed: %v", err)
+	}
+	db.Model(&models.ICD10Code{}).Where("code = ?", "I10").Update("keywords", "hypertension, bp")
+	svc.Seed()
+	var count int64
+	db.Model(&models.ICD10Code{}).Count(&count)
+	var edited models.ICD10Code
+	db.First(&edited, "code = ?", "I10")
+	if int(count) != len(services.DefaultICD10Codes) || edited.Keywords != "hypertension, bp" {
+		t.Errorf("Expected reseeding to keep edits and add nothing, got %d rows, %q", count, edited.Keywords)
+	}
+
+	codes, err := svc.Resolve([]string{" i10", "E11.9", "I10"})
+	if err != nil || !slices.Equal(codes, []string{"I10", "E11.9"}) {
+		t.Errorf("Expected normalized, deduplicated codes, got %v %v", codes, err)
+	}
+	for _, bad := range []string{"Z99.9", "banana"} {
+		if _, err := svc.Resolve([]string{bad}); !errors.Is(err, services.ErrUnknownICD10) {
+			t.Errorf("%s: expected ErrUnknownICD10, got %v", bad, err)
+		}
+	}
+}
+
+func TestDiagnosis_ICD10Suggestions(t *testing.T) {
+	ml := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/disease/predict":
+			json.NewEncoder(w).Encode(models.DiseaseResponse{Predictions: []models.DiseasePrediction{
+				{Disease: "heart failure", Probability: 70, Confidence: "high"},
+			}})
+		case "/diagnose":
+			json.NewEncoder(w).Encode(models.DiagnosisResponse{Diagnosis: "Likely hypertension with fluid overload.", Status: "ready"})
+		}
+	}))
+	t.Cleanup(ml.Close)
+	h, _, pred := newTestPatientHandler(t, ml.URL, handlers.NewWebSocketHandler())
+
+	if _, err := pred.PredictDisease(models.DiseaseRequest{Symptoms: []string{"dyspnea"}, PatientID: "7"}); err != nil {
+		t.Fatalf("PredictDisease failed: %v", err)
+	}
+	done := make(chan struct{})
+	pred.StartAsyncDiagnosis(7, models.DiagnosisRequest{}, func(uint, string, string) { close(done) })
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Diagnosis never finished")
+	}
+
+	app := fiber.New()
+	app.Get("/api/diagnosis/:id", h.GetDiagnosis)
+	var body struct {
+		Status           string
+		ICD10Suggestions []models.ICD10Suggestion `json:"icd10_suggestions"`
+	}
+	get := func(path string) []byte {
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		raw, _ := io.ReadAll(resp.Body)
+		return raw
+	}
+	raw := get("/api/diagnosis/7")
+	json.Unmarshal(raw, &body)
+	if body.Status != "ready" || len(body.ICD10Suggestions) != 2 {
+		t.Fatalf("Expected the ready diagnosis with 2 suggestions, got %s", raw)
+	}
+	if s := body.ICD10Suggestions[0]; s.Code != "I50.9" || s.Confidence != 0.7 || s.MatchedDiseases[0] != "heart failure" {
+		t.Errorf("Expected heart failure from the disease prediction first, got %+v", s)
+	}
+	if s := body.ICD10Suggestions[1]; s.Code != "I10" || s.MatchedKeywords[0] != "hypertension" {
+		t.Errorf("Expected hypertension from the text, got %+v", s)
+	}
+
+	pred.Cache.Set(8, "", "pending")
+	if raw := get("/api/diagnosis/8"); string(raw) != `{"diagnosis":"","icd10_suggestions":[],"id":8,"status":"pending"}` {
+		t.Errorf("Expected a pending diagnosis without suggestions, got %s", raw)
+	}
+}
+
+func TestSubmitFeedback_StoresConfirmedICD10Codes(t *testing.T) {
+	_, db, _ := newTestPatientHandler(t, "http://ml.invalid", handlers.NewWebSocketHandler())
+	db.AutoMigrate(&models.ICD10Code{}, &models.OverrideLog{}, &models.OverrideReason{})
+	icd10 := services.NewICD10Service(db)
+	icd10.Seed()
+	h := handlers.NewFeedbackHandler(db, repositories.NewFeedbackRepository(db), services.NewOverrideService(db), services.NewAuditService(db))
+	h.ICD10 = icd10
+	app := fiber.New()
+	app.Post("/api/feedback", h.SubmitFeedback)
+
+	code, body := postJSON(t, app, "/api/feedback", `{"assessment_id": 3, "approved": true, "icd10_codes": ["i10", "E11.9"]}`)
+	if code != 200 {
+		t.Fatalf("Expected the feedback recorded, got %d %v", code, body)
+	}
+	var fb models.Feedback
+	db.First(&fb, body["id"])
+	if fb.ICD10Codes != "I10,E11.9" {
+		t.Errorf("Expected the confirmed codes stored, got %q", fb.ICD10Codes)
+	}
+	if code, body := postJSON(t, app, "/api/feedback", `{"assessment_id": 3, "approved": true, "icd10_codes": ["Z99.9"]}`); code != 400 {
+		t.Errorf("Expected a code outside the table refused, got %d %v", code, body)
+	}
+}
@@ -0,0 +1,127 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupIntakeApp(t *testing.T) (*fiber.App, *gorm.DB, *services.IntakeService) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	db.AutoMigrate(&models.PatientData{}, &models.IntakeToken{}, &models.AuditLog{})
+
+	intake := services.NewIntakeService(db, time.Hour)
+	h := handlers.NewIntakeHandler(intake, services.NewAuditService(db), handlers.NewWebSocketHandler())
+	app := fiber.New()
+	app.Post("/api/admin/intake-tokens", h.CreateToken)
+	app.Post("/api/intake/:token", h.Submit)
+	return app, db, intake
+}
+
+func postIntake(t *testing.T, app *fiber.App, path, body string) int {
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	return resp.StatusCode
+}
+
+// TestIntake_TokenIsSingleUse tests that a redeemed token can't be replayed
+func TestIntake_TokenIsSingleUse(t *testing.T) {
+	app, db, _ := setupIntakeApp(t)
+
+	req := httptest.NewRequest("POST", "/api/admin/intake-tokens", bytes.NewBufferString(`{"clinic":"north-wing"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req)
+	if resp.StatusCode != 201 {
+		t.Fatalf("Expected 201 creating token, got %d", resp.StatusCode)
+	}
+	var created struct {
+		Token string `json:"token"`
+	}
+	json.NewDecoder(resp.Body).Decode(&created)
+
+	form := `{"age":54,"gender":"Female","smoking":"No","symptoms":"cough, fatigue"}`
+	if code := postIntake(t, app, "/api/intake/"+created.Token, form); code != 201 {
+		t.Fatalf("Expected 201 on first use, got %d", code)
+	}
+	if code := postIntake(t, app, "/api/intake/"+created.Token, form); code != 410 {
+		t.Errorf("Expected 410 on reuse, got %d", code)
+	}
+	if code := postIntake(t, app, "/api/intake/not-a-token", form); code != 404 {
+		t.Errorf("Expected 404 for unknown token, got %d", code)
+	}
+
+	var patients []models.PatientData
+	db.Find(&patients)
+	if len(patients) != 1 || patients[0].Source != services.PatientSourceKiosk || patients[0].Age != 54 {
+		t.Fatalf("Expected exactly one kiosk patient, got %+v", patients)
+	}
+
+	var events []models.AuditLog
+	db.Order("id").Find(&events)
+	if len(events) != 2 || events[0].EventType != "INTAKE_TOKEN_CREATED" || events[1].EventType != "INTAKE_TOKEN_USED" {
+		t.Errorf("Expected token creation and use audited, got %d events", len(events))
+	}
+}
+
+// TestIntake_FieldWhitelist tests that only self-reportable fields are accepted
+func TestIntake_FieldWhitelist(t *testing.T) {
+	app, db, intake := setupIntakeApp(t)
+	token, _, err := intake.Issue("north-wing")
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+
+	rejected := []string{
+		`{"age":54,"gender":"Female","systolic_bp":120}`,
+		`{"age":54,"gender":"Female","history_stroke":"Yes"}`,
+		`{"age":54,"gender":"Female","source":"clinician"}`,
+		`{"age":54,"gender":"Robot"}`,
+		`{"gender":"Female"}`,
+	}
+	for _, body := range rejected {
+		if code := postIntake(t, app, "/api/intake/"+token, body); code != 400 {
+			t.Errorf("Expected 400 for %s, got %d", body, code)
+		}
+	}
+
+	// Rejected forms must not burn the token
+	if code := postIntake(t, app, "/api/intake/"+token, `{"age":54,"gender":"Female","medications":"Metformin"}`); code != 201 {
+		t.Errorf("Expected token still valid after rejected forms, got %d", code)
+	}
+
+	var p models.PatientData
+	db.First(&p)
+	if p.SystolicBP != 0 || p.HistoryStroke != "" || p.Medications != "Metformin" {
+		t.Errorf("Unexpected patient fields %+v", p)
+	}
+}
+
+// TestIntake_ExpiredToken tests that expired tokens are refused
+func TestIntake_ExpiredToken(t *testing.T) {
+	app, db, intake := setupIntakeApp(t)
+	token, record, _ := intake.Issue("north-wing")
+	db.Model(&record).Update("expires_at", time.Now().Add(-time.Minute))
+
+	if code := postIntake(t, app, "/api/intake/"+token, `{"age":30,"gender":"Male"}`); code != 410 {
+		t.Errorf("Expected 410 for expired token, got %d", code)
+	}
+}
@@ -0,0 +1,138 @@
+package unit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// newFakeKubo serves the two RPC calls KuboClient uses
+func newFakeKubo(t *testing.T) *httptest.Server {
+	var mu sync.Mutex
+	blobs := map[string][]byte{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v0/add":
+			file, _, err := r.FormFile("file")
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			data, _ := io.ReadAll(file)
+			sum := sha256.Sum256(data)
+			cid := "Qm" + hex.EncodeToString(sum[:])[:44]
+			mu.Lock()
+			blobs[cid] = data
+			mu.Unlock()
+			json.NewEncoder(w).Encode(map[string]string{"Name": "audit-chain.enc", "Hash": cid})
+		case "/api/v0/cat":
+			mu.Lock()
+			data, ok := blobs[r.URL.Query().Get("arg")]
+			mu.Unlock()
+			if !ok {
+				http.Error(w, `{"Message":"block was not found locally (offline)"}`, http.StatusInternalServerError)
+				return
+			}
+			w.Write(data)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func restoreApp(audit *services.AuditService, ipfs *services.IPFSService) *fiber.App {
+	h := handlers.NewBlockchainHandler(audit, ipfs)
+	app := fiber.New()
+	app.Post("/api/blockchain/backup", h.BackupChain)
+	app.Post("/api/blockchain/restore", h.RestoreChain)
+	return app
+}
+
+func postJSON(t *testing.T, app *fiber.App, url, body string) (int, map[string]any) {
+	req := httptest.NewRequest("POST", url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, 5000)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	var out map[string]any
+	json.NewDecoder(resp.Body).Decode(&out)
+	return resp.StatusCode, out
+}
+
+func TestIPFSRestore_KuboSurvivesRestart(t *testing.T) {
+	kubo := newFakeKubo(t)
+	key := bytes.Repeat([]byte{7}, 32)
+	db := openTestAuditDB(t)
+	audit := services.NewAuditService(db)
+	for i := 0; i < 3; i++ {
+		audit.LogEvent("AI_PREDICTION", uint(i), map[string]any{"n": i}, auditctx.System)
+	}
+
+	code, backup := postJSON(t, restoreApp(audit, services.NewIPFSService(services.NewKuboClient(kubo.URL), key)), "/api/blockchain/backup", "")
+	if code != 200 || backup["provider"] != "IPFS (Decentralized)" {
+		t.Fatalf("Backup failed: %d %v", code, backup)
+	}
+	cid, _ := backup["ipfs_cid"].(string)
+
+	// A fresh process with the configured key can read it back
+	restarted := restoreApp(audit, services.NewIPFSService(services.NewKuboClient(kubo.URL), key))
+	code, restored := postJSON(t, restarted, "/api/blockchain/restore", `{"cid":"`+cid+`"}`)
+	report, _ := restored["report"].(map[string]any)
+	if code != 200 || restored["status"] != "match" || report["matched"] != float64(3) || report["backup_intact"] != true {
+		t.Fatalf("Expected the backup to match, got %d %v", code, restored)
+	}
+
+	// A different key can't
+	wrongKey := restoreApp(audit, services.NewIPFSService(services.NewKuboClient(kubo.URL), bytes.Repeat([]byte{8}, 32)))
+	if code, _ := postJSON(t, wrongKey, "/api/blockchain/restore", `{"cid":"`+cid+`"}`); code != 422 {
+		t.Errorf("Expected 422 with the wrong key, got %d", code)
+	}
+	if code, _ := postJSON(t, restarted, "/api/blockchain/restore", `{"cid":"QmUnknown"}`); code != 404 {
+		t.Errorf("Expected 404 for an unknown CID, got %d", code)
+	}
+	if code, _ := postJSON(t, restarted, "/api/blockchain/restore", `{}`); code != 400 {
+		t.Errorf("Expected 400 without a CID, got %d", code)
+	}
+}
+
+func TestIPFSRestore_ReportsLocalDivergence(t *testing.T) {
+	db := openTestAuditDB(t)
+	audit := services.NewAuditService(db)
+	for i := 0; i < 4; i++ {
+		audit.LogEvent("AI_PREDICTION", uint(i), map[string]any{"n": i}, auditctx.System)
+	}
+	app := restoreApp(audit, services.NewIPFSService(nil, nil))
+	_, backup := postJSON(t, app, "/api/blockchain/backup", "")
+	cid := `{"cid":"` + backup["ipfs_cid"].(string) + `"}`
+
+	db.Model(&models.AuditLog{}).Where("id = ?", 2).Update("current_hash", "rewritten")
+	db.Delete(&models.AuditLog{}, 4)
+
+	code, restored := postJSON(t, app, "/api/blockchain/restore", cid)
+	report, _ := restored["report"].(map[string]any)
+	if code != 200 || restored["status"] != "mismatch" {
+		t.Fatalf("Expected a mismatch, got %d %v", code, restored)
+	}
+	mismatched, _ := report["mismatched"].([]any)
+	missing, _ := report["missing"].([]any)
+	if len(mismatched) != 1 || mismatched[0].(map[string]any)["id"] != float64(2) || len(missing) != 1 || missing[0] != float64(4) || report["matched"] != float64(2) {
+		t.Errorf("Expected entry 2 mismatched and entry 4 missing, got %v", report)
+	}
+}
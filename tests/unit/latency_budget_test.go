@@ -0,0 +1,112 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/jobs"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/repositories"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestAssess_LatencyBudget tests that a slow ML model can't hold /api/assess
+// past its budget and that the missing component completes afterwards
+func TestAssess_LatencyBudget(t *testing.T) {
+	const budget = 2 * time.Second
+	ml := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/predict":
+			time.Sleep(budget + 500*time.Millisecond)
+			json.NewEncoder(w).Encode(models.PredictResponse{HeartRisk: 90, ModelPrecisions: map[string]float64{"Heart_Model": 0.9}})
+		case "/urgency/predict":
+			json.NewEncoder(w).Encode(models.UrgencyResponse{UrgencyLevel: 2})
+		default:
+			json.NewEncoder(w).Encode(models.DiagnosisResponse{Diagnosis: "ok", Status: "ready"})
+		}
+	}))
+	t.Cleanup(ml.Close)
+
+	h, db, _ := newTestPatientHandler(t, ml.URL, handlers.NewWebSocketHandler())
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1) // Keep background jobs on the same in-memory database
+	runner := jobs.NewRunner("test-completion", 2, 10, 0)
+	t.Cleanup(runner.Stop)
+	h.Budget = budget
+	h.Completions = runner
+
+	assessments := handlers.NewAssessmentHandler(repositories.NewAssessmentRepository(db))
+	app := fiber.New()
+	app.Post("/api/assess", h.AssessPatient)
+	app.Get("/api/assessments/:id", assessments.GetAssessment)
+
+	body, _ := json.Marshal(models.PatientData{Age: 70, Gender: "Male", SystolicBP: 150, DiastolicBP: 95, Glucose: 130, BMI: 31, Cholesterol: 220, HeartRate: 80})
+	req := httptest.NewRequest("POST", "/api/assess", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	start := time.Now()
+	resp, err := app.Test(req, 10000)
+	elapsed := time.Since(start)
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("Assess failed: %v %v", err, resp)
+	}
+	if elapsed > budget+300*time.Millisecond {
+		t.Errorf("Expected response within the %v budget, took %v", budget, elapsed)
+	}
+
+	var result models.FullAssessmentResponse
+	json.NewDecoder(resp.Body).Decode(&result)
+	if result.Budget == nil {
+		t.Fatal("Expected a budget report")
+	}
+	if len(result.Budget.Pending) != 1 || result.Budget.Pending[0] != models.ComponentRisks {
+		t.Errorf("Expected only risks pending, got %v", result.Budget.Pending)
+	}
+	if len(result.Budget.Completed) != 2 {
+		t.Errorf("Expected urgency and medications completed, got %v", result.Budget.Completed)
+	}
+	if result.Urgency.UrgencyLevel != 2 {
+		t.Errorf("Expected urgency in the response, got %+v", result.Urgency)
+	}
+
+	// The completion job fills in the risks later
+	var stored struct {
+		Assessment models.Assessment            `json:"assessment"`
+		Components []models.AssessmentComponent `json:"components"`
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		r, _ := app.Test(httptest.NewRequest("GET", "/api/assessments/"+jsonNumber(result.AssessmentID), nil))
+		json.NewDecoder(r.Body).Decode(&stored)
+		if stored.Assessment.HeartRisk != 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if stored.Assessment.HeartRisk != 90 || stored.Assessment.RuleBased {
+		t.Fatalf("Expected late ML risks stored on the assessment, got %+v", stored.Assessment)
+	}
+	if !stored.Assessment.Emergency {
+		t.Error("Expected the late heart risk to escalate the emergency flag")
+	}
+	for _, c := range stored.Components {
+		if c.Status != models.ComponentReady {
+			t.Errorf("Expected %s ready, got %s (%s)", c.Component, c.Status, c.Error)
+		}
+		if c.Late != (c.Component == models.ComponentRisks) {
+			t.Errorf("Unexpected late flag on %s", c.Component)
+		}
+	}
+}
+
+func jsonNumber(id uint) string {
+	b, _ := json.Marshal(id)
+	return string(b)
+}
@@ -0,0 +1,109 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/blockchain"
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// writeLedger opens a persisted chain at path and records n audit events on it
+func writeLedger(t *testing.T, path string, every, n int) *blockchain.Blockchain {
+	chain, err := blockchain.Open(path, every)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	audit := services.NewAuditServiceOnChain(openTestAuditDB(t), chain)
+	for i := 0; i < n; i++ {
+		audit.LogEvent("AI_PREDICTION", uint(i+1), map[string]any{"heart_risk": 42.5}, auditctx.System)
+	}
+	return chain
+}
+
+func TestLedger_SnapshotRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger", "chain.json")
+
+	chain := writeLedger(t, path, 2, 5)
+	// Blocks 2 and 4 triggered snapshots; the fifth is only saved on shutdown
+	reloaded, err := blockchain.Open(path, 2)
+	if err != nil || len(reloaded.GetChain()) != 5 {
+		t.Fatalf("Expected the periodic snapshot to hold 5 blocks, got %v %v", len(reloaded.GetChain()), err)
+	}
+
+	if err := chain.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	reloaded, err = blockchain.Open(path, 2)
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if len(reloaded.GetChain()) != 6 || !reloaded.IsChainValid() || reloaded.TaintedReason() != "" {
+		t.Fatalf("Expected all 6 blocks reloaded and valid, got %d valid=%v tainted=%q",
+			len(reloaded.GetChain()), reloaded.IsChainValid(), reloaded.TaintedReason())
+	}
+
+	// New blocks keep chaining off the reloaded tip
+	next := reloaded.AddBlock("after restart")
+	if next.PreviousHash != chain.GetChain()[5].Hash || !reloaded.IsChainValid() {
+		t.Errorf("Expected the new block chained to the persisted tip")
+	}
+}
+
+func TestLedger_TamperedSnapshotIsTainted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chain.json")
+	chain := writeLedger(t, path, 0, 3)
+	if err := chain.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	var blocks []map[string]any
+	data, _ := os.ReadFile(path)
+	json.Unmarshal(data, &blocks)
+	blocks[2]["data"].(map[string]any)["event_type"] = "HUMAN_OVERRIDE"
+	data, _ = json.Marshal(blocks)
+	os.WriteFile(path, data, 0640)
+
+	tampered, err := blockchain.Open(path, 0)
+	if err != nil {
+		t.Fatalf("Expected a tampered snapshot still loaded, got %v", err)
+	}
+	if tampered.TaintedReason() == "" || tampered.IsChainValid() {
+		t.Fatal("Expected the tampered snapshot flagged as tainted")
+	}
+
+	app := fiber.New()
+	app.Get("/api/blockchain/verify", handlers.NewBlockchainHandler(services.NewAuditServiceOnChain(openTestAuditDB(t), tampered), nil).VerifyChain)
+	resp, _ := app.Test(httptest.NewRequest("GET", "/api/blockchain/verify", nil))
+	var body map[string]any
+	json.NewDecoder(resp.Body).Decode(&body)
+	if body["status"] != "tainted" || body["ledger_valid"] != false || body["ledger_tainted"] == nil {
+		t.Errorf("Expected verify to report the tainted ledger, got %v", body)
+	}
+
+	os.WriteFile(path, []byte(`[{"index":0,`), 0640)
+	if _, err := blockchain.Open(path, 0); err == nil || !strings.Contains(err.Error(), "parse") {
+		t.Errorf("Expected an unreadable snapshot to fail Open, got %v", err)
+	}
+}
+
+func openTestAuditDB(t testing.TB) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	db.AutoMigrate(&models.AuditLog{})
+	return db
+}
@@ -0,0 +1,189 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/workers"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nats-io/nats.go"
+)
+
+// newConcurrencyML holds every /diagnose call until release is closed and
+// tracks how many were open at once
+func newConcurrencyML(t *testing.T, release <-chan struct{}) (*httptest.Server, *atomic.Int64, *atomic.Int64, *atomic.Int64) {
+	var open, peak, calls atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		n := open.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		<-release
+		open.Add(-1)
+		json.NewEncoder(w).Encode(models.DiagnosisResponse{Diagnosis: "ok", Status: "ready"})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &open, &peak, &calls
+}
+
+func diagnosisTask(patientID uint) *nats.Msg {
+	data, _ := json.Marshal(models.DiagnosisRequest{Patient: models.PatientData{ID: patientID}})
+	return &nats.Msg{Subject: workers.LLMTaskSubject, Data: data}
+}
+
+func TestLLMPool_BoundsConcurrency(t *testing.T) {
+	release := make(chan struct{})
+	ml, _, peak, calls := newConcurrencyML(t, release)
+	worker := workers.NewLLMWorker(ml.URL)
+	worker.Concurrency = 3
+	worker.StartPool()
+	defer worker.Stop(context.Background())
+
+	go func() {
+		for i := 0; i < 12; i++ {
+			worker.Enqueue(diagnosisTask(uint(100 + i)))
+		}
+	}()
+	waitFor(t, "the pool to fill", func() bool { return worker.Status().InFlight == 3 && worker.Status().Queued == 3 })
+
+	app := fiber.New()
+	app.Get("/api/workers/status", handlers.NewWorkerHandler(worker).GetStatus)
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/workers/status", nil))
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	var status map[string]workers.WorkerStatus
+	json.NewDecoder(resp.Body).Decode(&status)
+	if llm := status["llm"]; llm.Concurrency != 3 || llm.InFlight != 3 || llm.Queued != 3 || llm.Durable {
+		t.Errorf("Unexpected status %+v", llm)
+	}
+
+	close(release)
+	waitFor(t, "every task to finish", func() bool { return worker.Status().Processed == 12 })
+	if peak.Load() != 3 || calls.Load() != 12 {
+		t.Errorf("Expected at most 3 concurrent calls over 12 tasks, got peak %d over %d", peak.Load(), calls.Load())
+	}
+}
+
+func TestLLMPool_StopFinishesInFlightOnly(t *testing.T) {
+	release := make(chan struct{})
+	ml, _, _, calls := newConcurrencyML(t, release)
+	worker := workers.NewLLMWorker(ml.URL)
+	worker.Concurrency = 2
+	worker.StartPool()
+
+	enqueued := make(chan error, 8)
+	go func() {
+		for i := 0; i < 8; i++ {
+			enqueued <- worker.Enqueue(diagnosisTask(uint(200 + i)))
+		}
+	}()
+	waitFor(t, "the pool to fill", func() bool { return worker.Status().InFlight == 2 && worker.Status().Queued == 2 })
+
+	// In-flight diagnoses hold the drain past its deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := worker.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the drain to time out, got %v", err)
+	}
+	if !worker.Status().Stopped {
+		t.Error("Expected the pool reported stopped")
+	}
+
+	close(release)
+	if err := worker.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if processed := worker.Status().Processed; processed != 2 || calls.Load() != 2 {
+		t.Errorf("Expected only the 2 in-flight tasks finished, got %d processed, %d calls", processed, calls.Load())
+	}
+	if err := worker.Enqueue(diagnosisTask(299)); !errors.Is(err, workers.ErrWorkerStopped) {
+		t.Errorf("Expected enqueue after stop refused, got %v", err)
+	}
+}
+
+func TestLLMPool_EmergenciesJumpTheQueue(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var order []uint
+	ml := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req models.DiagnosisRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		order = append(order, req.Patient.ID)
+		first := len(order) == 1
+		mu.Unlock()
+		if first {
+			<-release
+		}
+		json.NewEncoder(w).Encode(models.DiagnosisResponse{Diagnosis: "ok", Status: "ready"})
+	}))
+	t.Cleanup(ml.Close)
+
+	worker := workers.NewLLMWorker(ml.URL)
+	worker.Concurrency = 1
+	worker.StartPool()
+	defer worker.Stop(context.Background())
+
+	worker.Enqueue(diagnosisTask(301))
+	waitFor(t, "the first task to start", func() bool { return worker.Status().InFlight == 1 })
+	worker.Enqueue(diagnosisTask(302))
+	emergency := diagnosisTask(303)
+	emergency.Subject = workers.LLMPriorityTaskSubject
+	worker.Enqueue(emergency)
+
+	backlog := worker.Status().Backlog
+	if backlog[models.DiagnosisPriorityRoutine] != 1 || backlog[models.DiagnosisPriorityEmergency] != 1 {
+		t.Errorf("Expected one task waiting per priority, got %v", backlog)
+	}
+
+	close(release)
+	waitFor(t, "every task to finish", func() bool { return worker.Status().Processed == 3 })
+	mu.Lock()
+	defer mu.Unlock()
+	if len(order) != 3 || order[1] != 303 || order[2] != 302 {
+		t.Errorf("Expected the emergency diagnosed before the queued routine task, got %v", order)
+	}
+}
+
+func TestAssess_EmergencyDiagnosisIsPrioritised(t *testing.T) {
+	priorities := make(chan string, 1)
+	ml := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/predict":
+			json.NewEncoder(w).Encode(models.PredictResponse{HeartRisk: 91.5, ModelPrecisions: map[string]float64{"Heart_Model": 0.9}})
+		case "/diagnose":
+			var req models.DiagnosisRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			priorities <- req.Priority
+			json.NewEncoder(w).Encode(models.DiagnosisResponse{Diagnosis: "ok", Status: "ready"})
+		default:
+			json.NewEncoder(w).Encode(models.UrgencyResponse{UrgencyLevel: 3})
+		}
+	}))
+	t.Cleanup(ml.Close)
+
+	assessCrisis(t, ml.URL)
+	select {
+	case p := <-priorities:
+		if p != models.DiagnosisPriorityEmergency {
+			t.Errorf("Expected an emergency diagnosis request, got priority %q", p)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("No diagnosis was requested")
+	}
+}
@@ -0,0 +1,136 @@
+package unit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+	"healthcare-backend/pkg/workers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// newFlakyDiagnoseML fails the first failures /diagnose calls with a 503
+func newFlakyDiagnoseML(t *testing.T, failures int64) (*httptest.Server, *atomic.Int64) {
+	var calls atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			http.Error(w, "model loading", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(models.DiagnosisResponse{Diagnosis: "Stage 1 hypertension", Status: "ready"})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestLLMWorker_RetriesWithBackoff(t *testing.T) {
+	ml, calls := newFlakyDiagnoseML(t, 2)
+	db := openTestAuditDB(t)
+	db.AutoMigrate(&models.DiagnosisFailure{})
+
+	worker := workers.NewLLMWorker(ml.URL)
+	worker.Failures = services.NewDiagnosisFailureService(db)
+	worker.RetryBackoff = 20 * time.Millisecond
+
+	start := time.Now()
+	worker.Process(models.DiagnosisRequest{Patient: models.PatientData{ID: 61}})
+	if calls.Load() != 3 {
+		t.Errorf("Expected success on the third attempt, got %d calls", calls.Load())
+	}
+	// 20ms then 40ms
+	if took := time.Since(start); took < 60*time.Millisecond {
+		t.Errorf("Expected exponential backoff between attempts, took %v", took)
+	}
+	var failures int64
+	db.Model(&models.DiagnosisFailure{}).Count(&failures)
+	if failures != 0 {
+		t.Errorf("Expected no dead-lettered task, got %d", failures)
+	}
+}
+
+func TestLLMWorker_DeadLettersAndRequeues(t *testing.T) {
+	ml, calls := newFlakyDiagnoseML(t, 1000)
+	ws := handlers.NewWebSocketHandler()
+	_, db, pred := newTestPatientHandler(t, ml.URL, ws)
+	db.AutoMigrate(&models.DiagnosisFailure{})
+	failureService := services.NewDiagnosisFailureService(db)
+
+	patient := models.PatientData{Age: 50, Gender: "Male"}
+	db.Create(&patient)
+
+	worker := workers.NewLLMWorker(ml.URL)
+	worker.Diagnoses = pred.Diagnoses
+	worker.Failures = failureService
+	worker.RetryBackoff = time.Millisecond
+	worker.Process(models.DiagnosisRequest{Patient: patient, PastContext: "none", Generation: pred.Diagnoses.Next(patient.ID)})
+
+	if calls.Load() != 4 {
+		t.Errorf("Expected the first attempt plus 3 retries, got %d calls", calls.Load())
+	}
+	pending, _ := failureService.List(false)
+	if len(pending) != 1 || pending[0].PatientID != patient.ID || pending[0].Attempts != 4 || pending[0].Error != "ml service: 503 Service Unavailable" {
+		t.Fatalf("Expected one recorded failure, got %+v", pending)
+	}
+
+	h := handlers.NewDiagnosisFailureHandler(db, failureService, pred, ws, services.NewAuditService(db))
+	app := fiber.New()
+	app.Get("/api/admin/diagnosis-failures", h.List)
+	app.Post("/api/admin/diagnosis-failures/:id/requeue", h.Requeue)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/admin/diagnosis-failures", nil))
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("List failed: %v %v", err, resp)
+	}
+	var listed map[string]any
+	json.NewDecoder(resp.Body).Decode(&listed)
+	if listed["count"] != float64(1) {
+		t.Errorf("Expected one failure listed, got %v", listed)
+	}
+
+	url := fmt.Sprintf("/api/admin/diagnosis-failures/%d/requeue", pending[0].ID)
+	code, body := postJSON(t, app, url, "")
+	if code != 200 || body["status"] != "pending" {
+		t.Fatalf("Requeue failed: %d %v", code, body)
+	}
+	if code, _ := postJSON(t, app, url, ""); code != 409 {
+		t.Errorf("Expected 409 requeueing twice, got %d", code)
+	}
+	if code, _ := postJSON(t, app, "/api/admin/diagnosis-failures/999/requeue", ""); code != 404 {
+		t.Errorf("Expected 404 for an unknown failure, got %d", code)
+	}
+	if remaining, _ := failureService.List(false); len(remaining) != 0 {
+		t.Errorf("Expected the requeued failure hidden by default, got %+v", remaining)
+	}
+	if all, _ := failureService.List(true); len(all) != 1 || all[0].RequeuedAt == nil {
+		t.Errorf("Expected ?all to include the requeued failure, got %+v", all)
+	}
+}
+
+func TestLLMWorker_SupersededTaskIsNotRetried(t *testing.T) {
+	ml, calls := newFlakyDiagnoseML(t, 1000)
+	db := openTestAuditDB(t)
+	db.AutoMigrate(&models.DiagnosisFailure{})
+
+	reg := services.NewDiagnosisRegistry()
+	worker := workers.NewLLMWorker(ml.URL)
+	worker.Diagnoses = reg
+	worker.Failures = services.NewDiagnosisFailureService(db)
+	worker.RetryBackoff = time.Millisecond
+
+	stale := reg.Next(62)
+	reg.Next(62)
+	worker.Process(models.DiagnosisRequest{Patient: models.PatientData{ID: 62}, Generation: stale})
+	var failures int64
+	db.Model(&models.DiagnosisFailure{}).Count(&failures)
+	if calls.Load() != 1 || failures != 0 {
+		t.Errorf("Expected one attempt and nothing dead-lettered, got %d calls and %d failures", calls.Load(), failures)
+	}
+}
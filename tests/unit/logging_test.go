@@ -0,0 +1,100 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/logging"
+)
+
+// TestBackend_LogsThroughSlog keeps unstructured logging out of the
+// backend: log.Printf and friends bypass LOG_LEVEL and the request ID, and
+// fmt.Print writes plain lines Loki can't parse
+func TestBackend_LogsThroughSlog(t *testing.T) {
+	banned := map[string]func(string) bool{
+		"log": func(fn string) bool {
+			return strings.HasPrefix(fn, "Print") || strings.HasPrefix(fn, "Fatal") || strings.HasPrefix(fn, "Panic")
+		},
+		"fmt": func(fn string) bool { return strings.HasPrefix(fn, "Print") },
+	}
+	fset := token.NewFileSet()
+	err := filepath.WalkDir("../../backend", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return err
+		}
+		file, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return err
+		}
+		imported := map[string]string{} // local name -> package path
+		for _, imp := range file.Imports {
+			pkg, _ := strconv.Unquote(imp.Path.Value)
+			name := filepath.Base(pkg)
+			if imp.Name != nil {
+				name = imp.Name.Name
+			}
+			imported[name] = pkg
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			sel, ok := n.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			if x, ok := sel.X.(*ast.Ident); ok {
+				if isBanned, ok := banned[imported[x.Name]]; ok && isBanned(sel.Sel.Name) {
+					t.Errorf("%s: %s.%s, log through slog or pkg/logging instead", fset.Position(sel.Pos()), x.Name, sel.Sel.Name)
+				}
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walking the backend failed: %v", err)
+	}
+}
+
+func TestLogging_ParseLevel(t *testing.T) {
+	for in, want := range map[string]slog.Level{"debug": slog.LevelDebug, "INFO": slog.LevelInfo, "Warn": slog.LevelWarn, "error": slog.LevelError} {
+		if got, err := logging.ParseLevel(in); err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := logging.ParseLevel("verbose"); err == nil {
+		t.Error("Expected an unknown level to be rejected")
+	}
+}
+
+func TestLogging_JSONLinesCarryRequestID(t *testing.T) {
+	var out bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(logging.New(&out, slog.LevelInfo, false))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	ctx := auditctx.WithRequestID(context.Background(), "req-5")
+	logging.FromContext(ctx).Debug("below the level")
+	logging.FromContext(ctx).Info("patient saved", "patient_id", 12, "duration_ms", 40)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected only the info line, got %q", out.String())
+	}
+	var line map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &line); err != nil {
+		t.Fatalf("Expected a JSON line, got %q", lines[0])
+	}
+	if line["msg"] != "patient saved" || line["level"] != "INFO" || line["request_id"] != "req-5" || line["patient_id"] != float64(12) {
+		t.Errorf("Unexpected line %v", line)
+	}
+}
@@ -0,0 +1,106 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestMedicationService_SeedKeepsEditsAndLoads(t *testing.T) {
+	db := setupTestDB(t)
+	db.AutoMigrate(&models.DrugInteraction{}, &models.DrugAlias{})
+	svc := services.NewMedicationService(db)
+	if err := svc.Seed(); err != nil {
+		t.Fatalf("Seed failed: %v", err)
+	}
+
+	// An admin downgrades one row and adds an alias; reseeding leaves both alone
+	db.Model(&models.DrugInteraction{}).Where("drug_a = ? AND drug_b = ?", "Atorvastatin", "Amlodipine").Update("severity", services.SeverityModerate)
+	db.Create(&models.DrugAlias{Alias: "caduet", Generic: "Amlodipine"})
+	if err := svc.Seed(); err != nil {
+		t.Fatalf("Reseed failed: %v", err)
+	}
+	var count int64
+	db.Model(&models.DrugInteraction{}).Count(&count)
+	if int(count) != len(services.DefaultDrugInteractions) {
+		t.Errorf("Expected %d interactions after reseeding, got %d", len(services.DefaultDrugInteractions), count)
+	}
+
+	checker, err := svc.Checker()
+	if err != nil {
+		t.Fatalf("Checker failed: %v", err)
+	}
+	result := checker.Check("Lipitor, Caduet")
+	if len(result.Interactions) != 1 || result.Interactions[0].Severity != services.SeverityModerate {
+		t.Errorf("Expected the edited row and alias used, got %+v", result.Interactions)
+	}
+}
+
+func TestCheckAllergies_PartialCaseAndClassMatches(t *testing.T) {
+	service := &services.PredictionService{}
+
+	cases := []struct {
+		meds, allergies string
+		want            []string // Conflicting medications as entered
+	}{
+		{"Amoxicillin 500mg, Lisinopril", "Penicillin", []string{"Amoxicillin 500mg"}}, // Cross-reacting class
+		{"AMOXICILLIN", "penicillins", []string{"AMOXICILLIN"}},                        // Plural class, any case
+		{"Penicillin V", "penicillin", []string{"Penicillin V"}},                       // Case variant
+		{"Amoxicillin", "amoxicil", []string{"Amoxicillin"}},                           // Partial allergy name
+		{"Advil", "Ibuprofen", []string{"Advil"}},                                      // Same generic via aliases
+		{"Metformin, Atorvastatin", "Sulfa, Latex", []string{}},                        // Unrelated
+		{"Aspirin", "pen", []string{}},                                                 // Too short to match
+	}
+	for _, c := range cases {
+		got := service.CheckAllergies(c.meds, c.allergies)
+		var meds []string
+		for _, conflict := range got {
+			meds = append(meds, conflict.Medication)
+			if conflict.Reason == "" {
+				t.Errorf("%q vs %q: conflict without a reason", c.meds, c.allergies)
+			}
+		}
+		if len(meds) != len(c.want) || (len(meds) > 0 && meds[0] != c.want[0]) {
+			t.Errorf("%q vs %q: expected %v, got %v", c.meds, c.allergies, c.want, meds)
+		}
+	}
+}
+
+// TestAssess_AllergyConflictRequiresReview tests that an allergy conflict is
+// surfaced for review without being treated as an emergency
+func TestAssess_AllergyConflictRequiresReview(t *testing.T) {
+	var predictHits atomic.Int64
+	h, _, _ := newTestPatientHandler(t, newFakeFullML(t, &predictHits).URL, handlers.NewWebSocketHandler())
+	app := fiber.New()
+	app.Post("/api/assess", h.AssessPatient)
+
+	body := `{"age":45,"gender":"Female","systolic_bp":120,"diastolic_bp":80,"glucose":95,"bmi":24,
+		"medications":"amoxicillin 500mg, Lisinopril","allergies":"Penicillin"}`
+	req := httptest.NewRequest("POST", "/api/assess", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, 5000)
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("Assess failed: %v %v", err, resp)
+	}
+	var result models.FullAssessmentResponse
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	if !result.RequiresReview || result.Emergency {
+		t.Errorf("Expected review without emergency, got requires_review=%v emergency=%v", result.RequiresReview, result.Emergency)
+	}
+	conflicts := result.Medications.AllergyConflicts
+	if len(conflicts) != 1 || conflicts[0].Medication != "amoxicillin 500mg" || conflicts[0].Allergy != "Penicillin" {
+		t.Errorf("Expected amoxicillin vs penicillin, got %+v", conflicts)
+	}
+	if result.Patient.Allergies != "Penicillin" {
+		t.Errorf("Expected allergies stored on the patient, got %q", result.Patient.Allergies)
+	}
+}
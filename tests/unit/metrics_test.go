@@ -0,0 +1,55 @@
+package unit
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/workers"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func TestMetrics_ExportedAfterAssessment(t *testing.T) {
+	var hits atomic.Int64
+	h, _, pred := newTestPatientHandler(t, newFakeFullML(t, &hits).URL, handlers.NewWebSocketHandler())
+	reg := prometheus.NewRegistry()
+	if err := pred.RegisterMetrics(reg); err != nil {
+		t.Fatalf("Failed to register prediction metrics: %v", err)
+	}
+	if err := workers.NewLLMWorker("http://ml.invalid").RegisterMetrics(reg); err != nil {
+		t.Fatalf("Failed to register LLM queue metrics: %v", err)
+	}
+
+	app := fiber.New()
+	app.Post("/api/assess", h.AssessPatient)
+	app.Get("/metrics", adaptor.HTTPHandler(promhttp.HandlerFor(reg, promhttp.HandlerOpts{})))
+
+	if status, body := assessWith(t, app, nil); status != 200 {
+		t.Fatalf("Assessment failed with %d: %v", status, body)
+	}
+	resp, err := app.Test(httptest.NewRequest("GET", "/metrics", nil))
+	if err != nil {
+		t.Fatalf("Scrape failed: %v", err)
+	}
+	raw, _ := io.ReadAll(resp.Body)
+	scrape := string(raw)
+
+	for _, series := range []string{
+		`healthcare_prediction_cache_lookups_total{result="miss"} 1`,
+		`healthcare_ml_predict_duration_seconds_count{backend="primary",outcome="ok"} 1`,
+		`healthcare_ml_circuit_breaker_state{backend="primary"} 0`,
+		`healthcare_llm_queue_backlog{priority="emergency"} 0`,
+		`healthcare_llm_queue_backlog{priority="routine"} 0`,
+	} {
+		if !strings.Contains(scrape, series) {
+			t.Errorf("Expected %s in the scrape, got:\n%s", series, scrape)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package unit
+
+import (
+	"errors"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"healthcare-backend/pkg/database"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupMigrationDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	return db
+}
+
+func loadMigrations(t *testing.T, files fstest.MapFS) []database.Migration {
+	migs, err := database.LoadMigrations(files)
+	if err != nil {
+		t.Fatalf("LoadMigrations failed: %v", err)
+	}
+	return migs
+}
+
+func TestMigrator_AppliesInOrderOnce(t *testing.T) {
+	db := setupMigrationDB(t)
+	files := fstest.MapFS{
+		"0002_add_notes.sql": {Data: []byte("ALTER TABLE widgets ADD COLUMN notes text;")},
+		"0001_widgets.sql":   {Data: []byte("CREATE TABLE widgets (id integer PRIMARY KEY);")},
+	}
+
+	m := database.NewMigrator(db, loadMigrations(t, files))
+	applied, err := m.Up()
+	if err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if len(applied) != 2 || applied[0] != 1 || applied[1] != 2 {
+		t.Fatalf("Expected [1 2] applied, got %v", applied)
+	}
+	if !db.Migrator().HasColumn("widgets", "notes") {
+		t.Error("Expected widgets.notes to exist")
+	}
+
+	applied, err = m.Up()
+	if err != nil || len(applied) != 0 {
+		t.Errorf("Expected second Up to be a no-op, got %v, %v", applied, err)
+	}
+	status, _ := m.Status()
+	if status.Current != 2 || status.Supported != 2 || len(status.Pending) != 0 {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+}
+
+func TestMigrator_RefusesOutOfOrder(t *testing.T) {
+	db := setupMigrationDB(t)
+	first := fstest.MapFS{
+		"0001_widgets.sql": {Data: []byte("CREATE TABLE widgets (id integer PRIMARY KEY);")},
+		"0003_gadgets.sql": {Data: []byte("CREATE TABLE gadgets (id integer PRIMARY KEY);")},
+	}
+	if _, err := database.NewMigrator(db, loadMigrations(t, first)).Up(); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+
+	// A branch that merged later added 0002
+	first["0002_late.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE late (id integer PRIMARY KEY);")}
+	_, err := database.NewMigrator(db, loadMigrations(t, first)).Up()
+	if !errors.Is(err, database.ErrMigrationOutOfOrder) {
+		t.Fatalf("Expected ErrMigrationOutOfOrder, got %v", err)
+	}
+	if db.Migrator().HasTable("late") {
+		t.Error("Out-of-order migration should not have run")
+	}
+}
+
+func TestMigrator_DetectsEditedMigration(t *testing.T) {
+	db := setupMigrationDB(t)
+	files := fstest.MapFS{"0001_widgets.sql": {Data: []byte("CREATE TABLE widgets (id integer PRIMARY KEY);")}}
+	if _, err := database.NewMigrator(db, loadMigrations(t, files)).Up(); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+
+	files["0001_widgets.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE widgets (id integer PRIMARY KEY, name text);")}
+	m := database.NewMigrator(db, loadMigrations(t, files))
+
+	status, err := m.CheckCompatibility()
+	if err != nil {
+		t.Fatalf("An edited migration should only warn at startup, got %v", err)
+	}
+	if len(status.Edited) != 1 || status.Edited[0] != 1 || len(status.Warnings) == 0 {
+		t.Errorf("Expected version 1 reported as edited, got %+v", status)
+	}
+	if _, err := m.Up(); !errors.Is(err, database.ErrMigrationChecksum) {
+		t.Errorf("Expected ErrMigrationChecksum from Up, got %v", err)
+	}
+}
+
+func TestMigrator_RefusesNewerSchema(t *testing.T) {
+	db := setupMigrationDB(t)
+	newer := fstest.MapFS{
+		"0001_widgets.sql": {Data: []byte("CREATE TABLE widgets (id integer PRIMARY KEY);")},
+		"0002_notes.sql":   {Data: []byte("ALTER TABLE widgets ADD COLUMN notes text;")},
+	}
+	if _, err := database.NewMigrator(db, loadMigrations(t, newer)).Up(); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+
+	// An older binary that only knows 0001
+	older := fstest.MapFS{"0001_widgets.sql": newer["0001_widgets.sql"]}
+	status, err := database.NewMigrator(db, loadMigrations(t, older)).CheckCompatibility()
+	if !errors.Is(err, database.ErrSchemaTooNew) {
+		t.Fatalf("Expected ErrSchemaTooNew, got %v", err)
+	}
+	if len(status.Unknown) != 1 || status.Unknown[0] != 2 {
+		t.Errorf("Expected version 2 reported as unknown, got %+v", status)
+	}
+}
+
+func TestMigrator_StatusDoesNotCreateTable(t *testing.T) {
+	db := setupMigrationDB(t)
+	status, err := database.NewMigrator(db, database.EmbeddedMigrations()).Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.Current != 0 || len(status.Pending) == 0 {
+		t.Errorf("Expected a fresh database to have everything pending, got %+v", status)
+	}
+	if db.Migrator().HasTable(&database.SchemaMigration{}) {
+		t.Error("Status should not create the migrations table")
+	}
+}
+
+// The embedded migrations must produce every table and column the models
+// expect, or the app would fail at runtime after a clean --migrate
+// TestEmbeddedPostgresMigrations_MirrorSQLite keeps the two sets in step:
+// each version has a twin naming the same tables, columns and indexes
+func TestEmbeddedPostgresMigrations_MirrorSQLite(t *testing.T) {
+	sqliteIdent := regexp.MustCompile("`(\\w+)`")
+	postgresIdent := regexp.MustCompile(`"(\w+)"`)
+	idents := func(re *regexp.Regexp, sql string) []string {
+		var out []string
+		for _, m := range re.FindAllStringSubmatch(sql, -1) {
+			out = append(out, m[1])
+		}
+		return out
+	}
+
+	lite, pg := database.EmbeddedMigrations(), database.EmbeddedPostgresMigrations()
+	if len(pg) != len(lite) {
+		t.Fatalf("Expected %d Postgres migrations, got %d", len(lite), len(pg))
+	}
+	for i, m := range lite {
+		if pg[i].Version != m.Version || pg[i].Name != m.Name {
+			t.Errorf("Expected a Postgres twin of %d_%s, got %d_%s", m.Version, m.Name, pg[i].Version, pg[i].Name)
+			continue
+		}
+		if want, got := idents(sqliteIdent, m.SQL), idents(postgresIdent, pg[i].SQL); !slices.Equal(got, want) {
+			t.Errorf("Migration %d: expected Postgres to touch %v, got %v", m.Version, want, got)
+		}
+	}
+}
+
+func TestDatabaseConnect_AppliesPoolSettings(t *testing.T) {
+	db, err := database.Connect(database.Settings{Driver: database.DriverSQLite, Path: filepath.Join(t.TempDir(), "pool.db"), MaxOpenConns: 3})
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	defer sqlDB.Close()
+	if n := sqlDB.Stats().MaxOpenConnections; n != 3 {
+		t.Errorf("Expected at most 3 open connections, got %d", n)
+	}
+
+	if _, err := database.Connect(database.Settings{Driver: "mysql"}); err == nil {
+		t.Error("Expected an unknown driver to be refused")
+	}
+}
+
+func TestDatabaseSettings_PostgresDSN(t *testing.T) {
+	s := database.Settings{Host: "db", Port: "5432", User: "postgres", Password: "secret", Name: "healthcare", SSLMode: "require"}
+	if dsn := s.PostgresDSN(); dsn != "host=db port=5432 user=postgres password=secret dbname=healthcare sslmode=require TimeZone=UTC" {
+		t.Errorf("Unexpected DSN %q", dsn)
+	}
+}
+
+func TestEmbeddedMigrations_CoverModels(t *testing.T) {
+	db := setupMigrationDB(t)
+	if _, err := database.NewMigrator(db, database.EmbeddedMigrations()).Up(); err != nil {
+		t.Fatalf("Embedded migrations failed: %v", err)
+	}
+
+	for _, model := range database.Models() {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatalf("Parse %T failed: %v", model, err)
+		}
+		if !db.Migrator().HasTable(model) {
+			t.Errorf("Missing table %s", stmt.Schema.Table)
+			continue
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" || field.IgnoreMigration {
+				continue
+			}
+			if !db.Migrator().HasColumn(model, field.DBName) {
+				t.Errorf("Missing column %s.%s", stmt.Schema.Table, field.DBName)
+			}
+		}
+	}
+}
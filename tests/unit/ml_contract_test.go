@@ -0,0 +1,203 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// contractPatient matches the patient in testdata/ml_contract
+var contractPatient = models.PatientData{
+	Age: 58, Gender: "Male", SystolicBP: 145, DiastolicBP: 90, Glucose: 130, BMI: 29.4,
+	Cholesterol: 240, HeartRate: 82, Steps: 3000, Smoking: "Yes", Alcohol: "No", Medications: "Metformin",
+	HistoryHeartDisease: "No", HistoryStroke: "No", HistoryDiabetes: "Yes", HistoryHighChol: "Yes",
+	Symptoms: "chest pain, fatigue",
+}
+
+// contractCase pairs an ML endpoint with the backend's real request payload
+// and the struct its response is decoded into
+type contractCase struct {
+	endpoint string
+	fixture  string
+	request  func(t *testing.T) []byte
+	response func() any
+}
+
+func mustMarshal(t *testing.T, v any) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	return data
+}
+
+var contractCases = []contractCase{
+	{"/predict", "predict", func(t *testing.T) []byte {
+		return services.BuildPredictPayload(contractPatient)
+	}, func() any { return &models.PredictResponse{} }},
+	{"/urgency/predict", "urgency_predict", func(t *testing.T) []byte {
+		return services.BuildUrgencyPayload([]string{"chest pain"}, contractPatient)
+	}, func() any { return &models.UrgencyResponse{} }},
+	{"/disease/predict", "disease_predict", func(t *testing.T) []byte {
+		return mustMarshal(t, models.DiseaseRequest{Symptoms: []string{"chest pain", "shortness of breath"}, PatientID: "42"})
+	}, func() any { return &models.DiseaseResponse{} }},
+	{"/ekg/analyze", "ekg_analyze", func(t *testing.T) []byte {
+		return mustMarshal(t, models.EKGRequest{Signal: []float64{0.01, 0.02, 0.85, 0.03}, SamplingRate: 360, PatientID: 42})
+	}, func() any { return &models.EKGResponse{} }},
+	{"/diagnose", "diagnose", func(t *testing.T) []byte {
+		p := contractPatient
+		p.ID = 42
+		risks := models.PredictResponse{
+			HeartRisk:       77.12,
+			ModelPrecisions: map[string]float64{"XGBoost Heart": 90.8},
+			Explanations:    map[string]map[string]float64{"heart": {"age": 0.21}},
+		}
+		return mustMarshal(t, models.DiagnosisRequest{Patient: p, RiskScores: risks, PastContext: "redacted", Generation: 3})
+	}, func() any { return &models.DiagnosisResponse{} }},
+}
+
+// keySkeleton re-encodes a JSON document with every leaf value nulled and
+// arrays cut to their first element, so two documents with the same keys at
+// every level produce identical bytes
+func keySkeleton(t *testing.T, data []byte) []byte {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	var strip func(any) any
+	strip = func(v any) any {
+		switch x := v.(type) {
+		case map[string]any:
+			for k, e := range x {
+				x[k] = strip(e)
+			}
+			return x
+		case []any:
+			if len(x) == 0 {
+				return x
+			}
+			return []any{strip(x[0])}
+		}
+		return nil
+	}
+	out, _ := json.MarshalIndent(strip(v), "", "  ") // Map keys marshal sorted
+	return out
+}
+
+func topLevelKeys(t *testing.T, data []byte) []string {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("Invalid JSON object: %v", err)
+	}
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func readContractFixture(t *testing.T, name string) []byte {
+	data, err := os.ReadFile(filepath.Join("testdata", "ml_contract", name))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	return data
+}
+
+// TestMLContract_RequestKeysMatchFixtures builds each request through the
+// backend's marshalling code and compares its key layout with the fixture
+func TestMLContract_RequestKeysMatchFixtures(t *testing.T) {
+	for _, tc := range contractCases {
+		t.Run(tc.fixture, func(t *testing.T) {
+			payload := tc.request(t)
+			fixture := readContractFixture(t, tc.fixture+".request.json")
+
+			if got, want := keySkeleton(t, payload), keySkeleton(t, fixture); !bytes.Equal(got, want) {
+				t.Errorf("Request keys drifted from the fixture.\ngot:\n%s\nwant:\n%s", got, want)
+			}
+
+			want := append([]string(nil), services.MLContracts[tc.endpoint].Request...)
+			sort.Strings(want)
+			if got := topLevelKeys(t, payload); strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("MLContracts lists %v, payload sends %v", want, got)
+			}
+		})
+	}
+}
+
+// TestMLContract_ResponsesReadBack checks each fixture response satisfies the
+// contract and that every expected key survives decoding into the Go struct
+func TestMLContract_ResponsesReadBack(t *testing.T) {
+	for _, tc := range contractCases {
+		t.Run(tc.fixture, func(t *testing.T) {
+			fixture := readContractFixture(t, tc.fixture+".response.json")
+			contract := services.MLContracts[tc.endpoint]
+
+			if unknown, missing := contract.CheckResponse(fixture); len(unknown) > 0 || len(missing) > 0 {
+				t.Errorf("Fixture violates the contract: unknown %v, missing %v", unknown, missing)
+			}
+
+			decoded := tc.response()
+			if err := json.Unmarshal(fixture, decoded); err != nil {
+				t.Fatalf("Failed to decode fixture: %v", err)
+			}
+			var readBack map[string]json.RawMessage
+			json.Unmarshal(mustMarshal(t, decoded), &readBack)
+			for _, k := range contract.Response {
+				if v, ok := readBack[k]; !ok || string(v) == "null" {
+					t.Errorf("Key %q was lost decoding into %T", k, decoded)
+				}
+			}
+		})
+	}
+}
+
+// TestMLContract_StrictModeCountsViolations tests the runtime check on a drifted /predict response
+func TestMLContract_StrictModeCountsViolations(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"heartRiskScore": 77, "diabetes_risk_score": 64, "stroke_risk_score": 21,
+			"general_health_score": 56, "clinical_confidence": 85, "model_precisions": {}, "explanations": {}}`))
+	}))
+	defer srv.Close()
+
+	reg := prometheus.NewRegistry()
+	monitor := services.NewContractMonitor()
+	if err := monitor.Register(reg); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	service := services.NewPredictionService(srv.URL)
+	service.SetContractMonitor(monitor)
+
+	if _, err := service.PredictRisks(models.PatientData{ID: 9001, Age: 40, SystolicBP: 120}); err != nil {
+		t.Fatalf("Prediction failed: %v", err)
+	}
+
+	families, _ := reg.Gather()
+	counts := map[string]float64{}
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			kind := ""
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "kind" {
+					kind = l.GetValue()
+				}
+			}
+			counts[kind] += m.GetCounter().GetValue()
+		}
+	}
+	if counts["unknown"] != 1 || counts["missing"] != 1 {
+		t.Errorf("Expected 1 unknown and 1 missing key, got %v", counts)
+	}
+}
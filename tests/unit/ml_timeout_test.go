@@ -0,0 +1,123 @@
+package unit
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+	"healthcare-backend/pkg/workers"
+)
+
+// newHungML is an ML service that never answers, until the caller gives up
+func newHungML(t *testing.T) *httptest.Server {
+	ml := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body) // Only then does the server notice the caller hanging up
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	t.Cleanup(ml.Close)
+	return ml
+}
+
+// within fails unless call returns before limit
+func within(t *testing.T, limit time.Duration, what string, call func()) {
+	t.Helper()
+	start := time.Now()
+	call()
+	if took := time.Since(start); took > limit {
+		t.Errorf("Expected %s to give up within %v, took %v", what, limit, took)
+	}
+}
+
+func TestPredictRisks_ClientTimeoutFallsBackToRules(t *testing.T) {
+	pred := services.NewPredictionService(newHungML(t).URL)
+	pred.SetClient(services.NewMLClient(50 * time.Millisecond))
+
+	var risks *models.PredictResponse
+	var err error
+	within(t, time.Second, "the prediction", func() {
+		risks, err = pred.PredictRisks(models.PatientData{ID: 41, Age: 50, SystolicBP: 130})
+	})
+	if err != nil || risks.Source != models.PredictionSourceRuleBased {
+		t.Fatalf("Expected the rule-based fallback, got %+v (%v)", risks, err)
+	}
+	if counts := pred.Primary.CB.Counts(); counts.TotalFailures != 1 {
+		t.Errorf("Expected the timeout to count against the breaker, got %+v", counts)
+	}
+}
+
+func TestPredictionService_CallsAbortAtContextDeadline(t *testing.T) {
+	pred := services.NewPredictionService(newHungML(t).URL)
+	pred.SetClient(services.NewMLClient(0)) // Only the deadline bounds the calls
+
+	calls := map[string]func(context.Context) error{
+		"PredictRisksCtx": func(ctx context.Context) error {
+			_, err := pred.PredictRisksCtx(ctx, models.PatientData{ID: 42})
+			return err
+		},
+		"PredictUrgencyCtx": func(ctx context.Context) error {
+			_, err := pred.PredictUrgencyCtx(ctx, []string{"chest pain"}, models.PatientData{})
+			return err
+		},
+		"PredictDiseaseCtx": func(ctx context.Context) error {
+			_, err := pred.PredictDiseaseCtx(ctx, models.DiseaseRequest{Symptoms: []string{"cough"}})
+			return err
+		},
+		"AnalyzeEKGCtx": func(ctx context.Context) error {
+			_, err := pred.AnalyzeEKGCtx(ctx, models.EKGRequest{Signal: []float64{0.1, 0.2}})
+			return err
+		},
+	}
+	for name, call := range calls {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		var err error
+		within(t, time.Second, name, func() { err = call(ctx) })
+		cancel()
+		if err == nil {
+			t.Errorf("Expected %s to fail at the deadline", name)
+		}
+	}
+}
+
+func TestDirectDiagnosis_TimesOut(t *testing.T) {
+	pred := services.NewPredictionService(newHungML(t).URL)
+	pred.LLMTimeout = 50 * time.Millisecond
+
+	done := make(chan [2]string, 1)
+	start := time.Now()
+	// NATS isn't running, so the diagnosis is made directly
+	pred.StartAsyncDiagnosis(43, models.DiagnosisRequest{}, func(_ uint, diagnosis, status string) {
+		done <- [2]string{diagnosis, status}
+	})
+
+	select {
+	case got := <-done:
+		if got[0] != services.DiagnosisTimedOut || got[1] != "error" {
+			t.Errorf("Expected the diagnosis to time out, got %q/%q", got[0], got[1])
+		}
+		if took := time.Since(start); took > time.Second {
+			t.Errorf("Expected the diagnosis to give up at its deadline, took %v", took)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("The direct diagnosis never gave up")
+	}
+	if diagnosis, status := pred.Cache.Get(43); diagnosis != services.DiagnosisTimedOut || status != "error" {
+		t.Errorf("Expected the timeout to be cached, got %q/%q", diagnosis, status)
+	}
+}
+
+func TestLLMWorker_ClientTimeoutEndsAttempt(t *testing.T) {
+	worker := workers.NewLLMWorker(newHungML(t).URL)
+	worker.Client = services.NewMLClient(50 * time.Millisecond)
+	worker.MaxRetries = 0
+	within(t, time.Second, "the worker", func() {
+		worker.Process(models.DiagnosisRequest{Patient: models.PatientData{ID: 44}})
+	})
+}
@@ -0,0 +1,70 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+)
+
+func TestModelPerformance_Report(t *testing.T) {
+	_, db := newOverrideService(t)
+	db.AutoMigrate(&models.Assessment{}, &models.AssessmentPrecision{})
+
+	week1 := time.Date(2026, 3, 3, 10, 0, 0, 0, time.UTC) // A Tuesday
+	week2 := week1.AddDate(0, 0, 7)
+	for _, a := range []models.Assessment{
+		{CreatedAt: week1, Precisions: []models.AssessmentPrecision{{CreatedAt: week1, ModelName: "Heart_Model", Confidence: 0.8}, {CreatedAt: week1, ModelName: "Diabetes_Model", Confidence: 0.9}}},
+		{CreatedAt: week2, Precisions: []models.AssessmentPrecision{{CreatedAt: week2, ModelName: "Heart_Model", Confidence: 0.6}}},
+		{CreatedAt: week2, RuleBased: true, Precisions: []models.AssessmentPrecision{{CreatedAt: week2, ModelName: "Heart_Model", RuleBased: true}}},
+	} {
+		db.Create(&a)
+	}
+	for _, at := range []time.Time{week1, week1, week2, week2} {
+		db.Create(&models.Feedback{CreatedAt: at})
+	}
+	for _, o := range []models.OverrideLog{
+		{CreatedAt: week1, ModelName: "Heart_Model", ModelsOverridden: []string{"Heart_Model", "Stroke_Model"}},
+		{CreatedAt: week2, ModelName: "Heart_Model", ModelsOverridden: []string{"Heart_Model"}},
+		{CreatedAt: week2, ModelName: services.OverrideModelUnspecified}, // Legacy row
+	} {
+		db.Create(&o)
+	}
+
+	report, err := services.NewModelPerformanceService(db).Report(week1.AddDate(0, 0, -1))
+	if err != nil {
+		t.Fatalf("Report failed: %v", err)
+	}
+	byName := map[string]models.ModelPerformance{}
+	var names []string
+	for _, m := range report.Models {
+		byName[m.ModelName] = m
+		names = append(names, m.ModelName)
+	}
+	if len(names) != 5 || names[0] != "Heart_Model" || names[4] != services.OverrideModelUnspecified {
+		t.Fatalf("Expected the four risk models first, then the legacy row, got %v", names)
+	}
+
+	heart := byName["Heart_Model"]
+	if heart.Assessments != 3 || *heart.AvgConfidence != 0.7 || heart.Reviews != 4 || heart.Overrides != 2 || heart.Approvals != 2 || *heart.AcceptanceRate != 0.5 {
+		t.Errorf("Unexpected heart performance: %+v", heart)
+	}
+	if len(heart.Weekly) != 2 || *heart.Weekly[0].AcceptanceRate != 0.5 || heart.Weekly[1].Overrides != 1 || !heart.Weekly[1].WeekStart.Equal(time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected heart weekly series: %+v", heart.Weekly)
+	}
+	if stroke := byName["Stroke_Model"]; stroke.Assessments != 0 || stroke.AvgConfidence != nil || stroke.Overrides != 1 || *stroke.AcceptanceRate != 0.75 {
+		t.Errorf("Unexpected stroke performance: %+v", stroke)
+	}
+	if kidney := byName["Kidney_Model"]; kidney.Overrides != 0 || *kidney.AcceptanceRate != 1 {
+		t.Errorf("Expected an untouched model fully accepted, got %+v", kidney)
+	}
+
+	check, err := services.NewModelPerformanceService(db).Check(services.NewDriftService(db, 0.05), week2.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if len(check.Models) != 5 || check.Models[0].Weekly != nil || check.Alerts == nil {
+		t.Errorf("Expected a compact check record, got %+v", check)
+	}
+}
@@ -0,0 +1,127 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/jobs"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNoteEmbeddingService_EmbedAndVectors(t *testing.T) {
+	db := openRAGDB(t)
+	client := new(MockEmbeddingClient)
+	svc := services.NewNoteEmbeddingService(db, client, "model-a", nil)
+	fb := models.Feedback{PatientID: 1, DoctorApproved: true, DoctorNotes: "Persistent dry cough"}
+	db.Create(&fb)
+
+	client.On("Embed", mock.Anything, []string{fb.DoctorNotes}).Return([][]float32{{1, 2, 3}}, nil).Once()
+	client.On("Embed", mock.Anything, []string{fb.DoctorNotes}).Return([][]float32{{4, 5, 6}}, nil).Once()
+	assert.NoError(t, svc.Embed(context.Background(), fb))
+	assert.NoError(t, svc.Embed(context.Background(), fb))
+
+	var rows int64
+	db.Model(&models.NoteEmbedding{}).Count(&rows)
+	assert.Equal(t, int64(1), rows, "Re-embedding replaces the vector")
+	vectors, err := svc.Vectors([]uint{fb.ID, 999})
+	assert.NoError(t, err)
+	assert.Equal(t, map[uint][]float32{fb.ID: {4, 5, 6}}, vectors)
+
+	// Another model's vectors aren't comparable
+	other := services.NewNoteEmbeddingService(db, client, "model-b", nil)
+	vectors, err = other.Vectors([]uint{fb.ID})
+	assert.NoError(t, err)
+	assert.Empty(t, vectors)
+
+	// Rejected feedback and blank notes are never retrieved, so never embedded
+	assert.NoError(t, svc.Embed(context.Background(), models.Feedback{ID: 7, DoctorNotes: "Rejected"}))
+	assert.NoError(t, svc.Embed(context.Background(), models.Feedback{ID: 8, DoctorApproved: true, DoctorNotes: "  "}))
+	client.AssertNumberOfCalls(t, "Embed", 2)
+}
+
+func TestNoteEmbeddingService_IndexAndBackfill(t *testing.T) {
+	db := openRAGDB(t)
+	client := new(MockEmbeddingClient)
+	runner := jobs.NewRunner("note-embeddings-test", 1, 8, 0)
+	defer runner.Stop()
+	svc := services.NewNoteEmbeddingService(db, client, "model-a", runner)
+
+	submitted := models.Feedback{PatientID: 1, DoctorApproved: true, DoctorNotes: "Orthopnea and ankle edema"}
+	db.Create(&submitted)
+	client.On("Embed", mock.Anything, []string{submitted.DoctorNotes}).Return([][]float32{{1, 0}}, nil).Once()
+	svc.Index(submitted)
+	assert.Eventually(t, func() bool {
+		var n int64
+		db.Model(&models.NoteEmbedding{}).Where("feedback_id = ?", submitted.ID).Count(&n)
+		return n == 1
+	}, time.Second, 10*time.Millisecond, "Submitted notes are embedded in the background")
+
+	// Saved before embeddings were configured
+	older := []models.Feedback{
+		{PatientID: 2, DoctorApproved: true, DoctorNotes: "Nocturnal polyuria"},
+		{PatientID: 3, DoctorApproved: false, DoctorNotes: "Not a useful case"},
+		{PatientID: 4, DoctorApproved: true, DoctorNotes: "Intermittent claudication"},
+	}
+	for i := range older {
+		db.Create(&older[i])
+	}
+	client.On("Embed", mock.Anything, []string{"Nocturnal polyuria", "Intermittent claudication"}).
+		Return([][]float32{{0, 1}, {1, 1}}, nil).Once()
+	n, err := svc.Backfill(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n, "Only approved notes missing a vector are embedded")
+	if n, _ := svc.Backfill(context.Background()); n != 0 {
+		t.Errorf("Expected a second backfill to find nothing, got %d", n)
+	}
+
+	// A client answering the wrong number of texts is an error, not a panic
+	changed := services.NewNoteEmbeddingService(db, client, "model-b", nil)
+	client.On("Embed", mock.Anything, mock.Anything).Return([][]float32{{1, 0}}, nil).Once()
+	_, err = changed.Backfill(context.Background())
+	assert.Error(t, err)
+	client.AssertExpectations(t)
+}
+
+func TestOpenAIEmbeddingClient(t *testing.T) {
+	var got struct {
+		Model string   `json:"model"`
+		Input []string `json:"input"`
+	}
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" {
+			http.NotFound(w, r)
+			return
+		}
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+		if len(got.Input) == 0 {
+			http.Error(w, `{"error":"input is required"}`, http.StatusBadRequest)
+			return
+		}
+		// Out of order, as the API allows
+		w.Write([]byte(`{"data":[{"index":1,"embedding":[0.5,0.5]},{"index":0,"embedding":[1,0]}]}`))
+	}))
+	defer srv.Close()
+
+	client := services.NewOpenAIEmbeddingClient(srv.URL+"/v1/", "text-embedding-3-small", "sk-test")
+	vectors, err := client.Embed(context.Background(), []string{"dyspnea", "syncope"})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]float32{{1, 0}, {0.5, 0.5}}, vectors)
+	assert.Equal(t, "text-embedding-3-small", got.Model)
+	assert.Equal(t, []string{"dyspnea", "syncope"}, got.Input)
+	assert.Equal(t, "Bearer sk-test", auth)
+
+	_, err = client.Embed(context.Background(), []string{})
+	assert.ErrorContains(t, err, "400")
+	_, err = client.Embed(context.Background(), []string{"a", "b", "c"})
+	assert.ErrorContains(t, err, "missing input 2")
+}
@@ -0,0 +1,164 @@
+package unit
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/middleware"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/repositories"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestOverride_AnalyticsGroupsAndRange(t *testing.T) {
+	svc, db := newOverrideService(t)
+	db.AutoMigrate(&models.Assessment{})
+
+	jan := time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC)
+	feb := time.Date(2026, 2, 10, 10, 0, 0, 0, time.UTC)
+	for _, at := range []time.Time{jan, jan, jan, jan, feb, feb, feb, feb} {
+		db.Create(&models.Assessment{CreatedAt: at})
+	}
+	rows := []models.OverrideLog{
+		{CreatedAt: jan, Reason: "Clinical Intuition", ModelName: "Heart_Model", ModelsOverridden: []string{"Heart_Model", "Stroke_Model"}, DoctorID: "user:1"},
+		{CreatedAt: jan.Add(time.Hour), Reason: "Data Quality Issue", ModelName: "Heart_Model", ModelsOverridden: []string{"Heart_Model"}, DoctorID: "user:2"},
+		{CreatedAt: feb, Reason: "Clinical Intuition", ModelName: "Diabetes_Model", DoctorID: "user:1"},
+		{CreatedAt: feb.Add(time.Hour), Reason: "Other (legacy)", ModelName: services.OverrideModelUnspecified}, // Migrated, no doctor
+	}
+	for i := range rows {
+		db.Create(&rows[i])
+	}
+
+	all, err := svc.Analytics(services.OverrideQuery{GroupBy: services.OverrideGroupReason, Recent: 3})
+	if err != nil {
+		t.Fatalf("Analytics failed: %v", err)
+	}
+	if all.Total != 4 || all.Assessments != 8 || all.Percent != 50 {
+		t.Errorf("Expected 4 overrides in 8 assessments, got %d in %d (%v%%)", all.Total, all.Assessments, all.Percent)
+	}
+	want := []models.OverrideGroup{{Key: "Clinical Intuition", Count: 2, Percent: 25}, {Key: "Data Quality Issue", Count: 1, Percent: 12.5}, {Key: "Other (legacy)", Count: 1, Percent: 12.5}}
+	if !equalGroups(all.Groups, want) {
+		t.Errorf("Expected %v, got %v", want, all.Groups)
+	}
+	if len(all.Recent) != 3 || all.Recent[0].ID != rows[3].ID || all.Recent[2].ID != rows[1].ID {
+		t.Errorf("Expected the three latest overrides, newest first, got %+v", all.Recent)
+	}
+
+	// Every overridden model counts, falling back to the single model name
+	to := feb
+	january, err := svc.Analytics(services.OverrideQuery{To: &to, GroupBy: services.OverrideGroupModel})
+	if err != nil {
+		t.Fatalf("Analytics failed: %v", err)
+	}
+	want = []models.OverrideGroup{{Key: "Heart_Model", Count: 2, Percent: 50}, {Key: "Stroke_Model", Count: 1, Percent: 25}}
+	if january.Total != 2 || january.Assessments != 4 || !equalGroups(january.Groups, want) || len(january.Recent) != 0 {
+		t.Errorf("Expected January's overrides per model, got %+v", january)
+	}
+
+	from := feb
+	byDoctor, _ := svc.Analytics(services.OverrideQuery{From: &from, GroupBy: services.OverrideGroupDoctor})
+	want = []models.OverrideGroup{{Key: "unknown", Count: 1, Percent: 25}, {Key: "user:1", Count: 1, Percent: 25}}
+	if !equalGroups(byDoctor.Groups, want) {
+		t.Errorf("Expected February's overrides per doctor, got %v", byDoctor.Groups)
+	}
+
+	if _, err := svc.Analytics(services.OverrideQuery{GroupBy: "month"}); !errors.Is(err, services.ErrInvalidOverride) {
+		t.Errorf("Expected ErrInvalidOverride for an unknown grouping, got %v", err)
+	}
+	empty, _ := svc.Analytics(services.OverrideQuery{From: &to, To: &from, GroupBy: services.OverrideGroupReason})
+	if empty.Total != 0 || empty.Percent != 0 || empty.Groups == nil {
+		t.Errorf("Expected an empty range to report zero groups, got %+v", empty)
+	}
+}
+
+func equalGroups(got, want []models.OverrideGroup) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestOverrideHandler_ComplianceRecordsDoctorAndExportsCSV(t *testing.T) {
+	db := openSearchDB(t)
+	overrides := services.NewOverrideService(db)
+	overrides.Seed()
+	audit := services.NewAuditService(db)
+	feedback := handlers.NewFeedbackHandler(db, repositories.NewFeedbackRepository(db), overrides, audit)
+	h := handlers.NewOverrideHandler(overrides, audit)
+	actors := map[string]auditctx.Identity{"house": doctorHouse, "wilson": doctorWilson, "admin": accessAdmin}
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		auditctx.Set(c, actors[c.Get("X-Test-Actor")])
+		return c.Next()
+	})
+	app.Post("/api/feedback", feedback.SubmitFeedback)
+	app.Get("/api/compliance/overrides", middleware.RequireRole(auditctx.RoleAdmin), h.GetCompliance)
+	send := func(actor, method, path, body string) (int, string) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Test-Actor", actor)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		out, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(out)
+	}
+
+	for i := 0; i < 4; i++ {
+		db.Create(&models.Assessment{PatientID: 1})
+	}
+	risks := `"risks": {"heart_risk_score": 72.5, "diabetes_risk_score": 18, "stroke_risk_score": 9, "kidney_risk_score": 5}`
+	for actor, model := range map[string]string{"house": "heart", "wilson": "stroke"} {
+		body := `{"assessment_id": 1, "approved": false, ` + risks + `, "override_details": {"reason_code": "clinical_intuition", "models_overridden": ["` + model + `"]}}`
+		if code, out := send(actor, "POST", "/api/feedback", body); code != 200 {
+			t.Fatalf("Expected the override recorded, got %d: %s", code, out)
+		}
+	}
+	var stored []models.OverrideLog
+	db.Order("doctor_id").Find(&stored)
+	if len(stored) != 2 || stored[0].DoctorID != doctorHouse.ID || stored[1].DoctorID != doctorWilson.ID {
+		t.Fatalf("Expected each override stored with its doctor, got %+v", stored)
+	}
+
+	code, out := send("admin", "GET", "/api/compliance/overrides?group_by=doctor&format=csv", "")
+	if code != 200 {
+		t.Fatalf("Expected 200, got %d: %s", code, out)
+	}
+	records, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatalf("Expected valid CSV, got %q: %v", out, err)
+	}
+	want := [][]string{{"doctor", "count", "percent_of_assessments"}, {"user:1", "1", "25.00"}, {"user:2", "1", "25.00"}, {"total", "2", "50.00"}}
+	if len(records) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, records)
+	}
+	for i := range want {
+		if strings.Join(records[i], ",") != strings.Join(want[i], ",") {
+			t.Errorf("Row %d: expected %v, got %v", i, want[i], records[i])
+		}
+	}
+
+	for _, query := range []string{"group_by=month", "format=xml", "from=yesterday", "to=2026-13-01", "recent=-1", "recent=101"} {
+		if code, body := send("admin", "GET", "/api/compliance/overrides?"+query, ""); code != 400 {
+			t.Errorf("Expected 400 for %s, got %d: %s", query, code, body)
+		}
+	}
+	if code, _ := send("house", "GET", "/api/compliance/overrides", ""); code != 403 {
+		t.Errorf("Expected 403 for a doctor, got %d", code)
+	}
+}
@@ -0,0 +1,154 @@
+package unit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newOverrideService(t *testing.T) (*services.OverrideService, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	db.AutoMigrate(&models.Feedback{}, &models.OverrideLog{}, &models.OverrideReason{})
+
+	svc := services.NewOverrideService(db)
+	if err := svc.Seed(); err != nil {
+		t.Fatalf("Seed failed: %v", err)
+	}
+	return svc, db
+}
+
+// TestOverride_Validation tests resolving overrides against the taxonomy
+func TestOverride_Validation(t *testing.T) {
+	svc, _ := newOverrideService(t)
+
+	tests := []struct {
+		name     string
+		override models.OverrideLog
+		wantCode string
+		wantErr  bool
+	}{
+		{"by code", models.OverrideLog{ReasonCode: "data_quality_issue"}, "data_quality_issue", false},
+		{"by label", models.OverrideLog{Reason: "clinical intuition"}, "clinical_intuition", false},
+		{"other with text", models.OverrideLog{ReasonCode: "other", ReasonText: "Family history not captured"}, "other", false},
+		{"other without text", models.OverrideLog{ReasonCode: "other", ReasonText: "  "}, "", true},
+		{"free text", models.OverrideLog{Reason: "I just disagree"}, "", true},
+		{"legacy is not selectable", models.OverrideLog{ReasonCode: "other_legacy"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := tt.override
+			err := svc.Resolve(&o)
+			if tt.wantErr {
+				if !errors.Is(err, services.ErrInvalidOverride) {
+					t.Errorf("Expected ErrInvalidOverride, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if o.ReasonCode != tt.wantCode {
+				t.Errorf("Expected code %s, got %s", tt.wantCode, o.ReasonCode)
+			}
+			if o.ModelName != services.OverrideModelUnspecified {
+				t.Errorf("Expected default model name, got %q", o.ModelName)
+			}
+		})
+	}
+
+	// Deactivating a reason makes it unselectable
+	if _, prev, err := svc.SaveReason(models.OverrideReason{Code: "data_quality_issue", Label: "Data Quality Issue", Active: false}); err != nil || prev == nil {
+		t.Fatalf("Expected update of existing reason, got prev=%v err=%v", prev, err)
+	}
+	if err := svc.Resolve(&models.OverrideLog{ReasonCode: "data_quality_issue"}); err == nil {
+		t.Error("Expected inactive reason to be rejected")
+	}
+}
+
+// TestOverride_ReportAndLegacyMigration tests the aggregation and the legacy backfill
+func TestOverride_ReportAndLegacyMigration(t *testing.T) {
+	svc, db := newOverrideService(t)
+
+	jan := time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC)
+	feb := time.Date(2026, 2, 10, 10, 0, 0, 0, time.UTC)
+	rows := []models.OverrideLog{
+		{CreatedAt: jan, ReasonCode: "clinical_intuition", Reason: "Clinical Intuition", ModelName: "Heart_Model"},
+		{CreatedAt: jan, ReasonCode: "clinical_intuition", Reason: "Clinical Intuition", ModelName: "Heart_Model"},
+		{CreatedAt: feb, ReasonCode: "data_quality_issue", Reason: "Data Quality Issue", ModelName: "Diabetes_Model"},
+	}
+	for i := range rows {
+		db.Create(&rows[i])
+	}
+
+	// Two rejected feedbacks with no override record, one approved
+	db.Create(&models.Feedback{CreatedAt: feb, DoctorApproved: false, DoctorNotes: "disagree"})
+	db.Create(&models.Feedback{CreatedAt: feb, DoctorApproved: false})
+	db.Create(&models.Feedback{CreatedAt: feb, DoctorApproved: true})
+
+	n, err := svc.MigrateLegacy()
+	if err != nil || n != 2 {
+		t.Fatalf("Expected 2 legacy overrides migrated, got %d (%v)", n, err)
+	}
+	if n, _ := svc.MigrateLegacy(); n != 0 {
+		t.Errorf("Expected migration to be idempotent, got %d", n)
+	}
+
+	report, err := svc.Report(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Report failed: %v", err)
+	}
+	if report.Total != 5 {
+		t.Errorf("Expected 5 overrides, got %d", report.Total)
+	}
+
+	byReason := map[string]int64{}
+	for _, c := range report.ByReason {
+		byReason[c.Key] = c.Count
+	}
+	if byReason["Clinical Intuition"] != 2 || byReason["Other (legacy)"] != 2 || byReason["Data Quality Issue"] != 1 {
+		t.Errorf("Unexpected reason counts: %v", report.ByReason)
+	}
+
+	byModel := map[string]int64{}
+	for _, c := range report.ByModel {
+		byModel[c.Key] = c.Count
+	}
+	if byModel["Heart_Model"] != 2 || byModel[services.OverrideModelUnspecified] != 2 {
+		t.Errorf("Unexpected model counts: %v", report.ByModel)
+	}
+
+	if len(report.ByMonth) != 2 || report.ByMonth[0].Key != "2026-01" || report.ByMonth[0].Count != 2 || report.ByMonth[1].Count != 3 {
+		t.Errorf("Unexpected month buckets: %v", report.ByMonth)
+	}
+}
+
+func TestOverride_ResolvesOverriddenModels(t *testing.T) {
+	svc, _ := newOverrideService(t)
+
+	o := models.OverrideLog{ReasonCode: "clinical_intuition", ModelsOverridden: []string{"heart", "Stroke_Model", "heart_model"}}
+	if err := svc.Resolve(&o); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if len(o.ModelsOverridden) != 2 || o.ModelsOverridden[0] != "Heart_Model" || o.ModelsOverridden[1] != "Stroke_Model" || o.ModelName != "Heart_Model" {
+		t.Errorf("Expected canonical, deduplicated models with the first as model_name, got %v %q", o.ModelsOverridden, o.ModelName)
+	}
+
+	legacy := models.OverrideLog{ReasonCode: "clinical_intuition", ModelName: "Kidney_Model"}
+	if err := svc.Resolve(&legacy); err != nil || len(legacy.ModelsOverridden) != 1 || legacy.ModelsOverridden[0] != "Kidney_Model" {
+		t.Errorf("Expected model_name alone to fill models_overridden, got %v (%v)", legacy.ModelsOverridden, err)
+	}
+
+	if err := svc.Resolve(&models.OverrideLog{ReasonCode: "clinical_intuition", ModelsOverridden: []string{"Liver_Model"}}); !errors.Is(err, services.ErrInvalidOverride) {
+		t.Errorf("Expected an unknown model refused, got %v", err)
+	}
+}
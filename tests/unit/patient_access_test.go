@@ -0,0 +1,234 @@
+package unit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Doctors in the access tests; doctorNoProvider has an account but no
+// provider record
+var (
+	doctorHouse      = auditctx.Identity{ID: "user:1", Role: auditctx.RoleDoctor}
+	doctorWilson     = auditctx.Identity{ID: "user:2", Role: auditctx.RoleDoctor}
+	doctorNoProvider = auditctx.Identity{ID: "user:3", Role: auditctx.RoleDoctor}
+	accessAdmin      = auditctx.Identity{ID: "user:4", Role: auditctx.RoleAdmin}
+	accessUser       = auditctx.Identity{ID: "user:5", Role: auditctx.RoleUser}
+	accessService    = auditctx.Identity{ID: "apikey:hl7", Role: auditctx.RoleService}
+)
+
+// newAccessApp seeds one patient for each of two doctors and one unassigned,
+// returning their IDs in that order. Requests run as the actor X-Test-Actor names.
+func newAccessApp(t *testing.T) (*fiber.App, *gorm.DB, []uint) {
+	h, db, _ := newTestPatientHandler(t, "http://ml.invalid", handlers.NewWebSocketHandler())
+	db.AutoMigrate(&models.Provider{})
+	h.Providers = services.NewProviderService(db)
+
+	var ids []uint
+	for _, userID := range []uint{1, 2} {
+		uid := userID
+		p := models.Provider{Name: fmt.Sprintf("Dr. %d", uid), UserID: &uid}
+		h.Providers.Create(&p)
+		patient := models.PatientData{Age: 50, Gender: "Female", AssignedProviderID: &p.ID}
+		db.Create(&patient)
+		ids = append(ids, patient.ID)
+	}
+	unassigned := models.PatientData{Age: 70, Gender: "Male"}
+	db.Create(&unassigned)
+	ids = append(ids, unassigned.ID)
+
+	actors := map[string]auditctx.Identity{"house": doctorHouse, "wilson": doctorWilson, "none": doctorNoProvider, "admin": accessAdmin,
+		"user": accessUser, "service": accessService, "anonymous": auditctx.Anonymous}
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		auditctx.Set(c, actors[c.Get("X-Test-Actor")])
+		return c.Next()
+	})
+	app.Get("/api/patients", h.GetPatients)
+	app.Post("/api/assess", h.AssessPatient)
+	app.Get("/api/patients/:id", h.RequireAccess, h.GetPatient)
+	app.Get("/api/diagnosis/:id", h.RequireAccess, h.GetDiagnosis)
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(200) }
+	app.Get("/api/assessments/:id", h.RequireAssessmentAccess, ok)
+	app.Get("/api/assessments/:id/compare/:other", h.RequireAssessmentAccess, ok)
+	return app, db, ids
+}
+
+func accessRequest(t *testing.T, app *fiber.App, actor, path string) (int, []byte) {
+	req := httptest.NewRequest("GET", path, nil)
+	req.Header.Set("X-Test-Actor", actor)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", path, err)
+	}
+	var body json.RawMessage
+	json.NewDecoder(resp.Body).Decode(&body)
+	return resp.StatusCode, body
+}
+
+func TestPatientAccess_ListIsScopedToAssignedDoctor(t *testing.T) {
+	app, _, ids := newAccessApp(t)
+
+	cases := map[string][]uint{
+		"house":  {ids[0]},
+		"wilson": {ids[1]},
+		"none":   {},
+		"admin":  {ids[2], ids[1], ids[0]},
+	}
+	for actor, want := range cases {
+		code, body := accessRequest(t, app, actor, "/api/patients")
+		var page models.PatientPage
+		json.Unmarshal(body, &page)
+		if code != 200 || page.Total != int64(len(want)) || len(page.Items) != len(want) {
+			t.Errorf("%s: expected %d patients, got %d total=%d items=%d", actor, len(want), code, page.Total, len(page.Items))
+			continue
+		}
+		for i, p := range page.Items {
+			if p.ID != want[i] {
+				t.Errorf("%s: expected patient %d at %d, got %d", actor, want[i], i, p.ID)
+			}
+		}
+	}
+}
+
+func TestPatientAccess_DeniesAndAuditsUnassigned(t *testing.T) {
+	app, db, ids := newAccessApp(t)
+
+	cases := []struct {
+		actor, path string
+		want        int
+	}{
+		{"house", fmt.Sprintf("/api/patients/%d", ids[0]), 200},
+		{"house", fmt.Sprintf("/api/diagnosis/%d", ids[0]), 200},
+		{"house", fmt.Sprintf("/api/patients/%d", ids[1]), 403},
+		{"house", fmt.Sprintf("/api/diagnosis/%d", ids[1]), 403},
+		{"house", fmt.Sprintf("/api/patients/%d", ids[2]), 403},
+		{"house", "/api/patients/999", 403}, // Unknown IDs can't be told apart
+		{"none", fmt.Sprintf("/api/patients/%d", ids[0]), 403},
+		{"admin", fmt.Sprintf("/api/patients/%d", ids[1]), 200},
+		{"admin", "/api/patients/999", 404},
+		{"house", "/api/patients/abc", 400},
+	}
+	for _, c := range cases {
+		if code, body := accessRequest(t, app, c.actor, c.path); code != c.want {
+			t.Errorf("%s %s: expected %d, got %d %s", c.actor, c.path, c.want, code, body)
+		}
+	}
+
+	var denied []models.AuditLog
+	db.Where("event_type = ?", "ACCESS_DENIED").Order("id").Find(&denied)
+	if len(denied) != 5 {
+		t.Fatalf("Expected 5 ACCESS_DENIED events, got %d", len(denied))
+	}
+	hash := sha256.Sum256([]byte(fmt.Sprint(ids[1])))
+	if denied[0].ActorID != doctorHouse.ID || denied[0].ActorRole != auditctx.RoleDoctor || denied[0].PatientIDHash != hex.EncodeToString(hash[:]) {
+		t.Errorf("Expected the denial recorded against the doctor and hashed patient, got %+v", denied[0])
+	}
+}
+
+func TestPatientAccess_GuardsAssessmentsByPatient(t *testing.T) {
+	app, db, ids := newAccessApp(t)
+	var assessments []uint
+	for _, patientID := range ids {
+		a := models.Assessment{PatientID: patientID}
+		db.Create(&a)
+		assessments = append(assessments, a.ID)
+	}
+
+	cases := []struct {
+		actor, path string
+		want        int
+	}{
+		{"house", fmt.Sprintf("/api/assessments/%d", assessments[0]), 200},
+		{"house", fmt.Sprintf("/api/assessments/%d", assessments[1]), 403},
+		{"house", fmt.Sprintf("/api/assessments/%d", assessments[2]), 403},
+		{"house", "/api/assessments/999", 403}, // Unknown IDs can't be told apart
+		{"house", fmt.Sprintf("/api/assessments/%d/compare/%d", assessments[0], assessments[1]), 403},
+		{"house", fmt.Sprintf("/api/assessments/%d/compare/%d", assessments[1], assessments[0]), 403},
+		{"user", fmt.Sprintf("/api/assessments/%d", assessments[0]), 403},
+		{"admin", fmt.Sprintf("/api/assessments/%d/compare/%d", assessments[1], assessments[2]), 200},
+		{"house", "/api/assessments/abc", 200}, // Left to the handler's 400
+	}
+	for _, c := range cases {
+		if code, body := accessRequest(t, app, c.actor, c.path); code != c.want {
+			t.Errorf("%s %s: expected %d, got %d %s", c.actor, c.path, c.want, code, body)
+		}
+	}
+
+	var denied []models.AuditLog
+	db.Where("event_type = ?", "ACCESS_DENIED").Order("id").Find(&denied)
+	if len(denied) != 6 {
+		t.Fatalf("Expected 6 ACCESS_DENIED events, got %d", len(denied))
+	}
+	hash := sha256.Sum256([]byte(fmt.Sprint(ids[1])))
+	if denied[0].ActorID != doctorHouse.ID || denied[0].PatientIDHash != hex.EncodeToString(hash[:]) {
+		t.Errorf("Expected the denial recorded against the assessed patient, got %+v", denied[0])
+	}
+}
+
+func TestPatientAccess_OnlyAdminsAndServicesSeeEveryone(t *testing.T) {
+	app, _, ids := newAccessApp(t)
+
+	for actor, want := range map[string]int64{"admin": 3, "service": 3, "user": 0, "anonymous": 0} {
+		code, body := accessRequest(t, app, actor, "/api/patients")
+		var page models.PatientPage
+		json.Unmarshal(body, &page)
+		if code != 200 || page.Total != want {
+			t.Errorf("%s: expected %d patients listed, got %d total=%d", actor, want, code, page.Total)
+		}
+	}
+	for actor, want := range map[string]int{"service": 200, "user": 403, "anonymous": 403} {
+		if code, body := accessRequest(t, app, actor, fmt.Sprintf("/api/patients/%d", ids[2])); code != want {
+			t.Errorf("%s: expected %d, got %d %s", actor, want, code, body)
+		}
+	}
+}
+
+func TestPatientAccess_DoctorReadsBackNewPatient(t *testing.T) {
+	app, db, _ := newAccessApp(t)
+
+	for _, actor := range []string{"house", "admin"} {
+		req := httptest.NewRequest("POST", "/api/assess", strings.NewReader(assessBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Test-Actor", actor)
+		if resp, err := app.Test(req, -1); err != nil || resp.StatusCode != 200 {
+			t.Fatalf("%s: assessment failed: %v %v", actor, resp, err)
+		}
+	}
+	var created []models.PatientData
+	db.Order("id DESC").Limit(2).Find(&created)
+	byAdmin, byHouse := created[0], created[1]
+	if byHouse.AssignedProviderID == nil || byAdmin.AssignedProviderID != nil {
+		t.Fatalf("Expected only the doctor's patient assigned, got %v and %v", byHouse.AssignedProviderID, byAdmin.AssignedProviderID)
+	}
+	if code, body := accessRequest(t, app, "house", fmt.Sprintf("/api/patients/%d", byHouse.ID)); code != 200 {
+		t.Errorf("Expected the doctor to read back their new patient, got %d %s", code, body)
+	}
+	if code, _ := accessRequest(t, app, "wilson", fmt.Sprintf("/api/patients/%d", byHouse.ID)); code != 403 {
+		t.Errorf("Expected another doctor refused, got %d", code)
+	}
+}
+
+func TestUserIDOf_RoundTrips(t *testing.T) {
+	id, ok := services.UserIDOf(services.UserIdentity(&models.User{ID: 42}))
+	if !ok || id != 42 {
+		t.Errorf("Expected 42, got %d %v", id, ok)
+	}
+	for _, actor := range []string{"ops", "user:", "user:x", "mcp-server"} {
+		if _, ok := services.UserIDOf(auditctx.Identity{ID: actor}); ok {
+			t.Errorf("Expected %q not to be an account", actor)
+		}
+	}
+}
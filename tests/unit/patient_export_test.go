@@ -0,0 +1,148 @@
+package unit
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/middleware"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// newExportApp seeds three patients, the first two assigned to doctorHouse's
+// provider, and serves the export as the actor X-Test-Actor names
+func newExportApp(t *testing.T) (*fiber.App, *gorm.DB, []models.PatientData) {
+	h, db, _ := newTestPatientHandler(t, "http://ml.invalid", handlers.NewWebSocketHandler())
+	db.AutoMigrate(&models.Provider{})
+	providers := services.NewProviderService(db)
+	userID := uint(1)
+	house := models.Provider{Name: "Dr. House", UserID: &userID}
+	providers.Create(&house)
+
+	day := func(d int) time.Time { return time.Date(2025, 3, d, 9, 0, 0, 0, time.UTC) }
+	patients := []models.PatientData{
+		{CreatedAt: day(1), Name: `Kaya, "Memo"` + "\nJr.", Age: 61, Gender: "Male", Symptoms: "=HYPERLINK(\"x\")", AssignedProviderID: &house.ID},
+		{CreatedAt: day(10), Name: "Ayse", Age: 58, Gender: "Female", AssignedProviderID: &house.ID},
+		{CreatedAt: day(20), Name: "Elif", Age: 35, Gender: "Female"},
+	}
+	for i := range patients {
+		db.Create(&patients[i])
+	}
+	db.Create(&models.Assessment{PatientID: patients[0].ID, HeartRisk: 30})
+	db.Create(&models.Assessment{PatientID: patients[0].ID, HeartRisk: 55}) // Latest
+
+	export := handlers.NewExportHandler(services.NewExportService(db), h.Audit, providers)
+	actors := map[string]auditctx.Identity{"house": doctorHouse, "admin": accessAdmin, "kiosk": {ID: "kiosk:1", Role: auditctx.RoleKiosk}}
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		auditctx.Set(c, actors[c.Get("X-Test-Actor")])
+		return c.Next()
+	})
+	app.Get("/api/patients/export", middleware.RequireRole(auditctx.RoleAdmin, auditctx.RoleDoctor), export.ExportPatients)
+	return app, db, patients
+}
+
+func exportRequest(t *testing.T, app *fiber.App, actor, query string) (int, string) {
+	req := httptest.NewRequest("GET", "/api/patients/export"+query, nil)
+	req.Header.Set("X-Test-Actor", actor)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestPatientExport_CSVRoundTripsAndIsAudited(t *testing.T) {
+	app, db, patients := newExportApp(t)
+
+	code, body := exportRequest(t, app, "admin", "")
+	if code != 200 {
+		t.Fatalf("Expected the export, got %d %s", code, body)
+	}
+	records, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+	if err != nil {
+		t.Fatalf("Expected valid CSV, got %v", err)
+	}
+	if len(records) != 4 || records[0][0] != "id" || slices.Contains(records[0], "heart_risk_score") {
+		t.Fatalf("Expected the patient columns only and 3 rows, got %d rows, header %v", len(records), records[0])
+	}
+	col := map[string]int{}
+	for i, name := range records[0] {
+		col[name] = i
+	}
+	first := records[1]
+	if first[col["name"]] != patients[0].Name {
+		t.Errorf("Expected the name to survive quoting, got %q", first[col["name"]])
+	}
+	if first[col["symptoms"]] != "'"+patients[0].Symptoms {
+		t.Errorf("Expected formulas neutralized, got %q", first[col["symptoms"]])
+	}
+
+	var event models.AuditLog
+	db.Where("event_type = ?", "EXPORT").First(&event)
+	if event.ActorID != accessAdmin.ID {
+		t.Errorf("Expected the export audited against the admin, got %+v", event)
+	}
+}
+
+func TestPatientExport_NDJSONWithLatestScores(t *testing.T) {
+	app, _, patients := newExportApp(t)
+
+	code, body := exportRequest(t, app, "admin", "?format=json&fields=heart_risk_score,id&from=2025-03-01&to=2025-03-10")
+	if code != 200 {
+		t.Fatalf("Expected the export, got %d %s", code, body)
+	}
+	var rows []map[string]any
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		var row map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			t.Fatalf("Expected one JSON object per line, got %q", scanner.Text())
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) != 2 || len(rows[0]) != 2 {
+		t.Fatalf("Expected the 2 patients up to and including Mar 10 with 2 fields, got %v", rows)
+	}
+	if rows[0]["id"] != float64(patients[0].ID) || rows[0]["heart_risk_score"] != float64(55) {
+		t.Errorf("Expected the latest assessment's score, got %v", rows[0])
+	}
+	if rows[1]["heart_risk_score"] != nil {
+		t.Errorf("Expected null for a patient never assessed, got %v", rows[1])
+	}
+}
+
+func TestPatientExport_ScopedAndValidated(t *testing.T) {
+	app, _, _ := newExportApp(t)
+
+	if code, body := exportRequest(t, app, "house", "?fields=id"); code != 200 || strings.Count(body, "\n") != 3 {
+		t.Errorf("Expected the doctor's 2 patients only, got %d %q", code, body)
+	}
+	cases := []struct {
+		actor, query string
+		want         int
+	}{
+		{"kiosk", "", 403},
+		{"admin", "?format=xml", 400},
+		{"admin", "?fields=id,ssn", 400},
+		{"admin", "?from=yesterday", 400},
+	}
+	for _, c := range cases {
+		if code, _ := exportRequest(t, app, c.actor, c.query); code != c.want {
+			t.Errorf("%s %q: expected %d, got %d", c.actor, c.query, c.want, code)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/repositories"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const importCSV = "\ufeffPatient Name,Age,Sex,SBP,DBP,Blood Glucose,BMI,Smoker,Notes\n" +
+	"Ayse Yilmaz,58,F,150,95,130,\"26,5\",Y,called twice\n" +
+	"Mehmet Kaya,61,m,20,80,100,27,n,\n" + // SBP out of range
+	"Short Row,40,M\n" +
+	"Elif Demir,35,Female,118,76,92,22,,\n"
+
+func postImport(t *testing.T, app *fiber.App, query, csv string) (int, models.ImportSummary) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if csv != "" {
+		part, _ := form.CreateFormFile("file", "export.csv")
+		part.Write([]byte(csv))
+	}
+	form.Close()
+
+	req := httptest.NewRequest("POST", "/api/patients/import"+query, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	var summary models.ImportSummary
+	json.NewDecoder(resp.Body).Decode(&summary)
+	return resp.StatusCode, summary
+}
+
+func TestPatientImport_DryRunThenImport(t *testing.T) {
+	h, db, _ := newTestPatientHandler(t, "http://ml.invalid", handlers.NewWebSocketHandler())
+	imports := handlers.NewImportHandler(services.NewImportService(h.Patients, h.Audit), h.WS)
+	app := fiber.New()
+	app.Post("/api/patients/import", imports.ImportPatients)
+
+	code, dry := postImport(t, app, "?dry_run=true", importCSV)
+	if code != 200 || !dry.DryRun || dry.Created != 2 || dry.Skipped != 2 {
+		t.Fatalf("Expected 2 rows to create and 2 to skip, got %d %+v", code, dry)
+	}
+	if len(dry.Errors) != 2 || dry.Errors[0].Row != 3 || !strings.Contains(dry.Errors[0].Reason, "SystolicBP") || dry.Errors[1].Row != 4 {
+		t.Errorf("Expected lines 3 and 4 reported, got %+v", dry.Errors)
+	}
+	if len(dry.IgnoredColumns) != 1 || dry.IgnoredColumns[0] != "Notes" {
+		t.Errorf("Expected the Notes column ignored, got %v", dry.IgnoredColumns)
+	}
+	var count int64
+	db.Model(&models.PatientData{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("Expected a dry run to create nothing, got %d patients", count)
+	}
+
+	code, summary := postImport(t, app, "", importCSV)
+	if code != 200 || summary.DryRun || summary.Created != 2 || summary.Skipped != 2 {
+		t.Fatalf("Expected 2 patients imported, got %d %+v", code, summary)
+	}
+	var ayse models.PatientData
+	db.Where("name = ?", "Ayse Yilmaz").First(&ayse)
+	if ayse.Gender != "Female" || ayse.BMI != 26.5 || ayse.Smoking != "Yes" || ayse.Alcohol != models.ClinicDefaultAnswer || ayse.Source != services.PatientSourceImport {
+		t.Errorf("Expected mapped and normalized fields, got %+v", ayse)
+	}
+	var audited int64
+	db.Model(&models.AuditLog{}).Where("event_type = ?", "PATIENT_IMPORTED").Count(&audited)
+	if audited != 2 {
+		t.Errorf("Expected a PATIENT_IMPORTED event per patient, got %d", audited)
+	}
+}
+
+func TestPatientImport_RejectsUnusableFiles(t *testing.T) {
+	h, _, _ := newTestPatientHandler(t, "http://ml.invalid", handlers.NewWebSocketHandler())
+	imports := handlers.NewImportHandler(services.NewImportService(h.Patients, h.Audit), h.WS)
+	app := fiber.New()
+	app.Post("/api/patients/import", imports.ImportPatients)
+
+	for name, csv := range map[string]string{
+		"no file":         "",
+		"no known column": "mrn,ward\n1,A\n",
+	} {
+		if code, _ := postImport(t, app, "", csv); code != 400 {
+			t.Errorf("%s: expected 400, got %d", name, code)
+		}
+	}
+}
+
+func TestPatientImport_CapsReportedErrors(t *testing.T) {
+	db := openTestAuditDB(t)
+	db.AutoMigrate(&models.PatientData{})
+	imports := services.NewImportService(repositories.NewPatientRepository(db), services.NewAuditService(db))
+	imports.MaxErrors = 2
+
+	csv := "age,gender\n" + strings.Repeat("200,Male\n", 5)
+	summary, err := imports.Import(strings.NewReader(csv), auditctx.System, false, nil)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if summary.Skipped != 5 || len(summary.Errors) != 2 || !summary.ErrorsTruncated {
+		t.Errorf("Expected 5 skipped, 2 listed and truncation flagged, got %+v", summary)
+	}
+}
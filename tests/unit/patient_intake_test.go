@@ -0,0 +1,250 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/middleware"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/repositories"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// omitField marks a case that leaves the field out of the body
+var omitField = struct{}{}
+
+// requiredIntake is the smallest body that creates a patient
+func requiredIntake() map[string]any {
+	return map[string]any{"age": 60, "gender": "Male", "systolic_bp": 130, "diastolic_bp": 85, "glucose": 100, "bmi": 26}
+}
+
+// decodeIntake runs a body through the create path: decode, validate, default
+func decodeIntake(t *testing.T, body map[string]any) (models.PatientData, []string) {
+	t.Helper()
+	data, _ := json.Marshal(body)
+	var intake models.PatientIntake
+	if err := json.Unmarshal(data, &intake); err != nil {
+		t.Fatalf("Unmarshal %s failed: %v", data, err)
+	}
+	var failed []string
+	for _, e := range middleware.ValidateStruct(intake) {
+		failed = append(failed, e.Field)
+	}
+	return intake.NewPatient(), failed
+}
+
+func TestPatientIntakeOmittedZeroExplicit(t *testing.T) {
+	imputed := func(field string) func(models.PatientData) bool {
+		return func(p models.PatientData) bool {
+			for _, f := range p.Imputed() {
+				if f == field {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	measured := func(field string) func(models.PatientData) bool {
+		return func(p models.PatientData) bool { return !imputed(field)(p) }
+	}
+
+	cases := []struct {
+		field   string
+		value   any
+		wantErr string // Go field that must fail validation
+		want    func(models.PatientData) bool
+	}{
+		// Required: omitted fails, zero is range-checked
+		{"age", omitField, "Age", nil},
+		{"age", 0, "", func(p models.PatientData) bool { return p.Age == 0 }},
+		{"age", 54, "", func(p models.PatientData) bool { return p.Age == 54 }},
+		{"gender", omitField, "Gender", nil},
+		{"gender", "", "Gender", nil},
+		{"gender", "Female", "", func(p models.PatientData) bool { return p.Gender == "Female" }},
+		{"systolic_bp", omitField, "SystolicBP", nil},
+		{"systolic_bp", 0, "SystolicBP", nil},
+		{"systolic_bp", "145", "", func(p models.PatientData) bool { return p.SystolicBP == 145 }},
+		{"diastolic_bp", omitField, "DiastolicBP", nil},
+		{"diastolic_bp", 0, "DiastolicBP", nil},
+		{"diastolic_bp", 92, "", func(p models.PatientData) bool { return p.DiastolicBP == 92 }},
+		{"glucose", omitField, "Glucose", nil},
+		{"glucose", "", "Glucose", nil},
+		{"glucose", 0, "Glucose", nil},
+		{"glucose", 110, "", func(p models.PatientData) bool { return p.Glucose == 110 }},
+		{"bmi", omitField, "BMI", nil},
+		{"bmi", 0, "BMI", nil},
+		{"bmi", "24,5", "", func(p models.PatientData) bool { return p.BMI == 24.5 }},
+
+		// Imputable measurements: omitted is left to the model, zero is a value
+		{"cholesterol", omitField, "", imputed("cholesterol")},
+		{"cholesterol", nil, "", imputed("cholesterol")},
+		{"cholesterol", 0, "Cholesterol", nil},
+		{"cholesterol", 210, "", func(p models.PatientData) bool { return p.Cholesterol == 210 && measured("cholesterol")(p) }},
+		{"heart_rate", omitField, "", imputed("heart_rate")},
+		{"heart_rate", 0, "HeartRate", nil},
+		{"heart_rate", 72, "", func(p models.PatientData) bool { return p.HeartRate == 72 && measured("heart_rate")(p) }},
+		{"steps", omitField, "", imputed("steps")},
+		{"steps", "", "", imputed("steps")},
+		{"steps", 0, "", func(p models.PatientData) bool { return p.Steps == 0 && measured("steps")(p) }},
+		{"steps", "8.500,0", "", func(p models.PatientData) bool { return p.Steps == 8500 && measured("steps")(p) }},
+
+		// Choices: omitted or blank take the clinic default
+		{"smoking", omitField, "", func(p models.PatientData) bool { return p.Smoking == "No" }},
+		{"smoking", "", "", func(p models.PatientData) bool { return p.Smoking == "No" }},
+		{"smoking", "Former", "", func(p models.PatientData) bool { return p.Smoking == "Former" }},
+		{"smoking", "Sometimes", "Smoking", nil},
+		{"alcohol", omitField, "", func(p models.PatientData) bool { return p.Alcohol == "No" }},
+		{"alcohol", "Yes", "", func(p models.PatientData) bool { return p.Alcohol == "Yes" }},
+		{"history_heart_disease", omitField, "", func(p models.PatientData) bool { return p.HistoryHeartDisease == "No" }},
+		{"history_heart_disease", "Yes", "", func(p models.PatientData) bool { return p.HistoryHeartDisease == "Yes" }},
+		{"history_stroke", omitField, "", func(p models.PatientData) bool { return p.HistoryStroke == "No" }},
+		{"history_stroke", "Yes", "", func(p models.PatientData) bool { return p.HistoryStroke == "Yes" }},
+		{"history_diabetes", omitField, "", func(p models.PatientData) bool { return p.HistoryDiabetes == "No" }},
+		{"history_diabetes", "Yes", "", func(p models.PatientData) bool { return p.HistoryDiabetes == "Yes" }},
+		{"history_high_chol", omitField, "", func(p models.PatientData) bool { return p.HistoryHighChol == "No" }},
+		{"history_high_chol", "Yes", "", func(p models.PatientData) bool { return p.HistoryHighChol == "Yes" }},
+
+		// Free text: omitted is empty
+		{"medications", omitField, "", func(p models.PatientData) bool { return p.Medications == "" }},
+		{"medications", "aspirin", "", func(p models.PatientData) bool { return p.Medications == "aspirin" }},
+		{"symptoms", omitField, "", func(p models.PatientData) bool { return p.Symptoms == "" }},
+		{"symptoms", "cough, fever", "", func(p models.PatientData) bool { return p.Symptoms == "cough, fever" }},
+		{"name", "Ada", "", func(p models.PatientData) bool { return p.Name == "Ada" }},
+	}
+	for _, tc := range cases {
+		name := fmt.Sprintf("%s=%v", tc.field, tc.value)
+		if tc.value == omitField {
+			name = tc.field + " omitted"
+		}
+		t.Run(name, func(t *testing.T) {
+			body := requiredIntake()
+			delete(body, tc.field)
+			if tc.value != omitField {
+				body[tc.field] = tc.value
+			}
+			patient, failed := decodeIntake(t, body)
+
+			if tc.wantErr != "" {
+				if len(failed) != 1 || failed[0] != tc.wantErr {
+					t.Errorf("Expected a %s validation error, got %v", tc.wantErr, failed)
+				}
+				return
+			}
+			if len(failed) > 0 {
+				t.Fatalf("Unexpected validation errors %v", failed)
+			}
+			if !tc.want(patient) {
+				t.Errorf("Unexpected patient %+v", patient)
+			}
+		})
+	}
+}
+
+func TestPredictPayloadLeavesOutImputedFields(t *testing.T) {
+	var sent map[string]any
+	ml := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&sent)
+		json.NewEncoder(w).Encode(models.PredictResponse{HeartRisk: 20})
+	}))
+	t.Cleanup(ml.Close)
+
+	body := requiredIntake()
+	body["steps"] = 0
+	patient, _ := decodeIntake(t, body)
+	patient.ID = 4242
+	if _, err := services.NewPredictionService(ml.URL).PredictRisks(patient); err != nil {
+		t.Fatalf("Predict failed: %v", err)
+	}
+
+	for _, field := range []string{"cholesterol", "heart_rate"} {
+		if _, ok := sent[field]; ok {
+			t.Errorf("Imputed %s was sent to the model: %v", field, sent[field])
+		}
+	}
+	if sent["steps"] != float64(0) {
+		t.Errorf("Explicit zero steps should be sent, got %v", sent["steps"])
+	}
+	if sent["smoking"] != "No" || sent["glucose"] != float64(100) {
+		t.Errorf("Provided and defaulted fields should still be sent: %v", sent)
+	}
+}
+
+func TestUpdatePatientIsPartial(t *testing.T) {
+	h, db, _ := newTestPatientHandler(t, "http://127.0.0.1:1", handlers.NewWebSocketHandler())
+	created, _ := decodeIntake(t, map[string]any{"age": 50, "gender": "Female", "systolic_bp": 125, "diastolic_bp": 80,
+		"glucose": 95, "bmi": 23, "medications": "metformin", "smoking": "Yes"})
+	db.Create(&created)
+
+	app := fiber.New()
+	app.Put("/api/patients/:id", h.UpdatePatient)
+	put := func(body string) (int, []byte) {
+		req := httptest.NewRequest("PUT", "/api/patients/"+fmt.Sprint(created.ID), bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		raw, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, raw
+	}
+
+	if status, raw := put(`{"glucose":"140","cholesterol":210,"medications":""}`); status != 200 {
+		t.Fatalf("Expected 200, got %d: %s", status, raw)
+	}
+	var stored models.PatientData
+	db.First(&stored, created.ID)
+	if stored.Glucose != 140 || stored.Cholesterol != 210 || stored.Medications != "" {
+		t.Errorf("Provided fields not applied: %+v", stored)
+	}
+	if stored.Age != 50 || stored.SystolicBP != 125 || stored.Smoking != "Yes" || stored.Gender != "Female" {
+		t.Errorf("Omitted fields must keep their values: %+v", stored)
+	}
+	if stored.ImputedFields != "heart_rate,steps" {
+		t.Errorf("Cholesterol should no longer be imputed, got %q", stored.ImputedFields)
+	}
+
+	// A provided zero is still validated; nothing is written
+	status, raw := put(`{"systolic_bp":0,"heart_rate":70}`)
+	if status != 400 || !bytes.Contains(raw, []byte("SystolicBP")) {
+		t.Errorf("Expected a SystolicBP error, got %d: %s", status, raw)
+	}
+	db.First(&stored, created.ID)
+	if stored.HeartRate != 0 || stored.SystolicBP != 125 {
+		t.Errorf("Rejected update was stored: %+v", stored)
+	}
+}
+
+func TestCompareShowsImputedFieldsAsMissing(t *testing.T) {
+	_, db, _ := newTestPatientHandler(t, "http://127.0.0.1:1", handlers.NewWebSocketHandler())
+	repo := repositories.NewAssessmentRepository(db)
+	app := fiber.New()
+	app.Get("/api/assessments/:id/compare/:other", handlers.NewAssessmentHandler(repo).Compare)
+
+	before, _ := decodeIntake(t, requiredIntake())
+	body := requiredIntake()
+	body["cholesterol"] = 190
+	after, _ := decodeIntake(t, body)
+	for _, p := range []models.PatientData{before, after} {
+		a := models.Assessment{PatientID: 1}
+		a.SetPatientSnapshot(p)
+		repo.Create(&a)
+	}
+
+	cmp := getJSON(t, app, "/api/assessments/1/compare/2")
+	changes := cmp["patient_inputs"].([]any)
+	if len(changes) != 1 {
+		t.Fatalf("Expected only cholesterol to differ, got %v", changes)
+	}
+	change := changes[0].(map[string]any)
+	if change["field"] != "cholesterol" || change["from"] != nil || change["to"] != float64(190) {
+		t.Errorf("Expected cholesterol from null to 190, got %v", change)
+	}
+}
@@ -0,0 +1,114 @@
+package unit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/repositories"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// seedPatientList creates 30 patients: ages 20-49, alternating gender, and
+// systolic BP above the high-risk line for every third one
+func seedPatientList(t *testing.T) *fiber.App {
+	h, db, _ := newTestPatientHandler(t, "http://ml.invalid", handlers.NewWebSocketHandler())
+	db.AutoMigrate(&models.Provider{})
+	for i := 0; i < 30; i++ {
+		p := models.PatientData{Age: 20 + i, Gender: "Male", SystolicBP: 120}
+		if i%2 == 1 {
+			p.Gender = "Female"
+		}
+		if i%3 == 0 {
+			p.SystolicBP = 170
+		}
+		db.Create(&p)
+	}
+	app := fiber.New()
+	app.Get("/api/patients", h.GetPatients)
+	return app
+}
+
+func listPatients(t *testing.T, app *fiber.App, query string) (int, models.PatientPage) {
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/patients"+query, nil))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	var page models.PatientPage
+	json.NewDecoder(resp.Body).Decode(&page)
+	return resp.StatusCode, page
+}
+
+func TestPatientList_DefaultsAndPages(t *testing.T) {
+	app := seedPatientList(t)
+
+	code, page := listPatients(t, app, "")
+	if code != 200 || page.Total != 30 || len(page.Items) != 30 || page.Page != 1 || page.Limit != handlers.DefaultPatientPageSize {
+		t.Fatalf("Unexpected default page: %d total=%d items=%d page=%d limit=%d", code, page.Total, len(page.Items), page.Page, page.Limit)
+	}
+
+	_, second := listPatients(t, app, "?page=2&limit=12")
+	if second.Total != 30 || len(second.Items) != 12 {
+		t.Errorf("Expected 12 of 30 on page 2, got %d of %d", len(second.Items), second.Total)
+	}
+	_, third := listPatients(t, app, "?page=3&limit=12")
+	if len(third.Items) != 6 {
+		t.Errorf("Expected 6 on the last page, got %d", len(third.Items))
+	}
+	if second.Items[0].ID == third.Items[0].ID {
+		t.Error("Pages must not overlap")
+	}
+
+	// Past the end: empty items but the total still reported
+	code, beyond := listPatients(t, app, "?page=99&limit=12")
+	if code != 200 || beyond.Total != 30 || len(beyond.Items) != 0 || beyond.Items == nil {
+		t.Errorf("Expected empty page past the end, got %d total=%d items=%v", code, beyond.Total, beyond.Items)
+	}
+}
+
+func TestPatientList_RejectsInvalidParams(t *testing.T) {
+	app := seedPatientList(t)
+	for _, q := range []string{"?page=0", "?page=-1", "?limit=0", fmt.Sprintf("?limit=%d", handlers.MaxPatientPageSize+1), "?min_age=50&max_age=40", "?min_age=-5"} {
+		if code, _ := listPatients(t, app, q); code != 400 {
+			t.Errorf("%s: expected 400, got %d", q, code)
+		}
+	}
+}
+
+func TestPatientList_CombinedFilters(t *testing.T) {
+	app := seedPatientList(t)
+
+	_, page := listPatients(t, app, "?gender=Female&min_age=30&max_age=45&high_risk=true")
+	// Female = odd i, high risk = i divisible by 3, age 30-45 = i 10-25: i in {15, 21}
+	if page.Total != 2 || len(page.Items) != 2 {
+		t.Fatalf("Expected 2 matches, got %d", page.Total)
+	}
+	for _, p := range page.Items {
+		if p.Gender != "Female" || p.Age < 30 || p.Age > 45 || p.SystolicBP <= 160 {
+			t.Errorf("Patient %+v doesn't match the filters", p)
+		}
+	}
+
+	_, highRisk := listPatients(t, app, "?high_risk=true&limit=3")
+	if highRisk.Total != 10 || len(highRisk.Items) != 3 {
+		t.Errorf("Expected 3 of 10 high-risk patients, got %d of %d", len(highRisk.Items), highRisk.Total)
+	}
+}
+
+func TestPatientRepo_ListNewestFirst(t *testing.T) {
+	repo := repositories.NewPatientRepository(setupTestDB(t))
+	for i := 0; i < 3; i++ {
+		repo.Create(&models.PatientData{Age: 40 + i})
+	}
+	page, err := repo.List(repositories.PatientFilter{Page: 1, Limit: 2})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if page.Total != 3 || len(page.Items) != 2 || page.Items[0].Age != 42 {
+		t.Errorf("Expected newest 2 of 3, got %+v", page)
+	}
+}
@@ -0,0 +1,315 @@
+package unit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/locks"
+	"healthcare-backend/pkg/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeRedis speaks just enough RESP for the lock backend: SET NX with
+// expiry, GET, and EVAL of the compare-and-swap/delete scripts
+type fakeRedis struct {
+	mu   sync.Mutex
+	data map[string]fakeEntry
+	down atomic.Bool
+}
+
+type fakeEntry struct {
+	value   string
+	expires time.Time
+}
+
+func newFakeRedis(t *testing.T) (*fakeRedis, *redis.Client) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	f := &fakeRedis{data: map[string]fakeEntry{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	client := redis.NewClient(&redis.Options{Addr: ln.Addr().String(), Protocol: 2, DisableIdentity: true, MaxRetries: -1})
+	t.Cleanup(func() { client.Close(); ln.Close() })
+	return f, client
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readRESP(r)
+		if err != nil {
+			return
+		}
+		reply := f.exec(args)
+		if f.down.Load() {
+			reply = "-ERR fake redis is down\r\n"
+		}
+		conn.Write([]byte(reply))
+	}
+}
+
+func readRESP(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, n)
+	for i := range args {
+		if _, err := r.ReadString('\n'); err != nil { // $len
+			return nil, err
+		}
+		arg, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return args, nil
+}
+
+func bulk(s string) string { return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s) }
+
+func (f *fakeRedis) get(key string) (string, bool) {
+	e, ok := f.data[key]
+	if !ok || time.Now().After(e.expires) {
+		return "", false
+	}
+	return e.value, true
+}
+
+func (f *fakeRedis) exec(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "SET": // SET key value EX|PX n NX
+		ttl, _ := strconv.Atoi(args[4])
+		d := time.Duration(ttl) * time.Second
+		if strings.EqualFold(args[3], "px") {
+			d = time.Duration(ttl) * time.Millisecond
+		}
+		if _, held := f.get(args[1]); held {
+			return "$-1\r\n"
+		}
+		f.data[args[1]] = fakeEntry{value: args[2], expires: time.Now().Add(d)}
+		return "+OK\r\n"
+	case "GET":
+		if v, ok := f.get(args[1]); ok {
+			return bulk(v)
+		}
+		return "$-1\r\n"
+	case "EVALSHA":
+		return "-NOSCRIPT No matching script\r\n"
+	case "EVAL": // EVAL script 1 key old [new px]
+		current, ok := f.get(args[3])
+		if !ok || current != args[4] {
+			if strings.Contains(args[1], "DEL") {
+				return ":0\r\n"
+			}
+			return "$-1\r\n"
+		}
+		if strings.Contains(args[1], "DEL") {
+			delete(f.data, args[3])
+			return ":1\r\n"
+		}
+		ms, _ := strconv.Atoi(args[6])
+		f.data[args[3]] = fakeEntry{value: args[5], expires: time.Now().Add(time.Duration(ms) * time.Millisecond)}
+		return "+OK\r\n"
+	}
+	return "-ERR unknown command\r\n"
+}
+
+// assertSerialized runs concurrent holders and fails if two ever overlap
+func assertSerialized(t *testing.T, replicas []*locks.PatientLocks) {
+	var active, maxActive atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(l *locks.PatientLocks) {
+			defer wg.Done()
+			lease, err := l.Acquire(context.Background(), 7)
+			if err != nil {
+				t.Errorf("Acquire failed: %v", err)
+				return
+			}
+			n := active.Add(1)
+			for m := maxActive.Load(); n > m && !maxActive.CompareAndSwap(m, n); m = maxActive.Load() {
+			}
+			time.Sleep(5 * time.Millisecond)
+			active.Add(-1)
+			lease.Release()
+		}(replicas[i%len(replicas)])
+	}
+	wg.Wait()
+	if maxActive.Load() != 1 {
+		t.Errorf("Expected one holder at a time, saw %d", maxActive.Load())
+	}
+}
+
+func TestPatientLocks_InProcessSerializes(t *testing.T) {
+	l := locks.NewPatientLocks(nil, time.Minute, 5*time.Second)
+	assertSerialized(t, []*locks.PatientLocks{l})
+
+	stats := l.Stats()
+	if stats.Acquired != 8 || stats.Held != 0 || stats.Contended == 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestPatientLocks_RedisSerializesAcrossReplicas(t *testing.T) {
+	_, client := newFakeRedis(t)
+	a := locks.NewPatientLocks(locks.NewRedisBackend(client), time.Minute, 5*time.Second)
+	b := locks.NewPatientLocks(locks.NewRedisBackend(client), time.Minute, 5*time.Second)
+	assertSerialized(t, []*locks.PatientLocks{a, b})
+
+	if a.Stats().Backend != "redis" || a.Stats().BackendErrors != 0 {
+		t.Errorf("Expected clean redis stats, got %+v", a.Stats())
+	}
+}
+
+func TestPatientLocks_RejectReportsInFlightAssessment(t *testing.T) {
+	_, client := newFakeRedis(t)
+	for name, shared := range map[string]locks.Backend{"local": nil, "redis": locks.NewRedisBackend(client)} {
+		l := locks.NewPatientLocks(shared, time.Minute, 0)
+		first, err := l.Acquire(context.Background(), 3)
+		if err != nil {
+			t.Fatalf("%s: Acquire failed: %v", name, err)
+		}
+		first.SetAssessment(42)
+
+		_, err = l.Acquire(context.Background(), 3)
+		var locked *locks.LockedError
+		if !errors.As(err, &locked) || locked.AssessmentID != 42 {
+			t.Errorf("%s: expected LockedError for assessment 42, got %v", name, err)
+		}
+		if l.Stats().Rejected != 1 {
+			t.Errorf("%s: expected one rejection, got %+v", name, l.Stats())
+		}
+
+		first.Release()
+		again, err := l.Acquire(context.Background(), 3)
+		if err != nil {
+			t.Errorf("%s: expected lock to be free after release: %v", name, err)
+		}
+		again.Release()
+	}
+}
+
+func TestPatientLocks_TTLFreesAbandonedLock(t *testing.T) {
+	_, client := newFakeRedis(t)
+	for name, shared := range map[string]locks.Backend{"local": nil, "redis": locks.NewRedisBackend(client)} {
+		l := locks.NewPatientLocks(shared, 50*time.Millisecond, time.Second)
+		stale, _ := l.Acquire(context.Background(), 9) // Holder "dies" without releasing
+
+		next, err := l.Acquire(context.Background(), 9)
+		if err != nil {
+			t.Fatalf("%s: expected TTL to free the lock: %v", name, err)
+		}
+		// The stale holder must not release the new holder's lock
+		stale.Release()
+		if _, err := locks.NewPatientLocks(shared, time.Minute, 0).Acquire(context.Background(), 9); shared != nil && !errors.Is(err, locks.ErrLocked) {
+			t.Errorf("%s: stale release freed the new holder's lock", name)
+		}
+		next.Release()
+	}
+}
+
+func TestPatientLocks_WaitTimesOut(t *testing.T) {
+	l := locks.NewPatientLocks(nil, time.Minute, 100*time.Millisecond)
+	held, _ := l.Acquire(context.Background(), 1)
+	defer held.Release()
+
+	start := time.Now()
+	if _, err := l.Acquire(context.Background(), 1); !errors.Is(err, locks.ErrLocked) {
+		t.Fatalf("Expected ErrLocked after waiting, got %v", err)
+	}
+	if time.Since(start) < 100*time.Millisecond {
+		t.Error("Expected Acquire to wait before giving up")
+	}
+	if l.Stats().Timeouts != 1 {
+		t.Errorf("Expected one timeout, got %+v", l.Stats())
+	}
+}
+
+func TestPatientLocks_FallsBackWhenRedisFails(t *testing.T) {
+	f, client := newFakeRedis(t)
+	f.down.Store(true)
+	l := locks.NewPatientLocks(locks.NewRedisBackend(client), time.Minute, 0)
+
+	lease, err := l.Acquire(context.Background(), 5)
+	if err != nil {
+		t.Fatalf("Expected local fallback, got %v", err)
+	}
+	if _, err := l.Acquire(context.Background(), 5); !errors.Is(err, locks.ErrLocked) {
+		t.Errorf("Local fallback should still serialize, got %v", err)
+	}
+	lease.Release()
+	if l.Stats().BackendErrors == 0 {
+		t.Error("Expected redis failures to be counted")
+	}
+}
+
+func TestAssessExisting_ConflictWhileLocked(t *testing.T) {
+	var hits atomic.Int64
+	ml := newFakeFullML(t, &hits)
+	h, db, _ := newTestPatientHandler(t, ml.URL, handlers.NewWebSocketHandler())
+	h.Locks = locks.NewPatientLocks(nil, time.Minute, 0)
+
+	patient := models.PatientData{Age: 60, Gender: "Male", SystolicBP: 130, DiastolicBP: 85, Glucose: 100, BMI: 27, Cholesterol: 200, HeartRate: 75,
+		Smoking: "No", Alcohol: "No", HistoryHeartDisease: "No", HistoryStroke: "No", HistoryDiabetes: "No", HistoryHighChol: "No"}
+	db.Create(&patient)
+
+	app := fiber.New()
+	app.Post("/api/patients/:id/assess", h.AssessExisting)
+	path := fmt.Sprintf("/api/patients/%d/assess", patient.ID)
+
+	inFlight, _ := h.Locks.Acquire(context.Background(), patient.ID)
+	inFlight.SetAssessment(77)
+
+	resp, err := app.Test(httptest.NewRequest("POST", path, nil))
+	if err != nil || resp.StatusCode != 409 {
+		t.Fatalf("Expected 409 while locked, got %v %v", resp.StatusCode, err)
+	}
+	var body map[string]any
+	json.NewDecoder(resp.Body).Decode(&body)
+	if body["in_flight_assessment_id"] != float64(77) {
+		t.Errorf("Expected in-flight assessment 77, got %v", body)
+	}
+	if hits.Load() != 0 {
+		t.Error("A rejected assessment must not call the ML service")
+	}
+
+	inFlight.Release()
+	resp, _ = app.Test(httptest.NewRequest("POST", path, nil), 5000)
+	if resp.StatusCode != 200 {
+		t.Errorf("Expected 200 once released, got %d", resp.StatusCode)
+	}
+	if h.Locks.Stats().Held != 0 {
+		t.Error("Handler should release its lease")
+	}
+}
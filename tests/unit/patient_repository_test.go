@@ -1,8 +1,11 @@
 package unit
 
 import (
+	"healthcare-backend/pkg/database"
 	"healthcare-backend/pkg/models"
 	"healthcare-backend/pkg/repositories"
+	"path/filepath"
+	"sync"
 	"testing"
 
 	"gorm.io/driver/sqlite"
@@ -125,3 +128,41 @@ func TestPatientRepo_GetAll_Empty(t *testing.T) {
 		t.Errorf("Expected 0 patients, got %d", len(all))
 	}
 }
+
+// TestPatientRepo_ConcurrentCreate hammers Create from many goroutines against a file-backed DB
+func TestPatientRepo_ConcurrentCreate(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "concurrent.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	db.Logger = logger.Default.LogMode(logger.Silent)
+	if err := db.AutoMigrate(&models.PatientData{}); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+	repo := repositories.NewPatientRepository(db)
+
+	const workers = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- repo.Create(&models.PatientData{Age: 20 + i, Gender: "Female"})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Concurrent create failed: %v", err)
+		}
+	}
+
+	var count int64
+	db.Model(&models.PatientData{}).Count(&count)
+	if count != workers {
+		t.Errorf("Expected %d patients, got %d", workers, count)
+	}
+}
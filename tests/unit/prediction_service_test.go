@@ -1,9 +1,13 @@
 package unit
 
 import (
+	"fmt"
 	"healthcare-backend/pkg/models"
 	"healthcare-backend/pkg/services"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // TestDiagnosisCache_SetGet tests cache set and get operations
@@ -53,6 +57,73 @@ func TestDiagnosisCache_Overwrite(t *testing.T) {
 	}
 }
 
+// TestDiagnosisCache_ConcurrentSetGet hammers the cache from many goroutines; run with -race
+func TestDiagnosisCache_ConcurrentSetGet(t *testing.T) {
+	cache := services.NewDiagnosisCache()
+	cache.MaxEntries = 50
+
+	var wg sync.WaitGroup
+	for w := 0; w < 16; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				id := uint((w*31 + i) % 200)
+				switch i % 3 {
+				case 0:
+					cache.Set(id, fmt.Sprintf("dx %d", i), "ready")
+				case 1:
+					cache.Get(id)
+				default:
+					cache.Delete(id)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if n := cache.Len(); n > 50 {
+		t.Errorf("Expected at most 50 entries, got %d", n)
+	}
+}
+
+// TestDiagnosisCache_EvictsLeastRecentlyUsed tests the size bound
+func TestDiagnosisCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := services.NewDiagnosisCache()
+	cache.MaxEntries = 2
+
+	cache.Set(1, "one", "ready")
+	cache.Set(2, "two", "ready")
+	cache.Get(1) // 2 is now least recently used
+	cache.Set(3, "three", "ready")
+
+	if _, status := cache.Get(2); status != "" {
+		t.Error("Expected patient 2 evicted")
+	}
+	if d, _ := cache.Get(1); d != "one" {
+		t.Errorf("Expected patient 1 kept, got %q", d)
+	}
+	if cache.Len() != 2 {
+		t.Errorf("Expected 2 entries, got %d", cache.Len())
+	}
+}
+
+// TestDiagnosisCache_Expires tests entries are dropped after the TTL
+func TestDiagnosisCache_Expires(t *testing.T) {
+	cache := services.NewDiagnosisCache()
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	cache.Now = func() time.Time { return now }
+
+	cache.Set(1, "dx", "ready")
+	now = now.Add(services.DiagnosisCacheTTL + time.Second)
+	if _, status := cache.Get(1); status != "" {
+		t.Errorf("Expected expired entry, got status %q", status)
+	}
+	if cache.Len() != 0 {
+		t.Errorf("Expected expired entry removed, got %d", cache.Len())
+	}
+}
+
 // TestCheckMedications_Risky tests detection of risky medications
 func TestCheckMedications_Risky(t *testing.T) {
 	service := &services.PredictionService{}
@@ -157,3 +228,106 @@ func TestHashVitals(t *testing.T) {
 		t.Error("Expected different patients to have different hash")
 	}
 }
+
+// TestCheckMedications_Interactions tests that flagged medications come with
+// the interaction behind them, resolving brand names case-insensitively
+func TestCheckMedications_Interactions(t *testing.T) {
+	service := &services.PredictionService{}
+
+	result := service.CheckMedications("coumadin 5mg, ADVIL, Lisinopril")
+	if len(result.Risky) != 3 || len(result.Safe) != 0 {
+		t.Fatalf("Expected all three risky, got risky=%v safe=%v", result.Risky, result.Safe)
+	}
+	// Warfarin+NSAIDs, Lisinopril+NSAIDs and the NSAIDs kidney caution
+	if len(result.Interactions) != 3 {
+		t.Fatalf("Expected 3 interactions, got %+v", result.Interactions)
+	}
+	if first := result.Interactions[0]; first.DrugA != "Warfarin" || first.DrugB != "NSAIDs" || first.Severity != services.SeverityMajor {
+		t.Errorf("Expected the major interaction first, got %+v", first)
+	}
+
+	// A condition interaction needs only the one drug
+	result = service.CheckMedications("Glucophage")
+	if len(result.Interactions) != 1 || result.Interactions[0].DrugB != "Contrast" || result.Interactions[0].Reason == "" {
+		t.Errorf("Expected Metformin + Contrast with a reason, got %+v", result.Interactions)
+	}
+}
+
+// TestHashVitals_CoversThePredictPayload tests that every field sent to
+// /predict changes the hash, and nothing else does
+func TestHashVitals_CoversThePredictPayload(t *testing.T) {
+	service := services.NewPredictionService("http://localhost:8000")
+	base := models.PatientData{Age: 45, Gender: "Male", SystolicBP: 120, DiastolicBP: 80, Glucose: 100, BMI: 24.5, Cholesterol: 190, HeartRate: 70, Smoking: "No"}
+	hash := service.HashVitals(base)
+
+	changed := []func(p *models.PatientData){
+		func(p *models.PatientData) { p.DiastolicBP = 95 },
+		func(p *models.PatientData) { p.HeartRate = 110 },
+		func(p *models.PatientData) { p.Gender = "Female" },
+		func(p *models.PatientData) { p.Symptoms = "chest pain" },
+		func(p *models.PatientData) { p.HistoryStroke = "Yes" },
+		func(p *models.PatientData) { p.BMI = 24.51 }, // Beyond the old %.2f rounding
+	}
+	for i, change := range changed {
+		p := base
+		change(&p)
+		if service.HashVitals(p) == hash {
+			t.Errorf("Change %d: expected a different hash", i)
+		}
+	}
+
+	// Identity isn't part of the model input, so patients share a hash
+	other := base
+	other.ID, other.Name, other.Clinic = 99, "Someone Else", "north"
+	if service.HashVitals(other) != hash {
+		t.Error("Expected identical vitals to hash the same across patients")
+	}
+}
+
+// TestPredictRisks_CountsCacheMisses tests the hit/miss counters without Redis
+func TestPredictRisks_CountsCacheMisses(t *testing.T) {
+	var hits atomic.Int64
+	service := services.NewPredictionService(newFakeFullML(t, &hits).URL)
+	for i := 0; i < 2; i++ {
+		if _, err := service.PredictRisks(models.PatientData{ID: uint(i + 1), Age: 45, SystolicBP: 120}); err != nil {
+			t.Fatalf("PredictRisks failed: %v", err)
+		}
+	}
+	if stats := service.CacheStats(); stats.Misses != 2 || stats.Hits != 0 || stats.HitRate != 0 {
+		t.Errorf("Expected two misses with no cache, got %+v", stats)
+	}
+}
+
+func TestRuleBasedPredictRisks_KidneyHeuristic(t *testing.T) {
+	service := services.NewPredictionService("http://localhost:8000")
+
+	// 70-year-old diabetic with hypertension
+	risks := service.RuleBasedPredictRisks(models.PatientData{Age: 70, SystolicBP: 150, Glucose: 160, BMI: 28, Cholesterol: 190, HistoryDiabetes: "Yes"})
+	if risks.KidneyRisk < 50 {
+		t.Errorf("Expected a high fallback kidney risk, got %v", risks.KidneyRisk)
+	}
+	want := models.ScoreScale - (risks.HeartRisk+risks.DiabetesRisk+risks.StrokeRisk+risks.KidneyRisk)/4
+	if risks.GeneralHealthScore != want {
+		t.Errorf("Expected the general health score over all four risks (%v), got %v", want, risks.GeneralHealthScore)
+	}
+	for _, model := range []string{"Heart_Model", "Diabetes_Model", "Stroke_Model", "Kidney_Model"} {
+		if c := risks.ModelPrecisions[model]; c <= 0 || c >= models.ScoreScale {
+			t.Errorf("%s: expected a per-model fallback confidence, got %v", model, c)
+		}
+	}
+	if !services.IsRuleBased(risks) {
+		t.Error("Expected the result still recognised as rule-based")
+	}
+
+	// Young and normotensive stays low
+	if low := service.RuleBasedPredictRisks(models.PatientData{Age: 30, SystolicBP: 115, Glucose: 90, BMI: 22}); low.KidneyRisk > 10 {
+		t.Errorf("Expected a low kidney risk, got %v", low.KidneyRisk)
+	}
+
+	// Unmeasured rule inputs lower that model's confidence
+	measured := service.RuleBasedPredictRisks(models.PatientData{Age: 50, SystolicBP: 130, Cholesterol: 190})
+	missing := service.RuleBasedPredictRisks(models.PatientData{Age: 50, SystolicBP: 130, ImputedFields: "cholesterol"})
+	if missing.ModelPrecisions["Heart_Model"] >= measured.ModelPrecisions["Heart_Model"] {
+		t.Errorf("Expected less heart confidence without cholesterol, got %v vs %v", missing.ModelPrecisions["Heart_Model"], measured.ModelPrecisions["Heart_Model"])
+	}
+}
@@ -0,0 +1,130 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupProviderApp(t *testing.T) (*fiber.App, *gorm.DB, *services.ProviderService) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	db.AutoMigrate(&models.Provider{}, &models.PatientData{}, &models.AuditLog{})
+
+	providers := services.NewProviderService(db)
+	h := handlers.NewProviderHandler(providers, services.NewAuditService(db), handlers.NewWebSocketHandler())
+	app := fiber.New()
+	app.Post("/api/patients/:id/assign", h.AssignPatient)
+	return app, db, providers
+}
+
+func newProvider(t *testing.T, svc *services.ProviderService, name string, onCall bool) models.Provider {
+	p := models.Provider{Name: name, Specialty: "Cardiology", OnCall: onCall}
+	if err := svc.Create(&p); err != nil {
+		t.Fatalf("Create provider failed: %v", err)
+	}
+	return p
+}
+
+func assign(t *testing.T, app *fiber.App, patientID uint, providerID uint) (int, models.PatientData) {
+	body := `{"provider_id":` + jsonNumber(providerID) + `}`
+	req := httptest.NewRequest("POST", "/api/patients/"+jsonNumber(patientID)+"/assign", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	var p models.PatientData
+	json.NewDecoder(resp.Body).Decode(&p)
+	return resp.StatusCode, p
+}
+
+// TestProvider_AssignAndReassign tests assignment, reassignment and their audit trail
+func TestProvider_AssignAndReassign(t *testing.T) {
+	app, db, svc := setupProviderApp(t)
+	house := newProvider(t, svc, "Dr. House", false)
+	wilson := newProvider(t, svc, "Dr. Wilson", true)
+
+	patient := models.PatientData{Age: 60, Gender: "Male"}
+	db.Create(&patient)
+
+	code, got := assign(t, app, patient.ID, house.ID)
+	if code != 200 || got.AssignedProvider == nil || got.AssignedProvider.Name != "Dr. House" {
+		t.Fatalf("Expected assignment to Dr. House, got %d %+v", code, got.AssignedProvider)
+	}
+	if code, _ := assign(t, app, patient.ID, wilson.ID); code != 200 {
+		t.Fatalf("Expected reassignment to succeed, got %d", code)
+	}
+	if code, _ := assign(t, app, patient.ID, 999); code != 404 {
+		t.Errorf("Expected 404 for unknown provider, got %d", code)
+	}
+	if code, _ := assign(t, app, 999, house.ID); code != 404 {
+		t.Errorf("Expected 404 for unknown patient, got %d", code)
+	}
+
+	var stored models.PatientData
+	db.First(&stored, patient.ID)
+	if stored.AssignedProviderID == nil || *stored.AssignedProviderID != wilson.ID {
+		t.Errorf("Expected Dr. Wilson assigned, got %v", stored.AssignedProviderID)
+	}
+
+	var events []models.AuditLog
+	db.Where("event_type IN ?", []string{"PATIENT_ASSIGNED", "PATIENT_REASSIGNED"}).Order("id").Find(&events)
+	if len(events) != 2 || events[0].EventType != "PATIENT_ASSIGNED" || events[1].EventType != "PATIENT_REASSIGNED" {
+		t.Fatalf("Expected assignment then reassignment audited, got %d events", len(events))
+	}
+}
+
+// TestProvider_EscalationPrefersAssigned tests notification routing order
+func TestProvider_EscalationPrefersAssigned(t *testing.T) {
+	_, _, svc := setupProviderApp(t)
+	assigned := newProvider(t, svc, "Dr. Assigned", false)
+	onCallA := newProvider(t, svc, "Dr. A", true)
+	onCallB := newProvider(t, svc, "Dr. B", true)
+	newProvider(t, svc, "Dr. Off", false)
+
+	names := func(ps []models.Provider) []string {
+		out := []string{}
+		for _, p := range ps {
+			out = append(out, p.Name)
+		}
+		return out
+	}
+
+	// Unassigned patients go straight to on-call providers
+	targets, _ := svc.EscalationTargets(models.PatientData{})
+	if got := strings.Join(names(targets), ","); got != "Dr. A,Dr. B" {
+		t.Errorf("Expected on-call fallback, got %s", got)
+	}
+
+	// The assigned provider comes first even when not on call
+	targets, _ = svc.EscalationTargets(models.PatientData{AssignedProviderID: &assigned.ID})
+	if got := strings.Join(names(targets), ","); got != "Dr. Assigned,Dr. A,Dr. B" {
+		t.Errorf("Expected assigned provider first, got %s", got)
+	}
+
+	// An on-call assigned provider isn't notified twice
+	targets, _ = svc.EscalationTargets(models.PatientData{AssignedProviderID: &onCallB.ID})
+	if got := strings.Join(names(targets), ","); got != "Dr. B,Dr. A" {
+		t.Errorf("Expected no duplicate for on-call assignee, got %s", got)
+	}
+
+	// Inactive providers are skipped
+	svc.SetStatus(assigned.ID, false, false)
+	targets, _ = svc.EscalationTargets(models.PatientData{AssignedProviderID: &assigned.ID})
+	if len(targets) != 2 || targets[0].ID != onCallA.ID {
+		t.Errorf("Expected inactive assignee skipped, got %v", names(targets))
+	}
+}
@@ -0,0 +1,166 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+
+	"github.com/fasthttp/websocket"
+	contribws "github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestQueueWS_CreateUpdateDelete tests that queue events reach subscribers in sequence order
+func TestQueueWS_CreateUpdateDelete(t *testing.T) {
+	var predictHits atomic.Int64
+	ml := newFakeFullML(t, &predictHits)
+	ws := handlers.NewWebSocketHandler()
+	h, _, _ := newTestPatientHandler(t, ml.URL, ws)
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/ws/diagnostics", contribws.New(ws.HandleConnection))
+	app.Post("/api/assess", h.AssessPatient)
+	app.Put("/api/patients/:id", h.UpdatePatient)
+	app.Delete("/api/patients/:id", h.DeletePatient)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	go app.Listener(ln)
+	t.Cleanup(func() { app.Shutdown() })
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+ln.Addr().String()+"/ws/diagnostics", nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	// A second client that never subscribes must not receive queue events
+	idle, _, err := websocket.DefaultDialer.Dial("ws://"+ln.Addr().String()+"/ws/diagnostics", nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { idle.Close() })
+
+	conn.WriteJSON(map[string]string{"type": "subscribe_queue"})
+	time.Sleep(100 * time.Millisecond) // let the server register the subscription
+
+	send := func(method, path string, body any) int {
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		return resp.StatusCode
+	}
+
+	patient := models.PatientData{Age: 44, Gender: "Female", SystolicBP: 125, DiastolicBP: 80, Glucose: 95, BMI: 23, Cholesterol: 190, HeartRate: 70,
+		Smoking: "No", Alcohol: "No", HistoryHeartDisease: "No", HistoryStroke: "No", HistoryDiabetes: "No", HistoryHighChol: "No"}
+	if code := send("POST", "/api/assess", patient); code != 200 {
+		t.Fatalf("Assess returned %d", code)
+	}
+	patient.Age = 45
+	if code := send("PUT", "/api/patients/1", patient); code != 200 {
+		t.Fatalf("Update returned %d", code)
+	}
+	if code := send("DELETE", "/api/patients/1", nil); code != 204 {
+		t.Fatalf("Delete returned %d", code)
+	}
+
+	var events []models.QueueEvent
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for len(events) < 3 {
+		var event models.QueueEvent
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("Expected 3 queue events, got %d: %v", len(events), err)
+		}
+		if event.Type == "queue_update" {
+			events = append(events, event)
+		}
+	}
+
+	wantActions := []string{"created", "updated", "deleted"}
+	for i, event := range events {
+		if event.Action != wantActions[i] {
+			t.Errorf("Event %d: expected %s, got %s", i, wantActions[i], event.Action)
+		}
+		if event.Patient.ID != 1 {
+			t.Errorf("Event %d: expected patient 1, got %d", i, event.Patient.ID)
+		}
+		if i > 0 && event.Seq <= events[i-1].Seq {
+			t.Errorf("Event %d: sequence %d not after %d", i, event.Seq, events[i-1].Seq)
+		}
+	}
+	if events[1].Patient.Age != 45 {
+		t.Errorf("Expected updated age 45, got %d", events[1].Patient.Age)
+	}
+
+	idle.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, msg, err := idle.ReadMessage(); err == nil {
+		t.Errorf("Unsubscribed client received %s", msg)
+	}
+}
+
+// TestQueueWS_Validation tests update/delete on missing patients and invalid bodies
+func TestQueueWS_Validation(t *testing.T) {
+	var predictHits atomic.Int64
+	ml := newFakeFullML(t, &predictHits)
+	h, db, _ := newTestPatientHandler(t, ml.URL, handlers.NewWebSocketHandler())
+	db.Create(&models.PatientData{Age: 30, Gender: "Male"})
+
+	app := fiber.New()
+	app.Put("/api/patients/:id", h.UpdatePatient)
+	app.Delete("/api/patients/:id", h.DeletePatient)
+
+	resp, _ := app.Test(httptest.NewRequest("DELETE", "/api/patients/99", nil))
+	if resp.StatusCode != 404 {
+		t.Errorf("Expected 404 for missing patient, got %d", resp.StatusCode)
+	}
+
+	req := httptest.NewRequest("PUT", "/api/patients/1", bytes.NewReader([]byte(`{"age": 400, "gender": "Male"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ = app.Test(req)
+	if resp.StatusCode != 400 {
+		t.Errorf("Expected 400 for invalid patient, got %d", resp.StatusCode)
+	}
+}
+
+// TestDeletePatient_SoftDeletes tests that a deleted patient disappears from
+// the API but its row stays behind for the audit trail
+func TestDeletePatient_SoftDeletes(t *testing.T) {
+	h, db, _ := newTestPatientHandler(t, "http://ml.invalid", handlers.NewWebSocketHandler())
+	patient := models.PatientData{Age: 30, Gender: "Male"}
+	db.Create(&patient)
+
+	app := fiber.New()
+	app.Delete("/api/patients/:id", h.DeletePatient)
+	path := fmt.Sprintf("/api/patients/%d", patient.ID)
+
+	if resp, _ := app.Test(httptest.NewRequest("DELETE", path, nil)); resp.StatusCode != 204 {
+		t.Fatalf("Expected 204, got %d", resp.StatusCode)
+	}
+	if resp, _ := app.Test(httptest.NewRequest("DELETE", path, nil)); resp.StatusCode != 404 {
+		t.Errorf("Expected a deleted patient to be gone, got %d", resp.StatusCode)
+	}
+
+	var stored models.PatientData
+	if err := db.Unscoped().First(&stored, patient.ID).Error; err != nil || !stored.DeletedAt.Valid {
+		t.Errorf("Expected the row kept with deleted_at set, got %+v (%v)", stored, err)
+	}
+	var audited int64
+	db.Model(&models.AuditLog{}).Where("event_type = ?", "PATIENT_DELETED").Count(&audited)
+	if audited != 1 {
+		t.Errorf("Expected one PATIENT_DELETED audit event, got %d", audited)
+	}
+}
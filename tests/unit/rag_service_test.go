@@ -1,11 +1,18 @@
 package unit
 
 import (
+	"context"
+	"errors"
 	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/repositories"
 	"healthcare-backend/pkg/services"
 	"testing"
+	"time"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 // -- Mocks --
@@ -32,6 +39,26 @@ func (m *MockPatientRepo) GetAll() ([]models.PatientData, error) {
 	return args.Get(0).([]models.PatientData), args.Error(1)
 }
 
+func (m *MockPatientRepo) List(f repositories.PatientFilter) (*models.PatientPage, error) {
+	args := m.Called(f)
+	return args.Get(0).(*models.PatientPage), args.Error(1)
+}
+
+func (m *MockPatientRepo) Update(p *models.PatientData) error {
+	args := m.Called(p)
+	return args.Error(0)
+}
+
+func (m *MockPatientRepo) Delete(id uint) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockPatientRepo) KnownNames() ([]string, error) {
+	args := m.Called()
+	return args.Get(0).([]string), args.Error(1)
+}
+
 type MockFeedbackRepo struct {
 	mock.Mock
 }
@@ -46,8 +73,62 @@ func (m *MockFeedbackRepo) GetApproved() ([]models.Feedback, error) {
 	return args.Get(0).([]models.Feedback), args.Error(1)
 }
 
+func (m *MockFeedbackRepo) GetApprovedWithPatients(limit int) ([]repositories.ApprovedCase, error) {
+	args := m.Called(limit)
+	return args.Get(0).([]repositories.ApprovedCase), args.Error(1)
+}
+
+func (m *MockFeedbackRepo) ListForPatient(patientID uint) ([]models.Feedback, error) {
+	args := m.Called(patientID)
+	return args.Get(0).([]models.Feedback), args.Error(1)
+}
+
+func (m *MockFeedbackRepo) List(f repositories.FeedbackFilter) (*models.FeedbackPage, error) {
+	args := m.Called(f)
+	return args.Get(0).(*models.FeedbackPage), args.Error(1)
+}
+
+func (m *MockFeedbackRepo) GetByID(id uint) (*models.Feedback, error) {
+	args := m.Called(id)
+	if f, ok := args.Get(0).(*models.Feedback); ok {
+		return f, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockFeedbackRepo) Amend(id uint, notes, reason, authorID string) (*models.FeedbackRevision, error) {
+	args := m.Called(id, notes, reason, authorID)
+	if r, ok := args.Get(0).(*models.FeedbackRevision); ok {
+		return r, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockFeedbackRepo) Revisions(feedbackID uint) ([]models.FeedbackRevision, error) {
+	args := m.Called(feedbackID)
+	return args.Get(0).([]models.FeedbackRevision), args.Error(1)
+}
+
+type MockEmbeddingClient struct {
+	mock.Mock
+}
+
+func (m *MockEmbeddingClient) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	args := m.Called(ctx, texts)
+	vectors, _ := args.Get(0).([][]float32)
+	return vectors, args.Error(1)
+}
+
 // -- Tests --
 
+// approved is an approved case on patient p, as the joined query returns it
+func approved(p models.PatientData, notes string) repositories.ApprovedCase {
+	return repositories.ApprovedCase{
+		Feedback: models.Feedback{PatientID: p.ID, DoctorApproved: true, DoctorNotes: notes},
+		Patient:  p,
+	}
+}
+
 func TestFindSimilarCases(t *testing.T) {
 	// Setup Mocks
 	mockP := new(MockPatientRepo)
@@ -63,24 +144,25 @@ func TestFindSimilarCases(t *testing.T) {
 		ID: 1, Age: 46, SystolicBP: 122, Glucose: 105, BMI: 25.5,
 	}
 
-	feedbacks := []models.Feedback{
-		{PatientID: 1, DoctorApproved: true, DoctorNotes: "Patient responded well to ACE inhibitors."},
-	}
-
-	// Expectations
-	mockF.On("GetApproved").Return(feedbacks, nil)
-	mockP.On("GetByID", uint(1)).Return(&histPatient, nil)
+	// Expectations: one query for the most recent candidates, no lookups per case
+	mockF.On("GetApprovedWithPatients", services.DefaultRAGMaxCandidates).
+		Return([]repositories.ApprovedCase{approved(histPatient, "Patient responded well to ACE inhibitors.")}, nil)
 
 	// Execute
-	result := rag.FindSimilarCases(currentPatient)
+	result, cases := rag.FindSimilarCases(currentPatient, 0)
 
 	// Assertions
 	assert.Contains(t, result, "PAST SIMILAR CLINICAL CASES")
 	assert.Contains(t, result, "responded well to ACE inhibitors")
 	assert.Contains(t, result, "Dist:") // Should have distance score
-	
+	if assert.Len(t, cases, 1) {
+		assert.Equal(t, uint(1), cases[0].PatientID)
+		assert.Equal(t, "Patient responded well to ACE inhibitors.", cases[0].Notes)
+		assert.InDelta(t, 0.03, cases[0].Distance, 0.01)
+	}
+
 	mockF.AssertExpectations(t)
-	mockP.AssertExpectations(t)
+	mockP.AssertNotCalled(t, "GetByID", mock.Anything)
 }
 
 func TestFindSimilarCases_NoFeedbacks(t *testing.T) {
@@ -88,9 +170,270 @@ func TestFindSimilarCases_NoFeedbacks(t *testing.T) {
 	mockF := new(MockFeedbackRepo)
 	rag := services.NewRAGService(mockP, mockF)
 
-	mockF.On("GetApproved").Return([]models.Feedback{}, nil)
+	mockF.On("GetApprovedWithPatients", mock.Anything).Return([]repositories.ApprovedCase{}, nil)
 
-	result := rag.FindSimilarCases(models.PatientData{})
+	result, cases := rag.FindSimilarCases(models.PatientData{}, 0)
 
 	assert.Contains(t, result, "None available")
+	assert.NotNil(t, cases)
+	assert.Empty(t, cases)
+}
+
+func TestFindSimilarCases_ExcludesOwnFeedbackAndDistantCases(t *testing.T) {
+	mockP := new(MockPatientRepo)
+	mockF := new(MockFeedbackRepo)
+	rag := services.NewRAGService(mockP, mockF)
+
+	current := models.PatientData{ID: 7, Age: 45, SystolicBP: 120, Glucose: 100, BMI: 25}
+	mockF.On("GetApprovedWithPatients", mock.Anything).Return([]repositories.ApprovedCase{
+		approved(current, "Own earlier visit"),
+		approved(models.PatientData{ID: 1, Age: 47, SystolicBP: 125, Glucose: 110, BMI: 26}, "Near case"),
+		approved(models.PatientData{ID: 2, Age: 85, SystolicBP: 200, Glucose: 300, BMI: 45}, "Distant case"),
+	}, nil)
+
+	result, cases := rag.FindSimilarCases(current, current.ID)
+
+	assert.NotContains(t, result, "Own earlier visit")
+	assert.NotContains(t, result, "Distant case")
+	if assert.Len(t, cases, 1) {
+		assert.Equal(t, uint(1), cases[0].PatientID)
+	}
+
+	// Without a cutoff the distant case comes back, nearest first
+	rag.MaxDistance = 0
+	_, cases = rag.FindSimilarCases(current, current.ID)
+	if assert.Len(t, cases, 2) {
+		assert.Equal(t, []uint{1, 2}, []uint{cases[0].PatientID, cases[1].PatientID})
+		assert.Greater(t, cases[1].Distance, services.DefaultRAGMaxDistance)
+	}
+}
+
+func TestFindSimilarCases_TopKAndCandidateCap(t *testing.T) {
+	mockP := new(MockPatientRepo)
+	mockF := new(MockFeedbackRepo)
+	rag := services.NewRAGService(mockP, mockF)
+	rag.K = 2
+	rag.MaxCandidates = 50
+
+	var candidates []repositories.ApprovedCase
+	for id := uint(1); id <= 4; id++ {
+		candidates = append(candidates, approved(models.PatientData{ID: id, Age: 40 + int(id), SystolicBP: 120, Glucose: 100, BMI: 25}, "Case"))
+	}
+	mockF.On("GetApprovedWithPatients", 50).Return(candidates, nil)
+
+	_, cases := rag.FindSimilarCases(models.PatientData{Age: 40, SystolicBP: 120, Glucose: 100, BMI: 25}, 0)
+	if assert.Len(t, cases, 2) {
+		assert.Equal(t, []uint{1, 2}, []uint{cases[0].PatientID, cases[1].PatientID})
+	}
+	mockF.AssertExpectations(t)
+}
+
+// The 46-year-old smoking man with a prior stroke from the request, and
+// two past cases with identical vitals
+func comorbidityCases(mockP *MockPatientRepo, mockF *MockFeedbackRepo) models.PatientData {
+	vitals := models.PatientData{Age: 46, SystolicBP: 150, Glucose: 110, BMI: 28}
+	current, matching, different := vitals, vitals, vitals
+	current.ID, matching.ID, different.ID = 10, 1, 2
+	current.Gender, current.Smoking, current.HistoryStroke = "Male", "Yes", "Yes"
+	matching.Gender, matching.Smoking, matching.HistoryStroke = "Male", "Yes", "Yes"
+	different.Gender, different.Smoking, different.HistoryStroke = "Female", "No", "No"
+
+	mockF.On("GetApprovedWithPatients", mock.Anything).Return([]repositories.ApprovedCase{
+		approved(different, "Never-smoker, no stroke"),
+		approved(matching, "Smoker after a stroke"),
+	}, nil)
+	return current
+}
+
+func TestFindSimilarCases_ComorbiditiesChangeRanking(t *testing.T) {
+	mockP := new(MockPatientRepo)
+	mockF := new(MockFeedbackRepo)
+	rag := services.NewRAGService(mockP, mockF)
+	current := comorbidityCases(mockP, mockF)
+
+	_, cases := rag.FindSimilarCases(current, current.ID)
+	if assert.Len(t, cases, 2) {
+		assert.Equal(t, uint(1), cases[0].PatientID)
+		assert.Zero(t, cases[0].Distance)
+		// Three mismatches at 0.2 each: rank second, but still close enough to keep
+		assert.InDelta(t, 0.346, cases[1].Distance, 0.001)
+	}
+
+	// Vitals alone can't tell them apart
+	rag.Weights = services.RAGWeights{Age: 1, SystolicBP: 1, Glucose: 1, BMI: 1}
+	_, cases = rag.FindSimilarCases(current, current.ID)
+	if assert.Len(t, cases, 2) {
+		assert.Equal(t, cases[0].Distance, cases[1].Distance)
+	}
+
+	// A heavy stroke weight pushes the mismatch past the cutoff
+	rag.Weights, _ = services.ParseRAGWeights("history_stroke=1")
+	_, cases = rag.FindSimilarCases(current, current.ID)
+	if assert.Len(t, cases, 1) {
+		assert.Equal(t, uint(1), cases[0].PatientID)
+	}
+}
+
+func TestFindSimilarCases_UnknownCategoriesMatch(t *testing.T) {
+	mockP := new(MockPatientRepo)
+	mockF := new(MockFeedbackRepo)
+	rag := services.NewRAGService(mockP, mockF)
+	comorbidityCases(mockP, mockF)
+
+	// The MCP tool only sends vitals
+	_, cases := rag.FindSimilarCases(models.PatientData{Age: 46, SystolicBP: 150, Glucose: 110, BMI: 28}, 0)
+	if assert.Len(t, cases, 2) {
+		assert.Zero(t, cases[0].Distance)
+		assert.Zero(t, cases[1].Distance)
+	}
+}
+
+func TestParseRAGWeights(t *testing.T) {
+	w, err := services.ParseRAGWeights("")
+	assert.NoError(t, err)
+	assert.Equal(t, services.DefaultRAGWeights, w)
+
+	w, err = services.ParseRAGWeights(" gender=0.5, history_diabetes=0 ")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.5, w.Gender)
+	assert.Zero(t, w.Diabetes)
+	assert.Equal(t, services.DefaultRAGWeights.Smoking, w.Smoking)
+
+	for _, bad := range []string{"height=1", "gender", "gender=heavy", "gender=-1"} {
+		_, err := services.ParseRAGWeights(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func openRAGDB(t testing.TB) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1) // One in-memory database for every query
+	db.AutoMigrate(&models.PatientData{}, &models.Feedback{}, &models.NoteEmbedding{})
+	return db
+}
+
+// seedRAGCorpus writes n approved cases, one patient each, a minute apart
+func seedRAGCorpus(t testing.TB, db *gorm.DB, n int) {
+	start := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		p := models.PatientData{Name: "Case", Age: 30 + i%50, Gender: "Female", SystolicBP: 110 + i%70, Glucose: 80 + i%150, BMI: 20 + float64(i%15),
+			Smoking: "No", HistoryHeartDisease: "No", HistoryStroke: "No", HistoryDiabetes: "No", HistoryHighChol: "No"}
+		if err := db.Create(&p).Error; err != nil {
+			t.Fatalf("Seeding patient failed: %v", err)
+		}
+		db.Create(&models.Feedback{CreatedAt: start.Add(time.Duration(i) * time.Minute), PatientID: p.ID, DoctorApproved: true, DoctorNotes: "Approved case"})
+	}
+}
+
+func TestFeedbackRepository_GetApprovedWithPatients(t *testing.T) {
+	db := openRAGDB(t)
+	seedRAGCorpus(t, db, 4)
+	db.Create(&models.Feedback{PatientID: 1, DoctorApproved: false, DoctorNotes: "Rejected"})
+	db.Delete(&models.PatientData{}, 2)
+
+	cases, err := repositories.NewFeedbackRepository(db).GetApprovedWithPatients(2)
+	if err != nil {
+		t.Fatalf("GetApprovedWithPatients failed: %v", err)
+	}
+	// Newest first, capped, and without the deleted patient's case
+	if assert.Len(t, cases, 2) {
+		assert.Equal(t, []uint{4, 3}, []uint{cases[0].PatientID, cases[1].PatientID})
+		assert.Equal(t, cases[0].PatientID, cases[0].Patient.ID)
+		assert.Equal(t, 33, cases[0].Patient.Age)
+		assert.Equal(t, "Female", cases[0].Patient.Gender)
+		assert.Empty(t, cases[0].Patient.Name, "Only the features are read")
+	}
+
+	stats, err := services.NewRAGService(repositories.NewPatientRepository(db), repositories.NewFeedbackRepository(db)).Reindex()
+	assert.NoError(t, err)
+	assert.Equal(t, services.RAGIndexStats{Approved: 4, Indexed: 3, Orphaned: 1}, stats)
+}
+
+// BenchmarkFindSimilarCases compares the joined, capped query with the
+// lookup per approved case it replaced, over 5000 approved cases
+func BenchmarkFindSimilarCases(b *testing.B) {
+	db := openRAGDB(b)
+	seedRAGCorpus(b, db, 5000)
+	patients, feedback := repositories.NewPatientRepository(db), repositories.NewFeedbackRepository(db)
+	current := models.PatientData{Age: 52, Gender: "Female", SystolicBP: 140, Glucose: 120, BMI: 27, Smoking: "No"}
+
+	b.Run("joined", func(b *testing.B) {
+		rag := services.NewRAGService(patients, feedback)
+		rag.CacheTTL = 0
+		for i := 0; i < b.N; i++ {
+			rag.FindSimilarCases(current, 0)
+		}
+	})
+	b.Run("lookup_per_case", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			approved, _ := feedback.GetApproved()
+			for _, f := range approved {
+				patients.GetByID(f.PatientID)
+			}
+		}
+	})
+}
+
+func TestFindSimilarCases_BlendsNoteEmbeddings(t *testing.T) {
+	db := openRAGDB(t)
+	client := new(MockEmbeddingClient)
+	notes := services.NewNoteEmbeddingService(db, client, "test-embed", nil)
+
+	current := models.PatientData{Age: 60, SystolicBP: 140, Glucose: 110, BMI: 28, Symptoms: "chest pain, left arm pain"}
+	// Closest vitals, notes about something else
+	hypertension := approved(models.PatientData{ID: 1, Age: 60, SystolicBP: 141, Glucose: 110, BMI: 28}, "Hypertension, well controlled on amlodipine")
+	hypertension.ID = 11
+	// Further vitals, notes about the same complaint
+	angina := approved(models.PatientData{ID: 2, Age: 66, SystolicBP: 150, Glucose: 120, BMI: 29}, "Exertional chest pain radiating to the left arm, started on nitrates")
+	angina.ID = 12
+	// Not embedded yet
+	unindexed := approved(models.PatientData{ID: 3, Age: 62, SystolicBP: 142, Glucose: 112, BMI: 28}, "Routine follow-up")
+	unindexed.ID = 13
+
+	client.On("Embed", mock.Anything, []string{hypertension.DoctorNotes}).Return([][]float32{{0, 1}}, nil)
+	client.On("Embed", mock.Anything, []string{angina.DoctorNotes}).Return([][]float32{{1, 0}}, nil)
+	for _, c := range []repositories.ApprovedCase{hypertension, angina} {
+		if err := notes.Embed(context.Background(), c.Feedback); err != nil {
+			t.Fatalf("Embed failed: %v", err)
+		}
+	}
+
+	mockF := new(MockFeedbackRepo)
+	mockF.On("GetApprovedWithPatients", services.DefaultRAGMaxCandidates).
+		Return([]repositories.ApprovedCase{hypertension, angina, unindexed}, nil)
+	rag := services.NewRAGService(new(MockPatientRepo), mockF)
+	rag.CacheTTL = 0
+	rag.MaxDistance = 0
+
+	// Vitals alone put the hypertension case first
+	_, plain := rag.FindSimilarCases(current, 0)
+	if assert.Len(t, plain, 3) {
+		assert.Equal(t, []uint{1, 3, 2}, []uint{plain[0].PatientID, plain[1].PatientID, plain[2].PatientID})
+	}
+
+	rag.Notes = notes
+	rag.NoteWeight = 0.7
+	client.On("Embed", mock.Anything, []string{current.Symptoms}).Return([][]float32{{0.9, 0.1}}, nil).Once()
+	_, blended := rag.FindSimilarCases(current, 0)
+	if assert.Len(t, blended, 3) {
+		assert.Equal(t, []uint{2, 1, 3}, []uint{blended[0].PatientID, blended[1].PatientID, blended[2].PatientID},
+			"Matching notes outweigh closer vitals")
+		assert.InDelta(t, 0.3*plain[1].Distance+0.7*0.5, blended[2].Distance, 1e-9, "Unembedded notes sit halfway")
+	}
+
+	// A failing embedding service falls back to vitals
+	client.On("Embed", mock.Anything, []string{current.Symptoms}).Return(nil, errors.New("connection refused")).Once()
+	_, fallback := rag.FindSimilarCases(current, 0)
+	assert.Equal(t, plain, fallback)
+
+	// Without symptoms there's nothing to compare the notes with
+	noSymptoms := current
+	noSymptoms.Symptoms = ""
+	_, vitalsOnly := rag.FindSimilarCases(noSymptoms, 0)
+	assert.Equal(t, plain, vitalsOnly)
+	client.AssertNumberOfCalls(t, "Embed", 4)
 }
@@ -0,0 +1,76 @@
+package unit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestReassess_UpdatesInPlaceAndAudits(t *testing.T) {
+	var predictHits atomic.Int64
+	ml := newFakeFullML(t, &predictHits)
+	h, db, _ := newTestPatientHandler(t, ml.URL, handlers.NewWebSocketHandler())
+
+	patient := models.PatientData{Age: 58, Gender: "Male", SystolicBP: 135, DiastolicBP: 85, Glucose: 100, BMI: 27, Cholesterol: 200, HeartRate: 75,
+		Smoking: "No", Alcohol: "No", HistoryHeartDisease: "No", HistoryStroke: "No", HistoryDiabetes: "No", HistoryHighChol: "No"}
+	db.Create(&patient)
+
+	app := fiber.New()
+	app.Post("/api/patients/:id/reassess", h.Reassess)
+	reassess := func(id uint, body string) (int, models.FullAssessmentResponse) {
+		req := httptest.NewRequest("POST", fmt.Sprintf("/api/patients/%d/reassess", id), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		var result models.FullAssessmentResponse
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	code, result := reassess(patient.ID, `{"systolic_bp":170}`)
+	if code != 200 || result.ID != patient.ID || result.AssessmentID == 0 {
+		t.Fatalf("Expected an assessment of patient %d, got %d %+v", patient.ID, code, result)
+	}
+	if result.Patient.SystolicBP != 170 || result.Patient.Glucose != 100 {
+		t.Errorf("Expected the changed vitals merged, got %+v", result.Patient)
+	}
+
+	var count int64
+	db.Model(&models.PatientData{}).Count(&count)
+	var stored models.PatientData
+	db.First(&stored, patient.ID)
+	if count != 1 || stored.SystolicBP != 170 {
+		t.Errorf("Expected the row updated in place, got %d rows, SBP %d", count, stored.SystolicBP)
+	}
+
+	var audited int64
+	db.Model(&models.AuditLog{}).Where("event_type = ?", "PATIENT_REASSESSED").Count(&audited)
+	if audited != 1 {
+		t.Errorf("Expected one PATIENT_REASSESSED audit event, got %d", audited)
+	}
+
+	// No body re-runs on the stored vitals
+	if code, _ := reassess(patient.ID, ""); code != 200 {
+		t.Errorf("Expected a bodiless re-run to succeed, got %d", code)
+	}
+	if predictHits.Load() != 2 {
+		t.Errorf("Expected the model called for each run, got %d calls", predictHits.Load())
+	}
+
+	if code, _ := reassess(patient.ID, `{"systolic_bp":0}`); code != 400 {
+		t.Errorf("Expected invalid vitals rejected, got %d", code)
+	}
+	if code, _ := reassess(9999, ""); code != 404 {
+		t.Errorf("Expected 404 for an unknown patient, got %d", code)
+	}
+}
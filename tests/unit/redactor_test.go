@@ -0,0 +1,139 @@
+package unit
+
+import (
+	"strings"
+	"testing"
+
+	"healthcare-backend/pkg/privacy"
+)
+
+// TestRedactor_Redact covers name, phone, identifier and custom-pattern detection
+func TestRedactor_Redact(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		knownNames []string
+		custom     []string
+		wantAbsent []string
+		wantCount  int
+	}{
+		{
+			name:       "known name",
+			text:       "John Smith responded well to ACE inhibitors.",
+			knownNames: []string{"John Smith"},
+			wantAbsent: []string{"John", "Smith"},
+			wantCount:  1,
+		},
+		{
+			name:       "turkish name with diacritics",
+			text:       "Şükrü Öztürk'e metformin başlandı.",
+			knownNames: []string{"Şükrü Öztürk"},
+			wantAbsent: []string{"Şükrü", "Öztürk"},
+			wantCount:  1,
+		},
+		{
+			name:       "turkish dotted and dotless i casing",
+			text:       "İBRAHİM YILDIZ and ibrahim yıldız are the same patient.",
+			knownNames: []string{"İbrahim Yıldız"},
+			wantAbsent: []string{"İBRAHİM", "ibrahim"},
+			wantCount:  2,
+		},
+		{
+			name:       "turkish honorific",
+			text:       "Ayşe Hanım reported dizziness; Mehmet Bey did not.",
+			wantAbsent: []string{"Ayşe", "Mehmet"},
+			wantCount:  2,
+		},
+		{
+			name:       "name inside a longer word is kept",
+			text:       "Alice is not Alicent.",
+			knownNames: []string{"Alicent"},
+			wantAbsent: []string{"Alicent"},
+			wantCount:  1,
+		},
+		{
+			name:       "turkish mobile number",
+			text:       "Call back on +90 532 123 45 67 after labs.",
+			wantAbsent: []string{"532 123"},
+			wantCount:  1,
+		},
+		{
+			name:       "domestic phone number",
+			text:       "Phone: 0532 123 45 67",
+			wantAbsent: []string{"0532"},
+			wantCount:  1,
+		},
+		{
+			name:       "tc kimlik no",
+			text:       "TCKN 12345678901 verified.",
+			wantAbsent: []string{"12345678901"},
+			wantCount:  1,
+		},
+		{
+			name:       "email",
+			text:       "Send results to patient@example.com",
+			wantAbsent: []string{"patient@example.com"},
+			wantCount:  1,
+		},
+		{
+			name:       "custom pattern",
+			text:       "Room B-204 patient stable.",
+			custom:     []string{`B-\d{3}`},
+			wantAbsent: []string{"B-204"},
+			wantCount:  1,
+		},
+		{
+			name:      "clinical numbers are not PHI",
+			text:      "SBP 185, glucose 240, BMI 32.5",
+			wantCount: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := privacy.NewRedactor(privacy.ModeRedact, tt.custom)
+			if err != nil {
+				t.Fatalf("Failed to build redactor: %v", err)
+			}
+
+			res := r.Redact(tt.text, tt.knownNames)
+
+			if res.Redactions != tt.wantCount {
+				t.Errorf("Expected %d redactions, got %d (%q)", tt.wantCount, res.Redactions, res.Text)
+			}
+			for _, s := range tt.wantAbsent {
+				if strings.Contains(res.Text, s) {
+					t.Errorf("Expected %q to be redacted from %q", s, res.Text)
+				}
+			}
+			if res.Blocked {
+				t.Error("Redact mode should never block")
+			}
+		})
+	}
+}
+
+// TestRedactor_BlockMode tests that PHI withholds the whole context in block mode
+func TestRedactor_BlockMode(t *testing.T) {
+	r, _ := privacy.NewRedactor(privacy.ModeBlock, nil)
+
+	res := r.Redact("Follow up with Jane Doe on 0532 123 45 67", []string{"Jane Doe"})
+	if !res.Blocked || res.Text != privacy.BlockedNotice {
+		t.Errorf("Expected blocked context, got %+v", res)
+	}
+
+	clean := r.Redact("Responded well to ACE inhibitors.", []string{"Jane Doe"})
+	if clean.Blocked || clean.Text != "Responded well to ACE inhibitors." {
+		t.Errorf("Expected clean context to pass through, got %+v", clean)
+	}
+}
+
+// TestRedactor_InvalidCustomPattern tests rejection of bad regexes
+func TestRedactor_InvalidCustomPattern(t *testing.T) {
+	if _, err := privacy.NewRedactor(privacy.ModeRedact, []string{"("}); err == nil {
+		t.Error("Expected error for invalid custom pattern")
+	}
+	if _, err := privacy.ParseMode("shred"); err == nil {
+		t.Error("Expected error for unknown mode")
+	}
+}
@@ -0,0 +1,136 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/middleware"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+	"healthcare-backend/pkg/workers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// requestIDRecorder is a fake ML service noting the X-Request-ID of each call by path
+type requestIDRecorder struct {
+	mu  sync.Mutex
+	ids map[string]string
+}
+
+func newRequestIDRecorder(t *testing.T) (*httptest.Server, *requestIDRecorder) {
+	rec := &requestIDRecorder{ids: map[string]string{}}
+	ml := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec.mu.Lock()
+		rec.ids[r.URL.Path] = r.Header.Get(auditctx.RequestIDHeader)
+		rec.mu.Unlock()
+		switch r.URL.Path {
+		case "/predict":
+			json.NewEncoder(w).Encode(models.PredictResponse{HeartRisk: 40, ModelPrecisions: map[string]float64{"Heart_Model": 0.9}})
+		case "/urgency/predict":
+			json.NewEncoder(w).Encode(models.UrgencyResponse{UrgencyLevel: 2})
+		default:
+			json.NewEncoder(w).Encode(models.DiagnosisResponse{Diagnosis: "ok", Status: "ready"})
+		}
+	}))
+	t.Cleanup(ml.Close)
+	return ml, rec
+}
+
+// waitFor returns the ID sent to path, waiting for asynchronous calls
+func (r *requestIDRecorder) waitFor(path string) (string, bool) {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		r.mu.Lock()
+		id, ok := r.ids[path]
+		r.mu.Unlock()
+		if ok {
+			return id, true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return "", false
+}
+
+func TestRequestIDMiddleware_AdoptsOrGenerates(t *testing.T) {
+	app := fiber.New()
+	app.Use(middleware.RequestID)
+	app.Get("/", func(c *fiber.Ctx) error { return c.SendString(auditctx.RequestID(c)) })
+
+	for sent, keep := range map[string]bool{
+		"trace-42":                   true,
+		"":                           false,
+		"has space":                  false,
+		strings.Repeat("x", 129):     false,
+		"line\r\nX-Injected: forged": false,
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if sent != "" {
+			req.Header.Set(auditctx.RequestIDHeader, sent)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		got := resp.Header.Get(auditctx.RequestIDHeader)
+		if keep && got != sent {
+			t.Errorf("Expected %q to be kept, got %q", sent, got)
+		}
+		if !keep && (got == sent || len(got) != 36) {
+			t.Errorf("Expected %q to be replaced by a UUID, got %q", sent, got)
+		}
+	}
+}
+
+func TestRequestID_PropagatesToMLAndAudit(t *testing.T) {
+	ml, rec := newRequestIDRecorder(t)
+	h, db, _ := newTestPatientHandler(t, ml.URL, handlers.NewWebSocketHandler())
+	app := fiber.New()
+	app.Use(middleware.RequestID)
+	app.Post("/api/assess", h.AssessPatient)
+
+	body, _ := json.Marshal(requiredIntake())
+	req := httptest.NewRequest("POST", "/api/assess", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(auditctx.RequestIDHeader, "assess-7")
+	resp, err := app.Test(req)
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("Assessment failed: %v (status %d)", err, resp.StatusCode)
+	}
+
+	// NATS isn't running, so the diagnosis goes straight to the ML service
+	for _, path := range []string{"/predict", "/urgency/predict", "/diagnose"} {
+		if id, ok := rec.waitFor(path); !ok || id != "assess-7" {
+			t.Errorf("Expected %s to receive the request ID, got %q (called: %v)", path, id, ok)
+		}
+	}
+
+	var entries []models.AuditLog
+	db.Order("id").Find(&entries)
+	if len(entries) < 2 {
+		t.Fatalf("Expected the patient and prediction to be audited, got %d entries", len(entries))
+	}
+	for _, e := range entries {
+		if e.RequestID != "assess-7" {
+			t.Errorf("Expected %s (actor %s) to record the request ID, got %q", e.EventType, e.ActorID, e.RequestID)
+		}
+	}
+	if ok, _, err := services.NewAuditService(db).VerifyChain(); err != nil || !ok {
+		t.Errorf("Expected the chain to verify with request IDs, got %v (%v)", ok, err)
+	}
+}
+
+func TestLLMWorker_ForwardsTaskRequestID(t *testing.T) {
+	ml, rec := newRequestIDRecorder(t)
+	workers.NewLLMWorker(ml.URL).Process(models.DiagnosisRequest{Patient: models.PatientData{ID: 9}, RequestID: "queued-3"})
+	if id, _ := rec.waitFor("/diagnose"); id != "queued-3" {
+		t.Errorf("Expected the worker to forward the task's request ID, got %q", id)
+	}
+}
@@ -0,0 +1,79 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// hypertensiveCrisis has SBP 185 and would be high risk under either scorer
+var hypertensiveCrisis = models.PatientData{Age: 68, Gender: "Male", SystolicBP: 185, DiastolicBP: 110, Glucose: 110, BMI: 29, Cholesterol: 250, HeartRate: 95}
+
+func assessCrisis(t *testing.T, mlURL string) models.FullAssessmentResponse {
+	h, _, _ := newTestPatientHandler(t, mlURL, handlers.NewWebSocketHandler())
+	app := fiber.New()
+	app.Post("/api/assess", h.AssessPatient)
+
+	body, _ := json.Marshal(hypertensiveCrisis)
+	req := httptest.NewRequest("POST", "/api/assess", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, 5000)
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("Assess failed: %v %v", err, resp)
+	}
+	var result models.FullAssessmentResponse
+	json.NewDecoder(resp.Body).Decode(&result)
+	return result
+}
+
+func TestRiskScale_EmergencyWithLiveML(t *testing.T) {
+	ml := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/predict":
+			json.NewEncoder(w).Encode(models.PredictResponse{HeartRisk: 91.5, ClinicalConfidence: 96, ModelPrecisions: map[string]float64{"Heart_Model": 0.9}})
+		case "/urgency/predict":
+			json.NewEncoder(w).Encode(models.UrgencyResponse{UrgencyLevel: 3})
+		default:
+			json.NewEncoder(w).Encode(models.DiagnosisResponse{Diagnosis: "ok", Status: "ready"})
+		}
+	}))
+	t.Cleanup(ml.Close)
+
+	result := assessCrisis(t, ml.URL)
+	if !result.Emergency || result.Risks.Degraded {
+		t.Errorf("Expected a live-ML emergency, got emergency=%v degraded=%v", result.Emergency, result.Risks.Degraded)
+	}
+}
+
+func TestRiskScale_EmergencyWithFallback(t *testing.T) {
+	result := assessCrisis(t, "http://127.0.0.1:1")
+	if !result.Emergency {
+		t.Error("Expected the fallback assessment flagged as an emergency")
+	}
+	// Fallback scores are on the same percent scale as the ML service
+	if result.Risks.HeartRisk != 90 || result.Risks.ClinicalConfidence != 50 {
+		t.Errorf("Expected heart risk 90 and confidence 50 out of %v, got %+v", models.ScoreScale, result.Risks)
+	}
+	if g := result.Risks.GeneralHealthScore; g <= 0 || g > models.ScoreScale {
+		t.Errorf("Expected general health score within 0-%v, got %v", models.ScoreScale, g)
+	}
+}
+
+// TestRiskScale_FallbackHighHeartRiskFiresDefaultRule pairs the fallback's
+// high heart risk with the seeded rule, below the blood pressure trigger
+func TestRiskScale_FallbackHighHeartRiskFiresDefaultRule(t *testing.T) {
+	p := models.PatientData{Age: 55, SystolicBP: 165, Cholesterol: 250, Glucose: 90, BMI: 22}
+	risks := services.NewPredictionService("").RuleBasedPredictRisks(p)
+	got := services.EvaluateEmergencyRules(services.DefaultEmergencyRules, p, risks, nil)
+	if len(got) != 1 || got[0] != services.DefaultEmergencyRules[0].Description {
+		t.Errorf("Expected only the heart risk rule to fire for %v, got %v", risks.HeartRisk, got)
+	}
+}
@@ -0,0 +1,76 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"healthcare-backend/pkg/schema"
+)
+
+var updateSnapshots = flag.Bool("update", false, "rewrite testdata snapshots")
+
+// TestAPISchema_Snapshot fails whenever a struct change alters the API contract.
+// If the change is intended, rerun with -update and commit the new snapshot.
+func TestAPISchema_Snapshot(t *testing.T) {
+	got, err := json.MarshalIndent(schema.API(), "", "  ")
+	if err != nil {
+		t.Fatalf("Failed to marshal schema: %v", err)
+	}
+	got = append(got, '\n')
+
+	path := filepath.Join("testdata", "api_schema.json")
+	if *updateSnapshots {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("Failed to update snapshot: %v", err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read snapshot (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("API schema changed; review the diff and rerun with -update if intended.\ngot:\n%s", got)
+	}
+}
+
+// TestAPISchema_PatientConstraints spot-checks the validation translation
+func TestAPISchema_PatientConstraints(t *testing.T) {
+	doc := schema.API()
+	patient := doc.Components.Schemas["PatientData"]
+	if patient == nil {
+		t.Fatal("Expected PatientData schema")
+	}
+
+	gender := patient.Properties["gender"]
+	if len(gender.Enum) != 3 || gender.Enum[0] != "Male" {
+		t.Errorf("Expected gender enum from oneof, got %v", gender.Enum)
+	}
+	chol := patient.Properties["cholesterol"]
+	if chol.Minimum == nil || *chol.Minimum != 50 || chol.Maximum == nil || *chol.Maximum != 500 {
+		t.Errorf("Expected cholesterol bounds 50-500, got %+v", chol)
+	}
+
+	required := map[string]bool{}
+	for _, r := range patient.Required {
+		required[r] = true
+	}
+	if !required["age"] || !required["bmi"] || required["cholesterol"] {
+		t.Errorf("Unexpected required list %v", patient.Required)
+	}
+
+	full := doc.Components.Schemas["FullAssessmentResponse"]
+	if ref := full.Properties["risks"].Ref; ref != "#/components/schemas/PredictResponse" {
+		t.Errorf("Expected nested struct as $ref, got %q", ref)
+	}
+	if doc.Components.Schemas["PredictResponse"] == nil {
+		t.Error("Expected referenced PredictResponse to be registered")
+	}
+	if _, ok := doc.Components.Schemas["FeedbackRequest"].Properties["override_details"]; !ok {
+		t.Error("Expected feedback override_details property")
+	}
+}
@@ -0,0 +1,200 @@
+package unit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/database"
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/middleware"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openSearchDB migrates every model and builds the search index and its
+// hooks the way InitDB does
+func openSearchDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1) // One in-memory database for every query
+	if err := db.AutoMigrate(database.Models()...); err != nil {
+		t.Fatalf("AutoMigrate failed: %v", err)
+	}
+	if err := database.EnsureSearchIndex(db); err != nil {
+		t.Fatalf("EnsureSearchIndex failed: %v", err)
+	}
+	if err := database.RegisterSearchHooks(db); err != nil {
+		t.Fatalf("RegisterSearchHooks failed: %v", err)
+	}
+	return db
+}
+
+func search(t *testing.T, db *gorm.DB, q services.SearchQuery) []services.SearchHit {
+	t.Helper()
+	hits, err := services.NewSearchService(db).Search(q)
+	if err != nil {
+		t.Fatalf("Search %q failed: %v", q.Text, err)
+	}
+	return hits
+}
+
+func TestSearch_StemsAndRanks(t *testing.T) {
+	db := openSearchDB(t)
+	notes := []models.Feedback{
+		{PatientID: 1, AssessmentID: "11", DoctorNotes: "Mild aching in the left shoulder after exercise"},
+		{PatientID: 2, AssessmentID: "12", DoctorNotes: "Chest aches at rest, aches again on exertion, constant ache overnight"},
+		{PatientID: 3, AssessmentID: "13", DoctorNotes: "No complaints, routine follow-up"},
+	}
+	for i := range notes {
+		db.Create(&notes[i])
+	}
+
+	hits := search(t, db, services.SearchQuery{Text: "ache"})
+	if len(hits) != 2 {
+		t.Fatalf("Expected aching and aches to match ache, got %+v", hits)
+	}
+	if hits[0].PatientID != 2 || hits[1].PatientID != 1 || hits[0].Rank <= hits[1].Rank {
+		t.Errorf("Expected the note repeating the term first, got %+v", hits)
+	}
+	if hits[0].Source != models.SearchFeedback || hits[0].SourceID != notes[1].ID || hits[0].AssessmentID != 12 {
+		t.Errorf("Expected the hit to point at its note and assessment, got %+v", hits[0])
+	}
+	if hits[1].Snippet == "" || hits[1].Snippet == notes[0].DoctorNotes {
+		t.Errorf("Expected the term marked in the snippet, got %q", hits[1].Snippet)
+	}
+
+	// Every word is required; punctuation is not query syntax
+	if hits := search(t, db, services.SearchQuery{Text: "shoulder (ache) -"}); len(hits) != 1 || hits[0].PatientID != 1 {
+		t.Errorf("Expected only the shoulder note, got %+v", hits)
+	}
+	if _, err := services.NewSearchService(db).Search(services.SearchQuery{Text: " ?! "}); err != services.ErrEmptySearch {
+		t.Errorf("Expected ErrEmptySearch, got %v", err)
+	}
+}
+
+func TestSearch_HooksFollowSavesAndDiagnoses(t *testing.T) {
+	db := openSearchDB(t)
+	note := models.Feedback{PatientID: 1, DoctorNotes: "Wheezing on expiration"}
+	db.Create(&note)
+	db.Model(&note).Update("doctor_notes", "Palpitations when lying down")
+	if hits := search(t, db, services.SearchQuery{Text: "wheezing"}); len(hits) != 0 {
+		t.Errorf("Expected edited notes reindexed, got %+v", hits)
+	}
+	if hits := search(t, db, services.SearchQuery{Text: "palpitation"}); len(hits) != 1 {
+		t.Errorf("Expected the new notes found, got %+v", hits)
+	}
+
+	db.Create(&models.DiagnosisContext{PatientID: 1, AssessmentID: 5, Generation: 3})
+	store := services.NewDiagnosisStore(db)
+	if err := store.Save(1, 3, "Likely paroxysmal atrial fibrillation", "error"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if hits := search(t, db, services.SearchQuery{Text: "fibrillation"}); len(hits) != 0 {
+		t.Errorf("Expected failed diagnoses not stored, got %+v", hits)
+	}
+	if err := store.Save(1, 3, "Likely paroxysmal atrial fibrillation", "ready"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	hits := search(t, db, services.SearchQuery{Text: "fibrillation", Sources: []string{models.SearchDiagnosis}})
+	if len(hits) != 1 || hits[0].Source != models.SearchDiagnosis || hits[0].AssessmentID != 5 {
+		t.Errorf("Expected the stored diagnosis found, got %+v", hits)
+	}
+	if hits := search(t, db, services.SearchQuery{Text: "fibrillation", Sources: []string{models.SearchFeedback}}); len(hits) != 0 {
+		t.Errorf("Expected the source filter to exclude diagnoses, got %+v", hits)
+	}
+
+	db.Delete(&note)
+	if hits := search(t, db, services.SearchQuery{Text: "palpitation"}); len(hits) != 0 {
+		t.Errorf("Expected deleted notes unindexed, got %+v", hits)
+	}
+}
+
+func TestSearch_BackfillsRowsSavedWithoutHooks(t *testing.T) {
+	db := openSearchDB(t)
+	if err := db.Exec("INSERT INTO feedbacks (patient_id, doctor_notes) VALUES (1, 'Swelling of both ankles')").Error; err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if n, err := database.BackfillSearchDocuments(db); err != nil || n != 1 {
+		t.Fatalf("Expected one document backfilled, got %d (%v)", n, err)
+	}
+	if n, _ := database.BackfillSearchDocuments(db); n != 0 {
+		t.Errorf("Expected a second backfill to find nothing, got %d", n)
+	}
+	if hits := search(t, db, services.SearchQuery{Text: "ankle swelling"}); len(hits) != 1 {
+		t.Errorf("Expected the backfilled note found, got %+v", hits)
+	}
+}
+
+func TestSearchHandler_ScopedValidatedAndAudited(t *testing.T) {
+	db := openSearchDB(t)
+	providers := services.NewProviderService(db)
+	userID := uint(1)
+	house := models.Provider{Name: "Dr. House", UserID: &userID}
+	providers.Create(&house)
+	mine := models.PatientData{Name: "Ayse", Age: 58, AssignedProviderID: &house.ID}
+	other := models.PatientData{Name: "Elif", Age: 35}
+	db.Create(&mine)
+	db.Create(&other)
+	db.Create(&models.Feedback{PatientID: mine.ID, DoctorNotes: "Dizziness on standing"})
+	db.Create(&models.Feedback{PatientID: other.ID, DoctorNotes: "Dizziness after new medication"})
+
+	audit := services.NewAuditService(db)
+	h := handlers.NewSearchHandler(services.NewSearchService(db), audit, providers)
+	actors := map[string]auditctx.Identity{"house": doctorHouse, "admin": accessAdmin, "kiosk": {ID: "kiosk:1", Role: auditctx.RoleKiosk}}
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		auditctx.Set(c, actors[c.Get("X-Test-Actor")])
+		return c.Next()
+	})
+	app.Get("/api/search", middleware.RequireRole(auditctx.RoleAdmin, auditctx.RoleDoctor), h.GetSearch)
+	get := func(actor, query string) (int, string) {
+		req := httptest.NewRequest("GET", "/api/search?"+query, nil)
+		req.Header.Set("X-Test-Actor", actor)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	// Doctors only find their own patients' notes
+	for actor, want := range map[string]int{"house": 1, "admin": 2} {
+		code, raw := get(actor, "q=dizziness")
+		var body struct{ Hits []services.SearchHit }
+		json.Unmarshal([]byte(raw), &body)
+		if code != 200 || len(body.Hits) != want {
+			t.Errorf("Expected %d hits for %s, got %d: %s", want, actor, code, raw)
+		}
+		if actor == "house" && len(body.Hits) == 1 && body.Hits[0].PatientID != mine.ID {
+			t.Errorf("Expected only the assigned patient's note, got %+v", body.Hits)
+		}
+	}
+
+	for _, query := range []string{"q=", "q=%3F%21", "q=dizziness&limit=0", "q=dizziness&limit=500", "q=dizziness&source=patients"} {
+		if code, body := get("admin", query); code != 400 {
+			t.Errorf("Expected 400 for %s, got %d: %s", query, code, body)
+		}
+	}
+	if code, _ := get("kiosk", "q=dizziness"); code != 403 {
+		t.Errorf("Expected 403 without a doctor or admin role, got %d", code)
+	}
+
+	audit.Flush()
+	var logged int64
+	db.Model(&models.AuditLog{}).Where("event_type = ?", "NOTES_SEARCHED").Count(&logged)
+	if logged != 2 {
+		t.Errorf("Expected each successful search audited, got %d entries", logged)
+	}
+}
@@ -0,0 +1,102 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/database"
+	"healthcare-backend/pkg/jobs"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+)
+
+func newShadowService(t *testing.T, url string) *services.ShadowService {
+	db, err := database.Open(filepath.Join(t.TempDir(), "shadow.db"))
+	if err != nil {
+		t.Fatalf("Failed to open db: %v", err)
+	}
+	if err := db.AutoMigrate(&models.ShadowComparison{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	runner := jobs.NewRunner("test-shadow", 1, 10, 0)
+	t.Cleanup(runner.Stop)
+	return services.NewShadowService(db, url, 5.0, runner)
+}
+
+// TestShadow_HangingShadowDoesNotSlowPrimary tests that the live response never waits on the shadow
+func TestShadow_HangingShadowDoesNotSlowPrimary(t *testing.T) {
+	var primaryHits atomic.Int64
+	primary := newFakeML(t, 40, &primaryHits)
+
+	release := make(chan struct{})
+	shadowSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(shadowSrv.Close)
+	t.Cleanup(func() { close(release) })
+
+	service := services.NewPredictionService(primary.URL)
+	service.Shadow = newShadowService(t, shadowSrv.URL)
+
+	start := time.Now()
+	risks, err := service.PredictRisks(models.PatientData{ID: 1, Age: 50})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Primary prediction blocked on shadow for %v", elapsed)
+	}
+	if risks.HeartRisk != 40 {
+		t.Errorf("Expected primary heart risk 40, got %v", risks.HeartRisk)
+	}
+}
+
+// TestShadow_StoresComparison tests that disagreements are recorded and reported
+func TestShadow_StoresComparison(t *testing.T) {
+	var primaryHits, shadowHits atomic.Int64
+	primary := newFakeML(t, 40, &primaryHits)
+	shadowSrv := newFakeML(t, 52, &shadowHits)
+
+	service := services.NewPredictionService(primary.URL)
+	shadow := newShadowService(t, shadowSrv.URL)
+	service.Shadow = shadow
+
+	if _, err := service.PredictRisks(models.PatientData{ID: 2, Age: 60}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var report *models.ShadowReport
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		var err error
+		report, err = shadow.Report(time.Now().Add(-time.Hour), 10)
+		if err != nil {
+			t.Fatalf("Report failed: %v", err)
+		}
+		if report.Total > 0 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if report.Total != 1 {
+		t.Fatalf("Expected 1 comparison, got %d", report.Total)
+	}
+	if report.AgreementRate != 0 {
+		t.Errorf("Expected disagreement beyond tolerance, got agreement rate %v", report.AgreementRate)
+	}
+	if report.MeanAbsDeltaHeart != 12 {
+		t.Errorf("Expected heart delta 12, got %v", report.MeanAbsDeltaHeart)
+	}
+	if len(report.LargestDisagreements) != 1 || report.LargestDisagreements[0].PatientID != 2 {
+		t.Errorf("Expected patient 2 in largest disagreements, got %+v", report.LargestDisagreements)
+	}
+	if _, err := json.Marshal(report); err != nil {
+		t.Errorf("Report is not serializable: %v", err)
+	}
+}
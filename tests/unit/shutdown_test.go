@@ -0,0 +1,132 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/lifecycle"
+
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+// livePhase returns /health/live's status code and reported phase
+func livePhase(t *testing.T, app *fiber.App) (int, string) {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest("GET", "/health/live", nil))
+	if err != nil {
+		t.Fatalf("Liveness request failed: %v", err)
+	}
+	var body struct {
+		Phase string `json:"phase"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	return resp.StatusCode, body.Phase
+}
+
+func TestShutdown_RunsStepsInOrderAndReportsPhase(t *testing.T) {
+	shutdown := lifecycle.NewShutdown(50 * time.Millisecond)
+	app := fiber.New()
+	app.Get("/health/live", shutdown.Live)
+
+	if status, phase := livePhase(t, app); status != 200 || phase != lifecycle.PhaseRunning {
+		t.Fatalf("Expected a live server, got %d %q", status, phase)
+	}
+
+	var ran []string
+	shutdown.Add("announce", func(context.Context) error {
+		ran = append(ran, "announce")
+		if status, phase := livePhase(t, app); status != 503 || phase != "announce" {
+			t.Errorf("Expected liveness to fail during announce, got %d %q", status, phase)
+		}
+		return nil
+	})
+	shutdown.Add("workers", func(ctx context.Context) error {
+		ran = append(ran, "workers")
+		<-ctx.Done() // Outlasts the budget
+		return ctx.Err()
+	})
+	shutdown.Add("audit", func(context.Context) error {
+		ran = append(ran, "audit")
+		return errors.New("flush failed")
+	})
+	shutdown.Add("redis", func(context.Context) error {
+		ran = append(ran, "redis")
+		return nil
+	})
+
+	shutdown.Run()
+	shutdown.Run() // Runs once
+	<-shutdown.Done()
+
+	if want := []string{"announce", "workers", "audit", "redis"}; !slices.Equal(ran, want) {
+		t.Errorf("Expected every step once, in order, past the budget and failures: got %v", ran)
+	}
+	if status, phase := livePhase(t, app); status != 503 || phase != lifecycle.PhaseStopped {
+		t.Errorf("Expected a stopped server, got %d %q", status, phase)
+	}
+}
+
+func TestWSCloseAll_SendsGoingAwayAndWaits(t *testing.T) {
+	ws, _, dial := newSweepServer(t)
+	clients := []*websocket.Conn{dial(), dial()}
+	waitFor(t, "two connections", func() bool { return ws.Status().Connections == 2 })
+
+	closed := make(chan error, len(clients))
+	for _, conn := range clients {
+		go func() {
+			conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			_, _, err := conn.ReadMessage() // Answers the close frame
+			closed <- err
+		}()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := ws.CloseAll(ctx); err != nil {
+		t.Fatalf("CloseAll failed: %v", err)
+	}
+	if n := ws.Status().Connections; n != 0 {
+		t.Errorf("Expected every connection gone, %d left", n)
+	}
+	for range clients {
+		var closeErr *websocket.CloseError
+		if err := <-closed; !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseGoingAway || closeErr.Text != "server shutting down" {
+			t.Errorf("Expected a going-away close frame, got %v", err)
+		}
+	}
+}
+
+func TestJobs_StopWaitsForRunUnderWay(t *testing.T) {
+	jobs := lifecycle.NewJobs()
+	var runs atomic.Int32
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	jobs.Every(time.Millisecond, func() {
+		if runs.Add(1) == 1 {
+			started <- struct{}{}
+			<-release
+		}
+	})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := jobs.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected Stop to give up on a stuck run, got %v", err)
+	}
+	close(release)
+	if err := jobs.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	after := runs.Load()
+	time.Sleep(10 * time.Millisecond)
+	if runs.Load() != after || after != 1 {
+		t.Errorf("Expected no run once stopped, got %d runs", runs.Load())
+	}
+}
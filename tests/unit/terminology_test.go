@@ -0,0 +1,112 @@
+package unit
+
+import (
+	"strings"
+	"testing"
+
+	"healthcare-backend/pkg/adapters"
+	"healthcare-backend/pkg/terminology"
+)
+
+func newTestMapper(t *testing.T) *terminology.Mapper {
+	m, err := terminology.NewMapper()
+	if err != nil {
+		t.Fatalf("Failed to load bundled table: %v", err)
+	}
+	return m
+}
+
+// TestTerminology_Map tests mapped, synonym, diacritic-insensitive and unmapped terms
+func TestTerminology_Map(t *testing.T) {
+	m := newTestMapper(t)
+
+	tests := []struct {
+		term string
+		code string
+	}{
+		{"Chest Pain", "29857009"},
+		{"  chest-pain ", "29857009"},
+		{"shortness of breath", "267036007"},
+		{"Baş ağrısı", "25064002"},
+		{"BAS AGRISI", "25064002"},
+		{"nefes darligi", "267036007"},
+		{"diarrhoea", "62315008"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.term, func(t *testing.T) {
+			cs, ok := m.Lookup(tt.term)
+			if !ok {
+				t.Fatalf("Expected %q to map", tt.term)
+			}
+			if cs.Code != tt.code {
+				t.Errorf("Expected code %s, got %s (%s)", tt.code, cs.Code, cs.Display)
+			}
+			if cs.Text != strings.TrimSpace(tt.term) {
+				t.Errorf("Expected original text to be kept, got %q", cs.Text)
+			}
+		})
+	}
+
+	coded, unmapped := m.Map([]string{"fever", "purple toes", "", "cough"})
+	if len(coded) != 2 {
+		t.Errorf("Expected 2 coded symptoms, got %+v", coded)
+	}
+	if len(unmapped) != 1 || unmapped[0] != "purple toes" {
+		t.Errorf("Expected purple toes to be unmapped, got %v", unmapped)
+	}
+}
+
+// TestTerminology_Load tests extending and rejecting uploaded tables
+func TestTerminology_Load(t *testing.T) {
+	m := newTestMapper(t)
+
+	if _, ok := m.Lookup("purple toes"); ok {
+		t.Fatal("Did not expect purple toes in bundled table")
+	}
+
+	n, err := m.Load(strings.NewReader("code,display,synonyms\n95343002,Blue toe syndrome,purple toes|mor parmak\n"))
+	if err != nil || n != 1 {
+		t.Fatalf("Expected 1 concept loaded, got %d (%v)", n, err)
+	}
+	if cs, ok := m.Lookup("Mor parmak"); !ok || cs.Code != "95343002" {
+		t.Errorf("Expected uploaded synonym to map, got %+v", cs)
+	}
+
+	if _, err := m.Load(strings.NewReader("abc,Not a code\n")); err == nil {
+		t.Error("Expected error for non-numeric code")
+	}
+	if _, err := m.Load(strings.NewReader("12345\n")); err == nil {
+		t.Error("Expected error for missing display")
+	}
+}
+
+// TestTerminology_FHIRObservations tests SNOMED codings on FHIR Observations
+func TestTerminology_FHIRObservations(t *testing.T) {
+	m := newTestMapper(t)
+	coded, unmapped := m.Map([]string{"göğüs ağrısı", "purple toes"})
+
+	obs := adapters.NewFHIRAdapter().ToFHIRSymptomObservations(7, coded, unmapped)
+	if len(obs) != 2 {
+		t.Fatalf("Expected 2 observations, got %d", len(obs))
+	}
+
+	code := obs[0]["code"].(map[string]interface{})
+	coding := code["coding"].([]map[string]string)
+	if coding[0]["system"] != terminology.SNOMEDSystem || coding[0]["code"] != "29857009" {
+		t.Errorf("Unexpected coding: %+v", coding)
+	}
+	if code["text"] != "göğüs ağrısı" {
+		t.Errorf("Expected original text on codeable concept, got %v", code["text"])
+	}
+	if obs[0]["subject"].(map[string]string)["reference"] != "Patient/pat-7" {
+		t.Errorf("Unexpected subject: %v", obs[0]["subject"])
+	}
+
+	unmappedCode := obs[1]["code"].(map[string]interface{})
+	if _, hasCoding := unmappedCode["coding"]; hasCoding {
+		t.Error("Unmapped symptom should not carry a coding")
+	}
+	if unmappedCode["text"] != "purple toes" {
+		t.Errorf("Expected free text for unmapped symptom, got %v", unmappedCode["text"])
+	}
+}
@@ -0,0 +1,204 @@
+package unit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/auditctx"
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var timelineStart = time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+
+// at is the test's clock: minutes after timelineStart
+func at(minutes int) time.Time {
+	return timelineStart.Add(time.Duration(minutes) * time.Minute)
+}
+
+func openTimelineDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1) // One in-memory database for every query
+	db.AutoMigrate(&models.Assessment{}, &models.Feedback{}, &models.OverrideLog{}, &models.AuditLog{})
+	return db
+}
+
+// seedTimeline writes patient 1's history across every source, with
+// events from another patient and ones the timeline leaves out mixed in
+func seedTimeline(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	rows := []any{
+		&models.AuditLog{Timestamp: at(0), EventType: "PATIENT_CREATED", PatientIDHash: services.PatientHash(1), ActorID: "user:12"},
+		&models.Assessment{CreatedAt: at(1), PatientID: 1, HeartRisk: 72, DiabetesRisk: 18, StrokeRisk: 9, KidneyRisk: 5},
+		&models.AuditLog{Timestamp: at(2), EventType: "PATIENT_VIEWED", PatientIDHash: services.PatientHash(1), ActorID: "user:12"},
+		&models.Feedback{CreatedAt: at(3), PatientID: 1, DoctorApproved: true, DoctorNotes: "Agree, start statins"},
+		&models.Feedback{CreatedAt: at(4), PatientID: 1, DoctorApproved: false},
+		&models.OverrideLog{CreatedAt: at(4), FeedbackID: 2, PatientID: 1, Reason: "Clinical Intuition", ModelsOverridden: []string{"Heart_Model"}},
+		&models.AuditLog{Timestamp: at(5), EventType: "PATIENT_UPDATED", PatientIDHash: services.PatientHash(1), ActorID: "user:12"},
+		&models.Assessment{CreatedAt: at(5), PatientID: 1, HeartRisk: 88, Emergency: true},
+		&models.Assessment{CreatedAt: at(5), PatientID: 1, HeartRisk: 90},
+		&models.Assessment{CreatedAt: at(6), PatientID: 2, HeartRisk: 40},
+		&models.AuditLog{Timestamp: at(6), EventType: "PATIENT_UPDATED", PatientIDHash: services.PatientHash(2), ActorID: "user:12"},
+	}
+	for _, row := range rows {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatalf("Seeding %T failed: %v", row, err)
+		}
+	}
+}
+
+// timelineKeys reduces events to type/ref_id pairs for comparison
+func timelineKeys(events []services.TimelineEvent) []string {
+	keys := make([]string, len(events))
+	for i, e := range events {
+		keys[i] = e.Type + "/" + strconv.FormatUint(uint64(e.RefID), 10)
+	}
+	return keys
+}
+
+func TestTimeline_MergesSourcesNewestFirst(t *testing.T) {
+	db := openTimelineDB(t)
+	seedTimeline(t, db)
+
+	page, err := services.NewTimelineService(db, nil).Timeline(services.TimelineQuery{PatientID: 1, Limit: 50})
+	if err != nil {
+		t.Fatalf("Timeline failed: %v", err)
+	}
+	// Ties at minute 5 fall back to type, then ID, both descending
+	want := []string{"audit/3", "assessment/3", "assessment/2", "override/1", "feedback/1", "assessment/1", "audit/1"}
+	if got := timelineKeys(page.Events); len(got) != len(want) || page.NextCursor != "" {
+		t.Fatalf("Expected %v on one page, got %v (next %q)", want, got, page.NextCursor)
+	} else {
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("Expected %v, got %v", want, got)
+			}
+		}
+	}
+
+	summaries := map[string]string{}
+	for _, e := range page.Events {
+		summaries[e.Type+"/"+strconv.FormatUint(uint64(e.RefID), 10)] = e.Summary
+	}
+	for key, summary := range map[string]string{
+		"assessment/1": "Heart 72%, diabetes 18%, stroke 9%, kidney 5%",
+		"assessment/2": "Emergency: Heart 88%, diabetes 0%, stroke 0%, kidney 0%",
+		"feedback/1":   "Doctor approved the assessment: Agree, start statins",
+		"override/1":   "Doctor overrode Heart_Model: Clinical Intuition",
+		"audit/1":      "Patient record created by user:12",
+	} {
+		if summaries[key] != summary {
+			t.Errorf("Expected %s summarized as %q, got %q", key, summary, summaries[key])
+		}
+	}
+}
+
+func TestTimeline_CursorPagesThroughTies(t *testing.T) {
+	db := openTimelineDB(t)
+	seedTimeline(t, db)
+	timeline := services.NewTimelineService(db, nil)
+	all, _ := timeline.Timeline(services.TimelineQuery{PatientID: 1, Limit: 50})
+
+	// Pages of two split the three events at minute 5 across a boundary
+	var paged []services.TimelineEvent
+	q := services.TimelineQuery{PatientID: 1, Limit: 2}
+	for pages := 0; ; pages++ {
+		if pages > len(all.Events) {
+			t.Fatal("Expected paging to end")
+		}
+		page, err := timeline.Timeline(q)
+		if err != nil {
+			t.Fatalf("Timeline failed: %v", err)
+		}
+		paged = append(paged, page.Events...)
+		if page.NextCursor == "" {
+			break
+		}
+		if q.After, err = services.ParseTimelineCursor(page.NextCursor); err != nil {
+			t.Fatalf("Cursor didn't parse: %v", err)
+		}
+	}
+	got, want := timelineKeys(paged), timelineKeys(all.Events)
+	if len(got) != len(want) {
+		t.Fatalf("Expected pages to add up to %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Expected pages to add up to %v, got %v", want, got)
+		}
+	}
+}
+
+func TestTimeline_Window(t *testing.T) {
+	db := openTimelineDB(t)
+	seedTimeline(t, db)
+
+	from, to := at(3), at(5)
+	page, err := services.NewTimelineService(db, nil).Timeline(services.TimelineQuery{PatientID: 1, From: &from, To: &to, Limit: 50})
+	if err != nil {
+		t.Fatalf("Timeline failed: %v", err)
+	}
+	if got := timelineKeys(page.Events); len(got) != 2 || got[0] != "override/1" || got[1] != "feedback/1" {
+		t.Errorf("Expected from inclusive and to exclusive, got %v", got)
+	}
+}
+
+func TestTimelineHandler(t *testing.T) {
+	db := openTimelineDB(t)
+	audit := services.NewAuditService(db)
+	app := fiber.New()
+	app.Get("/api/patients/:id/timeline", handlers.NewTimelineHandler(services.NewTimelineService(db, audit)).GetTimeline)
+
+	db.Create(&models.Assessment{PatientID: 7, HeartRisk: 30})
+	if _, err := audit.LogEvent("PATIENT_ASSIGNED", 7, fiber.Map{"to_provider_id": 2}, auditctx.System); err != nil {
+		t.Fatalf("LogEvent failed: %v", err)
+	}
+
+	// Buffered audit entries are flushed before reading
+	body := getJSON(t, app, "/api/patients/7/timeline?limit=1")
+	events, _ := body["events"].([]any)
+	if len(events) != 1 || body["next_cursor"] == nil {
+		t.Fatalf("Expected one event and a cursor, got %v", body)
+	}
+	first := events[0].(map[string]any)
+	for _, field := range []string{"type", "timestamp", "summary", "ref_id"} {
+		if _, ok := first[field]; !ok {
+			t.Errorf("Expected %s on every event, got %v", field, first)
+		}
+	}
+
+	body = getJSON(t, app, "/api/patients/7/timeline?limit=1&cursor="+url.QueryEscape(body["next_cursor"].(string)))
+	events, _ = body["events"].([]any)
+	if len(events) != 1 || body["next_cursor"] != nil || events[0].(map[string]any)["type"] == first["type"] {
+		t.Errorf("Expected the other event on the last page, got %v after %v", body, first)
+	}
+
+	for _, query := range []string{"cursor=nope", "from=yesterday", "limit=0", "limit=500"} {
+		resp, _ := app.Test(httptest.NewRequest("GET", "/api/patients/7/timeline?"+query, nil))
+		if resp.StatusCode != 400 {
+			b, _ := io.ReadAll(resp.Body)
+			t.Errorf("Expected 400 for %s, got %d: %s", query, resp.StatusCode, b)
+		}
+	}
+	resp, _ := app.Test(httptest.NewRequest("GET", "/api/patients/8/timeline", nil))
+	var empty services.TimelinePage
+	json.NewDecoder(resp.Body).Decode(&empty)
+	if resp.StatusCode != 200 || empty.Events == nil || len(empty.Events) != 0 {
+		t.Errorf("Expected an empty timeline for a patient without events, got %d %+v", resp.StatusCode, empty)
+	}
+}
@@ -0,0 +1,109 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/middleware"
+	"healthcare-backend/pkg/tracing"
+	"healthcare-backend/pkg/workers"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs a provider keeping every span in memory for the test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	rec := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return rec
+}
+
+// endedSpan waits for a span named name to end
+func endedSpan(t *testing.T, rec *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	var found sdktrace.ReadOnlySpan
+	waitFor(t, "span "+name, func() bool {
+		for _, s := range rec.Ended() {
+			if s.Name() == name {
+				found = s
+				return true
+			}
+		}
+		return false
+	})
+	return found
+}
+
+func TestTracing_AssessmentIsOneTrace(t *testing.T) {
+	rec := recordSpans(t)
+	ml, _ := newRequestIDRecorder(t)
+	h, _, _ := newTestPatientHandler(t, ml.URL, handlers.NewWebSocketHandler())
+	app := fiber.New()
+	app.Use(middleware.RequestID)
+	app.Use(middleware.Tracing)
+	app.Post("/api/assess", h.AssessPatient)
+
+	body, _ := json.Marshal(requiredIntake())
+	req := httptest.NewRequest("POST", "/api/assess", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("Assessment failed: %v (status %d)", err, resp.StatusCode)
+	}
+
+	// NATS isn't running, so the diagnosis is made directly, after the response
+	root := endedSpan(t, rec, "POST /api/assess")
+	endedSpan(t, rec, "llm.diagnose")
+	traceID := root.SpanContext().TraceID()
+	seen := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range rec.Ended() {
+		if s.SpanContext().TraceID() == traceID {
+			seen[s.Name()] = s
+		}
+	}
+	for _, name := range []string{"db.patient.create", "rag.search", "ml.predict", "llm.enqueue", "llm.diagnose"} {
+		if seen[name] == nil {
+			t.Errorf("Expected a %s span in the assessment's trace, got %v", name, slices.Collect(maps.Keys(seen)))
+		}
+	}
+	if s := seen["ml.predict"]; s != nil {
+		for _, kv := range s.Attributes() {
+			if kv.Key == "cache.hit" && kv.Value.AsBool() {
+				t.Error("Expected a first prediction to miss the cache")
+			}
+		}
+	}
+}
+
+func TestTracing_WorkerContinuesTaskTrace(t *testing.T) {
+	rec := recordSpans(t)
+	ml, _ := newRequestIDRecorder(t)
+	worker := workers.NewLLMWorker(ml.URL)
+	worker.StartPool()
+	defer worker.Stop(context.Background())
+
+	ctx, parent := tracing.Start(context.Background(), "llm.enqueue")
+	task := diagnosisTask(11)
+	task.Header = nats.Header{}
+	tracing.Inject(ctx, task.Header)
+	parent.End()
+	if err := worker.Enqueue(task); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	span := endedSpan(t, rec, "llm.diagnose")
+	if span.Parent().SpanID() != parent.SpanContext().SpanID() || span.SpanContext().TraceID() != parent.SpanContext().TraceID() {
+		t.Errorf("Expected the worker span to continue the task's trace, got parent %v", span.Parent())
+	}
+}
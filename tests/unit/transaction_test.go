@@ -0,0 +1,195 @@
+package unit
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/privacy"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nats-io/nats.go"
+	"gorm.io/gorm"
+)
+
+const assessBody = `{"age": 50, "gender": "Male", "systolic_bp": 120, "diastolic_bp": 80, "glucose": 100, "bmi": 25, "cholesterol": 190, "heart_rate": 72}`
+
+// failInserts makes inserts into table fail once the row is written, as a
+// crash right after it would; armed reports whether it's on
+func failInserts(t *testing.T, db *gorm.DB, table string) *atomic.Bool {
+	var armed atomic.Bool
+	armed.Store(true)
+	err := db.Callback().Create().After("gorm:create").Register("test:fail_"+table, func(tx *gorm.DB) {
+		if armed.Load() && tx.Statement.Table == table {
+			tx.AddError(errors.New("injected failure"))
+		}
+	})
+	if err != nil {
+		t.Fatalf("Registering the failure failed: %v", err)
+	}
+	return &armed
+}
+
+// newOutboxPatientHandler is newTestPatientHandler with an outbox whose
+// deliveries are collected rather than published
+func newOutboxPatientHandler(t *testing.T) (*fiber.App, *handlers.PatientHandler, *gorm.DB, *[]*nats.Msg) {
+	var hits atomic.Int64
+	h, db, pred := newTestPatientHandler(t, newFakeFullML(t, &hits).URL, handlers.NewWebSocketHandler())
+	db.AutoMigrate(&models.OutboxMessage{})
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1) // One in-memory database for every query
+
+	var delivered []*nats.Msg
+	pred.Outbox = services.NewOutbox(db, func(m *nats.Msg) error {
+		delivered = append(delivered, m)
+		return nil
+	})
+	app := fiber.New()
+	app.Post("/api/assess", h.AssessPatient)
+	return app, h, db, &delivered
+}
+
+func count(t *testing.T, db *gorm.DB, model any, where ...any) int64 {
+	t.Helper()
+	var n int64
+	q := db.Model(model)
+	if len(where) > 0 {
+		q = q.Where(where[0], where[1:]...)
+	}
+	if err := q.Count(&n).Error; err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	return n
+}
+
+func TestAssessPatient_FailedAuditLeavesNoPatient(t *testing.T) {
+	app, h, db, delivered := newOutboxPatientHandler(t)
+	armed := failInserts(t, db, "audit_logs") // The insert after the patient's
+
+	if code, _ := postJSON(t, app, "/api/assess", assessBody); code != 500 {
+		t.Fatalf("Expected the failed transaction to fail the request, got %d", code)
+	}
+	for name, model := range map[string]any{"patients": &models.PatientData{}, "audit entries": &models.AuditLog{}, "assessments": &models.Assessment{}, "outbox messages": &models.OutboxMessage{}} {
+		if n := count(t, db, model); n != 0 {
+			t.Errorf("Expected no %s, found %d", name, n)
+		}
+	}
+
+	// The chain continues from before the rolled-back entry
+	armed.Store(false)
+	if code, body := postJSON(t, app, "/api/assess", assessBody); code != 200 {
+		t.Fatalf("Expected the next assessment to succeed, got %d %v", code, body)
+	}
+	if ok, n, err := h.Audit.VerifyChain(); !ok || n != 2 {
+		t.Errorf("Expected the two new entries to verify, got %v %d %v", ok, n, err)
+	}
+	if _, err := h.Prediction.Outbox.Dispatch(); err != nil || len(*delivered) != 1 {
+		t.Errorf("Expected only the committed assessment's task, got %d (%v)", len(*delivered), err)
+	}
+}
+
+func TestAssessPatient_FailedAssessmentLeavesNoPredictionOrTask(t *testing.T) {
+	app, h, db, delivered := newOutboxPatientHandler(t)
+	failInserts(t, db, "assessments")
+
+	if code, _ := postJSON(t, app, "/api/assess", assessBody); code != 500 {
+		t.Fatalf("Expected the failed transaction to fail the request, got %d", code)
+	}
+
+	// The patient committed on its own, like one whose ML call failed
+	var patient models.PatientData
+	if err := db.First(&patient).Error; err != nil {
+		t.Fatalf("Expected the patient to be saved: %v", err)
+	}
+	if n := count(t, db, &models.AuditLog{}, "event_type = ?", "PATIENT_CREATED"); n != 1 {
+		t.Errorf("Expected the patient's audit entry, found %d", n)
+	}
+	if n := count(t, db, &models.AuditLog{}, "event_type = ?", "AI_PREDICTION"); n != 0 {
+		t.Errorf("Expected the prediction's audit entry rolled back, found %d", n)
+	}
+	if n := count(t, db, &models.Assessment{}); n != 0 {
+		t.Errorf("Expected no assessment, found %d", n)
+	}
+	if n := count(t, db, &models.OutboxMessage{}); n != 0 {
+		t.Errorf("Expected no diagnosis task, found %d", n)
+	}
+	if _, status := h.Prediction.Cache.Get(patient.ID); status == "pending" {
+		t.Error("Expected the diagnosis no longer pending")
+	}
+	if n, _ := h.Prediction.Outbox.Dispatch(); n != 0 || len(*delivered) != 0 {
+		t.Errorf("Expected nothing to publish, sent %d", n)
+	}
+	if ok, _, err := h.Audit.VerifyChain(); !ok {
+		t.Errorf("Expected the chain intact: %v", err)
+	}
+}
+
+func TestOutbox_SendsCommittedTasksOnce(t *testing.T) {
+	keys := useFieldKeys(t, 1)
+	app, h, db, delivered := newOutboxPatientHandler(t)
+
+	code, body := postJSON(t, app, "/api/assess", assessBody)
+	if code != 200 {
+		t.Fatalf("Assessment failed: %d %v", code, body)
+	}
+	if len(*delivered) != 0 {
+		t.Fatal("Expected nothing published before the dispatcher runs")
+	}
+	var queued models.OutboxMessage
+	db.First(&queued)
+	if queued.PatientID == 0 {
+		t.Error("Expected the task to name its patient")
+	}
+	if raw := rawColumn(t, db, "outbox_messages", "data", queued.ID); privacy.SealedWith(raw) != keys.CurrentID() || strings.Contains(raw, "systolic") {
+		t.Errorf("Expected the task sealed at rest, got %q", raw)
+	}
+	if n, err := h.Prediction.Outbox.Dispatch(); n != 1 || err != nil {
+		t.Fatalf("Expected one task sent, got %d (%v)", n, err)
+	}
+	msg := (*delivered)[0]
+	if msg.Subject != "llm.tasks" || msg.Header.Get(nats.MsgIdHdr) == "" {
+		t.Errorf("Expected a deduplicable llm.tasks message, got %q %v", msg.Subject, msg.Header)
+	}
+	if n, _ := h.Prediction.Outbox.Dispatch(); n != 0 || len(*delivered) != 1 {
+		t.Error("Expected a sent task not to be sent again")
+	}
+	if n := count(t, db, &models.OutboxMessage{}, "sent_at IS NOT NULL"); n != 1 {
+		t.Errorf("Expected the task marked sent, %d are", n)
+	}
+	if raw := rawColumn(t, db, "outbox_messages", "data", queued.ID); raw != "" {
+		t.Errorf("Expected a sent task's data cleared, got %q", raw)
+	}
+	if !strings.Contains(string(msg.Data), "systolic") {
+		t.Errorf("Expected the task delivered in plaintext, got %q", msg.Data)
+	}
+}
+
+func TestOutbox_FailedDeliveryIsRetried(t *testing.T) {
+	app, h, db, delivered := newOutboxPatientHandler(t)
+	postJSON(t, app, "/api/assess", assessBody)
+	postJSON(t, app, "/api/assess", assessBody)
+
+	outbox := h.Prediction.Outbox
+	deliver := outbox.Deliver
+	outbox.Deliver = func(*nats.Msg) error { return errors.New("nats down") }
+	if n, err := outbox.Dispatch(); n != 0 || err == nil {
+		t.Fatalf("Expected the failure reported, got %d (%v)", n, err)
+	}
+	var first models.OutboxMessage
+	db.First(&first)
+	if first.Attempts != 1 || first.LastError != "nats down" || first.SentAt != nil {
+		t.Errorf("Expected the failed attempt recorded, got %+v", first)
+	}
+
+	outbox.Deliver = deliver
+	if n, err := outbox.Dispatch(); n != 2 || err != nil {
+		t.Fatalf("Expected both tasks sent on retry, got %d (%v)", n, err)
+	}
+	if (*delivered)[0].Header.Get(nats.MsgIdHdr) >= (*delivered)[1].Header.Get(nats.MsgIdHdr) {
+		t.Error("Expected the tasks sent oldest first")
+	}
+}
@@ -0,0 +1,153 @@
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupUploads(t *testing.T) (*services.UploadService, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	db.AutoMigrate(&models.UploadedFile{})
+	return services.NewUploadService(db, t.TempDir(), time.Hour), db
+}
+
+// writeUpload creates a file in the uploads dir with the given age
+func writeUpload(t *testing.T, svc *services.UploadService, name string, age time.Duration) string {
+	path := filepath.Join(svc.Dir, name)
+	if err := os.WriteFile(path, []byte("video-"+name), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	old := time.Now().Add(-age)
+	os.Chtimes(path, old, old)
+	return path
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestUploads_RegisterHashesFile(t *testing.T) {
+	svc, _ := setupUploads(t)
+	path := writeUpload(t, svc, "a.mp4", 0)
+
+	rec, err := svc.Register(path, "vitals", "doctor_1")
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if rec.Status != services.UploadProcessing || rec.SizeBytes != int64(len("video-a.mp4")) || len(rec.SHA256) != 64 || rec.Owner != "doctor_1" {
+		t.Errorf("Unexpected record: %+v", rec)
+	}
+}
+
+func TestUploads_UsageReconcilesBothWays(t *testing.T) {
+	svc, db := setupUploads(t)
+	tracked := writeUpload(t, svc, "tracked.mp4", 0)
+	orphan := writeUpload(t, svc, "orphan.mp4", 0)
+	os.MkdirAll(filepath.Join(svc.Dir, "backups"), 0755) // Not an upload
+	if _, err := svc.Register(tracked, "vitals", "u"); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	db.Create(&models.UploadedFile{Path: filepath.Join(svc.Dir, "gone.mp4"), Status: services.UploadAnalyzed})
+
+	usage, err := svc.Usage()
+	if err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	if usage.Files != 2 || usage.Tracked != 1 || usage.InFlight != 1 {
+		t.Errorf("Unexpected counts: %+v", usage)
+	}
+	if len(usage.OrphanFiles) != 1 || usage.OrphanFiles[0] != orphan {
+		t.Errorf("Expected %s as the only orphan, got %v", orphan, usage.OrphanFiles)
+	}
+	if len(usage.MissingFiles) != 1 || filepath.Base(usage.MissingFiles[0]) != "gone.mp4" {
+		t.Errorf("Expected gone.mp4 missing, got %v", usage.MissingFiles)
+	}
+	if usage.Bytes != int64(len("video-tracked.mp4")+len("video-orphan.mp4")) {
+		t.Errorf("Unexpected bytes: %d", usage.Bytes)
+	}
+}
+
+func TestUploads_CleanupRemovesFinishedAndOldOrphans(t *testing.T) {
+	svc, db := setupUploads(t)
+
+	analyzed := writeUpload(t, svc, "analyzed.mp4", 0)
+	rec, _ := svc.Register(analyzed, "vitals", "u")
+	svc.Finish(rec.ID, true)
+
+	failed := writeUpload(t, svc, "failed.mp4", 0)
+	rec, _ = svc.Register(failed, "vitals", "u")
+	svc.Finish(rec.ID, false)
+
+	oldOrphan := writeUpload(t, svc, "old-orphan.mp4", 2*time.Hour)
+	newOrphan := writeUpload(t, svc, "new-orphan.mp4", 0) // Could be mid-save
+	db.Create(&models.UploadedFile{Path: filepath.Join(svc.Dir, "gone.mp4"), Status: services.UploadAnalyzed})
+
+	res, err := svc.Cleanup(time.Now())
+	if err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	if res.Removed != 2 || res.OrphansRemoved != 1 || res.MissingRows != 1 {
+		t.Errorf("Unexpected result: %+v", res)
+	}
+	if exists(analyzed) || exists(failed) || exists(oldOrphan) {
+		t.Error("Finished uploads and old orphans should be deleted")
+	}
+	if !exists(newOrphan) {
+		t.Error("A fresh untracked file may still be registering and must survive")
+	}
+
+	usage, _ := svc.Usage()
+	if len(usage.MissingFiles) != 0 || usage.Tracked != 0 {
+		t.Errorf("Expected registry reconciled after cleanup, got %+v", usage)
+	}
+	var deleted int64
+	db.Model(&models.UploadedFile{}).Where("status = ?", services.UploadDeleted).Count(&deleted)
+	if deleted != 3 {
+		t.Errorf("Expected 3 rows marked deleted, got %d", deleted)
+	}
+}
+
+func TestUploads_CleanupSparesInFlight(t *testing.T) {
+	svc, db := setupUploads(t)
+	path := writeUpload(t, svc, "busy.mp4", 0)
+	rec, _ := svc.Register(path, "vitals", "u")
+
+	// Even past the retention, an analysis still reading the file keeps it
+	if _, err := svc.Cleanup(time.Now().Add(48 * time.Hour)); err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	if !exists(path) {
+		t.Fatal("In-flight upload was deleted")
+	}
+
+	// A processing row from another replica is kept until it outlives the retention
+	other := writeUpload(t, svc, "other.mp4", 0)
+	db.Create(&models.UploadedFile{Path: other, Status: services.UploadProcessing})
+	svc.Cleanup(time.Now())
+	if !exists(other) {
+		t.Fatal("Recent processing upload was deleted")
+	}
+	svc.Cleanup(time.Now().Add(2 * time.Hour))
+	if exists(other) {
+		t.Error("Abandoned processing upload should be deleted after the retention")
+	}
+
+	svc.Finish(rec.ID, true)
+	svc.Cleanup(time.Now())
+	if exists(path) {
+		t.Error("Upload should be deleted once its analysis finished")
+	}
+}
@@ -0,0 +1,103 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"healthcare-backend/pkg/handlers"
+	"healthcare-backend/pkg/models"
+	"healthcare-backend/pkg/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func postUrgency(t *testing.T, app *fiber.App, body string) (int, models.UrgencyResponse) {
+	req := httptest.NewRequest("POST", "/api/urgency/predict", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, 5000)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	var result models.UrgencyResponse
+	json.NewDecoder(resp.Body).Decode(&result)
+	return resp.StatusCode, result
+}
+
+func TestUrgencyRoute_ForwardsSymptomsAndVitals(t *testing.T) {
+	var sent map[string]any
+	ml := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&sent)
+		json.NewEncoder(w).Encode(models.UrgencyResponse{UrgencyLevel: 3, UrgencyName: "Urgent", Confidence: "high"})
+	}))
+	t.Cleanup(ml.Close)
+
+	app := fiber.New()
+	app.Post("/api/urgency/predict", handlers.NewUrgencyHandler(services.NewPredictionService(ml.URL)).Predict)
+
+	code, result := postUrgency(t, app, `{"symptoms":["chest pain"],"age":64,"systolic_bp":165,"diastolic_bp":95,"glucose":140,"bmi":29.5}`)
+	if code != 200 || result.UrgencyLevel != 3 || result.Degraded {
+		t.Fatalf("Expected level 3 from the model, got %d %+v", code, result)
+	}
+	vitals, _ := sent["patient_data"].(map[string]any)
+	if vitals["age"] != float64(64) || sent["symptoms"] == nil {
+		t.Fatalf("Expected symptoms and vitals forwarded, got %v", sent)
+	}
+	// Omitted measurements are left for the model to impute, not sent as zero
+	if _, ok := vitals["cholesterol"]; ok {
+		t.Errorf("Expected omitted cholesterol left out, got %v", vitals["cholesterol"])
+	}
+
+	for _, body := range []string{`{"age":64,"systolic_bp":165,"diastolic_bp":95,"glucose":140,"bmi":29.5}`, `{"symptoms":["cough"],"age":64}`} {
+		if code, _ := postUrgency(t, app, body); code != 400 {
+			t.Errorf("%s: expected 400, got %d", body, code)
+		}
+	}
+}
+
+func TestUrgencyRoute_DegradedWhenMLDown(t *testing.T) {
+	app := fiber.New()
+	app.Post("/api/urgency/predict", handlers.NewUrgencyHandler(services.NewPredictionService("http://127.0.0.1:1")).Predict)
+
+	code, result := postUrgency(t, app, `{"symptoms":["headache"],"age":40,"systolic_bp":120,"diastolic_bp":80,"glucose":90,"bmi":24,"cholesterol":180,"heart_rate":70}`)
+	if code != 200 || !result.Degraded || result.UrgencyLevel != 0 {
+		t.Errorf("Expected a degraded level 0, got %d %+v", code, result)
+	}
+}
+
+func TestAssess_UrgencyDegradedWhenMLFails(t *testing.T) {
+	ml := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/urgency/predict":
+			http.Error(w, "model not loaded", http.StatusInternalServerError)
+		case "/predict":
+			json.NewEncoder(w).Encode(models.PredictResponse{HeartRisk: 20, ModelPrecisions: map[string]float64{"Heart_Model": 0.9}})
+		default:
+			json.NewEncoder(w).Encode(models.DiagnosisResponse{Diagnosis: "ok", Status: "ready"})
+		}
+	}))
+	t.Cleanup(ml.Close)
+
+	h, _, _ := newTestPatientHandler(t, ml.URL, handlers.NewWebSocketHandler())
+	app := fiber.New()
+	app.Post("/api/assess", h.AssessPatient)
+
+	body, _ := json.Marshal(models.PatientData{Age: 50, Gender: "Female", SystolicBP: 130, DiastolicBP: 85, Glucose: 100, BMI: 26, Cholesterol: 190, HeartRate: 72})
+	req := httptest.NewRequest("POST", "/api/assess", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, 5000)
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("Assess must not fail when urgency is unavailable: %v %v", err, resp)
+	}
+	var result models.FullAssessmentResponse
+	json.NewDecoder(resp.Body).Decode(&result)
+	if !result.Urgency.Degraded || result.Urgency.UrgencyLevel != 0 {
+		t.Errorf("Expected degraded urgency, got %+v", result.Urgency)
+	}
+	if result.Risks.HeartRisk != 20 {
+		t.Errorf("Expected the risk prediction kept, got %+v", result.Risks)
+	}
+}
@@ -0,0 +1,159 @@
+package unit
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"healthcare-backend/pkg/handlers"
+
+	"github.com/fasthttp/websocket"
+	contribws "github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+// fakeClock is a manually advanced clock for the sweep policy
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	f.now = f.now.Add(d)
+	f.mu.Unlock()
+}
+
+// newSweepServer serves the WS endpoint with a fake clock
+func newSweepServer(t *testing.T) (*handlers.WebSocketHandler, *fakeClock, func() *websocket.Conn) {
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)}
+	ws := handlers.NewWebSocketHandler()
+	ws.Now = clock.Now
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/ws/diagnostics", contribws.New(ws.HandleConnection))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	go app.Listener(ln)
+	t.Cleanup(func() { app.Shutdown() })
+
+	dial := func() *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial("ws://"+ln.Addr().String()+"/ws/diagnostics", nil)
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	return ws, clock, dial
+}
+
+// waitFor polls until cond holds or a second has passed
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatalf("Timed out waiting for %s", what)
+}
+
+func TestWSSweepClosesIdleConnections(t *testing.T) {
+	ws, clock, dial := newSweepServer(t)
+	idle := dial()
+	active := dial()
+	waitFor(t, "two connections", func() bool { return ws.Status().Connections == 2 })
+
+	clock.Advance(31 * time.Minute)
+	active.WriteJSON(map[string]any{"type": "subscribe", "patient_id": 1})
+	waitFor(t, "the subscription", func() bool { return ws.Status().PatientSubscriptions == 1 })
+
+	if res := ws.Sweep(); res.IdleClosed != 1 {
+		t.Fatalf("Expected one idle connection closed, got %+v", res)
+	}
+
+	idle.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err := idle.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseGoingAway || closeErr.Text != "idle timeout" {
+		t.Fatalf("Idle client should get a going-away close, got %v", err)
+	}
+	waitFor(t, "the idle connection to go", func() bool { return ws.Status().Connections == 1 })
+
+	// A second sweep at the same time keeps the active client
+	if res := ws.Sweep(); res.IdleClosed != 0 {
+		t.Errorf("Active client was closed: %+v", res)
+	}
+	status := ws.Status()
+	if status.Sweeps != 2 || status.IdleClosed != 1 || status.MaxIdleSeconds != 1800 || status.LastSweep == nil {
+		t.Errorf("Unexpected status %+v", status)
+	}
+}
+
+func TestWSSweepDropsFinishedDiagnosisSubscriptions(t *testing.T) {
+	ws, clock, dial := newSweepServer(t)
+	conn := dial()
+	conn.WriteJSON(map[string]any{"type": "subscribe", "patient_id": 5})
+	conn.WriteJSON(map[string]any{"type": "subscribe", "patient_id": 6})
+	waitFor(t, "both subscriptions", func() bool { return ws.Status().PatientSubscriptions == 2 })
+
+	ws.BroadcastDiagnosis(5, "Diagnosis text", "ready")
+	ws.BroadcastDiagnosis(6, "", "pending")
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	conn.ReadMessage()
+	conn.ReadMessage()
+
+	// Within the TTL nothing is swept
+	clock.Advance(30 * time.Minute)
+	if res := ws.Sweep(); res.SweptSubscriptions != 0 {
+		t.Fatalf("Swept too early: %+v", res)
+	}
+
+	// Keep the client active so only the subscription policy applies
+	clock.Advance(31 * time.Minute)
+	conn.WriteJSON(map[string]any{"type": "ping"})
+	time.Sleep(50 * time.Millisecond)
+
+	res := ws.Sweep()
+	if res.SweptSubscriptions != 1 || res.IdleClosed != 0 {
+		t.Fatalf("Expected patient 5's subscription swept, got %+v", res)
+	}
+	status := ws.Status()
+	if status.SubscribedPatients != 1 || status.PatientSubscriptions != 1 || status.Connections != 1 {
+		t.Errorf("Patient 6 should stay subscribed: %+v", status)
+	}
+
+	// Still-pending patient 6 keeps getting updates
+	ws.BroadcastDiagnosis(6, "Diagnosis text", "ready")
+	var msg struct {
+		PatientID uint   `json:"patient_id"`
+		Status    string `json:"status"`
+	}
+	if err := conn.ReadJSON(&msg); err != nil || msg.Status != "ready" {
+		t.Errorf("Expected patient 6's diagnosis, got %+v (%v)", msg, err)
+	}
+}
+
+func TestWSDisconnectRemovesEmptySubscriptionEntries(t *testing.T) {
+	ws, _, dial := newSweepServer(t)
+	conn := dial()
+	conn.WriteJSON(map[string]any{"type": "subscribe", "patient_id": 9})
+	waitFor(t, "the subscription", func() bool { return ws.Status().SubscribedPatients == 1 })
+
+	conn.Close()
+	waitFor(t, "the disconnect", func() bool {
+		s := ws.Status()
+		return s.Connections == 0 && s.SubscribedPatients == 0
+	})
+}